@@ -0,0 +1,279 @@
+package porcupine
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// A Transaction is a transaction in a history of a key-value store, for
+// [DetectAnomalies]: the reads, writes, and range scans it made, in order, and
+// whether it committed.
+type Transaction struct {
+	ClientId  int
+	Ops       []TransactionOp
+	Committed bool
+	Call      int64 // invocation time
+	Return    int64 // response time
+}
+
+// A TransactionOpKind is the kind of a TransactionOp.
+type TransactionOpKind string
+
+const (
+	ReadOp  TransactionOpKind = "read"
+	WriteOp TransactionOpKind = "write"
+	ScanOp  TransactionOpKind = "scan"
+)
+
+// A TransactionOp is a single operation in a Transaction.
+//
+// Values must be comparable, and dirty reads can only be told apart from
+// legitimate ones if no two writes to a key write the same value, which test
+// workloads usually ensure by writing unique values.
+type TransactionOp struct {
+	Kind TransactionOpKind
+	// the key read or written, or the inclusive start of the range scanned
+	Key string
+	// the exclusive end of the range scanned; "" means the end of the
+	// keyspace
+	End string
+	// the value read or written; a read of a missing key returns nil
+	Value interface{}
+	// the keys and values that a scan returned
+	Scan map[string]interface{}
+}
+
+func (op TransactionOp) inRange(key string) bool {
+	return key >= op.Key && (op.End == "" || key < op.End)
+}
+
+// An AnomalyKind is a kind of read anomaly.
+type AnomalyKind string
+
+const (
+	// a committed transaction read a value written by a transaction that
+	// aborted
+	AbortedRead AnomalyKind = "AbortedRead"
+	// a committed transaction read a value that the transaction that wrote
+	// it overwrote before committing
+	DirtyRead AnomalyKind = "DirtyRead"
+	// a transaction read a key twice and got different values, without
+	// writing it in between
+	NonRepeatableRead AnomalyKind = "NonRepeatableRead"
+	// a transaction scanned a range twice and got different sets of keys,
+	// without writing to the range in between
+	PhantomRead AnomalyKind = "PhantomRead"
+	// two committed transactions read the same value of a key, and both
+	// wrote the key, so one of the writes was lost
+	LostUpdate AnomalyKind = "LostUpdate"
+	// two committed transactions read the same values of two keys, and each
+	// wrote a different one of them, so neither saw the other's write
+	WriteSkew AnomalyKind = "WriteSkew"
+)
+
+// An Anomaly is a read anomaly found by [DetectAnomalies].
+type Anomaly struct {
+	Kind AnomalyKind
+	Key  string // the key read, or the start of the range scanned
+	// the indices in the history of the smallest set of transactions
+	// involved: the reader first, and for an aborted or dirty read, the
+	// writer; for a lost update or write skew, the two writers, in order
+	Transactions []int
+	Description  string
+}
+
+// DetectAnomalies looks for the anomalies that weak isolation levels allow in
+// a history of transactions, and reports each with the smallest set of
+// transactions involved, in the order of the first of them in the history, so
+// that it is clear which isolation mechanism is broken: aborted and dirty
+// reads break read committed, non-repeatable reads, phantom reads, and lost
+// updates break repeatable read and snapshot isolation, and write skew breaks
+// serializability. Unlike a linearizability check, it only looks for these
+// patterns, so it is fast, but it can't prove that the history is consistent.
+func DetectAnomalies(history []Transaction) []Anomaly {
+	type write struct {
+		key   string
+		value interface{}
+	}
+	type writer struct {
+		txn          int
+		committed    bool
+		intermediate bool // overwritten by the same transaction
+	}
+	writers := make(map[write]writer)
+	for i, txn := range history {
+		last := make(map[string]write)
+		for _, op := range txn.Ops {
+			if op.Kind != WriteOp {
+				continue
+			}
+			if w, ok := last[op.Key]; ok {
+				writers[w] = writer{i, txn.Committed, true}
+			}
+			w := write{op.Key, op.Value}
+			writers[w] = writer{i, txn.Committed, false}
+			last[op.Key] = w
+		}
+	}
+	var anomalies []Anomaly
+	for i, txn := range history {
+		written := make(map[write]bool) // by this transaction
+		observed := make(map[string]interface{})
+		scanned := make(map[[2]string][]string)
+		for _, op := range txn.Ops {
+			switch op.Kind {
+			case WriteOp:
+				written[write{op.Key, op.Value}] = true
+				observed[op.Key] = op.Value
+				for r := range scanned {
+					if (TransactionOp{Key: r[0], End: r[1]}).inRange(op.Key) {
+						delete(scanned, r)
+					}
+				}
+			case ReadOp:
+				if prev, ok := observed[op.Key]; ok && !reflect.DeepEqual(prev, op.Value) {
+					anomalies = append(anomalies, Anomaly{
+						Kind:         NonRepeatableRead,
+						Key:          op.Key,
+						Transactions: []int{i},
+						Description:  fmt.Sprintf("read %v from key %q after reading %v", op.Value, op.Key, prev),
+					})
+				}
+				observed[op.Key] = op.Value
+				w := write{op.Key, op.Value}
+				if op.Value == nil || written[w] || !txn.Committed {
+					continue
+				}
+				if by, ok := writers[w]; ok && (!by.committed || by.intermediate) {
+					kind, reason := AbortedRead, "an aborted transaction"
+					if by.committed {
+						kind, reason = DirtyRead, "a transaction that overwrote it"
+					}
+					anomalies = append(anomalies, Anomaly{
+						Kind:         kind,
+						Key:          op.Key,
+						Transactions: []int{i, by.txn},
+						Description:  fmt.Sprintf("read %v from key %q, written by %s", op.Value, op.Key, reason),
+					})
+				}
+			case ScanOp:
+				keys := make([]string, 0, len(op.Scan))
+				for k := range op.Scan {
+					keys = append(keys, k)
+				}
+				sort.Strings(keys)
+				r := [2]string{op.Key, op.End}
+				if prev, ok := scanned[r]; ok && !reflect.DeepEqual(prev, keys) {
+					anomalies = append(anomalies, Anomaly{
+						Kind:         PhantomRead,
+						Key:          op.Key,
+						Transactions: []int{i},
+						Description:  fmt.Sprintf("scan of [%q, %q) returned keys %q after returning %q", op.Key, op.End, keys, prev),
+					})
+				}
+				scanned[r] = keys
+			}
+		}
+	}
+	anomalies = append(anomalies, detectWriteAnomalies(history)...)
+	sort.SliceStable(anomalies, func(a, b int) bool {
+		return anomalies[a].Transactions[0] < anomalies[b].Transactions[0]
+	})
+	return anomalies
+}
+
+// detectWriteAnomalies looks for lost updates and write skew among the
+// committed transactions of a history. A transaction that reads a value of a
+// key and then writes the key overwrites that value, so two transactions that
+// read the same value of a key and both overwrite it, or that read the same
+// values of two keys and overwrite one each, each overwrote a value that the
+// other read, which no serial order allows.
+func detectWriteAnomalies(history []Transaction) []Anomaly {
+	type version struct {
+		key   string
+		value interface{}
+	}
+	// the value of each key that each transaction read before it wrote the
+	// key, if it did, and the keys it wrote
+	readBefore := make([]map[string]interface{}, len(history))
+	wrote := make([]map[string]bool, len(history))
+	// the transactions that read each value of a key and then overwrote it
+	overwriters := make(map[version][]int)
+	for i, txn := range history {
+		if !txn.Committed {
+			continue
+		}
+		readBefore[i] = make(map[string]interface{})
+		wrote[i] = make(map[string]bool)
+		for _, op := range txn.Ops {
+			switch op.Kind {
+			case ReadOp:
+				if _, ok := readBefore[i][op.Key]; !ok && !wrote[i][op.Key] {
+					readBefore[i][op.Key] = op.Value
+				}
+			case WriteOp:
+				if !wrote[i][op.Key] {
+					if v, ok := readBefore[i][op.Key]; ok {
+						overwriters[version{op.Key, v}] = append(overwriters[version{op.Key, v}], i)
+					}
+				}
+				wrote[i][op.Key] = true
+			}
+		}
+	}
+	var anomalies []Anomaly
+	for v, txns := range overwriters {
+		for _, j := range txns[1:] {
+			anomalies = append(anomalies, Anomaly{
+				Kind:         LostUpdate,
+				Key:          v.key,
+				Transactions: []int{txns[0], j},
+				Description:  fmt.Sprintf("both read %v from key %q and overwrote it", v.value, v.key),
+			})
+		}
+	}
+	for i := range history {
+		if readBefore[i] == nil {
+			continue
+		}
+		for x := range wrote[i] {
+			vx, ok := readBefore[i][x]
+			if !ok {
+				continue
+			}
+			for y, vy := range readBefore[i] {
+				if wrote[i][y] {
+					continue
+				}
+				// another transaction that read y as vy and overwrote
+				// it, and read x as vx, but didn't write it
+				for _, j := range overwriters[version{y, vy}] {
+					if j <= i || wrote[j][x] {
+						continue
+					}
+					if v, ok := readBefore[j][x]; !ok || v != vx {
+						continue
+					}
+					anomalies = append(anomalies, Anomaly{
+						Kind:         WriteSkew,
+						Key:          x,
+						Transactions: []int{i, j},
+						Description:  fmt.Sprintf("both read %v from key %q and %v from key %q, and overwrote one each", vx, x, vy, y),
+					})
+				}
+			}
+		}
+	}
+	sort.SliceStable(anomalies, func(a, b int) bool {
+		ta, tb := anomalies[a].Transactions, anomalies[b].Transactions
+		if ta[0] != tb[0] {
+			return ta[0] < tb[0]
+		}
+		if ta[1] != tb[1] {
+			return ta[1] < tb[1]
+		}
+		return anomalies[a].Key < anomalies[b].Key
+	})
+	return anomalies
+}
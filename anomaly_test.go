@@ -0,0 +1,104 @@
+package porcupine
+
+import "testing"
+
+func TestDetectAnomalies(t *testing.T) {
+	history := []Transaction{
+		// 0: aborted write
+		{ClientId: 0, Ops: []TransactionOp{
+			{Kind: WriteOp, Key: "x", Value: 1},
+		}},
+		// 1: overwrites its own write before committing
+		{ClientId: 1, Committed: true, Ops: []TransactionOp{
+			{Kind: WriteOp, Key: "y", Value: 2},
+			{Kind: WriteOp, Key: "y", Value: 3},
+		}},
+		// 2: reads both dirty values, and the committed one
+		{ClientId: 2, Committed: true, Ops: []TransactionOp{
+			{Kind: ReadOp, Key: "x", Value: 1},
+			{Kind: ReadOp, Key: "y", Value: 2},
+			{Kind: ReadOp, Key: "y", Value: 3},
+		}},
+		// 3: a phantom, then a scan after its own insert, which is fine
+		{ClientId: 3, Committed: true, Ops: []TransactionOp{
+			{Kind: ScanOp, Key: "a", End: "m", Scan: map[string]interface{}{"b": 1}},
+			{Kind: ScanOp, Key: "a", End: "m", Scan: map[string]interface{}{"b": 1, "c": 2}},
+			{Kind: WriteOp, Key: "d", Value: 4},
+			{Kind: ScanOp, Key: "a", End: "m", Scan: map[string]interface{}{"b": 1, "c": 2, "d": 4}},
+		}},
+		// 4: reads its own write, and an aborted transaction's dirty read
+		// doesn't matter
+		{ClientId: 4, Ops: []TransactionOp{
+			{Kind: WriteOp, Key: "z", Value: 5},
+			{Kind: ReadOp, Key: "z", Value: 5},
+			{Kind: ReadOp, Key: "x", Value: 1},
+		}},
+	}
+	anomalies := DetectAnomalies(history)
+	expected := []struct {
+		kind AnomalyKind
+		key  string
+		txns []int
+	}{
+		{AbortedRead, "x", []int{2, 0}},
+		{DirtyRead, "y", []int{2, 1}},
+		{NonRepeatableRead, "y", []int{2}},
+		{PhantomRead, "a", []int{3}},
+	}
+	if len(anomalies) != len(expected) {
+		t.Fatalf("expected %d anomalies, got %+v", len(expected), anomalies)
+	}
+	for i, e := range expected {
+		a := anomalies[i]
+		if a.Kind != e.kind || a.Key != e.key || len(a.Transactions) != len(e.txns) {
+			t.Fatalf("anomaly %d: expected %v on %q, got %+v", i, e.kind, e.key, a)
+		}
+		for j := range e.txns {
+			if a.Transactions[j] != e.txns[j] {
+				t.Fatalf("anomaly %d: expected transactions %v, got %v", i, e.txns, a.Transactions)
+			}
+		}
+		if a.Description == "" {
+			t.Fatalf("anomaly %d: expected a description", i)
+		}
+	}
+}
+
+func TestDetectWriteAnomalies(t *testing.T) {
+	read := func(key string, value interface{}) TransactionOp {
+		return TransactionOp{Kind: ReadOp, Key: key, Value: value}
+	}
+	write := func(key string, value interface{}) TransactionOp {
+		return TransactionOp{Kind: WriteOp, Key: key, Value: value}
+	}
+	history := []Transaction{
+		// 0-1: both increment the counter from 0
+		{ClientId: 0, Committed: true, Ops: []TransactionOp{read("n", 0), write("n", 1)}},
+		{ClientId: 1, Committed: true, Ops: []TransactionOp{read("n", 0), write("n", 2)}},
+		// 2-3: both take someone off call
+		{ClientId: 2, Committed: true, Ops: []TransactionOp{read("alice", true), read("bob", true), write("alice", false)}},
+		{ClientId: 3, Committed: true, Ops: []TransactionOp{read("alice", true), read("bob", true), write("bob", false)}},
+		// 4: reads the counter after an update, which is fine, and an
+		// aborted transaction doesn't count
+		{ClientId: 4, Committed: true, Ops: []TransactionOp{read("n", 2), write("n", 3)}},
+		{ClientId: 5, Ops: []TransactionOp{read("n", 2), write("n", 4)}},
+	}
+	anomalies := DetectAnomalies(history)
+	expected := []struct {
+		kind AnomalyKind
+		key  string
+		txns []int
+	}{
+		{LostUpdate, "n", []int{0, 1}},
+		{WriteSkew, "alice", []int{2, 3}},
+	}
+	if len(anomalies) != len(expected) {
+		t.Fatalf("expected %d anomalies, got %+v", len(expected), anomalies)
+	}
+	for i, e := range expected {
+		a := anomalies[i]
+		if a.Kind != e.kind || a.Key != e.key || len(a.Transactions) != 2 || a.Transactions[0] != e.txns[0] || a.Transactions[1] != e.txns[1] {
+			t.Fatalf("anomaly %d: expected %v on %q of %v, got %+v", i, e.kind, e.key, e.txns, a)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package porcupine
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// A BatchItem is a history to check as part of a batch; see [CheckBatch].
+type BatchItem struct {
+	// Name of the history, e.g., the file it was read from.
+	Name    string
+	Model   Model
+	History []Operation
+}
+
+// A BatchReport summarizes the checks of a batch of histories.
+type BatchReport struct {
+	// Illegal if any history is not linearizable; otherwise Unknown if any
+	// check did not run to completion, and Ok if every history is
+	// linearizable.
+	Result CheckResult `json:"result"`
+	// Wall-clock time the batch took, in nanoseconds in JSON.
+	Elapsed time.Duration     `json:"elapsed"`
+	Items   []BatchItemReport `json:"items"`
+}
+
+// A BatchItemReport describes the check of a single history in a batch.
+type BatchItemReport struct {
+	Name   string `json:"name"`
+	Report Report `json:"report"`
+}
+
+// JUnitSuites returns a suite for each history in the batch, to pass to
+// [WriteJUnitReport].
+func (r BatchReport) JUnitSuites() []JUnitSuite {
+	suites := make([]JUnitSuite, len(r.Items))
+	for i, item := range r.Items {
+		suites[i] = JUnitSuite{Name: item.Name, Report: item.Report}
+	}
+	return suites
+}
+
+// CheckBatch checks many histories, e.g., the histories recorded by a
+// nightly CI job, with shared resources, and returns a report for the whole
+// batch, with a verbose report for each history.
+//
+// The histories are checked a few at a time, on a shared pool of workers: a
+// WithParallelism bound applies to all of the checks together, and defaults
+// to runtime.GOMAXPROCS(0). Likewise, a WithMemoryBudget budget is shared by
+// all of the checks, and once the batch goes over it, the checks that are
+// still running return Unknown. The timeout applies to the whole batch;
+// histories that have not been checked by then are reported as Unknown. A
+// timeout of 0 is interpreted as an unlimited timeout. Progress reporting,
+// WithCheckpoint and WithDecisionTrace are not supported, and are ignored.
+func CheckBatch(items []BatchItem, timeout time.Duration, opts ...CheckOption) BatchReport {
+	start := time.Now()
+	o := checkOptions{}.apply(opts)
+	ctx := o.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	parallelism := o.parallelism
+	if parallelism <= 0 {
+		parallelism = runtime.GOMAXPROCS(0)
+	}
+	o.verbose = true
+	o.timeout = 0
+	o.ctx = ctx
+	o.progress = nil
+	o.checkpointPath = ""
+	o.decisionTrace = nil
+	if o.workers == nil {
+		o.workers = make(chan struct{}, parallelism)
+	}
+	o.budget = newMemoryBudget(o.memoryBudget)
+
+	report := BatchReport{Result: Ok, Items: make([]BatchItemReport, len(items))}
+	next := make(chan int, len(items))
+	for i := range items {
+		next <- i
+	}
+	close(next)
+	var wg sync.WaitGroup
+	for k := 0; k < parallelism && k < len(items); k++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				itemStart := time.Now()
+				res, info := checkOperations(items[i].Model, items[i].History, nil, o)
+				report.Items[i] = BatchItemReport{
+					Name:   items[i].Name,
+					Report: NewReport(res, info, time.Since(itemStart)),
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	for _, item := range report.Items {
+		switch {
+		case item.Report.Result == Illegal:
+			report.Result = Illegal
+		case item.Report.Result == Unknown && report.Result == Ok:
+			report.Result = Unknown
+		}
+	}
+	report.Elapsed = time.Since(start)
+	return report
+}
@@ -0,0 +1,52 @@
+package porcupine
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCheckBatch(t *testing.T) {
+	var items []BatchItem
+	for _, name := range []string{"c01-ok", "c01-bad", "c10-ok", "c10-bad"} {
+		ops := kvOperations(parseKvLog("test_data/kv/" + name + ".txt"))
+		items = append(items, BatchItem{Name: name, Model: kvModel, History: ops})
+	}
+	report := CheckBatch(items, 0, WithParallelism(2))
+	if report.Result != Illegal {
+		t.Fatalf("expected output %v, got output %v", Illegal, report.Result)
+	}
+	expected := []CheckResult{Ok, Illegal, Ok, Illegal}
+	for i, item := range report.Items {
+		if item.Name != items[i].Name {
+			t.Fatalf("expected item %d to be %s, got %s", i, items[i].Name, item.Name)
+		}
+		if item.Report.Result != expected[i] {
+			t.Fatalf("%s: expected output %v, got output %v", item.Name, expected[i], item.Report.Result)
+		}
+		if len(item.Report.Partitions) == 0 {
+			t.Fatalf("%s: expected a verbose report", item.Name)
+		}
+	}
+	if suites := report.JUnitSuites(); len(suites) != len(items) || suites[1].Name != "c01-bad" {
+		t.Fatalf("unexpected JUnit suites: %v", suites)
+	}
+	if report := CheckBatch(items[:1], 0); report.Result != Ok {
+		t.Fatalf("expected output %v, got output %v", Ok, report.Result)
+	}
+}
+
+func TestCheckBatchTimeout(t *testing.T) {
+	ops := kvOperations(parseKvLog("test_data/kv/c10-ok.txt"))
+	items := []BatchItem{
+		{Name: "slow", Model: kvNoPartitionModel, History: ops},
+		{Name: "slow-too", Model: kvNoPartitionModel, History: ops},
+	}
+	start := time.Now()
+	report := CheckBatch(items, 100*time.Millisecond)
+	if report.Result != Unknown {
+		t.Fatalf("expected output %v, got output %v", Unknown, report.Result)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("batch took too long to stop: %v", elapsed)
+	}
+}
@@ -16,10 +16,27 @@ func newBitset(bits uint) bitset {
 	return bitset(make([]uint64, chunks))
 }
 
-func (b bitset) clone() bitset {
-	dataCopy := make([]uint64, len(b))
-	copy(dataCopy, b)
-	return bitset(dataCopy)
+// bitsetSlabSize is how many bitsets a bitsetArena allocates at once.
+const bitsetSlabSize = 256
+
+// A bitsetArena allocates the bitsets that the search stores in its cache
+// from large slabs, so that a long search makes one allocation per slab
+// instead of one per visited state, which keeps it from spending much of its
+// time in the allocator and garbage collector. A slab is freed once none of
+// its bitsets are in use.
+type bitsetArena struct {
+	slab []uint64
+}
+
+// clone returns a copy of b allocated from the arena.
+func (a *bitsetArena) clone(b bitset) bitset {
+	if len(a.slab) < len(b) {
+		a.slab = make([]uint64, len(b)*bitsetSlabSize)
+	}
+	c := a.slab[:len(b):len(b)]
+	a.slab = a.slab[len(b):]
+	copy(c, b)
+	return bitset(c)
 }
 
 func bitsetIndex(pos uint) (uint, uint) {
@@ -38,6 +55,19 @@ func (b bitset) clear(pos uint) bitset {
 	return b
 }
 
+func (b bitset) get(pos uint) bool {
+	major, minor := bitsetIndex(pos)
+	return b[major]&(1<<minor) != 0
+}
+
+// union sets the bits of b that are set in b2, which must be as long.
+func (b bitset) union(b2 bitset) bitset {
+	for i, v := range b2 {
+		b[i] |= v
+	}
+	return b
+}
+
 func (b bitset) popcnt() uint {
 	total := 0
 	for _, v := range b {
@@ -46,12 +76,13 @@ func (b bitset) popcnt() uint {
 	return uint(total)
 }
 
-func (b bitset) hash() uint64 {
-	hash := uint64(b.popcnt())
-	for _, v := range b {
-		hash ^= v
-	}
-	return hash
+// bitHash returns the hash of a single set bit. The hash of a bitset is the
+// XOR of the hashes of its set bits, so that the search can update the hash
+// of its set of linearized operations in constant time as it sets and clears
+// bits, rather than rehashing every word, which dominates the search of
+// partitions with thousands of operations.
+func bitHash(pos uint) uint64 {
+	return mix64(uint64(pos) + 1)
 }
 
 func (b bitset) equals(b2 bitset) bool {
@@ -65,3 +96,93 @@ func (b bitset) equals(b2 bitset) bool {
 	}
 	return true
 }
+
+// A compactBitset is a copy of a bitset that leaves out its leading words
+// with every bit set and its trailing words with no bits set. The search
+// mostly linearizes operations in the order they were called, so the sets
+// of linearized operations that it stores in its cache are mostly a run of
+// set bits followed by a run of clear bits, and their compact copies take
+// space proportional to the operations in progress rather than the length of
+// the partition.
+type compactBitset struct {
+	full  int    // number of leading words with every bit set
+	words bitset // the words after them, up to the last nonzero word
+}
+
+// fullWordHashes returns the hashes of bitsets whose first i words have
+// every bit set, and whose other bits are clear, for i up to words.
+func fullWordHashes(words int) []uint64 {
+	hashes := make([]uint64, words+1)
+	for i := 0; i < words; i++ {
+		hashes[i+1] = hashes[i]
+		for j := 0; j < 64; j++ {
+			hashes[i+1] ^= bitHash(uint(i*64 + j))
+		}
+	}
+	return hashes
+}
+
+// hash returns the hash of the bitset that c is a compact copy of, given the
+// hashes of full words from fullWordHashes.
+func (c compactBitset) hash(fullHashes []uint64) uint64 {
+	hash := fullHashes[c.full]
+	for i, v := range c.words {
+		for v != 0 {
+			hash ^= bitHash(uint((c.full+i)*64 + bits.TrailingZeros64(v)))
+			v &= v - 1
+		}
+	}
+	return hash
+}
+
+// A linearizedSet is the set of operations that the search has linearized.
+// Along with the bitset, it keeps the bitset's hash, and the bounds of its
+// compact form, up to date as bits are set and cleared, so that the search
+// does constant work per step on them in the common case, rather than work
+// proportional to the length of the partition.
+type linearizedSet struct {
+	bits bitset
+	hash uint64 // XOR of the bitHash of each set bit
+	full int    // number of leading words with every bit set
+	end  int    // number of words up to and including the last nonzero word
+}
+
+func newLinearizedSet(n uint) *linearizedSet {
+	return &linearizedSet{bits: newBitset(n)}
+}
+
+func (s *linearizedSet) set(pos uint) {
+	major, _ := bitsetIndex(pos)
+	s.bits.set(pos)
+	s.hash ^= bitHash(pos)
+	if int(major) >= s.end {
+		s.end = int(major) + 1
+	}
+	for s.full < len(s.bits) && s.bits[s.full] == ^uint64(0) {
+		s.full++
+	}
+}
+
+func (s *linearizedSet) clear(pos uint) {
+	major, _ := bitsetIndex(pos)
+	s.bits.clear(pos)
+	s.hash ^= bitHash(pos)
+	if int(major) < s.full {
+		s.full = int(major)
+	}
+	for s.end > 0 && s.bits[s.end-1] == 0 {
+		s.end--
+	}
+}
+
+// compact returns a compact copy of the set, allocated from the arena.
+func (s *linearizedSet) compact(arena *bitsetArena) compactBitset {
+	return compactBitset{s.full, arena.clone(s.bits[s.full:s.end])}
+}
+
+// equals reports whether the set is the one that c is a compact copy of.
+// Compact copies are canonical, so this only compares the words between the
+// bounds.
+func (s *linearizedSet) equals(c compactBitset) bool {
+	return s.full == c.full && s.bits[s.full:s.end].equals(c.words)
+}
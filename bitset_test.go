@@ -0,0 +1,102 @@
+package porcupine
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+// registerHistory returns a linearizable register history of n operations,
+// in rounds of four concurrent operations: a put, and gets that return the
+// value before and after it.
+func registerHistory(n int) []Operation {
+	history := make([]Operation, 0, n)
+	for i := 0; len(history) < n; i++ {
+		t := int64(4 * i)
+		round := []Operation{
+			{ClientId: 0, Input: registerInput{false, i + 1}, Call: t, Output: 0, Return: t + 3},
+			{ClientId: 1, Input: registerInput{true, 0}, Call: t, Output: i, Return: t + 3},
+			{ClientId: 2, Input: registerInput{true, 0}, Call: t + 1, Output: i + 1, Return: t + 3},
+			{ClientId: 3, Input: registerInput{true, 0}, Call: t + 1, Output: i + 1, Return: t + 3},
+		}
+		for _, op := range round {
+			if len(history) < n {
+				history = append(history, op)
+			}
+		}
+	}
+	return history
+}
+
+func TestLinearizedSet(t *testing.T) {
+	const n = 300
+	s := newLinearizedSet(n)
+	fullHashes := fullWordHashes(len(s.bits))
+	rng := rand.New(rand.NewSource(0))
+	var copies []compactBitset
+	var arena bitsetArena
+	for step := 0; step < 10000; step++ {
+		// mostly set bits in order, like the search does
+		pos := uint(rng.Intn(n))
+		if rng.Intn(4) != 0 {
+			pos = uint(step % n)
+		}
+		if s.bits[pos/64]&(1<<(pos%64)) != 0 {
+			s.clear(pos)
+		} else {
+			s.set(pos)
+		}
+		full, end := 0, 0
+		for full < len(s.bits) && s.bits[full] == ^uint64(0) {
+			full++
+		}
+		for i, v := range s.bits {
+			if v != 0 {
+				end = i + 1
+			}
+		}
+		if s.full != full || s.end != end {
+			t.Fatalf("step %d: expected bounds [%d, %d), got [%d, %d)", step, full, end, s.full, s.end)
+		}
+		c := s.compact(&arena)
+		if !s.equals(c) || c.hash(fullHashes) != s.hash {
+			t.Fatalf("step %d: expected the compact copy to match the set", step)
+		}
+		copies = append(copies, c)
+	}
+	// the last copy is the only one of the current set, unless the set has
+	// been the same before
+	for i, c := range copies[:len(copies)-1] {
+		if s.equals(c) != (c.hash(fullHashes) == s.hash) {
+			t.Fatalf("copy %d: equality disagrees with the hash", i)
+		}
+	}
+	// bits in the same position of different words hash differently
+	if bitHash(1) == bitHash(65) {
+		t.Fatal("expected bits 1 and 65 to hash differently")
+	}
+}
+
+func TestUnpartitionedRegister(t *testing.T) {
+	history := registerHistory(5000)
+	if res := CheckOperations(registerModel, history); !res {
+		t.Fatal("expected operations to be linearizable")
+	}
+	history[len(history)-1].Output = -1
+	if res := CheckOperations(registerModel, history); res {
+		t.Fatal("expected operations not to be linearizable")
+	}
+}
+
+func BenchmarkUnpartitionedRegister(b *testing.B) {
+	for _, n := range []int{5000, 50000} {
+		history := registerHistory(n)
+		b.Run(fmt.Sprint(n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if !CheckOperations(registerModel, history) {
+					b.Fatal("expected operations to be linearizable")
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package porcupine
+
+import (
+	"fmt"
+	"sort"
+)
+
+// A BlockingPair is a proximate cause of a linearizability failure, found by
+// [LinearizationInfo.BlockingPairs]: an operation that could be linearized
+// next at the end of one of the longest partial linearizations, but that the
+// model rejects there, along with the operation that blocks it.
+type BlockingPair struct {
+	Partition int
+	// the operation that can't be linearized
+	Blocked Operation
+	// the latest operation of the partial linearization that the model
+	// would accept Blocked right before, so that Blocked can't follow it;
+	// nil if the model rejects Blocked in every state of the partial
+	// linearization, including the initial state
+	Blocker *Operation
+	// the model's description of the state at the end of the partial
+	// linearization, in which Blocked is rejected
+	State string
+}
+
+// Describe describes the pair using the model's DescribeOperation, e.g.,
+// "get() -> '2' cannot follow put('5')", or, without a blocker, "get() ->
+// '3' is rejected in every state from the initial one to "5"".
+func (p BlockingPair) Describe(model Model) string {
+	model = fillDefault(model)
+	blocked := model.DescribeOperation(p.Blocked.Input, p.Blocked.Output)
+	if p.Blocker == nil {
+		return fmt.Sprintf("%s is rejected in every state from the initial one to %q", blocked, p.State)
+	}
+	return fmt.Sprintf("%s cannot follow %s", blocked, model.DescribeOperation(p.Blocker.Input, p.Blocker.Output))
+}
+
+// BlockingPairs returns the proximate causes of a failed linearizability
+// check: at the failure frontier, the end of each of the longest partial
+// linearizations of a partition that is not linearizable, the operations
+// that could go next but that the model rejects, each paired with the
+// operation that blocks it. The blocker is found by replaying the partial
+// linearization: it's the latest operation such that the model accepts the
+// blocked operation in the state before it, but in none of the states after
+// it. The pairs are sorted by partition, and by the blocked operation's Call.
+//
+// Partitions whose check timed out are skipped, since their search didn't
+// exhaust its options. The LinearizationInfo must come from one of the
+// verbose check functions, e.g., [CheckOperationsVerbose].
+func (li *LinearizationInfo) BlockingPairs(model Model) []BlockingPair {
+	model = fillDefault(model)
+	var pairs []BlockingPair
+	for p, history := range li.history {
+		if p < len(li.timedOut) && li.timedOut[p] {
+			continue
+		}
+		ops := entryOperations(history)
+		longest := 0
+		for _, partial := range li.partialLinearizations[p] {
+			if len(partial) > longest {
+				longest = len(partial)
+			}
+		}
+		if longest == len(ops) {
+			continue
+		}
+		partials := li.partialLinearizations[p]
+		if longest == 0 {
+			// no operation can be linearized first, so the search is
+			// stuck in the initial state, with nothing linearized
+			partials = [][]int{{}}
+		}
+		type pair struct{ blocked, blocker int }
+		seen := make(map[pair]bool)
+		var found []BlockingPair
+		for _, partial := range partials {
+			if len(partial) != longest {
+				continue
+			}
+			// states[k] is the state after the first k operations
+			states := make([]interface{}, len(partial)+1)
+			states[0] = model.Init()
+			linearized := make([]bool, len(ops))
+			for k, id := range partial {
+				_, states[k+1] = model.Step(states[k], ops[id].Input, ops[id].Output)
+				linearized[id] = true
+			}
+			for _, id := range stuckOperations(model, ops, linearized, states[len(partial)]) {
+				blocker := -1
+				for k := len(partial) - 1; k >= 0; k-- {
+					if ok, _ := model.Step(states[k], ops[id].Input, ops[id].Output); ok {
+						blocker = partial[k]
+						break
+					}
+				}
+				if seen[pair{id, blocker}] {
+					continue
+				}
+				seen[pair{id, blocker}] = true
+				bp := BlockingPair{
+					Partition: p,
+					Blocked:   ops[id],
+					State:     model.DescribeState(states[len(partial)]),
+				}
+				if blocker >= 0 {
+					op := ops[blocker]
+					bp.Blocker = &op
+				}
+				found = append(found, bp)
+			}
+		}
+		sort.SliceStable(found, func(i, j int) bool {
+			return found[i].Blocked.Call < found[j].Blocked.Call
+		})
+		pairs = append(pairs, found...)
+	}
+	return pairs
+}
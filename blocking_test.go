@@ -0,0 +1,57 @@
+package porcupine
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBlockingPairs(t *testing.T) {
+	ops := []Operation{
+		{0, registerInput{false, 2}, 0, 0, 10},
+		{1, registerInput{false, 5}, 20, 0, 30},
+		{0, registerInput{true, 0}, 40, 2, 50},
+		{2, registerInput{true, 0}, 40, 3, 50},
+	}
+	res, info := CheckOperationsVerbose(registerModel, ops, 0)
+	if res != Illegal {
+		t.Fatalf("expected output %v, got output %v", Illegal, res)
+	}
+	pairs := info.BlockingPairs(registerModel)
+	if len(pairs) != 2 {
+		t.Fatalf("expected 2 blocking pairs, got %+v", pairs)
+	}
+	expected := BlockingPair{Partition: 0, Blocked: ops[2], Blocker: &ops[1], State: "5"}
+	if !reflect.DeepEqual(pairs[0], expected) && !reflect.DeepEqual(pairs[1], expected) {
+		t.Fatalf("expected the read of 2 to be blocked by the write of 5, got %+v", pairs)
+	}
+	for _, pair := range pairs {
+		var description string
+		switch pair.Blocked {
+		case ops[2]:
+			description = "get() -> '2' cannot follow put('5')"
+		case ops[3]:
+			description = "get() -> '3' is rejected in every state from the initial one to \"5\""
+		}
+		if d := pair.Describe(registerModel); d != description {
+			t.Fatalf("expected description %q, got %q", description, d)
+		}
+	}
+
+	// a read that can't go first is rejected in the initial state
+	res, info = CheckOperationsVerbose(registerModel, []Operation{ops[3]}, 0)
+	if res != Illegal {
+		t.Fatalf("expected output %v, got output %v", Illegal, res)
+	}
+	expected = BlockingPair{Partition: 0, Blocked: ops[3], State: "0"}
+	if pairs := info.BlockingPairs(registerModel); len(pairs) != 1 || !reflect.DeepEqual(pairs[0], expected) {
+		t.Fatalf("expected the read of 3 to be rejected in the initial state, got %+v", pairs)
+	}
+
+	res, info = CheckOperationsVerbose(registerModel, ops[:2], 0)
+	if res != Ok {
+		t.Fatalf("expected output %v, got output %v", Ok, res)
+	}
+	if pairs := info.BlockingPairs(registerModel); len(pairs) != 0 {
+		t.Fatalf("expected no blocking pairs, got %+v", pairs)
+	}
+}
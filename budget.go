@@ -0,0 +1,52 @@
+package porcupine
+
+import "sync/atomic"
+
+// Estimated sizes, in bytes, of the checker's bookkeeping. They leave out the
+// states themselves, which belong to the model, and only need to be accurate
+// enough to stop a search well before it exhausts the machine's memory.
+const (
+	cacheEntryOverhead = 64 // cacheEntry, bitset header, and map bucket share
+	callsEntrySize     = 24 // callsEntry on the stack
+)
+
+// memoryBudget tracks the estimated memory used by the search (the cache of
+// visited states and the stack of linearized calls) across all partitions of
+// a check, against a limit.
+type memoryBudget struct {
+	used     int64 // accessed atomically
+	exceeded int32 // accessed atomically
+	limit    int64
+}
+
+func newMemoryBudget(limit int64) *memoryBudget {
+	if limit <= 0 {
+		return nil
+	}
+	return &memoryBudget{limit: limit}
+}
+
+// charge accounts for n more bytes (or n fewer, if n is negative), and
+// reports whether the search is still within the budget. It always succeeds
+// on a nil receiver, so the checker can call it unconditionally.
+func (b *memoryBudget) charge(n int64) bool {
+	if b == nil {
+		return true
+	}
+	if atomic.AddInt64(&b.used, n) > b.limit {
+		atomic.StoreInt32(&b.exceeded, 1)
+		return false
+	}
+	return true
+}
+
+// isExceeded reports whether the search has gone over the budget.
+func (b *memoryBudget) isExceeded() bool {
+	return b != nil && atomic.LoadInt32(&b.exceeded) != 0
+}
+
+// cacheEntryCost returns the estimated size of a cache entry whose set of
+// linearized operations takes the given number of words.
+func cacheEntryCost(words int) int64 {
+	return cacheEntryOverhead + 8*int64(words)
+}
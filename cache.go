@@ -0,0 +1,109 @@
+package porcupine
+
+import "container/list"
+
+// A StateCache is the set of search states that the checker has visited
+// while checking a partition of a history: pairs of a model state and the set
+// of operations linearized to reach it. The checker doesn't search onward
+// from a state that it finds in the cache, because it has already searched
+// from an equal one.
+//
+// The default cache keeps every state it visits, which can use a lot of
+// memory for gigantic partitions. A custom cache, set with [WithStateCache],
+// can bound its memory by forgetting states, e.g., [NewLRUStateCache]. This
+// doesn't affect the result of the check, but the search may then visit the
+// same states again, so it trades completeness of the cache, and so search
+// time, for memory.
+//
+// A cache is only used by the search of a single partition, from a single
+// goroutine.
+type StateCache interface {
+	// Lookup returns the states stored under the given hash. It may
+	// return fewer states than were stored, e.g., if some were evicted.
+	Lookup(hash uint64) []CachedState
+	// Store adds a state under the given hash. The checker only stores a
+	// state after looking it up and finding no equal one.
+	Store(hash uint64, state CachedState)
+}
+
+// A CachedState is a search state stored in a [StateCache]. Caches only need
+// to store and return it; its contents are private to the checker.
+type CachedState struct {
+	entry cacheEntry
+}
+
+// mapStateCache is the default StateCache, which keeps every state.
+type mapStateCache map[uint64][]CachedState
+
+func (c mapStateCache) Lookup(hash uint64) []CachedState {
+	return c[hash]
+}
+
+func (c mapStateCache) Store(hash uint64, state CachedState) {
+	c[hash] = append(c[hash], state)
+}
+
+// lruStateCache is a StateCache that keeps a bounded number of states,
+// evicting the least recently used.
+type lruStateCache struct {
+	capacity int
+	order    *list.List // of *lruItem, most recently used first
+	items    map[uint64][]*list.Element
+}
+
+type lruItem struct {
+	hash  uint64
+	state CachedState
+}
+
+// NewLRUStateCache returns a [StateCache] that keeps at most capacity
+// states, evicting the least recently used state when it is full. Use it
+// with [WithStateCache], e.g.,
+//
+//	porcupine.WithStateCache(func() porcupine.StateCache {
+//		return porcupine.NewLRUStateCache(1 << 20)
+//	})
+func NewLRUStateCache(capacity int) StateCache {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &lruStateCache{
+		capacity: capacity,
+		order:    list.New(),
+		items:    make(map[uint64][]*list.Element),
+	}
+}
+
+func (c *lruStateCache) Lookup(hash uint64) []CachedState {
+	elems := c.items[hash]
+	if len(elems) == 0 {
+		return nil
+	}
+	states := make([]CachedState, len(elems))
+	for i, e := range elems {
+		c.order.MoveToFront(e)
+		states[i] = e.Value.(*lruItem).state
+	}
+	return states
+}
+
+func (c *lruStateCache) Store(hash uint64, state CachedState) {
+	if c.order.Len() >= c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		item := oldest.Value.(*lruItem)
+		elems := c.items[item.hash]
+		for i, e := range elems {
+			if e == oldest {
+				elems = append(elems[:i], elems[i+1:]...)
+				break
+			}
+		}
+		if len(elems) == 0 {
+			delete(c.items, item.hash)
+		} else {
+			c.items[item.hash] = elems
+		}
+	}
+	c.items[hash] = append(c.items[hash], c.order.PushFront(&lruItem{hash, state}))
+}
@@ -0,0 +1,49 @@
+package porcupine
+
+import "testing"
+
+func TestWithStateCache(t *testing.T) {
+	for _, tc := range []struct {
+		file     string
+		expected CheckResult
+	}{
+		{"test_data/kv/c10-ok.txt", Ok},
+		{"test_data/kv/c10-bad.txt", Illegal},
+	} {
+		ops := kvOperations(parseKvLog(tc.file))
+		for _, capacity := range []int{1, 16, 1 << 20} {
+			// a check that stops early can call newCache after it returns
+			capacity := capacity
+			newCache := func() StateCache { return NewLRUStateCache(capacity) }
+			if res := CheckOperationsTimeout(kvModel, ops, 0, WithStateCache(newCache)); res != tc.expected {
+				t.Fatalf("%s: capacity %d: expected output %v, got output %v", tc.file, capacity, tc.expected, res)
+			}
+		}
+	}
+}
+
+func TestLRUStateCache(t *testing.T) {
+	cache := NewLRUStateCache(2)
+	state := func(id int) CachedState {
+		return CachedState{cacheEntry{stateId: id}}
+	}
+	cache.Store(1, state(1))
+	cache.Store(1, state(2))
+	if states := cache.Lookup(1); len(states) != 2 {
+		t.Fatalf("expected 2 states, got %d", len(states))
+	}
+	// the lookup moved state 2 to the front last, so state 1 is the least
+	// recently used
+	cache.Store(2, state(3))
+	states := cache.Lookup(1)
+	if len(states) != 1 || states[0].entry.stateId != 2 {
+		t.Fatalf("expected only state 2 under hash 1, got %v", states)
+	}
+	if states := cache.Lookup(2); len(states) != 1 || states[0].entry.stateId != 3 {
+		t.Fatalf("expected state 3 under hash 2, got %v", states)
+	}
+	cache.Store(3, state(4))
+	if states := cache.Lookup(1); len(states) != 0 {
+		t.Fatalf("expected hash 1 to be evicted, got %v", states)
+	}
+}
@@ -0,0 +1,207 @@
+package porcupine
+
+import (
+	"fmt"
+	"sort"
+)
+
+// A CausalViolationKind is a kind of causal consistency violation.
+type CausalViolationKind string
+
+const (
+	// the causal order, of sessions and of reads from writes, has a cycle
+	CyclicCausality CausalViolationKind = "CyclicCausality"
+	// a read returned the initial value, after a write to the key that
+	// causally precedes it
+	InitialReadAfterWrite CausalViolationKind = "InitialReadAfterWrite"
+	// a read returned a write that another write to the key, which causally
+	// precedes the read, overwrote
+	OverwrittenRead CausalViolationKind = "OverwrittenRead"
+)
+
+// A CausalViolation is a violation of causal consistency, found by
+// [CheckCausalConsistency].
+type CausalViolation struct {
+	Kind    CausalViolationKind
+	Session string
+	// the index in the history of the read, or for a cycle, of an
+	// operation on it
+	Operation int
+	// the index in the history of the write that the read observed, or
+	// -1 if it observed the initial value or the violation is a cycle
+	Write       int
+	Description string
+}
+
+// CheckCausalConsistency checks whether the reads in a history are causally
+// consistent across keys, where access says how each operation accesses the
+// store, as for [CheckSessionGuarantees], with the same requirements on the
+// values. The causal order is each session's order, where each client is a
+// session, together with the order of each write before the reads that
+// observe it. A read violates causal consistency if it misses a write to
+// its key that causally precedes it: if it returns the initial value after
+// one, or a write that one overwrote.
+//
+// It returns the violations in the order of the history, or a single
+// violation if the causal order has a cycle. The check takes O(n^2) time and
+// O(n^2) bits of memory.
+func CheckCausalConsistency(history []Operation, access func(op Operation) (KeyAccess, bool)) []CausalViolation {
+	return checkCausalConsistency(history, operationSessions(history, nil), access)
+}
+
+// CheckTaggedCausalConsistency is like [CheckCausalConsistency], but the
+// sessions are given by [TaggedOperation.Session].
+func CheckTaggedCausalConsistency(history []TaggedOperation, access func(op Operation) (KeyAccess, bool)) []CausalViolation {
+	ops, tags := splitTaggedOperations(history)
+	return checkCausalConsistency(ops, operationSessions(ops, tags), access)
+}
+
+func checkCausalConsistency(history []Operation, opSessions []string, access func(op Operation) (KeyAccess, bool)) []CausalViolation {
+	type write struct {
+		key   string
+		value interface{}
+	}
+	var ops []int // the operations that access the store
+	accesses := make([]KeyAccess, len(history))
+	writes := make(map[write]int)
+	bySession := make(map[string][]int)
+	for i, op := range history {
+		a, ok := access(op)
+		if !ok {
+			continue
+		}
+		accesses[i] = a
+		ops = append(ops, i)
+		bySession[opSessions[i]] = append(bySession[opSessions[i]], i)
+		if a.Write {
+			writes[write{a.Key, a.Value}] = i
+		}
+	}
+	// the causal order, as edges between operations
+	succs := make(map[int][]int)
+	preds := make(map[int]int) // the number of direct predecessors
+	edge := func(from, to int) {
+		succs[from] = append(succs[from], to)
+		preds[to]++
+	}
+	for _, session := range bySession {
+		sort.SliceStable(session, func(a, b int) bool {
+			return history[session[a]].Call < history[session[b]].Call
+		})
+		for j := 1; j < len(session); j++ {
+			edge(session[j-1], session[j])
+		}
+	}
+	readsFrom := make(map[int]int)
+	for _, i := range ops {
+		if a := accesses[i]; !a.Write {
+			if w, ok := writes[write{a.Key, a.Value}]; ok {
+				readsFrom[i] = w
+				edge(w, i)
+			}
+		}
+	}
+	// the causal predecessors of each operation, computed in a topological
+	// order of the operations
+	before := make(map[int]bitset, len(ops))
+	var ready []int
+	for _, i := range ops {
+		if preds[i] == 0 {
+			ready = append(ready, i)
+		}
+	}
+	for len(ready) > 0 {
+		i := ready[0]
+		ready = ready[1:]
+		if before[i] == nil {
+			before[i] = newBitset(uint(len(history)))
+		}
+		for _, j := range succs[i] {
+			if before[j] == nil {
+				before[j] = newBitset(uint(len(history)))
+			}
+			before[j].union(before[i]).set(uint(i))
+			preds[j]--
+			if preds[j] == 0 {
+				ready = append(ready, j)
+			}
+		}
+	}
+	for _, i := range ops {
+		if preds[i] > 0 {
+			return []CausalViolation{{
+				Kind:        CyclicCausality,
+				Session:     opSessions[i],
+				Operation:   i,
+				Write:       -1,
+				Description: fmt.Sprintf("operation %d causally precedes itself", i),
+			}}
+		}
+	}
+	// the writes to each key
+	keyWrites := make(map[string][]int)
+	for _, i := range ops {
+		if a := accesses[i]; a.Write {
+			keyWrites[a.Key] = append(keyWrites[a.Key], i)
+		}
+	}
+	var violations []CausalViolation
+	for _, i := range ops {
+		a := accesses[i]
+		if a.Write {
+			continue
+		}
+		w, ok := readsFrom[i]
+		if !ok {
+			w = -1
+		}
+		for _, other := range keyWrites[a.Key] {
+			if other == w || !before[i].get(uint(other)) {
+				continue
+			}
+			if !ok {
+				violations = append(violations, CausalViolation{
+					Kind:        InitialReadAfterWrite,
+					Session:     opSessions[i],
+					Operation:   i,
+					Write:       -1,
+					Description: fmt.Sprintf("read the initial value of key %q after the write of %v", a.Key, accesses[other].Value),
+				})
+				break
+			}
+			if before[other].get(uint(w)) {
+				violations = append(violations, CausalViolation{
+					Kind:        OverwrittenRead,
+					Session:     opSessions[i],
+					Operation:   i,
+					Write:       w,
+					Description: fmt.Sprintf("read %v from key %q after the write of %v, which overwrote it", a.Value, a.Key, accesses[other].Value),
+				})
+				break
+			}
+		}
+	}
+	return violations
+}
+
+// A CausalResult is the result of [CheckLinearizableCausal].
+type CausalResult struct {
+	// the result of checking each key's operations for linearizability
+	Linearizable CheckResult
+	// the violations of causal consistency across keys
+	Violations []CausalViolation
+}
+
+// CheckLinearizableCausal checks the guarantees that several multi-key stores
+// advertise: that the operations on each key are linearizable, which it
+// checks with the model, which must partition histories by key, and that the
+// order in which each session observes operations across keys is causally
+// consistent, which it checks with [CheckCausalConsistency]. The options
+// apply to the linearizability check.
+func CheckLinearizableCausal(model Model, history []Operation, access func(op Operation) (KeyAccess, bool), opts ...CheckOption) CausalResult {
+	res, _ := CheckHistory(model, history, opts...)
+	return CausalResult{
+		Linearizable: res,
+		Violations:   CheckCausalConsistency(history, access),
+	}
+}
@@ -0,0 +1,85 @@
+package porcupine
+
+import "testing"
+
+func kvAccess(op Operation) (KeyAccess, bool) {
+	in := op.Input.(kvInput)
+	if in.op == 0 {
+		return KeyAccess{Key: in.key, Value: op.Output.(kvOutput).value}, true
+	}
+	return KeyAccess{Key: in.key, Write: true, Value: in.value}, true
+}
+
+func TestCheckCausalConsistency(t *testing.T) {
+	put := func(key, value string) kvInput { return kvInput{op: 1, key: key, value: value} }
+	get := func(key, value string) kvInput { return kvInput{op: 0, key: key, value: value} }
+	for _, tc := range []struct {
+		name     string
+		history  []Operation
+		expected []CausalViolation
+	}{
+		{
+			"causal",
+			kvProgram(
+				[]kvInput{put("x", "1"), put("y", "1")},
+				[]kvInput{get("y", ""), get("x", "")},
+				[]kvInput{get("x", "1"), get("y", "")},
+			),
+			nil,
+		},
+		{
+			"initial read",
+			kvProgram(
+				[]kvInput{put("x", "1"), put("y", "1")},
+				[]kvInput{get("y", "1"), get("x", "")},
+			),
+			[]CausalViolation{{Kind: InitialReadAfterWrite, Session: "client 1", Operation: 3, Write: -1}},
+		},
+		{
+			"overwritten read",
+			kvProgram(
+				[]kvInput{put("x", "1"), put("x", "2")},
+				[]kvInput{get("x", "2"), get("x", "1")},
+			),
+			[]CausalViolation{{Kind: OverwrittenRead, Session: "client 1", Operation: 3, Write: 0}},
+		},
+		{
+			"cycle",
+			kvProgram(
+				[]kvInput{get("x", "1"), put("x", "1")},
+			),
+			[]CausalViolation{{Kind: CyclicCausality, Session: "client 0", Operation: 0, Write: -1}},
+		},
+	} {
+		violations := CheckCausalConsistency(tc.history, kvAccess)
+		if len(violations) != len(tc.expected) {
+			t.Fatalf("%s: expected violations %+v, got %+v", tc.name, tc.expected, violations)
+		}
+		for i, e := range tc.expected {
+			v := violations[i]
+			if v.Kind != e.Kind || v.Session != e.Session || v.Operation != e.Operation || v.Write != e.Write || v.Description == "" {
+				t.Fatalf("%s: expected violations %+v, got %+v", tc.name, tc.expected, violations)
+			}
+		}
+	}
+}
+
+func TestCheckLinearizableCausal(t *testing.T) {
+	ops := kvProgram(
+		[]kvInput{{op: 1, key: "x", value: "1"}, {op: 1, key: "y", value: "1"}},
+		[]kvInput{{op: 0, key: "x", value: "1"}, {op: 0, key: "y", value: "1"}},
+	)
+	res := CheckLinearizableCausal(kvModel, ops, kvAccess)
+	if res.Linearizable != Ok || len(res.Violations) != 0 {
+		t.Fatalf("expected linearizable and causal, got %+v", res)
+	}
+	// a stale read on one key is causal, but not linearizable
+	history := kvProgram(
+		[]kvInput{{op: 1, key: "x", value: "1"}},
+		[]kvInput{{op: 0, key: "y"}, {op: 0, key: "x"}},
+	)
+	res = CheckLinearizableCausal(kvModel, history, kvAccess)
+	if res.Linearizable != Illegal || len(res.Violations) != 0 {
+		t.Fatalf("expected causal but not linearizable, got %+v", res)
+	}
+}
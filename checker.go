@@ -1,6 +1,10 @@
 package porcupine
 
 import (
+	"context"
+	"io"
+	"reflect"
+	"runtime"
 	"sort"
 	"sync/atomic"
 	"time"
@@ -19,12 +23,19 @@ type entry struct {
 	id       int
 	time     int64
 	clientId int
+	tags     map[string]string
 }
 
 type LinearizationInfo struct {
 	history               [][]entry // for each partition, a list of entries
 	partialLinearizations [][][]int // for each partition, a set of histories (list of ids)
 	annotations           []Annotation
+	showPlacement         bool
+	timedOut              []bool // for each partition, whether its check timed out
+	// for each partition, the length of the longest linearizable prefix
+	// found, and the number of operations; computed by every check
+	prefixes   []int
+	operations []int
 }
 
 // PartialLinearizations returns partial linearizations found during the
@@ -38,6 +49,45 @@ func (li *LinearizationInfo) PartialLinearizations() [][][]int {
 	return li.partialLinearizations
 }
 
+// TimedOutPartitions returns the indices of the partitions whose checks ran
+// out of time, as set with [WithPartitionTimeout]. Partitions that were never
+// checked because the check as a whole timed out are not included.
+func (li *LinearizationInfo) TimedOutPartitions() []int {
+	var partitions []int
+	for i, timedOut := range li.timedOut {
+		if timedOut {
+			partitions = append(partitions, i)
+		}
+	}
+	return partitions
+}
+
+// LongestPrefixes returns, for each partition, the number of operations in
+// the longest linearizable prefix that the check found, along with the number
+// of operations in the partition. Unlike the partial linearizations, these are
+// cheap to track, so they are returned by checks that are not verbose too,
+// e.g., by [CheckHistory] without [WithVerbose], to show whether a check that
+// timed out was almost done or hopeless. A partition that was never checked,
+// because the check stopped first, has a prefix of 0.
+func (li *LinearizationInfo) LongestPrefixes() (linearized, operations []int) {
+	if li.prefixes == nil && li.history != nil {
+		// restored from a saved result, which only has the partial
+		// linearizations
+		linearized = make([]int, len(li.history))
+		operations = make([]int, len(li.history))
+		for i, partition := range li.history {
+			operations[i] = len(partition) / 2
+			for _, partial := range li.partialLinearizations[i] {
+				if len(partial) > linearized[i] {
+					linearized[i] = len(partial)
+				}
+			}
+		}
+		return linearized, operations
+	}
+	return li.prefixes, li.operations
+}
+
 // PartialLinearizationsOperations returns partial linearizations found during
 // the linearizability check, as sets of sequences of [Operation].
 //
@@ -97,6 +147,62 @@ func (li *LinearizationInfo) PartialLinearizationsOperations() [][][]Operation {
 	return result
 }
 
+// A PartitionInfo is what a check found about one partition of a history, as
+// returned by [LinearizationInfo.Partitions].
+type PartitionInfo struct {
+	// the partition's operations, indexed by their IDs
+	Operations []Operation
+	// the partial linearizations found, as sequences of operation IDs and
+	// as sequences of operations, as from
+	// [LinearizationInfo.PartialLinearizations] and
+	// [LinearizationInfo.PartialLinearizationsOperations]
+	PartialLinearizationIds [][]int
+	PartialLinearizations   [][]Operation
+	// the number of operations in the longest linearizable prefix found
+	Linearized int
+	// whether the partition's check ran out of time; see
+	// [LinearizationInfo.TimedOutPartitions]
+	TimedOut bool
+}
+
+// Linearizable returns whether the partition was fully linearized.
+func (p PartitionInfo) Linearizable() bool {
+	return p.Linearized == len(p.Operations)
+}
+
+// Partitions returns what the check found about each partition of the
+// history, gathering the operations, partial linearizations, and status of
+// each in one place, rather than in parallel slices indexed by partition.
+//
+// The LinearizationInfo must come from one of the verbose check functions,
+// e.g., [CheckOperationsVerbose]; otherwise, there are no partitions.
+func (li *LinearizationInfo) Partitions() []PartitionInfo {
+	linearized, _ := li.LongestPrefixes()
+	partitions := make([]PartitionInfo, len(li.history))
+	for p, history := range li.history {
+		ops := entryOperations(history)
+		info := PartitionInfo{
+			Operations: ops,
+			TimedOut:   p < len(li.timedOut) && li.timedOut[p],
+		}
+		if p < len(linearized) {
+			info.Linearized = linearized[p]
+		}
+		if p < len(li.partialLinearizations) {
+			info.PartialLinearizationIds = li.partialLinearizations[p]
+			info.PartialLinearizations = make([][]Operation, len(li.partialLinearizations[p]))
+			for i, partial := range li.partialLinearizations[p] {
+				info.PartialLinearizations[i] = make([]Operation, len(partial))
+				for j, id := range partial {
+					info.PartialLinearizations[i][j] = ops[id]
+				}
+			}
+		}
+		partitions[p] = info
+	}
+	return partitions
+}
+
 type byTime []entry
 
 func (a byTime) Len() int {
@@ -116,20 +222,109 @@ func (a byTime) Less(i, j int) bool {
 	return a[i].kind == callEntry && a[j].kind == returnEntry
 }
 
-func makeEntries(history []Operation) []entry {
+// makeEntries converts a history to a list of entries. If tags is not nil,
+// tags[i] holds the tags for history[i].
+func makeEntries(history []Operation, tags []map[string]string) []entry {
 	var entries []entry = nil
 	id := 0
 	for _, elem := range history {
+		var t map[string]string
+		if tags != nil {
+			t = tags[id]
+		}
 		entries = append(entries, entry{
-			callEntry, elem.Input, id, elem.Call, elem.ClientId})
+			callEntry, elem.Input, id, elem.Call, elem.ClientId, t})
 		entries = append(entries, entry{
-			returnEntry, elem.Output, id, elem.Return, elem.ClientId})
+			returnEntry, elem.Output, id, elem.Return, elem.ClientId, t})
 		id++
 	}
 	sort.Sort(byTime(entries))
 	return entries
 }
 
+type tagKey struct {
+	clientId int
+	call     int64
+	ret      int64
+}
+
+// tagMatcher recovers the tags of operations after they have been through
+// the model's Partition function, which only deals in plain Operations.
+type tagMatcher struct {
+	history []Operation
+	tags    []map[string]string
+	byKey   map[tagKey][]int
+}
+
+func newTagMatcher(history []Operation, tags []map[string]string) *tagMatcher {
+	m := &tagMatcher{history: history, tags: tags, byKey: make(map[tagKey][]int)}
+	for i, op := range history {
+		key := tagKey{op.ClientId, op.Call, op.Return}
+		m.byKey[key] = append(m.byKey[key], i)
+	}
+	return m
+}
+
+// take returns the tags for the given operation, and removes it from the
+// matcher, so that identical-looking operations get their own tags.
+func (m *tagMatcher) take(op Operation) map[string]string {
+	if idx := m.index(op); idx >= 0 {
+		return m.tags[idx]
+	}
+	return nil
+}
+
+// index returns the index of the given operation in the original history, or
+// -1 if it isn't there, and removes it from the matcher.
+func (m *tagMatcher) index(op Operation) int {
+	key := tagKey{op.ClientId, op.Call, op.Return}
+	candidates := m.byKey[key]
+	for i, idx := range candidates {
+		orig := m.history[idx]
+		if reflect.DeepEqual(orig.Input, op.Input) && reflect.DeepEqual(orig.Output, op.Output) {
+			m.byKey[key] = append(candidates[:i:i], candidates[i+1:]...)
+			return idx
+		}
+	}
+	return -1
+}
+
+// splitTaggedOperations separates a tagged history into plain operations and
+// their tags.
+func splitTaggedOperations(history []TaggedOperation) ([]Operation, []map[string]string) {
+	ops := make([]Operation, len(history))
+	tags := make([]map[string]string, len(history))
+	for i, op := range history {
+		ops[i] = op.Operation
+		tags[i] = op.Tags
+	}
+	return ops, tags
+}
+
+// splitTaggedEvents separates a tagged history into plain events and their
+// tags, keyed by event ID, merging the tags of each call and return.
+func splitTaggedEvents(history []TaggedEvent) ([]Event, map[int]map[string]string) {
+	events := make([]Event, len(history))
+	tags := make(map[int]map[string]string)
+	for i, e := range history {
+		events[i] = e.Event
+		if len(e.Tags) == 0 {
+			continue
+		}
+		merged := tags[e.Id]
+		if merged == nil {
+			merged = make(map[string]string)
+			tags[e.Id] = merged
+		}
+		for k, v := range e.Tags {
+			if _, ok := merged[k]; !ok || e.Kind == ReturnEvent {
+				merged[k] = v
+			}
+		}
+	}
+	return events, tags
+}
+
 type node struct {
 	value interface{}
 	match *node // call if match is nil, otherwise return
@@ -176,46 +371,80 @@ func renumber(events []Event) []Event {
 	return e
 }
 
-func convertEntries(events []Event) []entry {
+// convertEntries converts a history to a list of entries. If tags is not
+// nil, tags[i] holds the tags for events[i].
+func convertEntries(events []Event, tags []map[string]string) []entry {
 	var entries []entry
 	for i, elem := range events {
 		kind := callEntry
 		if elem.Kind == ReturnEvent {
 			kind = returnEntry
 		}
+		var t map[string]string
+		if tags != nil {
+			t = tags[i]
+		}
 		// use index as "time"
-		entries = append(entries, entry{kind, elem.Value, elem.Id, int64(i), elem.ClientId})
+		entries = append(entries, entry{kind, elem.Value, elem.Id, int64(i), elem.ClientId, t})
 	}
 	return entries
 }
 
 func makeLinkedEntries(entries []entry) *node {
 	var root *node = nil
-	match := make(map[int]*node)
+	// the nodes live as long as the search, so they are allocated together
+	nodes := make([]node, len(entries))
+	match := make(map[int]*node, len(entries)/2)
 	for i := len(entries) - 1; i >= 0; i-- {
 		elem := entries[i]
+		entry := &nodes[i]
 		if elem.kind == returnEntry {
-			entry := &node{value: elem.value, match: nil, id: elem.id}
+			*entry = node{value: elem.value, match: nil, id: elem.id}
 			match[elem.id] = entry
-			insertBefore(entry, root)
-			root = entry
 		} else {
-			entry := &node{value: elem.value, match: match[elem.id], id: elem.id}
-			insertBefore(entry, root)
-			root = entry
+			*entry = node{value: elem.value, match: match[elem.id], id: elem.id}
 		}
+		insertBefore(entry, root)
+		root = entry
 	}
 	return root
 }
 
 type cacheEntry struct {
-	linearized bitset
+	linearized compactBitset
 	state      interface{}
+	stateId    int // ID of the interned state, or -1 if states are not interned
 }
 
-func cacheContains(model Model, cache map[uint64][]cacheEntry, entry cacheEntry) bool {
-	for _, elem := range cache[entry.linearized.hash()] {
-		if entry.linearized.equals(elem.linearized) && model.Equal(entry.state, elem.state) {
+// cacheKey returns the key of a cache entry: the hash of the set of
+// linearized operations, which the caller passes in because the search keeps
+// it up to date as it goes, combined with the hash of the state if the model
+// has a Hash function, so that entries with different states rarely need to
+// be compared with Equal.
+func cacheKey(model Model, entry cacheEntry, linearizedHash uint64) uint64 {
+	hash := linearizedHash
+	if entry.stateId >= 0 {
+		hash ^= mix64(uint64(entry.stateId))
+	} else if model.Hash != nil {
+		hash ^= mix64(model.Hash(entry.state))
+	}
+	return hash
+}
+
+// cacheContains reports whether the cache has an entry for the given state
+// with the given operations linearized.
+func cacheContains(model Model, cache StateCache, hash uint64, linearized *linearizedSet, state interface{}, stateId int) bool {
+	for _, cached := range cache.Lookup(hash) {
+		elem := cached.entry
+		if !linearized.equals(elem.linearized) {
+			continue
+		}
+		if stateId >= 0 {
+			// interned states are equal exactly when their IDs are
+			if stateId == elem.stateId {
+				return true
+			}
+		} else if model.Equal(state, elem.state) {
 			return true
 		}
 	}
@@ -247,36 +476,210 @@ func unlift(entry *node) {
 	entry.next.prev = entry
 }
 
-func checkSingle(model Model, history []entry, computePartial bool, kill *int32) (bool, []*[]int) {
+// yieldBatch is how many search steps a partition's search takes between
+// turns on its worker; see yieldWorker.
+const yieldBatch = 1 << 14
+
+// yieldWorker gives up a worker from a pool of workers and waits for one
+// again. Goroutines waiting for a worker get one in the order they started
+// waiting, so the partitions that are waiting go first, and a slow partition
+// can't keep the others from being checked, e.g., from finding that one is
+// not linearizable, which would stop the check early.
+func yieldWorker(workers chan struct{}) {
+	<-workers
+	workers <- struct{}{}
+}
+
+// searchOptions holds the settings for checking a single partition, along
+// with the state that its search shares with the rest of the check.
+type searchOptions struct {
+	computePartial bool
+	internStates   bool
+	reduceSymmetry bool
+	maxDepth       int    // 0 means no limit; see WithMaxDepth
+	kill           *int32 // set to stop the search of every partition
+	expired        *int32 // set to stop the search of this partition
+	prog           *partitionProgress
+	budget         *memoryBudget
+	cp             *partitionCheckpoint
+	workers        chan struct{}             // optional; a pool of workers that the search takes turns on
+	metrics        *PartitionMetrics         // optional; filled in when the search returns
+	newCache       func() StateCache         // optional; see WithStateCache
+	ordering       func(a, b Operation) bool // optional; see WithOrdering
+	prefix         *int64                    // length of the longest linearized prefix; accessed atomically
+	trace          *partitionTracer          // optional; see WithDecisionTrace
+	// optional; if set, the search enumerates the complete linearizations
+	// instead of stopping at the first, calling onComplete with the final
+	// state of each
+	onComplete func(state interface{})
+}
+
+// recordLongest records the stack of linearized calls as the longest
+// linearizable prefix for each of its operations, where it is longer than the
+// one recorded so far.
+func recordLongest(calls []callsEntry, longest []*[]int) {
+	callsLen := len(calls)
+	var seq []int = nil
+	for _, v := range calls {
+		if longest[v.entry.id] == nil || callsLen > len(*longest[v.entry.id]) {
+			// create seq lazily
+			if seq == nil {
+				seq = make([]int, len(calls))
+				for i, v := range calls {
+					seq[i] = v.entry.id
+				}
+			}
+			longest[v.entry.id] = &seq
+		}
+	}
+}
+
+// checkSingle checks whether a single partition is linearizable. It returns
+// Unknown if the search was stopped before it could decide. If opts.workers
+// is not nil, the search holds one of its workers, and takes turns with the
+// other searches that share it.
+func checkSingle(model Model, history []entry, opts searchOptions) (CheckResult, []*[]int) {
+	budget, cp, prog := opts.budget, opts.cp, opts.prog
+	if opts.ordering != nil {
+		history = orderEntries(history, opts.ordering)
+	}
 	entry := makeLinkedEntries(history)
 	n := length(entry) / 2
-	linearized := newBitset(uint(n))
-	cache := make(map[uint64][]cacheEntry) // map from hash to cache entry
+	// memory charged to the budget, released when the search is done
+	var charged int64
+	defer func() { budget.charge(-charged) }()
+	linearized := newLinearizedSet(uint(n))
+	var arena bitsetArena
+	var cache StateCache = make(mapStateCache) // map from hash to cache entry
+	// the budget can't see what a custom cache keeps
+	chargeCache := opts.newCache == nil
+	if opts.newCache != nil {
+		cache = opts.newCache()
+	}
+	var interner *stateInterner
+	if opts.internStates {
+		interner = newStateInterner(model)
+	}
+	var sym *symmetry
+	if opts.reduceSymmetry {
+		sym = newSymmetry(history)
+	}
 	var calls []callsEntry
 	// longest linearizable prefix that includes the given entry
 	longest := make([]*[]int, n)
 
 	state := model.Init()
 	headEntry := insertBefore(&node{value: nil, match: nil, id: -1}, entry)
-	for headEntry.next != nil {
-		if atomic.LoadInt32(kill) != 0 {
-			return false, longest
+	if cp != nil && cp.resume != nil {
+		if cp.resume.Done {
+			if cp.resume.Ok {
+				return Ok, restoreLongest(cp.resume, n)
+			}
+			return Illegal, restoreLongest(cp.resume, n)
+		}
+		entry, state, calls = resumePartition(model, headEntry, cp.resume, linearized, cache, interner)
+		atomic.StoreInt64(opts.prefix, int64(len(calls)))
+		longest = restoreLongest(cp.resume, n)
+		if chargeCache {
+			for _, e := range cp.resume.Cache {
+				charged += cacheEntryCost(len(e.Linearized))
+			}
 		}
-		if entry.match != nil {
+		charged += int64(len(calls)) * callsEntrySize
+		budget.charge(charged)
+	}
+	steps := 0
+	depth := len(calls)
+	completed := false // whether a complete linearization was enumerated
+	var generated, hits, misses uint64
+	if m := opts.metrics; m != nil {
+		defer func() {
+			m.Entries = len(history)
+			m.Steps = uint64(steps)
+			m.StatesGenerated = generated
+			m.CacheHits = hits
+			m.CacheMisses = misses
+			m.MaxDepth = depth
+		}()
+	}
+	for headEntry.next != nil || (opts.onComplete != nil && len(calls) > 0) {
+		if atomic.LoadInt32(opts.kill) != 0 || atomic.LoadInt32(opts.expired) != 0 {
+			if cp != nil {
+				cp.save(capturePartition(calls, entry, cache, longest))
+			}
+			return Unknown, longest
+		}
+		steps++
+		if opts.workers != nil && steps%yieldBatch == 0 {
+			yieldWorker(opts.workers)
+		}
+		if (prog != nil || cp != nil) && steps%progressBatch == 0 {
+			prog.report(steps, depth)
+			if cp.due() {
+				cp.save(capturePartition(calls, entry, cache, longest))
+			}
+		}
+		if entry == nil {
+			// every operation is linearized; record the final state, and
+			// backtrack to enumerate the other linearizations
+			opts.onComplete(state)
+			completed = true
+		}
+		if entry != nil && entry.match != nil && sym.redundant(headEntry, entry) {
+			entry = entry.next
+		} else if entry != nil && entry.match != nil {
 			matching := entry.match // the return entry
 			ok, newState := model.Step(state, entry.value, matching.value)
 			if ok {
-				newLinearized := linearized.clone().set(uint(entry.id))
-				newCacheEntry := cacheEntry{newLinearized, newState}
-				if !cacheContains(model, cache, newCacheEntry) {
-					hash := newLinearized.hash()
-					cache[hash] = append(cache[hash], newCacheEntry)
+				generated++
+				// look up the new state with the operation linearized in
+				// place, and only copy the set of linearized operations
+				// if the state is new
+				linearized.set(uint(entry.id))
+				stateId, newState := interner.intern(newState)
+				newCacheEntry := cacheEntry{state: newState, stateId: stateId}
+				hash := cacheKey(model, newCacheEntry, linearized.hash)
+				if !cacheContains(model, cache, hash, linearized, newState, stateId) {
+					misses++
+					opts.trace.linearize(entry.id, hash, false)
+					newCacheEntry.linearized = linearized.compact(&arena)
+					cost := int64(callsEntrySize)
+					if chargeCache {
+						cost += cacheEntryCost(len(newCacheEntry.linearized.words))
+					}
+					charged += cost
+					if !budget.charge(cost) {
+						atomic.StoreInt32(opts.kill, 1)
+						if cp != nil {
+							cp.save(capturePartition(calls, entry, cache, longest))
+						}
+						return Unknown, longest
+					}
+					cache.Store(hash, CachedState{newCacheEntry})
 					calls = append(calls, callsEntry{entry, state})
 					state = newState
-					linearized.set(uint(entry.id))
 					lift(entry)
 					entry = headEntry.next
+					if len(calls) > depth {
+						depth = len(calls)
+						atomic.StoreInt64(opts.prefix, int64(depth))
+					}
+					if opts.maxDepth > 0 && len(calls) >= opts.maxDepth && len(calls) < n {
+						// a prefix of the maximum depth is linearizable, which
+						// is as far as this search goes
+						if opts.computePartial {
+							recordLongest(calls, longest)
+						}
+						prog.report(steps, len(calls))
+						if cp != nil {
+							cp.save(capturePartition(calls, entry, cache, longest))
+						}
+						return Unknown, longest
+					}
 				} else {
+					hits++
+					opts.trace.linearize(entry.id, hash, true)
+					linearized.clear(uint(entry.id))
 					entry = entry.next
 				}
 			} else {
@@ -284,30 +687,29 @@ func checkSingle(model Model, history []entry, computePartial bool, kill *int32)
 			}
 		} else {
 			if len(calls) == 0 {
-				return false, longest
+				prog.report(steps, depth)
+				if completed {
+					return Ok, longest
+				}
+				if cp != nil {
+					done := capturePartition(nil, nil, nil, longest)
+					done.Done = true
+					cp.save(done)
+				}
+				return Illegal, longest
 			}
 			// longest
-			if computePartial {
-				callsLen := len(calls)
-				var seq []int = nil
-				for _, v := range calls {
-					if longest[v.entry.id] == nil || callsLen > len(*longest[v.entry.id]) {
-						// create seq lazily
-						if seq == nil {
-							seq = make([]int, len(calls))
-							for i, v := range calls {
-								seq[i] = v.entry.id
-							}
-						}
-						longest[v.entry.id] = &seq
-					}
-				}
+			if opts.computePartial {
+				recordLongest(calls, longest)
 			}
 			callsTop := calls[len(calls)-1]
 			entry = callsTop.entry
 			state = callsTop.state
+			opts.trace.revert(entry.id)
 			linearized.clear(uint(entry.id))
 			calls = calls[:len(calls)-1]
+			charged -= callsEntrySize
+			budget.charge(-callsEntrySize)
 			unlift(entry)
 			entry = entry.next
 		}
@@ -320,7 +722,14 @@ func checkSingle(model Model, history []entry, computePartial bool, kill *int32)
 	for i := 0; i < n; i++ {
 		longest[i] = &seq
 	}
-	return true, longest
+	prog.report(steps, n)
+	if cp != nil {
+		done := capturePartition(nil, nil, nil, longest)
+		done.Done = true
+		done.Ok = true
+		cp.save(done)
+	}
+	return Ok, longest
 }
 
 func fillDefault(model Model) Model {
@@ -342,36 +751,204 @@ func fillDefault(model Model) Model {
 	return model
 }
 
-func checkParallel(model Model, history [][]entry, computeInfo bool, timeout time.Duration) (CheckResult, LinearizationInfo) {
+// checkOptions holds the knobs that the exported Check* functions set for a
+// single linearizability check.
+type checkOptions struct {
+	verbose  bool
+	timeout  time.Duration
+	progress func(Progress)
+	ctx      context.Context // optional; cancelling it stops the check
+	workers  chan struct{}   // optional; a semaphore limiting concurrent partitions
+	// maximum number of partitions checked at once, if workers is nil;
+	// defaults to runtime.GOMAXPROCS(0)
+	parallelism int
+	// estimated memory, in bytes, that the search may use; 0 means no limit
+	memoryBudget int64
+	// optional; a budget shared with other checks, used instead of
+	// memoryBudget
+	budget *memoryBudget
+	// optional; a file to save the search state to, and to resume from
+	checkpointPath     string
+	checkpointInterval time.Duration
+	// optional; a time limit for each partition, so that one slow partition
+	// doesn't keep the others from being checked
+	partitionTimeout time.Duration
+	// whether to intern states; see WithStateInterning
+	internStates bool
+	// whether to skip interchangeable operations; see
+	// WithSymmetryReduction
+	reduceSymmetry bool
+	// maximum length of the linearized prefix; see WithMaxDepth
+	maxDepth int
+	// optional; receives the metrics of the check
+	metrics MetricsSink
+	// optional; creates the cache of visited states for each partition
+	newCache func() StateCache
+	// optional; the order in which to try operations; see WithOrdering
+	ordering func(a, b Operation) bool
+	// whether to make the result reproducible; see WithDeterministic
+	deterministic bool
+	// how much to relax the real-time order by; see WithEpsilon
+	epsilon int64
+	// optional; how to split partitions that are too large to check; see
+	// WithPartitionSplitting
+	split *SplitOptions
+	// optional; called with the index of the first partition found to be
+	// non-linearizable
+	onFailure func(partition int)
+	// optional; receives the search's decisions; see WithDecisionTrace
+	decisionTrace io.Writer
+}
+
+type partitionResult struct {
+	partition int
+	result    CheckResult
+	timedOut  bool // the partition ran out of time
+}
+
+func checkParallel(model Model, history [][]entry, opts checkOptions) (CheckResult, LinearizationInfo) {
+	computeInfo := opts.verbose
 	if len(history) == 0 {
+		if opts.progress != nil {
+			opts.progress(Progress{Done: true, Confidence: 1})
+		}
+		newMetricsRecorder(opts.metrics, 0).finish()
+		newDecisionTracer(opts.decisionTrace).finish()
 		return Ok, LinearizationInfo{}
 	}
 	ok := true
 	timedOut := false
-	results := make(chan bool, len(history))
+	results := make(chan partitionResult, len(history))
 	longest := make([][]*[]int, len(history))
 	kill := int32(0)
+	// the longest linearized prefix of each partition, published by the
+	// searches as they go, so that it can be read without waiting for them
+	prefixes := make([]int64, len(history))
+	budget := opts.budget
+	if budget == nil {
+		budget = newMemoryBudget(opts.memoryBudget)
+	}
+	var cp *checkpointer
+	if opts.checkpointPath != "" {
+		cp = newCheckpointer(opts.checkpointPath, history)
+	}
+	var tracker *progressTracker
+	if opts.progress != nil {
+		tracker = newProgressTracker(history)
+	}
+	metrics := newMetricsRecorder(opts.metrics, len(history))
+	tracer := newDecisionTracer(opts.decisionTrace)
+	workers := opts.workers
+	if workers == nil {
+		parallelism := opts.parallelism
+		if parallelism <= 0 {
+			parallelism = runtime.GOMAXPROCS(0)
+		}
+		workers = make(chan struct{}, parallelism)
+	}
 	for i, subhistory := range history {
 		go func(i int, subhistory []entry) {
-			ok, l := checkSingle(model, subhistory, computeInfo, &kill)
+			workers <- struct{}{}
+			defer func() { <-workers }()
+			expired := int32(0)
+			if opts.partitionTimeout > 0 {
+				timer := time.AfterFunc(opts.partitionTimeout, func() {
+					atomic.StoreInt32(&expired, 1)
+				})
+				defer timer.Stop()
+			}
+			search := searchOptions{
+				computePartial: computeInfo,
+				internStates:   opts.internStates,
+				reduceSymmetry: opts.reduceSymmetry,
+				maxDepth:       opts.maxDepth,
+				kill:           &kill,
+				expired:        &expired,
+				prog:           tracker.partition(i),
+				budget:         budget,
+				cp:             cp.partition(i),
+				workers:        workers,
+				metrics:        metrics.partition(i),
+				newCache:       opts.newCache,
+				ordering:       opts.ordering,
+				prefix:         &prefixes[i],
+				trace:          tracer.partition(i),
+			}
+			var res CheckResult
+			var l []*[]int
+			if opts.split.oversized(subhistory) {
+				res, l = checkOversized(model, subhistory, search, opts.split)
+			} else {
+				res, l = checkSingle(model, subhistory, search)
+			}
+			if res != Unknown {
+				tracker.finish(i)
+			}
+			if m := metrics.partition(i); m != nil {
+				m.Result = res
+			}
 			longest[i] = l
-			results <- ok
+			results <- partitionResult{i, res, res == Unknown && atomic.LoadInt32(&expired) != 0}
 		}(i, subhistory)
 	}
 	var timeoutChan <-chan time.Time
-	if timeout > 0 {
-		timeoutChan = time.After(timeout)
+	if opts.timeout > 0 {
+		timeoutChan = time.After(opts.timeout)
+	}
+	var doneChan <-chan struct{}
+	if opts.ctx != nil {
+		doneChan = opts.ctx.Done()
+	}
+	var progressChan <-chan time.Time
+	if tracker != nil {
+		ticker := time.NewTicker(progressInterval)
+		defer ticker.Stop()
+		progressChan = ticker.C
+	}
+	var checkpointChan <-chan time.Time
+	if cp != nil {
+		interval := opts.checkpointInterval
+		if interval <= 0 {
+			interval = defaultCheckpointInterval
+		}
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		checkpointChan = ticker.C
 	}
+	partitionsTimedOut := make([]bool, len(history))
+	failed := -1 // for deterministic checks, the first failed partition
 	count := 0
 loop:
 	for {
 		select {
 		case result := <-results:
 			count++
-			ok = ok && result
-			if !ok && !computeInfo {
-				atomic.StoreInt32(&kill, 1)
-				break loop
+			switch result.result {
+			case Unknown:
+				if budget.isExceeded() {
+					// the search was cut short, so the history might
+					// still be linearizable
+					timedOut = true
+					break loop
+				}
+				// the partition timed out or hit the maximum depth
+				timedOut = true
+				partitionsTimedOut[result.partition] = result.timedOut
+			case Illegal:
+				if opts.deterministic {
+					// report the first failed partition in order, once
+					// they have all been checked
+					if failed < 0 || result.partition < failed {
+						failed = result.partition
+					}
+				} else if ok && opts.onFailure != nil {
+					opts.onFailure(result.partition)
+				}
+				ok = false
+				if !computeInfo && !opts.deterministic {
+					atomic.StoreInt32(&kill, 1)
+					break loop
+				}
 			}
 			if count >= len(history) {
 				break loop
@@ -380,16 +957,31 @@ loop:
 			timedOut = true
 			atomic.StoreInt32(&kill, 1)
 			break loop // if we time out, we might get a false positive
+		case <-doneChan:
+			timedOut = true
+			atomic.StoreInt32(&kill, 1)
+			break loop
+		case <-progressChan:
+			opts.progress(tracker.snapshot(false))
+		case <-checkpointChan:
+			cp.write()
+			cp.request()
 		}
 	}
-	var info LinearizationInfo
-	if computeInfo {
+	if computeInfo || (cp != nil && ok && timedOut) || metrics != nil || tracer != nil {
 		// make sure we've waited for all goroutines to finish,
-		// otherwise we might race on access to longest[]
+		// otherwise we might race on access to longest[]; when
+		// checkpointing, this also waits for them to save their state,
+		// when collecting metrics, for them to fill them in, and when
+		// tracing, for them to stop writing the trace
 		for count < len(history) {
 			<-results
 			count++
 		}
+	}
+	tracer.finish()
+	var info LinearizationInfo
+	if computeInfo {
 		// return longest linearizable prefixes that include each history element
 		partialLinearizations := make([][][]int, len(history))
 		for i := 0; i < len(history); i++ {
@@ -406,10 +998,23 @@ loop:
 				copy(arr, *k)
 				partials = append(partials, arr)
 			}
+			if opts.deterministic {
+				sortPartials(partials)
+			}
 			partialLinearizations[i] = partials
 		}
 		info.history = history
 		info.partialLinearizations = partialLinearizations
+		info.timedOut = partitionsTimedOut
+	}
+	info.prefixes = make([]int, len(history))
+	info.operations = make([]int, len(history))
+	for i := range history {
+		info.prefixes[i] = int(atomic.LoadInt64(&prefixes[i]))
+		info.operations[i] = len(history[i]) / 2
+	}
+	if failed >= 0 && opts.onFailure != nil {
+		opts.onFailure(failed)
 	}
 	var result CheckResult
 	if !ok {
@@ -421,25 +1026,78 @@ loop:
 			result = Ok
 		}
 	}
+	if cp != nil {
+		if result == Unknown {
+			cp.write()
+		} else {
+			cp.remove()
+		}
+	}
+	if tracker != nil {
+		opts.progress(tracker.snapshot(true))
+	}
+	metrics.finish()
 	return result, info
 }
 
-func checkEvents(model Model, history []Event, verbose bool, timeout time.Duration) (CheckResult, LinearizationInfo) {
+func checkEvents(model Model, history []Event, tags map[int]map[string]string, opts checkOptions) (CheckResult, LinearizationInfo) {
 	model = fillDefault(model)
 	partitions := model.PartitionEvent(history)
+	if opts.deterministic {
+		sortEventPartitions(history, partitions)
+	}
 	l := make([][]entry, len(partitions))
 	for i, subhistory := range partitions {
-		l[i] = convertEntries(renumber(subhistory))
+		var subtags []map[string]string
+		if tags != nil {
+			subtags = make([]map[string]string, len(subhistory))
+			for j, e := range subhistory {
+				subtags[j] = tags[e.Id]
+			}
+		}
+		l[i] = convertEntries(renumber(subhistory), subtags)
+	}
+	return checkParallel(model, l, opts)
+}
+
+// relaxRealTime extends the return entries of each partition by epsilon, so
+// that operations at most epsilon apart are concurrent, and restores the
+// entries' order.
+func relaxRealTime(history [][]entry, epsilon int64) {
+	if epsilon <= 0 {
+		return
+	}
+	for _, entries := range history {
+		for i := range entries {
+			if entries[i].kind == returnEntry {
+				entries[i].time += epsilon
+			}
+		}
+		sort.Sort(byTime(entries))
 	}
-	return checkParallel(model, l, verbose, timeout)
 }
 
-func checkOperations(model Model, history []Operation, verbose bool, timeout time.Duration) (CheckResult, LinearizationInfo) {
+func checkOperations(model Model, history []Operation, tags []map[string]string, opts checkOptions) (CheckResult, LinearizationInfo) {
 	model = fillDefault(model)
 	partitions := model.Partition(history)
+	var matcher *tagMatcher
+	if tags != nil {
+		matcher = newTagMatcher(history, tags)
+	}
 	l := make([][]entry, len(partitions))
 	for i, subhistory := range partitions {
-		l[i] = makeEntries(subhistory)
+		var subtags []map[string]string
+		if matcher != nil {
+			subtags = make([]map[string]string, len(subhistory))
+			for j, op := range subhistory {
+				subtags[j] = matcher.take(op)
+			}
+		}
+		l[i] = makeEntries(subhistory, subtags)
+	}
+	relaxRealTime(l, opts.epsilon)
+	if opts.deterministic {
+		sortOperationPartitions(l)
 	}
-	return checkParallel(model, l, verbose, timeout)
+	return checkParallel(model, l, opts)
 }
@@ -0,0 +1,279 @@
+package porcupine
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"hash/fnv"
+	"io/ioutil"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// defaultCheckpointInterval is how often a check started with
+// [WithCheckpoint] saves its search state, if no interval is given.
+const defaultCheckpointInterval = time.Minute
+
+// checkpointVersion is the version of the checkpoint file format; files of
+// other versions are ignored.
+const checkpointVersion = 2
+
+// checkpointFile is the serialized search state of a check.
+type checkpointFile struct {
+	Version     int
+	Fingerprint uint64 // of the partitioned history, to detect stale files
+	Partitions  []checkpointPartition
+}
+
+// checkpointPartition is the search state of a single partition: enough to
+// rebuild the state of checkSingle, except for the model states on the
+// stack, which are recomputed by replaying the linearized prefix.
+type checkpointPartition struct {
+	Started bool
+	Done    bool
+	Ok      bool
+	Calls   []int // IDs of the linearized prefix, in order
+	Next    int   // ID of the entry that the search tries next, or -1 if none
+	// whether Next is a return entry, meaning that the search backtracks
+	// next
+	NextReturn bool
+	Cache      []checkpointCacheEntry
+	// the distinct longest linearizations, and for each operation, the
+	// index of the longest one that includes it, or -1 if none
+	Linearizations [][]int
+	Longest        []int
+}
+
+type checkpointCacheEntry struct {
+	// the set of linearized operations, in the form of a compactBitset
+	Full       int
+	Linearized []uint64
+	State      interface{}
+}
+
+// A checkpointer periodically saves the search state of a check to a file,
+// and loads it when the same check is started again.
+type checkpointer struct {
+	path        string
+	fingerprint uint64
+	partitions  []partitionCheckpoint
+
+	mu    sync.Mutex // protects saved
+	saved []checkpointPartition
+}
+
+// partitionCheckpoint is the checkpointing state that checkSingle sees for a
+// single partition.
+type partitionCheckpoint struct {
+	requested int32 // accessed atomically
+	resume    *checkpointPartition
+	cp        *checkpointer
+	i         int
+}
+
+func newCheckpointer(path string, history [][]entry) *checkpointer {
+	c := &checkpointer{
+		path:        path,
+		fingerprint: fingerprintHistory(history),
+		partitions:  make([]partitionCheckpoint, len(history)),
+		saved:       make([]checkpointPartition, len(history)),
+	}
+	for i := range c.partitions {
+		c.partitions[i].cp = c
+		c.partitions[i].i = i
+	}
+	if f, err := c.load(); err == nil {
+		for i := range f.Partitions {
+			if f.Partitions[i].Started {
+				c.saved[i] = f.Partitions[i]
+				c.partitions[i].resume = &c.saved[i]
+			}
+		}
+	}
+	return c
+}
+
+// fingerprintHistory hashes the partitioned history, so that a checkpoint is
+// only resumed by a check of the same history.
+func fingerprintHistory(history [][]entry) uint64 {
+	h := fnv.New64a()
+	for _, partition := range history {
+		fmt.Fprintf(h, "partition %d\n", len(partition))
+		for _, e := range partition {
+			fmt.Fprintf(h, "%v %d %d %d %#v\n", e.kind, e.id, e.time, e.clientId, e.value)
+		}
+	}
+	return h.Sum64()
+}
+
+func (c *checkpointer) load() (checkpointFile, error) {
+	var f checkpointFile
+	data, err := ioutil.ReadFile(c.path)
+	if err != nil {
+		return f, err
+	}
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&f); err != nil {
+		return f, err
+	}
+	if f.Version != checkpointVersion || f.Fingerprint != c.fingerprint || len(f.Partitions) != len(c.partitions) {
+		return f, fmt.Errorf("porcupine: checkpoint %s is for a different check", c.path)
+	}
+	return f, nil
+}
+
+// partition returns the checkpointing state for the i-th partition, or nil
+// if the check is not being checkpointed.
+func (c *checkpointer) partition(i int) *partitionCheckpoint {
+	if c == nil {
+		return nil
+	}
+	return &c.partitions[i]
+}
+
+// request asks every partition to save its search state at its next
+// opportunity.
+func (c *checkpointer) request() {
+	for i := range c.partitions {
+		atomic.StoreInt32(&c.partitions[i].requested, 1)
+	}
+}
+
+// write saves the latest search state of every partition to the file,
+// replacing it atomically.
+func (c *checkpointer) write() error {
+	c.mu.Lock()
+	f := checkpointFile{
+		Version:     checkpointVersion,
+		Fingerprint: c.fingerprint,
+		Partitions:  c.saved,
+	}
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(f)
+	c.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	tmp := c.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, buf.Bytes(), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, c.path)
+}
+
+// remove deletes the file, once the check has a definite result.
+func (c *checkpointer) remove() {
+	os.Remove(c.path)
+}
+
+// due reports whether the partition should save its search state. It is
+// false on a nil receiver.
+func (p *partitionCheckpoint) due() bool {
+	return p != nil && atomic.LoadInt32(&p.requested) != 0
+}
+
+// save records the search state of the partition, for the next write.
+func (p *partitionCheckpoint) save(s checkpointPartition) {
+	atomic.StoreInt32(&p.requested, 0)
+	s.Started = true
+	p.cp.mu.Lock()
+	p.cp.saved[p.i] = s
+	p.cp.mu.Unlock()
+}
+
+// capturePartition records the search state of checkSingle. The cache
+// entries are immutable once added, so they are shared rather than copied.
+// Only the default cache can be listed, so a custom StateCache isn't saved;
+// the resumed search still has the same result, but may revisit states.
+func capturePartition(calls []callsEntry, next *node, cache StateCache, longest []*[]int) checkpointPartition {
+	s := checkpointPartition{
+		Calls:   make([]int, len(calls)),
+		Next:    -1,
+		Longest: make([]int, len(longest)),
+	}
+	for i, c := range calls {
+		s.Calls[i] = c.entry.id
+	}
+	if next != nil {
+		s.Next = next.id
+		s.NextReturn = next.match == nil
+	}
+	if cache, ok := cache.(mapStateCache); ok {
+		for _, entries := range cache {
+			for _, e := range entries {
+				linearized := e.entry.linearized
+				s.Cache = append(s.Cache, checkpointCacheEntry{linearized.full, linearized.words, e.entry.state})
+			}
+		}
+	}
+	index := make(map[*[]int]int)
+	for i, l := range longest {
+		if l == nil {
+			s.Longest[i] = -1
+			continue
+		}
+		j, ok := index[l]
+		if !ok {
+			j = len(s.Linearizations)
+			index[l] = j
+			s.Linearizations = append(s.Linearizations, *l)
+		}
+		s.Longest[i] = j
+	}
+	return s
+}
+
+// restoreLongest rebuilds the longest linearizations of a saved partition.
+func restoreLongest(s *checkpointPartition, n int) []*[]int {
+	linearizations := make([]*[]int, len(s.Linearizations))
+	for i := range s.Linearizations {
+		l := s.Linearizations[i]
+		linearizations[i] = &l
+	}
+	longest := make([]*[]int, n)
+	for i, j := range s.Longest {
+		if i < n && j >= 0 {
+			longest[i] = linearizations[j]
+		}
+	}
+	return longest
+}
+
+// resumePartition restores the search state of checkSingle from a saved
+// partition, given the head of the linked entries. It replays the linearized
+// prefix to recompute the states on the stack, and returns the entry that
+// the search tries next, the current state, and the stack.
+func resumePartition(model Model, head *node, s *checkpointPartition, linearized *linearizedSet, cache StateCache, interner *stateInterner) (*node, interface{}, []callsEntry) {
+	callNodes := make(map[int]*node)
+	returnNodes := make(map[int]*node)
+	for n := head.next; n != nil; n = n.next {
+		if n.match != nil {
+			callNodes[n.id] = n
+		} else {
+			returnNodes[n.id] = n
+		}
+	}
+	fullHashes := fullWordHashes(len(linearized.bits))
+	for _, e := range s.Cache {
+		stateId, state := interner.intern(e.State)
+		entry := cacheEntry{compactBitset{e.Full, e.Linearized}, state, stateId}
+		hash := cacheKey(model, entry, entry.linearized.hash(fullHashes))
+		cache.Store(hash, CachedState{entry})
+	}
+	state := model.Init()
+	var calls []callsEntry
+	for _, id := range s.Calls {
+		call := callNodes[id]
+		calls = append(calls, callsEntry{call, state})
+		_, state = model.Step(state, call.value, call.match.value)
+		_, state = interner.intern(state)
+		linearized.set(uint(id))
+		lift(call)
+	}
+	next := callNodes[s.Next]
+	if s.NextReturn {
+		next = returnNodes[s.Next]
+	}
+	return next, state, calls
+}
@@ -0,0 +1,74 @@
+package porcupine
+
+import (
+	"bytes"
+	"encoding/gob"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func init() {
+	gob.Register(map[string]string{})
+}
+
+func TestCheckpointResume(t *testing.T) {
+	for _, tc := range []struct {
+		file     string
+		model    Model
+		budget   int64
+		expected CheckResult
+	}{
+		{"test_data/kv/c01-ok.txt", kvNoPartitionModel, 1 << 10, Ok},
+		{"test_data/kv/c01-bad.txt", kvNoPartitionModel, 1 << 10, Illegal},
+		{"test_data/kv/c10-ok.txt", kvModel, 1 << 10, Ok},
+		// the bad partition can't be found to be non-linearizable within
+		// this budget, so the check is interrupted whatever order the
+		// partitions are searched in
+		{"test_data/kv/c10-bad.txt", kvModel, 1 << 8, Illegal},
+	} {
+		path := filepath.Join(t.TempDir(), "checkpoint")
+		events := parseKvLog(tc.file)
+		// a small memory budget interrupts the check, which saves its
+		// state
+		res, _ := CheckEventsVerbose(tc.model, events, 0, WithCheckpoint(path, 0), WithMemoryBudget(tc.budget))
+		if res != Unknown {
+			t.Fatalf("%s: expected output %v, got output %v", tc.file, Unknown, res)
+		}
+		var f checkpointFile
+		if data, err := os.ReadFile(path); err != nil || gob.NewDecoder(bytes.NewReader(data)).Decode(&f) != nil {
+			t.Fatalf("%s: expected a checkpoint to be saved: %v", tc.file, err)
+		}
+		started := false
+		for _, p := range f.Partitions {
+			started = started || (p.Started && len(p.Cache) > 0)
+		}
+		if !started {
+			t.Fatalf("%s: expected the checkpoint to hold search state", tc.file)
+		}
+
+		res, info := CheckEventsVerbose(tc.model, events, 0, WithCheckpoint(path, 0))
+		if res != tc.expected {
+			t.Fatalf("%s: expected output %v, got output %v", tc.file, tc.expected, res)
+		}
+		if len(info.PartialLinearizations()) == 0 {
+			t.Fatalf("%s: expected partial linearizations", tc.file)
+		}
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Fatalf("%s: expected the checkpoint to be removed, got %v", tc.file, err)
+		}
+	}
+}
+
+func TestCheckpointDifferentHistory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoint")
+	ok := parseKvLog("test_data/kv/c01-ok.txt")
+	bad := parseKvLog("test_data/kv/c01-bad.txt")
+	if res := CheckEventsTimeout(kvNoPartitionModel, bad, 0, WithCheckpoint(path, 0), WithMemoryBudget(1<<10)); res != Unknown {
+		t.Fatalf("expected output %v, got output %v", Unknown, res)
+	}
+	// the checkpoint for the bad history must not be used for the ok one
+	if res := CheckEventsTimeout(kvNoPartitionModel, ok, 0, WithCheckpoint(path, 0)); res != Ok {
+		t.Fatalf("expected output %v, got output %v", Ok, res)
+	}
+}
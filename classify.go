@@ -0,0 +1,145 @@
+package porcupine
+
+import "fmt"
+
+// A ConsistencyLevel is a level of the consistency spectrum, from weakest to
+// strongest; each level implies the ones below it.
+type ConsistencyLevel int
+
+const (
+	// the history doesn't satisfy any of the levels
+	NoConsistency ConsistencyLevel = iota
+	// reads of each key that start after every write to it has finished
+	// agree on its value
+	EventualConsistency
+	// each client observes the writes of every other client in the order
+	// that client made them
+	PRAMConsistency
+	// see [CheckCausalConsistency]
+	CausalConsistency
+	// see [CheckSequentialConsistency]
+	SequentialConsistency
+	// the history is linearizable
+	Linearizability
+)
+
+func (l ConsistencyLevel) String() string {
+	switch l {
+	case NoConsistency:
+		return "none"
+	case EventualConsistency:
+		return "eventual"
+	case PRAMConsistency:
+		return "PRAM"
+	case CausalConsistency:
+		return "causal"
+	case SequentialConsistency:
+		return "sequential"
+	case Linearizability:
+		return "linearizable"
+	}
+	return fmt.Sprintf("ConsistencyLevel(%d)", int(l))
+}
+
+// A ConsistencyReport is the result of [ClassifyConsistency].
+type ConsistencyReport struct {
+	// the strongest level that the history is known to satisfy
+	Level ConsistencyLevel
+	// the result of each check that ran; a level that is implied by a
+	// stronger one that passed isn't checked
+	Results map[ConsistencyLevel]CheckResult
+}
+
+// Complete reports whether the checks decided the strongest level, rather
+// than one of them running out of time.
+func (r ConsistencyReport) Complete() bool {
+	for _, res := range r.Results {
+		if res == Unknown {
+			return false
+		}
+	}
+	return true
+}
+
+func (r ConsistencyReport) String() string {
+	if !r.Complete() {
+		return fmt.Sprintf("at least %s (a stronger check did not finish)", r.Level)
+	}
+	return r.Level.String()
+}
+
+// ClassifyConsistency reports the strongest level of the consistency spectrum
+// that a history of a key-value store satisfies, as a single summary, e.g.,
+// for qualifying a release. It checks the levels from weakest to strongest,
+// which is roughly in order of cost, and stops at the first that fails,
+// skipping PRAM consistency, which is expensive to check, when the history is
+// causally consistent.
+//
+// The model's state must cover every key, and the model should partition
+// histories by key, which the linearizability check uses; access says how
+// each operation accesses the store, as for [CheckSessionGuarantees]. The
+// options apply to the linearizability check, and of them, the sequential
+// and PRAM checks use [WithTimeout] and [WithContext]; the timeout covers all
+// of the checks.
+func ClassifyConsistency(history []Operation, model Model, access func(op Operation) (KeyAccess, bool), opts ...CheckOption) ConsistencyReport {
+	model = fillDefault(model)
+	o, cancel := sharedDeadline(checkOptions{}.apply(opts))
+	defer cancel()
+	report := ConsistencyReport{Results: make(map[ConsistencyLevel]CheckResult)}
+	// record returns whether the level passed, so that the next one
+	// should be checked
+	record := func(level ConsistencyLevel, res CheckResult) bool {
+		report.Results[level] = res
+		if res == Ok {
+			report.Level = level
+		}
+		return res == Ok
+	}
+	if !record(EventualConsistency, checkConvergence(history, access)) {
+		return report
+	}
+	if len(CheckCausalConsistency(history, access)) != 0 {
+		report.Results[CausalConsistency] = Illegal
+		isWrite := func(op Operation) bool {
+			a, ok := access(op)
+			return ok && a.Write
+		}
+		record(PRAMConsistency, checkPRAM(model, history, isWrite, o))
+		return report
+	}
+	record(CausalConsistency, Ok)
+	if !record(SequentialConsistency, checkSequential(model, clientPrograms(history), o)) {
+		return report
+	}
+	res, _ := checkOperations(model, history, nil, o)
+	record(Linearizability, res)
+	return report
+}
+
+// checkConvergence checks that the reads of each key that start after every
+// write to it has finished return the same value.
+func checkConvergence(history []Operation, access func(op Operation) (KeyAccess, bool)) CheckResult {
+	lastWrite := make(map[string]int64)
+	for _, op := range history {
+		if a, ok := access(op); ok && a.Write {
+			if last, ok := lastWrite[a.Key]; !ok || op.Return > last {
+				lastWrite[a.Key] = op.Return
+			}
+		}
+	}
+	final := make(map[string]interface{})
+	for _, op := range history {
+		a, ok := access(op)
+		if !ok || a.Write {
+			continue
+		}
+		if last, ok := lastWrite[a.Key]; ok && op.Call <= last {
+			continue
+		}
+		if v, ok := final[a.Key]; ok && v != a.Value {
+			return Illegal
+		}
+		final[a.Key] = a.Value
+	}
+	return Ok
+}
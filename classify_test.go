@@ -0,0 +1,65 @@
+package porcupine
+
+import "testing"
+
+func TestClassifyConsistency(t *testing.T) {
+	put := func(key, value string) kvInput { return kvInput{op: 1, key: key, value: value} }
+	get := func(key, value string) kvInput { return kvInput{op: 0, key: key, value: value} }
+	model := kvNoPartitionModel
+	model.Partition = kvModel.Partition
+	for _, tc := range []struct {
+		name     string
+		history  []Operation
+		expected ConsistencyLevel
+	}{
+		{
+			"linearizable",
+			kvProgram(
+				[]kvInput{put("x", "1"), put("y", "1")},
+				[]kvInput{get("x", "1"), get("y", "1")},
+			),
+			Linearizability,
+		},
+		{
+			"stale read",
+			kvProgram(
+				[]kvInput{put("x", "1")},
+				[]kvInput{get("y", ""), get("x", "")},
+			),
+			SequentialConsistency,
+		},
+		{
+			"iriw",
+			kvProgram(
+				[]kvInput{put("x", "1")},
+				[]kvInput{put("y", "1")},
+				[]kvInput{get("x", "1"), get("y", "")},
+				[]kvInput{get("y", "1"), get("x", "")},
+			),
+			CausalConsistency,
+		},
+		{
+			"missed cause",
+			kvProgram(
+				[]kvInput{put("x", "1")},
+				[]kvInput{get("x", "1"), put("y", "1")},
+				[]kvInput{get("z", ""), get("y", "1"), get("x", "")},
+			),
+			PRAMConsistency,
+		},
+		{
+			"diverged",
+			kvProgram(
+				[]kvInput{put("x", "1")},
+				[]kvInput{get("y", ""), get("x", "1")},
+				[]kvInput{get("y", ""), get("x", "")},
+			),
+			NoConsistency,
+		},
+	} {
+		report := ClassifyConsistency(tc.history, model, kvAccess)
+		if report.Level != tc.expected || !report.Complete() {
+			t.Fatalf("%s: expected %v, got %v (%v)", tc.name, tc.expected, report, report.Results)
+		}
+	}
+}
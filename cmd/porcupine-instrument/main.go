@@ -0,0 +1,280 @@
+// Command porcupine-instrument generates a wrapper for a Go interface that
+// records every method call as a porcupine operation.
+//
+// It is meant to be used with go:generate. Given an interface like
+//
+//	//go:generate porcupine-instrument -type KVClient
+//	type KVClient interface {
+//		Get(key string) (string, error)
+//		Put(key, value string) error
+//	}
+//
+// it writes kvclient_instrumented.go, which defines InstrumentedKVClient, a
+// KVClient that forwards each call to an underlying KVClient and records it
+// with a *porcupine.RecorderClient. Each recorded operation has a
+// porcupine.MethodCall (the method name and arguments) as its input and a
+// porcupine.MethodResult (the return values) as its output.
+//
+// Usage:
+//
+//	porcupine-instrument -type Name [-dir dir] [-output file]
+//
+// The interface must be declared in the package in dir, which defaults to the
+// current directory. Embedded interfaces are not supported.
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const porcupineImport = "github.com/anishathalye/porcupine"
+
+func main() {
+	typeName := flag.String("type", "", "name of the interface to instrument (required)")
+	dir := flag.String("dir", ".", "directory of the package that declares the interface")
+	output := flag.String("output", "", "output file name (default <type>_instrumented.go, lowercased)")
+	flag.Parse()
+	if *typeName == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *output == "" {
+		*output = strings.ToLower(*typeName) + "_instrumented.go"
+	}
+	src, err := generate(*dir, *typeName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "porcupine-instrument: %v\n", err)
+		os.Exit(1)
+	}
+	if err := os.WriteFile(filepath.Join(*dir, *output), src, 0o644); err != nil {
+		fmt.Fprintf(os.Stderr, "porcupine-instrument: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// generate finds the named interface in the package in dir and returns the
+// source of its instrumented wrapper.
+func generate(dir, typeName string) ([]byte, error) {
+	fset := token.NewFileSet()
+	paths, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range paths {
+		if strings.HasSuffix(path, "_test.go") {
+			continue
+		}
+		file, err := parser.ParseFile(fset, path, nil, 0)
+		if err != nil {
+			return nil, err
+		}
+		iface := findInterface(file, typeName)
+		if iface == nil {
+			continue
+		}
+		return generateWrapper(fset, file, typeName, iface)
+	}
+	return nil, fmt.Errorf("interface %s not found in %s", typeName, dir)
+}
+
+func findInterface(file *ast.File, typeName string) *ast.InterfaceType {
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			ts := spec.(*ast.TypeSpec)
+			if ts.Name.Name != typeName {
+				continue
+			}
+			if iface, ok := ts.Type.(*ast.InterfaceType); ok {
+				return iface
+			}
+		}
+	}
+	return nil
+}
+
+type param struct {
+	name     string
+	typ      string
+	variadic bool
+}
+
+type method struct {
+	name    string
+	params  []param
+	results []param
+}
+
+func generateWrapper(fset *token.FileSet, file *ast.File, typeName string, iface *ast.InterfaceType) ([]byte, error) {
+	var methods []method
+	usedPackages := make(map[string]bool)
+	for _, field := range iface.Methods.List {
+		fn, ok := field.Type.(*ast.FuncType)
+		if !ok || len(field.Names) == 0 {
+			return nil, errors.New("embedded interfaces are not supported")
+		}
+		collectPackages(fn, usedPackages)
+		params, err := fieldParams(fset, fn.Params, "a")
+		if err != nil {
+			return nil, err
+		}
+		results, err := fieldParams(fset, fn.Results, "r")
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range field.Names {
+			methods = append(methods, method{name.Name, params, results})
+		}
+	}
+
+	imports, err := neededImports(file, usedPackages)
+	if err != nil {
+		return nil, err
+	}
+
+	wrapper := "Instrumented" + typeName
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Code generated by porcupine-instrument -type %s; DO NOT EDIT.\n\n", typeName)
+	fmt.Fprintf(&b, "package %s\n\n", file.Name.Name)
+	b.WriteString("import (\n")
+	for _, imp := range imports {
+		fmt.Fprintf(&b, "\t%s\n", imp)
+	}
+	if len(imports) > 0 {
+		b.WriteString("\n")
+	}
+	fmt.Fprintf(&b, "\t%q\n)\n\n", porcupineImport)
+	fmt.Fprintf(&b, "// %s is a %s that records each method call with a\n", wrapper, typeName)
+	b.WriteString("// porcupine.RecorderClient.\n")
+	fmt.Fprintf(&b, "type %s struct {\n\tinner  %s\n\tclient *porcupine.RecorderClient\n}\n\n", wrapper, typeName)
+	fmt.Fprintf(&b, "// New%s returns a %s that forwards calls to inner and\n", wrapper, typeName)
+	b.WriteString("// records them with client.\n")
+	fmt.Fprintf(&b, "func New%s(inner %s, client *porcupine.RecorderClient) *%s {\n", wrapper, typeName, wrapper)
+	fmt.Fprintf(&b, "\treturn &%s{inner: inner, client: client}\n}\n", wrapper)
+	for _, m := range methods {
+		writeMethod(&b, wrapper, m)
+	}
+	return format.Source(b.Bytes())
+}
+
+func writeMethod(b *bytes.Buffer, wrapper string, m method) {
+	var sig, args, call, results, resultValues []string
+	for _, p := range m.params {
+		if p.variadic {
+			sig = append(sig, p.name+" ..."+p.typ)
+			call = append(call, p.name+"...")
+		} else {
+			sig = append(sig, p.name+" "+p.typ)
+			call = append(call, p.name)
+		}
+		args = append(args, p.name)
+	}
+	for _, r := range m.results {
+		results = append(results, r.typ)
+		resultValues = append(resultValues, r.name)
+	}
+	fmt.Fprintf(b, "\nfunc (w *%s) %s(%s) (%s) {\n", wrapper, m.name, strings.Join(sig, ", "), strings.Join(results, ", "))
+	fmt.Fprintf(b, "\tw.client.Begin(porcupine.MethodCall{Method: %q, Args: []interface{}{%s}})\n", m.name, strings.Join(args, ", "))
+	if len(m.results) == 0 {
+		fmt.Fprintf(b, "\tw.inner.%s(%s)\n", m.name, strings.Join(call, ", "))
+		b.WriteString("\tw.client.End(porcupine.MethodResult{})\n}\n")
+		return
+	}
+	fmt.Fprintf(b, "\t%s := w.inner.%s(%s)\n", strings.Join(resultValues, ", "), m.name, strings.Join(call, ", "))
+	fmt.Fprintf(b, "\tw.client.End(porcupine.MethodResult{Results: []interface{}{%s}})\n", strings.Join(resultValues, ", "))
+	fmt.Fprintf(b, "\treturn %s\n}\n", strings.Join(resultValues, ", "))
+}
+
+// fieldParams flattens a parameter or result list, giving every entry its own
+// name, since the generated code needs to refer to each one.
+func fieldParams(fset *token.FileSet, fields *ast.FieldList, prefix string) ([]param, error) {
+	if fields == nil {
+		return nil, nil
+	}
+	var params []param
+	for _, field := range fields.List {
+		typeExpr := field.Type
+		variadic := false
+		if ellipsis, ok := typeExpr.(*ast.Ellipsis); ok {
+			typeExpr = ellipsis.Elt
+			variadic = true
+		}
+		var typ bytes.Buffer
+		if err := format.Node(&typ, fset, typeExpr); err != nil {
+			return nil, err
+		}
+		n := len(field.Names)
+		if n == 0 {
+			n = 1
+		}
+		for i := 0; i < n; i++ {
+			params = append(params, param{
+				name:     prefix + strconv.Itoa(len(params)),
+				typ:      typ.String(),
+				variadic: variadic,
+			})
+		}
+	}
+	return params, nil
+}
+
+// collectPackages records the package names referred to by qualified
+// identifiers (e.g., context.Context) in a method signature.
+func collectPackages(node ast.Node, used map[string]bool) {
+	ast.Inspect(node, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			if ident, ok := sel.X.(*ast.Ident); ok {
+				used[ident.Name] = true
+			}
+		}
+		return true
+	})
+}
+
+// neededImports returns the import specs from the interface's file for the
+// packages that its method signatures use.
+func neededImports(file *ast.File, used map[string]bool) ([]string, error) {
+	var imports []string
+	found := make(map[string]bool)
+	for _, imp := range file.Imports {
+		path, err := strconv.Unquote(imp.Path.Value)
+		if err != nil {
+			return nil, err
+		}
+		if path == porcupineImport {
+			continue
+		}
+		name := filepath.Base(path)
+		spec := strconv.Quote(path)
+		if imp.Name != nil {
+			name = imp.Name.Name
+			spec = name + " " + spec
+		}
+		if used[name] {
+			imports = append(imports, spec)
+			found[name] = true
+		}
+	}
+	for name := range used {
+		if !found[name] {
+			return nil, fmt.Errorf("cannot find import for package %s", name)
+		}
+	}
+	sort.Strings(imports)
+	return imports, nil
+}
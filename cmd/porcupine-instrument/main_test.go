@@ -0,0 +1,78 @@
+package main
+
+import (
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testSource = `package kv
+
+import (
+	"context"
+	"time"
+)
+
+type KVClient interface {
+	Get(ctx context.Context, key string) (string, error)
+	Put(ctx context.Context, key, value string) error
+	Append(key string, values ...string)
+}
+
+type Unrelated interface {
+	Sleep(time.Duration)
+}
+`
+
+func writeTestPackage(t *testing.T, src string) string {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "kv.go"), []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestGenerate(t *testing.T) {
+	dir := writeTestPackage(t, testSource)
+	out, err := generate(dir, "KVClient")
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := string(out)
+	file, err := parser.ParseFile(token.NewFileSet(), "out.go", out, parser.ImportsOnly)
+	if err != nil {
+		t.Fatalf("generated code does not parse: %v\n%s", err, src)
+	}
+	var imports []string
+	for _, imp := range file.Imports {
+		imports = append(imports, imp.Path.Value)
+	}
+	if strings.Join(imports, " ") != `"context" "github.com/anishathalye/porcupine"` {
+		t.Fatalf("expected only the needed imports, got %v", imports)
+	}
+	for _, expected := range []string{
+		"func NewInstrumentedKVClient(inner KVClient, client *porcupine.RecorderClient) *InstrumentedKVClient",
+		"func (w *InstrumentedKVClient) Get(a0 context.Context, a1 string) (string, error)",
+		`w.client.Begin(porcupine.MethodCall{Method: "Put", Args: []interface{}{a0, a1, a2}})`,
+		"w.inner.Append(a0, a1...)",
+		"w.client.End(porcupine.MethodResult{Results: []interface{}{r0, r1}})",
+	} {
+		if !strings.Contains(src, expected) {
+			t.Fatalf("expected generated code to contain %q, got\n%s", expected, src)
+		}
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	dir := writeTestPackage(t, testSource)
+	if _, err := generate(dir, "Missing"); err == nil {
+		t.Fatal("expected an error for a missing interface")
+	}
+	dir = writeTestPackage(t, "package kv\n\nimport \"io\"\n\ntype RW interface {\n\tio.Reader\n}\n")
+	if _, err := generate(dir, "RW"); err == nil {
+		t.Fatal("expected an error for an embedded interface")
+	}
+}
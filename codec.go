@@ -0,0 +1,269 @@
+package porcupine
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// A Codec converts operation inputs and outputs to and from JSON, so that
+// histories can be written to and read from files.
+type Codec interface {
+	EncodeInput(input interface{}) ([]byte, error)
+	DecodeInput(data []byte) (interface{}, error)
+	EncodeOutput(output interface{}) ([]byte, error)
+	DecodeOutput(data []byte) (interface{}, error)
+}
+
+// A SchemaCodec decodes operation inputs and outputs according to a pair of
+// schemas. Either schema can be nil, in which case values are decoded without
+// validation. Values are encoded with encoding/json.
+type SchemaCodec struct {
+	Input  *Schema
+	Output *Schema
+}
+
+// EncodeInput encodes an input with encoding/json.
+func (c *SchemaCodec) EncodeInput(input interface{}) ([]byte, error) {
+	return json.Marshal(input)
+}
+
+// DecodeInput decodes and validates an input according to the Input schema.
+func (c *SchemaCodec) DecodeInput(data []byte) (interface{}, error) {
+	return decodeDynamic(c.Input, data)
+}
+
+// EncodeOutput encodes an output with encoding/json.
+func (c *SchemaCodec) EncodeOutput(output interface{}) ([]byte, error) {
+	return json.Marshal(output)
+}
+
+// DecodeOutput decodes and validates an output according to the Output
+// schema.
+func (c *SchemaCodec) DecodeOutput(data []byte) (interface{}, error) {
+	return decodeDynamic(c.Output, data)
+}
+
+func decodeDynamic(schema *Schema, data []byte) (interface{}, error) {
+	if schema == nil {
+		schema = &Schema{AdditionalProperties: true}
+	}
+	return schema.Decode(data)
+}
+
+// A FuncCodec is a [Codec] built from functions, for histories over Go types
+// that a model expects, rather than dynamic values. Any of the functions can
+// be nil: encoding then uses encoding/json, and decoding produces dynamic
+// values, as a [SchemaCodec] without schemas does.
+type FuncCodec struct {
+	MarshalInput    func(input interface{}) ([]byte, error)
+	UnmarshalInput  func(data []byte) (interface{}, error)
+	MarshalOutput   func(output interface{}) ([]byte, error)
+	UnmarshalOutput func(data []byte) (interface{}, error)
+}
+
+// EncodeInput encodes an input with MarshalInput.
+func (c *FuncCodec) EncodeInput(input interface{}) ([]byte, error) {
+	if c.MarshalInput == nil {
+		return json.Marshal(input)
+	}
+	return c.MarshalInput(input)
+}
+
+// DecodeInput decodes an input with UnmarshalInput.
+func (c *FuncCodec) DecodeInput(data []byte) (interface{}, error) {
+	if c.UnmarshalInput == nil {
+		return decodeDynamic(nil, data)
+	}
+	return c.UnmarshalInput(data)
+}
+
+// EncodeOutput encodes an output with MarshalOutput.
+func (c *FuncCodec) EncodeOutput(output interface{}) ([]byte, error) {
+	if c.MarshalOutput == nil {
+		return json.Marshal(output)
+	}
+	return c.MarshalOutput(output)
+}
+
+// DecodeOutput decodes an output with UnmarshalOutput.
+func (c *FuncCodec) DecodeOutput(data []byte) (interface{}, error) {
+	if c.UnmarshalOutput == nil {
+		return decodeDynamic(nil, data)
+	}
+	return c.UnmarshalOutput(data)
+}
+
+var (
+	codecsMu sync.RWMutex
+	codecs   = make(map[string]Codec)
+)
+
+// RegisterCodec registers a codec under the given name, so that JSON history
+// documents can refer to it by name (see [ReadJSONOperations]). Registering a
+// codec under an existing name replaces the old one.
+func RegisterCodec(name string, codec Codec) {
+	codecsMu.Lock()
+	defer codecsMu.Unlock()
+	codecs[name] = codec
+}
+
+// LookupCodec returns the codec registered under the given name.
+func LookupCodec(name string) (Codec, bool) {
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+	codec, ok := codecs[name]
+	return codec, ok
+}
+
+type jsonHistory struct {
+	Codec      string          `json:"codec"`
+	Operations []jsonOperation `json:"operations"`
+	Events     []jsonEvent     `json:"events"`
+}
+
+type jsonOperation struct {
+	Client int             `json:"client"`
+	Input  json.RawMessage `json:"input"`
+	Call   int64           `json:"call"`
+	Output json.RawMessage `json:"output"`
+	Return int64           `json:"return"`
+}
+
+type jsonEvent struct {
+	Client int             `json:"client"`
+	Kind   string          `json:"kind"` // "call" or "return"
+	Value  json.RawMessage `json:"value"`
+	Id     int             `json:"id"`
+}
+
+// ReadJSONOperations reads a history of operations from a JSON document of
+// the form
+//
+//	{
+//	  "codec": "kv",
+//	  "operations": [
+//	    {"client": 0, "input": ..., "call": 0, "output": ..., "return": 10},
+//	    ...
+//	  ]
+//	}
+//
+// decoding inputs and outputs with the given codec. If codec is nil, the
+// codec registered under the document's "codec" name is used.
+func ReadJSONOperations(r io.Reader, codec Codec) ([]Operation, error) {
+	doc, codec, err := readJSONHistory(r, codec)
+	if err != nil {
+		return nil, err
+	}
+	ops := make([]Operation, len(doc.Operations))
+	for i, op := range doc.Operations {
+		ops[i], err = decodeOperation(codec, op, fmt.Sprintf("operations[%d]", i))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return ops, nil
+}
+
+// ReadJSONEvents reads a history of events from a JSON document of the form
+//
+//	{
+//	  "codec": "kv",
+//	  "events": [
+//	    {"client": 0, "kind": "call", "value": ..., "id": 0},
+//	    {"client": 0, "kind": "return", "value": ..., "id": 0},
+//	    ...
+//	  ]
+//	}
+//
+// decoding call values as inputs and return values as outputs. If codec is
+// nil, the codec registered under the document's "codec" name is used.
+func ReadJSONEvents(r io.Reader, codec Codec) ([]Event, error) {
+	doc, codec, err := readJSONHistory(r, codec)
+	if err != nil {
+		return nil, err
+	}
+	events := make([]Event, len(doc.Events))
+	for i, e := range doc.Events {
+		path := fmt.Sprintf("events[%d]", i)
+		var kind EventKind
+		decode := codec.DecodeInput
+		switch e.Kind {
+		case "call":
+			kind = CallEvent
+		case "return":
+			kind = ReturnEvent
+			decode = codec.DecodeOutput
+		default:
+			return nil, fmt.Errorf("%s: invalid kind %q", path, e.Kind)
+		}
+		value, err := decodeWith(decode, e.Value, path+".value")
+		if err != nil {
+			return nil, err
+		}
+		events[i] = Event{ClientId: e.Client, Kind: kind, Value: value, Id: e.Id}
+	}
+	return events, nil
+}
+
+func readJSONHistory(r io.Reader, codec Codec) (jsonHistory, Codec, error) {
+	var doc jsonHistory
+	if err := json.NewDecoder(decompressed(r)).Decode(&doc); err != nil {
+		return doc, nil, err
+	}
+	if codec == nil {
+		if doc.Codec == "" {
+			codec = &SchemaCodec{}
+		} else {
+			var ok bool
+			codec, ok = LookupCodec(doc.Codec)
+			if !ok {
+				return doc, nil, fmt.Errorf("unknown codec %q", doc.Codec)
+			}
+		}
+	}
+	return doc, codec, nil
+}
+
+func encodeOperation(codec Codec, op Operation) (jsonOperation, error) {
+	input, err := codec.EncodeInput(op.Input)
+	if err != nil {
+		return jsonOperation{}, err
+	}
+	output, err := codec.EncodeOutput(op.Output)
+	if err != nil {
+		return jsonOperation{}, err
+	}
+	return jsonOperation{Client: op.ClientId, Input: input, Call: op.Call, Output: output, Return: op.Return}, nil
+}
+
+func decodeOperation(codec Codec, op jsonOperation, path string) (Operation, error) {
+	input, err := decodeWith(codec.DecodeInput, op.Input, path+".input")
+	if err != nil {
+		return Operation{}, err
+	}
+	output, err := decodeWith(codec.DecodeOutput, op.Output, path+".output")
+	if err != nil {
+		return Operation{}, err
+	}
+	return Operation{ClientId: op.Client, Input: input, Call: op.Call, Output: output, Return: op.Return}, nil
+}
+
+func decodeWith(decode func([]byte) (interface{}, error), data json.RawMessage, path string) (interface{}, error) {
+	if len(data) == 0 {
+		data = json.RawMessage("null")
+	}
+	v, err := decode(data)
+	if err != nil {
+		// schema errors are relative to the value, as "$.field: ..."
+		msg := err.Error()
+		if strings.HasPrefix(msg, "$") {
+			return nil, errors.New(path + msg[1:])
+		}
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return v, nil
+}
@@ -0,0 +1,153 @@
+package porcupine
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+)
+
+// A Compression is a stream compression format for histories; see
+// [RegisterCompression].
+type Compression struct {
+	// Magic bytes that start every compressed stream.
+	Magic []byte
+	// NewReader returns a reader that decompresses r.
+	NewReader func(r io.Reader) (io.Reader, error)
+	// NewWriter returns a writer that compresses to w; closing it flushes
+	// the compressed stream, but does not close w.
+	NewWriter func(w io.Writer) (io.WriteCloser, error)
+}
+
+var (
+	compressionsMu sync.RWMutex
+	compressions   = map[string]Compression{
+		"gzip": {
+			Magic: []byte{0x1f, 0x8b},
+			NewReader: func(r io.Reader) (io.Reader, error) {
+				return gzip.NewReader(r)
+			},
+			NewWriter: func(w io.Writer) (io.WriteCloser, error) {
+				return gzip.NewWriter(w), nil
+			},
+		},
+		// zstd is recognized, so that reading a zstd-compressed history
+		// fails with a useful error, but an implementation has to be
+		// registered by the user, as the standard library has none.
+		"zstd": {Magic: []byte{0x28, 0xb5, 0x2f, 0xfd}},
+	}
+)
+
+// RegisterCompression registers a compression format under the given name.
+// All history readers in this package detect compressed streams by their
+// magic bytes and decompress them transparently, and [NewCompressedWriter]
+// and [WriteHistoryFile] compress with registered formats.
+//
+// "gzip" is built in. "zstd" is recognized but has no implementation, as the
+// standard library has none; to read and write zstd-compressed histories,
+// register one, e.g., with github.com/klauspost/compress/zstd:
+//
+//	porcupine.RegisterCompression("zstd", porcupine.Compression{
+//		Magic: []byte{0x28, 0xb5, 0x2f, 0xfd},
+//		NewReader: func(r io.Reader) (io.Reader, error) {
+//			return zstd.NewReader(r)
+//		},
+//		NewWriter: func(w io.Writer) (io.WriteCloser, error) {
+//			return zstd.NewWriter(w)
+//		},
+//	})
+func RegisterCompression(name string, c Compression) {
+	compressionsMu.Lock()
+	defer compressionsMu.Unlock()
+	compressions[name] = c
+}
+
+// NewCompressedWriter returns a writer that compresses to w with the named
+// compression format, or that writes to w uncompressed if name is empty.
+// Close must be called to flush the compressed stream; it does not close w.
+func NewCompressedWriter(w io.Writer, name string) (io.WriteCloser, error) {
+	if name == "" {
+		return nopWriteCloser{w}, nil
+	}
+	compressionsMu.RLock()
+	c, ok := compressions[name]
+	compressionsMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown compression %q", name)
+	}
+	if c.NewWriter == nil {
+		return nil, fmt.Errorf("%s compression is not supported; see RegisterCompression", name)
+	}
+	return c.NewWriter(w)
+}
+
+// NewDecompressedReader returns a reader of the decompressed contents of r if
+// r starts with the magic bytes of a registered compression format, or of r as
+// is otherwise, along with the name of the format ("" if none).
+func NewDecompressedReader(r io.Reader) (io.Reader, string, error) {
+	br, ok := r.(*bufio.Reader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+	compressionsMu.RLock()
+	names := make([]string, 0, len(compressions))
+	registered := make(map[string]Compression, len(compressions))
+	for name, c := range compressions {
+		names = append(names, name)
+		registered[name] = c
+	}
+	compressionsMu.RUnlock()
+	sort.Strings(names)
+	for _, name := range names {
+		c := registered[name]
+		if len(c.Magic) == 0 {
+			continue
+		}
+		magic, err := br.Peek(len(c.Magic))
+		if err != nil && err != io.EOF {
+			return nil, "", err
+		}
+		if !bytes.Equal(magic, c.Magic) {
+			continue
+		}
+		if c.NewReader == nil {
+			return nil, name, fmt.Errorf("history is %s-compressed, which is not supported; see RegisterCompression", name)
+		}
+		dr, err := c.NewReader(br)
+		if err != nil {
+			return nil, name, fmt.Errorf("%s: %v", name, err)
+		}
+		return dr, name, nil
+	}
+	return br, "", nil
+}
+
+// decompressed returns a reader of the decompressed contents of r, as
+// returned by NewDecompressedReader, which returns any error from detecting
+// the compression when read.
+func decompressed(r io.Reader) io.Reader {
+	dr, _, err := NewDecompressedReader(r)
+	if err != nil {
+		return errReader{err}
+	}
+	return dr
+}
+
+type errReader struct {
+	err error
+}
+
+func (r errReader) Read(p []byte) (int, error) {
+	return 0, r.err
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
@@ -0,0 +1,133 @@
+package porcupine
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func gzipString(t *testing.T, s string) *bytes.Buffer {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := io.WriteString(w, s); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return &buf
+}
+
+func TestReadCompressed(t *testing.T) {
+	const jsonl = `{"client": 0, "input": "x", "call": 0, "output": 1, "return": 10}
+{"client": 1, "input": "y", "call": 5, "output": 2, "return": 20}
+`
+	expected, err := ReadJSONLOperations(strings.NewReader(jsonl), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ops, err := ReadJSONLOperations(gzipString(t, jsonl), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(expected, ops) {
+		t.Fatalf("expected %v, got %v", expected, ops)
+	}
+
+	knossos, err := ReadKnossosHistory(gzipString(t, knossosHistory))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(knossos) != 10 {
+		t.Fatalf("expected 10 ops, got %d", len(knossos))
+	}
+
+	events, err := ReadEvents("jsonl-operations", gzipString(t, jsonl))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 4 {
+		t.Fatalf("expected 4 events, got %d", len(events))
+	}
+
+	// a short, uncompressed input
+	if _, err := ReadJSONOperations(strings.NewReader("{}"), nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHistoryFileCompressed(t *testing.T) {
+	history := []Operation{
+		{0, kvInput{op: 1, key: "x", value: "y"}, 0, kvOutput{}, 10},
+		{1, kvInput{op: 0, key: "x"}, 5, kvOutput{"y"}, 20},
+	}
+	var buf bytes.Buffer
+	file := HistoryFile{Header: HistoryHeader{Encoding: "jsonl", Compression: "gzip"}, Operations: history}
+	if err := WriteHistoryFile(&buf, file, kvCodec); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(buf.Bytes(), []byte{0x1f, 0x8b}) {
+		t.Fatal("expected the file to be gzip-compressed")
+	}
+	decoded, err := ReadHistoryFile(&buf, kvCodec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Header.Compression != "gzip" {
+		t.Fatalf("expected gzip compression, got %q", decoded.Header.Compression)
+	}
+	if !reflect.DeepEqual(history, decoded.Operations) {
+		t.Fatalf("expected %v, got %v", history, decoded.Operations)
+	}
+
+	if _, err := NewCompressedWriter(&buf, "lz4"); err == nil {
+		t.Fatal("expected an error for an unknown compression")
+	}
+}
+
+func TestZstdUnsupported(t *testing.T) {
+	_, err := ReadJSONLOperations(bytes.NewReader([]byte{0x28, 0xb5, 0x2f, 0xfd, 0, 0}), nil)
+	if err == nil || !strings.Contains(err.Error(), "zstd") {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if _, err := NewCompressedWriter(io.Discard, "zstd"); err == nil {
+		t.Fatal("expected an error for zstd without an implementation")
+	}
+}
+
+func TestRegisterCompression(t *testing.T) {
+	// a toy format that prefixes the data
+	magic := []byte("TOY\x00")
+	RegisterCompression("toy", Compression{
+		Magic: magic,
+		NewReader: func(r io.Reader) (io.Reader, error) {
+			if _, err := io.ReadFull(r, make([]byte, len(magic))); err != nil {
+				return nil, err
+			}
+			return r, nil
+		},
+		NewWriter: func(w io.Writer) (io.WriteCloser, error) {
+			if _, err := w.Write(magic); err != nil {
+				return nil, err
+			}
+			return nopWriteCloser{w}, nil
+		},
+	})
+	var buf bytes.Buffer
+	w, err := NewCompressedWriter(&buf, "toy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.WriteString(w, "client,call,return,input,output\n0,0,10,1,2\n")
+	w.Close()
+	ops, err := ReadCSVOperations(&buf, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ops) != 1 || ops[0].Return != 10 {
+		t.Fatalf("unexpected ops %v", ops)
+	}
+}
@@ -0,0 +1,333 @@
+package porcupine
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// A ConflictEdgeKind is the reason for an edge in a [ConflictGraph].
+type ConflictEdgeKind string
+
+const (
+	// the first operation returned before the second was invoked
+	RealTimeEdge ConflictEdgeKind = "RealTime"
+	// the second operation read the value that the first wrote
+	ReadsFromEdge ConflictEdgeKind = "ReadsFrom"
+	// the first write must take effect before the second, because a read
+	// that the first precedes observed the second
+	WriteOrderEdge ConflictEdgeKind = "WriteOrder"
+	// the read must take effect before the write, because the write
+	// overwrote the value that the read observed
+	ReadBeforeEdge ConflictEdgeKind = "ReadBefore"
+)
+
+// label is the short name of the edge kind, for DOT output.
+func (k ConflictEdgeKind) label() string {
+	switch k {
+	case RealTimeEdge:
+		return "rt"
+	case ReadsFromEdge:
+		return "rf"
+	case WriteOrderEdge:
+		return "ww"
+	case ReadBeforeEdge:
+		return "rw"
+	}
+	return string(k)
+}
+
+// A ConflictEdge is an ordering constraint between two operations in a
+// [ConflictGraph], which are indices in its history.
+type ConflictEdge struct {
+	From, To int
+	Kind     ConflictEdgeKind
+}
+
+// A ConflictGraph is the graph of ordering constraints between the
+// operations of a history on a key-value store, built by
+// [BuildConflictGraph]: the real-time order, along with the orders that the
+// values read force on any linearization. If the constraints have a cycle,
+// no linearization exists, and the cycle explains why.
+type ConflictGraph struct {
+	History []Operation
+	// the constraints, sorted by From and To; real-time edges that are
+	// implied by others are left out, unless they are on the cycle
+	Edges []ConflictEdge
+	// the operations on a cycle of constraints, in order, or nil if there
+	// isn't one
+	Cycle []int
+}
+
+// BuildConflictGraph builds the graph of ordering constraints between the
+// operations of a history, where access says how each operation accesses the
+// store, as for [CheckSessionGuarantees], and returns false for operations
+// that neither read nor write it. Each write must write a value that no other
+// write to its key writes, and a read that returns a value that no write
+// wrote is taken to have observed the initial value. Since linearizability is
+// local, only constraints between operations on the same key are considered.
+//
+// The constraints are saturated: a write that precedes a read must precede
+// the write that the read observed, and a read must precede the writes that
+// follow the write it observed. A cycle of constraints means that the history
+// is not linearizable, but a history on which a check fails may have no
+// cycle, e.g., if the store isn't a plain register, so the graph complements
+// the checker's result rather than replacing it. It takes O(n^3) time for n
+// operations on a key, so it's meant for the short histories of failed
+// checks rather than for whole test runs.
+func BuildConflictGraph(history []Operation, access func(op Operation) (KeyAccess, bool)) ConflictGraph {
+	graph := ConflictGraph{History: history}
+	byKey := make(map[string][]int)
+	accesses := make([]KeyAccess, len(history))
+	for i, op := range history {
+		if a, ok := access(op); ok {
+			accesses[i] = a
+			byKey[a.Key] = append(byKey[a.Key], i)
+		}
+	}
+	keys := make([]string, 0, len(byKey))
+	for key := range byKey {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		edges, cycle := keyConflicts(history, accesses, byKey[key])
+		graph.Edges = append(graph.Edges, edges...)
+		if graph.Cycle == nil {
+			graph.Cycle = cycle
+		}
+	}
+	sort.Slice(graph.Edges, func(i, j int) bool {
+		a, b := graph.Edges[i], graph.Edges[j]
+		if a.From != b.From {
+			return a.From < b.From
+		}
+		return a.To < b.To
+	})
+	return graph
+}
+
+// keyConflicts builds the constraints between the given operations, all on
+// the same key, and finds a cycle among them.
+func keyConflicts(history []Operation, accesses []KeyAccess, ops []int) ([]ConflictEdge, []int) {
+	n := len(ops)
+	// the direct constraints, by position in ops
+	kinds := make([]map[int]ConflictEdgeKind, n)
+	for i := range kinds {
+		kinds[i] = make(map[int]ConflictEdgeKind)
+	}
+	reach := make([]bitset, n)
+	for i := range reach {
+		reach[i] = newBitset(uint(n))
+	}
+	edge := func(from, to int, kind ConflictEdgeKind) bool {
+		if _, ok := kinds[from][to]; ok {
+			return false
+		}
+		kinds[from][to] = kind
+		reach[from].set(uint(to))
+		return true
+	}
+	writes := make(map[interface{}]int)
+	for i, op := range ops {
+		if accesses[op].Write {
+			writes[accesses[op].Value] = i
+		}
+	}
+	// the write that each read observed, or -1 for the initial value
+	readsFrom := make(map[int]int)
+	for i, op := range ops {
+		if a := accesses[op]; !a.Write {
+			w, ok := writes[a.Value]
+			if !ok {
+				w = -1
+			}
+			readsFrom[i] = w
+		}
+	}
+	// constraints from values take precedence over real-time ones, which
+	// are added last
+	for r, w := range readsFrom {
+		if w >= 0 {
+			edge(w, r, ReadsFromEdge)
+			continue
+		}
+		for _, other := range writes {
+			edge(r, other, ReadBeforeEdge)
+		}
+	}
+	for i, a := range ops {
+		for j, b := range ops {
+			if history[a].Return < history[b].Call {
+				edge(i, j, RealTimeEdge)
+			}
+		}
+	}
+	closure := func() {
+		for k := 0; k < n; k++ {
+			for i := 0; i < n; i++ {
+				if reach[i].get(uint(k)) {
+					reach[i].union(reach[k])
+				}
+			}
+		}
+	}
+	cyclic := func() int {
+		for i := 0; i < n; i++ {
+			if reach[i].get(uint(i)) {
+				return i
+			}
+		}
+		return -1
+	}
+	for {
+		closure()
+		if cyclic() >= 0 {
+			break
+		}
+		changed := false
+		for r, w := range readsFrom {
+			if w < 0 {
+				continue
+			}
+			for _, other := range writes {
+				if other == w {
+					continue
+				}
+				if reach[other].get(uint(r)) && !reach[other].get(uint(w)) {
+					changed = edge(other, w, WriteOrderEdge) || changed
+				}
+				if reach[w].get(uint(other)) && !reach[r].get(uint(other)) {
+					changed = edge(r, other, ReadBeforeEdge) || changed
+				}
+			}
+		}
+		if !changed {
+			break
+		}
+	}
+	var cycle []int
+	var onCycle map[[2]int]bool
+	if start := cyclic(); start >= 0 {
+		cycle = shortestCycle(kinds, start)
+		onCycle = make(map[[2]int]bool)
+		for i := range cycle {
+			onCycle[[2]int{cycle[i], cycle[(i+1)%len(cycle)]}] = true
+		}
+	}
+	var edges []ConflictEdge
+	for i := range kinds {
+		for j, kind := range kinds[i] {
+			if kind == RealTimeEdge && !onCycle[[2]int{i, j}] && impliedRealTime(history, ops, i, j) {
+				continue
+			}
+			edges = append(edges, ConflictEdge{From: ops[i], To: ops[j], Kind: kind})
+		}
+	}
+	for i := range cycle {
+		cycle[i] = ops[cycle[i]]
+	}
+	return edges, cycle
+}
+
+// impliedRealTime returns whether the real-time edge from ops[i] to ops[j] is
+// implied by the real-time edges through another operation.
+func impliedRealTime(history []Operation, ops []int, i, j int) bool {
+	a, b := history[ops[i]], history[ops[j]]
+	for _, k := range ops {
+		if c := history[k]; a.Return < c.Call && c.Return < b.Call {
+			return true
+		}
+	}
+	return false
+}
+
+// shortestCycle returns the shortest cycle of direct constraints through
+// start, found with a breadth-first search.
+func shortestCycle(kinds []map[int]ConflictEdgeKind, start int) []int {
+	parent := make(map[int]int)
+	queue := []int{start}
+	for len(queue) > 0 {
+		i := queue[0]
+		queue = queue[1:]
+		succs := make([]int, 0, len(kinds[i]))
+		for j := range kinds[i] {
+			succs = append(succs, j)
+		}
+		sort.Ints(succs)
+		for _, j := range succs {
+			if j == start {
+				cycle := []int{i}
+				for cycle[0] != start {
+					cycle = append([]int{parent[cycle[0]]}, cycle...)
+				}
+				return cycle
+			}
+			if _, ok := parent[j]; !ok {
+				parent[j] = i
+				queue = append(queue, j)
+			}
+		}
+	}
+	return nil
+}
+
+// WriteDOT writes the graph in the DOT language of Graphviz, with operations
+// labeled using the model's DescribeOperation, and the cycle, if there is
+// one, highlighted in red. Edges are labeled "rt" for real-time, "rf" for
+// reads-from, "ww" for write-order, and "rw" for read-before constraints.
+// Operations with no constraints are left out.
+func (g ConflictGraph) WriteDOT(w io.Writer, model Model) error {
+	model = fillDefault(model)
+	nodes := make(map[int]bool)
+	for _, e := range g.Edges {
+		nodes[e.From] = true
+		nodes[e.To] = true
+	}
+	onCycle := make(map[int]bool)
+	cycleEdges := make(map[[2]int]bool)
+	for i, op := range g.Cycle {
+		onCycle[op] = true
+		cycleEdges[[2]int{op, g.Cycle[(i+1)%len(g.Cycle)]}] = true
+	}
+	ids := make([]int, 0, len(nodes))
+	for id := range nodes {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	var b strings.Builder
+	b.WriteString("digraph conflicts {\n")
+	b.WriteString("  rankdir=LR;\n")
+	b.WriteString("  node [shape=box, fontname=\"monospace\"];\n")
+	for _, id := range ids {
+		op := g.History[id]
+		label := fmt.Sprintf("%d: client %d\n%s", id, op.ClientId, model.DescribeOperation(op.Input, op.Output))
+		attrs := ""
+		if onCycle[id] {
+			attrs = ", color=red, fontcolor=red"
+		}
+		fmt.Fprintf(&b, "  op%d [label=%s%s];\n", id, dotQuote(label), attrs)
+	}
+	for _, e := range g.Edges {
+		attrs := ""
+		if e.Kind == RealTimeEdge {
+			attrs = ", style=dashed"
+		}
+		if cycleEdges[[2]int{e.From, e.To}] {
+			attrs += ", color=red, fontcolor=red, penwidth=2"
+		}
+		fmt.Fprintf(&b, "  op%d -> op%d [label=%q%s];\n", e.From, e.To, e.Kind.label(), attrs)
+	}
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// dotQuote quotes a string for DOT, where "\n" is a line break.
+func dotQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+	return `"` + s + `"`
+}
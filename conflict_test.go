@@ -0,0 +1,122 @@
+package porcupine
+
+import (
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBuildConflictGraph(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		history []Operation
+		cycle   []int
+	}{
+		{
+			"stale read",
+			[]Operation{
+				{ClientId: 0, Input: registerInput{false, 1}, Call: 0, Output: 0, Return: 10},
+				{ClientId: 1, Input: registerInput{false, 2}, Call: 20, Output: 0, Return: 30},
+				{ClientId: 2, Input: registerInput{true, 0}, Call: 40, Output: 1, Return: 50},
+			},
+			// put(2) precedes the read, so it must precede put(1)
+			[]int{0, 1},
+		},
+		{
+			"initial read after write",
+			[]Operation{
+				{ClientId: 0, Input: registerInput{false, 1}, Call: 0, Output: 0, Return: 10},
+				{ClientId: 1, Input: registerInput{true, 0}, Call: 20, Output: 0, Return: 30},
+			},
+			[]int{0, 1},
+		},
+		{
+			"concurrent",
+			[]Operation{
+				{ClientId: 0, Input: registerInput{false, 1}, Call: 0, Output: 0, Return: 100},
+				{ClientId: 1, Input: registerInput{false, 2}, Call: 10, Output: 0, Return: 90},
+				{ClientId: 2, Input: registerInput{true, 0}, Call: 20, Output: 1, Return: 30},
+				{ClientId: 2, Input: registerInput{true, 0}, Call: 40, Output: 2, Return: 50},
+			},
+			nil,
+		},
+	} {
+		graph := BuildConflictGraph(tc.history, registerAccess)
+		if !reflect.DeepEqual(graph.Cycle, tc.cycle) {
+			t.Fatalf("%s: expected cycle %v, got %v (edges %v)", tc.name, tc.cycle, graph.Cycle, graph.Edges)
+		}
+		if res := CheckOperations(registerModel, tc.history); res != (tc.cycle == nil) {
+			t.Fatalf("%s: expected a cycle iff the history is not linearizable", tc.name)
+		}
+	}
+}
+
+func TestConflictGraphEdges(t *testing.T) {
+	history := []Operation{
+		{ClientId: 0, Input: registerInput{false, 1}, Call: 0, Output: 0, Return: 10},
+		{ClientId: 1, Input: registerInput{false, 2}, Call: 20, Output: 0, Return: 30},
+		{ClientId: 2, Input: registerInput{true, 0}, Call: 40, Output: 1, Return: 50},
+	}
+	graph := BuildConflictGraph(history, registerAccess)
+	expected := []ConflictEdge{
+		{0, 1, RealTimeEdge},
+		// implied by the real-time edges through put(2), but also a
+		// reads-from edge
+		{0, 2, ReadsFromEdge},
+		{1, 0, WriteOrderEdge},
+		{1, 2, RealTimeEdge},
+		// put(1) precedes put(2), so the read of 1 must too
+		{2, 1, ReadBeforeEdge},
+	}
+	if !reflect.DeepEqual(graph.Edges, expected) {
+		t.Fatalf("expected edges %v, got %v", expected, graph.Edges)
+	}
+	var b strings.Builder
+	if err := graph.WriteDOT(&b, registerModel); err != nil {
+		t.Fatal(err)
+	}
+	dot := b.String()
+	for _, expected := range []string{
+		"digraph conflicts {",
+		`op2 [label="2: client 2\nget() -> '1'"];`,
+		`op0 [label="0: client 0\nput('1')", color=red, fontcolor=red];`,
+		`op0 -> op1 [label="rt", style=dashed, color=red, fontcolor=red, penwidth=2];`,
+		`op1 -> op0 [label="ww", color=red, fontcolor=red, penwidth=2];`,
+		`op0 -> op2 [label="rf"];`,
+	} {
+		if !strings.Contains(dot, expected) {
+			t.Fatalf("expected DOT output to contain %q, got:\n%s", expected, dot)
+		}
+	}
+}
+
+func TestConflictGraphCycleImpliesIllegal(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	cycles := 0
+	for n := 0; n < 500; n++ {
+		var history []Operation
+		for i := 0; i < 6; i++ {
+			call := int64(rng.Intn(100))
+			op := Operation{ClientId: i, Call: call, Return: call + 1 + int64(rng.Intn(30))}
+			if rng.Intn(2) == 0 {
+				// unique values
+				op.Input = registerInput{false, i + 1}
+				op.Output = 0
+			} else {
+				op.Input = registerInput{true, 0}
+				op.Output = rng.Intn(7)
+			}
+			history = append(history, op)
+		}
+		if graph := BuildConflictGraph(history, registerAccess); graph.Cycle != nil {
+			cycles++
+			if CheckOperations(registerModel, history) {
+				t.Fatalf("found cycle %v in linearizable history %v", graph.Cycle, history)
+			}
+		}
+	}
+	if cycles == 0 {
+		t.Fatal("expected some histories to have cycles")
+	}
+}
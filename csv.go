@@ -0,0 +1,106 @@
+package porcupine
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+var csvColumns = []string{"client", "call", "return", "input", "output"}
+
+// WriteCSVOperations writes a history of operations as CSV, with a header row
+// followed by one row per operation, with the columns
+//
+//	client,call,return,input,output
+//
+// Inputs and outputs are encoded with the given codec; if codec is nil, they
+// are encoded as JSON.
+func WriteCSVOperations(w io.Writer, history []Operation, codec Codec) error {
+	if codec == nil {
+		codec = &SchemaCodec{}
+	}
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvColumns); err != nil {
+		return err
+	}
+	for i, op := range history {
+		input, err := codec.EncodeInput(op.Input)
+		if err != nil {
+			return fmt.Errorf("operation %d: %v", i, err)
+		}
+		output, err := codec.EncodeOutput(op.Output)
+		if err != nil {
+			return fmt.Errorf("operation %d: %v", i, err)
+		}
+		record := []string{
+			strconv.Itoa(op.ClientId),
+			strconv.FormatInt(op.Call, 10),
+			strconv.FormatInt(op.Return, 10),
+			string(input),
+			string(output),
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// ReadCSVOperations reads a history of operations from CSV, in the format
+// written by [WriteCSVOperations]. Columns are identified by the header row,
+// so they can be in any order, and columns other than those written by
+// WriteCSVOperations are ignored.
+//
+// Inputs and outputs are decoded with the given codec; if codec is nil, they
+// are decoded from JSON as dynamic values. An empty input or output cell
+// decodes as JSON null.
+func ReadCSVOperations(r io.Reader, codec Codec) ([]Operation, error) {
+	if codec == nil {
+		codec = &SchemaCodec{}
+	}
+	cr := csv.NewReader(decompressed(r))
+	header, err := cr.Read()
+	if err != nil {
+		return nil, err
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[name] = i
+	}
+	for _, name := range csvColumns {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("missing column %q", name)
+		}
+	}
+	var ops []Operation
+	for row := 1; ; row++ {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return ops, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		path := fmt.Sprintf("row %d", row)
+		jop := jsonOperation{
+			Input:  []byte(record[columns["input"]]),
+			Output: []byte(record[columns["output"]]),
+		}
+		if jop.Client, err = strconv.Atoi(record[columns["client"]]); err != nil {
+			return nil, fmt.Errorf("%s.client: %v", path, err)
+		}
+		if jop.Call, err = strconv.ParseInt(record[columns["call"]], 10, 64); err != nil {
+			return nil, fmt.Errorf("%s.call: %v", path, err)
+		}
+		if jop.Return, err = strconv.ParseInt(record[columns["return"]], 10, 64); err != nil {
+			return nil, fmt.Errorf("%s.return: %v", path, err)
+		}
+		op, err := decodeOperation(codec, jop, path)
+		if err != nil {
+			return nil, err
+		}
+		ops = append(ops, op)
+	}
+}
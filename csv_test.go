@@ -0,0 +1,76 @@
+package porcupine
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCSVRoundTrip(t *testing.T) {
+	ops := []Operation{
+		{0, kvInput{op: 1, key: "x", value: "a,b"}, 0, kvOutput{}, 10},
+		{1, kvInput{op: 0, key: "x"}, 5, kvOutput{"a,b"}, 15},
+	}
+	var buf bytes.Buffer
+	if err := WriteCSVOperations(&buf, ops, kvCodec); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(buf.String(), "client,call,return,input,output\n") {
+		t.Fatalf("unexpected header in %q", buf.String())
+	}
+	read, err := ReadCSVOperations(&buf, kvCodec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(ops, read) {
+		t.Fatalf("expected %v, got %v", ops, read)
+	}
+}
+
+func TestReadCSVOperations(t *testing.T) {
+	// columns in a different order, with an extra column, and with
+	// plain-text inputs and outputs
+	history := `call,return,client,input,output,note
+0,10,0,put x a,,first
+20,30,1,get x,a,
+`
+	codec := &FuncCodec{
+		UnmarshalInput: func(data []byte) (interface{}, error) {
+			fields := strings.Fields(string(data))
+			if fields[0] == "get" {
+				return kvInput{op: 0, key: fields[1]}, nil
+			}
+			return kvInput{op: 1, key: fields[1], value: fields[2]}, nil
+		},
+		UnmarshalOutput: func(data []byte) (interface{}, error) {
+			if string(data) == "null" {
+				return kvOutput{}, nil
+			}
+			return kvOutput{string(data)}, nil
+		},
+	}
+	ops, err := ReadCSVOperations(strings.NewReader(history), codec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Operation{
+		{0, kvInput{op: 1, key: "x", value: "a"}, 0, kvOutput{}, 10},
+		{1, kvInput{op: 0, key: "x"}, 20, kvOutput{"a"}, 30},
+	}
+	if !reflect.DeepEqual(expected, ops) {
+		t.Fatalf("expected %v, got %v", expected, ops)
+	}
+	if !CheckOperations(kvModel, ops) {
+		t.Fatal("expected operations to be linearizable")
+	}
+
+	_, err = ReadCSVOperations(strings.NewReader("client,call,return,input\n"), codec)
+	if err == nil || !strings.Contains(err.Error(), `"output"`) {
+		t.Fatalf("expected an error about the missing column, got %v", err)
+	}
+	_, err = ReadCSVOperations(strings.NewReader(strings.Replace(history, "20,30", "20,soon", 1)), codec)
+	if err == nil || !strings.HasPrefix(err.Error(), "row 2.return") {
+		t.Fatalf("expected an error pointing at the bad cell, got %v", err)
+	}
+}
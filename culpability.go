@@ -0,0 +1,151 @@
+package porcupine
+
+import (
+	"math/rand"
+	"sort"
+	"time"
+)
+
+// CulpabilityOptions configures [RankCulpability].
+type CulpabilityOptions struct {
+	// Fraction of a partition's operations removed in each trial. Defaults
+	// to 0.1 if left as 0.
+	Fraction float64
+	// Number of trials for each partition that is not linearizable.
+	// Defaults to 100 if left as 0.
+	Trials int
+	// Seed for the removals, so that an analysis can be reproduced.
+	Seed int64
+	// Timeout for each individual check; 0 means no timeout.
+	Timeout time.Duration
+}
+
+// A Suspect is an operation whose removal can make a history linearizable,
+// as ranked by [RankCulpability].
+type Suspect struct {
+	Partition int
+	Operation Operation
+	Removed   int // trials that removed the operation and were checked to completion
+	Fixed     int // of those, trials whose remaining operations were linearizable
+}
+
+// Score returns the fraction of the trials that removed the operation after
+// which the partition was linearizable.
+func (s Suspect) Score() float64 {
+	if s.Removed == 0 {
+		return 0
+	}
+	return float64(s.Fixed) / float64(s.Removed)
+}
+
+// A CulpabilityReport is the result of [RankCulpability].
+type CulpabilityReport struct {
+	Result  CheckResult // verdict on the history as recorded
+	Trials  int         // number of trials checked, across partitions
+	Unknown int         // trials that timed out
+	Seed    int64       // the seed that was used
+	// the operations whose removal made a partition linearizable in at
+	// least one trial, by decreasing Score
+	Suspects []Suspect
+}
+
+// Top returns the n operations most likely to be at fault, or all suspects
+// if there are fewer.
+func (r CulpabilityReport) Top(n int) []Suspect {
+	if n > len(r.Suspects) {
+		n = len(r.Suspects)
+	}
+	return r.Suspects[:n]
+}
+
+// RankCulpability ranks the operations of a history that is not linearizable
+// by how likely each is to be at fault, surfacing suspects for the bug, which
+// is usually one stale read or one lost write, without minimizing the
+// history.
+//
+// For each partition that is not linearizable, it repeatedly removes a random
+// sample of the partition's operations and checks the rest. An operation's
+// score is the fraction of the trials that removed it after which the rest
+// was linearizable. An operation without which the partition is linearizable,
+// such as a stale read, scores highest: the rest is linearizable whenever it's
+// removed, unless the trial also removed an operation that others depend on,
+// such as a write whose value is read later. Other operations only score when
+// the culprit happens to be removed along with them, so about Fraction of the
+// time. If either of two operations can be removed, e.g., two reads that
+// disagree on a value, both score highly. Trials that time out are counted in
+// the report, but not in the scores.
+//
+// If the history is linearizable, or its check timed out, the report has no
+// suspects.
+func RankCulpability(model Model, history []Operation, opts CulpabilityOptions) CulpabilityReport {
+	model = fillDefault(model)
+	fraction := opts.Fraction
+	if fraction == 0 {
+		fraction = 0.1
+	}
+	trials := opts.Trials
+	if trials == 0 {
+		trials = 100
+	}
+	report := CulpabilityReport{
+		Result: CheckOperationsTimeout(model, history, opts.Timeout),
+		Seed:   opts.Seed,
+	}
+	if report.Result != Illegal {
+		return report
+	}
+	rng := rand.New(rand.NewSource(opts.Seed))
+	for p, partition := range model.Partition(history) {
+		if len(partition) < 2 || CheckOperationsTimeout(model, partition, opts.Timeout) != Illegal {
+			continue
+		}
+		suspects := make([]Suspect, len(partition))
+		for id, op := range partition {
+			suspects[id] = Suspect{Partition: p, Operation: op}
+		}
+		for i := 0; i < trials; i++ {
+			removed := make([]bool, len(partition))
+			var rest []Operation
+			for id, op := range partition {
+				if rng.Float64() < fraction {
+					removed[id] = true
+				} else {
+					rest = append(rest, op)
+				}
+			}
+			// each trial removes at least one operation
+			if len(rest) == len(partition) {
+				id := rng.Intn(len(partition))
+				removed[id] = true
+				rest = append(rest[:id:id], rest[id+1:]...)
+			}
+			res := CheckOperationsTimeout(model, rest, opts.Timeout)
+			report.Trials++
+			if res == Unknown {
+				report.Unknown++
+				continue
+			}
+			for id := range partition {
+				if removed[id] {
+					suspects[id].Removed++
+					if res == Ok {
+						suspects[id].Fixed++
+					}
+				}
+			}
+		}
+		for _, s := range suspects {
+			if s.Fixed > 0 {
+				report.Suspects = append(report.Suspects, s)
+			}
+		}
+	}
+	sort.SliceStable(report.Suspects, func(i, j int) bool {
+		si, sj := report.Suspects[i], report.Suspects[j]
+		if si.Score() != sj.Score() {
+			return si.Score() > sj.Score()
+		}
+		return si.Fixed > sj.Fixed
+	})
+	return report
+}
@@ -0,0 +1,38 @@
+package porcupine
+
+import "testing"
+
+func TestRankCulpability(t *testing.T) {
+	// a stale read of 1 after the write of 2, among otherwise fine operations
+	ops := []Operation{
+		{0, registerInput{false, 1}, 0, 0, 10},
+		{1, registerInput{true, 0}, 20, 1, 30},
+		{0, registerInput{false, 2}, 40, 0, 50},
+		{1, registerInput{true, 0}, 60, 2, 70},
+		{2, registerInput{true, 0}, 80, 1, 90},
+		{1, registerInput{false, 3}, 100, 0, 110},
+		{0, registerInput{true, 0}, 120, 3, 130},
+		{2, registerInput{true, 0}, 140, 3, 150},
+	}
+	report := RankCulpability(registerModel, ops, CulpabilityOptions{Fraction: 0.2, Trials: 200, Seed: 1})
+	if report.Result != Illegal {
+		t.Fatalf("expected output %v, got output %v", Illegal, report.Result)
+	}
+	if report.Trials != 200 || report.Unknown != 0 {
+		t.Fatalf("expected 200 completed trials, got %d, with %d unknown", report.Trials, report.Unknown)
+	}
+	top := report.Top(1)
+	if len(top) != 1 || top[0].Operation != ops[4] {
+		t.Fatalf("expected the stale read as the top suspect, got %+v", report.Suspects)
+	}
+	for _, s := range report.Suspects[1:] {
+		if s.Score() > top[0].Score()/2 {
+			t.Fatalf("expected the other operations to score well below the stale read, got %+v", report.Suspects)
+		}
+	}
+
+	report = RankCulpability(registerModel, ops[:4], CulpabilityOptions{})
+	if report.Result != Ok || report.Trials != 0 || len(report.Suspects) != 0 {
+		t.Fatalf("expected no trials for a linearizable history, got %+v", report)
+	}
+}
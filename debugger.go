@@ -0,0 +1,215 @@
+package porcupine
+
+import (
+	"fmt"
+	"sort"
+)
+
+// A DebugStepKind is the kind of decision that a [Debugger] made in a step.
+type DebugStepKind int
+
+const (
+	// an operation was linearized next
+	DebugLinearize DebugStepKind = iota
+	// the last operation linearized was undone, since no operation can
+	// follow it
+	DebugBacktrack
+	// the search is over; see Debugger.Result
+	DebugDone
+)
+
+// A DebugStep is a decision that a [Debugger] made.
+type DebugStep struct {
+	Kind DebugStepKind
+	// the operation that was linearized or undone, and its index in the
+	// history, for DebugLinearize and DebugBacktrack
+	ID        int
+	Operation Operation
+}
+
+// A DebugCandidate is an operation that could be linearized next, in the
+// real-time order, as listed by [Debugger.Candidates].
+type DebugCandidate struct {
+	ID        int // the operation's index in the history
+	Operation Operation
+	// whether the model accepts the operation in the current state, and
+	// the state it would go to if so
+	Legal     bool
+	NextState interface{}
+	// whether the search already tried the operation at this point, and
+	// backtracked from it
+	Tried bool
+}
+
+// A Debugger steps through a linearizability search one decision at a time,
+// e.g., to find out why a model, rather than the system, is wrong: why it
+// rejects a history that should be linearizable, or accepts one that
+// shouldn't be. At each point, the operations that could go next are those
+// that no other remaining operation returned before, and each step either
+// linearizes the first of them, by Call, that the model accepts and that
+// wasn't tried yet, or, if there is none, backtracks. Choose and Undo force
+// choices instead.
+//
+// The search is the checker's depth-first search, but without its caching of
+// the states it has seen, and over the whole history, ignoring the model's
+// Partition function, so it's meant for short histories, e.g., a partition
+// of a failed check, from Model.Partition, cut down with [FindFailingWindow].
+type Debugger struct {
+	model Model
+	ops   []Operation
+	// the operations linearized so far, and the states after each of them,
+	// starting from the initial state
+	order  []int
+	states []interface{}
+	// for each depth, the operations tried there
+	tried  []map[int]bool
+	result CheckResult
+}
+
+// NewDebugger returns a Debugger for a search of the history, positioned
+// before the first decision.
+func NewDebugger(model Model, history []Operation) *Debugger {
+	model = fillDefault(model)
+	return &Debugger{
+		model:  model,
+		ops:    history,
+		states: []interface{}{model.Init()},
+		tried:  []map[int]bool{make(map[int]bool)},
+		result: Unknown,
+	}
+}
+
+// State returns the model's state after the operations linearized so far.
+func (d *Debugger) State() interface{} {
+	return d.states[len(d.states)-1]
+}
+
+// Linearized returns the IDs of the operations linearized so far, in order.
+func (d *Debugger) Linearized() []int {
+	return append([]int(nil), d.order...)
+}
+
+// Result returns Ok if every operation has been linearized, Illegal if the
+// search backtracked past the first decision without finding a
+// linearization, and Unknown otherwise.
+func (d *Debugger) Result() CheckResult {
+	return d.result
+}
+
+// Candidates returns the operations that could be linearized next, in the
+// real-time order, sorted by Call, along with whether the model accepts each.
+func (d *Debugger) Candidates() []DebugCandidate {
+	done := make([]bool, len(d.ops))
+	for _, id := range d.order {
+		done[id] = true
+	}
+	// an operation can go next if no other remaining operation returned
+	// before it was called
+	minReturn := int64(0)
+	first := true
+	for id, op := range d.ops {
+		if !done[id] && (first || op.Return < minReturn) {
+			minReturn = op.Return
+			first = false
+		}
+	}
+	var candidates []DebugCandidate
+	for id, op := range d.ops {
+		if done[id] || op.Call > minReturn {
+			continue
+		}
+		ok, next := d.model.Step(d.State(), op.Input, op.Output)
+		c := DebugCandidate{ID: id, Operation: op, Legal: ok, Tried: d.tried[len(d.order)][id]}
+		if ok {
+			c.NextState = next
+		}
+		candidates = append(candidates, c)
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Operation.Call < candidates[j].Operation.Call
+	})
+	return candidates
+}
+
+// Step makes the search's next decision: it linearizes the first candidate
+// that the model accepts and that wasn't tried yet, or, if there is none,
+// backtracks. Once the search is over, it returns a DebugDone step.
+func (d *Debugger) Step() DebugStep {
+	if d.result != Unknown {
+		return DebugStep{Kind: DebugDone}
+	}
+	for _, c := range d.Candidates() {
+		if c.Legal && !c.Tried {
+			d.push(c.ID, c.NextState)
+			if len(d.order) == len(d.ops) {
+				d.result = Ok
+			}
+			return DebugStep{Kind: DebugLinearize, ID: c.ID, Operation: c.Operation}
+		}
+	}
+	if len(d.order) == 0 {
+		d.result = Illegal
+		return DebugStep{Kind: DebugDone}
+	}
+	id := d.pop()
+	return DebugStep{Kind: DebugBacktrack, ID: id, Operation: d.ops[id]}
+}
+
+// Run steps until the search is over, or until stop returns true for a
+// step, e.g., to stop at a breakpoint, and returns the search's result.
+func (d *Debugger) Run(stop func(DebugStep) bool) CheckResult {
+	for d.result == Unknown {
+		step := d.Step()
+		if stop != nil && stop(step) {
+			break
+		}
+	}
+	return d.result
+}
+
+// Choose forces the choice of the next operation, which must be one of the
+// candidates, and one that the model accepts.
+func (d *Debugger) Choose(id int) error {
+	for _, c := range d.Candidates() {
+		if c.ID != id {
+			continue
+		}
+		if !c.Legal {
+			return fmt.Errorf("porcupine: the model rejects operation %d in the current state", id)
+		}
+		d.push(id, c.NextState)
+		d.result = Unknown
+		if len(d.order) == len(d.ops) {
+			d.result = Ok
+		}
+		return nil
+	}
+	return fmt.Errorf("porcupine: operation %d can't be linearized next", id)
+}
+
+// Undo undoes the last operation linearized, returning false if there is
+// none. The operation stays marked as tried, so that Step won't choose it
+// again at this point, but Choose can.
+func (d *Debugger) Undo() bool {
+	if len(d.order) == 0 {
+		return false
+	}
+	d.pop()
+	d.result = Unknown
+	return true
+}
+
+func (d *Debugger) push(id int, state interface{}) {
+	d.tried[len(d.order)][id] = true
+	d.order = append(d.order, id)
+	d.states = append(d.states, state)
+	d.tried = append(d.tried, make(map[int]bool))
+}
+
+func (d *Debugger) pop() int {
+	id := d.order[len(d.order)-1]
+	d.order = d.order[:len(d.order)-1]
+	d.states = d.states[:len(d.states)-1]
+	d.tried = d.tried[:len(d.tried)-1]
+	return id
+}
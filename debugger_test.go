@@ -0,0 +1,90 @@
+package porcupine
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDebugger(t *testing.T) {
+	ops := []Operation{
+		{0, registerInput{false, 1}, 0, 0, 10},
+		{1, registerInput{false, 2}, 0, 0, 10},
+		{2, registerInput{true, 0}, 20, 1, 30},
+	}
+	d := NewDebugger(registerModel, ops)
+	candidates := d.Candidates()
+	if len(candidates) != 2 || candidates[0].ID != 0 || candidates[1].ID != 1 {
+		t.Fatalf("expected the two writes as candidates, got %+v", candidates)
+	}
+	if !candidates[0].Legal || candidates[0].NextState != 1 || candidates[0].Tried {
+		t.Fatalf("expected the write of 1 to be legal and untried, got %+v", candidates[0])
+	}
+
+	// the search tries the write of 1 then the write of 2, after which the
+	// read of 1 is rejected, so it backtracks and tries the write of 2 first
+	expected := []DebugStep{
+		{Kind: DebugLinearize, ID: 0, Operation: ops[0]},
+		{Kind: DebugLinearize, ID: 1, Operation: ops[1]},
+		{Kind: DebugBacktrack, ID: 1, Operation: ops[1]},
+		{Kind: DebugBacktrack, ID: 0, Operation: ops[0]},
+		{Kind: DebugLinearize, ID: 1, Operation: ops[1]},
+		{Kind: DebugLinearize, ID: 0, Operation: ops[0]},
+		{Kind: DebugLinearize, ID: 2, Operation: ops[2]},
+	}
+	var steps []DebugStep
+	res := d.Run(func(step DebugStep) bool {
+		steps = append(steps, step)
+		return false
+	})
+	if res != Ok {
+		t.Fatalf("expected output %v, got output %v", Ok, res)
+	}
+	if !reflect.DeepEqual(steps, expected) {
+		t.Fatalf("expected steps %+v, got %+v", expected, steps)
+	}
+	if l := d.Linearized(); !reflect.DeepEqual(l, []int{1, 0, 2}) {
+		t.Fatalf("expected linearization [1 0 2], got %v", l)
+	}
+	if step := d.Step(); step.Kind != DebugDone {
+		t.Fatalf("expected the search to be over, got %+v", step)
+	}
+}
+
+func TestDebuggerChoose(t *testing.T) {
+	ops := []Operation{
+		{0, registerInput{false, 1}, 0, 0, 10},
+		{1, registerInput{true, 0}, 20, 1, 30},
+		{2, registerInput{true, 0}, 40, 0, 50},
+	}
+	d := NewDebugger(registerModel, ops)
+	if err := d.Choose(1); err == nil {
+		t.Fatal("expected an error choosing an operation that can't go next")
+	}
+	if err := d.Choose(0); err != nil {
+		t.Fatal(err)
+	}
+	if d.State() != 1 {
+		t.Fatalf("expected state 1, got %v", d.State())
+	}
+	if err := d.Choose(1); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Choose(2); err == nil {
+		t.Fatal("expected an error choosing an operation that the model rejects")
+	}
+	if !d.Undo() || !d.Undo() || d.Undo() {
+		t.Fatal("expected to undo exactly two operations")
+	}
+	if d.State() != 0 {
+		t.Fatalf("expected the initial state, got %v", d.State())
+	}
+	// the write was tried at the start, so the search has nothing left
+	if step := d.Step(); step.Kind != DebugDone || d.Result() != Illegal {
+		t.Fatalf("expected the search to fail, got %+v and %v", step, d.Result())
+	}
+
+	d = NewDebugger(registerModel, ops)
+	if res := d.Run(nil); res != Illegal {
+		t.Fatalf("expected output %v, got output %v", Illegal, res)
+	}
+}
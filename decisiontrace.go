@@ -0,0 +1,187 @@
+package porcupine
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// A DecisionKind is the kind of a decision of the checker's search, as
+// recorded by [WithDecisionTrace].
+type DecisionKind byte
+
+const (
+	// the model accepted an operation, which was linearized next, unless
+	// the resulting state had already been visited (see Decision.CacheHit)
+	DecisionLinearize DecisionKind = iota
+	// the search backtracked, reverting the last operation linearized
+	DecisionRevert
+)
+
+// A Decision is a step of the checker's search, as recorded by
+// [WithDecisionTrace] and read by [ReadDecisionTrace].
+type Decision struct {
+	Kind      DecisionKind
+	Partition int
+	// the operation's ID within the partition, as in
+	// [LinearizationInfo.PartialLinearizations]; for partitions split with
+	// [WithPartitionSplitting], within the piece of the partition being
+	// searched
+	Operation int
+	// the key of the search's cache for the resulting state: a hash of the
+	// state, if the model has a Hash function, combined with a hash of the
+	// set of linearized operations; for reverts, the key of the state being
+	// left, or 0 if it isn't known, e.g., for a search resumed from a
+	// checkpoint
+	StateHash uint64
+	// for DecisionLinearize, whether the state had already been visited
+	// with the same operations linearized, so the search didn't linearize
+	// the operation after all
+	CacheHit bool
+}
+
+// decisionTraceMagic starts a decision trace, followed by its version.
+const decisionTraceMagic = "PCDT\x01"
+
+// Each decision is written as a byte for its kind, with the high bit set for
+// cache hits, the partition and operation as uvarints, and the state hash as
+// 8 little-endian bytes.
+const decisionCacheHit = 0x80
+
+// A decisionTracer writes the decisions of the searches of every partition
+// of a check to one writer.
+type decisionTracer struct {
+	mu  sync.Mutex
+	w   *bufio.Writer
+	err error // the first write error, after which the trace stops
+}
+
+func newDecisionTracer(w io.Writer) *decisionTracer {
+	if w == nil {
+		return nil
+	}
+	t := &decisionTracer{w: bufio.NewWriter(w)}
+	_, t.err = t.w.WriteString(decisionTraceMagic)
+	return t
+}
+
+func (t *decisionTracer) write(d Decision) {
+	var buf [1 + 2*binary.MaxVarintLen64 + 8]byte
+	buf[0] = byte(d.Kind)
+	if d.CacheHit {
+		buf[0] |= decisionCacheHit
+	}
+	n := 1
+	n += binary.PutUvarint(buf[n:], uint64(d.Partition))
+	n += binary.PutUvarint(buf[n:], uint64(d.Operation))
+	binary.LittleEndian.PutUint64(buf[n:], d.StateHash)
+	n += 8
+	t.mu.Lock()
+	if t.err == nil {
+		_, t.err = t.w.Write(buf[:n])
+	}
+	t.mu.Unlock()
+}
+
+// finish flushes the trace.
+func (t *decisionTracer) finish() {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.err == nil {
+		t.err = t.w.Flush()
+	}
+}
+
+// partition returns the tracer for the search of partition p, or nil if
+// there is no trace.
+func (t *decisionTracer) partition(p int) *partitionTracer {
+	if t == nil {
+		return nil
+	}
+	return &partitionTracer{t: t, partition: p}
+}
+
+// A partitionTracer records the decisions of one partition's search; its
+// methods do nothing on a nil tracer.
+type partitionTracer struct {
+	t         *decisionTracer
+	partition int
+	// the state hashes of the operations on the search's stack
+	hashes []uint64
+}
+
+func (pt *partitionTracer) linearize(id int, hash uint64, hit bool) {
+	if pt == nil {
+		return
+	}
+	if !hit {
+		pt.hashes = append(pt.hashes, hash)
+	}
+	pt.t.write(Decision{Kind: DecisionLinearize, Partition: pt.partition, Operation: id, StateHash: hash, CacheHit: hit})
+}
+
+func (pt *partitionTracer) revert(id int) {
+	if pt == nil {
+		return
+	}
+	var hash uint64
+	if len(pt.hashes) > 0 {
+		hash = pt.hashes[len(pt.hashes)-1]
+		pt.hashes = pt.hashes[:len(pt.hashes)-1]
+	}
+	pt.t.write(Decision{Kind: DecisionRevert, Partition: pt.partition, Operation: id, StateHash: hash})
+}
+
+// ReadDecisionTrace reads the decisions recorded by [WithDecisionTrace], in
+// the order they were made within each partition; the decisions of
+// partitions searched at the same time are interleaved.
+func ReadDecisionTrace(r io.Reader) ([]Decision, error) {
+	br := bufio.NewReader(r)
+	magic := make([]byte, len(decisionTraceMagic))
+	if _, err := io.ReadFull(br, magic); err != nil || string(magic) != decisionTraceMagic {
+		return nil, errors.New("porcupine: not a decision trace")
+	}
+	var decisions []Decision
+	for {
+		kind, err := br.ReadByte()
+		if err == io.EOF {
+			return decisions, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		d := Decision{Kind: DecisionKind(kind &^ decisionCacheHit), CacheHit: kind&decisionCacheHit != 0}
+		if d.Kind > DecisionRevert {
+			return nil, fmt.Errorf("porcupine: unknown decision kind %d", d.Kind)
+		}
+		partition, err := binary.ReadUvarint(br)
+		if err != nil {
+			return nil, truncatedTrace(err)
+		}
+		operation, err := binary.ReadUvarint(br)
+		if err != nil {
+			return nil, truncatedTrace(err)
+		}
+		var hash [8]byte
+		if _, err := io.ReadFull(br, hash[:]); err != nil {
+			return nil, truncatedTrace(err)
+		}
+		d.Partition = int(partition)
+		d.Operation = int(operation)
+		d.StateHash = binary.LittleEndian.Uint64(hash[:])
+		decisions = append(decisions, d)
+	}
+}
+
+func truncatedTrace(err error) error {
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return fmt.Errorf("porcupine: reading decision trace: %v", err)
+}
@@ -0,0 +1,77 @@
+package porcupine
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDecisionTrace(t *testing.T) {
+	// the search linearizes the write of 1 first, and has to revert it
+	ops := []Operation{
+		{0, registerInput{false, 1}, 0, 0, 10},
+		{1, registerInput{false, 2}, 0, 0, 10},
+		{2, registerInput{true, 0}, 20, 1, 30},
+	}
+	var buf bytes.Buffer
+	if !CheckOperations(registerModel, ops, WithDecisionTrace(&buf)) {
+		t.Fatal("expected operations to be linearizable")
+	}
+	decisions, err := ReadDecisionTrace(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kinds := []DecisionKind{DecisionLinearize, DecisionLinearize, DecisionRevert, DecisionRevert, DecisionLinearize, DecisionLinearize, DecisionLinearize}
+	ids := []int{0, 1, 1, 0, 1, 0, 2}
+	if len(decisions) != len(kinds) {
+		t.Fatalf("expected %d decisions, got %+v", len(kinds), decisions)
+	}
+	for i, d := range decisions {
+		if d.Kind != kinds[i] || d.Operation != ids[i] || d.Partition != 0 || d.CacheHit {
+			t.Fatalf("expected decision %d to be of kind %d on operation %d, got %+v", i, kinds[i], ids[i], decisions)
+		}
+	}
+	// a revert leaves the state that the operation's linearization led to
+	if decisions[2].StateHash != decisions[1].StateHash || decisions[3].StateHash != decisions[0].StateHash {
+		t.Fatalf("expected reverts to leave the states linearized into, got %+v", decisions)
+	}
+
+	// cache hits are recorded: the reads of 0 lead to the same state in
+	// either order, so the second order is found in the cache
+	ops = []Operation{
+		{0, registerInput{true, 0}, 0, 0, 10},
+		{1, registerInput{true, 0}, 0, 0, 10},
+		{2, registerInput{true, 0}, 20, 1, 30},
+	}
+	buf.Reset()
+	if CheckOperations(registerModel, ops, WithDecisionTrace(&buf)) {
+		t.Fatal("expected operations not to be linearizable")
+	}
+	decisions, err = ReadDecisionTrace(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hit := false
+	for _, d := range decisions {
+		hit = hit || d.CacheHit
+	}
+	if !hit {
+		t.Fatalf("expected a cache hit, got %+v", decisions)
+	}
+}
+
+func TestReadDecisionTraceErrors(t *testing.T) {
+	if _, err := ReadDecisionTrace(bytes.NewReader([]byte("not a trace"))); err == nil {
+		t.Fatal("expected an error reading something that isn't a trace")
+	}
+	var buf bytes.Buffer
+	CheckOperations(registerModel, []Operation{{0, registerInput{false, 1}, 0, 0, 10}}, WithDecisionTrace(&buf))
+	trace := buf.Bytes()
+	if _, err := ReadDecisionTrace(bytes.NewReader(trace[:len(trace)-1])); err == nil {
+		t.Fatal("expected an error reading a truncated trace")
+	}
+	buf.Reset()
+	CheckOperations(registerModel, nil, WithDecisionTrace(&buf))
+	if decisions, err := ReadDecisionTrace(&buf); err != nil || len(decisions) != 0 {
+		t.Fatalf("expected an empty trace, got %+v, %v", decisions, err)
+	}
+}
@@ -0,0 +1,153 @@
+package porcupine
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"sync"
+)
+
+// A Decoder reads a history of events in some format, e.g., a proprietary
+// log format; see [RegisterDecoder].
+type Decoder func(r io.Reader) ([]Event, error)
+
+var (
+	decodersMu sync.RWMutex
+	decoders   = make(map[string]Decoder)
+)
+
+func init() {
+	for format, decoder := range map[string]Decoder{
+		"json": func(r io.Reader) ([]Event, error) {
+			file, err := readLegacyHistoryFile(r, nil)
+			return file.events(), err
+		},
+		"jsonl": func(r io.Reader) ([]Event, error) {
+			events, pending, err := ReadJSONLEvents(r, nil)
+			if err == nil && len(pending) > 0 {
+				err = fmt.Errorf("%d calls without returns", len(pending))
+			}
+			return events, err
+		},
+		"jsonl-operations": func(r io.Reader) ([]Event, error) {
+			ops, err := ReadJSONLOperations(r, nil)
+			return operationEvents(ops), err
+		},
+		"csv": func(r io.Reader) ([]Event, error) {
+			ops, err := ReadCSVOperations(r, nil)
+			return operationEvents(ops), err
+		},
+		"proto": func(r io.Reader) ([]Event, error) {
+			data, err := ioutil.ReadAll(r)
+			if err != nil {
+				return nil, err
+			}
+			// a History message holds either operations or events
+			events, err := UnmarshalEventsProto(data, nil)
+			if err != nil || len(events) > 0 {
+				return events, err
+			}
+			ops, err := UnmarshalOperationsProto(data, nil)
+			return operationEvents(ops), err
+		},
+		"msgpack": func(r io.Reader) ([]Event, error) {
+			data, err := ioutil.ReadAll(r)
+			if err != nil {
+				return nil, err
+			}
+			events, err := UnmarshalEventsMsgpack(data, nil)
+			if err != nil || len(events) > 0 {
+				return events, err
+			}
+			ops, err := UnmarshalOperationsMsgpack(data, nil)
+			return operationEvents(ops), err
+		},
+		"knossos": func(r io.Reader) ([]Event, error) {
+			ops, err := ReadKnossosHistory(r)
+			if err != nil {
+				return nil, err
+			}
+			return KnossosEvents(ops)
+		},
+		"history": func(r io.Reader) ([]Event, error) {
+			file, err := ReadHistoryFile(r, nil)
+			return file.events(), err
+		},
+	} {
+		RegisterDecoder(format, decoder)
+	}
+}
+
+// RegisterDecoder registers a decoder for a history format under the given
+// name, so that [ReadEvents] and [ReadHistoryFile] can load histories in that
+// format, e.g., a proprietary log format, without changes to this package.
+// Registering a decoder under an existing name replaces the old one.
+//
+// The built-in formats are "json", "jsonl" (see [ReadJSONLEvents]),
+// "jsonl-operations" (see [ReadJSONLOperations]), "csv", "proto", "msgpack",
+// "knossos", and "history" (see [ReadHistoryFile]). Their decoders decode
+// inputs and outputs as dynamic values, or with the codec named in the
+// history, if any, and convert histories of operations to events.
+func RegisterDecoder(format string, decoder Decoder) {
+	decodersMu.Lock()
+	defer decodersMu.Unlock()
+	decoders[format] = decoder
+}
+
+// LookupDecoder returns the decoder registered for the given format.
+func LookupDecoder(format string) (Decoder, bool) {
+	decodersMu.RLock()
+	defer decodersMu.RUnlock()
+	decoder, ok := decoders[format]
+	return decoder, ok
+}
+
+// DecoderFormats returns the names of the registered formats, in sorted order.
+func DecoderFormats() []string {
+	decodersMu.RLock()
+	defer decodersMu.RUnlock()
+	formats := make([]string, 0, len(decoders))
+	for format := range decoders {
+		formats = append(formats, format)
+	}
+	sort.Strings(formats)
+	return formats
+}
+
+// ReadEvents reads a history of events in the given format, with the decoder
+// registered for it; see [RegisterDecoder]. A compressed history is
+// decompressed before it is passed to the decoder; see [RegisterCompression].
+func ReadEvents(format string, r io.Reader) ([]Event, error) {
+	decoder, ok := LookupDecoder(format)
+	if !ok {
+		return nil, fmt.Errorf("unknown history format %q", format)
+	}
+	return decoder(decompressed(r))
+}
+
+// events returns the file's history as events.
+func (f HistoryFile) events() []Event {
+	if f.Header.Kind == "events" {
+		return f.Events
+	}
+	return operationEvents(f.Operations)
+}
+
+// operationEvents converts a history of operations to the equivalent history
+// of events, ordered by time as the checker orders them.
+func operationEvents(history []Operation) []Event {
+	if history == nil {
+		return nil
+	}
+	entries := makeEntries(history, nil)
+	events := make([]Event, len(entries))
+	for i, e := range entries {
+		kind := CallEvent
+		if e.kind == returnEntry {
+			kind = ReturnEvent
+		}
+		events[i] = Event{ClientId: e.clientId, Kind: kind, Value: e.value, Id: e.id}
+	}
+	return events
+}
@@ -0,0 +1,106 @@
+package porcupine
+
+import (
+	"bufio"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// decodeRegisterLog reads a made-up log format, with lines like
+//
+//	<client> call <value>
+//	<client> return <value>
+func decodeRegisterLog(r io.Reader) ([]Event, error) {
+	var events []Event
+	pending := make(map[int]int)
+	scanner := bufio.NewScanner(r)
+	for id := 0; scanner.Scan(); {
+		fields := strings.Fields(scanner.Text())
+		client, _ := strconv.Atoi(fields[0])
+		value, _ := strconv.Atoi(fields[2])
+		if fields[1] == "call" {
+			pending[client] = id
+			events = append(events, Event{ClientId: client, Kind: CallEvent, Value: registerInput{value < 0, value}, Id: id})
+			id++
+		} else {
+			events = append(events, Event{ClientId: client, Kind: ReturnEvent, Value: value, Id: pending[client]})
+		}
+	}
+	return events, scanner.Err()
+}
+
+func TestRegisterDecoder(t *testing.T) {
+	RegisterDecoder("register-log", decodeRegisterLog)
+	if _, ok := LookupDecoder("register-log"); !ok {
+		t.Fatal("expected the decoder to be registered")
+	}
+	found := false
+	for _, format := range DecoderFormats() {
+		found = found || format == "register-log"
+	}
+	if !found {
+		t.Fatalf("expected register-log in %v", DecoderFormats())
+	}
+	events, err := ReadEvents("register-log", strings.NewReader("0 call 1\n1 call -1\n0 return 0\n1 return 1\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !CheckEvents(registerModel, events) {
+		t.Fatal("expected events to be linearizable")
+	}
+
+	// the history file container uses registered decoders too
+	file, err := ReadHistoryFile(strings.NewReader("PORCUPINE-HISTORY 1\n{\"encoding\": \"register-log\"}\n0 call 1\n0 return 0\n"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file.Header.Kind != "events" || len(file.Events) != 2 {
+		t.Fatalf("unexpected file %+v", file)
+	}
+
+	if _, err := ReadEvents("nope", strings.NewReader("")); err == nil || err.Error() != `unknown history format "nope"` {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestBuiltinDecoders(t *testing.T) {
+	expected := []Event{
+		{0, CallEvent, "x", 0},
+		{1, CallEvent, "y", 1},
+		{0, ReturnEvent, int64(1), 0},
+		{1, ReturnEvent, int64(2), 1},
+	}
+	for _, tc := range []struct {
+		format string
+		input  string
+	}{
+		{"json", `{"operations": [{"client": 0, "input": "x", "call": 0, "output": 1, "return": 10},
+			{"client": 1, "input": "y", "call": 5, "output": 2, "return": 20}]}`},
+		{"csv", "client,call,return,input,output\n0,0,10,\"\"\"x\"\"\",1\n1,5,20,\"\"\"y\"\"\",2\n"},
+		{"jsonl-operations", `{"client": 0, "input": "x", "call": 0, "output": 1, "return": 10}
+			{"client": 1, "input": "y", "call": 5, "output": 2, "return": 20}`},
+		{"jsonl", `{"client": 0, "kind": "call", "value": "x", "id": 0}
+			{"client": 1, "kind": "call", "value": "y", "id": 1}
+			{"client": 0, "kind": "return", "value": 1, "id": 0}
+			{"client": 1, "kind": "return", "value": 2, "id": 1}`},
+	} {
+		events, err := ReadEvents(tc.format, strings.NewReader(tc.input))
+		if err != nil {
+			t.Fatalf("%s: %v", tc.format, err)
+		}
+		if !reflect.DeepEqual(expected, events) {
+			t.Fatalf("%s: expected %v, got %v", tc.format, expected, events)
+		}
+	}
+
+	events, err := ReadEvents("knossos", strings.NewReader(knossosHistory))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 8 {
+		t.Fatalf("expected 8 events, got %d", len(events))
+	}
+}
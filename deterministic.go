@@ -0,0 +1,74 @@
+package porcupine
+
+import "sort"
+
+// sortOperationPartitions puts the partitions of a history of operations in
+// a canonical order, for WithDeterministic: by their entries, compared by
+// time, then kind, then client.
+func sortOperationPartitions(partitions [][]entry) {
+	sort.SliceStable(partitions, func(i, j int) bool {
+		a, b := partitions[i], partitions[j]
+		for k := 0; k < len(a) && k < len(b); k++ {
+			if a[k].time != b[k].time {
+				return a[k].time < b[k].time
+			}
+			if a[k].kind != b[k].kind {
+				return a[k].kind == callEntry
+			}
+			if a[k].clientId != b[k].clientId {
+				return a[k].clientId < b[k].clientId
+			}
+		}
+		return len(a) < len(b)
+	})
+}
+
+// sortEventPartitions puts the partitions of a history of events in a
+// canonical order, for WithDeterministic: by the position of their first
+// event in the history.
+func sortEventPartitions(history []Event, partitions [][]Event) {
+	type key struct {
+		id   int
+		kind EventKind
+	}
+	positions := make(map[key]int, len(history))
+	for i, e := range history {
+		positions[key{e.Id, e.Kind}] = i
+	}
+	first := make([]int, len(partitions))
+	for i, partition := range partitions {
+		first[i] = len(history)
+		for _, e := range partition {
+			if p := positions[key{e.Id, e.Kind}]; p < first[i] {
+				first[i] = p
+			}
+		}
+	}
+	order := make([]int, len(partitions))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool { return first[order[i]] < first[order[j]] })
+	sorted := make([][]Event, len(partitions))
+	for i, j := range order {
+		sorted[i] = partitions[j]
+	}
+	copy(partitions, sorted)
+}
+
+// sortPartials puts a partition's partial linearizations in a canonical
+// order, for WithDeterministic: longest first, then by their operation IDs.
+func sortPartials(partials [][]int) {
+	sort.Slice(partials, func(i, j int) bool {
+		a, b := partials[i], partials[j]
+		if len(a) != len(b) {
+			return len(a) > len(b)
+		}
+		for k := range a {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+		return false
+	})
+}
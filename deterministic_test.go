@@ -0,0 +1,45 @@
+package porcupine
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestWithDeterministic(t *testing.T) {
+	// kvModel's PartitionEvent returns partitions in map order, which
+	// varies from run to run
+	events := parseKvLog("test_data/kv/c10-bad.txt")
+	ops := kvOperations(events)
+	var firstEvents, firstOps [][][]int
+	var firstFailure *PartitionFailure
+	for i := 0; i < 5; i++ {
+		res, info := CheckEventsVerbose(kvModel, events, 0, WithDeterministic())
+		if res != Illegal {
+			t.Fatalf("expected output %v, got output %v", Illegal, res)
+		}
+		_, opsInfo := CheckHistory(kvModel, ops, WithVerbose(), WithDeterministic())
+		_, failure := CheckOperationsFailFast(kvModel, ops, nil, WithDeterministic())
+		if i == 0 {
+			firstEvents, firstOps, firstFailure = info.PartialLinearizations(), opsInfo.PartialLinearizations(), failure
+			continue
+		}
+		if !reflect.DeepEqual(info.PartialLinearizations(), firstEvents) {
+			t.Fatal("expected the same partial linearizations from every check of events")
+		}
+		if !reflect.DeepEqual(opsInfo.PartialLinearizations(), firstOps) {
+			t.Fatal("expected the same partial linearizations from every check of operations")
+		}
+		if failure == nil || failure.Index != firstFailure.Index {
+			t.Fatalf("expected the same failed partition %d, got %v", firstFailure.Index, failure)
+		}
+	}
+}
+
+func TestSortPartials(t *testing.T) {
+	partials := [][]int{{2, 1}, {0, 1, 2}, {1, 0}, {3}}
+	sortPartials(partials)
+	expected := [][]int{{0, 1, 2}, {1, 0}, {2, 1}, {3}}
+	if !reflect.DeepEqual(partials, expected) {
+		t.Fatalf("expected %v, got %v", expected, partials)
+	}
+}
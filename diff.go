@@ -0,0 +1,165 @@
+package porcupine
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// A DiffKind is the kind of a difference between two histories, as found by
+// [DiffHistories].
+type DiffKind int
+
+const (
+	// an operation of the first history has no counterpart in the second
+	DiffRemoved DiffKind = iota
+	// an operation of the second history has no counterpart in the first
+	DiffAdded
+	// an operation has a different output in the second history
+	DiffOutput
+	// two operations are in a different real-time order in the second
+	// history: one that preceded the other now overlaps or follows it, or
+	// the other way around
+	DiffOrder
+)
+
+// A DiffEntry is a difference between two histories, as found by
+// [DiffHistories].
+type DiffEntry struct {
+	Kind DiffKind
+	// the operation in the first and second histories; Old is nil for
+	// DiffAdded, and New is nil for DiffRemoved
+	Old, New *Operation
+	// for DiffOrder, the other operation, in the first and second histories
+	OtherOld, OtherNew *Operation
+}
+
+// DiffHistories compares two histories of the same test, e.g., from a run
+// that passed and one that failed, to show what changed between them. Since
+// timestamps differ from run to run, operations are aligned by client and by
+// content: each client's operations, in the order it invoked them, are
+// matched with the other history's by their inputs, keeping as many matches
+// as possible. Matched operations whose outputs differ are reported, as are
+// operations without a match, and pairs of matched operations of different
+// clients whose real-time order differs, e.g., a write that preceded a read
+// in the first history but overlaps it in the second.
+//
+// Differences in operations' outputs and presence come first, by client, in
+// the order the client invoked them, followed by differences in order.
+func DiffHistories(old, new []Operation) []DiffEntry {
+	oldByClient := operationsByClient(old)
+	newByClient := operationsByClient(new)
+	clients := make(map[int]bool)
+	for c := range oldByClient {
+		clients[c] = true
+	}
+	for c := range newByClient {
+		clients[c] = true
+	}
+	var ids []int
+	for c := range clients {
+		ids = append(ids, c)
+	}
+	sort.Ints(ids)
+
+	var diffs []DiffEntry
+	type match struct{ old, new *Operation }
+	var matches []match
+	for _, c := range ids {
+		a, b := oldByClient[c], newByClient[c]
+		// lcs[i][j] is the number of matches between a[i:] and b[j:]
+		lcs := make([][]int, len(a)+1)
+		for i := range lcs {
+			lcs[i] = make([]int, len(b)+1)
+		}
+		for i := len(a) - 1; i >= 0; i-- {
+			for j := len(b) - 1; j >= 0; j-- {
+				if reflect.DeepEqual(a[i].Input, b[j].Input) {
+					lcs[i][j] = lcs[i+1][j+1] + 1
+				} else if lcs[i+1][j] >= lcs[i][j+1] {
+					lcs[i][j] = lcs[i+1][j]
+				} else {
+					lcs[i][j] = lcs[i][j+1]
+				}
+			}
+		}
+		i, j := 0, 0
+		for i < len(a) || j < len(b) {
+			switch {
+			case i < len(a) && j < len(b) && reflect.DeepEqual(a[i].Input, b[j].Input) && lcs[i][j] == lcs[i+1][j+1]+1:
+				matches = append(matches, match{&a[i], &b[j]})
+				if !reflect.DeepEqual(a[i].Output, b[j].Output) {
+					diffs = append(diffs, DiffEntry{Kind: DiffOutput, Old: &a[i], New: &b[j]})
+				}
+				i++
+				j++
+			case j == len(b) || (i < len(a) && lcs[i+1][j] >= lcs[i][j+1]):
+				diffs = append(diffs, DiffEntry{Kind: DiffRemoved, Old: &a[i]})
+				i++
+			default:
+				diffs = append(diffs, DiffEntry{Kind: DiffAdded, New: &b[j]})
+				j++
+			}
+		}
+	}
+
+	for x := range matches {
+		for y := x + 1; y < len(matches); y++ {
+			mx, my := matches[x], matches[y]
+			if mx.old.ClientId == my.old.ClientId {
+				continue
+			}
+			if realTimeOrder(*mx.old, *my.old) != realTimeOrder(*mx.new, *my.new) {
+				diffs = append(diffs, DiffEntry{Kind: DiffOrder, Old: mx.old, New: mx.new, OtherOld: my.old, OtherNew: my.new})
+			}
+		}
+	}
+	return diffs
+}
+
+// operationsByClient returns the operations of each client, in the order it
+// invoked them.
+func operationsByClient(history []Operation) map[int][]Operation {
+	byClient := make(map[int][]Operation)
+	for _, op := range history {
+		byClient[op.ClientId] = append(byClient[op.ClientId], op)
+	}
+	for _, ops := range byClient {
+		sort.SliceStable(ops, func(i, j int) bool { return ops[i].Call < ops[j].Call })
+	}
+	return byClient
+}
+
+// realTimeOrder returns -1 if a returned before b was called, 1 if b
+// returned before a was called, and 0 if they overlap.
+func realTimeOrder(a, b Operation) int {
+	switch {
+	case a.Return < b.Call:
+		return -1
+	case b.Return < a.Call:
+		return 1
+	}
+	return 0
+}
+
+// Describe describes the difference using the model's DescribeOperation, e.g.,
+// "client 1: get() -> '1' became get() -> '2'".
+func (d DiffEntry) Describe(model Model) string {
+	model = fillDefault(model)
+	describe := func(op *Operation) string {
+		return model.DescribeOperation(op.Input, op.Output)
+	}
+	switch d.Kind {
+	case DiffRemoved:
+		return fmt.Sprintf("client %d: %s was removed", d.Old.ClientId, describe(d.Old))
+	case DiffAdded:
+		return fmt.Sprintf("client %d: %s was added", d.New.ClientId, describe(d.New))
+	case DiffOutput:
+		return fmt.Sprintf("client %d: %s became %s", d.Old.ClientId, describe(d.Old), describe(d.New))
+	}
+	past := map[int]string{-1: "preceded", 0: "overlapped", 1: "followed"}
+	present := map[int]string{-1: "precedes", 0: "overlaps", 1: "follows"}
+	return fmt.Sprintf("%s (client %d) %s %s (client %d), but now %s it",
+		describe(d.Old), d.Old.ClientId, past[realTimeOrder(*d.Old, *d.OtherOld)],
+		describe(d.OtherOld), d.OtherOld.ClientId, present[realTimeOrder(*d.New, *d.OtherNew)])
+}
@@ -0,0 +1,51 @@
+package porcupine
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffHistories(t *testing.T) {
+	passing := []Operation{
+		{0, registerInput{false, 1}, 0, 0, 10},
+		{1, registerInput{true, 0}, 20, 1, 30},
+		{0, registerInput{false, 2}, 40, 0, 50},
+		{1, registerInput{true, 0}, 60, 2, 70},
+	}
+	// timestamps shift, the write of 2 now overlaps the read after it,
+	// which returns a stale value, and client 1 reads again
+	failing := []Operation{
+		{0, registerInput{false, 1}, 100, 0, 110},
+		{1, registerInput{true, 0}, 120, 1, 130},
+		{0, registerInput{false, 2}, 140, 0, 165},
+		{1, registerInput{true, 0}, 160, 1, 170},
+		{1, registerInput{true, 0}, 180, 2, 190},
+	}
+	diffs := DiffHistories(passing, failing)
+	expected := []DiffEntry{
+		{Kind: DiffOutput, Old: &passing[3], New: &failing[3]},
+		{Kind: DiffAdded, New: &failing[4]},
+		{Kind: DiffOrder, Old: &passing[2], New: &failing[2], OtherOld: &passing[3], OtherNew: &failing[3]},
+	}
+	if !reflect.DeepEqual(diffs, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, diffs)
+	}
+	descriptions := []string{
+		"client 1: get() -> '2' became get() -> '1'",
+		"client 1: get() -> '2' was added",
+		"put('2') (client 0) preceded get() -> '2' (client 1), but now overlaps it",
+	}
+	for i, d := range diffs {
+		if s := d.Describe(registerModel); s != descriptions[i] {
+			t.Fatalf("expected description %q, got %q", descriptions[i], s)
+		}
+	}
+
+	if diffs := DiffHistories(passing, passing); len(diffs) != 0 {
+		t.Fatalf("expected no differences, got %+v", diffs)
+	}
+	diffs = DiffHistories(passing, passing[:3])
+	if len(diffs) != 1 || diffs[0].Kind != DiffRemoved || *diffs[0].Old != passing[3] {
+		t.Fatalf("expected the last read to be removed, got %+v", diffs)
+	}
+}
@@ -0,0 +1,294 @@
+package porcupine
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+var (
+	modelsMu sync.RWMutex
+	models   = make(map[string]Model)
+)
+
+// RegisterModel registers a model under the given name, so that remote
+// workers can check histories for it (see [NewWorkerHandler]). Models are Go
+// code, so the coordinator and every worker must register the same model
+// under the same name. Registering a model under an existing name replaces
+// the old one.
+func RegisterModel(name string, model Model) {
+	modelsMu.Lock()
+	defer modelsMu.Unlock()
+	models[name] = model
+}
+
+// LookupModel returns the model registered under the given name.
+func LookupModel(name string) (Model, bool) {
+	modelsMu.RLock()
+	defer modelsMu.RUnlock()
+	model, ok := models[name]
+	return model, ok
+}
+
+// remoteCheckRequest is the body of a request to a worker.
+type remoteCheckRequest struct {
+	Model      string          `json:"model"`
+	Codec      string          `json:"codec,omitempty"`
+	Operations []jsonOperation `json:"operations"`
+}
+
+// remoteCheckResponse is the body of a worker's response.
+type remoteCheckResponse struct {
+	Result CheckResult `json:"result,omitempty"`
+	Error  string      `json:"error,omitempty"`
+}
+
+// NewWorkerHandler returns an HTTP handler that checks histories sent to it
+// by a [Coordinator], on the given engine, so that checks from several
+// coordinators share the engine's workers.
+//
+// A request is a POST of a JSON document of the form
+//
+//	{
+//	  "model": "kv",
+//	  "codec": "kv",
+//	  "operations": [
+//	    {"client": 0, "input": ..., "call": 0, "output": ..., "return": 10},
+//	    ...
+//	  ]
+//	}
+//
+// where the model and the codec for inputs and outputs are referred to by the
+// names they are registered under with [RegisterModel] and [RegisterCodec];
+// without a codec, inputs and outputs are decoded as dynamic values. The
+// response is a JSON document {"result": "Ok"}, or {"error": "..."} with an
+// HTTP error status. The check stops if the request is cancelled.
+func NewWorkerHandler(engine *Engine) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeRemoteResponse(w, http.StatusMethodNotAllowed, remoteCheckResponse{Error: "method not allowed"})
+			return
+		}
+		res, status, err := serveRemoteCheck(r, engine)
+		if err != nil {
+			writeRemoteResponse(w, status, remoteCheckResponse{Error: err.Error()})
+			return
+		}
+		writeRemoteResponse(w, http.StatusOK, remoteCheckResponse{Result: res})
+	})
+}
+
+func serveRemoteCheck(r *http.Request, engine *Engine) (CheckResult, int, error) {
+	var req remoteCheckRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return Unknown, http.StatusBadRequest, err
+	}
+	model, ok := LookupModel(req.Model)
+	if !ok {
+		return Unknown, http.StatusBadRequest, fmt.Errorf("unknown model %q", req.Model)
+	}
+	var codec Codec = &SchemaCodec{}
+	if req.Codec != "" {
+		if codec, ok = LookupCodec(req.Codec); !ok {
+			return Unknown, http.StatusBadRequest, fmt.Errorf("unknown codec %q", req.Codec)
+		}
+	}
+	ops := make([]Operation, len(req.Operations))
+	for i, op := range req.Operations {
+		var err error
+		ops[i], err = decodeOperation(codec, op, fmt.Sprintf("operations[%d]", i))
+		if err != nil {
+			return Unknown, http.StatusBadRequest, err
+		}
+	}
+	res, _, err := engine.Check(r.Context(), model, ops)
+	if err != nil {
+		return res, http.StatusServiceUnavailable, err
+	}
+	return res, http.StatusOK, nil
+}
+
+func writeRemoteResponse(w http.ResponseWriter, status int, resp remoteCheckResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// ErrNoWorkers is returned by [Coordinator.Check] when no worker could check
+// a partition.
+var ErrNoWorkers = errors.New("porcupine: no workers available")
+
+// A Coordinator checks histories by farming out their partitions to remote
+// workers, each serving [NewWorkerHandler], for histories that are too large
+// to check on one machine in the time available. A partition is the unit of
+// work, so the model should have a partition function that splits histories
+// into many partitions.
+type Coordinator struct {
+	// URLs that the workers serve their handlers at, e.g.,
+	// "http://checker-1:8080/check".
+	Workers []string
+	// Name of the model, as registered with RegisterModel, on the
+	// coordinator and on every worker.
+	Model string
+	// Optional: name of the codec for inputs and outputs, as registered
+	// with RegisterCodec. Defaults to encoding/json on the coordinator,
+	// and dynamic values on the workers.
+	Codec string
+	// Optional: the HTTP client for requests to workers; defaults to
+	// http.DefaultClient.
+	Client *http.Client
+	// Number of partitions sent to each worker at once; defaults to 1.
+	Concurrency int
+}
+
+// Check checks whether a history is linearizable, by partitioning it with
+// the model's partition function and checking the partitions on the
+// workers. It stops as soon as a partition is not linearizable.
+//
+// A worker that cannot be reached, or that returns an error, is not sent
+// more partitions, and its partition is retried on another worker. If no
+// workers are left, Check returns Unknown and ErrNoWorkers. If ctx is done
+// before the check finishes, Check returns Unknown and the context's error.
+func (c *Coordinator) Check(ctx context.Context, history []Operation) (CheckResult, error) {
+	model, ok := LookupModel(c.Model)
+	if !ok {
+		return Unknown, fmt.Errorf("porcupine: unknown model %q", c.Model)
+	}
+	var codec Codec = &SchemaCodec{}
+	if c.Codec != "" {
+		if codec, ok = LookupCodec(c.Codec); !ok {
+			return Unknown, fmt.Errorf("porcupine: unknown codec %q", c.Codec)
+		}
+	}
+	model = fillDefault(model)
+	partitions := model.Partition(history)
+	bodies := make([][]byte, len(partitions))
+	for i, partition := range partitions {
+		req := remoteCheckRequest{Model: c.Model, Codec: c.Codec, Operations: make([]jsonOperation, len(partition))}
+		for j, op := range partition {
+			var err error
+			if req.Operations[j], err = encodeOperation(codec, op); err != nil {
+				return Unknown, fmt.Errorf("partition %d: operation %d: %v", i, j, err)
+			}
+		}
+		var err error
+		if bodies[i], err = json.Marshal(req); err != nil {
+			return Unknown, err
+		}
+	}
+	if len(bodies) == 0 {
+		return Ok, nil
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	// every partition is in the queue until it is checked, so a worker
+	// that fails can give its partition back
+	queue := make(chan int, len(bodies))
+	for i := range bodies {
+		queue <- i
+	}
+	results := make(chan CheckResult, len(bodies))
+	var live sync.WaitGroup
+	concurrency := c.Concurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	for _, url := range c.Workers {
+		for k := 0; k < concurrency; k++ {
+			live.Add(1)
+			go func(url string) {
+				defer live.Done()
+				for {
+					var i int
+					select {
+					case i = <-queue:
+					case <-ctx.Done():
+						return
+					}
+					res, err := c.post(ctx, url, bodies[i])
+					if err != nil {
+						queue <- i
+						return
+					}
+					results <- res
+				}
+			}(url)
+		}
+	}
+	// all workers have failed once every worker goroutine has returned
+	workersDone := make(chan struct{})
+	go func() {
+		live.Wait()
+		close(workersDone)
+	}()
+
+	result := Ok
+	for count := 0; count < len(bodies); count++ {
+		select {
+		case res := <-results:
+			switch res {
+			case Illegal:
+				return Illegal, nil
+			case Unknown:
+				result = Unknown
+			}
+		case <-workersDone:
+			// a worker may have sent its result just before returning
+			select {
+			case res := <-results:
+				if res == Illegal {
+					return Illegal, nil
+				}
+				if res == Unknown {
+					result = Unknown
+				}
+				continue
+			default:
+			}
+			if ctx.Err() != nil {
+				return Unknown, ctx.Err()
+			}
+			return Unknown, ErrNoWorkers
+		case <-ctx.Done():
+			return Unknown, ctx.Err()
+		}
+	}
+	return result, nil
+}
+
+// post sends a partition to a worker and returns its result.
+func (c *Coordinator) post(ctx context.Context, url string, body []byte) (CheckResult, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return Unknown, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return Unknown, err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return Unknown, err
+	}
+	var r remoteCheckResponse
+	if err := json.Unmarshal(data, &r); err != nil {
+		return Unknown, fmt.Errorf("worker %s: %v", url, err)
+	}
+	if resp.StatusCode != http.StatusOK || r.Error != "" {
+		return Unknown, fmt.Errorf("worker %s: %s", url, strings.TrimSpace(r.Error))
+	}
+	return r.Result, nil
+}
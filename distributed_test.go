@@ -0,0 +1,84 @@
+package porcupine
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func init() {
+	RegisterModel("kv-test", kvModel)
+	RegisterCodec("kv-test", &FuncCodec{
+		MarshalInput: func(input interface{}) ([]byte, error) {
+			inp := input.(kvInput)
+			return json.Marshal([]interface{}{inp.op, inp.key, inp.value})
+		},
+		UnmarshalInput: func(data []byte) (interface{}, error) {
+			var inp kvInput
+			err := json.Unmarshal(data, &[]interface{}{&inp.op, &inp.key, &inp.value})
+			return inp, err
+		},
+		MarshalOutput: func(output interface{}) ([]byte, error) {
+			return json.Marshal(output.(kvOutput).value)
+		},
+		UnmarshalOutput: func(data []byte) (interface{}, error) {
+			var out kvOutput
+			err := json.Unmarshal(data, &out.value)
+			return out, err
+		},
+	})
+}
+
+func TestCoordinator(t *testing.T) {
+	engine := New(EngineOptions{})
+	defer engine.Close()
+	var workers []string
+	for i := 0; i < 2; i++ {
+		server := httptest.NewServer(NewWorkerHandler(engine))
+		defer server.Close()
+		workers = append(workers, server.URL)
+	}
+	coordinator := &Coordinator{Workers: workers, Model: "kv-test", Codec: "kv-test", Concurrency: 2}
+	for _, tc := range []struct {
+		file     string
+		expected CheckResult
+	}{
+		{"test_data/kv/c10-ok.txt", Ok},
+		{"test_data/kv/c10-bad.txt", Illegal},
+	} {
+		res, err := coordinator.Check(context.Background(), kvOperations(parseKvLog(tc.file)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res != tc.expected {
+			t.Fatalf("%s: expected output %v, got output %v", tc.file, tc.expected, res)
+		}
+	}
+}
+
+func TestCoordinatorFailover(t *testing.T) {
+	engine := New(EngineOptions{})
+	defer engine.Close()
+	good := httptest.NewServer(NewWorkerHandler(engine))
+	defer good.Close()
+	bad := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer bad.Close()
+
+	ops := kvOperations(parseKvLog("test_data/kv/c10-ok.txt"))
+	coordinator := &Coordinator{Workers: []string{bad.URL, good.URL}, Model: "kv-test", Codec: "kv-test"}
+	if res, err := coordinator.Check(context.Background(), ops); err != nil || res != Ok {
+		t.Fatalf("expected output %v, got output %v (%v)", Ok, res, err)
+	}
+	coordinator.Workers = []string{bad.URL}
+	if res, err := coordinator.Check(context.Background(), ops); err != ErrNoWorkers || res != Unknown {
+		t.Fatalf("expected output %v with %v, got output %v (%v)", Unknown, ErrNoWorkers, res, err)
+	}
+	coordinator.Model = "nonexistent"
+	if _, err := coordinator.Check(context.Background(), ops); err == nil {
+		t.Fatal("expected an error for an unknown model")
+	}
+}
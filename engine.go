@@ -0,0 +1,142 @@
+package porcupine
+
+import (
+	"context"
+	"errors"
+	"log"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// ErrEngineClosed is returned by the methods of an [Engine] after it has been
+// closed.
+var ErrEngineClosed = errors.New("porcupine: engine is closed")
+
+// EngineOptions configures an [Engine]. The zero value is a usable
+// configuration.
+type EngineOptions struct {
+	// Maximum number of partitions checked at once, across all checks
+	// running on the engine. Defaults to runtime.GOMAXPROCS(0).
+	Workers int
+	// Whether checks compute a LinearizationInfo, as CheckOperationsVerbose
+	// does.
+	Verbose bool
+	// Optional: log a line for each check.
+	Logger *log.Logger
+	// Optional: options applied to every check, before those passed to the
+	// check, e.g., WithStateCache for the caches of the checks' searches,
+	// or WithMetrics for a sink that they share. The engine's workers and
+	// the check's context take the place of WithParallelism and
+	// WithContext, and checks running at once can't share a
+	// WithDecisionTrace writer, so it is ignored here.
+	CheckOptions []CheckOption
+}
+
+// An Engine runs linearizability checks with shared resources, for programs
+// that embed the checker and run many checks, such as a service that checks
+// histories submitted to it.
+//
+// Checks on an engine share a pool of workers, so that running many checks at
+// once doesn't oversubscribe the machine, and the engine's CheckOptions, such
+// as a state cache or a metrics sink, and they can be cancelled through a
+// context. An Engine is safe for concurrent use. Create one with [New], and
+// release it with [Engine.Close].
+type Engine struct {
+	opts    EngineOptions
+	workers chan struct{}
+	done    chan struct{} // closed by Close
+
+	mu       sync.Mutex // protects closed and adding to inFlight
+	closed   bool
+	inFlight sync.WaitGroup
+}
+
+// New creates an [Engine] with the given options.
+func New(opts EngineOptions) *Engine {
+	if opts.Workers <= 0 {
+		opts.Workers = runtime.GOMAXPROCS(0)
+	}
+	return &Engine{
+		opts:    opts,
+		workers: make(chan struct{}, opts.Workers),
+		done:    make(chan struct{}),
+	}
+}
+
+// Check checks whether a history of operations is linearizable, with the
+// engine's CheckOptions followed by opts.
+//
+// If ctx is done before the check finishes, Check stops the check and returns
+// Unknown along with the context's error. The LinearizationInfo is only
+// computed if the engine's options set Verbose, or opts include WithVerbose.
+func (e *Engine) Check(ctx context.Context, model Model, history []Operation, opts ...CheckOption) (CheckResult, LinearizationInfo, error) {
+	return e.run(ctx, len(history), opts, func(opts checkOptions) (CheckResult, LinearizationInfo) {
+		return checkOperations(model, history, nil, opts)
+	})
+}
+
+// CheckEvents checks whether a history of events is linearizable; see
+// [Engine.Check].
+func (e *Engine) CheckEvents(ctx context.Context, model Model, history []Event, opts ...CheckOption) (CheckResult, LinearizationInfo, error) {
+	return e.run(ctx, len(history)/2, opts, func(opts checkOptions) (CheckResult, LinearizationInfo) {
+		return checkEvents(model, history, nil, opts)
+	})
+}
+
+func (e *Engine) run(ctx context.Context, n int, opts []CheckOption, check func(checkOptions) (CheckResult, LinearizationInfo)) (CheckResult, LinearizationInfo, error) {
+	e.mu.Lock()
+	if e.closed {
+		e.mu.Unlock()
+		return Unknown, LinearizationInfo{}, ErrEngineClosed
+	}
+	e.inFlight.Add(1)
+	e.mu.Unlock()
+	defer e.inFlight.Done()
+
+	// stop the check if the engine is closed
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-e.done:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	start := time.Now()
+	o := checkOptions{verbose: e.opts.Verbose}.apply(e.opts.CheckOptions)
+	o.decisionTrace = nil
+	o = o.apply(opts)
+	o.ctx, o.workers = ctx, e.workers
+	res, info := check(o)
+	if e.opts.Logger != nil {
+		e.opts.Logger.Printf("porcupine: checked %d operations in %v: %v", n, time.Since(start), res)
+	}
+	if res == Unknown && ctx.Err() != nil {
+		select {
+		case <-e.done:
+			return res, info, ErrEngineClosed
+		default:
+			return res, info, ctx.Err()
+		}
+	}
+	return res, info, nil
+}
+
+// Close stops any checks that are running on the engine, which return
+// Unknown and ErrEngineClosed, and waits for them to return. Checks started
+// after Close return ErrEngineClosed immediately. Close always returns nil.
+func (e *Engine) Close() error {
+	e.mu.Lock()
+	if e.closed {
+		e.mu.Unlock()
+		return nil
+	}
+	e.closed = true
+	close(e.done)
+	e.mu.Unlock()
+	e.inFlight.Wait()
+	return nil
+}
@@ -0,0 +1,130 @@
+package porcupine
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestEngineCheck(t *testing.T) {
+	var logs bytes.Buffer
+	engine := New(EngineOptions{Workers: 2, Verbose: true, Logger: log.New(&logs, "", 0)})
+	defer engine.Close()
+
+	res, info, err := engine.CheckEvents(context.Background(), kvModel, parseKvLog("test_data/kv/c10-ok.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res != Ok {
+		t.Fatalf("expected output %v, got output %v", Ok, res)
+	}
+	if len(info.PartialLinearizations()) == 0 {
+		t.Fatal("expected a verbose engine to compute linearization info")
+	}
+	res, _, err = engine.CheckEvents(context.Background(), kvModel, parseKvLog("test_data/kv/c10-bad.txt"))
+	if err != nil || res != Illegal {
+		t.Fatalf("expected output %v, got output %v (%v)", Illegal, res, err)
+	}
+	if !strings.Contains(logs.String(), "checked") {
+		t.Fatalf("expected the engine to log checks, got %q", logs.String())
+	}
+}
+
+func TestEngineConcurrentChecks(t *testing.T) {
+	engine := New(EngineOptions{Workers: 1})
+	defer engine.Close()
+	ops := []Operation{
+		{0, registerInput{false, 1}, 0, 0, 10},
+		{1, registerInput{true, 0}, 20, 1, 30},
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res, _, err := engine.Check(context.Background(), registerModel, ops)
+			if err != nil || res != Ok {
+				t.Errorf("expected output %v, got output %v (%v)", Ok, res, err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestEngineCancel(t *testing.T) {
+	engine := New(EngineOptions{})
+	defer engine.Close()
+	events := parseKvLog("test_data/kv/c10-ok.txt")
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	res, _, err := engine.CheckEvents(ctx, kvNoPartitionModel, events)
+	if res != Unknown || err != context.DeadlineExceeded {
+		t.Fatalf("expected output %v with a deadline error, got output %v (%v)", Unknown, res, err)
+	}
+}
+
+func TestEngineClose(t *testing.T) {
+	engine := New(EngineOptions{})
+	events := parseKvLog("test_data/kv/c10-ok.txt")
+	done := make(chan error)
+	go func() {
+		_, _, err := engine.CheckEvents(context.Background(), kvNoPartitionModel, events)
+		done <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	engine.Close()
+	if err := <-done; err != ErrEngineClosed {
+		t.Fatalf("expected a running check to be stopped by Close, got %v", err)
+	}
+	if _, _, err := engine.Check(context.Background(), registerModel, nil); err != ErrEngineClosed {
+		t.Fatalf("expected %v after Close, got %v", ErrEngineClosed, err)
+	}
+}
+
+func TestEngineCheckOptions(t *testing.T) {
+	var mu sync.Mutex
+	var caches, reports int
+	var engineTrace bytes.Buffer
+	engine := New(EngineOptions{CheckOptions: []CheckOption{
+		WithStateCache(func() StateCache {
+			mu.Lock()
+			defer mu.Unlock()
+			caches++
+			return NewLRUStateCache(1 << 10)
+		}),
+		WithMetrics(MetricsFunc(func(m Metrics) {
+			mu.Lock()
+			defer mu.Unlock()
+			reports++
+		})),
+		WithDecisionTrace(&engineTrace),
+	}})
+	defer engine.Close()
+	events := parseKvLog("test_data/kv/c10-ok.txt")
+	res, info, err := engine.CheckEvents(context.Background(), kvModel, events, WithVerbose())
+	if err != nil || res != Ok {
+		t.Fatalf("expected output %v, got output %v (%v)", Ok, res, err)
+	}
+	if len(info.PartialLinearizations()) == 0 {
+		t.Fatal("expected WithVerbose to apply to the check")
+	}
+	var trace bytes.Buffer
+	if res, _, err := engine.Check(context.Background(), kvModel, kvOperations(events), WithDecisionTrace(&trace)); err != nil || res != Ok {
+		t.Fatalf("expected output %v, got output %v (%v)", Ok, res, err)
+	}
+	if decisions, err := ReadDecisionTrace(&trace); err != nil || len(decisions) == 0 {
+		t.Fatalf("expected a decision trace for the check, got %d decisions (%v)", len(decisions), err)
+	}
+	if engineTrace.Len() != 0 {
+		t.Fatalf("expected the engine's decision trace to be ignored, got %d bytes", engineTrace.Len())
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if caches == 0 || reports != 2 {
+		t.Fatalf("expected the engine's options to apply to every check, got %d caches and %d metrics reports", caches, reports)
+	}
+}
@@ -0,0 +1,147 @@
+package porcupine
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// An EtcdReport holds the histories recorded by a run of etcd's robustness
+// tests (tests/robustness in the etcd repository), as read by
+// [ReadEtcdReport].
+type EtcdReport struct {
+	// Key-value operations from all clients, in the order they were read.
+	Operations []Operation
+	// Watch operations, by client ID, as dynamic values (see [Schema]).
+	// These are not operations on the key-value model, but they are useful
+	// for annotating visualizations.
+	Watches map[int][]interface{}
+}
+
+// ReadEtcdReport reads the client histories from the report directory of a
+// run of etcd's robustness tests. The directory has a subdirectory
+// client-<id> for each client, holding
+//
+//   - operations.json: the client's key-value operations, as a stream of
+//     JSON-encoded Operation values, i.e., objects with the fields
+//     "ClientId", "Input", "Call", "Output", and "Return"
+//   - watch.json: the client's watch operations, as a stream of JSON values
+//
+// Either file may be missing. Operation inputs (etcd requests) and outputs
+// (etcd responses) are decoded with the given codec; if codec is nil, they are
+// decoded as dynamic values, for checking with a model written over those
+// values. Other files in the report directory are ignored.
+func ReadEtcdReport(dir string, codec Codec) (EtcdReport, error) {
+	report := EtcdReport{Watches: make(map[int][]interface{})}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return report, err
+	}
+	type clientDir struct {
+		id   int
+		path string
+	}
+	var clients []clientDir
+	for _, e := range entries {
+		if !e.IsDir() || !strings.HasPrefix(e.Name(), "client-") {
+			continue
+		}
+		id, err := strconv.Atoi(strings.TrimPrefix(e.Name(), "client-"))
+		if err != nil {
+			continue
+		}
+		clients = append(clients, clientDir{id, filepath.Join(dir, e.Name())})
+	}
+	sort.Slice(clients, func(i, j int) bool {
+		return clients[i].id < clients[j].id
+	})
+	for _, c := range clients {
+		ops, err := readEtcdFile(filepath.Join(c.path, "operations.json"), func(r io.Reader) (interface{}, error) {
+			return ReadEtcdOperations(r, codec)
+		})
+		if err != nil {
+			return report, err
+		}
+		if ops != nil {
+			report.Operations = append(report.Operations, ops.([]Operation)...)
+		}
+		watches, err := readEtcdFile(filepath.Join(c.path, "watch.json"), readJSONStream)
+		if err != nil {
+			return report, err
+		}
+		if watches != nil {
+			report.Watches[c.id] = watches.([]interface{})
+		}
+	}
+	return report, nil
+}
+
+// readEtcdFile reads a file with the given function, returning nil if the
+// file does not exist.
+func readEtcdFile(path string, read func(io.Reader) (interface{}, error)) (interface{}, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	v, err := read(decompressed(f))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return v, nil
+}
+
+type etcdOperation struct {
+	ClientId int
+	Input    json.RawMessage
+	Call     int64
+	Output   json.RawMessage
+	Return   int64
+}
+
+// ReadEtcdOperations reads one client's operations.json file from a report of
+// etcd's robustness tests; see [ReadEtcdReport].
+func ReadEtcdOperations(r io.Reader, codec Codec) ([]Operation, error) {
+	if codec == nil {
+		codec = &SchemaCodec{}
+	}
+	dec := json.NewDecoder(decompressed(r))
+	var ops []Operation
+	for {
+		var eop etcdOperation
+		if err := dec.Decode(&eop); err == io.EOF {
+			return ops, nil
+		} else if err != nil {
+			return nil, err
+		}
+		jop := jsonOperation{Client: eop.ClientId, Input: eop.Input, Call: eop.Call, Output: eop.Output, Return: eop.Return}
+		op, err := decodeOperation(codec, jop, fmt.Sprintf("operation %d", len(ops)))
+		if err != nil {
+			return nil, err
+		}
+		ops = append(ops, op)
+	}
+}
+
+func readJSONStream(r io.Reader) (interface{}, error) {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	values := []interface{}{}
+	for {
+		var raw interface{}
+		if err := dec.Decode(&raw); err == io.EOF {
+			return values, nil
+		} else if err != nil {
+			return nil, err
+		}
+		values = append(values, normalizeJSON(raw))
+	}
+}
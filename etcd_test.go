@@ -0,0 +1,82 @@
+package porcupine
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeEtcdReport(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestReadEtcdReport(t *testing.T) {
+	dir := writeEtcdReport(t, map[string]string{
+		"client-1/operations.json": `{"ClientId":1,"Input":{"op":"put","key":"x","value":"a"},"Call":0,"Output":null,"Return":10}
+{"ClientId":1,"Input":{"op":"get","key":"x"},"Call":20,"Output":{"value":"a","version":3},"Return":30}
+`,
+		"client-1/watch.json": `{"Request":{"Key":"x","Revision":1},"Responses":[{"Revision":2}]}
+`,
+		"client-2/operations.json": `{"ClientId":2,"Input":{"op":"get","key":"x"},"Call":5,"Output":{"value":""},"Return":8}
+`,
+		"client-3/watch.json": "",
+		"server-0/log.txt":    "ignored",
+	})
+	report, err := ReadEtcdReport(dir, kvSchemaCodec(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Operations) != 3 {
+		t.Fatalf("expected 3 operations, got %d", len(report.Operations))
+	}
+	if op := report.Operations[2]; op.ClientId != 2 || op.Call != 5 || op.Return != 8 {
+		t.Fatalf("unexpected operation %v", op)
+	}
+	if v := report.Operations[1].Output.(map[string]interface{})["version"]; v != int64(3) {
+		t.Fatalf("expected version 3, got %#v", v)
+	}
+	if len(report.Watches[1]) != 1 || len(report.Watches[3]) != 0 {
+		t.Fatalf("unexpected watches %v", report.Watches)
+	}
+	if _, ok := report.Watches[2]; ok {
+		t.Fatal("expected no watches for a client without watch.json")
+	}
+	request := report.Watches[1][0].(map[string]interface{})["Request"].(map[string]interface{})
+	if request["Revision"] != int64(1) {
+		t.Fatalf("unexpected watch request %v", request)
+	}
+	if res := CheckOperations(dynamicKvModel, report.Operations); res != true {
+		t.Fatal("expected operations to be linearizable")
+	}
+}
+
+func TestReadEtcdReportError(t *testing.T) {
+	dir := writeEtcdReport(t, map[string]string{
+		"client-0/operations.json": `{"ClientId":0,"Input":{"op":"cas","key":"x"},"Call":0,"Output":null,"Return":1}`,
+	})
+	_, err := ReadEtcdReport(dir, kvSchemaCodec(t))
+	if err == nil || !strings.Contains(err.Error(), "operations.json: operation 0.input.op") {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestReadEtcdOperationsDynamic(t *testing.T) {
+	ops, err := ReadEtcdOperations(strings.NewReader(`{"ClientId":4,"Input":{"Type":"range","Range":{"Start":"x"}},"Call":1,"Output":{"Error":"timeout"},"Return":2}`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ops) != 1 || ops[0].ClientId != 4 || ops[0].Input.(map[string]interface{})["Type"] != "range" {
+		t.Fatalf("unexpected operations %v", ops)
+	}
+}
@@ -0,0 +1,180 @@
+package porcupine
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// An EventLog writes a history of events as JSON Lines as the calls and
+// returns happen, one event per line, in the same form as the elements of
+// "events" in [ReadJSONEvents]:
+//
+//	{"client": 0, "kind": "call", "value": ..., "id": 0}
+//	{"client": 0, "kind": "return", "value": ..., "id": 0}
+//
+// Each line is written to the underlying writer with a single Write, so if
+// the test harness crashes, the log holds every event up to the crash,
+// possibly followed by one partial line; [ReadJSONLEvents] reassembles such
+// a log into a history. Wrap the writer in a buffer for speed at the cost of
+// this guarantee.
+//
+// An EventLog is safe for concurrent use.
+type EventLog struct {
+	mu      sync.Mutex
+	w       io.Writer
+	codec   Codec
+	pending map[int]int // call ID -> client ID
+	nextId  int
+	err     error
+}
+
+// NewEventLog returns an [EventLog] that writes to w, encoding inputs and
+// outputs with the given codec. If codec is nil, they are encoded as JSON.
+func NewEventLog(w io.Writer, codec Codec) *EventLog {
+	if codec == nil {
+		codec = &SchemaCodec{}
+	}
+	return &EventLog{w: w, codec: codec, pending: make(map[int]int)}
+}
+
+// Call logs a call event with the given client ID and input, and returns the
+// ID to pass to [EventLog.Return] when the call returns. The error is the
+// first error encountered while writing the log, if any; once an error
+// occurs, no more events are written, but IDs are still handed out.
+func (l *EventLog) Call(clientId int, input interface{}) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	id := l.nextId
+	l.nextId++
+	l.pending[id] = clientId
+	return id, l.write(l.codec.EncodeInput, jsonEvent{Client: clientId, Kind: "call", Id: id}, input)
+}
+
+// Return logs the return event for the call with the given ID, with the given
+// output. The error is as for Call.
+//
+// Return panics if the ID was not returned by Call, or if the call has
+// already returned.
+func (l *EventLog) Return(id int, output interface{}) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	clientId, ok := l.pending[id]
+	if !ok {
+		if id >= 0 && id < l.nextId {
+			panic(fmt.Sprintf("porcupine: Return called twice for call ID %d", id))
+		}
+		panic(fmt.Sprintf("porcupine: Return called with unknown call ID %d", id))
+	}
+	delete(l.pending, id)
+	return l.write(l.codec.EncodeOutput, jsonEvent{Client: clientId, Kind: "return", Id: id}, output)
+}
+
+// Err returns the first error encountered while writing the log, if any.
+func (l *EventLog) Err() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.err
+}
+
+// write encodes an event and writes it as one line. The caller must hold
+// l.mu.
+func (l *EventLog) write(encode func(interface{}) ([]byte, error), e jsonEvent, value interface{}) error {
+	if l.err != nil {
+		return l.err
+	}
+	if e.Value, l.err = encode(value); l.err != nil {
+		return l.err
+	}
+	line, err := json.Marshal(e)
+	if err != nil {
+		l.err = err
+		return err
+	}
+	_, l.err = l.w.Write(append(line, '\n'))
+	return l.err
+}
+
+// ReadJSONLEvents reads a history of events from JSON Lines, such as a log
+// written by an [EventLog], decoding call values as inputs and return values
+// as outputs with the given codec. If codec is nil, they are decoded as
+// dynamic values.
+//
+// The log may end with a partial line, as left by a crash while it was being
+// written, which is ignored. Calls that never returned are removed from the
+// history, so that it is well-formed, and returned separately in order: the
+// operations may or may not have taken effect, so to check the history
+// soundly, append a return event for each of them with an output that the
+// model accepts for any outcome.
+func ReadJSONLEvents(r io.Reader, codec Codec) ([]Event, []Event, error) {
+	if codec == nil {
+		codec = &SchemaCodec{}
+	}
+	br := bufio.NewReader(decompressed(r))
+	var events []Event
+	calls := make(map[int]bool) // pending calls
+	returned := make(map[int]bool)
+	for line := 1; ; line++ {
+		data, readErr := br.ReadBytes('\n')
+		if readErr == io.EOF {
+			if len(bytes.TrimSpace(data)) == 0 || !json.Valid(data) {
+				// nothing, or a partial line
+				break
+			}
+		} else if readErr != nil {
+			return nil, nil, readErr
+		}
+		if len(bytes.TrimSpace(data)) == 0 {
+			continue
+		}
+		path := fmt.Sprintf("line %d", line)
+		var e jsonEvent
+		if err := json.Unmarshal(data, &e); err != nil {
+			return nil, nil, fmt.Errorf("%s: %v", path, err)
+		}
+		event := Event{ClientId: e.Client, Id: e.Id}
+		var err error
+		switch e.Kind {
+		case "call":
+			if _, ok := calls[e.Id]; ok || returned[e.Id] {
+				return nil, nil, fmt.Errorf("%s: duplicate call for ID %d", path, e.Id)
+			}
+			event.Kind = CallEvent
+			event.Value, err = decodeWith(codec.DecodeInput, e.Value, path+".value")
+			calls[e.Id] = true
+		case "return":
+			if _, ok := calls[e.Id]; !ok {
+				return nil, nil, fmt.Errorf("%s: return for ID %d without a pending call", path, e.Id)
+			}
+			delete(calls, e.Id)
+			returned[e.Id] = true
+			event.Kind = ReturnEvent
+			event.Value, err = decodeWith(codec.DecodeOutput, e.Value, path+".value")
+		default:
+			return nil, nil, fmt.Errorf("%s: invalid kind %q", path, e.Kind)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		events = append(events, event)
+		if readErr == io.EOF {
+			break
+		}
+	}
+	if len(calls) == 0 {
+		return events, nil, nil
+	}
+	var pending []Event
+	complete := events[:0]
+	for _, e := range events {
+		if calls[e.Id] {
+			pending = append(pending, e)
+		} else {
+			complete = append(complete, e)
+		}
+	}
+	return complete, pending, nil
+}
@@ -0,0 +1,99 @@
+package porcupine
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestEventLog(t *testing.T) {
+	var buf bytes.Buffer
+	log := NewEventLog(&buf, kvCodec)
+	kv := &lockedKv{data: make(map[string]string)}
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				inp := kvInput{op: uint8(j % 3), key: "x", value: "v"}
+				id, err := log.Call(i, inp)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if err := log.Return(id, kv.apply(inp)); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	events, pending, err := ReadJSONLEvents(&buf, kvCodec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2*4*10 || len(pending) != 0 {
+		t.Fatalf("expected %d events and no pending calls, got %d and %d", 2*4*10, len(events), len(pending))
+	}
+	if err := ValidateEvents(events); err != nil {
+		t.Fatal(err)
+	}
+	if !CheckEvents(kvModel, events) {
+		t.Fatal("expected events to be linearizable")
+	}
+}
+
+func TestReadJSONLEventsCrash(t *testing.T) {
+	var buf bytes.Buffer
+	log := NewEventLog(&buf, nil)
+	first, _ := log.Call(0, 1)
+	second, _ := log.Call(1, 2)
+	log.Return(first, true)
+	log.Call(2, 3)
+	// a crash while writing the last line
+	full := buf.String()
+	truncated := full[:len(full)-10]
+
+	events, pending, err := ReadJSONLEvents(strings.NewReader(truncated), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || events[0].Id != first || events[1].Kind != ReturnEvent || events[1].Value != true {
+		t.Fatalf("unexpected events %v", events)
+	}
+	if len(pending) != 1 || pending[0].Id != second || pending[0].Value != int64(2) {
+		t.Fatalf("unexpected pending calls %v", pending)
+	}
+
+	// a final line without a newline is kept if it is complete
+	events, pending, err = ReadJSONLEvents(strings.NewReader(strings.TrimSuffix(full, "\n")), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || len(pending) != 2 {
+		t.Fatalf("expected 2 events and 2 pending calls, got %d and %d", len(events), len(pending))
+	}
+}
+
+func TestReadJSONLEventsError(t *testing.T) {
+	_, _, err := ReadJSONLEvents(strings.NewReader(`{"client":0,"kind":"call","value":1,"id":0}
+{"client":0,"kind":"return","value":1,"id":1}
+`), nil)
+	if err == nil || err.Error() != "line 2: return for ID 1 without a pending call" {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestEventLogError(t *testing.T) {
+	log := NewEventLog(failingWriter{}, nil)
+	id, err := log.Call(0, 1)
+	if err == nil || err.Error() != "disk full" {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err := log.Return(id, 1); err == nil || log.Err() != err {
+		t.Fatalf("expected the error to stick, got %v", err)
+	}
+}
@@ -0,0 +1,111 @@
+package porcupine
+
+import (
+	"fmt"
+	"sync"
+)
+
+// An EventBuilder builds a history of [Event] from calls and returns
+// reported by concurrent goroutines, handing out event IDs so that callers
+// don't have to coordinate them.
+//
+//	b := porcupine.NewEventBuilder()
+//	id := b.Call(clientId, input)
+//	output := doOperation(input)
+//	b.Return(id, output)
+//	...
+//	ok := porcupine.CheckEvents(model, b.Events())
+//
+// An EventBuilder is safe for concurrent use.
+type EventBuilder struct {
+	mu      sync.Mutex
+	events  []Event
+	pending map[int]int // call ID -> index of the call event in events
+	nextId  int
+}
+
+// NewEventBuilder creates an empty [EventBuilder].
+func NewEventBuilder() *EventBuilder {
+	return &EventBuilder{pending: make(map[int]int)}
+}
+
+// Call appends a call event with the given client ID and input, and returns
+// the ID to pass to [EventBuilder.Return] when the call returns.
+func (b *EventBuilder) Call(clientId int, input interface{}) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	id := b.nextId
+	b.nextId++
+	b.pending[id] = len(b.events)
+	b.events = append(b.events, Event{ClientId: clientId, Kind: CallEvent, Value: input, Id: id})
+	return id
+}
+
+// Return appends the return event for the call with the given ID, with the
+// given output.
+//
+// Return panics if the ID was not returned by Call, or if the call has
+// already returned.
+func (b *EventBuilder) Return(id int, output interface{}) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	i, ok := b.pending[id]
+	if !ok {
+		if id >= 0 && id < b.nextId {
+			panic(fmt.Sprintf("porcupine: Return called twice for call ID %d", id))
+		}
+		panic(fmt.Sprintf("porcupine: Return called with unknown call ID %d", id))
+	}
+	delete(b.pending, id)
+	b.events = append(b.events, Event{ClientId: b.events[i].ClientId, Kind: ReturnEvent, Value: output, Id: id})
+}
+
+// Events returns the history built so far. Calls that have not returned yet
+// are left out, so the history is always well-formed.
+func (b *EventBuilder) Events() []Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	events := make([]Event, 0, len(b.events))
+	for _, e := range b.events {
+		if _, pending := b.pending[e.Id]; !pending {
+			events = append(events, e)
+		}
+	}
+	return events
+}
+
+// ValidateEvents checks that a history of events is well-formed: every ID
+// has exactly one call event followed by exactly one return event, from the
+// same client. The checker assumes this, and gives meaningless results for
+// histories that violate it.
+func ValidateEvents(history []Event) error {
+	calls := make(map[int]Event)
+	returned := make(map[int]bool)
+	for i, e := range history {
+		call, called := calls[e.Id]
+		switch e.Kind {
+		case CallEvent:
+			if called {
+				return fmt.Errorf("event %d: duplicate call for ID %d", i, e.Id)
+			}
+			calls[e.Id] = e
+		case ReturnEvent:
+			if !called {
+				return fmt.Errorf("event %d: return for ID %d without a preceding call", i, e.Id)
+			}
+			if returned[e.Id] {
+				return fmt.Errorf("event %d: duplicate return for ID %d", i, e.Id)
+			}
+			if e.ClientId != call.ClientId {
+				return fmt.Errorf("event %d: return for ID %d from client %d, but the call was from client %d", i, e.Id, e.ClientId, call.ClientId)
+			}
+			returned[e.Id] = true
+		}
+	}
+	for id := range calls {
+		if !returned[id] {
+			return fmt.Errorf("call for ID %d has no return", id)
+		}
+	}
+	return nil
+}
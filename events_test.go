@@ -0,0 +1,92 @@
+package porcupine
+
+import (
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestEventBuilder(t *testing.T) {
+	b := NewEventBuilder()
+	kv := &lockedKv{data: make(map[string]string)}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				inp := kvInput{op: uint8(j % 3), key: "x", value: "v"}
+				id := b.Call(i, inp)
+				b.Return(id, kv.apply(inp))
+			}
+		}(i)
+	}
+	wg.Wait()
+	events := b.Events()
+	if len(events) != 2*8*20 {
+		t.Fatalf("expected %d events, got %d", 2*8*20, len(events))
+	}
+	if err := ValidateEvents(events); err != nil {
+		t.Fatal(err)
+	}
+	if !CheckEvents(kvModel, events) {
+		t.Fatal("expected events to be linearizable")
+	}
+}
+
+func TestEventBuilderPending(t *testing.T) {
+	b := NewEventBuilder()
+	first := b.Call(0, registerInput{false, 1})
+	second := b.Call(1, registerInput{true, 0})
+	b.Return(second, 0)
+	events := b.Events()
+	if len(events) != 2 || events[0].Id != second {
+		t.Fatalf("expected only the completed call, got %v", events)
+	}
+	b.Return(first, 0)
+	if err := ValidateEvents(b.Events()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEventBuilderMisuse(t *testing.T) {
+	b := NewEventBuilder()
+	id := b.Call(0, nil)
+	b.Return(id, nil)
+	for _, tc := range []struct {
+		id      int
+		message string
+	}{{id, "twice"}, {id + 1, "unknown"}, {-1, "unknown"}} {
+		func() {
+			defer func() {
+				r := recover()
+				if r == nil || !strings.Contains(r.(string), tc.message) {
+					t.Fatalf("expected a panic about %q, got %v", tc.message, r)
+				}
+			}()
+			b.Return(tc.id, nil)
+		}()
+	}
+}
+
+func TestValidateEvents(t *testing.T) {
+	for _, tc := range []struct {
+		events []Event
+		err    string
+	}{
+		{[]Event{{0, CallEvent, nil, 0}, {0, ReturnEvent, nil, 0}}, ""},
+		{[]Event{{0, CallEvent, nil, 0}, {0, CallEvent, nil, 0}}, "duplicate call"},
+		{[]Event{{0, ReturnEvent, nil, 0}}, "without a preceding call"},
+		{[]Event{{0, CallEvent, nil, 0}, {0, ReturnEvent, nil, 0}, {0, ReturnEvent, nil, 0}}, "duplicate return"},
+		{[]Event{{0, CallEvent, nil, 0}, {1, ReturnEvent, nil, 0}}, "from client 1"},
+		{[]Event{{0, CallEvent, nil, 0}}, "has no return"},
+	} {
+		err := ValidateEvents(tc.events)
+		if tc.err == "" && err != nil {
+			t.Fatalf("expected %v to be valid, got %v", tc.events, err)
+		}
+		if tc.err != "" && (err == nil || !strings.Contains(err.Error(), tc.err)) {
+			t.Fatalf("expected an error about %q, got %v", tc.err, err)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package porcupine
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Explain describes why a history is not linearizable, in plain text, so that
+// a failed check can be understood without opening its visualization, e.g.,
+// in a test's failure message.
+//
+// For each partition that is not linearizable, it finds the smallest window
+// of time whose operations can't be linearized, however the operations before
+// it are (see [FindFailingWindow]), and explains it using the model's
+// DescribeOperation and DescribeState: the states that the operations before
+// the window can leave the model in, the longest order of the window's
+// operations that the model accepts from there, and the operations that it
+// then rejects. For example:
+//
+//	get() -> '1' (client 1, at [45, 50]) can't be linearized: every
+//	linearization of the 3 operations before it leaves the model in state "2".
+//
+// Partitions whose check timed out are listed as such. The LinearizationInfo
+// must come from one of the verbose check functions, e.g.,
+// [CheckOperationsVerbose].
+func Explain(model Model, info LinearizationInfo) string {
+	model = fillDefault(model)
+	linearized, operations := info.LongestPrefixes()
+	var b strings.Builder
+	for p, history := range info.history {
+		if len(history) == 0 || p >= len(linearized) || linearized[p] == operations[p] {
+			continue
+		}
+		ops := entryOperations(history)
+		prefix := ""
+		if model.DescribePartition != nil {
+			prefix = model.DescribePartition(ops) + ": "
+		} else if len(info.history) > 1 {
+			prefix = fmt.Sprintf("Partition %d: ", p)
+		}
+		if p < len(info.timedOut) && info.timedOut[p] {
+			fmt.Fprintf(&b, "%sthe check timed out after linearizing %d of %d operations.\n", prefix, linearized[p], operations[p])
+			continue
+		}
+		f, res := failingWindow(model, history, failingWindowTimeout)
+		if res != Illegal {
+			fmt.Fprintf(&b, "%snot linearizable; the longest linearizable prefix has %d of %d operations.\n", prefix, linearized[p], operations[p])
+			continue
+		}
+		b.WriteString(prefix)
+		explainWindow(&b, model, ops, history, f)
+	}
+	if b.Len() == 0 {
+		return "The history is linearizable.\n"
+	}
+	return b.String()
+}
+
+// explainWindow describes why the failing window of a partition, whose
+// operations are ops, can't be linearized.
+func explainWindow(b *strings.Builder, model Model, ops []Operation, history []entry, f windowFailure) {
+	tags := make([]map[string]string, len(ops))
+	for _, e := range history {
+		if e.kind == returnEntry {
+			tags[e.id] = e.tags
+		}
+	}
+	describe := func(id int) string {
+		op := ops[id]
+		return fmt.Sprintf("%s (client %d, at [%d, %d])",
+			describeOperation(model, op.Input, op.Output, tags[id]), op.ClientId, op.Call, op.Return)
+	}
+	var descriptions []string
+	for _, s := range f.states {
+		descriptions = append(descriptions, fmt.Sprintf("%q", model.DescribeState(s)))
+	}
+	states := "state " + descriptions[0]
+	if len(descriptions) > 1 {
+		states = "one of the states " + joinList(descriptions)
+	}
+	pronoun := "it"
+	if len(f.ops) == 1 {
+		fmt.Fprintf(b, "%s can't be linearized: ", describe(f.ops[0]))
+	} else {
+		pronoun = "them"
+		fmt.Fprintf(b, "the %d operations at [%d, %d] can't be linearized: ", len(f.ops), f.start, f.end)
+	}
+	switch f.before {
+	case 0:
+		fmt.Fprintf(b, "the model starts in %s.\n", states)
+	case 1:
+		fmt.Fprintf(b, "the operation before %s leaves the model in %s.\n", pronoun, states)
+	default:
+		fmt.Fprintf(b, "every linearization of the %d operations before %s leaves the model in %s.\n", f.before, pronoun, states)
+	}
+	if len(f.ops) == 1 {
+		return
+	}
+
+	// replay the longest order of the window's operations from a state that
+	// accepts it, to find the operations that the model rejects after it
+	inWindow := make(map[int]bool)
+	for _, id := range f.ops {
+		inWindow[id] = true
+	}
+	var state interface{}
+	var steps []string
+	for _, s := range f.states {
+		state, steps = s, nil
+		ok := true
+		for _, id := range f.longest {
+			if ok, state = model.Step(state, ops[id].Input, ops[id].Output); !ok {
+				break
+			}
+			steps = append(steps, fmt.Sprintf("%s -> state %q", describe(id), model.DescribeState(state)))
+		}
+		if ok {
+			break
+		}
+	}
+	done := make([]bool, len(ops))
+	for id := range ops {
+		done[id] = !inWindow[id]
+	}
+	for _, id := range f.longest {
+		done[id] = true
+	}
+	var rejected []string
+	for _, id := range stuckOperations(model, ops, done, state) {
+		rejected = append(rejected, describe(id))
+	}
+	if len(steps) > 0 {
+		fmt.Fprintf(b, "  The longest order of them that the model accepts is %s", strings.Join(steps, ", then "))
+		if len(rejected) > 0 {
+			fmt.Fprintf(b, ", after which it rejects %s", joinList(rejected))
+		}
+		b.WriteString(".\n")
+	} else if len(rejected) > 0 {
+		fmt.Fprintf(b, "  The model rejects each of them that could go first: %s.\n", joinList(rejected))
+	}
+}
+
+// joinList joins items into an English list, e.g., "a, b, and c".
+func joinList(items []string) string {
+	switch len(items) {
+	case 1:
+		return items[0]
+	case 2:
+		return items[0] + " and " + items[1]
+	}
+	return strings.Join(items[:len(items)-1], ", ") + ", and " + items[len(items)-1]
+}
@@ -0,0 +1,63 @@
+package porcupine
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExplain(t *testing.T) {
+	ops := []Operation{
+		{0, registerInput{false, 1}, 0, 0, 10},
+		{1, registerInput{false, 2}, 20, 0, 30},
+		{0, registerInput{false, 3}, 40, 0, 60},
+		{1, registerInput{true, 0}, 45, 1, 50},
+	}
+	res, info := CheckOperationsVerbose(registerModel, ops, 0)
+	if res != Illegal {
+		t.Fatalf("expected output %v, got output %v", Illegal, res)
+	}
+	expected := `the 2 operations at [40, 60] can't be linearized: every linearization of the 2 operations before them leaves the model in state "2".
+  The longest order of them that the model accepts is put('3') (client 0, at [40, 60]) -> state "3", after which it rejects get() -> '1' (client 1, at [45, 50]).
+`
+	if explanation := Explain(registerModel, info); explanation != expected {
+		t.Fatalf("expected explanation\n%s\ngot\n%s", expected, explanation)
+	}
+
+	res, info = CheckOperationsVerbose(registerModel, ops[3:], 0)
+	if res != Illegal {
+		t.Fatalf("expected output %v, got output %v", Illegal, res)
+	}
+	expected = "get() -> '1' (client 1, at [45, 50]) can't be linearized: the model starts in state \"0\".\n"
+	if explanation := Explain(registerModel, info); explanation != expected {
+		t.Fatalf("expected explanation\n%s\ngot\n%s", expected, explanation)
+	}
+
+	res, info = CheckOperationsVerbose(registerModel, ops[:2], 0)
+	if res != Ok {
+		t.Fatalf("expected output %v, got output %v", Ok, res)
+	}
+	if explanation := Explain(registerModel, info); explanation != "The history is linearizable.\n" {
+		t.Fatalf("expected the history to be explained as linearizable, got %q", explanation)
+	}
+}
+
+func TestExplainPartitions(t *testing.T) {
+	ops := []Operation{
+		{0, kvInput{op: 1, key: "x", value: "a"}, 0, kvOutput{}, 10},
+		{1, kvInput{op: 0, key: "x"}, 20, kvOutput{"b"}, 30},
+		{2, kvInput{op: 1, key: "y", value: "c"}, 0, kvOutput{}, 10},
+		{2, kvInput{op: 0, key: "y"}, 20, kvOutput{"c"}, 30},
+	}
+	res, info := CheckOperationsVerbose(kvModel, ops, 0)
+	if res != Illegal {
+		t.Fatalf("expected output %v, got output %v", Illegal, res)
+	}
+	explanation := Explain(kvModel, info)
+	if !strings.HasPrefix(explanation, "Partition 0: get('x') -> 'b' (client 1, at [20, 30]) can't be linearized: "+
+		"the operation before it leaves the model in state \"a\".") {
+		t.Fatalf("expected the failing partition to be explained, got\n%s", explanation)
+	}
+	if strings.Count(explanation, "\n") != 1 {
+		t.Fatalf("expected only the failing partition to be explained, got\n%s", explanation)
+	}
+}
@@ -0,0 +1,70 @@
+package porcupine
+
+import "fmt"
+
+// A PartitionFailure identifies the partition of a history that a check
+// found to be non-linearizable; see [CheckOperationsFailFast].
+type PartitionFailure struct {
+	Index int    // index of the partition, as returned by the model's partition function
+	Label string // the partition's label
+	// The partition's sub-history: Operations for
+	// CheckOperationsFailFast, and Events for CheckEventsFailFast.
+	Operations []Operation
+	Events     []Event
+}
+
+// CheckOperationsFailFast checks whether a history is linearizable, stopping
+// at the first partition that is not, and returning it, so that the failure
+// can be reproduced and visualized on its own without checking every
+// partition again.
+//
+// The label function names a partition given its sub-history, e.g., by the
+// key that the model partitions on. If it is nil, partitions are labeled by
+// their index. The PartitionFailure is nil unless the result is Illegal.
+func CheckOperationsFailFast(model Model, history []Operation, label func(partition []Operation) string, opts ...CheckOption) (CheckResult, *PartitionFailure) {
+	model = fillDefault(model)
+	partitions := model.Partition(history)
+	l := make([][]entry, len(partitions))
+	for i, subhistory := range partitions {
+		l[i] = makeEntries(subhistory, nil)
+	}
+	failed := -1
+	o := checkOptions{onFailure: func(i int) { failed = i }}.apply(opts)
+	relaxRealTime(l, o.epsilon)
+	res, _ := checkParallel(model, l, o)
+	if res != Illegal || failed < 0 {
+		return res, nil
+	}
+	failure := &PartitionFailure{Index: failed, Operations: partitions[failed]}
+	if label != nil {
+		failure.Label = label(partitions[failed])
+	} else {
+		failure.Label = fmt.Sprintf("partition %d", failed)
+	}
+	return res, failure
+}
+
+// CheckEventsFailFast checks whether a history is linearizable, stopping at
+// the first partition that is not, and returning it; see
+// [CheckOperationsFailFast].
+func CheckEventsFailFast(model Model, history []Event, label func(partition []Event) string, opts ...CheckOption) (CheckResult, *PartitionFailure) {
+	model = fillDefault(model)
+	partitions := model.PartitionEvent(history)
+	l := make([][]entry, len(partitions))
+	for i, subhistory := range partitions {
+		l[i] = convertEntries(renumber(subhistory), nil)
+	}
+	failed := -1
+	o := checkOptions{onFailure: func(i int) { failed = i }}.apply(opts)
+	res, _ := checkParallel(model, l, o)
+	if res != Illegal || failed < 0 {
+		return res, nil
+	}
+	failure := &PartitionFailure{Index: failed, Events: partitions[failed]}
+	if label != nil {
+		failure.Label = label(partitions[failed])
+	} else {
+		failure.Label = fmt.Sprintf("partition %d", failed)
+	}
+	return res, failure
+}
@@ -0,0 +1,44 @@
+package porcupine
+
+import "testing"
+
+func TestCheckEventsFailFast(t *testing.T) {
+	label := func(partition []Event) string {
+		return partition[0].Value.(kvInput).key
+	}
+	res, failure := CheckEventsFailFast(kvModel, parseKvLog("test_data/kv/c10-bad.txt"), label)
+	if res != Illegal {
+		t.Fatalf("expected output %v, got output %v", Illegal, res)
+	}
+	if failure == nil || failure.Label == "" || len(failure.Events) == 0 {
+		t.Fatalf("expected a failing partition, got %+v", failure)
+	}
+	// the partition is non-linearizable on its own
+	if CheckEvents(kvModel, failure.Events) {
+		t.Fatalf("expected partition %q to not be linearizable", failure.Label)
+	}
+	for _, e := range failure.Events {
+		if e.Kind == CallEvent && e.Value.(kvInput).key != failure.Label {
+			t.Fatalf("expected only operations on key %q, got %v", failure.Label, e.Value)
+		}
+	}
+
+	res, failure = CheckEventsFailFast(kvModel, parseKvLog("test_data/kv/c10-ok.txt"), label)
+	if res != Ok || failure != nil {
+		t.Fatalf("expected output %v without a failure, got output %v, %+v", Ok, res, failure)
+	}
+}
+
+func TestCheckOperationsFailFast(t *testing.T) {
+	ops := []Operation{
+		{0, registerInput{false, 1}, 0, 0, 10},
+		{1, registerInput{true, 0}, 20, 0, 30},
+	}
+	res, failure := CheckOperationsFailFast(registerModel, ops, nil)
+	if res != Illegal {
+		t.Fatalf("expected output %v, got output %v", Illegal, res)
+	}
+	if failure == nil || failure.Label != "partition 0" || len(failure.Operations) != 2 {
+		t.Fatalf("expected the single partition to fail, got %+v", failure)
+	}
+}
@@ -0,0 +1,141 @@
+package porcupine
+
+import (
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// failingWindowTimeout bounds the search for a partition's failing window
+// when the partition is visualized or explained.
+const failingWindowTimeout = 10 * time.Second
+
+// A FailingWindow is the smallest window of time of a history that is not
+// linearizable, as found by [FindFailingWindow].
+type FailingWindow struct {
+	Start, End int64 // the window's span
+	// the operations in the window, in the order they were invoked, which
+	// are all in the same partition
+	Operations []Operation
+	// the number of operations of the partition before the window
+	Before int
+	// the states that the operations before the window can leave the model
+	// in; none of them lets the window's operations be linearized
+	States []interface{}
+}
+
+// FindFailingWindow finds the smallest window of time of a history whose
+// operations can't be linearized, however the operations before it are, to
+// localize a failure in time, e.g., before shrinking the history, or instead
+// of it.
+//
+// Each partition is cut wherever no operation is in progress, and the pieces
+// are searched in order, each from every state that the pieces before it can
+// end in, as with SplitOptions.WindowOperations. The first piece in which the
+// search gets stuck is the partition's failing window. Checking a suffix of a
+// history on its own wouldn't do: starting from the initial state, it would
+// fail on reads of values written before it. If several partitions fail, the
+// window that ends first is returned. The search takes about as long as a
+// check with window splitting, which is usually less than a check of the
+// whole history, and much less than shrinking it.
+//
+// It returns Ok if the history is linearizable, and Unknown if the search
+// timed out before finding a failing window. A timeout of 0 is interpreted as
+// an unlimited timeout.
+func FindFailingWindow(model Model, history []Operation, timeout time.Duration) (CheckResult, FailingWindow) {
+	model = fillDefault(model)
+	var deadline time.Time
+	if timeout > 0 {
+		deadline = time.Now().Add(timeout)
+	}
+	result := Ok
+	var window FailingWindow
+	for _, partition := range model.Partition(history) {
+		var remaining time.Duration
+		if timeout > 0 {
+			if remaining = time.Until(deadline); remaining <= 0 {
+				return Unknown, FailingWindow{}
+			}
+		}
+		entries := makeEntries(partition, nil)
+		f, res := failingWindow(model, entries, remaining)
+		if res == Unknown {
+			return Unknown, FailingWindow{}
+		}
+		if res == Ok || (result == Illegal && f.end >= window.End) {
+			continue
+		}
+		result = Illegal
+		ops := entryOperations(entries)
+		window = FailingWindow{Start: f.start, End: f.end, Before: f.before, States: f.states}
+		for _, id := range f.ops {
+			window.Operations = append(window.Operations, ops[id])
+		}
+		sort.SliceStable(window.Operations, func(i, j int) bool {
+			return window.Operations[i].Call < window.Operations[j].Call
+		})
+	}
+	return result, window
+}
+
+// A windowFailure is the smallest window of a partition that is not
+// linearizable, as found by failingWindow.
+type windowFailure struct {
+	start, end int64 // the window's span, in the history's timestamps
+	ops        []int // the IDs of the operations in the window
+	before     int   // the number of operations before the window
+	// the states that the operations before the window can end in
+	states []interface{}
+	// the longest sequence of the window's operations that can be
+	// linearized from one of the states
+	longest []int
+}
+
+// failingWindow finds the smallest window of a partition that is not
+// linearizable, as for FindFailingWindow, returning Illegal along with the
+// window if there is one, and Unknown if the search timed out. A timeout of 0
+// is interpreted as an unlimited timeout.
+//
+// Since a partition can only be cut where no operation is in progress, this
+// is as small as the window can be made without knowing about the model.
+func failingWindow(model Model, history []entry, timeout time.Duration) (windowFailure, CheckResult) {
+	var kill, expired int32
+	if timeout > 0 {
+		timer := time.AfterFunc(timeout, func() {
+			atomic.StoreInt32(&expired, 1)
+		})
+		defer timer.Stop()
+	}
+	search := searchOptions{computePartial: true, kill: &kill, expired: &expired}
+	var total PartitionMetrics
+	states := []interface{}{model.Init()}
+	before := 0
+	for start := 0; start < len(history); {
+		end, ops := windowEnd(history, start, 1)
+		ids, entries := windowEntries(history[start:end])
+		longest := make([]*[]int, len(history)/2)
+		res, next := searchWindow(model, entries, states, search, &total, longest, ids)
+		if res == Unknown {
+			return windowFailure{}, Unknown
+		}
+		if res == Illegal {
+			failure := windowFailure{
+				start:  history[start].time,
+				end:    history[end-1].time,
+				ops:    ids,
+				before: before,
+				states: states,
+			}
+			for _, id := range ids {
+				if l := longest[id]; l != nil && len(*l) > len(failure.longest) {
+					failure.longest = *l
+				}
+			}
+			return failure, Illegal
+		}
+		states = next
+		before += ops
+		start = end
+	}
+	return windowFailure{}, Ok
+}
@@ -0,0 +1,86 @@
+package porcupine
+
+import "testing"
+
+func TestFailingWindow(t *testing.T) {
+	ops := []Operation{
+		{0, registerInput{false, 1}, 0, 0, 10},
+		{1, registerInput{false, 2}, 20, 0, 30},
+		// overlaps the stale read, but can't explain it
+		{0, registerInput{false, 3}, 40, 0, 60},
+		{1, registerInput{true, 0}, 45, 1, 50},
+		{0, registerInput{true, 0}, 70, 3, 80},
+	}
+	res, info := CheckOperationsVerbose(registerModel, ops, 0)
+	if res != Illegal {
+		t.Fatalf("expected output %v, got output %v", Illegal, res)
+	}
+	data, err := VisualizeData(registerModel, info)
+	if err != nil {
+		t.Fatalf("failed to get visualization data: %v", err)
+	}
+	w := data.Partitions[0].FailingWindow
+	if w == nil || w.OriginalStart != "40" || w.OriginalEnd != "60" {
+		t.Fatalf("expected the failing window to be from 40 to 60, got %+v", w)
+	}
+
+	res, info = CheckOperationsVerbose(registerModel, ops[:3], 0)
+	if res != Ok {
+		t.Fatalf("expected output %v, got output %v", Ok, res)
+	}
+	data, err = VisualizeData(registerModel, info)
+	if err != nil {
+		t.Fatalf("failed to get visualization data: %v", err)
+	}
+	if w := data.Partitions[0].FailingWindow; w != nil {
+		t.Fatalf("expected no failing window, got %+v", w)
+	}
+}
+
+func TestFailingWindowCarriesStates(t *testing.T) {
+	// either write can come last, so the second window is only
+	// linearizable if both states are carried to it
+	ops := []Operation{
+		{0, registerInput{false, 1}, 0, 0, 10},
+		{1, registerInput{false, 2}, 0, 0, 10},
+		{0, registerInput{true, 0}, 20, 2, 30},
+		{1, registerInput{true, 0}, 40, 1, 50},
+	}
+	f, res := failingWindow(fillDefault(registerModel), makeEntries(ops, nil), 0)
+	if res != Illegal || f.start != 40 || f.end != 50 {
+		t.Fatalf("expected the failing window to be from 40 to 50, got %d to %d (%v)", f.start, f.end, res)
+	}
+	if len(f.states) != 1 || f.states[0] != 2 || f.before != 3 {
+		t.Fatalf("expected state 2 after the 3 operations before the window, got %v after %d", f.states, f.before)
+	}
+}
+
+func TestFindFailingWindow(t *testing.T) {
+	ops := []Operation{
+		{0, kvInput{op: 1, key: "x", value: "a"}, 0, kvOutput{}, 10},
+		{1, kvInput{op: 1, key: "y", value: "b"}, 0, kvOutput{}, 10},
+		{1, kvInput{op: 0, key: "y"}, 20, kvOutput{"c"}, 30},
+		{0, kvInput{op: 0, key: "x"}, 40, kvOutput{"b"}, 60},
+		{2, kvInput{op: 1, key: "x", value: "b"}, 50, kvOutput{}, 70},
+		{0, kvInput{op: 0, key: "x"}, 80, kvOutput{"d"}, 90},
+	}
+	res, window := FindFailingWindow(kvModel, ops, 0)
+	if res != Illegal {
+		t.Fatalf("expected output %v, got output %v", Illegal, res)
+	}
+	// key "x" fails later, from 80 to 90
+	if window.Start != 20 || window.End != 30 || len(window.Operations) != 1 || window.Operations[0] != ops[2] {
+		t.Fatalf("expected the failing window to be the read of key \"y\", got %+v", window)
+	}
+	if window.Before != 1 || len(window.States) != 1 || window.States[0] != "b" {
+		t.Fatalf("expected the write before the window to leave the state at \"b\", got %v after %d", window.States, window.Before)
+	}
+
+	res, window = FindFailingWindow(kvModel, ops[3:5], 0)
+	if res != Ok {
+		t.Fatalf("expected output %v, got output %v", Ok, res)
+	}
+	if window.Operations != nil {
+		t.Fatalf("expected no failing window, got %+v", window)
+	}
+}
@@ -0,0 +1,183 @@
+package porcupine
+
+import (
+	"math/bits"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// maxForkClients is the largest number of clients that
+// CheckForkLinearizability supports.
+const maxForkClients = 64
+
+// CheckForkLinearizability checks whether a history is fork-linearizable,
+// the strongest guarantee that a storage protocol can provide when the
+// server is untrusted: a faulty server can hide clients' operations from
+// each other, but only by forking their views of the history, so that once
+// two clients' views differ, they never see each other's operations again.
+// Formally, each client has a view, a sequential order of its own operations
+// and some of the others', that is legal for the model and respects the
+// real-time order of its operations, and if an operation is in two views,
+// the views are the same up to that operation.
+//
+// The model's partition functions are not used, because the views span the
+// whole history. The search is exponential in the number of clients as well
+// as the number of operations, so it is meant for small histories, and
+// supports up to 64 clients; it returns Unknown for more. Of the options, the
+// check uses [WithTimeout] and [WithContext], and returns Unknown if it is
+// stopped before it can decide. Weaker forking conditions, such as fork-*
+// consistency, are not supported.
+func CheckForkLinearizability(model Model, history []Operation, opts ...CheckOption) CheckResult {
+	model = fillDefault(model)
+	clientIds := make(map[int]uint)
+	for _, op := range history {
+		if _, ok := clientIds[op.ClientId]; !ok {
+			clientIds[op.ClientId] = uint(len(clientIds))
+		}
+	}
+	if len(clientIds) > maxForkClients {
+		return Unknown
+	}
+	n := len(history)
+	clients := make([]uint64, n) // the client of each operation, as a bit
+	// the operations that precede each operation in real time
+	preceding := make([][]int, n)
+	for i, op := range history {
+		clients[i] = 1 << clientIds[op.ClientId]
+		for j, other := range history {
+			if other.Return < op.Call {
+				preceding[i] = append(preceding[i], j)
+			}
+		}
+	}
+	o := checkOptions{}.apply(opts)
+	stop, release := stopSignal(o)
+	defer release()
+
+	// a branch is a view shared by a set of clients, whose views haven't
+	// forked yet
+	type branch struct {
+		clients uint64
+		placed  bitset
+		state   interface{}
+	}
+	visited := make(map[string][][]interface{})
+	// seen reports whether the search has been at the given branches
+	// before, and records them if not
+	seen := func(branches []branch) bool {
+		keys := make([]string, len(branches))
+		order := make([]int, len(branches))
+		for i, b := range branches {
+			var key strings.Builder
+			key.WriteString(strconv.FormatUint(b.clients, 16))
+			for _, w := range b.placed {
+				key.WriteByte(':')
+				key.WriteString(strconv.FormatUint(w, 16))
+			}
+			keys[i] = key.String()
+			order[i] = i
+		}
+		sort.Slice(order, func(a, b int) bool {
+			return keys[order[a]] < keys[order[b]]
+		})
+		states := make([]interface{}, len(branches))
+		var key strings.Builder
+		for i, j := range order {
+			key.WriteString(keys[j])
+			key.WriteByte('|')
+			states[i] = branches[j].state
+		}
+		for _, other := range visited[key.String()] {
+			same := true
+			for i := range states {
+				if !model.Equal(states[i], other[i]) {
+					same = false
+					break
+				}
+			}
+			if same {
+				return true
+			}
+		}
+		visited[key.String()] = append(visited[key.String()], states)
+		return false
+	}
+	var search func(branches []branch) CheckResult
+	search = func(branches []branch) CheckResult {
+		if atomic.LoadInt32(stop) != 0 {
+			return Unknown
+		}
+		done := true
+		for _, b := range branches {
+			for i := range history {
+				if b.clients&clients[i] != 0 && !b.placed.get(uint(i)) {
+					done = false
+				}
+			}
+		}
+		if done {
+			return Ok
+		}
+		if seen(branches) {
+			return Illegal
+		}
+		result := Illegal
+		try := func(next []branch) bool {
+			switch search(next) {
+			case Ok:
+				return true
+			case Unknown:
+				result = Unknown
+			}
+			return false
+		}
+		for bi, b := range branches {
+			// add one of the branch's clients' operations to the view
+		ops:
+			for i, op := range history {
+				if b.clients&clients[i] == 0 || b.placed.get(uint(i)) {
+					continue
+				}
+				// the operations that precede it, that the view
+				// includes, must come first
+				for _, j := range preceding[i] {
+					if b.clients&clients[j] != 0 && !b.placed.get(uint(j)) {
+						continue ops
+					}
+				}
+				ok, state := model.Step(b.state, op.Input, op.Output)
+				if !ok {
+					continue
+				}
+				next := append([]branch(nil), branches...)
+				placed := append(bitset(nil), b.placed...)
+				next[bi] = branch{b.clients, placed.set(uint(i)), state}
+				if try(next) {
+					return Ok
+				}
+			}
+			// fork the branch, splitting off some of its clients other
+			// than the first, so that each split is tried once
+			rest := b.clients &^ (b.clients & -b.clients)
+			if bits.OnesCount64(b.clients) < 2 {
+				continue
+			}
+			for sub := rest; sub != 0; sub = (sub - 1) & rest {
+				next := append([]branch(nil), branches...)
+				next[bi] = branch{b.clients &^ sub, b.placed, b.state}
+				next = append(next, branch{sub, append(bitset(nil), b.placed...), b.state})
+				if try(next) {
+					return Ok
+				}
+			}
+		}
+		return result
+	}
+	all := uint64(0)
+	for _, c := range clients {
+		all |= c
+	}
+	return search([]branch{{all, newBitset(uint(n)), model.Init()}})
+}
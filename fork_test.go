@@ -0,0 +1,54 @@
+package porcupine
+
+import "testing"
+
+func TestCheckForkLinearizability(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		history  []Operation
+		expected CheckResult
+	}{
+		{
+			"linearizable",
+			registerHistory(12),
+			Ok,
+		},
+		{
+			// the server hides client 0's write from client 1, which is
+			// fine as long as client 1 never sees it
+			"forked",
+			[]Operation{
+				{ClientId: 0, Input: registerInput{false, 1}, Call: 0, Output: 0, Return: 10},
+				{ClientId: 0, Input: registerInput{true, 0}, Call: 20, Output: 1, Return: 30},
+				{ClientId: 1, Input: registerInput{true, 0}, Call: 40, Output: 0, Return: 50},
+			},
+			Ok,
+		},
+		{
+			// client 1 sees client 0's write, and then doesn't
+			"joined",
+			[]Operation{
+				{ClientId: 0, Input: registerInput{false, 1}, Call: 0, Output: 0, Return: 10},
+				{ClientId: 1, Input: registerInput{true, 0}, Call: 20, Output: 1, Return: 30},
+				{ClientId: 1, Input: registerInput{true, 0}, Call: 40, Output: 0, Return: 50},
+			},
+			Illegal,
+		},
+		{
+			// after the views fork, client 1 sees a write that only client
+			// 0's view has
+			"forked then joined",
+			[]Operation{
+				{ClientId: 0, Input: registerInput{false, 1}, Call: 0, Output: 0, Return: 10},
+				{ClientId: 1, Input: registerInput{true, 0}, Call: 20, Output: 0, Return: 30},
+				{ClientId: 0, Input: registerInput{false, 2}, Call: 40, Output: 0, Return: 50},
+				{ClientId: 1, Input: registerInput{true, 0}, Call: 60, Output: 2, Return: 70},
+			},
+			Illegal,
+		},
+	} {
+		if res := CheckForkLinearizability(registerModel, tc.history); res != tc.expected {
+			t.Fatalf("%s: expected output %v, got output %v", tc.name, tc.expected, res)
+		}
+	}
+}
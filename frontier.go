@@ -0,0 +1,89 @@
+package porcupine
+
+// A FrontierState is a state that the model can be in at the point where a
+// check failed, i.e., at the end of one of the longest partial linearizations
+// of a partition that is not linearizable, as found by
+// [LinearizationInfo.FrontierStates].
+type FrontierState struct {
+	Partition int
+	// the state, as returned by the model's Step function, so that it can be
+	// inspected with a type assertion, and its description
+	State       interface{}
+	Description string
+	// a partial linearization that leaves the model in the state, as
+	// operation IDs into the partition
+	Linearization []int
+	// the operations that could be linearized next, but that the model
+	// rejects in the state
+	Rejected []Operation
+}
+
+// FrontierStates returns the states that the model can be in at the point
+// where a check failed: for each partition that is not linearizable, the
+// states reached by replaying the longest of its partial linearizations (see
+// [LinearizationInfo.PartialLinearizations]), which are the states that the
+// model believes possible when it rejects every operation that could go next.
+// The check records a partial linearization for each operation, so this can
+// leave out states that other orders of the same operations would reach; a
+// [Debugger] can enumerate those. States that are equal, according to the
+// model's Equal function, are returned once, with the first partial
+// linearization that reaches them. The states are sorted by partition.
+//
+// Partitions whose check timed out are skipped, since their search didn't
+// exhaust its options. The LinearizationInfo must come from one of the
+// verbose check functions, e.g., [CheckOperationsVerbose].
+func (li *LinearizationInfo) FrontierStates(model Model) []FrontierState {
+	model = fillDefault(model)
+	var frontier []FrontierState
+	for p, history := range li.history {
+		if p < len(li.timedOut) && li.timedOut[p] {
+			continue
+		}
+		ops := entryOperations(history)
+		longest := 0
+		for _, partial := range li.partialLinearizations[p] {
+			if len(partial) > longest {
+				longest = len(partial)
+			}
+		}
+		if longest == len(ops) {
+			continue
+		}
+		partials := li.partialLinearizations[p]
+		if longest == 0 {
+			// no operation can be linearized first, so the search is
+			// stuck in the initial state, with nothing linearized
+			partials = [][]int{{}}
+		}
+		var states []interface{}
+	partials:
+		for _, partial := range partials {
+			if len(partial) != longest {
+				continue
+			}
+			state := model.Init()
+			linearized := make([]bool, len(ops))
+			for _, id := range partial {
+				_, state = model.Step(state, ops[id].Input, ops[id].Output)
+				linearized[id] = true
+			}
+			for _, s := range states {
+				if model.Equal(s, state) {
+					continue partials
+				}
+			}
+			states = append(states, state)
+			fs := FrontierState{
+				Partition:     p,
+				State:         state,
+				Description:   model.DescribeState(state),
+				Linearization: append([]int(nil), partial...),
+			}
+			for _, id := range stuckOperations(model, ops, linearized, state) {
+				fs.Rejected = append(fs.Rejected, ops[id])
+			}
+			frontier = append(frontier, fs)
+		}
+	}
+	return frontier
+}
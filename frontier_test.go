@@ -0,0 +1,56 @@
+package porcupine
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFrontierStates(t *testing.T) {
+	ops := []Operation{
+		{0, registerInput{false, 1}, 0, 0, 10},
+		{1, registerInput{false, 2}, 0, 0, 10},
+		{2, registerInput{true, 0}, 20, 1, 30},
+		{3, registerInput{true, 0}, 20, 2, 30},
+	}
+	res, info := CheckOperationsVerbose(registerModel, ops, 0)
+	if res != Illegal {
+		t.Fatalf("expected output %v, got output %v", Illegal, res)
+	}
+	frontier := info.FrontierStates(registerModel)
+	states := make(map[int]FrontierState)
+	for _, fs := range frontier {
+		states[fs.State.(int)] = fs
+	}
+	if len(frontier) != 2 || len(states) != 2 {
+		t.Fatalf("expected the two states that the writes can leave, got %+v", frontier)
+	}
+	// in each state, one of the reads is linearized and the other rejected
+	for state, read := range map[int]int{1: 2, 2: 3} {
+		fs := states[state]
+		if len(fs.Linearization) != 3 || fs.Linearization[2] != read {
+			t.Fatalf("expected state %d to be reached by a linearization ending in %d, got %+v", state, read, fs)
+		}
+		if !reflect.DeepEqual(fs.Rejected, []Operation{ops[5-read]}) {
+			t.Fatalf("expected the other read to be rejected in state %d, got %+v", state, fs.Rejected)
+		}
+	}
+
+	// a read that can't go first leaves the initial state as the frontier
+	res, info = CheckOperationsVerbose(registerModel, ops[3:], 0)
+	if res != Illegal {
+		t.Fatalf("expected output %v, got output %v", Illegal, res)
+	}
+	frontier = info.FrontierStates(registerModel)
+	if len(frontier) != 1 || frontier[0].State != 0 || len(frontier[0].Linearization) != 0 ||
+		!reflect.DeepEqual(frontier[0].Rejected, []Operation{ops[3]}) {
+		t.Fatalf("expected the initial state as the frontier, got %+v", frontier)
+	}
+
+	res, info = CheckOperationsVerbose(registerModel, ops[:2], 0)
+	if res != Ok {
+		t.Fatalf("expected output %v, got output %v", Ok, res)
+	}
+	if frontier := info.FrontierStates(registerModel); len(frontier) != 0 {
+		t.Fatalf("expected no frontier states, got %+v", frontier)
+	}
+}
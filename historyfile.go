@@ -0,0 +1,323 @@
+package porcupine
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+)
+
+// HistoryFileVersion is the version of the history file format written by
+// [WriteHistoryFile]. Readers load files of this version and older.
+const HistoryFileVersion = 1
+
+const historyFileMagic = "PORCUPINE-HISTORY"
+
+// A HistoryHeader describes the contents of a history file.
+type HistoryHeader struct {
+	// Format version; set by WriteHistoryFile.
+	Version int `json:"version"`
+	// Encoding of the history: "json" (as read by ReadJSONOperations and
+	// ReadJSONEvents), "jsonl" (as read by ReadJSONLOperations and
+	// ReadJSONLEvents), "csv" (as read by ReadCSVOperations; operations
+	// only), "proto" (see MarshalOperationsProto), or "msgpack" (see
+	// MarshalOperationsMsgpack). Defaults to "json". Readers also accept the
+	// name of any format registered with RegisterDecoder, which is read as
+	// events with that decoder.
+	Encoding string `json:"encoding"`
+	// "operations" or "events"; set by WriteHistoryFile.
+	Kind string `json:"kind"`
+	// Optional: the name of a registered codec for inputs and outputs; see
+	// RegisterCodec.
+	Codec string `json:"codec,omitempty"`
+	// Optional: JSON Schemas for inputs and outputs, so that readers without
+	// the codec can decode them as dynamic values; see Schema.
+	InputSchema  json.RawMessage `json:"inputSchema,omitempty"`
+	OutputSchema json.RawMessage `json:"outputSchema,omitempty"`
+	// Optional: free-form metadata, e.g., the test and seed that produced
+	// the history.
+	Metadata map[string]string `json:"metadata,omitempty"`
+	// Optional: the name of a compression format for the whole file, e.g.,
+	// "gzip"; see RegisterCompression. Not part of the header line: readers
+	// detect compression from the file's first bytes, and set this field.
+	Compression string `json:"-"`
+}
+
+// A HistoryFile is a history along with a header describing it. Exactly one
+// of Operations and Events is used.
+type HistoryFile struct {
+	Header     HistoryHeader
+	Operations []Operation
+	Events     []Event
+}
+
+// WriteHistoryFile writes a history in a self-describing container format: a
+// first line with a magic string and the format version,
+//
+//	PORCUPINE-HISTORY 1
+//
+// then the header, as a line of JSON, and then the history, in the encoding
+// named in the header. The container lets files stay loadable as encodings
+// and types evolve: [ReadHistoryFile] picks the decoder and the codec based on
+// the header.
+//
+// If the header names a compression format, the whole file is compressed.
+//
+// Inputs and outputs are encoded with the given codec. If codec is nil, the
+// codec registered under the header's Codec name is used, or if there is none,
+// they are encoded as JSON.
+func WriteHistoryFile(w io.Writer, file HistoryFile, codec Codec) error {
+	header := file.Header
+	header.Version = HistoryFileVersion
+	if header.Encoding == "" {
+		header.Encoding = "json"
+	}
+	header.Kind = "operations"
+	if file.Events != nil {
+		header.Kind = "events"
+	}
+	if codec == nil {
+		var ok bool
+		if codec, ok = LookupCodec(header.Codec); !ok {
+			codec = &SchemaCodec{}
+		}
+	}
+	var payload bytes.Buffer
+	var err error
+	switch header.Encoding + "/" + header.Kind {
+	case "json/operations":
+		doc := jsonHistory{Codec: header.Codec, Operations: make([]jsonOperation, len(file.Operations))}
+		for i, op := range file.Operations {
+			if doc.Operations[i], err = encodeOperation(codec, op); err != nil {
+				return fmt.Errorf("operations[%d]: %v", i, err)
+			}
+		}
+		err = json.NewEncoder(&payload).Encode(doc)
+	case "json/events":
+		doc := jsonHistory{Codec: header.Codec, Events: make([]jsonEvent, len(file.Events))}
+		for i, e := range file.Events {
+			if doc.Events[i], err = encodeEvent(codec, e); err != nil {
+				return fmt.Errorf("events[%d]: %v", i, err)
+			}
+		}
+		err = json.NewEncoder(&payload).Encode(doc)
+	case "jsonl/operations":
+		enc := json.NewEncoder(&payload)
+		for i, op := range file.Operations {
+			jop, err := encodeOperation(codec, op)
+			if err != nil {
+				return fmt.Errorf("operation %d: %v", i, err)
+			}
+			if err := enc.Encode(jop); err != nil {
+				return err
+			}
+		}
+	case "jsonl/events":
+		enc := json.NewEncoder(&payload)
+		for i, e := range file.Events {
+			je, err := encodeEvent(codec, e)
+			if err != nil {
+				return fmt.Errorf("event %d: %v", i, err)
+			}
+			if err := enc.Encode(je); err != nil {
+				return err
+			}
+		}
+	case "csv/operations":
+		err = WriteCSVOperations(&payload, file.Operations, codec)
+	case "proto/operations":
+		var data []byte
+		data, err = MarshalOperationsProto(file.Operations, codec)
+		payload.Write(data)
+	case "proto/events":
+		var data []byte
+		data, err = MarshalEventsProto(file.Events, codec)
+		payload.Write(data)
+	case "msgpack/operations":
+		var data []byte
+		data, err = MarshalOperationsMsgpack(file.Operations, codec)
+		payload.Write(data)
+	case "msgpack/events":
+		var data []byte
+		data, err = MarshalEventsMsgpack(file.Events, codec)
+		payload.Write(data)
+	default:
+		return fmt.Errorf("unsupported encoding %q for %s", header.Encoding, header.Kind)
+	}
+	if err != nil {
+		return err
+	}
+	headerLine, err := json.Marshal(header)
+	if err != nil {
+		return err
+	}
+	cw, err := NewCompressedWriter(w, header.Compression)
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(cw)
+	fmt.Fprintf(bw, "%s %d\n%s\n", historyFileMagic, HistoryFileVersion, headerLine)
+	if _, err := payload.WriteTo(bw); err != nil {
+		return err
+	}
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+	return cw.Close()
+}
+
+// ReadHistoryFile reads a history written by [WriteHistoryFile]. For
+// compatibility with files written before the container format, a file
+// without the magic string is read as a JSON document, as by
+// [ReadJSONOperations] or [ReadJSONEvents], and gets a header with version 0.
+// Compressed files are decompressed transparently; see [RegisterCompression].
+//
+// Inputs and outputs are decoded with the given codec. If codec is nil, the
+// codec registered under the header's Codec name is used; if there is none,
+// they are decoded as dynamic values with the header's schemas.
+func ReadHistoryFile(r io.Reader, codec Codec) (HistoryFile, error) {
+	var file HistoryFile
+	dr, compression, err := NewDecompressedReader(r)
+	if err != nil {
+		return file, err
+	}
+	br := bufio.NewReader(dr)
+	magic, err := br.Peek(len(historyFileMagic))
+	if err != nil && err != io.EOF {
+		return file, err
+	}
+	if string(magic) != historyFileMagic {
+		file, err = readLegacyHistoryFile(br, codec)
+		file.Header.Compression = compression
+		return file, err
+	}
+	line, err := br.ReadString('\n')
+	if err != nil {
+		return file, unexpectedEOF(err)
+	}
+	version, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, historyFileMagic)))
+	if err != nil {
+		return file, fmt.Errorf("invalid history file version line %q", strings.TrimSpace(line))
+	}
+	if version > HistoryFileVersion {
+		return file, fmt.Errorf("history file version %d is newer than the supported version %d", version, HistoryFileVersion)
+	}
+	line, err = br.ReadString('\n')
+	if err != nil {
+		return file, unexpectedEOF(err)
+	}
+	if err := json.Unmarshal([]byte(line), &file.Header); err != nil {
+		return file, fmt.Errorf("header: %v", err)
+	}
+	file.Header.Version = version
+	file.Header.Compression = compression
+	if codec == nil {
+		if codec, err = file.Header.codec(); err != nil {
+			return file, err
+		}
+	}
+	header := file.Header
+	switch header.Encoding + "/" + header.Kind {
+	case "json/operations":
+		file.Operations, err = ReadJSONOperations(br, codec)
+	case "json/events":
+		file.Events, err = ReadJSONEvents(br, codec)
+	case "jsonl/operations":
+		file.Operations, err = ReadJSONLOperations(br, codec)
+	case "jsonl/events":
+		var pending []Event
+		file.Events, pending, err = ReadJSONLEvents(br, codec)
+		if err == nil && len(pending) > 0 {
+			err = fmt.Errorf("%d calls without returns", len(pending))
+		}
+	case "csv/operations":
+		file.Operations, err = ReadCSVOperations(br, codec)
+	case "proto/operations", "proto/events", "msgpack/operations", "msgpack/events":
+		var data []byte
+		if data, err = ioutil.ReadAll(br); err != nil {
+			return file, err
+		}
+		switch header.Encoding + "/" + header.Kind {
+		case "proto/operations":
+			file.Operations, err = UnmarshalOperationsProto(data, codec)
+		case "proto/events":
+			file.Events, err = UnmarshalEventsProto(data, codec)
+		case "msgpack/operations":
+			file.Operations, err = UnmarshalOperationsMsgpack(data, codec)
+		case "msgpack/events":
+			file.Events, err = UnmarshalEventsMsgpack(data, codec)
+		}
+	default:
+		decoder, ok := LookupDecoder(header.Encoding)
+		if !ok {
+			return file, fmt.Errorf("unsupported encoding %q for %q", header.Encoding, header.Kind)
+		}
+		file.Header.Kind = "events"
+		file.Events, err = decoder(br)
+	}
+	return file, err
+}
+
+func readLegacyHistoryFile(r io.Reader, codec Codec) (HistoryFile, error) {
+	file := HistoryFile{Header: HistoryHeader{Encoding: "json", Kind: "operations"}}
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return file, err
+	}
+	var probe struct {
+		Codec  string          `json:"codec"`
+		Events json.RawMessage `json:"events"`
+	}
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return file, err
+	}
+	file.Header.Codec = probe.Codec
+	if probe.Events != nil {
+		file.Header.Kind = "events"
+		file.Events, err = ReadJSONEvents(bytes.NewReader(data), codec)
+	} else {
+		file.Operations, err = ReadJSONOperations(bytes.NewReader(data), codec)
+	}
+	return file, err
+}
+
+// codec returns the codec that the header describes.
+func (h HistoryHeader) codec() (Codec, error) {
+	if h.Codec != "" {
+		if codec, ok := LookupCodec(h.Codec); ok {
+			return codec, nil
+		}
+		if h.InputSchema == nil && h.OutputSchema == nil {
+			return nil, fmt.Errorf("unknown codec %q", h.Codec)
+		}
+	}
+	codec := &SchemaCodec{}
+	var err error
+	if h.InputSchema != nil {
+		if codec.Input, err = ParseSchema(h.InputSchema); err != nil {
+			return nil, fmt.Errorf("inputSchema: %v", err)
+		}
+	}
+	if h.OutputSchema != nil {
+		if codec.Output, err = ParseSchema(h.OutputSchema); err != nil {
+			return nil, fmt.Errorf("outputSchema: %v", err)
+		}
+	}
+	return codec, nil
+}
+
+func encodeEvent(codec Codec, e Event) (jsonEvent, error) {
+	je := jsonEvent{Client: e.ClientId, Kind: "call", Id: e.Id}
+	var err error
+	if e.Kind == CallEvent {
+		je.Value, err = codec.EncodeInput(e.Value)
+	} else {
+		je.Kind = "return"
+		je.Value, err = codec.EncodeOutput(e.Value)
+	}
+	return je, err
+}
@@ -0,0 +1,120 @@
+package porcupine
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHistoryFileRoundTrip(t *testing.T) {
+	ops := []Operation{
+		{0, kvInput{op: 1, key: "x", value: "a"}, 0, kvOutput{}, 10},
+		{1, kvInput{op: 0, key: "x"}, 5, kvOutput{"a"}, 25},
+	}
+	events := []Event{
+		{0, CallEvent, kvInput{op: 1, key: "x", value: "a"}, 0},
+		{1, CallEvent, kvInput{op: 0, key: "x"}, 1},
+		{0, ReturnEvent, kvOutput{}, 0},
+		{1, ReturnEvent, kvOutput{"a"}, 1},
+	}
+	for _, encoding := range []string{"", "json", "jsonl", "csv", "proto", "msgpack"} {
+		file := HistoryFile{
+			Header:     HistoryHeader{Encoding: encoding, Metadata: map[string]string{"seed": "42"}},
+			Operations: ops,
+		}
+		var buf bytes.Buffer
+		if err := WriteHistoryFile(&buf, file, kvCodec); err != nil {
+			t.Fatalf("%s: %v", encoding, err)
+		}
+		read, err := ReadHistoryFile(&buf, kvCodec)
+		if err != nil {
+			t.Fatalf("%s: %v", encoding, err)
+		}
+		if !reflect.DeepEqual(ops, read.Operations) || read.Header.Kind != "operations" || read.Header.Metadata["seed"] != "42" {
+			t.Fatalf("%s: unexpected file %+v", encoding, read)
+		}
+
+		if encoding == "csv" {
+			continue
+		}
+		buf.Reset()
+		if err := WriteHistoryFile(&buf, HistoryFile{Header: HistoryHeader{Encoding: encoding}, Events: events}, kvCodec); err != nil {
+			t.Fatalf("%s: %v", encoding, err)
+		}
+		read, err = ReadHistoryFile(&buf, kvCodec)
+		if err != nil {
+			t.Fatalf("%s: %v", encoding, err)
+		}
+		if !reflect.DeepEqual(events, read.Events) || read.Header.Kind != "events" {
+			t.Fatalf("%s: unexpected file %+v", encoding, read)
+		}
+	}
+}
+
+func TestHistoryFileSchemas(t *testing.T) {
+	file := HistoryFile{
+		Header: HistoryHeader{
+			Encoding:     "msgpack",
+			Codec:        "unregistered",
+			InputSchema:  []byte(kvInputSchema),
+			OutputSchema: []byte(kvOutputSchema),
+		},
+		Operations: []Operation{
+			{0, map[string]interface{}{"op": "put", "key": "x", "value": "a"}, 0, nil, 10},
+			{1, map[string]interface{}{"op": "get", "key": "x"}, 20, map[string]interface{}{"value": "a", "version": int64(1)}, 30},
+		},
+	}
+	var buf bytes.Buffer
+	if err := WriteHistoryFile(&buf, file, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(buf.String(), "PORCUPINE-HISTORY 1\n{") {
+		t.Fatalf("unexpected start of file %q", buf.String()[:30])
+	}
+	// without the codec, the schemas are used
+	read, err := ReadHistoryFile(&buf, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(file.Operations, read.Operations) {
+		t.Fatalf("expected %v, got %v", file.Operations, read.Operations)
+	}
+	if !CheckOperations(dynamicKvModel, read.Operations) {
+		t.Fatal("expected operations to be linearizable")
+	}
+}
+
+func TestHistoryFileLegacy(t *testing.T) {
+	read, err := ReadHistoryFile(strings.NewReader(`{"events": [
+	  {"client": 0, "kind": "call", "value": 1, "id": 0},
+	  {"client": 0, "kind": "return", "value": 2, "id": 0}
+	]}`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if read.Header.Version != 0 || read.Header.Kind != "events" || len(read.Events) != 2 || read.Events[1].Value != int64(2) {
+		t.Fatalf("unexpected file %+v", read)
+	}
+}
+
+func TestHistoryFileErrors(t *testing.T) {
+	for _, tc := range []struct {
+		input string
+		err   string
+	}{
+		{"PORCUPINE-HISTORY 2\n{}\n", "history file version 2 is newer than the supported version 1"},
+		{"PORCUPINE-HISTORY 1\n{\"encoding\": \"yaml\", \"kind\": \"operations\"}\n", `unsupported encoding "yaml" for "operations"`},
+		{"PORCUPINE-HISTORY 1\n{\"encoding\": \"json\", \"kind\": \"operations\", \"codec\": \"nope\"}\n{}", `unknown codec "nope"`},
+		{"PORCUPINE-HISTORY 1\n", "unexpected EOF"},
+	} {
+		_, err := ReadHistoryFile(strings.NewReader(tc.input), nil)
+		if err == nil || err.Error() != tc.err {
+			t.Errorf("%q: expected error %q, got %v", tc.input, tc.err, err)
+		}
+	}
+	err := WriteHistoryFile(&bytes.Buffer{}, HistoryFile{Header: HistoryHeader{Encoding: "csv"}, Events: []Event{}}, nil)
+	if err == nil {
+		t.Fatal("expected an error for events as CSV")
+	}
+}
@@ -0,0 +1,91 @@
+package porcupine
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// A MethodCall is the input of an operation that records a method call, as
+// recorded by [RecorderClient.Invoke] or by the wrappers that the
+// porcupine-instrument command generates.
+type MethodCall struct {
+	Method string
+	Args   []interface{}
+}
+
+// String formats the call as, e.g., "Put(x, 1)".
+func (c MethodCall) String() string {
+	return fmt.Sprintf("%s(%s)", c.Method, joinValues(c.Args))
+}
+
+// A MethodResult is the output of an operation that records a method call:
+// the values that the method returned.
+type MethodResult struct {
+	Results []interface{}
+}
+
+// String formats the results as, e.g., "[1, <nil>]".
+func (r MethodResult) String() string {
+	return fmt.Sprintf("[%s]", joinValues(r.Results))
+}
+
+// Error returns the method's last result if it is a non-nil error, which is
+// the Go convention for a method that can fail, and nil otherwise.
+func (r MethodResult) Error() error {
+	if len(r.Results) == 0 {
+		return nil
+	}
+	err, _ := r.Results[len(r.Results)-1].(error)
+	return err
+}
+
+func joinValues(values []interface{}) string {
+	s := make([]string, len(values))
+	for i, v := range values {
+		s[i] = fmt.Sprintf("%v", v)
+	}
+	return strings.Join(s, ", ")
+}
+
+// Invoke calls fn with the given arguments and records the call as an
+// operation, with a [MethodCall] as its input and a [MethodResult] as its
+// output. It returns fn's results.
+//
+// This removes the boilerplate of instrumenting a client by hand: for
+// example, client.Invoke("Get", kv.Get, "x") calls kv.Get("x") and records
+// it. Invoke uses reflection, so it panics if fn is not a function or the
+// arguments don't match its signature; for a type-checked alternative that
+// wraps a whole interface, see the porcupine-instrument command.
+func (c *RecorderClient) Invoke(method string, fn interface{}, args ...interface{}) []interface{} {
+	f := reflect.ValueOf(fn)
+	if f.Kind() != reflect.Func {
+		panic(fmt.Sprintf("porcupine: Invoke called with non-function %T", fn))
+	}
+	in := make([]reflect.Value, len(args))
+	for i, arg := range args {
+		if arg == nil {
+			// untyped nil: use the zero value of the parameter's type
+			in[i] = reflect.Zero(parameterType(f.Type(), i))
+		} else {
+			in[i] = reflect.ValueOf(arg)
+		}
+	}
+	c.Begin(MethodCall{Method: method, Args: args})
+	out := f.Call(in)
+	results := make([]interface{}, len(out))
+	for i, v := range out {
+		results[i] = v.Interface()
+	}
+	c.End(MethodResult{Results: results})
+	return results
+}
+
+// parameterType returns the type of the i-th argument to a function of type
+// t, accounting for variadic parameters.
+func parameterType(t reflect.Type, i int) reflect.Type {
+	if t.IsVariadic() && i >= t.NumIn()-1 {
+		return t.In(t.NumIn() - 1).Elem()
+	}
+	return t.In(i)
+}
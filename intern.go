@@ -0,0 +1,39 @@
+package porcupine
+
+// A stateInterner deduplicates the states that a partition's search visits,
+// so that equal states are stored once and identified by a small ID. Equal
+// states returned by separate calls to the model's Step function then share
+// one copy, and the checker's cache compares IDs instead of calling Equal.
+//
+// It relies on the model's Hash function to find equal states quickly.
+type stateInterner struct {
+	model  Model
+	byHash map[uint64][]int // from hash to IDs of states with that hash
+	states []interface{}    // indexed by ID
+}
+
+func newStateInterner(model Model) *stateInterner {
+	if model.Hash == nil {
+		return nil
+	}
+	return &stateInterner{model: model, byHash: make(map[uint64][]int)}
+}
+
+// intern returns the ID of the given state, along with the canonical copy of
+// it, which should be used in its place. On a nil receiver, it returns an ID
+// of -1 and the state itself.
+func (in *stateInterner) intern(state interface{}) (int, interface{}) {
+	if in == nil {
+		return -1, state
+	}
+	hash := in.model.Hash(state)
+	for _, id := range in.byHash[hash] {
+		if in.model.Equal(state, in.states[id]) {
+			return id, in.states[id]
+		}
+	}
+	id := len(in.states)
+	in.states = append(in.states, state)
+	in.byHash[hash] = append(in.byHash[hash], id)
+	return id, state
+}
@@ -0,0 +1,120 @@
+package porcupine
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// A JUnitSuite is a check to include in a JUnit XML report; see
+// [WriteJUnitReport].
+type JUnitSuite struct {
+	// Name of the test suite, e.g., the name of the test or of the model.
+	Name   string
+	Report Report
+	// Files to attach to failing test cases, e.g., a visualization written
+	// with [VisualizePath] or a saved history, so that they are linked from
+	// the CI system's test report.
+	Attachments []string
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Errors   int              `xml:"errors,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Errors   int             `xml:"errors,attr"`
+	Time     float64         `xml:"time,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Error     *junitMessage `xml:"error,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// WriteJUnitReport writes the results of checks as JUnit XML, so that CI
+// systems such as Jenkins and GitLab show linearizability failures as test
+// failures. Each suite has a test case per partition, or a single test case
+// if its report has no partitions (i.e., it comes from a check that wasn't
+// verbose).
+//
+// An Illegal test case is reported as a failure, and an Unknown one (from a
+// check that timed out) as an error. Attachments are listed in the output of
+// failing test cases in the form [[ATTACHMENT|path]], which Jenkins and
+// GitLab turn into links.
+func WriteJUnitReport(w io.Writer, suites ...JUnitSuite) error {
+	doc := junitTestSuites{Suites: make([]junitTestSuite, len(suites))}
+	for i, suite := range suites {
+		ts := junitTestSuite{Name: suite.Name, Time: suite.Report.Elapsed.Seconds()}
+		partitions := suite.Report.Partitions
+		if len(partitions) == 0 {
+			partitions = []PartitionReport{{Result: suite.Report.Result}}
+		}
+		for p, partition := range partitions {
+			tc := junitTestCase{Name: suite.Name, ClassName: suite.Name}
+			if len(suite.Report.Partitions) > 0 {
+				tc.Name = fmt.Sprintf("partition %d", p)
+			}
+			switch partition.Result {
+			case Illegal:
+				tc.Failure = &junitMessage{Type: "Illegal", Message: "history is not linearizable", Text: partitionSummary(partition)}
+				ts.Failures++
+			case Unknown:
+				tc.Error = &junitMessage{Type: "Unknown", Message: "check did not complete", Text: partitionSummary(partition)}
+				ts.Errors++
+			}
+			if partition.Result != Ok && len(suite.Attachments) > 0 {
+				var out strings.Builder
+				for _, path := range suite.Attachments {
+					fmt.Fprintf(&out, "[[ATTACHMENT|%s]]\n", path)
+				}
+				tc.SystemOut = out.String()
+			}
+			ts.Cases = append(ts.Cases, tc)
+		}
+		ts.Tests = len(ts.Cases)
+		doc.Tests += ts.Tests
+		doc.Failures += ts.Failures
+		doc.Errors += ts.Errors
+		doc.Suites[i] = ts
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func partitionSummary(p PartitionReport) string {
+	if p.Operations == 0 {
+		return ""
+	}
+	longest := 0
+	if len(p.PartialLinearizations) > 0 {
+		longest = p.PartialLinearizations[0]
+	}
+	return fmt.Sprintf("longest partial linearization has %d of %d operations", longest, p.Operations)
+}
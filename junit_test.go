@@ -0,0 +1,59 @@
+package porcupine
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteJUnitReport(t *testing.T) {
+	ops := []Operation{
+		{0, kvInput{op: 1, key: "x", value: "a"}, 0, kvOutput{}, 10},
+		{1, kvInput{op: 0, key: "x"}, 20, kvOutput{"b"}, 30},
+		{0, kvInput{op: 1, key: "y", value: "b"}, 20, kvOutput{}, 30},
+	}
+	res, info := CheckOperationsVerbose(kvModel, ops, 0)
+	suites := []JUnitSuite{
+		{Name: "kv", Report: NewReport(res, info, 2*time.Second), Attachments: []string{"out/kv.html"}},
+		{Name: "register", Report: NewReport(Unknown, LinearizationInfo{}, time.Second)},
+	}
+	var buf bytes.Buffer
+	if err := WriteJUnitReport(&buf, suites...); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	if !strings.HasPrefix(out, xml.Header) {
+		t.Fatal("missing XML header")
+	}
+	var doc junitTestSuites
+	if err := xml.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.Tests != 3 || doc.Failures != 1 || doc.Errors != 1 {
+		t.Fatalf("unexpected totals %+v", doc)
+	}
+	kv := doc.Suites[0]
+	if kv.Name != "kv" || kv.Time != 2 || len(kv.Cases) != 2 {
+		t.Fatalf("unexpected suite %+v", kv)
+	}
+	var failed, passed junitTestCase
+	for _, tc := range kv.Cases {
+		if tc.Failure != nil {
+			failed = tc
+		} else {
+			passed = tc
+		}
+	}
+	if failed.Failure == nil || failed.Failure.Text != "longest partial linearization has 1 of 2 operations" {
+		t.Fatalf("unexpected failing test case %+v", failed)
+	}
+	if failed.SystemOut != "[[ATTACHMENT|out/kv.html]]\n" || passed.SystemOut != "" {
+		t.Fatalf("unexpected attachments %q, %q", failed.SystemOut, passed.SystemOut)
+	}
+	register := doc.Suites[1]
+	if len(register.Cases) != 1 || register.Cases[0].Name != "register" || register.Cases[0].Error == nil {
+		t.Fatalf("unexpected suite %+v", register)
+	}
+}
@@ -0,0 +1,527 @@
+package porcupine
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// An EDNKeyword is an EDN keyword, like :ok, without the leading colon.
+type EDNKeyword string
+
+// A KnossosOp is an operation in a Knossos (or Jepsen) history: a map like
+//
+//	{:process 0, :type :invoke, :f :write, :value 3, :time 1000}
+//
+// EDN values are represented as follows: integers as int64 (or *big.Int if
+// they don't fit), floats as float64, strings as string, keywords as
+// [EDNKeyword], symbols as string, nil as nil, booleans as bool, vectors,
+// lists, and sets as []interface{}, and maps as map[interface{}]interface{}.
+type KnossosOp struct {
+	Process int
+	Type    EDNKeyword // :invoke, :ok, :fail, or :info
+	F       interface{}
+	Value   interface{}
+	// Time in nanoseconds, or -1 if the op has none.
+	Time int64
+}
+
+// ReadKnossosHistory reads a history in the Knossos format: a sequence of op
+// maps, either at the top level (as in Jepsen's history.edn) or in a vector.
+// Ops whose process is not an integer, like the :nemesis's, are skipped, as
+// they are not client operations.
+func ReadKnossosHistory(r io.Reader) ([]KnossosOp, error) {
+	p := &ednParser{r: bufio.NewReader(decompressed(r))}
+	var ops []KnossosOp
+	add := func(v interface{}) error {
+		m, ok := v.(map[interface{}]interface{})
+		if !ok {
+			return fmt.Errorf("op %d: expected a map, got %T", len(ops), v)
+		}
+		process, ok := m[EDNKeyword("process")].(int64)
+		if !ok {
+			return nil
+		}
+		op := KnossosOp{Process: int(process), F: m[EDNKeyword("f")], Value: m[EDNKeyword("value")], Time: -1}
+		if op.Type, ok = m[EDNKeyword("type")].(EDNKeyword); !ok {
+			return fmt.Errorf("op %d: missing :type", len(ops))
+		}
+		if t, ok := m[EDNKeyword("time")].(int64); ok {
+			op.Time = t
+		}
+		ops = append(ops, op)
+		return nil
+	}
+	for {
+		v, err := p.read()
+		if err == io.EOF {
+			return ops, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if v == (ednDiscard{}) {
+			continue
+		}
+		if list, ok := v.([]interface{}); ok {
+			for _, elem := range list {
+				if err := add(elem); err != nil {
+					return nil, err
+				}
+			}
+			continue
+		}
+		if err := add(v); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// WriteKnossosHistory writes a history in the Knossos format, one op map per
+// line, leaving out :time for ops that have none.
+func WriteKnossosHistory(w io.Writer, history []KnossosOp) error {
+	bw := bufio.NewWriter(w)
+	for i, op := range history {
+		var b strings.Builder
+		fmt.Fprintf(&b, "{:process %d, :type :%s, :f ", op.Process, op.Type)
+		if err := writeEDN(&b, op.F); err != nil {
+			return fmt.Errorf("op %d: %v", i, err)
+		}
+		b.WriteString(", :value ")
+		if err := writeEDN(&b, op.Value); err != nil {
+			return fmt.Errorf("op %d: %v", i, err)
+		}
+		if op.Time >= 0 {
+			fmt.Fprintf(&b, ", :time %d", op.Time)
+		}
+		b.WriteString("}\n")
+		if _, err := bw.WriteString(b.String()); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// KnossosEvents converts a Knossos history to a history of events, for
+// checking with a model whose inputs and outputs are KnossosOp values: the
+// value of a call event is the :invoke op, and the value of a return event is
+// the op that completed it.
+//
+// As in Knossos, an operation that completed with :fail did not take effect,
+// so it is left out of the history, and an operation that completed with
+// :info (or not at all) may or may not have taken effect, so it returns at
+// the end of the history, with the :info op (or the :invoke op, if there is
+// none) as its output. Models should accept any outcome for such operations.
+func KnossosEvents(history []KnossosOp) ([]Event, error) {
+	type pending struct {
+		call    KnossosOp
+		index   int // of the call event
+		id      int
+		outcome *KnossosOp
+	}
+	var events []Event
+	var indeterminate []*pending
+	inProgress := make(map[int]*pending)
+	failed := make(map[int]bool) // call event indices to drop
+	id := 0
+	for i, op := range history {
+		p := inProgress[op.Process]
+		switch op.Type {
+		case "invoke":
+			if p != nil {
+				return nil, fmt.Errorf("op %d: process %d invoked an operation while another was in progress", i, op.Process)
+			}
+			p = &pending{call: op, index: len(events), id: id}
+			id++
+			inProgress[op.Process] = p
+			events = append(events, Event{ClientId: op.Process, Kind: CallEvent, Value: op, Id: p.id})
+		case "ok", "fail", "info":
+			if p == nil {
+				return nil, fmt.Errorf("op %d: process %d completed an operation without invoking one", i, op.Process)
+			}
+			delete(inProgress, op.Process)
+			switch op.Type {
+			case "ok":
+				events = append(events, Event{ClientId: op.Process, Kind: ReturnEvent, Value: op, Id: p.id})
+			case "fail":
+				failed[p.index] = true
+			case "info":
+				outcome := op
+				p.outcome = &outcome
+				indeterminate = append(indeterminate, p)
+			}
+		default:
+			return nil, fmt.Errorf("op %d: unknown type :%s", i, op.Type)
+		}
+	}
+	// operations that never completed are indeterminate too
+	var stillPending []*pending
+	for _, p := range inProgress {
+		stillPending = append(stillPending, p)
+	}
+	sort.Slice(stillPending, func(i, j int) bool {
+		return stillPending[i].index < stillPending[j].index
+	})
+	indeterminate = append(indeterminate, stillPending...)
+	for _, p := range indeterminate {
+		outcome := p.call
+		if p.outcome != nil {
+			outcome = *p.outcome
+		}
+		events = append(events, Event{ClientId: p.call.Process, Kind: ReturnEvent, Value: outcome, Id: p.id})
+	}
+	if len(failed) == 0 {
+		return events, nil
+	}
+	// drop failed operations, and renumber so that IDs stay dense
+	var kept []Event
+	ids := make(map[int]int)
+	for i, e := range events {
+		if failed[i] {
+			continue
+		}
+		if _, ok := ids[e.Id]; !ok {
+			ids[e.Id] = len(ids)
+		}
+		e.Id = ids[e.Id]
+		kept = append(kept, e)
+	}
+	return kept, nil
+}
+
+// KnossosHistory converts a history of operations to a Knossos history, with
+// an :invoke and an :ok op for each operation, in order of time. The convert
+// function gives an operation's :f, and the :value of its :invoke and :ok ops.
+// Operation times are used as :time, and client IDs as :process.
+func KnossosHistory(history []Operation, convert func(input, output interface{}) (f, invokeValue, okValue interface{})) []KnossosOp {
+	type timed struct {
+		op   KnossosOp
+		time int64
+		ret  bool
+	}
+	ops := make([]timed, 0, 2*len(history))
+	for _, op := range history {
+		f, invokeValue, okValue := convert(op.Input, op.Output)
+		ops = append(ops,
+			timed{KnossosOp{Process: op.ClientId, Type: "invoke", F: f, Value: invokeValue, Time: op.Call}, op.Call, false},
+			timed{KnossosOp{Process: op.ClientId, Type: "ok", F: f, Value: okValue, Time: op.Return}, op.Return, true})
+	}
+	// calls before returns at the same time, as in the checker, so that
+	// operations that touch are concurrent
+	sort.SliceStable(ops, func(i, j int) bool {
+		if ops[i].time != ops[j].time {
+			return ops[i].time < ops[j].time
+		}
+		return !ops[i].ret && ops[j].ret
+	})
+	result := make([]KnossosOp, len(ops))
+	for i, op := range ops {
+		result[i] = op.op
+	}
+	return result
+}
+
+func writeEDN(b *strings.Builder, v interface{}) error {
+	switch v := v.(type) {
+	case nil:
+		b.WriteString("nil")
+	case bool:
+		b.WriteString(strconv.FormatBool(v))
+	case int:
+		b.WriteString(strconv.Itoa(v))
+	case int64:
+		b.WriteString(strconv.FormatInt(v, 10))
+	case *big.Int:
+		b.WriteString(v.String())
+		b.WriteByte('N')
+	case float64:
+		s := strconv.FormatFloat(v, 'g', -1, 64)
+		if !strings.ContainsAny(s, ".eEIN") {
+			s += ".0"
+		}
+		b.WriteString(s)
+	case string:
+		b.WriteString(strconv.Quote(v))
+	case EDNKeyword:
+		b.WriteByte(':')
+		b.WriteString(string(v))
+	case []interface{}:
+		b.WriteByte('[')
+		for i, elem := range v {
+			if i > 0 {
+				b.WriteByte(' ')
+			}
+			if err := writeEDN(b, elem); err != nil {
+				return err
+			}
+		}
+		b.WriteByte(']')
+	case map[interface{}]interface{}:
+		// sort entries for deterministic output
+		entries := make([]string, 0, len(v))
+		for k, val := range v {
+			var entry strings.Builder
+			if err := writeEDN(&entry, k); err != nil {
+				return err
+			}
+			entry.WriteByte(' ')
+			if err := writeEDN(&entry, val); err != nil {
+				return err
+			}
+			entries = append(entries, entry.String())
+		}
+		sort.Strings(entries)
+		b.WriteByte('{')
+		b.WriteString(strings.Join(entries, ", "))
+		b.WriteByte('}')
+	default:
+		return fmt.Errorf("cannot write %T as EDN", v)
+	}
+	return nil
+}
+
+// ednParser reads the subset of EDN used in Knossos and Jepsen histories.
+type ednParser struct {
+	r *bufio.Reader
+}
+
+var errEDNClose = errors.New("unexpected closing delimiter")
+
+// ednDiscard is read for a form discarded with #_.
+type ednDiscard struct{}
+
+// read reads the next value, returning io.EOF at the end of the input.
+func (p *ednParser) read() (interface{}, error) {
+	c, err := p.skip()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case c == '{':
+		return p.readMap()
+	case c == '[' || c == '(':
+		return p.readSeq(map[rune]rune{'[': ']', '(': ')'}[c])
+	case c == '}' || c == ']' || c == ')':
+		return nil, errEDNClose
+	case c == '"':
+		return p.readString()
+	case c == ':':
+		tok, err := p.token()
+		return EDNKeyword(tok), err
+	case c == '\\':
+		tok, err := p.token()
+		if err != nil {
+			return nil, err
+		}
+		if tok == "" {
+			// a single punctuation character
+			r, _, err := p.r.ReadRune()
+			return string(r), err
+		}
+		switch tok {
+		case "newline":
+			return "\n", nil
+		case "space":
+			return " ", nil
+		case "tab":
+			return "\t", nil
+		}
+		return tok, nil
+	case c == '#':
+		next, _, err := p.r.ReadRune()
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		switch next {
+		case '{':
+			return p.readSeq('}')
+		case '_':
+			if _, err := p.read(); err != nil {
+				return nil, unexpectedEOF(err)
+			}
+			return ednDiscard{}, nil
+		}
+		// a tagged literal, like #inst "...", read as its value
+		p.r.UnreadRune()
+		if _, err := p.token(); err != nil {
+			return nil, err
+		}
+		v, err := p.read()
+		return v, unexpectedEOF(err)
+	}
+	p.r.UnreadRune()
+	tok, err := p.token()
+	if err != nil {
+		return nil, err
+	}
+	return ednAtom(tok)
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// skip skips whitespace, commas, and comments, and returns the next rune.
+func (p *ednParser) skip() (rune, error) {
+	for {
+		c, _, err := p.r.ReadRune()
+		if err != nil {
+			return 0, err
+		}
+		switch {
+		case c == ';':
+			if _, err := p.r.ReadString('\n'); err != nil {
+				return 0, err
+			}
+		case c == ',' || unicode.IsSpace(c):
+		default:
+			return c, nil
+		}
+	}
+}
+
+// token reads a run of characters up to a delimiter.
+func (p *ednParser) token() (string, error) {
+	var b strings.Builder
+	for {
+		c, _, err := p.r.ReadRune()
+		if err == io.EOF {
+			return b.String(), nil
+		}
+		if err != nil {
+			return "", err
+		}
+		if c == ',' || c == ';' || c == '"' || unicode.IsSpace(c) || strings.ContainsRune("{}[]()", c) {
+			p.r.UnreadRune()
+			return b.String(), nil
+		}
+		b.WriteRune(c)
+	}
+}
+
+func ednAtom(tok string) (interface{}, error) {
+	switch tok {
+	case "nil":
+		return nil, nil
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	}
+	if tok == "" {
+		return nil, errors.New("unexpected character")
+	}
+	if c := tok[0]; c >= '0' && c <= '9' || (c == '-' || c == '+') && len(tok) > 1 && tok[1] >= '0' && tok[1] <= '9' {
+		// N and M mark arbitrary-precision integers and decimals
+		tok = strings.TrimRight(tok, "NM")
+		if !strings.ContainsAny(tok, ".eE") {
+			n, ok := new(big.Int).SetString(strings.TrimPrefix(tok, "+"), 10)
+			if !ok {
+				return nil, fmt.Errorf("invalid number %s", tok)
+			}
+			if n.IsInt64() {
+				return n.Int64(), nil
+			}
+			return n, nil
+		}
+		f, err := strconv.ParseFloat(tok, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %s", tok)
+		}
+		return f, nil
+	}
+	// a symbol
+	return tok, nil
+}
+
+func (p *ednParser) readString() (string, error) {
+	var b strings.Builder
+	for {
+		c, _, err := p.r.ReadRune()
+		if err != nil {
+			return "", unexpectedEOF(err)
+		}
+		switch c {
+		case '"':
+			return b.String(), nil
+		case '\\':
+			e, _, err := p.r.ReadRune()
+			if err != nil {
+				return "", unexpectedEOF(err)
+			}
+			switch e {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			case 'r':
+				b.WriteByte('\r')
+			case 'u':
+				var hex [4]byte
+				if _, err := io.ReadFull(p.r, hex[:]); err != nil {
+					return "", unexpectedEOF(err)
+				}
+				r, err := strconv.ParseUint(string(hex[:]), 16, 32)
+				if err != nil {
+					return "", fmt.Errorf("invalid escape \\u%s", hex[:])
+				}
+				b.WriteRune(rune(r))
+			default:
+				b.WriteRune(e)
+			}
+		default:
+			b.WriteRune(c)
+		}
+	}
+}
+
+// readSeq reads the elements of a vector, list, or set up to the closing
+// delimiter.
+func (p *ednParser) readSeq(close rune) ([]interface{}, error) {
+	elems := []interface{}{}
+	for {
+		c, err := p.skip()
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		if c == close {
+			return elems, nil
+		}
+		p.r.UnreadRune()
+		v, err := p.read()
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		if v != (ednDiscard{}) {
+			elems = append(elems, v)
+		}
+	}
+}
+
+func (p *ednParser) readMap() (map[interface{}]interface{}, error) {
+	elems, err := p.readSeq('}')
+	if err != nil {
+		return nil, err
+	}
+	if len(elems)%2 != 0 {
+		return nil, errors.New("map with an odd number of forms")
+	}
+	m := make(map[interface{}]interface{}, len(elems)/2)
+	for i := 0; i < len(elems); i += 2 {
+		switch elems[i].(type) {
+		case []interface{}, map[interface{}]interface{}, *big.Int:
+			return nil, fmt.Errorf("unsupported map key type %T", elems[i])
+		}
+		m[elems[i]] = elems[i+1]
+	}
+	return m, nil
+}
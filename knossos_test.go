@@ -0,0 +1,179 @@
+package porcupine
+
+import (
+	"bytes"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// a register model over Knossos ops, with :read, :write, and :cas, where
+// indeterminate operations may or may not have happened
+var knossosRegisterModel = NondeterministicModel{
+	Init: func() []interface{} {
+		return []interface{}{nil}
+	},
+	Step: func(state, input, output interface{}) []interface{} {
+		inp := input.(KnossosOp)
+		out := output.(KnossosOp)
+		indeterminate := out.Type != "ok"
+		switch inp.F {
+		case EDNKeyword("read"):
+			if indeterminate || out.Value == nil || out.Value == state {
+				return []interface{}{state}
+			}
+			return nil
+		case EDNKeyword("write"):
+			if indeterminate {
+				return []interface{}{state, inp.Value}
+			}
+			return []interface{}{inp.Value}
+		case EDNKeyword("cas"):
+			args := inp.Value.([]interface{})
+			if state != args[0] {
+				if indeterminate {
+					return []interface{}{state}
+				}
+				return nil
+			}
+			if indeterminate {
+				return []interface{}{state, args[1]}
+			}
+			return []interface{}{args[1]}
+		}
+		return nil
+	},
+}
+
+const knossosHistory = `; a Jepsen history
+{:process 0, :type :invoke, :f :write, :value 1, :time 10}
+{:process 1, :type :invoke, :f :read, :value nil}
+{:process 0, :type :ok, :f :write, :value 1}
+{:process :nemesis, :type :info, :f :start, :value "partition"}
+{:process 1, :type :ok, :f :read, :value 1}
+{:process 2, :type :invoke, :f :cas, :value [1 2]}
+{:process 2, :type :info, :f :cas, :value [1 2]}
+{:process 3, :type :invoke, :f :write, :value 5}
+{:process 3, :type :fail, :f :write, :value 5}
+{:process 4, :type :invoke, :f :read, :value nil}
+{:process 4, :type :ok, :f :read, :value 2}
+`
+
+func TestReadKnossosHistory(t *testing.T) {
+	ops, err := ReadKnossosHistory(strings.NewReader(knossosHistory))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ops) != 10 {
+		t.Fatalf("expected 10 ops, got %d", len(ops))
+	}
+	expected := KnossosOp{Process: 0, Type: "invoke", F: EDNKeyword("write"), Value: int64(1), Time: 10}
+	if !reflect.DeepEqual(expected, ops[0]) {
+		t.Fatalf("expected %v, got %v", expected, ops[0])
+	}
+	if !reflect.DeepEqual([]interface{}{int64(1), int64(2)}, ops[4].Value) || ops[4].Time != -1 {
+		t.Fatalf("unexpected op %v", ops[4])
+	}
+
+	events, err := KnossosEvents(ops)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ValidateEvents(events); err != nil {
+		t.Fatal(err)
+	}
+	// the failed write is dropped
+	if len(events) != 8 {
+		t.Fatalf("expected 8 events, got %d", len(events))
+	}
+	// the indeterminate cas returns last
+	if last := events[len(events)-1]; last.ClientId != 2 || last.Value.(KnossosOp).Type != "info" {
+		t.Fatalf("unexpected last event %v", last)
+	}
+	if !CheckEvents(knossosRegisterModel.ToModel(), events) {
+		t.Fatal("expected history to be linearizable")
+	}
+
+	// without the cas, reading 2 is not linearizable
+	ops = append(ops[:4:4], ops[6:]...)
+	events, err = KnossosEvents(ops)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if CheckEvents(knossosRegisterModel.ToModel(), events) {
+		t.Fatal("expected history to not be linearizable")
+	}
+}
+
+func TestKnossosRoundTrip(t *testing.T) {
+	ops := []KnossosOp{
+		{Process: 0, Type: "invoke", F: EDNKeyword("write"), Value: "a \"quoted\"\nvalue", Time: -1},
+		{Process: 0, Type: "ok", F: EDNKeyword("write"), Value: []interface{}{1.5, true, nil, big.NewInt(0).Lsh(big.NewInt(1), 70)}, Time: 5},
+		{Process: 1, Type: "info", F: "txn", Value: map[interface{}]interface{}{EDNKeyword("k"): int64(-3)}, Time: -1},
+	}
+	var buf bytes.Buffer
+	if err := WriteKnossosHistory(&buf, ops); err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := ReadKnossosHistory(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(ops, decoded) {
+		t.Fatalf("expected %v, got %v", ops, decoded)
+	}
+
+	// a vector of ops, with other EDN syntax
+	decoded, err = ReadKnossosHistory(strings.NewReader(`[{:process 0 :type :invoke :f :add :value #{1 2} #_ :ignored :time 7 #_[]}
+	  {:process 0, :type :ok, :f :add, :value (1 2), :at #inst "2024-01-01T00:00:00Z"}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded) != 2 || decoded[0].Time != 7 || !reflect.DeepEqual([]interface{}{int64(1), int64(2)}, decoded[1].Value) {
+		t.Fatalf("unexpected ops %v", decoded)
+	}
+}
+
+func TestKnossosHistory(t *testing.T) {
+	history := []Operation{
+		{0, registerInput{false, 1}, 0, 0, 10},
+		{1, registerInput{true, 0}, 10, 1, 20},
+	}
+	ops := KnossosHistory(history, func(input, output interface{}) (interface{}, interface{}, interface{}) {
+		inp := input.(registerInput)
+		if inp.op {
+			return EDNKeyword("read"), nil, int64(output.(int))
+		}
+		return EDNKeyword("write"), int64(inp.value), int64(inp.value)
+	})
+	types := make([]EDNKeyword, len(ops))
+	for i, op := range ops {
+		types[i] = op.Type
+	}
+	// the read is called at the same time as the write returns
+	if !reflect.DeepEqual([]EDNKeyword{"invoke", "invoke", "ok", "ok"}, types) {
+		t.Fatalf("unexpected op types %v", types)
+	}
+	events, err := KnossosEvents(ops)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !CheckEvents(knossosRegisterModel.ToModel(), events) {
+		t.Fatal("expected history to be linearizable")
+	}
+}
+
+func TestReadKnossosHistoryError(t *testing.T) {
+	for _, input := range []string{
+		`{:process 0, :type :invoke`,
+		`{:process 0 :type}`,
+		`[1 2]`,
+		`{:process 0, :value 1}`,
+		`"unterminated`,
+	} {
+		if _, err := ReadKnossosHistory(strings.NewReader(input)); err == nil {
+			t.Errorf("expected an error for %q", input)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package porcupine
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// liveOperations is how many of the most recent operations the
+	// visualization from VisualizeHandler shows.
+	liveOperations = 10000
+	// liveRefresh is how often the pages from VisualizeHandler and
+	// ProgressHandler reload themselves.
+	liveRefresh = 2 * time.Second
+)
+
+// VisualizeHandler returns an HTTP handler that serves a visualization of the
+// history that an [OnlineChecker] has been given so far, which reloads itself
+// every few seconds, so that operators can watch a long-running test as it
+// happens. The zoom and scroll position are kept across reloads.
+//
+// To bound its memory use, the visualization only shows the 10,000 most
+// recently added operations, starting from when the handler was created. The
+// checker's watermark is shown as an annotation, as is the violation, if one
+// has been found. Because an online checker doesn't find linearizations, no
+// linearization points are shown. Partitions are described using the model's
+// DescribePartition function, or else by their key.
+func VisualizeHandler(model Model, checker *OnlineChecker) http.Handler {
+	checker.mu.Lock()
+	if checker.keepRecent == 0 {
+		checker.keepRecent = liveOperations
+	}
+	checker.mu.Unlock()
+	if model.DescribePartition == nil && checker.opts.PartitionKey != nil {
+		model.DescribePartition = func(history []Operation) string {
+			if len(history) == 0 {
+				return ""
+			}
+			return checker.opts.PartitionKey(history[0].Input)
+		}
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		info := checker.snapshot()
+		var buf bytes.Buffer
+		if err := writeVisualization(computeVisualizationData(model, info), &buf, VisualizeOptions{}, liveRefresh); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		w.Write(buf.Bytes())
+	})
+}
+
+// ProgressHandler returns a callback that records the progress of a check,
+// for [WithProgress] or [CheckOperationsProgress], and an HTTP handler that
+// serves a page showing the latest progress it recorded, including the
+// estimated time remaining (see [Progress]). The page reloads itself every
+// few seconds until the check is done, so that operators can tell whether a
+// long-running check is going to finish.
+func ProgressHandler() (func(Progress), http.Handler) {
+	var mu sync.Mutex
+	var latest *Progress
+	update := func(p Progress) {
+		mu.Lock()
+		latest = &p
+		mu.Unlock()
+	}
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		mu.Lock()
+		var p *Progress
+		if latest != nil {
+			copied := *latest
+			p = &copied
+		}
+		mu.Unlock()
+		var buf bytes.Buffer
+		writeProgressPage(&buf, p)
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		w.Write(buf.Bytes())
+	})
+	return update, handler
+}
+
+// writeProgressPage writes the page that ProgressHandler serves, for the
+// given progress, or nil if none has been recorded yet.
+func writeProgressPage(buf *bytes.Buffer, p *Progress) {
+	buf.WriteString("<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n")
+	if p == nil || !p.Done {
+		fmt.Fprintf(buf, "<meta http-equiv=\"refresh\" content=\"%d\">\n", int(liveRefresh.Seconds()))
+	}
+	buf.WriteString("<title>Check progress</title>\n</head>\n<body>\n")
+	if p == nil {
+		buf.WriteString("<p>The check has not reported any progress yet.</p>\n</body>\n</html>\n")
+		return
+	}
+	eta := "unknown"
+	switch {
+	case p.Done:
+		eta = "done"
+	case p.Confidence > 0:
+		eta = fmt.Sprintf("%v (confidence %.0f%%)", p.ETA.Round(time.Second), 100*p.Confidence)
+	}
+	buf.WriteString("<table>\n")
+	rows := [][2]string{
+		{"Elapsed", p.Elapsed.Round(time.Second).String()},
+		{"Partitions checked", fmt.Sprintf("%d of %d", p.PartitionsDone, p.Partitions)},
+		{"Operations linearized", fmt.Sprintf("%d of %d", p.Frontier, p.Operations)},
+		{"Search steps", fmt.Sprintf("%d", p.Steps)},
+		{"Time remaining", eta},
+	}
+	for _, row := range rows {
+		fmt.Fprintf(buf, "<tr><th>%s</th><td>%s</td></tr>\n", row[0], row[1])
+	}
+	buf.WriteString("</table>\n</body>\n</html>\n")
+}
+
+// snapshot returns the recent history of the checker, partitioned by key, for
+// VisualizeHandler.
+func (c *OnlineChecker) snapshot() LinearizationInfo {
+	c.mu.Lock()
+	recent := c.recent
+	if len(recent) > c.keepRecent {
+		recent = recent[len(recent)-c.keepRecent:]
+	}
+	recent = append([]Operation(nil), recent...)
+	watermark := c.watermark
+	var violation *Operation
+	if c.violation != nil {
+		v := *c.violation
+		violation = &v
+	}
+	c.mu.Unlock()
+
+	byKey := make(map[string][]Operation)
+	for _, op := range recent {
+		key := ""
+		if c.opts.PartitionKey != nil {
+			key = c.opts.PartitionKey(op.Input)
+		}
+		byKey[key] = append(byKey[key], op)
+	}
+	keys := make([]string, 0, len(byKey))
+	for key := range byKey {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var info LinearizationInfo
+	for _, key := range keys {
+		info.history = append(info.history, makeEntries(byKey[key], nil))
+		info.partialLinearizations = append(info.partialLinearizations, nil)
+	}
+	if watermark != math.MinInt64 && watermark != math.MaxInt64 && len(recent) > 0 {
+		info.annotations = append(info.annotations, Annotation{
+			Tag:         "Online checker",
+			Start:       watermark,
+			End:         watermark,
+			Description: "watermark",
+			Details:     fmt.Sprintf("operations invoked before %d have been checked", watermark),
+		})
+	}
+	if violation != nil {
+		info.annotations = append(info.annotations, Annotation{
+			ClientId:        violation.ClientId,
+			Start:           violation.Call,
+			End:             violation.Return,
+			Description:     "violation",
+			Details:         "the history is not linearizable once this operation returns",
+			BackgroundColor: "#ff9191",
+		})
+	}
+	return info
+}
@@ -0,0 +1,116 @@
+package porcupine
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVisualizeHandler(t *testing.T) {
+	checker := NewOnlineChecker(registerModel, OnlineOptions{})
+	server := httptest.NewServer(VisualizeHandler(registerModel, checker))
+	defer server.Close()
+
+	get := func() string {
+		resp, err := http.Get(server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, resp.StatusCode)
+		}
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(body)
+	}
+
+	if page := get(); !strings.Contains(page, "window.location.reload()") {
+		t.Fatal("expected the page to reload itself")
+	}
+	// put(1), then a get that sees the initial value
+	checker.Add(Operation{0, registerInput{false, 1}, 0, 0, 10})
+	checker.Advance(15)
+	page := get()
+	if !strings.Contains(page, "put('1')") || !strings.Contains(page, "watermark") {
+		t.Fatal("expected the page to show the operation and the watermark")
+	}
+	if strings.Contains(page, "violation") {
+		t.Fatal("expected no violation to be shown")
+	}
+	checker.Add(Operation{1, registerInput{true, 0}, 20, 0, 30})
+	checker.Advance(40)
+	if page := get(); !strings.Contains(page, "get() -\\u003e '0'") || !strings.Contains(page, "violation") {
+		t.Fatal("expected the page to show the violation")
+	}
+
+	resp, err := http.Post(server.URL, "text/plain", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Fatalf("expected status %d, got %d", http.StatusMethodNotAllowed, resp.StatusCode)
+	}
+}
+
+func TestVisualizeHandlerKeepsRecentOperations(t *testing.T) {
+	checker := NewOnlineChecker(kvModel, OnlineOptions{PartitionKey: kvKey})
+	VisualizeHandler(kvModel, checker)
+	for i := 0; i < 3*liveOperations; i++ {
+		checker.Add(Operation{0, kvInput{op: 1, key: "x", value: "v"}, int64(2 * i), kvOutput{}, int64(2*i + 1)})
+	}
+	info := checker.snapshot()
+	if len(info.history) != 1 || len(info.history[0]) != 2*liveOperations {
+		t.Fatalf("expected the %d most recent operations", liveOperations)
+	}
+	if first := info.history[0][0].time; first != int64(4*liveOperations) {
+		t.Fatalf("expected the earliest operation shown to be invoked at %d, got %d", 4*liveOperations, first)
+	}
+}
+
+func TestProgressHandler(t *testing.T) {
+	update, handler := ProgressHandler()
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	get := func() string {
+		resp, err := http.Get(server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(body)
+	}
+
+	if page := get(); !strings.Contains(page, "not reported") || !strings.Contains(page, "refresh") {
+		t.Fatal("expected the page to reload itself until the check reports progress")
+	}
+	update(Progress{Partitions: 2, Operations: 10, Frontier: 4, ETA: 90 * time.Second, Confidence: 0.5})
+	if page := get(); !strings.Contains(page, "1m30s (confidence 50%)") || !strings.Contains(page, "4 of 10") {
+		t.Fatalf("expected the page to show the ETA, got %q", page)
+	}
+	ops := []Operation{
+		{0, registerInput{false, 1}, 0, 0, 10},
+		{1, registerInput{true, 0}, 20, 1, 30},
+	}
+	if res, _ := CheckHistory(registerModel, ops, WithProgress(update)); res != Ok {
+		t.Fatalf("expected output %v, got output %v", Ok, res)
+	}
+	page := get()
+	if !strings.Contains(page, "<td>done</td>") || !strings.Contains(page, "2 of 2") {
+		t.Fatalf("expected the page to show the finished check, got %q", page)
+	}
+	if strings.Contains(page, "refresh") {
+		t.Fatal("expected the page to stop reloading once the check is done")
+	}
+}
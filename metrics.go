@@ -0,0 +1,125 @@
+package porcupine
+
+import (
+	"runtime"
+	"time"
+)
+
+// Metrics describe the work that a linearizability check did, to help
+// understand why a history is slow to check; see [WithMetrics].
+type Metrics struct {
+	Elapsed time.Duration // wall-clock time the check took
+	// Number of heap objects allocated while the check ran. This is
+	// measured across the whole process, so it includes allocations by
+	// other goroutines.
+	Allocations uint64
+	Partitions  []PartitionMetrics
+}
+
+// PartitionMetrics describe the search of a single partition of a history.
+//
+// A partition whose search generates many more states than it has entries,
+// with few cache hits, has many concurrent operations that the model can
+// reorder; a finer partition function or a model Equal function that treats
+// more states as equal can help. A low hit rate combined with many
+// generated states can also mean that Equal is too strict.
+type PartitionMetrics struct {
+	Result  CheckResult // Unknown if the search was stopped
+	Entries int         // number of call and return entries
+	Steps   uint64      // number of search steps
+	// Number of times the model's Step function accepted an operation.
+	StatesGenerated uint64
+	// Number of generated states that had already been visited with the same
+	// operations linearized, and those that had not.
+	CacheHits   uint64
+	CacheMisses uint64
+	// Length of the longest partial linearization on the search stack.
+	MaxDepth int
+}
+
+// Total sums the metrics of every partition. The result is Illegal if any
+// partition is, and otherwise Unknown if any partition is, and the maximum
+// depth is the greatest of any partition.
+func (m Metrics) Total() PartitionMetrics {
+	total := PartitionMetrics{Result: Ok}
+	for _, p := range m.Partitions {
+		switch {
+		case p.Result == Illegal:
+			total.Result = Illegal
+		case p.Result == Unknown && total.Result == Ok:
+			total.Result = Unknown
+		}
+		total.Entries += p.Entries
+		total.Steps += p.Steps
+		total.StatesGenerated += p.StatesGenerated
+		total.CacheHits += p.CacheHits
+		total.CacheMisses += p.CacheMisses
+		if p.MaxDepth > total.MaxDepth {
+			total.MaxDepth = p.MaxDepth
+		}
+	}
+	return total
+}
+
+// A MetricsSink receives the metrics of checks run with [WithMetrics], e.g.,
+// to export them to a monitoring system.
+type MetricsSink interface {
+	// ReportMetrics is called once per check, when it finishes. Checks
+	// that share a sink, e.g., those of CheckBatch, may call it
+	// concurrently.
+	ReportMetrics(m Metrics)
+}
+
+// MetricsFunc adapts a function to a [MetricsSink].
+type MetricsFunc func(m Metrics)
+
+// ReportMetrics calls f(m).
+func (f MetricsFunc) ReportMetrics(m Metrics) {
+	f(m)
+}
+
+// metricsRecorder collects the metrics of a check.
+type metricsRecorder struct {
+	sink       MetricsSink
+	start      time.Time
+	mallocs    uint64
+	partitions []PartitionMetrics
+}
+
+func newMetricsRecorder(sink MetricsSink, partitions int) *metricsRecorder {
+	if sink == nil {
+		return nil
+	}
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	return &metricsRecorder{
+		sink:       sink,
+		start:      time.Now(),
+		mallocs:    stats.Mallocs,
+		partitions: make([]PartitionMetrics, partitions),
+	}
+}
+
+// partition returns the metrics of the i-th partition, for checkSingle to
+// fill in, or nil if metrics are not being collected.
+func (r *metricsRecorder) partition(i int) *PartitionMetrics {
+	if r == nil {
+		return nil
+	}
+	return &r.partitions[i]
+}
+
+// finish reports the metrics to the sink. It must only be called once every
+// partition's search has returned.
+func (r *metricsRecorder) finish() {
+	if r == nil {
+		return
+	}
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	r.sink.ReportMetrics(Metrics{
+		Elapsed:     time.Since(r.start),
+		Allocations: stats.Mallocs - r.mallocs,
+		Partitions:  r.partitions,
+	})
+}
@@ -66,6 +66,52 @@ type Event struct {
 	Id       int
 }
 
+// A TaggedOperation is an [Operation] along with tags: free-form metadata,
+// such as a request ID, the name of the node that served the request, or a
+// retry count.
+//
+// Tags do not affect linearizability checking. They are carried through to
+// visualizations, where they are shown in the tooltip for the operation, and
+// to the model's DescribeTaggedOperation function. Use
+// [CheckTaggedOperationsVerbose] to check a history of TaggedOperation.
+//
+// The [SessionTag] tag says which logical session an operation belongs to,
+// for clients that multiplex several sessions, e.g., over one connection.
+type TaggedOperation struct {
+	Operation
+	Tags map[string]string
+}
+
+// SessionTag is the tag that names the session that an operation belongs to.
+// Session-oriented checks, such as [CheckTaggedSessionGuarantees], are keyed
+// by session, and visualizations show each session on its own row. An
+// operation without the tag belongs to a session of its own client.
+const SessionTag = "session"
+
+// Session returns the session that the operation belongs to: its
+// [SessionTag] tag, if set, and otherwise a session named after its client.
+func (op TaggedOperation) Session() string {
+	return sessionOf(op.ClientId, op.Tags)
+}
+
+func sessionOf(clientId int, tags map[string]string) string {
+	if s := tags[SessionTag]; s != "" {
+		return s
+	}
+	return fmt.Sprintf("client %d", clientId)
+}
+
+// A TaggedEvent is an [Event] along with tags. The tags of a call event and
+// its matching return event are merged, with the return event's tags taking
+// precedence.
+//
+// See [TaggedOperation] for how tags are used, and use
+// [CheckTaggedEventsVerbose] to check a history of TaggedEvent.
+type TaggedEvent struct {
+	Event
+	Tags map[string]string
+}
+
 // A Model is a sequential specification of a system.
 //
 // Note: models in this package are expected to be purely functional. That is,
@@ -89,6 +135,9 @@ type Event struct {
 //
 // [test code]: https://github.com/anishathalye/porcupine/blob/master/porcupine_test.go
 type Model struct {
+	// Optional: a name for the model, used in failure signatures (see
+	// [LinearizationInfo.FailureSignature]).
+	Name string
 	// Partition functions, such that a history is linearizable if and only
 	// if each partition is linearizable. If left nil, this package will
 	// skip partitioning.
@@ -104,6 +153,11 @@ type Model struct {
 	// Equality on states. If left nil, this package will use == as a
 	// fallback ([ShallowEqual]).
 	Equal func(state1, state2 interface{}) bool
+	// Optional: a hash of a state, consistent with Equal: equal states
+	// must have equal hashes. The checker uses it to index the states it
+	// has visited, which avoids comparing large states with Equal when
+	// they differ.
+	Hash func(state interface{}) uint64
 	// For visualization, describe an operation as a string. For example,
 	// "Get('x') -> 'y'". Can be omitted if you're not producing
 	// visualizations.
@@ -112,6 +166,19 @@ type Model struct {
 	// example, "{'x' -> 'y', 'z' -> 'w'}". Can be omitted if you're not
 	// producing visualizations.
 	DescribeState func(state interface{}) string
+	// For visualization, describe an operation along with its tags (see
+	// [TaggedOperation]). If given, this is used instead of
+	// DescribeOperation. Can be omitted.
+	DescribeTaggedOperation func(input interface{}, output interface{}, tags map[string]string) string
+	// For visualization, describe a partition, given its operations, as a
+	// header for its lanes. For example, "key 'x'". If omitted,
+	// partitions are numbered.
+	DescribePartition func(history []Operation) string
+	// For failure signatures, describe the "shape" of an operation,
+	// leaving out details that vary from run to run, such as the values
+	// written or read. For example, "Put -> ok". If omitted, the shape is
+	// the Go types of the input and output.
+	OperationShape func(input interface{}, output interface{}) string
 }
 
 // A NondeterministicModel is a nondeterministic sequential specification of a
@@ -127,6 +194,9 @@ type Model struct {
 //
 // [test code]: https://github.com/anishathalye/porcupine/blob/master/porcupine_test.go
 type NondeterministicModel struct {
+	// Optional: a name for the model, used in failure signatures (see
+	// [LinearizationInfo.FailureSignature]).
+	Name string
 	// Partition functions, such that a history is linearizable if and only
 	// if each partition is linearizable. If left nil, this package will
 	// skip partitioning.
@@ -142,6 +212,9 @@ type NondeterministicModel struct {
 	// Equality on states. If left nil, this package will use == as a
 	// fallback ([ShallowEqual]).
 	Equal func(state1, state2 interface{}) bool
+	// Optional: a hash of a state, consistent with Equal; see
+	// [Model].
+	Hash func(state interface{}) uint64
 	// For visualization, describe an operation as a string. For example,
 	// "Get('x') -> 'y'". Can be omitted if you're not producing
 	// visualizations.
@@ -150,6 +223,19 @@ type NondeterministicModel struct {
 	// example, "{'x' -> 'y', 'z' -> 'w'}". Can be omitted if you're not
 	// producing visualizations.
 	DescribeState func(state interface{}) string
+	// For visualization, describe an operation along with its tags (see
+	// [TaggedOperation]). If given, this is used instead of
+	// DescribeOperation. Can be omitted.
+	DescribeTaggedOperation func(input interface{}, output interface{}, tags map[string]string) string
+	// For visualization, describe a partition, given its operations, as a
+	// header for its lanes. For example, "key 'x'". If omitted,
+	// partitions are numbered.
+	DescribePartition func(history []Operation) string
+	// For failure signatures, describe the "shape" of an operation,
+	// leaving out details that vary from run to run, such as the values
+	// written or read. For example, "Put -> ok". If omitted, the shape is
+	// the Go types of the input and output.
+	OperationShape func(input interface{}, output interface{}) string
 }
 
 func merge(states []interface{}, eq func(state1, state2 interface{}) bool) []interface{} {
@@ -192,6 +278,7 @@ func (nm *NondeterministicModel) ToModel() Model {
 		describeState = defaultDescribeState
 	}
 	return Model{
+		Name:           nm.Name,
 		Partition:      nm.Partition,
 		PartitionEvent: nm.PartitionEvent,
 		// we need this wrapper to convert a []interface{} to an interface{}
@@ -229,7 +316,11 @@ func (nm *NondeterministicModel) ToModel() Model {
 			}
 			return true
 		},
-		DescribeOperation: describeOperation,
+		Hash:                    hashStates(nm.Hash),
+		DescribeOperation:       describeOperation,
+		DescribeTaggedOperation: nm.DescribeTaggedOperation,
+		DescribePartition:       nm.DescribePartition,
+		OperationShape:          nm.OperationShape,
 		DescribeState: func(state interface{}) string {
 			states := state.([]interface{})
 			var descriptions []string
@@ -241,6 +332,23 @@ func (nm *NondeterministicModel) ToModel() Model {
 	}
 }
 
+// hashStates converts a hash function on states to one on the merged sets of
+// states used by [NondeterministicModel.ToModel]. The hash of a set doesn't
+// depend on the order of its elements, so that it is consistent with the
+// set's Equal function.
+func hashStates(hash func(state interface{}) uint64) func(state interface{}) uint64 {
+	if hash == nil {
+		return nil
+	}
+	return func(state interface{}) uint64 {
+		var h uint64
+		for _, s := range state.([]interface{}) {
+			h += mix64(hash(s))
+		}
+		return h
+	}
+}
+
 // noPartition is a fallback partition function that partitions the history
 // into a single partition containing all of the operations.
 func noPartition(history []Operation) [][]Operation {
@@ -265,6 +373,15 @@ func defaultDescribeOperation(input interface{}, output interface{}) string {
 	return fmt.Sprintf("%v -> %v", input, output)
 }
 
+// describeOperation describes an operation using the model's
+// DescribeTaggedOperation if it has one, and DescribeOperation otherwise.
+func describeOperation(model Model, input interface{}, output interface{}, tags map[string]string) string {
+	if model.DescribeTaggedOperation != nil {
+		return model.DescribeTaggedOperation(input, output, tags)
+	}
+	return model.DescribeOperation(input, output)
+}
+
 // defaultDescribeState is a fallback to convert a state to a string. It
 // renders the state using the "%v" format specifier.
 func defaultDescribeState(state interface{}) string {
@@ -275,14 +392,15 @@ func defaultDescribeState(state interface{}) string {
 //
 // Checking for linearizability is decidable, but it is an NP-hard problem, so
 // the checker might take a long time. If a timeout is not given, functions in
-// this package will always return Ok or Illegal, but if a timeout is supplied,
-// then some functions may return Unknown. Depending on the use case, you can
+// this package will always return Ok or Illegal, but if a timeout (or a
+// memory budget; see [WithMemoryBudget]) is supplied, then some functions may
+// return Unknown. Depending on the use case, you can
 // interpret an Unknown result as Ok (i.e., the tool didn't find a
 // linearizability violation within the given timeout).
 type CheckResult string
 
 const (
-	Unknown CheckResult = "Unknown" // timed out
+	Unknown CheckResult = "Unknown" // timed out or exceeded the memory budget
 	Ok      CheckResult = "Ok"
 	Illegal CheckResult = "Illegal"
 )
@@ -0,0 +1,524 @@
+package porcupine
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// This file implements a MessagePack encoding of histories, for shipping
+// them over the network more compactly than JSON. A history is a map with a
+// single key, "operations" or "events", holding an array of operations or
+// events, each an array of its fields:
+//
+//	operation: [client, input, call, output, return]
+//	event:     [client, kind, value, id]
+//
+// where kind is 0 for a call and 1 for a return. Inputs, outputs, and values
+// are encoded with a codec, as JSON, and then converted to the equivalent
+// MessagePack values, so they are compact and readable by other MessagePack
+// tools.
+
+// MarshalOperationsMsgpack encodes a history of operations as MessagePack,
+// encoding inputs and outputs with the given codec. If codec is nil, they are
+// encoded as JSON before conversion.
+func MarshalOperationsMsgpack(history []Operation, codec Codec) ([]byte, error) {
+	if codec == nil {
+		codec = &SchemaCodec{}
+	}
+	b := appendMsgpackMapHeader(nil, 1)
+	b = appendMsgpackString(b, "operations")
+	b = appendMsgpackArrayHeader(b, len(history))
+	for i, op := range history {
+		jop, err := encodeOperation(codec, op)
+		if err != nil {
+			return nil, fmt.Errorf("operation %d: %v", i, err)
+		}
+		b = appendMsgpackArrayHeader(b, 5)
+		b = appendMsgpackInt(b, int64(jop.Client))
+		if b, err = appendMsgpackJSON(b, jop.Input); err != nil {
+			return nil, fmt.Errorf("operation %d.input: %v", i, err)
+		}
+		b = appendMsgpackInt(b, jop.Call)
+		if b, err = appendMsgpackJSON(b, jop.Output); err != nil {
+			return nil, fmt.Errorf("operation %d.output: %v", i, err)
+		}
+		b = appendMsgpackInt(b, jop.Return)
+	}
+	return b, nil
+}
+
+// UnmarshalOperationsMsgpack decodes a history of operations encoded by
+// [MarshalOperationsMsgpack], decoding inputs and outputs with the given
+// codec. If codec is nil, they are decoded as dynamic values.
+func UnmarshalOperationsMsgpack(data []byte, codec Codec) ([]Operation, error) {
+	if codec == nil {
+		codec = &SchemaCodec{}
+	}
+	items, err := readMsgpackHistory(data, "operations")
+	if err != nil {
+		return nil, err
+	}
+	ops := make([]Operation, len(items))
+	for i, item := range items {
+		path := fmt.Sprintf("operation %d", i)
+		fields, ok := item.([]interface{})
+		if !ok || len(fields) < 5 {
+			return nil, fmt.Errorf("%s: expected an array of 5 fields", path)
+		}
+		var jop jsonOperation
+		var call, ret int64
+		var client int64
+		if client, err = msgpackInt(fields[0], path+".client"); err != nil {
+			return nil, err
+		}
+		if call, err = msgpackInt(fields[2], path+".call"); err != nil {
+			return nil, err
+		}
+		if ret, err = msgpackInt(fields[4], path+".return"); err != nil {
+			return nil, err
+		}
+		jop.Client, jop.Call, jop.Return = int(client), call, ret
+		if jop.Input, err = json.Marshal(fields[1]); err != nil {
+			return nil, fmt.Errorf("%s.input: %v", path, err)
+		}
+		if jop.Output, err = json.Marshal(fields[3]); err != nil {
+			return nil, fmt.Errorf("%s.output: %v", path, err)
+		}
+		if ops[i], err = decodeOperation(codec, jop, path); err != nil {
+			return nil, err
+		}
+	}
+	return ops, nil
+}
+
+// MarshalEventsMsgpack encodes a history of events as MessagePack, encoding
+// call values as inputs and return values as outputs with the given codec. If
+// codec is nil, they are encoded as JSON before conversion.
+func MarshalEventsMsgpack(history []Event, codec Codec) ([]byte, error) {
+	if codec == nil {
+		codec = &SchemaCodec{}
+	}
+	b := appendMsgpackMapHeader(nil, 1)
+	b = appendMsgpackString(b, "events")
+	b = appendMsgpackArrayHeader(b, len(history))
+	for i, e := range history {
+		var value []byte
+		var err error
+		kind := int64(0)
+		if e.Kind == CallEvent {
+			value, err = codec.EncodeInput(e.Value)
+		} else {
+			kind = 1
+			value, err = codec.EncodeOutput(e.Value)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("event %d: %v", i, err)
+		}
+		b = appendMsgpackArrayHeader(b, 4)
+		b = appendMsgpackInt(b, int64(e.ClientId))
+		b = appendMsgpackInt(b, kind)
+		if b, err = appendMsgpackJSON(b, value); err != nil {
+			return nil, fmt.Errorf("event %d.value: %v", i, err)
+		}
+		b = appendMsgpackInt(b, int64(e.Id))
+	}
+	return b, nil
+}
+
+// UnmarshalEventsMsgpack decodes a history of events encoded by
+// [MarshalEventsMsgpack], decoding call values as inputs and return values as
+// outputs with the given codec. If codec is nil, they are decoded as dynamic
+// values.
+func UnmarshalEventsMsgpack(data []byte, codec Codec) ([]Event, error) {
+	if codec == nil {
+		codec = &SchemaCodec{}
+	}
+	items, err := readMsgpackHistory(data, "events")
+	if err != nil {
+		return nil, err
+	}
+	events := make([]Event, len(items))
+	for i, item := range items {
+		path := fmt.Sprintf("event %d", i)
+		fields, ok := item.([]interface{})
+		if !ok || len(fields) < 4 {
+			return nil, fmt.Errorf("%s: expected an array of 4 fields", path)
+		}
+		client, err := msgpackInt(fields[0], path+".client")
+		if err != nil {
+			return nil, err
+		}
+		kind, err := msgpackInt(fields[1], path+".kind")
+		if err != nil {
+			return nil, err
+		}
+		id, err := msgpackInt(fields[3], path+".id")
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(fields[2])
+		if err != nil {
+			return nil, fmt.Errorf("%s.value: %v", path, err)
+		}
+		e := Event{ClientId: int(client), Id: int(id)}
+		decode := codec.DecodeInput
+		switch kind {
+		case 0:
+			e.Kind = CallEvent
+		case 1:
+			e.Kind = ReturnEvent
+			decode = codec.DecodeOutput
+		default:
+			return nil, fmt.Errorf("%s.kind: invalid kind %d", path, kind)
+		}
+		if e.Value, err = decodeWith(decode, value, path+".value"); err != nil {
+			return nil, err
+		}
+		events[i] = e
+	}
+	return events, nil
+}
+
+func readMsgpackHistory(data []byte, key string) ([]interface{}, error) {
+	d := &msgpackDecoder{b: data}
+	v, err := d.value()
+	if err != nil {
+		return nil, err
+	}
+	if len(d.b) != 0 {
+		return nil, errors.New("trailing data after history")
+	}
+	doc, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("expected a map")
+	}
+	items, ok := doc[key].([]interface{})
+	if !ok {
+		if _, present := doc[key]; present {
+			return nil, fmt.Errorf("%s: expected an array", key)
+		}
+		return nil, nil
+	}
+	return items, nil
+}
+
+func msgpackInt(v interface{}, path string) (int64, error) {
+	switch v := v.(type) {
+	case int64:
+		return v, nil
+	case uint64:
+		if v <= math.MaxInt64 {
+			return int64(v), nil
+		}
+	}
+	return 0, fmt.Errorf("%s: expected an integer", path)
+}
+
+// appendMsgpackJSON converts a JSON value to MessagePack.
+func appendMsgpackJSON(b []byte, data []byte) ([]byte, error) {
+	var v interface{}
+	if len(data) > 0 {
+		if err := decodeJSONNumbers(data, &v); err != nil {
+			return nil, err
+		}
+	}
+	return appendMsgpackValue(b, v)
+}
+
+func appendMsgpackValue(b []byte, v interface{}) ([]byte, error) {
+	switch v := v.(type) {
+	case nil:
+		return append(b, 0xc0), nil
+	case bool:
+		if v {
+			return append(b, 0xc3), nil
+		}
+		return append(b, 0xc2), nil
+	case json.Number:
+		if n, err := strconv.ParseInt(string(v), 10, 64); err == nil {
+			return appendMsgpackInt(b, n), nil
+		}
+		if n, err := strconv.ParseUint(string(v), 10, 64); err == nil {
+			return appendMsgpackUint(b, n), nil
+		}
+		f, err := v.Float64()
+		if err != nil {
+			return nil, err
+		}
+		b = append(b, 0xcb)
+		return appendUint64BE(b, math.Float64bits(f)), nil
+	case string:
+		return appendMsgpackString(b, v), nil
+	case []interface{}:
+		b = appendMsgpackArrayHeader(b, len(v))
+		for _, elem := range v {
+			var err error
+			if b, err = appendMsgpackValue(b, elem); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		b = appendMsgpackMapHeader(b, len(v))
+		for _, k := range keys {
+			b = appendMsgpackString(b, k)
+			var err error
+			if b, err = appendMsgpackValue(b, v[k]); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	}
+	return nil, fmt.Errorf("cannot encode %T as MessagePack", v)
+}
+
+func appendMsgpackInt(b []byte, v int64) []byte {
+	switch {
+	case v >= 0:
+		return appendMsgpackUint(b, uint64(v))
+	case v >= -32:
+		return append(b, byte(v))
+	case v >= math.MinInt8:
+		return append(b, 0xd0, byte(v))
+	case v >= math.MinInt16:
+		return appendUint16BE(append(b, 0xd1), uint16(v))
+	case v >= math.MinInt32:
+		return appendUint32BE(append(b, 0xd2), uint32(v))
+	}
+	return appendUint64BE(append(b, 0xd3), uint64(v))
+}
+
+func appendMsgpackUint(b []byte, v uint64) []byte {
+	switch {
+	case v < 128:
+		return append(b, byte(v))
+	case v <= math.MaxUint8:
+		return append(b, 0xcc, byte(v))
+	case v <= math.MaxUint16:
+		return appendUint16BE(append(b, 0xcd), uint16(v))
+	case v <= math.MaxUint32:
+		return appendUint32BE(append(b, 0xce), uint32(v))
+	}
+	return appendUint64BE(append(b, 0xcf), v)
+}
+
+func appendMsgpackString(b []byte, s string) []byte {
+	n := len(s)
+	switch {
+	case n < 32:
+		b = append(b, 0xa0|byte(n))
+	case n <= math.MaxUint8:
+		b = append(b, 0xd9, byte(n))
+	case n <= math.MaxUint16:
+		b = appendUint16BE(append(b, 0xda), uint16(n))
+	default:
+		b = appendUint32BE(append(b, 0xdb), uint32(n))
+	}
+	return append(b, s...)
+}
+
+func appendMsgpackArrayHeader(b []byte, n int) []byte {
+	switch {
+	case n < 16:
+		return append(b, 0x90|byte(n))
+	case n <= math.MaxUint16:
+		return appendUint16BE(append(b, 0xdc), uint16(n))
+	}
+	return appendUint32BE(append(b, 0xdd), uint32(n))
+}
+
+func appendMsgpackMapHeader(b []byte, n int) []byte {
+	switch {
+	case n < 16:
+		return append(b, 0x80|byte(n))
+	case n <= math.MaxUint16:
+		return appendUint16BE(append(b, 0xde), uint16(n))
+	}
+	return appendUint32BE(append(b, 0xdf), uint32(n))
+}
+
+func appendUint16BE(b []byte, v uint16) []byte {
+	return append(b, byte(v>>8), byte(v))
+}
+
+func appendUint32BE(b []byte, v uint32) []byte {
+	var buf [4]byte
+	binary.BigEndian.PutUint32(buf[:], v)
+	return append(b, buf[:]...)
+}
+
+func appendUint64BE(b []byte, v uint64) []byte {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], v)
+	return append(b, buf[:]...)
+}
+
+// msgpackDecoder decodes MessagePack values to the types that encoding/json
+// can encode: nil, bool, int64, uint64, float64, string (for both str and bin),
+// []interface{}, and map[string]interface{}.
+type msgpackDecoder struct {
+	b []byte
+}
+
+var errMsgpackTruncated = errors.New("truncated MessagePack data")
+
+func (d *msgpackDecoder) take(n int) ([]byte, error) {
+	if n < 0 || len(d.b) < n {
+		return nil, errMsgpackTruncated
+	}
+	v := d.b[:n]
+	d.b = d.b[n:]
+	return v, nil
+}
+
+func (d *msgpackDecoder) uint(n int) (uint64, error) {
+	b, err := d.take(n)
+	if err != nil {
+		return 0, err
+	}
+	var v uint64
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+	return v, nil
+}
+
+func (d *msgpackDecoder) value() (interface{}, error) {
+	t, err := d.take(1)
+	if err != nil {
+		return nil, err
+	}
+	c := t[0]
+	switch {
+	case c <= 0x7f:
+		return int64(c), nil
+	case c >= 0xe0:
+		return int64(int8(c)), nil
+	case c&0xf0 == 0x80:
+		return d.mapValue(int(c & 0x0f))
+	case c&0xf0 == 0x90:
+		return d.array(int(c & 0x0f))
+	case c&0xe0 == 0xa0:
+		return d.str(int(c & 0x1f))
+	}
+	switch c {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xc4, 0xd9: // bin 8, str 8
+		n, err := d.uint(1)
+		if err != nil {
+			return nil, err
+		}
+		return d.str(int(n))
+	case 0xc5, 0xda:
+		n, err := d.uint(2)
+		if err != nil {
+			return nil, err
+		}
+		return d.str(int(n))
+	case 0xc6, 0xdb:
+		n, err := d.uint(4)
+		if err != nil {
+			return nil, err
+		}
+		return d.str(int(n))
+	case 0xca:
+		v, err := d.uint(4)
+		return float64(math.Float32frombits(uint32(v))), err
+	case 0xcb:
+		v, err := d.uint(8)
+		return math.Float64frombits(v), err
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		v, err := d.uint(1 << (c - 0xcc))
+		if v <= math.MaxInt64 {
+			return int64(v), err
+		}
+		return v, err
+	case 0xd0:
+		v, err := d.uint(1)
+		return int64(int8(v)), err
+	case 0xd1:
+		v, err := d.uint(2)
+		return int64(int16(v)), err
+	case 0xd2:
+		v, err := d.uint(4)
+		return int64(int32(v)), err
+	case 0xd3:
+		v, err := d.uint(8)
+		return int64(v), err
+	case 0xdc, 0xde:
+		n, err := d.uint(2)
+		if err != nil {
+			return nil, err
+		}
+		if c == 0xdc {
+			return d.array(int(n))
+		}
+		return d.mapValue(int(n))
+	case 0xdd, 0xdf:
+		n, err := d.uint(4)
+		if err != nil {
+			return nil, err
+		}
+		if c == 0xdd {
+			return d.array(int(n))
+		}
+		return d.mapValue(int(n))
+	}
+	return nil, fmt.Errorf("unsupported MessagePack type 0x%02x", c)
+}
+
+func (d *msgpackDecoder) str(n int) (string, error) {
+	b, err := d.take(n)
+	return string(b), err
+}
+
+func (d *msgpackDecoder) array(n int) ([]interface{}, error) {
+	if n > len(d.b) {
+		// every element takes at least a byte
+		return nil, errMsgpackTruncated
+	}
+	arr := make([]interface{}, n)
+	for i := range arr {
+		var err error
+		if arr[i], err = d.value(); err != nil {
+			return nil, err
+		}
+	}
+	return arr, nil
+}
+
+func (d *msgpackDecoder) mapValue(n int) (map[string]interface{}, error) {
+	if n > len(d.b) {
+		return nil, errMsgpackTruncated
+	}
+	m := make(map[string]interface{}, n)
+	for i := 0; i < n; i++ {
+		k, err := d.value()
+		if err != nil {
+			return nil, err
+		}
+		key, ok := k.(string)
+		if !ok {
+			return nil, fmt.Errorf("unsupported map key type %T", k)
+		}
+		if m[key], err = d.value(); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
@@ -0,0 +1,117 @@
+package porcupine
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestOperationsMsgpackRoundTrip(t *testing.T) {
+	ops := []Operation{
+		{0, kvInput{op: 1, key: "x", value: "a"}, 0, kvOutput{}, 10},
+		{1, kvInput{op: 0, key: "x"}, -5, kvOutput{"a"}, 1 << 40},
+	}
+	data, err := MarshalOperationsMsgpack(ops, kvCodec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	read, err := UnmarshalOperationsMsgpack(data, kvCodec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(ops, read) {
+		t.Fatalf("expected %v, got %v", ops, read)
+	}
+	jops := make([]jsonOperation, len(ops))
+	for i, op := range ops {
+		if jops[i], err = encodeOperation(kvCodec, op); err != nil {
+			t.Fatal(err)
+		}
+	}
+	jsonData, err := json.Marshal(jsonHistory{Operations: jops})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) >= len(jsonData) {
+		t.Fatalf("expected MessagePack (%d bytes) to be smaller than JSON (%d bytes)", len(data), len(jsonData))
+	}
+}
+
+func TestOperationsMsgpackWireFormat(t *testing.T) {
+	// {"operations": [[1, "x", 0, nil, 300]]}
+	expected := []byte{
+		0x81, 0xaa, 'o', 'p', 'e', 'r', 'a', 't', 'i', 'o', 'n', 's',
+		0x91, 0x95, 0x01, 0xa1, 'x', 0x00, 0xc0, 0xcd, 0x01, 0x2c,
+	}
+	data, err := MarshalOperationsMsgpack([]Operation{{ClientId: 1, Input: "x", Return: 300}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(expected, data) {
+		t.Fatalf("expected % x, got % x", expected, data)
+	}
+	if _, err := UnmarshalOperationsMsgpack(data[:len(data)-3], nil); err == nil {
+		t.Fatal("expected an error for truncated data")
+	}
+}
+
+func TestMsgpackDynamicValues(t *testing.T) {
+	input := map[string]interface{}{
+		"nested": []interface{}{int64(-100), int64(70000), 1.5, "a long string that is more than thirty-one bytes", true, nil},
+		"big":    int64(1) << 40,
+		"neg":    int64(-1) << 40,
+	}
+	data, err := MarshalOperationsMsgpack([]Operation{{Input: input}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ops, err := UnmarshalOperationsMsgpack(data, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(input, ops[0].Input) {
+		t.Fatalf("expected %v, got %v", input, ops[0].Input)
+	}
+}
+
+func TestEventsMsgpackRoundTrip(t *testing.T) {
+	events := []Event{
+		{0, CallEvent, registerInput{false, 1}, 0},
+		{1, CallEvent, registerInput{true, 0}, 1},
+		{1, ReturnEvent, 1, 1},
+		{0, ReturnEvent, 0, 0},
+	}
+	codec := &FuncCodec{
+		MarshalInput: func(input interface{}) ([]byte, error) {
+			inp := input.(registerInput)
+			return json.Marshal([]interface{}{inp.op, inp.value})
+		},
+		UnmarshalInput: func(data []byte) (interface{}, error) {
+			var v []interface{}
+			if err := json.Unmarshal(data, &v); err != nil {
+				return nil, err
+			}
+			return registerInput{v[0].(bool), int(v[1].(float64))}, nil
+		},
+		UnmarshalOutput: func(data []byte) (interface{}, error) {
+			var v int
+			err := json.Unmarshal(data, &v)
+			return v, err
+		},
+	}
+	data, err := MarshalEventsMsgpack(events, codec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	read, err := UnmarshalEventsMsgpack(data, codec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(events, read) {
+		t.Fatalf("expected %v, got %v", events, read)
+	}
+	if _, err := UnmarshalOperationsMsgpack(data, codec); err != nil {
+		t.Fatalf("expected no operations in a history of events, got %v", err)
+	}
+}
@@ -0,0 +1,63 @@
+package porcupine
+
+import "sync"
+
+// CheckOperationsMulti checks a history against several models at once,
+// e.g., a strict model and a relaxed one, returning the result for each
+// model, in order.
+//
+// The history is partitioned and preprocessed once, with the first model's
+// partition function, and the models are checked concurrently, so the models
+// should partition histories the same way. The options apply to the check of
+// each model, and a WithParallelism bound is shared by all of them; progress
+// reporting, WithCheckpoint and WithDecisionTrace are not supported, and are
+// ignored.
+func CheckOperationsMulti(models []Model, history []Operation, opts ...CheckOption) []CheckResult {
+	if len(models) == 0 {
+		return nil
+	}
+	partitions := fillDefault(models[0]).Partition(history)
+	l := make([][]entry, len(partitions))
+	for i, subhistory := range partitions {
+		l[i] = makeEntries(subhistory, nil)
+	}
+	o := checkOptions{}.apply(opts)
+	relaxRealTime(l, o.epsilon)
+	return checkMulti(models, l, o)
+}
+
+// CheckEventsMulti checks a history against several models at once; see
+// [CheckOperationsMulti].
+func CheckEventsMulti(models []Model, history []Event, opts ...CheckOption) []CheckResult {
+	if len(models) == 0 {
+		return nil
+	}
+	partitions := fillDefault(models[0]).PartitionEvent(history)
+	l := make([][]entry, len(partitions))
+	for i, subhistory := range partitions {
+		l[i] = convertEntries(renumber(subhistory), nil)
+	}
+	return checkMulti(models, l, checkOptions{}.apply(opts))
+}
+
+// checkMulti checks the partitioned history against each model. The entries
+// are only read by checkSingle, so the checks share them.
+func checkMulti(models []Model, history [][]entry, opts checkOptions) []CheckResult {
+	opts.progress = nil
+	opts.checkpointPath = ""
+	opts.decisionTrace = nil
+	if opts.workers == nil && opts.parallelism > 0 {
+		opts.workers = make(chan struct{}, opts.parallelism)
+	}
+	results := make([]CheckResult, len(models))
+	var wg sync.WaitGroup
+	for i, model := range models {
+		wg.Add(1)
+		go func(i int, model Model) {
+			defer wg.Done()
+			results[i], _ = checkParallel(fillDefault(model), history, opts)
+		}(i, model)
+	}
+	wg.Wait()
+	return results
+}
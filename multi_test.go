@@ -0,0 +1,65 @@
+package porcupine
+
+import (
+	"bytes"
+	"testing"
+)
+
+// kvRelaxedModel is like kvModel, but allows gets to return any value.
+var kvRelaxedModel = Model{
+	Partition:      kvModel.Partition,
+	PartitionEvent: kvModel.PartitionEvent,
+	Init:           kvModel.Init,
+	Step: func(state, input, output interface{}) (bool, interface{}) {
+		if input.(kvInput).op == 0 {
+			return true, state
+		}
+		return kvModel.Step(state, input, output)
+	},
+	DescribeOperation: kvModel.DescribeOperation,
+}
+
+func TestCheckOperationsMulti(t *testing.T) {
+	models := []Model{kvModel, kvRelaxedModel}
+	for _, tc := range []struct {
+		file     string
+		expected []CheckResult
+	}{
+		{"test_data/kv/c10-ok.txt", []CheckResult{Ok, Ok}},
+		{"test_data/kv/c10-bad.txt", []CheckResult{Illegal, Ok}},
+	} {
+		events := parseKvLog(tc.file)
+		for name, res := range map[string][]CheckResult{
+			"operations": CheckOperationsMulti(models, kvOperations(events)),
+			"events":     CheckEventsMulti(models, events, WithParallelism(2)),
+		} {
+			if len(res) != len(tc.expected) {
+				t.Fatalf("%s (%s): expected %d results, got %d", tc.file, name, len(tc.expected), len(res))
+			}
+			for i := range res {
+				if res[i] != tc.expected[i] {
+					t.Fatalf("%s (%s): model %d: expected output %v, got output %v", tc.file, name, i, tc.expected[i], res[i])
+				}
+			}
+		}
+	}
+	if res := CheckOperationsMulti(nil, nil); res != nil {
+		t.Fatalf("expected no results, got %v", res)
+	}
+}
+
+// run with -race: the concurrent checks must not share a decision tracer
+func TestCheckOperationsMultiDecisionTrace(t *testing.T) {
+	events := parseKvLog("test_data/kv/c10-bad.txt")
+	var trace bytes.Buffer
+	res := CheckOperationsMulti([]Model{kvModel, kvRelaxedModel, kvModel}, kvOperations(events), WithDecisionTrace(&trace))
+	expected := []CheckResult{Illegal, Ok, Illegal}
+	for i := range res {
+		if res[i] != expected[i] {
+			t.Fatalf("model %d: expected output %v, got output %v", i, expected[i], res[i])
+		}
+	}
+	if trace.Len() != 0 {
+		t.Fatalf("expected no decision trace, got %d bytes", trace.Len())
+	}
+}
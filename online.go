@@ -0,0 +1,277 @@
+package porcupine
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ErrLateOperation is returned by [OnlineChecker.Add] for an operation that
+// was invoked before the checker's watermark.
+var ErrLateOperation = errors.New("porcupine: operation invoked before the watermark")
+
+// OnlineOptions configures an [OnlineChecker]. The zero value checks the
+// history as a single partition.
+type OnlineOptions struct {
+	// Optional: map an operation's input to the key that the model
+	// partitions on, so that each key is checked separately, as with a
+	// model's Partition function. The model's Init and Step functions are
+	// then per-key. The online checker cannot use the model's Partition
+	// function, because it never has the whole history.
+	PartitionKey func(input interface{}) string
+}
+
+// An OnlineChecker checks a history for linearizability as it is being
+// recorded, reporting a violation as soon as the history so far cannot be
+// linearized, rather than after the run ends.
+//
+// Feed it completed operations with [OnlineChecker.Add], in any order, e.g.,
+// as they are read from a [Recorder]'s spill file, and periodically call
+// [OnlineChecker.Advance] with a watermark: a time before which no more
+// operations will be invoked. Operations can only be checked once the
+// watermark has passed them, because an operation that is still in flight
+// can be linearized before operations that have already completed. When the
+// run is over, call [OnlineChecker.Finish].
+//
+// The checker keeps the set of states that the system could be in after the
+// operations that have returned before the watermark, and linearizes each
+// operation as late as possible, so its memory use is bounded by the
+// concurrency of the history rather than its length. Because it must keep
+// every state the system could be in, rather than finding a single
+// linearization, it can be much slower than [CheckOperations] on histories
+// with many concurrent operations on the same key. An OnlineChecker is safe
+// for concurrent use.
+type OnlineChecker struct {
+	model Model
+	opts  OnlineOptions
+
+	mu         sync.Mutex // protects everything below
+	nextId     int
+	watermark  int64
+	buffered   []entry // events not yet checked
+	ops        map[int]Operation
+	partitions map[string]*onlinePartition
+	violation  *Operation
+	// the most recently added operations, at least keepRecent of them if
+	// there are that many, for VisualizeHandler
+	recent     []Operation
+	keepRecent int
+}
+
+// NewOnlineChecker creates an [OnlineChecker] for the given model.
+func NewOnlineChecker(model Model, opts OnlineOptions) *OnlineChecker {
+	return &OnlineChecker{
+		model:      fillDefault(model),
+		opts:       opts,
+		watermark:  math.MinInt64,
+		ops:        make(map[int]Operation),
+		partitions: make(map[string]*onlinePartition),
+	}
+}
+
+// Add adds a completed operation to the history. It returns
+// ErrLateOperation, and ignores the operation, if the operation was invoked
+// before the watermark.
+func (c *OnlineChecker) Add(op Operation) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if op.Call < c.watermark {
+		return ErrLateOperation
+	}
+	id := c.nextId
+	c.nextId++
+	c.ops[id] = op
+	if c.keepRecent > 0 {
+		// trim in batches, so that adding stays amortized constant time
+		if len(c.recent) >= 2*c.keepRecent {
+			c.recent = append(c.recent[:0], c.recent[len(c.recent)-c.keepRecent:]...)
+		}
+		c.recent = append(c.recent, op)
+	}
+	c.buffered = append(c.buffered,
+		entry{kind: callEntry, value: op.Input, id: id, time: op.Call, clientId: op.ClientId},
+		entry{kind: returnEntry, value: op.Output, id: id, time: op.Return, clientId: op.ClientId})
+	return nil
+}
+
+// Advance tells the checker that every operation invoked before time t has
+// been added, and checks the history up to t. It returns Illegal if the
+// history is not linearizable, and Ok otherwise, meaning that no violation
+// has been found so far.
+func (c *OnlineChecker) Advance(t int64) CheckResult {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if t > c.watermark {
+		c.watermark = t
+	}
+	return c.check(c.watermark)
+}
+
+// Finish checks the rest of the history, once every operation has been
+// added, and returns whether the whole history is linearizable. Operations
+// can no longer be added afterwards.
+func (c *OnlineChecker) Finish() CheckResult {
+	return c.Advance(math.MaxInt64)
+}
+
+// Violation returns the operation whose return made the history
+// non-linearizable, if a violation has been found.
+func (c *OnlineChecker) Violation() (Operation, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.violation == nil {
+		return Operation{}, false
+	}
+	return *c.violation, true
+}
+
+// check processes the buffered events that happened before t. The caller
+// must hold c.mu.
+func (c *OnlineChecker) check(t int64) CheckResult {
+	if c.violation != nil {
+		return Illegal
+	}
+	sort.Sort(byTime(c.buffered))
+	n := 0
+	for n < len(c.buffered) && (c.buffered[n].time < t || t == math.MaxInt64) {
+		n++
+	}
+	for _, e := range c.buffered[:n] {
+		op := c.ops[e.id]
+		key := ""
+		if c.opts.PartitionKey != nil {
+			key = c.opts.PartitionKey(op.Input)
+		}
+		p := c.partitions[key]
+		if p == nil {
+			p = &onlinePartition{
+				pending:  make(map[int]Operation),
+				frontier: []onlineConfig{{state: c.model.Init()}},
+			}
+			c.partitions[key] = p
+		}
+		if e.kind == callEntry {
+			p.pending[e.id] = op
+			continue
+		}
+		delete(c.ops, e.id)
+		if !p.complete(c.model, e.id) {
+			c.violation = &op
+			c.buffered = nil
+			return Illegal
+		}
+	}
+	c.buffered = append(c.buffered[:0], c.buffered[n:]...)
+	return Ok
+}
+
+// An onlinePartition is the search state of an OnlineChecker for one key.
+type onlinePartition struct {
+	pending  map[int]Operation // invoked, but not yet returned
+	frontier []onlineConfig
+}
+
+// An onlineConfig is a possible state of the system, along with the pending
+// operations that were linearized to reach it.
+type onlineConfig struct {
+	state      interface{}
+	linearized []int // sorted IDs of pending operations
+}
+
+func (cfg onlineConfig) has(id int) bool {
+	i := sort.SearchInts(cfg.linearized, id)
+	return i < len(cfg.linearized) && cfg.linearized[i] == id
+}
+
+func (cfg onlineConfig) with(id int) []int {
+	i := sort.SearchInts(cfg.linearized, id)
+	ids := make([]int, 0, len(cfg.linearized)+1)
+	ids = append(ids, cfg.linearized[:i]...)
+	ids = append(ids, id)
+	return append(ids, cfg.linearized[i:]...)
+}
+
+func (cfg onlineConfig) without(id int) []int {
+	ids := make([]int, 0, len(cfg.linearized))
+	for _, v := range cfg.linearized {
+		if v != id {
+			ids = append(ids, v)
+		}
+	}
+	return ids
+}
+
+// key returns the key of a configuration within a set: the operations it has
+// linearized, along with the hash of its state if the model has a Hash
+// function.
+func (cfg onlineConfig) key(model Model) string {
+	var b strings.Builder
+	for _, id := range cfg.linearized {
+		b.WriteString(strconv.Itoa(id))
+		b.WriteByte(',')
+	}
+	if model.Hash != nil {
+		b.WriteString(strconv.FormatUint(model.Hash(cfg.state), 16))
+	}
+	return b.String()
+}
+
+// onlineConfigSet is a set of configurations, deduplicated using the model's
+// Equal function.
+type onlineConfigSet struct {
+	byKey   map[string][]onlineConfig
+	configs []onlineConfig
+}
+
+func newOnlineConfigSet() *onlineConfigSet {
+	return &onlineConfigSet{byKey: make(map[string][]onlineConfig)}
+}
+
+// add adds a configuration to the set, and reports whether it was new.
+func (s *onlineConfigSet) add(model Model, cfg onlineConfig) bool {
+	key := cfg.key(model)
+	for _, other := range s.byKey[key] {
+		if model.Equal(cfg.state, other.state) {
+			return false
+		}
+	}
+	s.byKey[key] = append(s.byKey[key], cfg)
+	s.configs = append(s.configs, cfg)
+	return true
+}
+
+// complete handles the return of operation id: every configuration must
+// have linearized it by now. Configurations that have not are extended by
+// linearizing pending operations, as late as possible, until they have. It
+// reports whether any configuration remains.
+func (p *onlinePartition) complete(model Model, id int) bool {
+	next := newOnlineConfigSet()
+	visited := newOnlineConfigSet()
+	var extend func(cfg onlineConfig)
+	extend = func(cfg onlineConfig) {
+		if cfg.has(id) {
+			next.add(model, onlineConfig{cfg.state, cfg.without(id)})
+			return
+		}
+		if !visited.add(model, cfg) {
+			return
+		}
+		for opId, op := range p.pending {
+			if cfg.has(opId) {
+				continue
+			}
+			if ok, state := model.Step(cfg.state, op.Input, op.Output); ok {
+				extend(onlineConfig{state, cfg.with(opId)})
+			}
+		}
+	}
+	for _, cfg := range p.frontier {
+		extend(cfg)
+	}
+	delete(p.pending, id)
+	p.frontier = next.configs
+	return len(p.frontier) > 0
+}
@@ -0,0 +1,92 @@
+package porcupine
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// kvOperations converts a history of events to operations, using each
+// event's index as its timestamp.
+func kvOperations(events []Event) []Operation {
+	calls := make(map[int]int)
+	var ops []Operation
+	for i, e := range events {
+		if e.Kind == CallEvent {
+			calls[e.Id] = len(ops)
+			ops = append(ops, Operation{ClientId: e.ClientId, Input: e.Value, Call: int64(i)})
+		} else {
+			op := &ops[calls[e.Id]]
+			op.Output = e.Value
+			op.Return = int64(i)
+		}
+	}
+	return ops
+}
+
+func kvKey(input interface{}) string {
+	return input.(kvInput).key
+}
+
+func TestOnlineChecker(t *testing.T) {
+	for _, tc := range []struct {
+		file     string
+		expected CheckResult
+	}{
+		{"test_data/kv/c10-ok.txt", Ok},
+		{"test_data/kv/c10-bad.txt", Illegal},
+		{"test_data/kv/c01-ok.txt", Ok},
+		{"test_data/kv/c01-bad.txt", Illegal},
+	} {
+		ops := kvOperations(parseKvLog(tc.file))
+		// operations are added out of order
+		rand.New(rand.NewSource(0)).Shuffle(len(ops), func(i, j int) {
+			ops[i], ops[j] = ops[j], ops[i]
+		})
+		checker := NewOnlineChecker(kvModel, OnlineOptions{PartitionKey: kvKey})
+		for _, op := range ops {
+			if err := checker.Add(op); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if res := checker.Finish(); res != tc.expected {
+			t.Fatalf("%s: expected output %v, got output %v", tc.file, tc.expected, res)
+		}
+		if _, ok := checker.Violation(); ok != (tc.expected == Illegal) {
+			t.Fatalf("%s: expected a violation to be reported iff the history is illegal", tc.file)
+		}
+	}
+}
+
+func TestOnlineCheckerReportsEarly(t *testing.T) {
+	checker := NewOnlineChecker(registerModel, OnlineOptions{})
+	// put(1), then a get that sees the initial value
+	checker.Add(Operation{0, registerInput{false, 1}, 0, 0, 10})
+	if res := checker.Advance(15); res != Ok {
+		t.Fatalf("expected output %v, got output %v", Ok, res)
+	}
+	checker.Add(Operation{1, registerInput{true, 0}, 20, 0, 30})
+	if res := checker.Advance(40); res != Illegal {
+		t.Fatalf("expected output %v, got output %v", Illegal, res)
+	}
+	op, ok := checker.Violation()
+	if !ok || op.Call != 20 {
+		t.Fatalf("expected the get to be reported as the violation, got %v", op)
+	}
+	if err := checker.Add(Operation{0, registerInput{false, 2}, 35, 0, 50}); err != ErrLateOperation {
+		t.Fatalf("expected %v, got %v", ErrLateOperation, err)
+	}
+}
+
+func TestOnlineCheckerWaitsForWatermark(t *testing.T) {
+	checker := NewOnlineChecker(registerModel, OnlineOptions{})
+	// the get completes first, but it observes a put that was invoked
+	// concurrently and is still in flight
+	checker.Add(Operation{1, registerInput{true, 1}, 10, 1, 20})
+	if res := checker.Advance(5); res != Ok {
+		t.Fatalf("expected output %v, got output %v", Ok, res)
+	}
+	checker.Add(Operation{0, registerInput{false, 1}, 5, 0, 100})
+	if res := checker.Finish(); res != Ok {
+		t.Fatalf("expected output %v, got output %v", Ok, res)
+	}
+}
@@ -0,0 +1,255 @@
+package porcupine
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// A CheckOption configures a linearizability check; pass options to the
+// Check* functions.
+type CheckOption func(*checkOptions)
+
+// WithTimeout stops the check after the given time, in which case it returns
+// Unknown. A timeout of 0 is interpreted as an unlimited timeout, which is the
+// default.
+func WithTimeout(timeout time.Duration) CheckOption {
+	return func(o *checkOptions) {
+		o.timeout = timeout
+	}
+}
+
+// WithContext stops the check if ctx is done, e.g., on SIGINT or when a
+// test's deadline is near, in which case it returns Unknown.
+func WithContext(ctx context.Context) CheckOption {
+	return func(o *checkOptions) {
+		o.ctx = ctx
+	}
+}
+
+// WithVerbose makes the check compute a LinearizationInfo, which can be used
+// to visualize the history and linearization with [Visualize]. A verbose
+// check keeps checking the other partitions of a history after one is found
+// to be non-linearizable, so that all of them can be visualized.
+func WithVerbose() CheckOption {
+	return func(o *checkOptions) {
+		o.verbose = true
+	}
+}
+
+// WithProgress periodically reports the progress of the check to the given
+// callback; see [CheckOperationsProgress].
+func WithProgress(progress func(Progress)) CheckOption {
+	return func(o *checkOptions) {
+		o.progress = progress
+	}
+}
+
+// WithParallelism sets the maximum number of partitions of a history that are
+// checked concurrently, for models that define a partition function. It
+// defaults to runtime.GOMAXPROCS(0); a value of 1 checks partitions one at a
+// time. Partitions waiting to be checked take turns with the ones being
+// checked, so that a slow partition can't hold up the others.
+func WithParallelism(n int) CheckOption {
+	return func(o *checkOptions) {
+		o.parallelism = n
+	}
+}
+
+// WithMemoryBudget limits the estimated memory, in bytes, that the search may
+// use for its cache of visited states and its stack of linearized operations,
+// across all partitions being checked at once. If the search goes over the
+// budget, the check stops and returns Unknown, along with the partial
+// linearizations found so far for verbose checks, instead of letting the
+// process run out of memory. The estimate does not include the model's
+// states themselves. A budget of 0 means no limit, which is the default.
+func WithMemoryBudget(bytes int64) CheckOption {
+	return func(o *checkOptions) {
+		o.memoryBudget = bytes
+	}
+}
+
+// WithMetrics reports counters describing the work that the check did, such
+// as the number of states generated and cache hits for each partition, to the
+// given sink when the check finishes; see [Metrics]. Collecting them waits
+// for every partition's search to stop, even if the check stops early.
+func WithMetrics(sink MetricsSink) CheckOption {
+	return func(o *checkOptions) {
+		o.metrics = sink
+	}
+}
+
+// WithStateCache sets the cache of visited states that the search of each
+// partition uses, instead of the default one, which keeps every state; see
+// [StateCache]. The function is called once per partition, from the goroutine
+// that searches it, and a check that stops early, e.g., once a partition is
+// found to be non-linearizable, can return before the searches of the other
+// partitions stop, so it can be called after the check returns. States kept
+// by a custom cache are not counted against a [WithMemoryBudget] budget, and
+// are not saved by [WithCheckpoint].
+func WithStateCache(newCache func() StateCache) CheckOption {
+	return func(o *checkOptions) {
+		o.newCache = newCache
+	}
+}
+
+// WithOrdering sets the order in which the search tries operations that
+// could each be linearized next: less(a, b) reports whether a should be
+// tried before b. By default, the search tries operations in the order they
+// were called. A good ordering, such as [EarliestReturnFirst] or
+// [ShortestWindowFirst], can find a linearization of a linearizable history
+// with much less backtracking on skewed workloads; it doesn't change the
+// result. For events, Call and Return are the positions of the events in the
+// history.
+//
+// To keep the search's bookkeeping cheap, the ordering is applied among
+// operations whose calls are not separated by a return in the history, which
+// covers bursts of concurrent calls. A check resumed with [WithCheckpoint]
+// must use the same ordering.
+func WithOrdering(less func(a, b Operation) bool) CheckOption {
+	return func(o *checkOptions) {
+		o.ordering = less
+	}
+}
+
+// WithDeterministic makes the check reproducible, for bisecting the
+// checker's behavior: two checks of the same history return the same
+// partitions, in the same order, and the same partial linearizations, in the
+// same order, no matter how the partitions' searches are scheduled. To do
+// so, the check puts partitions in a canonical order, instead of the order
+// the model's partition function returns them in, and checks every partition
+// to completion, even after one is found to be non-linearizable, so it can
+// be slower on histories that are not linearizable.
+//
+// Limits on time and memory, such as timeouts and [WithMemoryBudget], stop
+// the check at a point that depends on timing, so checks that hit them are
+// not reproducible.
+func WithDeterministic() CheckOption {
+	return func(o *checkOptions) {
+		o.deterministic = true
+	}
+}
+
+// WithEpsilon relaxes the real-time order that a linearizable history must
+// respect by epsilon, in the units of the operations' timestamps: operations
+// that are at most epsilon apart are treated as concurrent, so either can be
+// linearized first. This tolerates clock skew of up to epsilon between the
+// clients that recorded a history, or a bounded staleness that the system
+// accepts. The check extends each operation's return by epsilon, which the
+// visualization shows. It doesn't apply to histories of events, which have no
+// timestamps.
+func WithEpsilon(epsilon int64) CheckOption {
+	return func(o *checkOptions) {
+		o.epsilon = epsilon
+	}
+}
+
+// WithPartitionSplitting splits the partitions that split predicts are too
+// large to check, by their number of operations and their concurrency,
+// rather than spending the whole timeout searching one of them. See
+// [SplitOptions] for the ways a partition can be split; splitting by time
+// window is exact, and splitting with a secondary partition function is as
+// sound as the function is.
+//
+// The partial linearizations of a split partition only cover the pieces it
+// was split into, and split partitions are not saved by [WithCheckpoint].
+func WithPartitionSplitting(split SplitOptions) CheckOption {
+	return func(o *checkOptions) {
+		o.split = &split
+	}
+}
+
+// WithStateInterning deduplicates the states that the search visits, so that
+// equal states returned by separate calls to the model's Step function are
+// stored once, and are compared by identity rather than with Equal. This
+// saves memory for models whose Step function returns large copied
+// structures, such as maps or slices, on every call. It requires the model to
+// have a Hash function; without one, the option has no effect.
+func WithStateInterning() CheckOption {
+	return func(o *checkOptions) {
+		o.internStates = true
+	}
+}
+
+// WithSymmetryReduction makes the search skip operations that are
+// interchangeable with ones it has already tried. The model's Step function
+// only sees an operation's input and output, so operations from different
+// clients with equal inputs and outputs (compared by their "%#v" formatting)
+// lead to the same states, and trying each of them in turn is redundant. This
+// shrinks the search space for highly concurrent histories where many clients
+// perform the same operations, such as reads of the same value, at the cost
+// of some extra work per search step.
+func WithSymmetryReduction() CheckOption {
+	return func(o *checkOptions) {
+		o.reduceSymmetry = true
+	}
+}
+
+// WithMaxDepth caps the search at linearized prefixes of depth operations in
+// each partition, for quick feedback on enormous histories. If some ordering
+// of depth operations of a partition is a valid linearizable prefix, the
+// search of that partition stops there, and the check returns Unknown (unless
+// another partition is Illegal); verbose checks return the prefix as a
+// partial linearization. If no such prefix exists, the partition, and so the
+// history, is definitely not linearizable, and the check returns Illegal. A
+// depth of 0 means no limit, which is the default.
+func WithMaxDepth(depth int) CheckOption {
+	return func(o *checkOptions) {
+		o.maxDepth = depth
+	}
+}
+
+// WithPartitionTimeout limits the time spent checking each partition of a
+// history, so that one pathological partition can't use up the whole timeout
+// and keep the other partitions from being checked. A partition that runs out
+// of time is treated as Unknown: the check still returns Illegal if another
+// partition is not linearizable, and otherwise returns Unknown. Verbose checks
+// list the partitions that ran out of time in
+// [LinearizationInfo.TimedOutPartitions]. A timeout of 0 means no limit,
+// which is the default.
+func WithPartitionTimeout(timeout time.Duration) CheckOption {
+	return func(o *checkOptions) {
+		o.partitionTimeout = timeout
+	}
+}
+
+// WithCheckpoint periodically saves the state of the search to the file at
+// path, every interval (or every minute, if interval is 0), so that a long
+// check that is interrupted, e.g., by a timeout or a cancelled context, can
+// be resumed by starting the same check again with the same path. The state
+// is also saved when the check stops without a result. Once the check has a
+// result, Ok or Illegal, the file is removed.
+//
+// A file saved by a check of a different history is ignored, and so are
+// errors saving the file. The model's states are encoded with encoding/gob,
+// so their concrete types must be registered with gob.Register, and the
+// model's Step function must be deterministic, because resuming replays it.
+func WithCheckpoint(path string, interval time.Duration) CheckOption {
+	return func(o *checkOptions) {
+		o.checkpointPath = path
+		o.checkpointInterval = interval
+	}
+}
+
+// WithDecisionTrace records every decision of the search to w, in a compact
+// binary format that [ReadDecisionTrace] reads, so that the search's behavior
+// can be analyzed offline: each time the model accepts an operation, whether
+// the search linearized it or found the resulting state in its cache, and
+// each time it backtracked, along with the operation's ID and the state's
+// hash. A trace can be large, since the search can make millions of
+// decisions a second; w is written through a buffer, and flushed when the
+// check returns. The trace stops at the first error writing it. Collecting it
+// waits for every partition's search to stop, even if the check stops early.
+func WithDecisionTrace(w io.Writer) CheckOption {
+	return func(o *checkOptions) {
+		o.decisionTrace = w
+	}
+}
+
+// apply returns the options with the given CheckOptions applied.
+func (o checkOptions) apply(opts []CheckOption) checkOptions {
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
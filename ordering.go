@@ -0,0 +1,41 @@
+package porcupine
+
+import "sort"
+
+// EarliestReturnFirst is an ordering for [WithOrdering] that tries operations
+// that return earlier first. An operation that returns early must be
+// linearized before everything called after its return, so trying it first
+// tends to find a linearization with less backtracking.
+func EarliestReturnFirst(a, b Operation) bool {
+	return a.Return < b.Return
+}
+
+// ShortestWindowFirst is an ordering for [WithOrdering] that tries
+// operations with the shortest time between call and return first, as they
+// have the fewest places they can be linearized.
+func ShortestWindowFirst(a, b Operation) bool {
+	return a.Return-a.Call < b.Return-b.Call
+}
+
+// orderEntries returns a copy of a partition's entries with each run of
+// consecutive call entries sorted by less, so that the search, which tries
+// calls in the order of the entries, tries them in that order. Reordering
+// calls within a run doesn't change which calls precede each return, so the
+// search still explores the same linearizations.
+func orderEntries(history []entry, less func(a, b Operation) bool) []entry {
+	ops := entryOperations(history)
+	ordered := make([]entry, len(history))
+	copy(ordered, history)
+	for i := 0; i < len(ordered); {
+		j := i
+		for j < len(ordered) && ordered[j].kind == callEntry {
+			j++
+		}
+		run := ordered[i:j]
+		sort.SliceStable(run, func(a, b int) bool {
+			return less(ops[run[a].id], ops[run[b].id])
+		})
+		i = j + 1
+	}
+	return ordered
+}
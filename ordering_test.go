@@ -0,0 +1,55 @@
+package porcupine
+
+import "testing"
+
+func TestWithOrdering(t *testing.T) {
+	for _, tc := range []struct {
+		file     string
+		expected bool
+	}{
+		{"test_data/kv/c10-ok.txt", true},
+		{"test_data/kv/c10-bad.txt", false},
+	} {
+		events := parseKvLog(tc.file)
+		ops := kvOperations(events)
+		for name, less := range map[string]func(a, b Operation) bool{
+			"EarliestReturnFirst": EarliestReturnFirst,
+			"ShortestWindowFirst": ShortestWindowFirst,
+		} {
+			if res := CheckOperations(kvModel, ops, WithOrdering(less)); res != tc.expected {
+				t.Fatalf("%s: %s: expected output %t, got output %t", tc.file, name, tc.expected, res)
+			}
+			if res := CheckEvents(kvModel, events, WithOrdering(less)); res != tc.expected {
+				t.Fatalf("%s: %s (events): expected output %t, got output %t", tc.file, name, tc.expected, res)
+			}
+		}
+	}
+}
+
+func TestOrderEntries(t *testing.T) {
+	history := makeEntries([]Operation{
+		{ClientId: 0, Input: 0, Call: 0, Output: 0, Return: 10},
+		{ClientId: 1, Input: 1, Call: 1, Output: 1, Return: 5},
+		{ClientId: 2, Input: 2, Call: 2, Output: 2, Return: 3},
+		{ClientId: 3, Input: 3, Call: 6, Output: 3, Return: 9},
+		{ClientId: 4, Input: 4, Call: 7, Output: 4, Return: 8},
+	}, nil)
+	ordered := orderEntries(history, EarliestReturnFirst)
+	// calls are reordered within each run, and returns stay in place
+	expected := []struct {
+		kind entryKind
+		id   int
+	}{
+		{callEntry, 2}, {callEntry, 1}, {callEntry, 0}, {returnEntry, 2},
+		{returnEntry, 1}, {callEntry, 4}, {callEntry, 3}, {returnEntry, 4},
+		{returnEntry, 3}, {returnEntry, 0},
+	}
+	for i, e := range ordered {
+		if e.kind != expected[i].kind || e.id != expected[i].id {
+			t.Fatalf("entry %d: expected %v of %d, got %v of %d", i, expected[i].kind, expected[i].id, e.kind, e.id)
+		}
+	}
+	if history[0].id != 0 {
+		t.Fatal("expected the original entries to be unchanged")
+	}
+}
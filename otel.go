@@ -0,0 +1,296 @@
+package porcupine
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+)
+
+// A Span is a span from a distributed trace, as read by [ReadOTLPSpans] or
+// [ReadJaegerSpans], for converting to operations with [SpanOperations].
+type Span struct {
+	TraceId      string
+	SpanId       string
+	ParentSpanId string // empty for a root span
+	Name         string
+	Service      string
+	// Start and end times, in nanoseconds since the Unix epoch.
+	Start int64
+	End   int64
+	// Span attributes (tags, in Jaeger), as dynamic values (see [Schema]).
+	Attributes map[string]interface{}
+	// Whether the span's status is an error.
+	Error bool
+}
+
+// A SpanMapping describes how to convert spans to operations.
+type SpanMapping struct {
+	// Filter selects the spans that are operations. If it is nil, all spans
+	// are.
+	Filter func(span Span) bool
+	// Input and Output give an operation's input and output. An error
+	// aborts the conversion.
+	Input  func(span Span) (interface{}, error)
+	Output func(span Span) (interface{}, error)
+	// Client gives an operation's client ID. If it is nil, each operation is
+	// assigned to the lowest-numbered client with no operation in progress
+	// when it starts, so that each client's operations are sequential.
+	Client func(span Span) int
+}
+
+// SpanOperations converts spans to a history of operations, using the span's
+// start and end times as the operation's call and return times.
+//
+// Operations are returned in order of call time.
+func SpanOperations(spans []Span, mapping SpanMapping) ([]Operation, error) {
+	var selected []Span
+	for _, span := range spans {
+		if mapping.Filter == nil || mapping.Filter(span) {
+			selected = append(selected, span)
+		}
+	}
+	sort.SliceStable(selected, func(i, j int) bool {
+		return selected[i].Start < selected[j].Start
+	})
+	ops := make([]Operation, len(selected))
+	var busyUntil []int64 // for assigning clients, by client ID
+	for i, span := range selected {
+		input, err := mapping.Input(span)
+		if err != nil {
+			return nil, fmt.Errorf("span %s: %v", span.SpanId, err)
+		}
+		output, err := mapping.Output(span)
+		if err != nil {
+			return nil, fmt.Errorf("span %s: %v", span.SpanId, err)
+		}
+		ops[i] = Operation{Input: input, Call: span.Start, Output: output, Return: span.End}
+		if mapping.Client != nil {
+			ops[i].ClientId = mapping.Client(span)
+		} else {
+			client := 0
+			for client < len(busyUntil) && busyUntil[client] > span.Start {
+				client++
+			}
+			if client == len(busyUntil) {
+				busyUntil = append(busyUntil, 0)
+			}
+			busyUntil[client] = span.End
+			ops[i].ClientId = client
+		}
+	}
+	return ops, nil
+}
+
+// OTLP JSON encoding, as written by the OpenTelemetry Collector's file
+// exporter and accepted by OTLP/HTTP
+
+type otlpTraces struct {
+	ResourceSpans []struct {
+		Resource struct {
+			Attributes []otlpKeyValue `json:"attributes"`
+		} `json:"resource"`
+		ScopeSpans []struct {
+			Spans []otlpSpan `json:"spans"`
+		} `json:"scopeSpans"`
+	} `json:"resourceSpans"`
+}
+
+type otlpSpan struct {
+	TraceId           string         `json:"traceId"`
+	SpanId            string         `json:"spanId"`
+	ParentSpanId      string         `json:"parentSpanId"`
+	Name              string         `json:"name"`
+	StartTimeUnixNano json.Number    `json:"startTimeUnixNano"`
+	EndTimeUnixNano   json.Number    `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes"`
+	Status            struct {
+		Code json.RawMessage `json:"code"`
+	} `json:"status"`
+}
+
+type otlpKeyValue struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	StringValue *string      `json:"stringValue"`
+	BoolValue   *bool        `json:"boolValue"`
+	IntValue    *json.Number `json:"intValue"`
+	DoubleValue *json.Number `json:"doubleValue"`
+	BytesValue  *string      `json:"bytesValue"`
+	ArrayValue  *struct {
+		Values []otlpValue `json:"values"`
+	} `json:"arrayValue"`
+	KvlistValue *struct {
+		Values []otlpKeyValue `json:"values"`
+	} `json:"kvlistValue"`
+}
+
+func (v otlpValue) dynamic() (interface{}, error) {
+	switch {
+	case v.StringValue != nil:
+		return *v.StringValue, nil
+	case v.BoolValue != nil:
+		return *v.BoolValue, nil
+	case v.IntValue != nil:
+		return strconv.ParseInt(string(*v.IntValue), 10, 64)
+	case v.DoubleValue != nil:
+		return strconv.ParseFloat(string(*v.DoubleValue), 64)
+	case v.BytesValue != nil:
+		return *v.BytesValue, nil
+	case v.ArrayValue != nil:
+		values := make([]interface{}, len(v.ArrayValue.Values))
+		for i, elem := range v.ArrayValue.Values {
+			var err error
+			if values[i], err = elem.dynamic(); err != nil {
+				return nil, err
+			}
+		}
+		return values, nil
+	case v.KvlistValue != nil:
+		return otlpAttributes(v.KvlistValue.Values)
+	}
+	return nil, nil
+}
+
+func otlpAttributes(kvs []otlpKeyValue) (map[string]interface{}, error) {
+	attributes := make(map[string]interface{}, len(kvs))
+	for _, kv := range kvs {
+		v, err := kv.Value.dynamic()
+		if err != nil {
+			return nil, fmt.Errorf("attribute %s: %v", kv.Key, err)
+		}
+		attributes[kv.Key] = v
+	}
+	return attributes, nil
+}
+
+// ReadOTLPSpans reads spans in the OTLP JSON encoding: a stream of
+// ExportTraceServiceRequest messages (objects with a "resourceSpans" field),
+// as written by the OpenTelemetry Collector's file exporter. A span's service
+// is its resource's service.name attribute.
+func ReadOTLPSpans(r io.Reader) ([]Span, error) {
+	dec := json.NewDecoder(decompressed(r))
+	dec.UseNumber()
+	var spans []Span
+	for {
+		var traces otlpTraces
+		if err := dec.Decode(&traces); err == io.EOF {
+			return spans, nil
+		} else if err != nil {
+			return nil, err
+		}
+		for _, rs := range traces.ResourceSpans {
+			resource, err := otlpAttributes(rs.Resource.Attributes)
+			if err != nil {
+				return nil, fmt.Errorf("resource: %v", err)
+			}
+			service, _ := resource["service.name"].(string)
+			for _, ss := range rs.ScopeSpans {
+				for _, s := range ss.Spans {
+					span, err := s.span(service)
+					if err != nil {
+						return nil, fmt.Errorf("span %s: %v", s.SpanId, err)
+					}
+					spans = append(spans, span)
+				}
+			}
+		}
+	}
+}
+
+func (s otlpSpan) span(service string) (Span, error) {
+	span := Span{
+		TraceId:      s.TraceId,
+		SpanId:       s.SpanId,
+		ParentSpanId: s.ParentSpanId,
+		Name:         s.Name,
+		Service:      service,
+	}
+	var err error
+	if span.Start, err = strconv.ParseInt(string(s.StartTimeUnixNano), 10, 64); err != nil {
+		return span, fmt.Errorf("startTimeUnixNano: %v", err)
+	}
+	if span.End, err = strconv.ParseInt(string(s.EndTimeUnixNano), 10, 64); err != nil {
+		return span, fmt.Errorf("endTimeUnixNano: %v", err)
+	}
+	if span.Attributes, err = otlpAttributes(s.Attributes); err != nil {
+		return span, err
+	}
+	// the status code is an enum, encoded either by number or by name
+	code := string(bytes.Trim(s.Status.Code, `"`))
+	span.Error = code == "2" || code == "STATUS_CODE_ERROR"
+	return span, nil
+}
+
+// Jaeger JSON encoding, as returned by the Jaeger query service's API and
+// its UI's "Download JSON"
+
+type jaegerTraces struct {
+	Data []struct {
+		Spans []struct {
+			TraceId       string `json:"traceID"`
+			SpanId        string `json:"spanID"`
+			OperationName string `json:"operationName"`
+			References    []struct {
+				RefType string `json:"refType"`
+				SpanId  string `json:"spanID"`
+			} `json:"references"`
+			StartTime int64       `json:"startTime"`
+			Duration  int64       `json:"duration"`
+			Tags      []jaegerTag `json:"tags"`
+			ProcessId string      `json:"processID"`
+		} `json:"spans"`
+		Processes map[string]struct {
+			ServiceName string `json:"serviceName"`
+		} `json:"processes"`
+	} `json:"data"`
+}
+
+type jaegerTag struct {
+	Key   string      `json:"key"`
+	Value interface{} `json:"value"`
+}
+
+// ReadJaegerSpans reads spans in the JSON format returned by the Jaeger query
+// API (an object with a "data" field holding a list of traces). Jaeger times
+// are in microseconds, and are converted to nanoseconds. A span is an error if
+// it has the tag error=true.
+func ReadJaegerSpans(r io.Reader) ([]Span, error) {
+	dec := json.NewDecoder(decompressed(r))
+	dec.UseNumber()
+	var traces jaegerTraces
+	if err := dec.Decode(&traces); err != nil {
+		return nil, err
+	}
+	var spans []Span
+	for _, trace := range traces.Data {
+		for _, s := range trace.Spans {
+			span := Span{
+				TraceId:    s.TraceId,
+				SpanId:     s.SpanId,
+				Name:       s.OperationName,
+				Service:    trace.Processes[s.ProcessId].ServiceName,
+				Start:      s.StartTime * 1000,
+				End:        (s.StartTime + s.Duration) * 1000,
+				Attributes: make(map[string]interface{}, len(s.Tags)),
+			}
+			for _, ref := range s.References {
+				if ref.RefType == "CHILD_OF" {
+					span.ParentSpanId = ref.SpanId
+					break
+				}
+			}
+			for _, tag := range s.Tags {
+				span.Attributes[tag.Key] = normalizeJSON(tag.Value)
+			}
+			span.Error = span.Attributes["error"] == true
+			spans = append(spans, span)
+		}
+	}
+	return spans, nil
+}
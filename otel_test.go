@@ -0,0 +1,128 @@
+package porcupine
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const otlpTestTraces = `{"resourceSpans": [{
+  "resource": {"attributes": [{"key": "service.name", "value": {"stringValue": "kv-client"}}]},
+  "scopeSpans": [{"spans": [
+    {"traceId": "t1", "spanId": "a", "name": "kv.put", "startTimeUnixNano": "1000", "endTimeUnixNano": "5000",
+     "attributes": [{"key": "key", "value": {"stringValue": "x"}}, {"key": "value", "value": {"stringValue": "a"}}]},
+    {"traceId": "t2", "spanId": "b", "name": "kv.get", "startTimeUnixNano": 2000, "endTimeUnixNano": 3000,
+     "attributes": [{"key": "key", "value": {"stringValue": "x"}}, {"key": "result", "value": {"stringValue": "a"}},
+                    {"key": "attempt", "value": {"intValue": "2"}}]},
+    {"traceId": "t2", "spanId": "c", "parentSpanId": "b", "name": "rpc", "startTimeUnixNano": "2100", "endTimeUnixNano": "2900",
+     "status": {"code": 2}},
+    {"traceId": "t3", "spanId": "d", "name": "kv.get", "startTimeUnixNano": "6000", "endTimeUnixNano": "7000",
+     "attributes": [{"key": "key", "value": {"stringValue": "x"}}, {"key": "result", "value": {"stringValue": "a"}}],
+     "status": {"code": "STATUS_CODE_ERROR"}}
+  ]}]
+}]}`
+
+const jaegerTestTraces = `{"data": [{
+  "traceID": "t1",
+  "spans": [
+    {"traceID": "t1", "spanID": "a", "operationName": "kv.put", "references": [], "startTime": 1, "duration": 4,
+     "tags": [{"key": "key", "type": "string", "value": "x"}, {"key": "value", "type": "string", "value": "a"}], "processID": "p1"},
+    {"traceID": "t1", "spanID": "b", "operationName": "kv.get", "references": [{"refType": "CHILD_OF", "traceID": "t1", "spanID": "a"}],
+     "startTime": 2, "duration": 1,
+     "tags": [{"key": "key", "type": "string", "value": "x"}, {"key": "result", "type": "string", "value": "b"},
+              {"key": "error", "type": "bool", "value": true}], "processID": "p1"}
+  ],
+  "processes": {"p1": {"serviceName": "kv-client", "tags": []}}
+}]}`
+
+var kvSpanMapping = SpanMapping{
+	Filter: func(span Span) bool {
+		return strings.HasPrefix(span.Name, "kv.")
+	},
+	Input: func(span Span) (interface{}, error) {
+		key, _ := span.Attributes["key"].(string)
+		switch span.Name {
+		case "kv.get":
+			return kvInput{op: 0, key: key}, nil
+		case "kv.put":
+			value, _ := span.Attributes["value"].(string)
+			return kvInput{op: 1, key: key, value: value}, nil
+		}
+		return nil, fmt.Errorf("unknown operation %s", span.Name)
+	},
+	Output: func(span Span) (interface{}, error) {
+		result, _ := span.Attributes["result"].(string)
+		return kvOutput{result}, nil
+	},
+}
+
+func TestReadOTLPSpans(t *testing.T) {
+	spans, err := ReadOTLPSpans(strings.NewReader(otlpTestTraces))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(spans) != 4 {
+		t.Fatalf("expected 4 spans, got %d", len(spans))
+	}
+	b := spans[1]
+	if b.Service != "kv-client" || b.Start != 2000 || b.End != 3000 || b.Attributes["attempt"] != int64(2) || b.Error {
+		t.Fatalf("unexpected span %+v", b)
+	}
+	if spans[2].ParentSpanId != "b" || !spans[2].Error || !spans[3].Error {
+		t.Fatalf("unexpected spans %+v", spans[2:])
+	}
+
+	ops, err := SpanOperations(spans, kvSpanMapping)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Operation{
+		{0, kvInput{op: 1, key: "x", value: "a"}, 1000, kvOutput{}, 5000},
+		{1, kvInput{op: 0, key: "x"}, 2000, kvOutput{"a"}, 3000},
+		{0, kvInput{op: 0, key: "x"}, 6000, kvOutput{"a"}, 7000},
+	}
+	if !reflect.DeepEqual(expected, ops) {
+		t.Fatalf("expected %v, got %v", expected, ops)
+	}
+	if !CheckOperations(kvModel, ops) {
+		t.Fatal("expected operations to be linearizable")
+	}
+}
+
+func TestReadJaegerSpans(t *testing.T) {
+	spans, err := ReadJaegerSpans(strings.NewReader(jaegerTestTraces))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+	b := spans[1]
+	if b.Service != "kv-client" || b.ParentSpanId != "a" || b.Start != 2000 || b.End != 3000 || !b.Error {
+		t.Fatalf("unexpected span %+v", b)
+	}
+
+	mapping := kvSpanMapping
+	mapping.Client = func(span Span) int {
+		return 5
+	}
+	ops, err := SpanOperations(spans, mapping)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ops[0].ClientId != 5 || ops[1].ClientId != 5 {
+		t.Fatalf("unexpected operations %v", ops)
+	}
+	if CheckOperations(kvModel, ops) {
+		t.Fatal("expected operations to not be linearizable")
+	}
+}
+
+func TestSpanOperationsError(t *testing.T) {
+	spans := []Span{{SpanId: "a", Name: "kv.cas"}}
+	_, err := SpanOperations(spans, kvSpanMapping)
+	if err == nil || err.Error() != "span a: unknown operation kv.cas" {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
@@ -0,0 +1,147 @@
+package porcupine
+
+import (
+	"fmt"
+	"html"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// VisualizePartitions writes a visualization of each partition of a history
+// to its own file in the directory dir, which is created if needed, along
+// with an index.html page that links to them, for histories with too many
+// partitions, e.g., keys, to show on a single page. The index lists the
+// partitions that were not linearized first, with each partition's
+// description (see Model.DescribePartition), number of operations, and
+// status.
+//
+// Each partition's page is written with [VisualizeWithOptions], with its own
+// timeline, and with the annotations that fall within the partition's time
+// span: those with a Tag, and those for the partition's clients.
+func VisualizePartitions(model Model, info LinearizationInfo, dir string, opts VisualizeOptions) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	model = fillDefault(model)
+	linearized, operations := info.LongestPrefixes()
+	timedOut := make(map[int]bool)
+	for _, p := range info.TimedOutPartitions() {
+		timedOut[p] = true
+	}
+	type page struct {
+		file        string
+		description string
+		operations  int
+		status      string
+		failed      bool
+	}
+	var pages []page
+	for p, history := range info.history {
+		if len(history) == 0 {
+			continue
+		}
+		ops := entryOperations(history)
+		pg := page{
+			file:        fmt.Sprintf("partition-%d.html", p),
+			description: fmt.Sprintf("Partition %d", p),
+			operations:  len(ops),
+			status:      "linearized",
+		}
+		if model.DescribePartition != nil {
+			pg.description = model.DescribePartition(ops)
+		}
+		if p < len(linearized) && linearized[p] < operations[p] {
+			pg.status = fmt.Sprintf("not linearized (%d of %d operations)", linearized[p], operations[p])
+			pg.failed = true
+		}
+		if timedOut[p] {
+			pg.status = "timed out"
+			pg.failed = true
+		}
+		partition := partitionInfo(info, p, ops)
+		if err := VisualizePathWithOptions(model, partition, filepath.Join(dir, pg.file), opts); err != nil {
+			return err
+		}
+		pages = append(pages, pg)
+	}
+	sort.SliceStable(pages, func(i, j int) bool {
+		return pages[i].failed && !pages[j].failed
+	})
+
+	failed := 0
+	for _, pg := range pages {
+		if pg.failed {
+			failed++
+		}
+	}
+	var b strings.Builder
+	b.WriteString("<!doctype html>\n<html>\n<head>\n<meta charset=\"UTF-8\" />\n<title>Porcupine</title>\n<style>\n")
+	b.WriteString(opts.css())
+	b.WriteString(pagesCSS)
+	b.WriteString("</style>\n</head>\n<body>\n")
+	fmt.Fprintf(&b, "<p>%d of %d partitions not linearized</p>\n", failed, len(pages))
+	b.WriteString("<table>\n<tr><th>Partition</th><th>Operations</th><th>Status</th></tr>\n")
+	for _, pg := range pages {
+		class := ""
+		if pg.failed {
+			class = ` class="failed"`
+		}
+		fmt.Fprintf(&b, "<tr%s><td><a href=\"%s\">%s</a></td><td>%d</td><td>%s</td></tr>\n",
+			class, pg.file, html.EscapeString(pg.description), pg.operations, html.EscapeString(pg.status))
+	}
+	b.WriteString("</table>\n</body>\n</html>\n")
+	return os.WriteFile(filepath.Join(dir, "index.html"), []byte(b.String()), 0o644)
+}
+
+const pagesCSS = `
+html {
+  font-family: Helvetica, Arial, sans-serif;
+  font-size: var(--font-size);
+  background-color: var(--background);
+  color: var(--text);
+}
+a {
+  color: var(--link);
+}
+th, td {
+  text-align: left;
+  padding: 2px 10px 2px 0;
+  border-bottom: 1px solid var(--divider);
+}
+.failed td {
+  color: var(--illegal-linearization);
+}
+`
+
+// partitionInfo returns the part of info for partition p, whose operations
+// are ops, for VisualizePartitions.
+func partitionInfo(info LinearizationInfo, p int, ops []Operation) LinearizationInfo {
+	partition := LinearizationInfo{
+		history:               [][]entry{info.history[p]},
+		partialLinearizations: [][][]int{nil},
+		showPlacement:         info.showPlacement,
+	}
+	if p < len(info.partialLinearizations) {
+		partition.partialLinearizations[0] = info.partialLinearizations[p]
+	}
+	start, end := ops[0].Call, ops[0].Return
+	clients := make(map[int]bool)
+	for _, op := range ops {
+		if op.Call < start {
+			start = op.Call
+		}
+		if op.Return > end {
+			end = op.Return
+		}
+		clients[op.ClientId] = true
+	}
+	for _, a := range info.annotations {
+		if a.End < start || a.Start > end || (a.Tag == "" && !clients[a.ClientId]) {
+			continue
+		}
+		partition.annotations = append(partition.annotations, a)
+	}
+	return partition
+}
@@ -0,0 +1,51 @@
+package porcupine
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestVisualizePartitions(t *testing.T) {
+	ops := []Operation{
+		{0, kvInput{op: 1, key: "x", value: "a"}, 0, kvOutput{}, 10},
+		{1, kvInput{op: 0, key: "x"}, 20, kvOutput{""}, 30},
+		{2, kvInput{op: 1, key: "y", value: "b"}, 100, kvOutput{}, 110},
+	}
+	model := kvModel
+	model.DescribePartition = func(history []Operation) string {
+		return "key " + history[0].Input.(kvInput).key
+	}
+	res, info := CheckOperationsVerbose(model, ops, 0)
+	if res != Illegal {
+		t.Fatalf("expected output %v, got output %v", Illegal, res)
+	}
+	info.AddAnnotations([]Annotation{
+		{Tag: "Test Framework", Start: 5, Description: "during x"},
+		{Tag: "Test Framework", Start: 105, Description: "during y"},
+	})
+	dir := filepath.Join(t.TempDir(), "viz")
+	if err := VisualizePartitions(model, info, dir, VisualizeOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	index, err := os.ReadFile(filepath.Join(dir, "index.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	x := strings.Index(string(index), `<a href="partition-0.html">key x</a>`)
+	y := strings.Index(string(index), `<a href="partition-1.html">key y</a>`)
+	if x < 0 || y < 0 || x > y {
+		t.Fatalf("expected the index to list key x, which failed, before key y:\n%s", index)
+	}
+	if !strings.Contains(string(index), "1 of 2 partitions not linearized") {
+		t.Fatalf("expected a summary in the index:\n%s", index)
+	}
+	page, err := os.ReadFile(filepath.Join(dir, "partition-1.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(page), "during y") || strings.Contains(string(page), "during x") {
+		t.Fatal("expected each page to have the annotations in its time span")
+	}
+}
@@ -0,0 +1,405 @@
+package porcupine
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// This file implements a minimal Parquet writer: one row group, with one
+// uncompressed, PLAIN-encoded data page per column, and the file metadata in
+// the Thrift compact protocol. These are the basic features that every Parquet
+// reader supports, and they avoid depending on a Parquet library.
+
+// Parquet physical types
+const (
+	parquetBoolean   = 0
+	parquetInt64     = 2
+	parquetByteArray = 6
+)
+
+// Parquet encodings
+const (
+	parquetPlain = 0
+	parquetRLE   = 3
+)
+
+type parquetColumn struct {
+	name     string
+	typ      int32
+	optional bool
+	defined  []bool // for optional columns, whether each row has a value
+	int64s   []int64
+	bools    []bool
+	bytes    [][]byte
+}
+
+func (c *parquetColumn) rows() int {
+	switch {
+	case c.optional:
+		return len(c.defined)
+	case c.typ == parquetInt64:
+		return len(c.int64s)
+	case c.typ == parquetBoolean:
+		return len(c.bools)
+	default:
+		return len(c.bytes)
+	}
+}
+
+// WriteParquetOperations writes a history of operations as a Parquet file,
+// with one row per operation and the columns
+//
+//	client (int64), call (int64), return (int64), latency (int64,
+//	return - call), input (binary), output (binary)
+//
+// Inputs and outputs are encoded with the given codec; if codec is nil, they
+// are encoded as JSON.
+func WriteParquetOperations(w io.Writer, history []Operation, codec Codec) error {
+	columns, err := parquetOperationColumns(history, codec)
+	if err != nil {
+		return err
+	}
+	return writeParquet(w, columns, nil)
+}
+
+// WriteParquetCheck writes the operations of a checked history as a Parquet
+// file, along with per-operation linearization metadata, so that large
+// corpora of checks can be analyzed with query engines. The
+// LinearizationInfo must come from one of the verbose check functions, e.g.,
+// [CheckOperationsVerbose]. In addition to the columns written by
+// [WriteParquetOperations], there are the columns
+//
+//	partition (int64): the operation's partition
+//	linearized (bool): whether the operation is part of the partition's
+//	    longest partial linearization
+//	linearization_index (int64, nullable): the operation's position in
+//	    that linearization
+//	window_earliest, window_latest (int64, nullable): the window in which
+//	    the operation's linearization point can fall, if the partition is
+//	    fully linearized (see [LinearizationInfo.PlacementReport])
+//
+// The result is stored in the file's key-value metadata, under
+// "porcupine.result".
+func WriteParquetCheck(w io.Writer, result CheckResult, info LinearizationInfo, codec Codec) error {
+	var history []Operation
+	partition := &parquetColumn{name: "partition", typ: parquetInt64}
+	linearized := &parquetColumn{name: "linearized", typ: parquetBoolean}
+	index := &parquetColumn{name: "linearization_index", typ: parquetInt64, optional: true}
+	earliest := &parquetColumn{name: "window_earliest", typ: parquetInt64, optional: true}
+	latest := &parquetColumn{name: "window_latest", typ: parquetInt64, optional: true}
+	for p, entries := range info.history {
+		ops := entryOperations(entries)
+		history = append(history, ops...)
+		var longest []int
+		for _, partial := range info.partialLinearizations[p] {
+			if len(partial) > len(longest) {
+				longest = partial
+			}
+		}
+		position := make(map[int]int)
+		for i, id := range longest {
+			position[id] = i
+		}
+		windows, order, full := linearizationWindows(entries, info.partialLinearizations[p])
+		windowOf := make(map[int]LinearizationWindow)
+		for i, id := range order {
+			windowOf[id] = windows[i]
+		}
+		for id := range ops {
+			partition.int64s = append(partition.int64s, int64(p))
+			i, ok := position[id]
+			linearized.bools = append(linearized.bools, ok)
+			index.appendOptionalInt64(int64(i), ok)
+			window := windowOf[id]
+			earliest.appendOptionalInt64(window.Earliest, full)
+			latest.appendOptionalInt64(window.Latest, full)
+		}
+	}
+	columns, err := parquetOperationColumns(history, codec)
+	if err != nil {
+		return err
+	}
+	columns = append([]*parquetColumn{partition}, columns...)
+	columns = append(columns, linearized, index, earliest, latest)
+	return writeParquet(w, columns, map[string]string{"porcupine.result": string(result)})
+}
+
+func (c *parquetColumn) appendOptionalInt64(v int64, ok bool) {
+	c.defined = append(c.defined, ok)
+	if ok {
+		c.int64s = append(c.int64s, v)
+	}
+}
+
+func parquetOperationColumns(history []Operation, codec Codec) ([]*parquetColumn, error) {
+	if codec == nil {
+		codec = &SchemaCodec{}
+	}
+	client := &parquetColumn{name: "client", typ: parquetInt64}
+	call := &parquetColumn{name: "call", typ: parquetInt64}
+	ret := &parquetColumn{name: "return", typ: parquetInt64}
+	latency := &parquetColumn{name: "latency", typ: parquetInt64}
+	input := &parquetColumn{name: "input", typ: parquetByteArray}
+	output := &parquetColumn{name: "output", typ: parquetByteArray}
+	for i, op := range history {
+		jop, err := encodeOperation(codec, op)
+		if err != nil {
+			return nil, fmt.Errorf("operation %d: %v", i, err)
+		}
+		client.int64s = append(client.int64s, int64(op.ClientId))
+		call.int64s = append(call.int64s, op.Call)
+		ret.int64s = append(ret.int64s, op.Return)
+		latency.int64s = append(latency.int64s, op.Return-op.Call)
+		input.bytes = append(input.bytes, jop.Input)
+		output.bytes = append(output.bytes, jop.Output)
+	}
+	return []*parquetColumn{client, call, ret, latency, input, output}, nil
+}
+
+func writeParquet(w io.Writer, columns []*parquetColumn, metadata map[string]string) error {
+	rows := 0
+	if len(columns) > 0 {
+		rows = columns[0].rows()
+	}
+	file := []byte("PAR1")
+	type chunk struct {
+		offset, size int
+	}
+	chunks := make([]chunk, len(columns))
+	for i, c := range columns {
+		data := c.pageData()
+		var header thriftWriter
+		header.i32(1, 0) // type: DATA_PAGE
+		header.i32(2, int32(len(data)))
+		header.i32(3, int32(len(data)))
+		header.beginStruct(5) // data_page_header
+		header.i32(1, int32(rows))
+		header.i32(2, parquetPlain)
+		header.i32(3, parquetRLE)
+		header.i32(4, parquetRLE)
+		header.endStruct()
+		header.stop()
+		chunks[i] = chunk{offset: len(file), size: len(header.b) + len(data)}
+		file = append(file, header.b...)
+		file = append(file, data...)
+	}
+
+	var meta thriftWriter
+	meta.i32(1, 1) // version
+	meta.beginList(2, thriftStruct, len(columns)+1)
+	meta.beginElement()
+	meta.binary(4, []byte("schema"))
+	meta.i32(5, int32(len(columns)))
+	meta.endStruct()
+	for _, c := range columns {
+		meta.beginElement()
+		meta.i32(1, c.typ)
+		repetition := int32(0) // REQUIRED
+		if c.optional {
+			repetition = 1 // OPTIONAL
+		}
+		meta.i32(3, repetition)
+		meta.binary(4, []byte(c.name))
+		meta.endStruct()
+	}
+	meta.i64(3, int64(rows))
+	meta.beginList(4, thriftStruct, 1)
+	meta.beginElement() // row group
+	meta.beginList(1, thriftStruct, len(columns))
+	total := 0
+	for i, c := range columns {
+		meta.beginElement() // column chunk
+		meta.i64(2, int64(chunks[i].offset))
+		meta.beginStruct(3) // column metadata
+		meta.i32(1, c.typ)
+		meta.beginList(2, thriftI32, 2)
+		meta.listI32(parquetPlain)
+		meta.listI32(parquetRLE)
+		meta.beginList(3, thriftBinary, 1)
+		meta.listBinary([]byte(c.name))
+		meta.i32(4, 0) // codec: UNCOMPRESSED
+		meta.i64(5, int64(rows))
+		meta.i64(6, int64(chunks[i].size))
+		meta.i64(7, int64(chunks[i].size))
+		meta.i64(9, int64(chunks[i].offset))
+		meta.endStruct()
+		meta.endStruct()
+		total += chunks[i].size
+	}
+	meta.i64(2, int64(total))
+	meta.i64(3, int64(rows))
+	meta.endStruct()
+	if len(metadata) > 0 {
+		keys := make([]string, 0, len(metadata))
+		for k := range metadata {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		meta.beginList(5, thriftStruct, len(keys))
+		for _, k := range keys {
+			meta.beginElement()
+			meta.binary(1, []byte(k))
+			meta.binary(2, []byte(metadata[k]))
+			meta.endStruct()
+		}
+	}
+	meta.binary(6, []byte("porcupine"))
+	meta.stop()
+
+	file = append(file, meta.b...)
+	file = appendUint32LE(file, uint32(len(meta.b)))
+	file = append(file, "PAR1"...)
+	_, err := w.Write(file)
+	return err
+}
+
+// pageData returns the contents of a data page holding the column's values:
+// definition levels, for optional columns, followed by the PLAIN-encoded
+// values.
+func (c *parquetColumn) pageData() []byte {
+	var b []byte
+	if c.optional {
+		levels := rleBooleans(c.defined)
+		b = appendUint32LE(b, uint32(len(levels)))
+		b = append(b, levels...)
+	}
+	switch c.typ {
+	case parquetInt64:
+		for _, v := range c.int64s {
+			b = appendUint64LE(b, uint64(v))
+		}
+	case parquetBoolean:
+		packed := make([]byte, (len(c.bools)+7)/8)
+		for i, v := range c.bools {
+			if v {
+				packed[i/8] |= 1 << (i % 8)
+			}
+		}
+		b = append(b, packed...)
+	case parquetByteArray:
+		for _, v := range c.bytes {
+			b = appendUint32LE(b, uint32(len(v)))
+			b = append(b, v...)
+		}
+	}
+	return b
+}
+
+// rleBooleans encodes a sequence of 0/1 levels with the RLE/bit-packing
+// hybrid encoding, using only RLE runs.
+func rleBooleans(values []bool) []byte {
+	var b []byte
+	for i := 0; i < len(values); {
+		j := i
+		for j < len(values) && values[j] == values[i] {
+			j++
+		}
+		b = appendVarint(b, uint64(j-i)<<1)
+		if values[i] {
+			b = append(b, 1)
+		} else {
+			b = append(b, 0)
+		}
+		i = j
+	}
+	return b
+}
+
+// Thrift compact protocol types
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// A thriftWriter encodes structs in the Thrift compact protocol.
+type thriftWriter struct {
+	b     []byte
+	last  int16   // ID of the last field written in the current struct
+	stack []int16 // last field IDs of enclosing structs
+}
+
+func (t *thriftWriter) field(id int16, typ byte) {
+	if delta := id - t.last; delta > 0 && delta <= 15 {
+		t.b = append(t.b, byte(delta)<<4|typ)
+	} else {
+		t.b = append(t.b, typ)
+		t.b = appendVarint(t.b, zigzag(int64(id)))
+	}
+	t.last = id
+}
+
+func (t *thriftWriter) i32(id int16, v int32) {
+	t.field(id, thriftI32)
+	t.b = appendVarint(t.b, zigzag(int64(v)))
+}
+
+func (t *thriftWriter) i64(id int16, v int64) {
+	t.field(id, thriftI64)
+	t.b = appendVarint(t.b, zigzag(v))
+}
+
+func (t *thriftWriter) binary(id int16, v []byte) {
+	t.field(id, thriftBinary)
+	t.listBinary(v)
+}
+
+func (t *thriftWriter) beginStruct(id int16) {
+	t.field(id, thriftStruct)
+	t.beginElement()
+}
+
+// beginElement begins a struct that is an element of a list.
+func (t *thriftWriter) beginElement() {
+	t.stack = append(t.stack, t.last)
+	t.last = 0
+}
+
+func (t *thriftWriter) endStruct() {
+	t.stop()
+	t.last = t.stack[len(t.stack)-1]
+	t.stack = t.stack[:len(t.stack)-1]
+}
+
+func (t *thriftWriter) stop() {
+	t.b = append(t.b, 0)
+}
+
+func (t *thriftWriter) beginList(id int16, elem byte, n int) {
+	t.field(id, thriftList)
+	if n < 15 {
+		t.b = append(t.b, byte(n)<<4|elem)
+	} else {
+		t.b = append(t.b, 0xf0|elem)
+		t.b = appendVarint(t.b, uint64(n))
+	}
+}
+
+func (t *thriftWriter) listI32(v int32) {
+	t.b = appendVarint(t.b, zigzag(int64(v)))
+}
+
+func (t *thriftWriter) listBinary(v []byte) {
+	t.b = appendVarint(t.b, uint64(len(v)))
+	t.b = append(t.b, v...)
+}
+
+func appendUint32LE(b []byte, v uint32) []byte {
+	var buf [4]byte
+	binary.LittleEndian.PutUint32(buf[:], v)
+	return append(b, buf[:]...)
+}
+
+func appendUint64LE(b []byte, v uint64) []byte {
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], v)
+	return append(b, buf[:]...)
+}
+
+func zigzag(v int64) uint64 {
+	return uint64(v<<1) ^ uint64(v>>63)
+}
@@ -0,0 +1,197 @@
+package porcupine
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+// readThrift decodes a Thrift compact protocol struct into a map from field
+// ID to value, for checking the Parquet writer's output. It returns the rest
+// of the input.
+func readThrift(t *testing.T, b []byte) (map[int16]interface{}, []byte) {
+	fields := make(map[int16]interface{})
+	last := int16(0)
+	for {
+		header := b[0]
+		b = b[1:]
+		if header == 0 {
+			return fields, b
+		}
+		typ := header & 0x0f
+		if delta := header >> 4; delta != 0 {
+			last += int16(delta)
+		} else {
+			v, n := readVarint(b)
+			b = b[n:]
+			last = int16(unzigzag(v))
+		}
+		fields[last], b = readThriftValue(t, typ, b)
+	}
+}
+
+func readThriftValue(t *testing.T, typ byte, b []byte) (interface{}, []byte) {
+	switch typ {
+	case thriftI32, thriftI64:
+		v, n := readVarint(b)
+		return unzigzag(v), b[n:]
+	case thriftBinary:
+		length, n := readVarint(b)
+		return string(b[n : n+int(length)]), b[n+int(length):]
+	case thriftStruct:
+		return readThrift(t, b)
+	case thriftList:
+		size := int(b[0] >> 4)
+		elem := b[0] & 0x0f
+		b = b[1:]
+		if size == 15 {
+			v, n := readVarint(b)
+			size = int(v)
+			b = b[n:]
+		}
+		list := make([]interface{}, size)
+		for i := range list {
+			list[i], b = readThriftValue(t, elem, b)
+		}
+		return list, b
+	}
+	t.Fatalf("unexpected thrift type %d", typ)
+	return nil, nil
+}
+
+func unzigzag(v uint64) int64 {
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+// readParquet decodes the columns of a file written by writeParquet, as
+// int64, bool, or string values, with nil for nulls.
+func readParquet(t *testing.T, file []byte) (map[string][]interface{}, map[int16]interface{}) {
+	if string(file[:4]) != "PAR1" || string(file[len(file)-4:]) != "PAR1" {
+		t.Fatal("missing magic number")
+	}
+	footerLen := int(binary.LittleEndian.Uint32(file[len(file)-8:]))
+	meta, rest := readThrift(t, file[len(file)-8-footerLen:len(file)-8])
+	if len(rest) != 0 {
+		t.Fatalf("trailing bytes after file metadata")
+	}
+	rows := int(meta[3].(int64))
+	schema := meta[2].([]interface{})
+	columns := make(map[string][]interface{})
+	chunks := meta[4].([]interface{})[0].(map[int16]interface{})[1].([]interface{})
+	for i, chunk := range chunks {
+		element := schema[i+1].(map[int16]interface{})
+		name := element[4].(string)
+		colMeta := chunk.(map[int16]interface{})[3].(map[int16]interface{})
+		offset := colMeta[9].(int64)
+		header, data := readThrift(t, file[offset:])
+		data = data[:header[2].(int64)]
+		defined := make([]bool, rows)
+		for j := range defined {
+			defined[j] = true
+		}
+		if element[3].(int64) == 1 {
+			// definition levels, as RLE runs
+			length := int(binary.LittleEndian.Uint32(data))
+			levels := data[4 : 4+length]
+			data = data[4+length:]
+			j := 0
+			for len(levels) > 0 {
+				run, n := readVarint(levels)
+				for k := 0; k < int(run>>1); k++ {
+					defined[j] = levels[n] == 1
+					j++
+				}
+				levels = levels[n+1:]
+			}
+		}
+		values := make([]interface{}, rows)
+		bit := 0
+		for j := range values {
+			if !defined[j] {
+				continue
+			}
+			switch element[1].(int64) {
+			case parquetInt64:
+				values[j] = int64(binary.LittleEndian.Uint64(data))
+				data = data[8:]
+			case parquetBoolean:
+				values[j] = data[bit/8]&(1<<(bit%8)) != 0
+				bit++
+			case parquetByteArray:
+				length := int(binary.LittleEndian.Uint32(data))
+				values[j] = string(data[4 : 4+length])
+				data = data[4+length:]
+			}
+		}
+		columns[name] = values
+	}
+	return columns, meta
+}
+
+func TestWriteParquetOperations(t *testing.T) {
+	ops := []Operation{
+		{0, kvInput{op: 1, key: "x", value: "a"}, 0, kvOutput{}, 10},
+		{1, kvInput{op: 0, key: "x"}, 5, kvOutput{"a"}, 25},
+	}
+	var buf bytes.Buffer
+	if err := WriteParquetOperations(&buf, ops, kvCodec); err != nil {
+		t.Fatal(err)
+	}
+	columns, _ := readParquet(t, buf.Bytes())
+	expected := map[string][]interface{}{
+		"client":  {int64(0), int64(1)},
+		"call":    {int64(0), int64(5)},
+		"return":  {int64(10), int64(25)},
+		"latency": {int64(10), int64(20)},
+		"input":   {`[1,"x","a"]`, `[0,"x",""]`},
+		"output":  {`""`, `"a"`},
+	}
+	if !reflect.DeepEqual(expected, columns) {
+		t.Fatalf("expected %v, got %v", expected, columns)
+	}
+}
+
+func TestWriteParquetCheck(t *testing.T) {
+	ops := []Operation{
+		{0, registerInput{false, 1}, 0, 0, 100},
+		{1, registerInput{true, 0}, 10, 1, 20},
+		{2, registerInput{true, 0}, 30, 2, 40},
+	}
+	res, info := CheckOperationsVerbose(registerModel, ops, 0)
+	if res != Illegal {
+		t.Fatalf("expected output %v, got output %v", Illegal, res)
+	}
+	var buf bytes.Buffer
+	if err := WriteParquetCheck(&buf, res, info, nil); err != nil {
+		t.Fatal(err)
+	}
+	columns, meta := readParquet(t, buf.Bytes())
+	if !reflect.DeepEqual([]interface{}{true, true, false}, columns["linearized"]) {
+		t.Fatalf("unexpected linearized column %v", columns["linearized"])
+	}
+	if !reflect.DeepEqual([]interface{}{int64(0), int64(1), nil}, columns["linearization_index"]) {
+		t.Fatalf("unexpected linearization_index column %v", columns["linearization_index"])
+	}
+	if !reflect.DeepEqual([]interface{}{nil, nil, nil}, columns["window_earliest"]) {
+		t.Fatalf("expected no windows for a failed partition, got %v", columns["window_earliest"])
+	}
+	kv := meta[5].([]interface{})[0].(map[int16]interface{})
+	if kv[1] != "porcupine.result" || kv[2] != "Illegal" {
+		t.Fatalf("unexpected key-value metadata %v", kv)
+	}
+
+	ops[2].Output = 1
+	res, info = CheckOperationsVerbose(registerModel, ops, 0)
+	buf.Reset()
+	if err := WriteParquetCheck(&buf, res, info, nil); err != nil {
+		t.Fatal(err)
+	}
+	columns, _ = readParquet(t, buf.Bytes())
+	if !reflect.DeepEqual([]interface{}{int64(0), int64(10), int64(30)}, columns["window_earliest"]) {
+		t.Fatalf("unexpected window_earliest column %v", columns["window_earliest"])
+	}
+	if !reflect.DeepEqual([]interface{}{int64(20), int64(20), int64(40)}, columns["window_latest"]) {
+		t.Fatalf("unexpected window_latest column %v", columns["window_latest"])
+	}
+}
@@ -0,0 +1,231 @@
+package porcupine
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// persistedInfo is the serialized form of a LinearizationInfo.
+type persistedInfo struct {
+	Partitions    []persistedPartition `json:"partitions"`
+	Annotations   []Annotation         `json:"annotations,omitempty"`
+	ShowPlacement bool                 `json:"showPlacement,omitempty"`
+}
+
+type persistedPartition struct {
+	Entries               []persistedEntry `json:"entries"`
+	PartialLinearizations [][]int          `json:"partialLinearizations"`
+	TimedOut              bool             `json:"timedOut,omitempty"`
+}
+
+// A persistedEntry holds its value as is for gob, and encoded with a codec
+// for JSON.
+type persistedEntry struct {
+	Return bool              `json:"return,omitempty"`
+	Value  interface{}       `json:"value"`
+	Id     int               `json:"id"`
+	Time   int64             `json:"time"`
+	Client int               `json:"client"`
+	Tags   map[string]string `json:"tags,omitempty"`
+}
+
+func (li *LinearizationInfo) persist(encode func(e entry) (interface{}, error)) (persistedInfo, error) {
+	p := persistedInfo{
+		Partitions:    make([]persistedPartition, len(li.history)),
+		Annotations:   li.annotations,
+		ShowPlacement: li.showPlacement,
+	}
+	for i, history := range li.history {
+		entries := make([]persistedEntry, len(history))
+		for j, e := range history {
+			value, err := encode(e)
+			if err != nil {
+				return p, fmt.Errorf("partition %d: operation %d: %v", i, e.id, err)
+			}
+			entries[j] = persistedEntry{
+				Return: e.kind == returnEntry,
+				Value:  value,
+				Id:     e.id,
+				Time:   e.time,
+				Client: e.clientId,
+				Tags:   e.tags,
+			}
+		}
+		p.Partitions[i] = persistedPartition{Entries: entries, PartialLinearizations: li.partialLinearizations[i]}
+		if li.timedOut != nil {
+			p.Partitions[i].TimedOut = li.timedOut[i]
+		}
+	}
+	return p, nil
+}
+
+func (li *LinearizationInfo) restore(p persistedInfo, decode func(e persistedEntry, path string) (interface{}, error)) error {
+	info := LinearizationInfo{
+		history:               make([][]entry, len(p.Partitions)),
+		partialLinearizations: make([][][]int, len(p.Partitions)),
+		annotations:           p.Annotations,
+		showPlacement:         p.ShowPlacement,
+	}
+	for i, partition := range p.Partitions {
+		entries := make([]entry, len(partition.Entries))
+		ids := make(map[int]bool)
+		for j, e := range partition.Entries {
+			path := fmt.Sprintf("partitions[%d].entries[%d]", i, j)
+			value, err := decode(e, path)
+			if err != nil {
+				return err
+			}
+			kind := callEntry
+			if e.Return {
+				kind = returnEntry
+			}
+			entries[j] = entry{kind, value, e.Id, e.Time, e.Client, e.Tags}
+			ids[e.Id] = true
+		}
+		for _, partial := range partition.PartialLinearizations {
+			for _, id := range partial {
+				if !ids[id] {
+					return fmt.Errorf("partitions[%d]: partial linearization refers to unknown operation %d", i, id)
+				}
+			}
+		}
+		info.history[i] = entries
+		info.partialLinearizations[i] = partition.PartialLinearizations
+		if partition.TimedOut {
+			if info.timedOut == nil {
+				info.timedOut = make([]bool, len(p.Partitions))
+			}
+			info.timedOut[i] = true
+		}
+	}
+	*li = info
+	return nil
+}
+
+// GobEncode implements gob.GobEncoder, so that the result of an expensive
+// check can be saved and visualized later without checking again. Inputs and
+// outputs are encoded as interface values, so their concrete types must be
+// registered with gob.Register, both before encoding and before decoding.
+func (li LinearizationInfo) GobEncode() ([]byte, error) {
+	p, err := li.persist(func(e entry) (interface{}, error) {
+		return e.value, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(p); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// GobDecode implements gob.GobDecoder; see [LinearizationInfo.GobEncode].
+func (li *LinearizationInfo) GobDecode(data []byte) error {
+	var p persistedInfo
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&p); err != nil {
+		return err
+	}
+	return li.restore(p, func(e persistedEntry, path string) (interface{}, error) {
+		return e.Value, nil
+	})
+}
+
+// MarshalJSON implements json.Marshaler, encoding inputs and outputs with
+// encoding/json. See [WriteLinearizationInfoJSON] for the format.
+func (li LinearizationInfo) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := WriteLinearizationInfoJSON(&buf, li, nil); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler, decoding inputs and outputs as
+// dynamic values (see [Schema]). To decode them as the Go types that a model
+// expects, use [ReadLinearizationInfoJSON] with a [Codec].
+func (li *LinearizationInfo) UnmarshalJSON(data []byte) error {
+	info, err := ReadLinearizationInfoJSON(bytes.NewReader(data), nil)
+	if err != nil {
+		return err
+	}
+	*li = info
+	return nil
+}
+
+// WriteLinearizationInfoJSON writes the result of a verbose check as JSON,
+// so that the check can run once, e.g., in CI, and the visualization can be
+// regenerated later from the saved result with [ReadLinearizationInfoJSON]
+// and [Visualize]. The document holds, for each partition, the history as a
+// list of call and return entries, and the partial linearizations as lists of
+// operation IDs, along with any annotations:
+//
+//	{
+//	  "partitions": [
+//	    {
+//	      "entries": [
+//	        {"value": ..., "id": 0, "time": 0, "client": 0},
+//	        {"return": true, "value": ..., "id": 0, "time": 10, "client": 0},
+//	        ...
+//	      ],
+//	      "partialLinearizations": [[0, ...], ...]
+//	    },
+//	    ...
+//	  ],
+//	  "annotations": [...]
+//	}
+//
+// Inputs and outputs are encoded with the given codec; if codec is nil, they
+// are encoded as JSON.
+func WriteLinearizationInfoJSON(w io.Writer, info LinearizationInfo, codec Codec) error {
+	if codec == nil {
+		codec = &SchemaCodec{}
+	}
+	p, err := info.persist(func(e entry) (interface{}, error) {
+		var data []byte
+		var err error
+		if e.kind == callEntry {
+			data, err = codec.EncodeInput(e.value)
+		} else {
+			data, err = codec.EncodeOutput(e.value)
+		}
+		return json.RawMessage(data), err
+	})
+	if err != nil {
+		return err
+	}
+	return json.NewEncoder(w).Encode(p)
+}
+
+// ReadLinearizationInfoJSON reads the result of a check written by
+// [WriteLinearizationInfoJSON], decoding inputs and outputs with the given
+// codec. If codec is nil, they are decoded as dynamic values.
+func ReadLinearizationInfoJSON(r io.Reader, codec Codec) (LinearizationInfo, error) {
+	if codec == nil {
+		codec = &SchemaCodec{}
+	}
+	// values are decoded with json.Number for numbers, so that they can be
+	// re-encoded exactly for the codec
+	var p persistedInfo
+	dec := json.NewDecoder(decompressed(r))
+	dec.UseNumber()
+	if err := dec.Decode(&p); err != nil {
+		return LinearizationInfo{}, err
+	}
+	var info LinearizationInfo
+	err := info.restore(p, func(e persistedEntry, path string) (interface{}, error) {
+		decode := codec.DecodeInput
+		if e.Return {
+			decode = codec.DecodeOutput
+		}
+		data, err := json.Marshal(e.Value)
+		if err != nil {
+			return nil, err
+		}
+		return decodeWith(decode, data, path+".value")
+	})
+	return info, err
+}
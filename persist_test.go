@@ -0,0 +1,109 @@
+package porcupine
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+const persistHistory = `{"operations": [
+  {"client": 0, "input": {"op": "put", "key": "x", "value": "a"}, "call": 0, "output": null, "return": 10},
+  {"client": 1, "input": {"op": "get", "key": "x"}, "call": 5, "output": {"value": "b"}, "return": 20, "session": "s1"},
+  {"client": 2, "input": {"op": "put", "key": "y", "value": "c"}, "call": 30, "output": null, "return": 40}
+]}`
+
+func checkPersistHistory(t *testing.T) (CheckResult, LinearizationInfo) {
+	ops, err := ReadJSONOperations(strings.NewReader(persistHistory), kvSchemaCodec(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, info := CheckOperationsVerbose(dynamicKvModel, ops, 0)
+	if res != Illegal {
+		t.Fatalf("expected output %v, got output %v", Illegal, res)
+	}
+	info.AddAnnotations([]Annotation{{Tag: "server", Start: 3, Description: "leader elected"}})
+	return res, info
+}
+
+func visualizeString(t *testing.T, model Model, info LinearizationInfo) string {
+	var buf bytes.Buffer
+	if err := Visualize(model, info, &buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestLinearizationInfoGob(t *testing.T) {
+	gob.Register(map[string]interface{}{})
+	_, info := checkPersistHistory(t)
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(info); err != nil {
+		t.Fatal(err)
+	}
+	var decoded LinearizationInfo
+	if err := gob.NewDecoder(&buf).Decode(&decoded); err != nil {
+		t.Fatal(err)
+	}
+	if visualizeString(t, dynamicKvModel, info) != visualizeString(t, dynamicKvModel, decoded) {
+		t.Fatal("expected the same visualization after a gob round trip")
+	}
+}
+
+func TestLinearizationInfoJSON(t *testing.T) {
+	_, info := checkPersistHistory(t)
+	var buf bytes.Buffer
+	if err := WriteLinearizationInfoJSON(&buf, info, nil); err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := ReadLinearizationInfoJSON(&buf, kvSchemaCodec(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if visualizeString(t, dynamicKvModel, info) != visualizeString(t, dynamicKvModel, decoded) {
+		t.Fatal("expected the same visualization after a JSON round trip")
+	}
+
+	// and through encoding/json
+	data, err := json.Marshal(info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var unmarshaled LinearizationInfo
+	if err := json.Unmarshal(data, &unmarshaled); err != nil {
+		t.Fatal(err)
+	}
+	if len(unmarshaled.PartialLinearizations()) != 2 {
+		t.Fatalf("expected 2 partitions, got %d", len(unmarshaled.PartialLinearizations()))
+	}
+}
+
+func TestLinearizationInfoJSONTyped(t *testing.T) {
+	ops := []Operation{
+		{0, kvInput{op: 1, key: "x", value: "a"}, 0, kvOutput{}, 10},
+		{1, kvInput{op: 0, key: "x"}, 20, kvOutput{"a"}, 30},
+	}
+	res, info := CheckOperationsVerbose(kvModel, ops, 0)
+	if res != Ok {
+		t.Fatalf("expected output %v, got output %v", Ok, res)
+	}
+	var buf bytes.Buffer
+	if err := WriteLinearizationInfoJSON(&buf, info, kvCodec); err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := ReadLinearizationInfoJSON(&buf, kvCodec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if visualizeString(t, kvModel, info) != visualizeString(t, kvModel, decoded) {
+		t.Fatal("expected the same visualization after a JSON round trip")
+	}
+}
+
+func TestReadLinearizationInfoJSONError(t *testing.T) {
+	_, err := ReadLinearizationInfoJSON(strings.NewReader(`{"partitions": [{"entries": [], "partialLinearizations": [[0]]}]}`), nil)
+	if err == nil || err.Error() != "partitions[0]: partial linearization refers to unknown operation 0" {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
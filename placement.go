@@ -0,0 +1,154 @@
+package porcupine
+
+import (
+	"sort"
+)
+
+// A LinearizationWindow is the range of times at which an operation can take
+// effect, given the order in which a linearization places it relative to the
+// other operations in its partition.
+//
+// An operation's linearization point must lie within its call/return
+// interval, and the points must occur in linearization order, so an
+// operation can take effect no earlier than the operations linearized before
+// it, and no later than the operations linearized after it. This narrows the
+// call/return interval to [Earliest, Latest].
+type LinearizationWindow struct {
+	Operation Operation
+	Earliest  int64
+	Latest    int64
+}
+
+// Position returns where the middle of the window falls within the
+// operation's call/return interval, from 0 (at the call) to 1 (at the
+// return). An operation whose call and return coincide has position 0.5.
+func (w LinearizationWindow) Position() float64 {
+	length := w.Operation.Return - w.Operation.Call
+	if length <= 0 {
+		return 0.5
+	}
+	mid := float64(w.Earliest-w.Operation.Call) + float64(w.Latest-w.Earliest)/2
+	return mid / float64(length)
+}
+
+// PlacementStats summarizes where linearization points fall for operations
+// of one type, as positions within their call/return intervals (see
+// [LinearizationWindow.Position]).
+type PlacementStats struct {
+	Type   string // operation type, from the model's OperationShape
+	Count  int
+	Mean   float64
+	Median float64
+	Early  int // number of operations with position below 0.25
+	Late   int // number of operations with position above 0.75
+}
+
+// A PlacementReport describes where linearization points fall within the
+// call/return intervals of the operations in a linearizable history.
+//
+// For systems with a well-defined commit point, operations whose points
+// drift later (or earlier) across releases can point at performance or
+// correctness regressions in the commit path, even while the history stays
+// linearizable.
+type PlacementReport struct {
+	Windows [][]LinearizationWindow // for each partition, in linearization order
+	Types   []PlacementStats        // sorted by Type
+}
+
+// PlacementReport computes where linearization points can fall for the
+// operations in a linearizable history, grouping operations into types with
+// the model's OperationShape (which defaults to the Go types of the input and
+// output).
+//
+// The LinearizationInfo must come from one of the verbose check functions,
+// e.g., [CheckOperationsVerbose], and it returns false if some partition was
+// not fully linearized.
+func (li *LinearizationInfo) PlacementReport(model Model) (PlacementReport, bool) {
+	model = fillDefault(model)
+	var report PlacementReport
+	positions := make(map[string][]float64)
+	for p := range li.history {
+		windows, _, ok := linearizationWindows(li.history[p], li.partialLinearizations[p])
+		if !ok {
+			return PlacementReport{}, false
+		}
+		for _, w := range windows {
+			t := operationShape(model, w.Operation)
+			positions[t] = append(positions[t], w.Position())
+		}
+		report.Windows = append(report.Windows, windows)
+	}
+	for t, ps := range positions {
+		report.Types = append(report.Types, placementStats(t, ps))
+	}
+	sort.Slice(report.Types, func(i, j int) bool {
+		return report.Types[i].Type < report.Types[j].Type
+	})
+	return report, true
+}
+
+// linearizationWindows computes the windows of the operations in a
+// partition, in linearization order, along with the operations' IDs, or
+// returns false if the partition has no complete linearization.
+func linearizationWindows(history []entry, partials [][]int) ([]LinearizationWindow, []int, bool) {
+	ops := entryOperations(history)
+	var order []int
+	for _, partial := range partials {
+		if len(partial) == len(ops) {
+			order = partial
+			break
+		}
+	}
+	if order == nil {
+		return nil, nil, false
+	}
+	windows := make([]LinearizationWindow, len(order))
+	for i, id := range order {
+		windows[i] = LinearizationWindow{Operation: ops[id], Earliest: ops[id].Call, Latest: ops[id].Return}
+		if i > 0 && windows[i-1].Earliest > windows[i].Earliest {
+			windows[i].Earliest = windows[i-1].Earliest
+		}
+	}
+	for i := len(windows) - 2; i >= 0; i-- {
+		if windows[i+1].Latest < windows[i].Latest {
+			windows[i].Latest = windows[i+1].Latest
+		}
+	}
+	for i := range windows {
+		// the checker orders operations by call and return times, so the
+		// window is non-empty, but clamp anyway in case of ties
+		if windows[i].Latest < windows[i].Earliest {
+			windows[i].Latest = windows[i].Earliest
+		}
+	}
+	return windows, order, true
+}
+
+func placementStats(t string, positions []float64) PlacementStats {
+	sort.Float64s(positions)
+	stats := PlacementStats{Type: t, Count: len(positions)}
+	sum := 0.0
+	for _, p := range positions {
+		sum += p
+		if p < 0.25 {
+			stats.Early++
+		} else if p > 0.75 {
+			stats.Late++
+		}
+	}
+	stats.Mean = sum / float64(len(positions))
+	mid := len(positions) / 2
+	if len(positions)%2 == 1 {
+		stats.Median = positions[mid]
+	} else {
+		stats.Median = (positions[mid-1] + positions[mid]) / 2
+	}
+	return stats
+}
+
+// ShowPlacement adds an overlay to the visualization that marks, under each
+// operation of a fully linearized partition, the window in which its
+// linearization point can fall (see [LinearizationInfo.PlacementReport]).
+func (li *LinearizationInfo) ShowPlacement() {
+	li.showPlacement = true
+}
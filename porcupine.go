@@ -1,10 +1,31 @@
 package porcupine
 
-import "time"
+import (
+	"context"
+	"time"
+)
+
+// CheckHistory checks whether a history is linearizable, configured by the
+// given options, e.g., [WithTimeout], [WithContext], [WithVerbose],
+// [WithParallelism], and [WithProgress]. The other CheckOperations*
+// functions are shorthands for it with particular options.
+//
+// The check returns Unknown if it is stopped before it could decide, e.g., by
+// a timeout. The LinearizationInfo is only computed with [WithVerbose].
+func CheckHistory(model Model, history []Operation, opts ...CheckOption) (CheckResult, LinearizationInfo) {
+	return checkOperations(model, history, nil, checkOptions{}.apply(opts))
+}
+
+// CheckEventHistory checks whether a history of events is linearizable,
+// configured by the given options; see [CheckHistory]. The other CheckEvents*
+// functions are shorthands for it with particular options.
+func CheckEventHistory(model Model, history []Event, opts ...CheckOption) (CheckResult, LinearizationInfo) {
+	return checkEvents(model, history, nil, checkOptions{}.apply(opts))
+}
 
 // CheckOperations checks whether a history is linearizable.
-func CheckOperations(model Model, history []Operation) bool {
-	res, _ := checkOperations(model, history, false, 0)
+func CheckOperations(model Model, history []Operation, opts ...CheckOption) bool {
+	res, _ := CheckHistory(model, history, opts...)
 	return res == Ok
 }
 
@@ -12,8 +33,8 @@ func CheckOperations(model Model, history []Operation) bool {
 // timeout.
 //
 // A timeout of 0 is interpreted as an unlimited timeout.
-func CheckOperationsTimeout(model Model, history []Operation, timeout time.Duration) CheckResult {
-	res, _ := checkOperations(model, history, false, timeout)
+func CheckOperationsTimeout(model Model, history []Operation, timeout time.Duration, opts ...CheckOption) CheckResult {
+	res, _ := CheckHistory(model, history, append([]CheckOption{WithTimeout(timeout)}, opts...)...)
 	return res
 }
 
@@ -21,21 +42,78 @@ func CheckOperationsTimeout(model Model, history []Operation, timeout time.Durat
 // computing data that can be used to visualize the history and linearization.
 //
 // The returned LinearizationInfo can be used with [Visualize].
-func CheckOperationsVerbose(model Model, history []Operation, timeout time.Duration) (CheckResult, LinearizationInfo) {
-	return checkOperations(model, history, true, timeout)
+func CheckOperationsVerbose(model Model, history []Operation, timeout time.Duration, opts ...CheckOption) (CheckResult, LinearizationInfo) {
+	return CheckHistory(model, history, append([]CheckOption{WithVerbose(), WithTimeout(timeout)}, opts...)...)
+}
+
+// CheckTaggedOperationsVerbose checks whether a history of operations with
+// tags is linearizable while computing data that can be used to visualize the
+// history and linearization.
+//
+// Tags do not affect the result of the check; see [TaggedOperation]. The
+// returned LinearizationInfo can be used with [Visualize], which shows each
+// operation's tags in its tooltip.
+func CheckTaggedOperationsVerbose(model Model, history []TaggedOperation, timeout time.Duration, opts ...CheckOption) (CheckResult, LinearizationInfo) {
+	ops, tags := splitTaggedOperations(history)
+	return checkOperations(model, ops, tags, checkOptions{verbose: true, timeout: timeout}.apply(opts))
+}
+
+// CheckOperationsProgress checks whether a history is linearizable, with a
+// timeout, while periodically reporting the progress of the check.
+//
+// The progress callback is called about once a second while the check is
+// running, and a final time when it finishes; see [Progress] for what is
+// reported. The callback is called from a single goroutine, and the check does
+// not make progress while it runs, so it should return quickly.
+//
+// A timeout of 0 is interpreted as an unlimited timeout.
+func CheckOperationsProgress(model Model, history []Operation, timeout time.Duration, progress func(Progress), opts ...CheckOption) CheckResult {
+	res, _ := CheckHistory(model, history, append([]CheckOption{WithTimeout(timeout), WithProgress(progress)}, opts...)...)
+	return res
+}
+
+// CheckOperationsContext checks whether a history is linearizable, stopping
+// the check if ctx is done, e.g., on SIGINT or when a test's deadline is near,
+// in which case it returns Unknown.
+func CheckOperationsContext(ctx context.Context, model Model, history []Operation, opts ...CheckOption) CheckResult {
+	res, _ := CheckHistory(model, history, append([]CheckOption{WithContext(ctx)}, opts...)...)
+	return res
+}
+
+// CheckOperationsVerboseContext is like [CheckOperationsVerbose], but the
+// check stops when ctx is done instead of after a timeout; see
+// [CheckOperationsContext].
+func CheckOperationsVerboseContext(ctx context.Context, model Model, history []Operation, opts ...CheckOption) (CheckResult, LinearizationInfo) {
+	return CheckHistory(model, history, append([]CheckOption{WithVerbose(), WithContext(ctx)}, opts...)...)
+}
+
+// CheckTaggedOperationsVerboseContext is like [CheckTaggedOperationsVerbose],
+// but the check stops when ctx is done instead of after a timeout; see
+// [CheckOperationsContext].
+func CheckTaggedOperationsVerboseContext(ctx context.Context, model Model, history []TaggedOperation, opts ...CheckOption) (CheckResult, LinearizationInfo) {
+	ops, tags := splitTaggedOperations(history)
+	return checkOperations(model, ops, tags, checkOptions{verbose: true, ctx: ctx}.apply(opts))
+}
+
+// CheckOperationsProgressContext is like [CheckOperationsProgress], but the
+// check stops when ctx is done instead of after a timeout; see
+// [CheckOperationsContext].
+func CheckOperationsProgressContext(ctx context.Context, model Model, history []Operation, progress func(Progress), opts ...CheckOption) CheckResult {
+	res, _ := CheckHistory(model, history, append([]CheckOption{WithContext(ctx), WithProgress(progress)}, opts...)...)
+	return res
 }
 
 // CheckEvents checks whether a history is linearizable.
-func CheckEvents(model Model, history []Event) bool {
-	res, _ := checkEvents(model, history, false, 0)
+func CheckEvents(model Model, history []Event, opts ...CheckOption) bool {
+	res, _ := CheckEventHistory(model, history, opts...)
 	return res == Ok
 }
 
 // CheckEventsTimeout checks whether a history is linearizable, with a timeout.
 //
 // A timeout of 0 is interpreted as an unlimited timeout.
-func CheckEventsTimeout(model Model, history []Event, timeout time.Duration) CheckResult {
-	res, _ := checkEvents(model, history, false, timeout)
+func CheckEventsTimeout(model Model, history []Event, timeout time.Duration, opts ...CheckOption) CheckResult {
+	res, _ := CheckEventHistory(model, history, append([]CheckOption{WithTimeout(timeout)}, opts...)...)
 	return res
 }
 
@@ -43,6 +121,57 @@ func CheckEventsTimeout(model Model, history []Event, timeout time.Duration) Che
 // data that can be used to visualize the history and linearization.
 //
 // The returned LinearizationInfo can be used with [Visualize].
-func CheckEventsVerbose(model Model, history []Event, timeout time.Duration) (CheckResult, LinearizationInfo) {
-	return checkEvents(model, history, true, timeout)
+func CheckEventsVerbose(model Model, history []Event, timeout time.Duration, opts ...CheckOption) (CheckResult, LinearizationInfo) {
+	return CheckEventHistory(model, history, append([]CheckOption{WithVerbose(), WithTimeout(timeout)}, opts...)...)
+}
+
+// CheckTaggedEventsVerbose checks whether a history of events with tags is
+// linearizable while computing data that can be used to visualize the history
+// and linearization.
+//
+// Tags do not affect the result of the check; see [TaggedEvent]. The returned
+// LinearizationInfo can be used with [Visualize], which shows each operation's
+// tags in its tooltip.
+func CheckTaggedEventsVerbose(model Model, history []TaggedEvent, timeout time.Duration, opts ...CheckOption) (CheckResult, LinearizationInfo) {
+	events, tags := splitTaggedEvents(history)
+	return checkEvents(model, events, tags, checkOptions{verbose: true, timeout: timeout}.apply(opts))
+}
+
+// CheckEventsProgress checks whether a history is linearizable, with a
+// timeout, while periodically reporting the progress of the check.
+//
+// See [CheckOperationsProgress] for details on how progress is reported.
+func CheckEventsProgress(model Model, history []Event, timeout time.Duration, progress func(Progress), opts ...CheckOption) CheckResult {
+	res, _ := CheckEventHistory(model, history, append([]CheckOption{WithTimeout(timeout), WithProgress(progress)}, opts...)...)
+	return res
+}
+
+// CheckEventsContext checks whether a history is linearizable, stopping the
+// check if ctx is done, in which case it returns Unknown; see
+// [CheckOperationsContext].
+func CheckEventsContext(ctx context.Context, model Model, history []Event, opts ...CheckOption) CheckResult {
+	res, _ := CheckEventHistory(model, history, append([]CheckOption{WithContext(ctx)}, opts...)...)
+	return res
+}
+
+// CheckEventsVerboseContext is like [CheckEventsVerbose], but the check stops
+// when ctx is done instead of after a timeout; see [CheckOperationsContext].
+func CheckEventsVerboseContext(ctx context.Context, model Model, history []Event, opts ...CheckOption) (CheckResult, LinearizationInfo) {
+	return CheckEventHistory(model, history, append([]CheckOption{WithVerbose(), WithContext(ctx)}, opts...)...)
+}
+
+// CheckTaggedEventsVerboseContext is like [CheckTaggedEventsVerbose], but the
+// check stops when ctx is done instead of after a timeout; see
+// [CheckOperationsContext].
+func CheckTaggedEventsVerboseContext(ctx context.Context, model Model, history []TaggedEvent, opts ...CheckOption) (CheckResult, LinearizationInfo) {
+	events, tags := splitTaggedEvents(history)
+	return checkEvents(model, events, tags, checkOptions{verbose: true, ctx: ctx}.apply(opts))
+}
+
+// CheckEventsProgressContext is like [CheckEventsProgress], but the check
+// stops when ctx is done instead of after a timeout; see
+// [CheckOperationsContext].
+func CheckEventsProgressContext(ctx context.Context, model Model, history []Event, progress func(Progress), opts ...CheckOption) CheckResult {
+	res, _ := CheckEventHistory(model, history, append([]CheckOption{WithContext(ctx), WithProgress(progress)}, opts...)...)
+	return res
 }
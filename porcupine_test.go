@@ -2,14 +2,19 @@ package porcupine
 
 import (
 	"bufio"
+	"bytes"
+	"context"
 	"fmt"
+	"hash/fnv"
 	"io"
 	"os"
 	"reflect"
 	"regexp"
 	"sort"
 	"strconv"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 type registerInput struct {
@@ -1703,3 +1708,685 @@ func TestCheckNoPartitions(t *testing.T) {
 		t.Fatalf("expected output %v, got output %v", Ok, res)
 	}
 }
+
+func TestCheckSensitivityStable(t *testing.T) {
+	// well-separated operations: a small jitter can't reorder them
+	ops := []Operation{
+		{0, registerInput{false, 1}, 0, 0, 100},
+		{1, registerInput{true, 0}, 200, 1, 300},
+		{0, registerInput{false, 2}, 400, 0, 500},
+		{1, registerInput{true, 0}, 600, 2, 700},
+	}
+	report := CheckSensitivity(registerModel, ops, SensitivityOptions{Jitter: 10, Trials: 50})
+	if report.Result != Ok {
+		t.Fatalf("expected output %v, got output %v", Ok, report.Result)
+	}
+	if !report.Stable() || report.Agreed != 50 || report.Counterexample != nil {
+		t.Fatalf("expected verdict to be stable, got %+v", report)
+	}
+}
+
+func TestCheckSensitivityUnstable(t *testing.T) {
+	// the get only sees the initial value because it overlaps the put by
+	// a single time unit
+	ops := []Operation{
+		{0, registerInput{false, 1}, 0, 0, 10},
+		{1, registerInput{true, 0}, 9, 0, 20},
+	}
+	report := CheckSensitivity(registerModel, ops, SensitivityOptions{Jitter: 5, Trials: 50, Seed: 1})
+	if report.Result != Ok {
+		t.Fatalf("expected output %v, got output %v", Ok, report.Result)
+	}
+	if report.Stable() || report.Flipped+report.Agreed != 50 {
+		t.Fatalf("expected verdict to be unstable, got %+v", report)
+	}
+	if CheckOperations(registerModel, report.Counterexample) {
+		t.Fatal("expected reported instance to not be linearizable")
+	}
+	for _, op := range report.Counterexample {
+		if op.Call > op.Return {
+			t.Fatalf("perturbed operation returns before it is called: %+v", op)
+		}
+	}
+}
+
+func TestFailureSignature(t *testing.T) {
+	model := kvModel
+	model.Name = "kv"
+	model.OperationShape = func(input, output interface{}) string {
+		return []string{"get", "put", "append"}[input.(kvInput).op]
+	}
+	events := parseKvLog("test_data/kv/c10-bad.txt")
+	res, info := CheckEventsVerbose(model, events, 0)
+	if res != Illegal {
+		t.Fatalf("expected output %v, got output %v", Illegal, res)
+	}
+	sig, ok := info.FailureSignature(model)
+	if !ok {
+		t.Fatal("expected a failure signature")
+	}
+	if sig.Model != "kv" || sig.Operations == "" {
+		t.Fatalf("unexpected signature %v", sig)
+	}
+	// partitions come out in a random order, which must not matter
+	for i := 0; i < 5; i++ {
+		_, info2 := CheckEventsVerbose(model, events, 0)
+		sig2, _ := info2.FailureSignature(model)
+		if sig2 != sig {
+			t.Fatalf("expected signature to be stable, got %v and %v", sig, sig2)
+		}
+	}
+
+	_, info = CheckEventsVerbose(model, parseKvLog("test_data/kv/c10-ok.txt"), 0)
+	if _, ok := info.FailureSignature(model); ok {
+		t.Fatal("expected no failure signature for a linearizable history")
+	}
+}
+
+func TestFailureSignatureGroupsFailures(t *testing.T) {
+	model := registerModel
+	model.Name = "register"
+	model.OperationShape = func(input, output interface{}) string {
+		if input.(registerInput).op {
+			return "get"
+		}
+		return "put"
+	}
+	model.DescribeState = func(state interface{}) string {
+		if state.(int) == 0 {
+			return "initial"
+		}
+		return "written"
+	}
+	// stale reads, with different values
+	signature := func(v int) FailureSignature {
+		ops := []Operation{
+			{0, registerInput{false, v}, 0, 0, 10},
+			{1, registerInput{false, v + 1}, 20, 0, 30},
+			{2, registerInput{true, 0}, 40, v, 50},
+		}
+		res, info := CheckOperationsVerbose(model, ops, 0)
+		if res != Illegal {
+			t.Fatalf("expected output %v, got output %v", Illegal, res)
+		}
+		sig, _ := info.FailureSignature(model)
+		return sig
+	}
+	if signature(1).Key() != signature(7).Key() {
+		t.Fatalf("expected failures to share a signature, got %v and %v", signature(1), signature(7))
+	}
+	if signature(1).Operations != "get" {
+		t.Fatalf("expected to be stuck on a get, got %v", signature(1))
+	}
+
+	// a read of a value that was never written is a different failure
+	ops := []Operation{
+		{0, registerInput{true, 0}, 0, 5, 10},
+	}
+	_, info := CheckOperationsVerbose(model, ops, 0)
+	other, _ := info.FailureSignature(model)
+	if other.Key() == signature(1).Key() {
+		t.Fatalf("expected different failures to have different signatures, got %v", other)
+	}
+	// the first operation fails, so the checker is stuck on it in the
+	// initial state
+	if other.Operations != "get" {
+		t.Fatalf("expected to be stuck on a get, got %v", other)
+	}
+	ops = []Operation{
+		{0, registerInput{false, 1}, 0, 0, 10},
+		{1, registerInput{true, 0}, 0, 5, 10},
+	}
+	_, info = CheckOperationsVerbose(model, ops, 0)
+	if sig, _ := info.FailureSignature(model); sig.Operations != "get" || sig.Key() == other.Key() {
+		t.Fatalf("expected to be stuck on a get after the put, got %v", sig)
+	}
+}
+
+func TestPlacementReport(t *testing.T) {
+	model := registerModel
+	model.OperationShape = func(input, output interface{}) string {
+		if input.(registerInput).op {
+			return "get"
+		}
+		return "put"
+	}
+	ops := []Operation{
+		// the put must take effect by the time the get returns, early in
+		// its interval
+		{0, registerInput{false, 1}, 0, 0, 100},
+		{1, registerInput{true, 0}, 10, 1, 20},
+		// the second get is unconstrained
+		{2, registerInput{true, 0}, 150, 1, 250},
+	}
+	res, info := CheckOperationsVerbose(model, ops, 0)
+	if res != Ok {
+		t.Fatalf("expected output %v, got output %v", Ok, res)
+	}
+	report, ok := info.PlacementReport(model)
+	if !ok {
+		t.Fatal("expected a placement report")
+	}
+	windows := report.Windows[0]
+	if windows[0].Earliest != 0 || windows[0].Latest != 20 || windows[0].Position() != 0.1 {
+		t.Fatalf("unexpected window for put: %+v", windows[0])
+	}
+	if windows[1].Earliest != 10 || windows[1].Latest != 20 {
+		t.Fatalf("unexpected window for get: %+v", windows[1])
+	}
+	expected := []PlacementStats{
+		{Type: "get", Count: 2, Mean: 0.5, Median: 0.5},
+		{Type: "put", Count: 1, Mean: 0.1, Median: 0.1, Early: 1},
+	}
+	if !reflect.DeepEqual(expected, report.Types) {
+		t.Fatalf("expected stats %+v, got %+v", expected, report.Types)
+	}
+
+	ops[2].Output = 2
+	_, info = CheckOperationsVerbose(model, ops, 0)
+	if _, ok := info.PlacementReport(model); ok {
+		t.Fatal("expected no placement report for a non-linearizable history")
+	}
+}
+
+func TestTaggedOperationSession(t *testing.T) {
+	op := TaggedOperation{Operation: Operation{ClientId: 3}}
+	if op.Session() != "client 3" {
+		t.Fatalf("expected the session to default to the client, got %q", op.Session())
+	}
+	op.Tags = map[string]string{SessionTag: "s1"}
+	if op.Session() != "s1" {
+		t.Fatalf("expected session %q, got %q", "s1", op.Session())
+	}
+}
+
+func TestPartitions(t *testing.T) {
+	ops := []Operation{
+		{0, kvInput{op: 1, key: "x", value: "a"}, 0, kvOutput{}, 10},
+		{1, kvInput{op: 0, key: "x"}, 20, kvOutput{"b"}, 30},
+		{2, kvInput{op: 1, key: "y", value: "c"}, 0, kvOutput{}, 10},
+		{2, kvInput{op: 0, key: "y"}, 20, kvOutput{"c"}, 30},
+	}
+	res, info := CheckOperationsVerbose(kvModel, ops, 0)
+	if res != Illegal {
+		t.Fatalf("expected output %v, got output %v", Illegal, res)
+	}
+	partitions := info.Partitions()
+	if len(partitions) != 2 {
+		t.Fatalf("expected 2 partitions, got %d", len(partitions))
+	}
+	if !reflect.DeepEqual(info.PartialLinearizationsOperations()[0], partitions[0].PartialLinearizations) ||
+		!reflect.DeepEqual(info.PartialLinearizations()[0], partitions[0].PartialLinearizationIds) {
+		t.Fatalf("expected the partial linearizations of the partition, got %v", partitions[0])
+	}
+	x, y := partitions[0], partitions[1]
+	if !reflect.DeepEqual(x.Operations, ops[:2]) || x.Linearizable() || x.Linearized != 1 || x.TimedOut {
+		t.Fatalf("expected key x to be partly linearized, got %+v", x)
+	}
+	if !reflect.DeepEqual(y.Operations, ops[2:]) || !y.Linearizable() {
+		t.Fatalf("expected key y to be linearized, got %+v", y)
+	}
+}
+
+func TestCheckContext(t *testing.T) {
+	events := parseKvLog("test_data/kv/c10-ok.txt")
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if res := CheckEventsContext(ctx, kvNoPartitionModel, events); res != Unknown {
+		t.Fatalf("expected output %v, got output %v", Unknown, res)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("check took too long to stop: %v", elapsed)
+	}
+
+	ops := []Operation{
+		{0, registerInput{false, 1}, 0, 0, 10},
+		{1, registerInput{true, 0}, 20, 1, 30},
+	}
+	res, info := CheckOperationsVerboseContext(context.Background(), registerModel, ops)
+	if res != Ok || len(info.PartialLinearizations()) == 0 {
+		t.Fatalf("expected output %v with linearization info, got output %v", Ok, res)
+	}
+
+	if res := CheckEventsContext(context.Background(), kvModel, events); res != Ok {
+		t.Fatalf("expected output %v, got output %v", Ok, res)
+	}
+	if res := CheckEventsContext(context.Background(), kvModel, parseKvLog("test_data/kv/c10-bad.txt")); res != Illegal {
+		t.Fatalf("expected output %v, got output %v", Illegal, res)
+	}
+}
+
+func TestWithParallelism(t *testing.T) {
+	events := parseKvLog("test_data/kv/c10-ok.txt")
+	for _, parallelism := range []int{1, 3} {
+		var active, maxActive int32
+		model := kvModel
+		step := model.Step
+		model.Step = func(state, input, output interface{}) (bool, interface{}) {
+			n := atomic.AddInt32(&active, 1)
+			defer atomic.AddInt32(&active, -1)
+			for {
+				max := atomic.LoadInt32(&maxActive)
+				if n <= max || atomic.CompareAndSwapInt32(&maxActive, max, n) {
+					break
+				}
+			}
+			return step(state, input, output)
+		}
+		if !CheckEvents(model, events, WithParallelism(parallelism)) {
+			t.Fatal("expected history to be linearizable")
+		}
+		if maxActive > int32(parallelism) {
+			t.Fatalf("expected at most %d partitions checked at once, got %d", parallelism, maxActive)
+		}
+	}
+	if CheckEvents(kvModel, parseKvLog("test_data/kv/c10-bad.txt"), WithParallelism(2)) {
+		t.Fatal("expected history to not be linearizable")
+	}
+}
+
+func TestWithMemoryBudget(t *testing.T) {
+	events := parseKvLog("test_data/kv/c10-ok.txt")
+	res, info := CheckEventsVerbose(kvNoPartitionModel, events, 0, WithMemoryBudget(1<<10))
+	if res != Unknown {
+		t.Fatalf("expected output %v, got output %v", Unknown, res)
+	}
+	if len(info.PartialLinearizations()) == 0 {
+		t.Fatal("expected partial linearizations")
+	}
+	if !CheckEvents(kvModel, events, WithMemoryBudget(1<<30)) {
+		t.Fatal("expected history to be linearizable")
+	}
+	if CheckEvents(kvModel, parseKvLog("test_data/kv/c10-bad.txt"), WithMemoryBudget(1<<30)) {
+		t.Fatal("expected history to not be linearizable")
+	}
+}
+
+func TestWithPartitionTimeout(t *testing.T) {
+	slow := parseKvLog("test_data/kv/c10-ok.txt")
+	for _, tc := range []struct {
+		file     string
+		expected CheckResult
+	}{
+		{"test_data/kv/c01-ok.txt", Unknown},
+		{"test_data/kv/c01-bad.txt", Illegal},
+	} {
+		fast := parseKvLog(tc.file)
+		model := kvNoPartitionModel
+		model.PartitionEvent = func(history []Event) [][]Event {
+			return [][]Event{fast, slow}
+		}
+		start := time.Now()
+		res, info := CheckEventsVerbose(model, append(fast, slow...), 0, WithPartitionTimeout(100*time.Millisecond))
+		if res != tc.expected {
+			t.Fatalf("%s: expected output %v, got output %v", tc.file, tc.expected, res)
+		}
+		if elapsed := time.Since(start); elapsed > 5*time.Second {
+			t.Fatalf("%s: check took too long to stop: %v", tc.file, elapsed)
+		}
+		if timedOut := info.TimedOutPartitions(); !reflect.DeepEqual(timedOut, []int{1}) {
+			t.Fatalf("%s: expected partition 1 to time out, got %v", tc.file, timedOut)
+		}
+	}
+}
+
+func hashKvState(state interface{}) uint64 {
+	st := state.(map[string]string)
+	keys := make([]string, 0, len(st))
+	for k := range st {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	h := fnv.New64a()
+	for _, k := range keys {
+		fmt.Fprintf(h, "%q=%q;", k, st[k])
+	}
+	return h.Sum64()
+}
+
+func TestModelHash(t *testing.T) {
+	for _, tc := range []struct {
+		file     string
+		expected CheckResult
+	}{
+		{"test_data/kv/c01-ok.txt", Ok},
+		{"test_data/kv/c01-bad.txt", Illegal},
+	} {
+		events := parseKvLog(tc.file)
+		var equalCalls, hashedEqualCalls int
+		model := kvNoPartitionModel
+		model.Equal = func(state1, state2 interface{}) bool {
+			equalCalls++
+			return kvNoPartitionModel.Equal(state1, state2)
+		}
+		if res, _ := CheckEventsVerbose(model, events, 0); res != tc.expected {
+			t.Fatalf("%s: expected output %v, got output %v", tc.file, tc.expected, res)
+		}
+		model.Equal = func(state1, state2 interface{}) bool {
+			hashedEqualCalls++
+			return kvNoPartitionModel.Equal(state1, state2)
+		}
+		model.Hash = hashKvState
+		if res, _ := CheckEventsVerbose(model, events, 0); res != tc.expected {
+			t.Fatalf("%s: expected output %v with Hash, got output %v", tc.file, tc.expected, res)
+		}
+		if hashedEqualCalls > equalCalls {
+			t.Fatalf("%s: expected Hash to avoid calls to Equal, got %d calls with Hash and %d without", tc.file, hashedEqualCalls, equalCalls)
+		}
+	}
+
+	nm := nondeterministicRegisterModel
+	nm.Hash = func(state interface{}) uint64 {
+		return uint64(state.(int))
+	}
+	model := nm.ToModel()
+	if model.Hash([]interface{}{1, 2}) != model.Hash([]interface{}{2, 1}) {
+		t.Fatal("expected the hash of a set of states to not depend on their order")
+	}
+}
+
+func TestWithStateInterning(t *testing.T) {
+	model := kvNoPartitionModel
+	model.Hash = hashKvState
+	for _, tc := range []struct {
+		file     string
+		expected CheckResult
+	}{
+		{"test_data/kv/c01-ok.txt", Ok},
+		{"test_data/kv/c01-bad.txt", Illegal},
+	} {
+		res, info := CheckEventsVerbose(model, parseKvLog(tc.file), 0, WithStateInterning())
+		if res != tc.expected {
+			t.Fatalf("%s: expected output %v, got output %v", tc.file, tc.expected, res)
+		}
+		if len(info.PartialLinearizations()) == 0 {
+			t.Fatalf("%s: expected partial linearizations", tc.file)
+		}
+	}
+	if !CheckEvents(kvModel, parseKvLog("test_data/kv/c10-ok.txt"), WithStateInterning()) {
+		t.Fatal("expected interning to have no effect on a model without Hash")
+	}
+}
+
+func TestStateInterner(t *testing.T) {
+	model := kvNoPartitionModel
+	model.Hash = hashKvState
+	in := newStateInterner(model)
+	id1, s1 := in.intern(map[string]string{"x": "1"})
+	id2, s2 := in.intern(map[string]string{"x": "1"})
+	id3, _ := in.intern(map[string]string{"x": "2"})
+	if id1 != id2 || reflect.ValueOf(s1).Pointer() != reflect.ValueOf(s2).Pointer() {
+		t.Fatal("expected equal states to be interned to the same copy")
+	}
+	if id3 == id1 {
+		t.Fatal("expected different states to get different IDs")
+	}
+	if newStateInterner(kvNoPartitionModel) != nil {
+		t.Fatal("expected no interner for a model without Hash")
+	}
+}
+
+func TestWithMaxDepth(t *testing.T) {
+	// the unbounded search of this history takes a long time
+	events := parseKvLog("test_data/kv/c10-ok.txt")
+	start := time.Now()
+	res, info := CheckEventsVerbose(kvNoPartitionModel, events, 0, WithMaxDepth(20))
+	if res != Unknown {
+		t.Fatalf("expected output %v, got output %v", Unknown, res)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("check took too long to stop: %v", elapsed)
+	}
+	deepest := 0
+	for _, partial := range info.PartialLinearizations()[0] {
+		if len(partial) > deepest {
+			deepest = len(partial)
+		}
+	}
+	if deepest != 20 {
+		t.Fatalf("expected a partial linearization of length 20, got %d", deepest)
+	}
+
+	// a violation within the first operations is found regardless
+	ops := []Operation{
+		{0, registerInput{true, 5}, 0, 5, 10},
+		{1, registerInput{false, 1}, 20, 0, 30},
+		{0, registerInput{true, 0}, 40, 1, 50},
+	}
+	if res := CheckOperationsTimeout(registerModel, ops, 0, WithMaxDepth(1)); res != Illegal {
+		t.Fatalf("expected output %v, got output %v", Illegal, res)
+	}
+	if res := CheckEventsTimeout(kvModel, parseKvLog("test_data/kv/c10-bad.txt"), 0, WithMaxDepth(1<<20)); res != Illegal {
+		t.Fatalf("expected output %v, got output %v", Illegal, res)
+	}
+}
+
+func TestWithSymmetryReduction(t *testing.T) {
+	// many clients concurrently write the same value, followed by a read
+	// of a value that was never written
+	var ops []Operation
+	for i := 0; i < 12; i++ {
+		ops = append(ops, Operation{i, registerInput{false, 1}, 0, 0, 100})
+	}
+	ops = append(ops, Operation{0, registerInput{true, 0}, 200, 2, 210})
+	var steps, reducedSteps int
+	model := registerModel
+	model.Step = func(state, input, output interface{}) (bool, interface{}) {
+		steps++
+		return registerModel.Step(state, input, output)
+	}
+	if res := CheckOperationsTimeout(model, ops, 0); res != Illegal {
+		t.Fatalf("expected output %v, got output %v", Illegal, res)
+	}
+	model.Step = func(state, input, output interface{}) (bool, interface{}) {
+		reducedSteps++
+		return registerModel.Step(state, input, output)
+	}
+	if res := CheckOperationsTimeout(model, ops, 0, WithSymmetryReduction()); res != Illegal {
+		t.Fatalf("expected output %v, got output %v", Illegal, res)
+	}
+	if reducedSteps*10 > steps {
+		t.Fatalf("expected symmetry reduction to shrink the search, got %d steps with it and %d without", reducedSteps, steps)
+	}
+
+	for _, tc := range []struct {
+		file     string
+		expected bool
+	}{
+		{"test_data/kv/c10-ok.txt", true},
+		{"test_data/kv/c10-bad.txt", false},
+	} {
+		if res := CheckEvents(kvModel, parseKvLog(tc.file), WithSymmetryReduction()); res != tc.expected {
+			t.Fatalf("%s: expected output %v, got output %v", tc.file, tc.expected, res)
+		}
+	}
+}
+
+func TestWithMetrics(t *testing.T) {
+	for _, tc := range []struct {
+		file     string
+		expected CheckResult
+	}{
+		{"test_data/kv/c10-ok.txt", Ok},
+		{"test_data/kv/c10-bad.txt", Illegal},
+	} {
+		events := parseKvLog(tc.file)
+		var metrics []Metrics
+		sink := MetricsFunc(func(m Metrics) { metrics = append(metrics, m) })
+		res := CheckEventsTimeout(kvModel, events, 0, WithMetrics(sink))
+		if res != tc.expected {
+			t.Fatalf("%s: expected output %v, got output %v", tc.file, tc.expected, res)
+		}
+		if len(metrics) != 1 {
+			t.Fatalf("%s: expected metrics to be reported once, got %d", tc.file, len(metrics))
+		}
+		total := metrics[0].Total()
+		if total.Entries != len(events) {
+			t.Fatalf("%s: expected %d entries, got %d", tc.file, len(events), total.Entries)
+		}
+		if total.Result != tc.expected || total.Steps == 0 || total.StatesGenerated != total.CacheHits+total.CacheMisses || total.MaxDepth == 0 {
+			t.Fatalf("%s: unexpected metrics %+v", tc.file, total)
+		}
+	}
+	// a sequential history is linearized one operation per step, without
+	// backtracking
+	ops := []Operation{
+		{0, registerInput{false, 1}, 0, 0, 10},
+		{1, registerInput{true, 0}, 20, 1, 30},
+		{0, registerInput{false, 2}, 40, 0, 50},
+	}
+	var metrics Metrics
+	if CheckOperations(registerModel, ops, WithMetrics(MetricsFunc(func(m Metrics) { metrics = m }))) != true {
+		t.Fatal("expected operations to be linearizable")
+	}
+	if steps := metrics.Total().Steps; steps != uint64(len(ops)) {
+		t.Fatalf("expected %d steps, got %d", len(ops), steps)
+	}
+	var reported bool
+	CheckOperations(kvModel, nil, WithMetrics(MetricsFunc(func(m Metrics) { reported = true })))
+	if !reported {
+		t.Fatal("expected metrics for an empty history")
+	}
+}
+
+func TestCheckHistory(t *testing.T) {
+	events := parseKvLog("test_data/kv/c10-bad.txt")
+	ops := kvOperations(events)
+	res, info := CheckHistory(kvModel, ops, WithVerbose(), WithTimeout(time.Minute))
+	if res != Illegal {
+		t.Fatalf("expected output %v, got output %v", Illegal, res)
+	}
+	if len(info.PartialLinearizations()) == 0 {
+		t.Fatal("expected a LinearizationInfo from a verbose check")
+	}
+	if res, info := CheckEventHistory(kvModel, events); res != Illegal || info.PartialLinearizations() != nil {
+		t.Fatalf("expected output %v without a LinearizationInfo, got output %v", Illegal, res)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if res, _ := CheckEventHistory(kvNoPartitionModel, events, WithContext(ctx)); res != Unknown {
+		t.Fatalf("expected output %v, got output %v", Unknown, res)
+	}
+	var final Progress
+	CheckHistory(kvModel, kvOperations(parseKvLog("test_data/kv/c10-ok.txt")), WithProgress(func(p Progress) { final = p }))
+	if !final.Done {
+		t.Fatal("expected a final progress report")
+	}
+}
+
+func TestBitsetArena(t *testing.T) {
+	var arena bitsetArena
+	b := newBitset(100)
+	var clones []bitset
+	for i := uint(0); i < 2*bitsetSlabSize; i++ {
+		b.set(i % 100)
+		clones = append(clones, arena.clone(b))
+		b.clear(i % 100)
+	}
+	for i, c := range clones {
+		if c.popcnt() != 1 || len(c) != len(b) || cap(c) != len(b) {
+			t.Fatalf("clone %d: expected a single bit in %d words, got %v", i, len(b), c)
+		}
+		c.set(99)
+	}
+	if b.popcnt() != 0 || clones[0].popcnt() != 2 || clones[1].popcnt() != 2 {
+		t.Fatal("expected clones to be independent")
+	}
+}
+
+func TestLongestPrefixes(t *testing.T) {
+	ops := kvOperations(parseKvLog("test_data/kv/c10-ok.txt"))
+	_, info := CheckHistory(kvModel, ops)
+	linearized, operations := info.LongestPrefixes()
+	if len(linearized) == 0 || len(linearized) != len(operations) {
+		t.Fatalf("expected a prefix per partition, got %v and %v", linearized, operations)
+	}
+	total := 0
+	for i := range linearized {
+		if linearized[i] != operations[i] {
+			t.Fatalf("partition %d: expected all %d operations to be linearized, got %d", i, operations[i], linearized[i])
+		}
+		total += operations[i]
+	}
+	if total != len(ops) {
+		t.Fatalf("expected %d operations, got %d", len(ops), total)
+	}
+
+	// a check that times out still reports how far it got
+	events := parseKvLog("test_data/kv/c50-ok.txt")
+	res, info := CheckEventHistory(kvNoPartitionModel, events, WithTimeout(500*time.Millisecond))
+	if res != Unknown {
+		t.Fatalf("expected output %v, got output %v", Unknown, res)
+	}
+	linearized, operations = info.LongestPrefixes()
+	if len(linearized) != 1 || linearized[0] == 0 || linearized[0] >= operations[0] || operations[0] != len(events)/2 {
+		t.Fatalf("expected a partial prefix of %d operations, got %v of %v", len(events)/2, linearized, operations)
+	}
+
+	// saved results compute the prefixes from the partial linearizations
+	_, info = checkPersistHistory(t)
+	var buf bytes.Buffer
+	if err := WriteLinearizationInfoJSON(&buf, info, nil); err != nil {
+		t.Fatal(err)
+	}
+	restored, err := ReadLinearizationInfoJSON(&buf, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	linearized, operations = info.LongestPrefixes()
+	restoredLinearized, restoredOperations := restored.LongestPrefixes()
+	if !reflect.DeepEqual(linearized, restoredLinearized) || !reflect.DeepEqual(operations, restoredOperations) {
+		t.Fatalf("expected prefixes %v of %v, got %v of %v", linearized, operations, restoredLinearized, restoredOperations)
+	}
+}
+
+func TestWithEpsilon(t *testing.T) {
+	// the read starts 5 after the write returns, but returns the old value
+	history := []Operation{
+		{ClientId: 0, Input: registerInput{false, 1}, Call: 0, Output: 0, Return: 10},
+		{ClientId: 1, Input: registerInput{true, 0}, Call: 15, Output: 0, Return: 20},
+	}
+	for _, tc := range []struct {
+		epsilon  int64
+		expected bool
+	}{
+		{0, false},
+		{4, false},
+		{5, true},
+		{100, true},
+	} {
+		if res := CheckOperations(registerModel, history, WithEpsilon(tc.epsilon)); res != tc.expected {
+			t.Fatalf("epsilon %d: expected output %t, got output %t", tc.epsilon, tc.expected, res)
+		}
+	}
+	if history[0].Return != 10 {
+		t.Fatal("expected the history to be unchanged")
+	}
+}
+
+func TestWithEpsilonMultiFailFast(t *testing.T) {
+	history := []Operation{
+		{ClientId: 0, Input: registerInput{false, 1}, Call: 0, Output: 0, Return: 10},
+		{ClientId: 1, Input: registerInput{true, 0}, Call: 15, Output: 0, Return: 20},
+	}
+	for _, tc := range []struct {
+		epsilon  int64
+		expected CheckResult
+	}{
+		{0, Illegal},
+		{5, Ok},
+	} {
+		results := CheckOperationsMulti([]Model{registerModel, registerModel}, history, WithEpsilon(tc.epsilon))
+		for i, res := range results {
+			if res != tc.expected {
+				t.Fatalf("epsilon %d: expected model %d output %v, got output %v", tc.epsilon, i, tc.expected, res)
+			}
+		}
+		res, failure := CheckOperationsFailFast(registerModel, history, nil, WithEpsilon(tc.epsilon))
+		if res != tc.expected || (failure != nil) != (tc.expected == Illegal) {
+			t.Fatalf("epsilon %d: expected fail-fast output %v, got output %v, failure %v", tc.epsilon, tc.expected, res, failure)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package porcupine
+
+import "context"
+
+// CheckProcessorConsistency checks whether a history is processor
+// consistent: whether it is both PRAM consistent, so that each client
+// observes the writes of every other client in the order that client made
+// them, and cache coherent, so that the operations on each location are
+// sequentially consistent. The locations are the model's partitions, so the
+// model must partition histories by location, as a key-value store model
+// usually does; a model without a partition function has a single location.
+// Unlike for a linearizability check, the model's state must cover every
+// location, because a client's view of the history spans them. isWrite
+// reports which operations write; the model must accept a write whatever its
+// output, because a client's view includes the writes of other clients, but
+// not their reads.
+//
+// Like [CheckSequentialConsistency], of the options, the check uses
+// [WithTimeout] and [WithContext], and returns Unknown if it is stopped before
+// it can decide. It checks each location, and each client's view of the
+// history, separately, and returns Illegal as soon as one of them fails.
+func CheckProcessorConsistency(model Model, history []Operation, isWrite func(op Operation) bool, opts ...CheckOption) CheckResult {
+	model = fillDefault(model)
+	o, cancel := sharedDeadline(checkOptions{}.apply(opts))
+	defer cancel()
+	// cache coherence
+	res := checkAllSequential(model, model.Partition(history), o)
+	if res == Illegal {
+		return Illegal
+	}
+	if pram := checkPRAM(model, history, isWrite, o); pram != Ok {
+		return pram
+	}
+	return res
+}
+
+// checkPRAM checks whether a history is PRAM consistent, by checking that
+// each client's view of the history, its own operations and the writes of
+// the other clients, is sequentially consistent.
+func checkPRAM(model Model, history []Operation, isWrite func(op Operation) bool, opts checkOptions) CheckResult {
+	var views [][]Operation
+	for client := range clientPrograms(history) {
+		var view []Operation
+		for _, op := range history {
+			if op.ClientId == client || isWrite(op) {
+				view = append(view, op)
+			}
+		}
+		views = append(views, view)
+	}
+	return checkAllSequential(model, views, opts)
+}
+
+// checkAllSequential checks whether each of the given histories is
+// sequentially consistent, returning Illegal as soon as one isn't.
+func checkAllSequential(model Model, histories [][]Operation, opts checkOptions) CheckResult {
+	result := Ok
+	for _, history := range histories {
+		switch checkSequential(model, clientPrograms(history), opts) {
+		case Illegal:
+			return Illegal
+		case Unknown:
+			result = Unknown
+		}
+	}
+	return result
+}
+
+// sharedDeadline turns a check's timeout into a deadline on its context, so
+// that the timeout covers every search the check runs, rather than each.
+func sharedDeadline(opts checkOptions) (checkOptions, context.CancelFunc) {
+	ctx := opts.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	cancel := context.CancelFunc(func() {})
+	if opts.timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, opts.timeout)
+		opts.timeout = 0
+	}
+	opts.ctx = ctx
+	return opts, cancel
+}
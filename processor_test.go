@@ -0,0 +1,83 @@
+package porcupine
+
+import "testing"
+
+// kvProgram builds a history in which each client runs its operations one
+// after another, all clients starting at once.
+func kvProgram(clients ...[]kvInput) []Operation {
+	var history []Operation
+	for client, ops := range clients {
+		for i, in := range ops {
+			out := kvOutput{}
+			if in.op == 0 {
+				// the input's value is what the get returned
+				out.value, in.value = in.value, ""
+			}
+			history = append(history, Operation{ClientId: client, Input: in, Call: int64(10 * i), Output: out, Return: int64(10*i + 5)})
+		}
+	}
+	return history
+}
+
+func kvIsWrite(op Operation) bool {
+	return op.Input.(kvInput).op != 0
+}
+
+func TestCheckProcessorConsistency(t *testing.T) {
+	put := func(key, value string) kvInput { return kvInput{op: 1, key: key, value: value} }
+	get := func(key, value string) kvInput { return kvInput{op: 0, key: key, value: value} }
+	// the views of the history span keys, so the model keeps all of them
+	model := kvNoPartitionModel
+	model.Partition = kvModel.Partition
+	for _, tc := range []struct {
+		name     string
+		history  []Operation
+		expected CheckResult
+	}{
+		{
+			// independent reads of independent writes: the readers see
+			// the writes in opposite orders, which isn't sequentially
+			// consistent, but is processor consistent
+			"iriw",
+			kvProgram(
+				[]kvInput{put("x", "1")},
+				[]kvInput{put("y", "1")},
+				[]kvInput{get("x", "1"), get("y", "")},
+				[]kvInput{get("y", "1"), get("x", "")},
+			),
+			Ok,
+		},
+		{
+			// a reader sees one client's writes to x out of order
+			"incoherent",
+			kvProgram(
+				[]kvInput{put("x", "1"), put("x", "2")},
+				[]kvInput{get("x", "2"), get("x", "1")},
+			),
+			Illegal,
+		},
+		{
+			// a reader sees one client's writes to different keys out of
+			// order, which each key alone allows
+			"not pram",
+			kvProgram(
+				[]kvInput{put("x", "1"), put("y", "1")},
+				[]kvInput{get("y", "1"), get("x", "")},
+			),
+			Illegal,
+		},
+	} {
+		if res := CheckProcessorConsistency(model, tc.history, kvIsWrite); res != tc.expected {
+			t.Fatalf("%s: expected output %v, got output %v", tc.name, tc.expected, res)
+		}
+	}
+	iriw := kvProgram(
+		[]kvInput{put("x", "1")},
+		[]kvInput{put("y", "1")},
+		[]kvInput{get("x", "1"), get("y", "")},
+		[]kvInput{get("y", "1"), get("x", "")},
+	)
+	if res := CheckSequentialConsistency(kvNoPartitionModel, iriw); res != Illegal {
+		t.Fatalf("expected output %v, got output %v", Illegal, res)
+	}
+}
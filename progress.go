@@ -0,0 +1,204 @@
+package porcupine
+
+import (
+	"math"
+	"sync/atomic"
+	"time"
+)
+
+// Progress describes how far along a linearizability check is.
+//
+// While a check started with [CheckOperationsProgress] or
+// [CheckEventsProgress] is running, a Progress is periodically delivered to
+// the supplied callback, and a final one (with Done set) is delivered when the
+// check finishes.
+//
+// The checker cannot know how much of the search space remains, so progress
+// is measured by the search frontier: for each partition, the length of the
+// longest partial linearization found so far. Partitions that have been fully
+// checked count all of their operations. The frontier only ever grows, but it
+// can stall while the checker backtracks, so the ETA is an extrapolation, not
+// a bound. [ProgressHandler] serves the latest Progress, ETA included, over
+// HTTP.
+type Progress struct {
+	Elapsed        time.Duration // time since the check started
+	Partitions     int           // number of partitions in the history
+	PartitionsDone int           // number of partitions that have been fully checked
+	Operations     int           // number of operations, across all partitions
+	Frontier       int           // number of operations linearized so far, across all partitions
+	Steps          uint64        // number of search steps taken so far
+	Done           bool          // whether the check has finished
+	// ETA is the estimated time remaining until the check finishes, and
+	// Confidence, in the range [0, 1], is how much the estimate can be
+	// trusted. A Confidence of 0 means there is no estimate, e.g., because
+	// the frontier has not advanced recently.
+	ETA        time.Duration
+	Confidence float64
+}
+
+// progressInterval is how often an in-progress check reports progress.
+var progressInterval = time.Second
+
+// progressBatch is how many search steps a partition's checker takes between
+// publishing its counters, to keep the cost of progress reporting off the hot
+// path.
+const progressBatch = 1024
+
+// partitionProgress holds the counters that a single partition's checker
+// publishes for the progress tracker.
+type partitionProgress struct {
+	// accessed atomically; kept first for 64-bit alignment on 32-bit
+	// platforms
+	steps int64
+	depth int64
+	done  int32
+
+	operations int
+}
+
+// report publishes the number of search steps taken and the length of the
+// longest partial linearization found so far. It is a no-op on a nil
+// receiver, so the checker can call it unconditionally.
+func (p *partitionProgress) report(steps, depth int) {
+	if p == nil {
+		return
+	}
+	atomic.StoreInt64(&p.steps, int64(steps))
+	atomic.StoreInt64(&p.depth, int64(depth))
+}
+
+type progressTracker struct {
+	start      time.Time
+	partitions []partitionProgress
+	operations int
+	estimator  etaEstimator
+}
+
+func newProgressTracker(history [][]entry) *progressTracker {
+	t := &progressTracker{
+		start:      time.Now(),
+		partitions: make([]partitionProgress, len(history)),
+	}
+	for i, partition := range history {
+		n := len(partition) / 2
+		t.partitions[i].operations = n
+		t.operations += n
+	}
+	return t
+}
+
+// partition returns the counters for the i-th partition, or nil if progress
+// is not being tracked.
+func (t *progressTracker) partition(i int) *partitionProgress {
+	if t == nil {
+		return nil
+	}
+	return &t.partitions[i]
+}
+
+// finish marks the i-th partition as fully checked.
+func (t *progressTracker) finish(i int) {
+	if t == nil {
+		return
+	}
+	atomic.StoreInt32(&t.partitions[i].done, 1)
+}
+
+// snapshot collects the partition counters into a Progress. It must only be
+// called from a single goroutine, because it updates the ETA estimator.
+func (t *progressTracker) snapshot(done bool) Progress {
+	p := Progress{
+		Elapsed:    time.Since(t.start),
+		Partitions: len(t.partitions),
+		Operations: t.operations,
+		Done:       done,
+	}
+	for i := range t.partitions {
+		part := &t.partitions[i]
+		p.Steps += uint64(atomic.LoadInt64(&part.steps))
+		if atomic.LoadInt32(&part.done) != 0 {
+			p.PartitionsDone++
+			p.Frontier += part.operations
+		} else {
+			p.Frontier += int(atomic.LoadInt64(&part.depth))
+		}
+	}
+	if done {
+		p.Confidence = 1
+		return p
+	}
+	fraction := 1.0
+	if t.operations > 0 {
+		fraction = float64(p.Frontier) / float64(t.operations)
+	}
+	p.ETA, p.Confidence = t.estimator.estimate(p.Elapsed, fraction)
+	return p
+}
+
+// etaWindow is the number of recent progress samples that the ETA estimator
+// considers.
+const etaWindow = 16
+
+type progressSample struct {
+	elapsed  time.Duration
+	fraction float64
+}
+
+// etaEstimator extrapolates the time remaining in a check from how quickly
+// the search frontier has been advancing over the last few samples.
+type etaEstimator struct {
+	samples []progressSample // oldest first
+}
+
+// estimate records a new sample, where fraction is the portion of the
+// frontier covered so far, and returns the estimated time remaining along
+// with a confidence in the range [0, 1].
+//
+// The estimate uses the average rate over the sample window. The confidence
+// is high when the per-sample rates agree with each other and the window is
+// full, and is 0 when the frontier is not advancing at all.
+func (e *etaEstimator) estimate(elapsed time.Duration, fraction float64) (time.Duration, float64) {
+	e.samples = append(e.samples, progressSample{elapsed, fraction})
+	if len(e.samples) > etaWindow {
+		e.samples = e.samples[len(e.samples)-etaWindow:]
+	}
+	if fraction >= 1 {
+		return 0, 1
+	}
+	if len(e.samples) < 2 {
+		return 0, 0
+	}
+	first := e.samples[0]
+	last := e.samples[len(e.samples)-1]
+	dt := (last.elapsed - first.elapsed).Seconds()
+	if dt <= 0 || last.fraction <= first.fraction {
+		return 0, 0
+	}
+	rate := (last.fraction - first.fraction) / dt
+	eta := time.Duration((1 - fraction) / rate * float64(time.Second))
+
+	// coefficient of variation of the per-interval rates
+	var rates []float64
+	for i := 1; i < len(e.samples); i++ {
+		d := (e.samples[i].elapsed - e.samples[i-1].elapsed).Seconds()
+		if d > 0 {
+			rates = append(rates, (e.samples[i].fraction-e.samples[i-1].fraction)/d)
+		}
+	}
+	mean := 0.0
+	for _, r := range rates {
+		mean += r
+	}
+	mean /= float64(len(rates))
+	variance := 0.0
+	for _, r := range rates {
+		variance += (r - mean) * (r - mean)
+	}
+	variance /= float64(len(rates))
+	consistency := 1 - math.Sqrt(variance)/mean
+	if consistency < 0 {
+		consistency = 0
+	}
+	coverage := float64(len(e.samples)-1) / float64(etaWindow-1)
+	return eta, consistency * coverage
+}
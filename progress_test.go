@@ -0,0 +1,111 @@
+package porcupine
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCheckOperationsProgress(t *testing.T) {
+	ops := []Operation{
+		{0, kvInput{op: 1, key: "x", value: "y"}, 0, kvOutput{}, 10},
+		{1, kvInput{op: 0, key: "x"}, 5, kvOutput{"y"}, 15},
+		{2, kvInput{op: 1, key: "z", value: "w"}, 20, kvOutput{}, 30},
+	}
+	var reports []Progress
+	res := CheckOperationsProgress(kvModel, ops, 0, func(p Progress) {
+		reports = append(reports, p)
+	})
+	if res != Ok {
+		t.Fatalf("expected output %v, got output %v", Ok, res)
+	}
+	if len(reports) == 0 {
+		t.Fatal("expected at least one progress report")
+	}
+	last := reports[len(reports)-1]
+	if !last.Done || last.Confidence != 1 || last.ETA != 0 {
+		t.Fatalf("expected final report to be done, got %+v", last)
+	}
+	if last.Partitions != 2 || last.PartitionsDone != 2 {
+		t.Fatalf("expected 2 of 2 partitions done, got %+v", last)
+	}
+	if last.Operations != 3 || last.Frontier != 3 {
+		t.Fatalf("expected frontier to cover all 3 operations, got %+v", last)
+	}
+}
+
+func TestCheckEventsProgressIllegal(t *testing.T) {
+	events := parseKvLog("test_data/kv/c10-bad.txt")
+	var last Progress
+	res := CheckEventsProgress(kvModel, events, 0, func(p Progress) {
+		last = p
+	})
+	if res != Illegal {
+		t.Fatalf("expected output %v, got output %v", Illegal, res)
+	}
+	if !last.Done {
+		t.Fatalf("expected final report to be done, got %+v", last)
+	}
+}
+
+func TestProgressReportedWhileRunning(t *testing.T) {
+	old := progressInterval
+	progressInterval = 10 * time.Millisecond
+	defer func() { progressInterval = old }()
+
+	events := parseKvLog("test_data/kv/c10-ok.txt")
+	var reports []Progress
+	CheckEventsProgress(kvNoPartitionModel, events, 500*time.Millisecond, func(p Progress) {
+		reports = append(reports, p)
+	})
+	if len(reports) < 2 {
+		t.Fatalf("expected periodic progress reports, got %d", len(reports))
+	}
+	for i := 1; i < len(reports); i++ {
+		if reports[i].Frontier < reports[i-1].Frontier {
+			t.Fatalf("frontier went backwards: %d -> %d", reports[i-1].Frontier, reports[i].Frontier)
+		}
+	}
+}
+
+func TestEtaEstimatorSteadyRate(t *testing.T) {
+	var e etaEstimator
+	var eta time.Duration
+	var confidence float64
+	// 1% of the frontier per second
+	for i := 0; i <= etaWindow; i++ {
+		eta, confidence = e.estimate(time.Duration(i)*time.Second, float64(i)/100)
+	}
+	expected := time.Duration(100-etaWindow) * time.Second
+	if eta < expected-time.Second || eta > expected+time.Second {
+		t.Fatalf("expected ETA of about %v, got %v", expected, eta)
+	}
+	if confidence < 0.99 {
+		t.Fatalf("expected high confidence for a steady rate, got %v", confidence)
+	}
+}
+
+func TestEtaEstimatorStalled(t *testing.T) {
+	var e etaEstimator
+	var confidence float64
+	for i := 0; i < etaWindow; i++ {
+		_, confidence = e.estimate(time.Duration(i)*time.Second, 0.5)
+	}
+	if confidence != 0 {
+		t.Fatalf("expected no confidence when the frontier is stalled, got %v", confidence)
+	}
+}
+
+func TestEtaEstimatorErratic(t *testing.T) {
+	var e etaEstimator
+	var confidence float64
+	fraction := 0.0
+	for i := 0; i < etaWindow; i++ {
+		if i%4 == 0 {
+			fraction += 0.1
+		}
+		_, confidence = e.estimate(time.Duration(i)*time.Second, fraction)
+	}
+	if confidence > 0.5 {
+		t.Fatalf("expected low confidence for an erratic rate, got %v", confidence)
+	}
+}
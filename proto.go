@@ -0,0 +1,411 @@
+package porcupine
+
+import (
+	"errors"
+	"fmt"
+)
+
+// This file implements the protobuf wire format for the messages defined in
+// proto/porcupine.proto, without depending on a protobuf runtime.
+
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+// field numbers, from proto/porcupine.proto
+const (
+	protoOperationClient = 1
+	protoOperationInput  = 2
+	protoOperationCall   = 3
+	protoOperationOutput = 4
+	protoOperationReturn = 5
+
+	protoEventClient = 1
+	protoEventKind   = 2
+	protoEventValue  = 3
+	protoEventId     = 4
+
+	protoHistoryOperations = 1
+	protoHistoryEvents     = 2
+
+	protoLinearizationOperations = 1
+
+	protoPartitionOperations            = 1
+	protoPartitionPartialLinearizations = 2
+
+	protoReportResult     = 1
+	protoReportPartitions = 2
+)
+
+var protoCheckResults = []CheckResult{Unknown, Ok, Illegal}
+
+// MarshalOperationsProto encodes a history of operations as a History
+// message (see proto/porcupine.proto), encoding inputs and outputs with the
+// given codec. If codec is nil, they are encoded as JSON.
+func MarshalOperationsProto(history []Operation, codec Codec) ([]byte, error) {
+	if codec == nil {
+		codec = &SchemaCodec{}
+	}
+	var b []byte
+	for i, op := range history {
+		msg, err := marshalProtoOperation(codec, op)
+		if err != nil {
+			return nil, fmt.Errorf("operation %d: %v", i, err)
+		}
+		b = appendProtoMessage(b, protoHistoryOperations, msg)
+	}
+	return b, nil
+}
+
+// UnmarshalOperationsProto decodes a history of operations from a History
+// message, decoding inputs and outputs with the given codec. If codec is nil,
+// they are decoded from JSON as dynamic values.
+func UnmarshalOperationsProto(data []byte, codec Codec) ([]Operation, error) {
+	if codec == nil {
+		codec = &SchemaCodec{}
+	}
+	var ops []Operation
+	err := parseProto(data, func(field, wire int, v uint64, b []byte) error {
+		if field != protoHistoryOperations || wire != wireBytes {
+			return nil
+		}
+		op, err := unmarshalProtoOperation(codec, b, fmt.Sprintf("operations[%d]", len(ops)))
+		ops = append(ops, op)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ops, nil
+}
+
+// MarshalEventsProto encodes a history of events as a History message (see
+// proto/porcupine.proto), encoding call values as inputs and return values as
+// outputs with the given codec. If codec is nil, they are encoded as JSON.
+func MarshalEventsProto(history []Event, codec Codec) ([]byte, error) {
+	if codec == nil {
+		codec = &SchemaCodec{}
+	}
+	var b []byte
+	for i, e := range history {
+		var value []byte
+		var err error
+		kind := uint64(0)
+		if e.Kind == CallEvent {
+			value, err = codec.EncodeInput(e.Value)
+		} else {
+			kind = 1
+			value, err = codec.EncodeOutput(e.Value)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("event %d: %v", i, err)
+		}
+		var msg []byte
+		msg = appendProtoVarint(msg, protoEventClient, uint64(e.ClientId))
+		msg = appendProtoVarint(msg, protoEventKind, kind)
+		msg = appendProtoBytes(msg, protoEventValue, value)
+		msg = appendProtoVarint(msg, protoEventId, uint64(e.Id))
+		b = appendProtoMessage(b, protoHistoryEvents, msg)
+	}
+	return b, nil
+}
+
+// UnmarshalEventsProto decodes a history of events from a History message,
+// decoding call values as inputs and return values as outputs with the given
+// codec. If codec is nil, they are decoded from JSON as dynamic values.
+func UnmarshalEventsProto(data []byte, codec Codec) ([]Event, error) {
+	if codec == nil {
+		codec = &SchemaCodec{}
+	}
+	var events []Event
+	err := parseProto(data, func(field, wire int, v uint64, b []byte) error {
+		if field != protoHistoryEvents || wire != wireBytes {
+			return nil
+		}
+		path := fmt.Sprintf("events[%d]", len(events))
+		var e Event
+		var value []byte
+		err := parseProto(b, func(field, wire int, v uint64, b []byte) error {
+			switch field {
+			case protoEventClient:
+				e.ClientId = int(v)
+			case protoEventKind:
+				e.Kind = v == 1
+			case protoEventValue:
+				value = b
+			case protoEventId:
+				e.Id = int(v)
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		decode := codec.DecodeInput
+		if e.Kind == ReturnEvent {
+			decode = codec.DecodeOutput
+		}
+		e.Value, err = decodeWith(decode, value, path+".value")
+		events = append(events, e)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// MarshalCheckReportProto encodes the result of a check as a CheckReport
+// message (see proto/porcupine.proto). If the LinearizationInfo came from a
+// verbose check, the report includes each partition's operations and partial
+// linearizations. Inputs and outputs are encoded with the given codec; if
+// codec is nil, they are encoded as JSON.
+func MarshalCheckReportProto(result CheckResult, info LinearizationInfo, codec Codec) ([]byte, error) {
+	if codec == nil {
+		codec = &SchemaCodec{}
+	}
+	var b []byte
+	for i, r := range protoCheckResults {
+		if r == result {
+			b = appendProtoVarint(b, protoReportResult, uint64(i))
+		}
+	}
+	for p, history := range info.history {
+		var partition []byte
+		for _, op := range entryOperations(history) {
+			msg, err := marshalProtoOperation(codec, op)
+			if err != nil {
+				return nil, fmt.Errorf("partition %d: %v", p, err)
+			}
+			partition = appendProtoMessage(partition, protoPartitionOperations, msg)
+		}
+		for _, partial := range info.partialLinearizations[p] {
+			var packed []byte
+			for _, id := range partial {
+				packed = appendVarint(packed, uint64(id))
+			}
+			var msg []byte
+			msg = appendProtoBytes(msg, protoLinearizationOperations, packed)
+			partition = appendProtoMessage(partition, protoPartitionPartialLinearizations, msg)
+		}
+		b = appendProtoMessage(b, protoReportPartitions, partition)
+	}
+	return b, nil
+}
+
+// UnmarshalCheckReportProto decodes the result of a check from a
+// CheckReport message, decoding inputs and outputs with the given codec. If
+// codec is nil, they are decoded from JSON as dynamic values. The returned
+// LinearizationInfo can be used with [Visualize].
+func UnmarshalCheckReportProto(data []byte, codec Codec) (CheckResult, LinearizationInfo, error) {
+	if codec == nil {
+		codec = &SchemaCodec{}
+	}
+	result := Unknown
+	var info LinearizationInfo
+	err := parseProto(data, func(field, wire int, v uint64, b []byte) error {
+		switch field {
+		case protoReportResult:
+			if v >= uint64(len(protoCheckResults)) {
+				return fmt.Errorf("invalid result %d", v)
+			}
+			result = protoCheckResults[v]
+		case protoReportPartitions:
+			p := len(info.history)
+			history, partials, err := unmarshalProtoPartition(codec, b, fmt.Sprintf("partitions[%d]", p))
+			if err != nil {
+				return err
+			}
+			info.history = append(info.history, history)
+			info.partialLinearizations = append(info.partialLinearizations, partials)
+		}
+		return nil
+	})
+	if err != nil {
+		return Unknown, LinearizationInfo{}, err
+	}
+	return result, info, nil
+}
+
+func unmarshalProtoPartition(codec Codec, data []byte, path string) ([]entry, [][]int, error) {
+	var ops []Operation
+	var partials [][]int
+	err := parseProto(data, func(field, wire int, v uint64, b []byte) error {
+		switch field {
+		case protoPartitionOperations:
+			op, err := unmarshalProtoOperation(codec, b, fmt.Sprintf("%s.operations[%d]", path, len(ops)))
+			ops = append(ops, op)
+			return err
+		case protoPartitionPartialLinearizations:
+			partial := []int{}
+			err := parseProto(b, func(field, wire int, v uint64, b []byte) error {
+				if field != protoLinearizationOperations {
+					return nil
+				}
+				if wire == wireVarint {
+					partial = append(partial, int(v))
+					return nil
+				}
+				// packed
+				for len(b) > 0 {
+					id, n := readVarint(b)
+					if n == 0 {
+						return errors.New("truncated varint")
+					}
+					partial = append(partial, int(id))
+					b = b[n:]
+				}
+				return nil
+			})
+			partials = append(partials, partial)
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, partial := range partials {
+		for _, id := range partial {
+			if id < 0 || id >= len(ops) {
+				return nil, nil, fmt.Errorf("%s: linearization refers to unknown operation %d", path, id)
+			}
+		}
+	}
+	return makeEntries(ops, nil), partials, nil
+}
+
+func marshalProtoOperation(codec Codec, op Operation) ([]byte, error) {
+	jop, err := encodeOperation(codec, op)
+	if err != nil {
+		return nil, err
+	}
+	var b []byte
+	b = appendProtoVarint(b, protoOperationClient, uint64(jop.Client))
+	b = appendProtoBytes(b, protoOperationInput, jop.Input)
+	b = appendProtoVarint(b, protoOperationCall, uint64(jop.Call))
+	b = appendProtoBytes(b, protoOperationOutput, jop.Output)
+	b = appendProtoVarint(b, protoOperationReturn, uint64(jop.Return))
+	return b, nil
+}
+
+func unmarshalProtoOperation(codec Codec, data []byte, path string) (Operation, error) {
+	var jop jsonOperation
+	err := parseProto(data, func(field, wire int, v uint64, b []byte) error {
+		switch field {
+		case protoOperationClient:
+			jop.Client = int(v)
+		case protoOperationInput:
+			jop.Input = b
+		case protoOperationCall:
+			jop.Call = int64(v)
+		case protoOperationOutput:
+			jop.Output = b
+		case protoOperationReturn:
+			jop.Return = int64(v)
+		}
+		return nil
+	})
+	if err != nil {
+		return Operation{}, fmt.Errorf("%s: %v", path, err)
+	}
+	return decodeOperation(codec, jop, path)
+}
+
+// appendProtoVarint appends a varint field, omitting it if it has the
+// default value, as proto3 does.
+func appendProtoVarint(b []byte, field int, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = appendVarint(b, uint64(field)<<3|wireVarint)
+	return appendVarint(b, v)
+}
+
+// appendProtoBytes appends a bytes or string field, omitting it if it is
+// empty, as proto3 does.
+func appendProtoBytes(b []byte, field int, v []byte) []byte {
+	if len(v) == 0 {
+		return b
+	}
+	return appendProtoMessage(b, field, v)
+}
+
+// appendProtoMessage appends an embedded message field, even if it is empty,
+// so that repeated fields keep their length.
+func appendProtoMessage(b []byte, field int, msg []byte) []byte {
+	b = appendVarint(b, uint64(field)<<3|wireBytes)
+	b = appendVarint(b, uint64(len(msg)))
+	return append(b, msg...)
+}
+
+func appendVarint(b []byte, v uint64) []byte {
+	for v >= 0x80 {
+		b = append(b, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(b, byte(v))
+}
+
+// readVarint decodes a varint, returning the number of bytes read, or 0 if
+// the varint is truncated or too long.
+func readVarint(b []byte) (uint64, int) {
+	var v uint64
+	for i := 0; i < len(b) && i < 10; i++ {
+		v |= uint64(b[i]&0x7f) << (7 * i)
+		if b[i] < 0x80 {
+			return v, i + 1
+		}
+	}
+	return 0, 0
+}
+
+// parseProto calls fn for each field of a message, with the value of varint
+// fields in v and the contents of length-delimited fields in b. Fixed-width
+// fields, which none of the messages use, are skipped.
+func parseProto(data []byte, fn func(field, wire int, v uint64, b []byte) error) error {
+	for len(data) > 0 {
+		tag, n := readVarint(data)
+		if n == 0 {
+			return errors.New("truncated tag")
+		}
+		data = data[n:]
+		field, wire := int(tag>>3), int(tag&7)
+		var v uint64
+		var b []byte
+		switch wire {
+		case wireVarint:
+			v, n = readVarint(data)
+			if n == 0 {
+				return errors.New("truncated varint")
+			}
+			data = data[n:]
+		case wireBytes:
+			length, n := readVarint(data)
+			if n == 0 || uint64(len(data)-n) < length {
+				return errors.New("truncated field")
+			}
+			b = data[n : n+int(length)]
+			data = data[n+int(length):]
+		case wireFixed64, wireFixed32:
+			size := 8
+			if wire == wireFixed32 {
+				size = 4
+			}
+			if len(data) < size {
+				return errors.New("truncated field")
+			}
+			data = data[size:]
+			continue
+		default:
+			return fmt.Errorf("unsupported wire type %d", wire)
+		}
+		if err := fn(field, wire, v, b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
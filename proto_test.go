@@ -0,0 +1,132 @@
+package porcupine
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestOperationsProtoRoundTrip(t *testing.T) {
+	ops := []Operation{
+		{0, kvInput{op: 1, key: "x", value: "a"}, 0, kvOutput{}, 10},
+		{1, kvInput{op: 0, key: "x"}, 5, kvOutput{"a"}, 1 << 40},
+	}
+	data, err := MarshalOperationsProto(ops, kvCodec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	read, err := UnmarshalOperationsProto(data, kvCodec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(ops, read) {
+		t.Fatalf("expected %v, got %v", ops, read)
+	}
+}
+
+func TestOperationsProtoWireFormat(t *testing.T) {
+	// History{operations: [Operation{client_id: 1, input: `"x"`, output: `null`}]}
+	expected := []byte{
+		0x0a, 0x0d,
+		0x08, 0x01,
+		0x12, 0x03, '"', 'x', '"',
+		0x22, 0x04, 'n', 'u', 'l', 'l',
+	}
+	data, err := MarshalOperationsProto([]Operation{{ClientId: 1, Input: "x"}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(expected, data) {
+		t.Fatalf("expected % x, got % x", expected, data)
+	}
+	// unknown fields are skipped
+	data = append(data, 0x7a, 0x01, 0x00, 0x81, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00)
+	ops, err := UnmarshalOperationsProto(data, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ops) != 1 || ops[0].Input != "x" || ops[0].ClientId != 1 {
+		t.Fatalf("unexpected operations %v", ops)
+	}
+	if _, err := UnmarshalOperationsProto(data[:5], nil); err == nil {
+		t.Fatal("expected an error for a truncated message")
+	}
+}
+
+func TestEventsProtoRoundTrip(t *testing.T) {
+	events := []Event{
+		{0, CallEvent, registerInput{false, 1}, 0},
+		{1, CallEvent, registerInput{true, 0}, 1},
+		{1, ReturnEvent, 1, 1},
+		{0, ReturnEvent, 0, 0},
+	}
+	codec := &FuncCodec{
+		MarshalInput: func(input interface{}) ([]byte, error) {
+			inp := input.(registerInput)
+			return []byte{boolByte(inp.op), byte(inp.value)}, nil
+		},
+		UnmarshalInput: func(data []byte) (interface{}, error) {
+			return registerInput{data[0] == 1, int(data[1])}, nil
+		},
+		MarshalOutput: func(output interface{}) ([]byte, error) {
+			return []byte{byte(output.(int))}, nil
+		},
+		UnmarshalOutput: func(data []byte) (interface{}, error) {
+			return int(data[0]), nil
+		},
+	}
+	data, err := MarshalEventsProto(events, codec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	read, err := UnmarshalEventsProto(data, codec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(events, read) {
+		t.Fatalf("expected %v, got %v", events, read)
+	}
+}
+
+func boolByte(b bool) byte {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func TestCheckReportProtoRoundTrip(t *testing.T) {
+	ops := []Operation{
+		{0, kvInput{op: 1, key: "x", value: "a"}, 0, kvOutput{}, 10},
+		{1, kvInput{op: 0, key: "x"}, 20, kvOutput{"b"}, 30},
+		{2, kvInput{op: 1, key: "y", value: "c"}, 0, kvOutput{}, 10},
+	}
+	res, info := CheckOperationsVerbose(kvModel, ops, 0)
+	if res != Illegal {
+		t.Fatalf("expected output %v, got output %v", Illegal, res)
+	}
+	data, err := MarshalCheckReportProto(res, info, kvCodec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res2, info2, err := UnmarshalCheckReportProto(data, kvCodec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res2 != res {
+		t.Fatalf("expected result %v, got %v", res, res2)
+	}
+	if !reflect.DeepEqual(info.PartialLinearizationsOperations(), info2.PartialLinearizationsOperations()) {
+		t.Fatalf("expected %v, got %v", info.PartialLinearizationsOperations(), info2.PartialLinearizationsOperations())
+	}
+	visualizeTempFile(t, kvModel, info2)
+
+	// a non-verbose report has just the result
+	data, err = MarshalCheckReportProto(Ok, LinearizationInfo{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal([]byte{0x08, 0x01}, data) {
+		t.Fatalf("unexpected encoding % x", data)
+	}
+}
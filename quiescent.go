@@ -0,0 +1,49 @@
+package porcupine
+
+import "sort"
+
+// CheckQuiescentConsistency checks whether a history is quiescently
+// consistent: whether there is a sequential order of its operations, legal
+// for the model, in which operations separated by a period of quiescence, when
+// no operation is in progress, take effect in real-time order. Operations
+// within a busy period can take effect in any order, even when one returns
+// before the other is called, so several concurrent data structures, such as
+// counting networks, are quiescently consistent but not linearizable.
+//
+// Quiescent consistency is compositional, so each of the model's partitions
+// is an object with its own periods of quiescence. The check is a
+// linearizability check in which each operation spans its busy period, and
+// takes the same options as [CheckHistory].
+func CheckQuiescentConsistency(model Model, history []Operation, opts ...CheckOption) CheckResult {
+	model = fillDefault(model)
+	var relaxed []Operation
+	for _, partition := range model.Partition(history) {
+		relaxed = append(relaxed, spanBusyPeriods(partition)...)
+	}
+	res, _ := CheckHistory(model, relaxed, opts...)
+	return res
+}
+
+// spanBusyPeriods returns a copy of a history in which each operation spans
+// the busy period it is in.
+func spanBusyPeriods(history []Operation) []Operation {
+	ops := make([]Operation, len(history))
+	copy(ops, history)
+	sort.SliceStable(ops, func(i, j int) bool {
+		return ops[i].Call < ops[j].Call
+	})
+	for start := 0; start < len(ops); {
+		end, busyUntil := start, ops[start].Return
+		for end < len(ops) && ops[end].Call <= busyUntil {
+			if ops[end].Return > busyUntil {
+				busyUntil = ops[end].Return
+			}
+			end++
+		}
+		for i := start; i < end; i++ {
+			ops[i].Call, ops[i].Return = ops[start].Call, busyUntil
+		}
+		start = end
+	}
+	return ops
+}
@@ -0,0 +1,42 @@
+package porcupine
+
+import "testing"
+
+func TestCheckQuiescentConsistency(t *testing.T) {
+	// the read returns the old value after the write returns, but the
+	// long-running read of client 2 keeps the register busy throughout
+	history := []Operation{
+		{ClientId: 0, Input: registerInput{false, 1}, Call: 0, Output: 0, Return: 10},
+		{ClientId: 1, Input: registerInput{true, 0}, Call: 20, Output: 0, Return: 30},
+		{ClientId: 2, Input: registerInput{true, 0}, Call: 5, Output: 1, Return: 25},
+	}
+	if CheckOperations(registerModel, history) {
+		t.Fatal("expected operations not to be linearizable")
+	}
+	if res := CheckQuiescentConsistency(registerModel, history); res != Ok {
+		t.Fatalf("expected output %v, got output %v", Ok, res)
+	}
+	// after a quiescent period, the write must have taken effect
+	history[2].Return = 15
+	if res := CheckQuiescentConsistency(registerModel, history); res != Illegal {
+		t.Fatalf("expected output %v, got output %v", Illegal, res)
+	}
+	if history[0].Call != 0 || history[0].Return != 10 {
+		t.Fatal("expected the history to be unchanged")
+	}
+}
+
+func TestSpanBusyPeriods(t *testing.T) {
+	ops := spanBusyPeriods([]Operation{
+		{Call: 10, Return: 20},
+		{Call: 0, Return: 10},
+		{Call: 30, Return: 40},
+		{Call: 35, Return: 36},
+	})
+	expected := [][2]int64{{0, 20}, {0, 20}, {30, 40}, {30, 40}}
+	for i, op := range ops {
+		if op.Call != expected[i][0] || op.Return != expected[i][1] {
+			t.Fatalf("operation %d: expected %v, got [%d, %d]", i, expected[i], op.Call, op.Return)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package porcupine
+
+import "sort"
+
+// RankTimestamps returns a copy of a history with its Call and Return
+// timestamps replaced by their dense ranks: the smallest timestamp in the
+// history becomes 0, the next smallest distinct timestamp 1, and so on.
+//
+// Ranking preserves the order of every pair of timestamps, including ties,
+// so operations overlap in the ranked history exactly when they overlap in
+// the original one, and the result of a check is the same. The ranked
+// timestamps are small and dense, which makes the history smaller to store,
+// e.g., with varint encodings, and keeps timestamps that span huge ranges,
+// such as raw nanosecond clocks from machines that have been up for years,
+// from distorting visualizations and time-based analyses such as
+// [CheckSensitivity], where a jitter is then measured in ranks.
+func RankTimestamps(history []Operation) []Operation {
+	times := make([]int64, 0, 2*len(history))
+	for _, op := range history {
+		times = append(times, op.Call, op.Return)
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i] < times[j] })
+	ranks := make(map[int64]int64, len(times))
+	for _, t := range times {
+		if _, ok := ranks[t]; !ok {
+			ranks[t] = int64(len(ranks))
+		}
+	}
+	ranked := make([]Operation, len(history))
+	for i, op := range history {
+		op.Call = ranks[op.Call]
+		op.Return = ranks[op.Return]
+		ranked[i] = op
+	}
+	return ranked
+}
@@ -0,0 +1,40 @@
+package porcupine
+
+import (
+	"math"
+	"testing"
+)
+
+func TestRankTimestamps(t *testing.T) {
+	history := []Operation{
+		{ClientId: 0, Input: registerInput{false, 100}, Call: math.MinInt64, Output: 0, Return: 1 << 40},
+		{ClientId: 1, Input: registerInput{true, 0}, Call: 1 << 40, Output: 100, Return: math.MaxInt64},
+		{ClientId: 2, Input: registerInput{true, 0}, Call: 7, Output: 0, Return: 1 << 40},
+	}
+	ranked := RankTimestamps(history)
+	expected := [][2]int64{{0, 2}, {2, 3}, {1, 2}}
+	for i, op := range ranked {
+		if op.Call != expected[i][0] || op.Return != expected[i][1] {
+			t.Fatalf("operation %d: expected times %v, got [%d %d]", i, expected[i], op.Call, op.Return)
+		}
+		if op.ClientId != history[i].ClientId || op.Input != history[i].Input || op.Output != history[i].Output {
+			t.Fatalf("operation %d: expected only the times to change, got %+v", i, op)
+		}
+	}
+	if history[0].Call != math.MinInt64 {
+		t.Fatal("expected the original history to be unchanged")
+	}
+
+	for _, tc := range []struct {
+		file     string
+		expected bool
+	}{
+		{"test_data/kv/c10-ok.txt", true},
+		{"test_data/kv/c10-bad.txt", false},
+	} {
+		ops := RankTimestamps(kvOperations(parseKvLog(tc.file)))
+		if res := CheckOperations(kvModel, ops); res != tc.expected {
+			t.Fatalf("%s: expected output %v, got output %v", tc.file, tc.expected, res)
+		}
+	}
+}
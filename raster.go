@@ -0,0 +1,167 @@
+package porcupine
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"os"
+	"sort"
+)
+
+// PNGOptions configures [VisualizePNG].
+type PNGOptions struct {
+	// the size of the image, in pixels; a width of 0 means 1200, and a height
+	// of 0 fits the history's lanes at 16 pixels each
+	Width, Height int
+	// if End is after Start, the image only shows this range of time, in
+	// the units of the history's timestamps
+	Start, End int64
+}
+
+var (
+	pngBackground = color.RGBA{0xff, 0xff, 0xff, 0xff}
+	pngSeparator  = color.RGBA{0xcc, 0xcc, 0xcc, 0xff}
+	// operations in a partial linearization, and ones that aren't
+	pngLinearized = color.RGBA{0x8b, 0xc3, 0x4a, 0xff}
+	pngIllegal    = color.RGBA{0xe5, 0x39, 0x35, 0xff}
+)
+
+const (
+	pngDefaultWidth = 1200
+	pngLaneHeight   = 16
+	pngMargin       = 4
+)
+
+// VisualizePNG renders a history as a PNG image, for dashboards and chat
+// notifications that can't show the HTML of [Visualize]. Each partition is a
+// band of lanes, one per client, with each operation drawn as a bar from its
+// call to its return: green if it is in a partial linearization, and red
+// otherwise. The image has no text, so it's an overview; the HTML
+// visualization has the details.
+func VisualizePNG(model Model, info LinearizationInfo, output io.Writer, opts PNGOptions) error {
+	return png.Encode(output, renderHistory(info, opts))
+}
+
+// VisualizePNGPath is a wrapper around [VisualizePNG] to write the image to a
+// file path.
+func VisualizePNGPath(model Model, info LinearizationInfo, path string, opts PNGOptions) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return VisualizePNG(model, info, f, opts)
+}
+
+// renderHistory draws the image for VisualizePNG.
+func renderHistory(info LinearizationInfo, opts PNGOptions) *image.RGBA {
+	type bar struct {
+		lane       int
+		call, ret  int64
+		linearized bool
+	}
+	var bars []bar
+	var separators []int // the lanes that start each partition after the first
+	lanes := 0
+	start, end := opts.Start, opts.End
+	fit := end <= start
+	first := true
+	for p, partition := range info.history {
+		if len(partition) == 0 {
+			continue
+		}
+		linearized := make(map[int]bool)
+		for _, partial := range info.partialLinearizations[p] {
+			for _, id := range partial {
+				linearized[id] = true
+			}
+		}
+		var clients []int
+		laneOf := make(map[int]int)
+		for _, e := range partition {
+			if _, ok := laneOf[e.clientId]; !ok {
+				laneOf[e.clientId] = 0
+				clients = append(clients, e.clientId)
+			}
+		}
+		sort.Ints(clients)
+		if lanes > 0 {
+			separators = append(separators, lanes)
+		}
+		for i, c := range clients {
+			laneOf[c] = lanes + i
+		}
+		calls := make(map[int]int64)
+		for _, e := range partition {
+			if e.kind == callEntry {
+				calls[e.id] = e.time
+				continue
+			}
+			b := bar{lane: laneOf[e.clientId], call: calls[e.id], ret: e.time, linearized: linearized[e.id]}
+			bars = append(bars, b)
+			if fit && (first || b.call < start) {
+				start = b.call
+			}
+			if fit && (first || b.ret > end) {
+				end = b.ret
+			}
+			first = false
+		}
+		lanes += len(clients)
+	}
+	width, height := opts.Width, opts.Height
+	if width <= 0 {
+		width = pngDefaultWidth
+	}
+	if height <= 0 {
+		height = lanes*pngLaneHeight + 2*pngMargin
+	}
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), &image.Uniform{pngBackground}, image.Point{}, draw.Src)
+	if lanes == 0 {
+		return img
+	}
+	laneHeight := float64(height-2*pngMargin) / float64(lanes)
+	span := end - start
+	if span <= 0 {
+		span = 1
+	}
+	x := func(t int64) int {
+		if t < start {
+			t = start
+		}
+		if t > end {
+			t = end
+		}
+		return pngMargin + int(float64(t-start)*float64(width-2*pngMargin)/float64(span))
+	}
+	y := func(lane int) int {
+		return pngMargin + int(float64(lane)*laneHeight)
+	}
+	for _, lane := range separators {
+		line := image.Rect(0, y(lane)-1, width, y(lane))
+		draw.Draw(img, line, &image.Uniform{pngSeparator}, image.Point{}, draw.Src)
+	}
+	for _, b := range bars {
+		if b.ret < start || b.call > end {
+			continue
+		}
+		c := pngIllegal
+		if b.linearized {
+			c = pngLinearized
+		}
+		x0, x1 := x(b.call), x(b.ret)
+		if x1 <= x0 {
+			x1 = x0 + 1
+		}
+		// leave a gap between lanes
+		y0, y1 := y(b.lane)+1, y(b.lane+1)-1
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+		draw.Draw(img, image.Rect(x0, y0, x1, y1), &image.Uniform{c}, image.Point{}, draw.Src)
+	}
+	return img
+}
@@ -0,0 +1,54 @@
+package porcupine
+
+import (
+	"bytes"
+	"image/png"
+	"testing"
+)
+
+func TestVisualizePNG(t *testing.T) {
+	ops := []Operation{
+		{ClientId: 0, Input: registerInput{false, 1}, Call: 0, Output: 0, Return: 10},
+		{ClientId: 1, Input: registerInput{true, 0}, Call: 20, Output: 2, Return: 30},
+		{ClientId: 2, Input: registerInput{true, 0}, Call: 40, Output: 1, Return: 50},
+	}
+	res, info := CheckOperationsVerbose(registerModel, ops, 0)
+	if res != Illegal {
+		t.Fatalf("expected output %v, got output %v", Illegal, res)
+	}
+	var buf bytes.Buffer
+	if err := VisualizePNG(registerModel, info, &buf, PNGOptions{}); err != nil {
+		t.Fatalf("visualization failed: %v", err)
+	}
+	img, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatalf("failed to decode image: %v", err)
+	}
+	if b := img.Bounds(); b.Dx() != pngDefaultWidth || b.Dy() != 3*pngLaneHeight+2*pngMargin {
+		t.Fatalf("unexpected size %v", b)
+	}
+	// the read of 2 can't be linearized, so it's drawn in red
+	lane := pngMargin + pngLaneHeight + pngLaneHeight/2
+	if c := img.At(pngDefaultWidth/2, lane); c != pngIllegal {
+		t.Fatalf("expected illegal operation at %d, got color %v", lane, c)
+	}
+
+	// crop to the first write, which then fills the width
+	buf.Reset()
+	if err := VisualizePNG(registerModel, info, &buf, PNGOptions{Width: 100, Height: 30, Start: 0, End: 10}); err != nil {
+		t.Fatalf("visualization failed: %v", err)
+	}
+	img, err = png.Decode(&buf)
+	if err != nil {
+		t.Fatalf("failed to decode image: %v", err)
+	}
+	if b := img.Bounds(); b.Dx() != 100 || b.Dy() != 30 {
+		t.Fatalf("unexpected size %v", b)
+	}
+	if c := img.At(90, pngMargin+2); c != pngLinearized {
+		t.Fatalf("expected linearized operation, got color %v", c)
+	}
+	if c := img.At(50, 15); c != pngBackground {
+		t.Fatalf("expected cropped operation not to be drawn, got color %v", c)
+	}
+}
@@ -0,0 +1,423 @@
+package porcupine
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"sort"
+	"sync/atomic"
+	"time"
+	"unsafe"
+)
+
+// A Recorder records a history of operations performed concurrently by a
+// number of clients, for checking with [CheckOperations] and friends.
+//
+// Each client is a sequential process, such as a goroutine, that performs one
+// operation at a time. Obtain a client with [Recorder.Client], and then
+// bracket each operation with [RecorderClient.Begin] and
+// [RecorderClient.End]:
+//
+//	recorder := porcupine.NewRecorder(porcupine.RecorderOptions{})
+//	client := recorder.Client()
+//	client.Begin(input)
+//	output := doOperation(input)
+//	client.End(output)
+//
+// Timestamps are taken from a monotonic clock. Operations that have begun but
+// not ended are not included in the recorded history.
+//
+// A client that multiplexes several logical sessions can say which one each
+// operation belongs to with [RecorderClient.SetSession]; the sessions are
+// recorded as [SessionTag] tags, in the history returned by
+// [Recorder.TaggedOperations].
+//
+// Recording is cheap enough to leave enabled in performance-sensitive stress
+// tests: each client appends to its own buffer, so Begin and End never take a
+// lock or contend with other clients, and they don't allocate except to grow
+// the buffer.
+type Recorder struct {
+	// accessed atomically; kept first for 64-bit alignment on 32-bit
+	// platforms
+	nextClient int64
+
+	clients unsafe.Pointer // *RecorderClient, head of a lock-free list of all clients
+	start   time.Time
+	opts    RecorderOptions
+	spill   *spiller // non-nil if operations are spilled to opts.Spill
+}
+
+// RecorderOptions configures a [Recorder]. The zero value records every
+// operation.
+//
+// For workloads that are too hot to record completely, SampleRate can be set
+// to a fraction in (0, 1) to record only that fraction of operations. Client
+// IDs are assigned the same way regardless of sampling, so sampled histories
+// from different runs line up.
+//
+// The checker only sees the sampled operations, so a check of a sampled
+// history is a check of the sampled subset, not of the full execution. In
+// particular, dropping an arbitrary subset of operations can make a correct
+// execution look non-linearizable (e.g., a read that observes a write that
+// was not sampled). To avoid this, set SampleKey to a function that maps an
+// operation's input to the key that the model partitions on: the sampling
+// decision is then made per key, so every recorded key has a complete
+// history, and checking it with a partitioned model is sound for the sampled
+// keys. Without SampleKey, each operation is sampled independently, which is
+// only appropriate for models that tolerate missing operations.
+//
+// For runs that are too long to hold in memory, set Spill to a writer, such
+// as a file: completed operations are then periodically appended to it as
+// JSON Lines and dropped from memory. Read the file back with
+// [ReadJSONLOperations] or [NewJSONLReader], using the same codec. Spilled
+// operations don't include their sessions.
+type RecorderOptions struct {
+	// Fraction of operations to record, in (0, 1]. A value of 0 records
+	// every operation.
+	SampleRate float64
+	// Optional: sample all-or-nothing per key, rather than per operation.
+	SampleKey func(input interface{}) string
+	// Seed for per-operation sampling. Unused if SampleKey is set. Each
+	// client samples from its own stream, derived from the seed and its
+	// client ID.
+	Seed int64
+	// Optional: write completed operations here, rather than keeping them in
+	// memory. The recorder must be closed with [Recorder.Close] when done.
+	Spill io.Writer
+	// Codec for spilled inputs and outputs; defaults to encoding/json.
+	SpillCodec Codec
+	// How often to spill completed operations; defaults to 100ms.
+	SpillInterval time.Duration
+}
+
+// NewRecorder creates a new [Recorder] with the given options.
+func NewRecorder(opts RecorderOptions) *Recorder {
+	r := &Recorder{
+		start: time.Now(),
+		opts:  opts,
+	}
+	if opts.Spill != nil {
+		r.spill = newSpiller(r)
+	}
+	return r
+}
+
+// Client returns a handle for a new client, which is assigned the next
+// available client ID.
+//
+// A RecorderClient must not be used concurrently from multiple goroutines.
+func (r *Recorder) Client() *RecorderClient {
+	id := int(atomic.AddInt64(&r.nextClient, 1) - 1)
+	c := r.newClient(id)
+	r.publish(c)
+	return c
+}
+
+func (r *Recorder) newClient(id int) *RecorderClient {
+	c := &RecorderClient{recorder: r, id: id}
+	c.head = newRecordChunk(recordChunkMin)
+	c.tail = c.head
+	return c
+}
+
+// publish adds a client to the recorder's list of clients.
+func (r *Recorder) publish(c *RecorderClient) {
+	for {
+		head := atomic.LoadPointer(&r.clients)
+		c.next = (*RecorderClient)(head)
+		if atomic.CompareAndSwapPointer(&r.clients, head, unsafe.Pointer(c)) {
+			return
+		}
+	}
+}
+
+// RestoreRecorder creates a new [Recorder] that starts out with the given
+// history, e.g., one saved by an earlier run, so that a paused test can
+// resume and append to it.
+//
+// The restored recorder continues where the history left off: its clock
+// starts after the latest timestamp in the history, so that new operations
+// come after the restored ones, and [Recorder.Client] assigns IDs after the
+// largest client ID in the history, so that new clients don't collide with
+// restored ones. To continue recording as one of the restored clients, use
+// [Recorder.ResumeClient].
+//
+// Restored operations are included in [Recorder.Operations], and if the
+// options set Spill, they are written to the spill writer along with new
+// operations, so the new spill file holds the complete history.
+func RestoreRecorder(history []Operation, opts RecorderOptions) *Recorder {
+	latest := int64(-1)
+	clients := make(map[int][]Operation)
+	var ids []int
+	for _, op := range history {
+		if op.Call > latest {
+			latest = op.Call
+		}
+		if op.Return > latest {
+			latest = op.Return
+		}
+		if _, ok := clients[op.ClientId]; !ok {
+			ids = append(ids, op.ClientId)
+		}
+		clients[op.ClientId] = append(clients[op.ClientId], op)
+	}
+	sort.Ints(ids)
+	r := &Recorder{
+		start: time.Now().Add(-time.Duration(latest + 1)),
+		opts:  opts,
+	}
+	if len(ids) > 0 {
+		r.nextClient = int64(ids[len(ids)-1] + 1)
+	}
+	for _, id := range ids {
+		c := r.newClient(id)
+		c.restored = true
+		for _, op := range clients[id] {
+			c.append(TaggedOperation{Operation: op})
+		}
+		r.publish(c)
+	}
+	if opts.Spill != nil {
+		r.spill = newSpiller(r)
+	}
+	return r
+}
+
+// RestoreRecorderJSONL is like [RestoreRecorder], but it reads the history
+// from a JSON Lines file, such as one written by a spilling recorder; see
+// [ReadJSONLOperations].
+func RestoreRecorderJSONL(r io.Reader, codec Codec, opts RecorderOptions) (*Recorder, error) {
+	history, err := ReadJSONLOperations(r, codec)
+	if err != nil {
+		return nil, err
+	}
+	return RestoreRecorder(history, opts), nil
+}
+
+// ResumeClient returns a handle for the restored client with the given ID,
+// so that it can continue recording under its old identity; see
+// [RestoreRecorder].
+//
+// ResumeClient panics if the history that the recorder was restored from has
+// no operations from a client with that ID, or if the client has already
+// been resumed.
+func (r *Recorder) ResumeClient(id int) *RecorderClient {
+	for c := (*RecorderClient)(atomic.LoadPointer(&r.clients)); c != nil; c = c.next {
+		if c.id != id || !c.restored {
+			continue
+		}
+		if !atomic.CompareAndSwapInt32(&c.resumed, 0, 1) {
+			panic(fmt.Sprintf("porcupine: client %d has already been resumed", id))
+		}
+		return c
+	}
+	panic(fmt.Sprintf("porcupine: no restored client with ID %d", id))
+}
+
+// Operations returns the operations that have been recorded so far, in the
+// order in which they ended.
+//
+// Operations can be called while clients are still recording; it returns
+// every operation whose End has returned.
+//
+// A recorder that spills to a writer does not keep operations in memory, so
+// Operations returns nil; read the spilled operations back instead.
+func (r *Recorder) Operations() []Operation {
+	tagged := r.TaggedOperations()
+	if tagged == nil {
+		return nil
+	}
+	ops := make([]Operation, len(tagged))
+	for i, op := range tagged {
+		ops[i] = op.Operation
+	}
+	return ops
+}
+
+// TaggedOperations is like [Recorder.Operations], but each operation is
+// tagged with the session it belongs to, if its client set one with
+// [RecorderClient.SetSession]; see [SessionTag].
+func (r *Recorder) TaggedOperations() []TaggedOperation {
+	if r.spill != nil {
+		return nil
+	}
+	var ops []TaggedOperation
+	for c := (*RecorderClient)(atomic.LoadPointer(&r.clients)); c != nil; c = c.next {
+		for chunk := c.head; chunk != nil; chunk = chunk.loadNext() {
+			n := atomic.LoadInt64(&chunk.n)
+			ops = append(ops, chunk.ops[:n]...)
+		}
+	}
+	sort.SliceStable(ops, func(i, j int) bool {
+		return ops[i].Return < ops[j].Return
+	})
+	return ops
+}
+
+func (r *Recorder) now() int64 {
+	return time.Since(r.start).Nanoseconds()
+}
+
+// recordChunkMin and recordChunkMax bound the size of a client's buffer
+// chunks: chunks start small, so that idle clients are cheap, and double in
+// size up to the maximum.
+const (
+	recordChunkMin = 16
+	recordChunkMax = 4096
+)
+
+// A recordChunk is a fixed-capacity piece of a client's buffer. Only the
+// owning client writes to a chunk; it publishes each operation by
+// incrementing n, and publishes the following chunk by setting next once this
+// one is full, so that readers can see a consistent prefix without locking.
+type recordChunk struct {
+	// accessed atomically; kept first for 64-bit alignment on 32-bit
+	// platforms
+	n int64
+
+	next unsafe.Pointer // *recordChunk
+	ops  []TaggedOperation
+}
+
+func newRecordChunk(size int) *recordChunk {
+	return &recordChunk{ops: make([]TaggedOperation, size)}
+}
+
+func (chunk *recordChunk) loadNext() *recordChunk {
+	return (*recordChunk)(atomic.LoadPointer(&chunk.next))
+}
+
+// A RecorderClient records the operations of a single client of a
+// [Recorder].
+type RecorderClient struct {
+	recorder *Recorder
+	id       int
+	next     *RecorderClient // next client in the recorder's list; immutable once published
+	rand     *rand.Rand      // created lazily, for per-operation sampling
+	pending  bool
+	sampled  bool
+	input    interface{}
+	call     int64
+	tags     map[string]string // recorded with each operation; set by SetSession
+	head     *recordChunk
+	tail     *recordChunk // the chunk being written to
+	n        int          // number of operations in tail, mirrors tail.n
+	restored bool         // whether the client was created by RestoreRecorder
+	resumed  int32        // accessed atomically; whether ResumeClient has returned this client
+}
+
+// Id returns the client ID that this client's operations are recorded with.
+func (c *RecorderClient) Id() int {
+	return c.id
+}
+
+// SetSession sets the session that the client's following operations
+// belong to, for a client that multiplexes several logical sessions, e.g.,
+// over one connection. An empty session means the client's own session,
+// which is the default. See [SessionTag].
+//
+// SetSession panics if an operation is in progress.
+func (c *RecorderClient) SetSession(session string) {
+	if c.pending {
+		panic("porcupine: SetSession called with an operation in progress")
+	}
+	if session == "" {
+		c.tags = nil
+	} else {
+		// shared by the operations with this session, which don't modify
+		// their tags
+		c.tags = map[string]string{SessionTag: session}
+	}
+}
+
+// Begin records the invocation of an operation with the given input.
+//
+// Begin panics if the client's previous operation has not ended.
+func (c *RecorderClient) Begin(input interface{}) {
+	if c.pending {
+		panic("porcupine: Begin called with an operation already in progress")
+	}
+	c.pending = true
+	c.sampled = c.sample(input)
+	c.input = input
+	c.call = c.recorder.now()
+}
+
+// End records the response to the operation in progress, with the given
+// output.
+//
+// End panics if there is no operation in progress.
+func (c *RecorderClient) End(output interface{}) {
+	ret := c.recorder.now()
+	if !c.pending {
+		panic("porcupine: End called without an operation in progress")
+	}
+	c.pending = false
+	if c.sampled {
+		c.append(TaggedOperation{
+			Operation: Operation{
+				ClientId: c.id,
+				Input:    c.input,
+				Call:     c.call,
+				Output:   output,
+				Return:   ret,
+			},
+			Tags: c.tags,
+		})
+	}
+	c.input = nil
+}
+
+func (c *RecorderClient) append(op TaggedOperation) {
+	if c.n == len(c.tail.ops) {
+		size := 2 * len(c.tail.ops)
+		if size > recordChunkMax {
+			size = recordChunkMax
+		}
+		chunk := newRecordChunk(size)
+		atomic.StorePointer(&c.tail.next, unsafe.Pointer(chunk))
+		c.tail = chunk
+		c.n = 0
+	}
+	c.tail.ops[c.n] = op
+	c.n++
+	atomic.StoreInt64(&c.tail.n, int64(c.n))
+}
+
+// sample decides whether an operation with the given input is recorded.
+func (c *RecorderClient) sample(input interface{}) bool {
+	opts := &c.recorder.opts
+	rate := opts.SampleRate
+	if rate <= 0 || rate >= 1 {
+		return true
+	}
+	if opts.SampleKey != nil {
+		return float64(mix64(fnv64a(opts.SampleKey(input)))) < rate*math.MaxUint64
+	}
+	if c.rand == nil {
+		c.rand = rand.New(rand.NewSource(opts.Seed + int64(c.id)))
+	}
+	return c.rand.Float64() < rate
+}
+
+// fnv64a is the 64-bit FNV-1a hash, computed without allocating.
+func fnv64a(s string) uint64 {
+	h := uint64(14695981039346656037)
+	for i := 0; i < len(s); i++ {
+		h ^= uint64(s[i])
+		h *= 1099511628211
+	}
+	return h
+}
+
+// mix64 is the MurmurHash3 finalizer. FNV alone leaves the high bits poorly
+// mixed for short keys that differ only in their last few bytes.
+func mix64(h uint64) uint64 {
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+	return h
+}
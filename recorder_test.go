@@ -0,0 +1,508 @@
+package porcupine
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// a linearizable key-value store for exercising the recorder
+type lockedKv struct {
+	mu   sync.Mutex
+	data map[string]string
+}
+
+func (kv *lockedKv) apply(inp kvInput) kvOutput {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	switch inp.op {
+	case 0:
+		return kvOutput{kv.data[inp.key]}
+	case 1:
+		kv.data[inp.key] = inp.value
+	case 2:
+		kv.data[inp.key] = kv.data[inp.key] + inp.value
+	}
+	return kvOutput{}
+}
+
+func runRecordedKv(recorder *Recorder, clients, opsPerClient, keys int) {
+	kv := &lockedKv{data: make(map[string]string)}
+	var wg sync.WaitGroup
+	for i := 0; i < clients; i++ {
+		client := recorder.Client()
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < opsPerClient; j++ {
+				inp := kvInput{
+					op:    uint8(j % 3),
+					key:   fmt.Sprintf("k%d", (i+j)%keys),
+					value: fmt.Sprintf("%d.%d ", i, j),
+				}
+				client.Begin(inp)
+				out := kv.apply(inp)
+				client.End(out)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestRecorder(t *testing.T) {
+	recorder := NewRecorder(RecorderOptions{})
+	runRecordedKv(recorder, 8, 50, 4)
+	ops := recorder.Operations()
+	if len(ops) != 8*50 {
+		t.Fatalf("expected %d operations, got %d", 8*50, len(ops))
+	}
+	for _, op := range ops {
+		if op.Call > op.Return {
+			t.Fatalf("operation returned before it was called: %+v", op)
+		}
+	}
+	res := CheckOperationsTimeout(kvModel, ops, 0)
+	if res != Ok {
+		t.Fatalf("expected output %v, got output %v", Ok, res)
+	}
+}
+
+func TestRecorderMisuse(t *testing.T) {
+	client := NewRecorder(RecorderOptions{}).Client()
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("expected End without Begin to panic")
+			}
+		}()
+		client.End(nil)
+	}()
+	client.Begin(nil)
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("expected nested Begin to panic")
+			}
+		}()
+		client.Begin(nil)
+	}()
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("expected SetSession during an operation to panic")
+			}
+		}()
+		client.SetSession("s")
+	}()
+}
+
+func TestRecorderSessions(t *testing.T) {
+	recorder := NewRecorder(RecorderOptions{})
+	client := recorder.Client()
+	for _, session := range []string{"a", "b", ""} {
+		client.SetSession(session)
+		client.Begin(registerInput{true, 0})
+		client.End(0)
+	}
+	var sessions []string
+	for _, op := range recorder.TaggedOperations() {
+		sessions = append(sessions, op.Session())
+	}
+	if !reflect.DeepEqual([]string{"a", "b", "client 0"}, sessions) {
+		t.Fatalf("unexpected sessions %v", sessions)
+	}
+	if len(recorder.Operations()) != 3 {
+		t.Fatalf("expected 3 operations, got %v", recorder.Operations())
+	}
+}
+
+func TestRecorderSamplingByKey(t *testing.T) {
+	sampleKey := func(input interface{}) string {
+		return input.(kvInput).key
+	}
+	recorder := NewRecorder(RecorderOptions{SampleRate: 0.5, SampleKey: sampleKey})
+	runRecordedKv(recorder, 8, 96, 32)
+	ops := recorder.Operations()
+
+	perKey := make(map[string]int)
+	clients := make(map[int]bool)
+	for _, op := range ops {
+		perKey[op.Input.(kvInput).key]++
+		clients[op.ClientId] = true
+	}
+	if len(perKey) == 0 || len(perKey) == 32 {
+		t.Fatalf("expected a strict subset of keys to be sampled, got %d of 32", len(perKey))
+	}
+	for key, count := range perKey {
+		// every client touches each key the same number of times
+		if count != 8*96/32 {
+			t.Fatalf("expected all %d operations on sampled key %s, got %d", 8*96/32, key, count)
+		}
+	}
+	for id := range clients {
+		if id < 0 || id >= 8 {
+			t.Fatalf("unexpected client id %d", id)
+		}
+	}
+	res := CheckOperationsTimeout(kvModel, ops, 0)
+	if res != Ok {
+		t.Fatalf("expected output %v, got output %v", Ok, res)
+	}
+}
+
+func TestRecorderSamplingPerOperation(t *testing.T) {
+	recorder := NewRecorder(RecorderOptions{SampleRate: 0.25, Seed: 1})
+	runRecordedKv(recorder, 4, 200, 4)
+	n := len(recorder.Operations())
+	if n < 100 || n > 300 {
+		t.Fatalf("expected about 200 of 800 operations to be sampled, got %d", n)
+	}
+}
+
+func TestRecorderOperationsWhileRecording(t *testing.T) {
+	recorder := NewRecorder(RecorderOptions{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		runRecordedKv(recorder, 4, 2000, 4)
+	}()
+	last := 0
+	for finished := false; !finished; {
+		select {
+		case <-done:
+			finished = true
+		default:
+		}
+		n := len(recorder.Operations())
+		if n < last {
+			t.Fatalf("number of recorded operations went backwards: %d -> %d", last, n)
+		}
+		last = n
+	}
+	if n := len(recorder.Operations()); n != 4*2000 {
+		t.Fatalf("expected %d operations, got %d", 4*2000, n)
+	}
+}
+
+func BenchmarkRecorderBeginEnd(b *testing.B) {
+	var input, output interface{} = kvInput{op: 1, key: "x", value: "y"}, kvOutput{}
+	var client *RecorderClient
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		// bound memory use for large b.N; the cost of fresh buffers is
+		// part of what's being measured
+		if i%(1<<16) == 0 {
+			client = NewRecorder(RecorderOptions{}).Client()
+		}
+		client.Begin(input)
+		client.End(output)
+	}
+}
+
+func BenchmarkRecorderBeginEndParallel(b *testing.B) {
+	var input, output interface{} = kvInput{op: 1, key: "x", value: "y"}, kvOutput{}
+	recorder := NewRecorder(RecorderOptions{})
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		var client *RecorderClient
+		for i := 0; pb.Next(); i++ {
+			if i%(1<<16) == 0 {
+				client = recorder.Client()
+			}
+			client.Begin(input)
+			client.End(output)
+		}
+	})
+}
+
+func BenchmarkRecorderBeginEndSampled(b *testing.B) {
+	var input, output interface{} = kvInput{op: 1, key: "x", value: "y"}, kvOutput{}
+	opts := RecorderOptions{SampleRate: 0.5, SampleKey: func(input interface{}) string {
+		return input.(kvInput).key
+	}}
+	var client *RecorderClient
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if i%(1<<16) == 0 {
+			client = NewRecorder(opts).Client()
+		}
+		client.Begin(input)
+		client.End(output)
+	}
+}
+
+// Recording should be cheap enough to leave on in stress tests: less than
+// 100ns each for Begin and End. Timing is meaningless under the race detector
+// or with coverage instrumentation, so this only runs without them, and it
+// takes the best of a few runs to ride out noise from other processes.
+func TestRecorderOverhead(t *testing.T) {
+	if testing.Short() || raceEnabled || testing.CoverMode() != "" {
+		t.Skip("skipping overhead measurement")
+	}
+	best := int64(-1)
+	for i := 0; i < 3; i++ {
+		perCall := testing.Benchmark(BenchmarkRecorderBeginEnd).NsPerOp() / 2
+		if best < 0 || perCall < best {
+			best = perCall
+		}
+	}
+	if best >= 100 {
+		t.Fatalf("expected Begin/End to take less than 100ns each, took %dns", best)
+	}
+	t.Logf("Begin/End take %dns each", best)
+}
+
+func TestRecorderInvoke(t *testing.T) {
+	kv := &lockedKv{data: make(map[string]string)}
+	put := func(key, value string) {
+		kv.apply(kvInput{op: 1, key: key, value: value})
+	}
+	get := func(key string) (string, error) {
+		return kv.apply(kvInput{op: 0, key: key}).value, nil
+	}
+	concat := func(sep string, values ...string) string {
+		return strings.Join(values, sep)
+	}
+	recorder := NewRecorder(RecorderOptions{})
+	client := recorder.Client()
+	client.Invoke("Put", put, "x", "y")
+	results := client.Invoke("Get", get, "x")
+	if results[0] != "y" || results[1] != nil {
+		t.Fatalf("unexpected results %v", results)
+	}
+	client.Invoke("Concat", concat, "-", "a", "b")
+
+	ops := recorder.Operations()
+	descriptions := make([]string, len(ops))
+	for i, op := range ops {
+		descriptions[i] = defaultDescribeOperation(op.Input, op.Output)
+	}
+	expected := []string{"Put(x, y) -> []", "Get(x) -> [y, <nil>]", "Concat(-, a, b) -> [a-b]"}
+	if !reflect.DeepEqual(expected, descriptions) {
+		t.Fatalf("expected %v, got %v", expected, descriptions)
+	}
+	if ops[1].Output.(MethodResult).Error() != nil {
+		t.Fatal("expected no error")
+	}
+}
+
+// a codec for kvInput and kvOutput, which have unexported fields
+var kvCodec = &FuncCodec{
+	MarshalInput: func(input interface{}) ([]byte, error) {
+		inp := input.(kvInput)
+		return json.Marshal([]interface{}{inp.op, inp.key, inp.value})
+	},
+	UnmarshalInput: func(data []byte) (interface{}, error) {
+		var inp kvInput
+		err := json.Unmarshal(data, &[]interface{}{&inp.op, &inp.key, &inp.value})
+		return inp, err
+	},
+	MarshalOutput: func(output interface{}) ([]byte, error) {
+		return json.Marshal(output.(kvOutput).value)
+	},
+	UnmarshalOutput: func(data []byte) (interface{}, error) {
+		var out kvOutput
+		err := json.Unmarshal(data, &out.value)
+		return out, err
+	},
+}
+
+func TestRecorderSpill(t *testing.T) {
+	f, err := os.CreateTemp("", "porcupine-*.jsonl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	recorder := NewRecorder(RecorderOptions{Spill: f, SpillCodec: kvCodec, SpillInterval: time.Millisecond})
+	runRecordedKv(recorder, 8, 500, 4)
+	if ops := recorder.Operations(); ops != nil {
+		t.Fatalf("expected a spilling recorder to hold no operations, got %d", len(ops))
+	}
+	if err := recorder.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	ops, err := ReadJSONLOperations(f, kvCodec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ops) != 8*500 {
+		t.Fatalf("expected %d operations, got %d", 8*500, len(ops))
+	}
+	res := CheckOperationsTimeout(kvModel, ops, 0)
+	if res != Ok {
+		t.Fatalf("expected output %v, got output %v", Ok, res)
+	}
+}
+
+func TestRecorderSpillFlush(t *testing.T) {
+	var buf bytes.Buffer
+	// a long interval, so that only Flush writes
+	recorder := NewRecorder(RecorderOptions{Spill: &buf, SpillInterval: time.Hour})
+	client := recorder.Client()
+	client.Begin("x")
+	client.End(1)
+	if err := recorder.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	ops, err := ReadJSONLOperations(bytes.NewReader(buf.Bytes()), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ops) != 1 || ops[0].Input != "x" || ops[0].Output != int64(1) {
+		t.Fatalf("unexpected operations %+v", ops)
+	}
+	client.Begin("y")
+	client.End(2)
+	if err := recorder.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := recorder.Close(); err != nil {
+		t.Fatal(err)
+	}
+	client.Begin("z")
+	client.End(3)
+	ops, err = ReadJSONLOperations(bytes.NewReader(buf.Bytes()), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ops) != 2 || ops[1].Input != "y" {
+		t.Fatalf("unexpected operations %+v", ops)
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestRecorderSpillError(t *testing.T) {
+	recorder := NewRecorder(RecorderOptions{Spill: failingWriter{}})
+	client := recorder.Client()
+	client.Begin("x")
+	client.End(1)
+	if err := recorder.Close(); err == nil || err.Error() != "disk full" {
+		t.Fatalf("expected the write error, got %v", err)
+	}
+}
+
+func TestJSONLReader(t *testing.T) {
+	lines := `{"client": 0, "input": [1, "x", "a"], "call": 0, "output": "", "return": 10}
+{"client": 1, "input": [0, "x", ""], "call": 5, "output": "a", "return": 15}
+`
+	reader := NewJSONLReader(strings.NewReader(lines), kvCodec)
+	op, err := reader.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if op.Input != (kvInput{op: 1, key: "x", value: "a"}) || op.Return != 10 {
+		t.Fatalf("unexpected operation %+v", op)
+	}
+	if _, err := reader.Next(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reader.Next(); err != io.EOF {
+		t.Fatalf("expected io.EOF, got %v", err)
+	}
+
+	_, err = ReadJSONLOperations(strings.NewReader(lines+"{\"input\": 7}\n"), kvCodec)
+	if err == nil || !strings.HasPrefix(err.Error(), "operation 2.input: ") {
+		t.Fatalf("expected a decoding error for operation 2, got %v", err)
+	}
+}
+
+func TestRestoreRecorder(t *testing.T) {
+	recorder := NewRecorder(RecorderOptions{})
+	runRecordedKv(recorder, 4, 50, 2)
+	saved := recorder.Operations()
+	latest := saved[len(saved)-1].Return
+
+	restored := RestoreRecorder(saved, RecorderOptions{})
+	if ops := restored.Operations(); !reflect.DeepEqual(saved, ops) {
+		t.Fatal("expected restored recorder to start with the saved history")
+	}
+	// resuming a client keeps its ID, and new clients come after the
+	// restored ones
+	resumed := restored.ResumeClient(2)
+	if resumed.Id() != 2 {
+		t.Fatalf("expected resumed client to have ID 2, got %d", resumed.Id())
+	}
+	if id := restored.Client().Id(); id != 4 {
+		t.Fatalf("expected new client to have ID 4, got %d", id)
+	}
+	resumed.Begin(kvInput{op: 1, key: "k0", value: "resumed"})
+	resumed.End(kvOutput{})
+	ops := restored.Operations()
+	if len(ops) != len(saved)+1 {
+		t.Fatalf("expected %d operations, got %d", len(saved)+1, len(ops))
+	}
+	last := ops[len(ops)-1]
+	if last.ClientId != 2 || last.Call <= latest {
+		t.Fatalf("expected new operation from client 2 after the saved history, got %+v", last)
+	}
+	if res := CheckOperationsTimeout(kvModel, ops, 0); res != Ok {
+		t.Fatalf("expected output %v, got output %v", Ok, res)
+	}
+
+	for _, id := range []int{2, 4, 7} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("expected resuming client %d to panic", id)
+				}
+			}()
+			restored.ResumeClient(id)
+		}()
+	}
+}
+
+func TestRestoreRecorderJSONL(t *testing.T) {
+	var saved bytes.Buffer
+	recorder := NewRecorder(RecorderOptions{Spill: &saved, SpillCodec: kvCodec})
+	runRecordedKv(recorder, 4, 50, 2)
+	if err := recorder.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// resume, spilling the complete history to a new file
+	var resumed bytes.Buffer
+	restored, err := RestoreRecorderJSONL(&saved, kvCodec, RecorderOptions{Spill: &resumed, SpillCodec: kvCodec})
+	if err != nil {
+		t.Fatal(err)
+	}
+	runRecordedKv(restored, 2, 50, 2)
+	if err := restored.Close(); err != nil {
+		t.Fatal(err)
+	}
+	ops, err := ReadJSONLOperations(&resumed, kvCodec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ops) != 6*50 {
+		t.Fatalf("expected %d operations, got %d", 6*50, len(ops))
+	}
+	clients := make(map[int]int)
+	for _, op := range ops {
+		clients[op.ClientId]++
+	}
+	if len(clients) != 6 {
+		t.Fatalf("expected 6 distinct clients, got %v", clients)
+	}
+}
@@ -0,0 +1,129 @@
+package porcupine
+
+import (
+	"fmt"
+	"sort"
+)
+
+// A RegisterSemantics is a consistency condition on a register, weaker than
+// the atomicity that a linearizability check of a register model checks.
+type RegisterSemantics string
+
+const (
+	// Lamport's safe register: a read that is not concurrent with any
+	// write returns the value of the latest write that precedes it, and a
+	// read that is concurrent with a write can return anything.
+	SafeRegister RegisterSemantics = "Safe"
+	// Lamport's regular register: a read returns the value of the latest
+	// write that precedes it, or of a write that is concurrent with it.
+	RegularRegister RegisterSemantics = "Regular"
+)
+
+// A RegisterViolation is a read that violates a register's semantics, found
+// by [CheckRegisterSemantics].
+type RegisterViolation struct {
+	Operation   int // the index in the history of the read
+	Description string
+}
+
+// CheckRegisterSemantics checks whether the reads in a history satisfy the
+// given register semantics, where access says how each operation accesses
+// the registers, one per key, and returns false for operations that neither
+// read nor write them; initial is the value of every register before the
+// first write. Values must be comparable. Quorum systems often provide these
+// semantics without providing atomic registers, which a linearizability
+// check requires.
+//
+// With several writers, writes can be concurrent, so there can be more than
+// one latest write that precedes a read: a write precedes a read if it
+// finishes before the read starts, and it is one of the latest if no other
+// write that precedes the read starts after it finishes. The check takes
+// O(n log n) time, and violations are in the order of the history.
+func CheckRegisterSemantics(history []Operation, access func(op Operation) (KeyAccess, bool), initial interface{}, semantics RegisterSemantics) []RegisterViolation {
+	type register struct {
+		writes []int // sorted by call time
+		// the earliest return of the writes from each index on
+		minReturn []int64
+		// the latest return of the writes up to each index
+		maxReturn []int64
+		byValue   map[interface{}][]int
+	}
+	registers := make(map[string]*register)
+	var reads []int
+	for i, op := range history {
+		a, ok := access(op)
+		if !ok {
+			continue
+		}
+		if !a.Write {
+			reads = append(reads, i)
+			continue
+		}
+		r := registers[a.Key]
+		if r == nil {
+			r = &register{byValue: make(map[interface{}][]int)}
+			registers[a.Key] = r
+		}
+		r.writes = append(r.writes, i)
+		r.byValue[a.Value] = append(r.byValue[a.Value], i)
+	}
+	for _, r := range registers {
+		sort.SliceStable(r.writes, func(a, b int) bool {
+			return history[r.writes[a]].Call < history[r.writes[b]].Call
+		})
+		n := len(r.writes)
+		r.minReturn = make([]int64, n)
+		r.maxReturn = make([]int64, n)
+		for j := n - 1; j >= 0; j-- {
+			r.minReturn[j] = history[r.writes[j]].Return
+			if j+1 < n && r.minReturn[j+1] < r.minReturn[j] {
+				r.minReturn[j] = r.minReturn[j+1]
+			}
+		}
+		for j, w := range r.writes {
+			r.maxReturn[j] = history[w].Return
+			if j > 0 && r.maxReturn[j-1] > r.maxReturn[j] {
+				r.maxReturn[j] = r.maxReturn[j-1]
+			}
+		}
+	}
+	// overwritten reports whether some write starts after the given time
+	// and finishes before the read starts
+	overwritten := func(r *register, after int64, read Operation) bool {
+		j := sort.Search(len(r.writes), func(j int) bool {
+			return history[r.writes[j]].Call > after
+		})
+		return j < len(r.writes) && r.minReturn[j] < read.Call
+	}
+	var violations []RegisterViolation
+	for _, i := range reads {
+		read := history[i]
+		a, _ := access(read)
+		r := registers[a.Key]
+		if r == nil {
+			r = &register{}
+		}
+		// the writes that start before the read finishes
+		started := sort.Search(len(r.writes), func(j int) bool {
+			return history[r.writes[j]].Call > read.Return
+		})
+		if semantics == SafeRegister && started > 0 && r.maxReturn[started-1] >= read.Call {
+			// concurrent with a write, so the read can return anything
+			continue
+		}
+		valid := a.Value == initial && !overwritten(r, -1<<63, read)
+		for _, w := range r.byValue[a.Value] {
+			if valid {
+				break
+			}
+			valid = history[w].Call <= read.Return && !overwritten(r, history[w].Return, read)
+		}
+		if !valid {
+			violations = append(violations, RegisterViolation{
+				Operation:   i,
+				Description: fmt.Sprintf("read %v from register %q, which is not the value of a latest preceding or concurrent write", a.Value, a.Key),
+			})
+		}
+	}
+	return violations
+}
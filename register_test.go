@@ -0,0 +1,46 @@
+package porcupine
+
+import "testing"
+
+func TestCheckRegisterSemantics(t *testing.T) {
+	history := []Operation{
+		{ClientId: 0, Input: registerInput{false, 1}, Call: 0, Output: 0, Return: 10},
+		{ClientId: 1, Input: registerInput{true, 0}, Call: 20, Output: 1, Return: 30},
+		{ClientId: 0, Input: registerInput{false, 2}, Call: 40, Output: 0, Return: 60},
+		// concurrent with the write of 2, returning the old value
+		{ClientId: 1, Input: registerInput{true, 0}, Call: 50, Output: 1, Return: 55},
+		// concurrent with the write of 2, returning a value never written
+		{ClientId: 2, Input: registerInput{true, 0}, Call: 45, Output: 7, Return: 50},
+		// after the write of 2, returning the old value
+		{ClientId: 1, Input: registerInput{true, 0}, Call: 70, Output: 1, Return: 80},
+		// after the write of 1, returning the initial value
+		{ClientId: 3, Input: registerInput{true, 0}, Call: 20, Output: 0, Return: 30},
+		// concurrent with the write of 1, returning the initial value
+		{ClientId: 4, Input: registerInput{true, 0}, Call: 5, Output: 0, Return: 8},
+	}
+	for _, tc := range []struct {
+		semantics RegisterSemantics
+		expected  []int
+	}{
+		{RegularRegister, []int{4, 5, 6}},
+		{SafeRegister, []int{5, 6}},
+	} {
+		violations := CheckRegisterSemantics(history, registerAccess, 0, tc.semantics)
+		if len(violations) != len(tc.expected) {
+			t.Fatalf("%s: expected violations %v, got %+v", tc.semantics, tc.expected, violations)
+		}
+		for i, v := range violations {
+			if v.Operation != tc.expected[i] || v.Description == "" {
+				t.Fatalf("%s: expected violations %v, got %+v", tc.semantics, tc.expected, violations)
+			}
+		}
+	}
+}
+
+func TestCheckRegisterSemanticsLinearizable(t *testing.T) {
+	// an atomic register is regular
+	history := registerHistory(400)
+	if violations := CheckRegisterSemantics(history, registerAccess, 0, RegularRegister); len(violations) != 0 {
+		t.Fatalf("expected no violations, got %+v", violations)
+	}
+}
@@ -0,0 +1,144 @@
+package porcupine
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+	"time"
+)
+
+// A Report summarizes a linearizability check in a form meant for machines,
+// e.g., CI pipelines and dashboards; see [WriteJSONReport].
+type Report struct {
+	Result CheckResult `json:"result"`
+	// Wall-clock time the check took, in nanoseconds in JSON.
+	Elapsed    time.Duration     `json:"elapsed"`
+	History    HistoryStats      `json:"history"`
+	Partitions []PartitionReport `json:"partitions"`
+}
+
+// HistoryStats describes the history that was checked. Times are in the
+// history's units.
+type HistoryStats struct {
+	Operations int `json:"operations"`
+	Clients    int `json:"clients"`
+	Partitions int `json:"partitions"`
+	// Earliest call and latest return.
+	Start int64 `json:"start"`
+	End   int64 `json:"end"`
+	// Greatest number of operations in progress at once.
+	MaxConcurrency int     `json:"maxConcurrency"`
+	MeanLatency    float64 `json:"meanLatency"`
+	MaxLatency     int64   `json:"maxLatency"`
+}
+
+// A PartitionReport describes the check of a single partition of a history.
+type PartitionReport struct {
+	Operations int `json:"operations"`
+	// Ok if the partition was fully linearized; otherwise Illegal, or
+	// Unknown if the check did not run to completion.
+	Result CheckResult `json:"result"`
+	// Lengths of the partial linearizations found, longest first; see
+	// [LinearizationInfo.PartialLinearizations].
+	PartialLinearizations []int `json:"partialLinearizations"`
+}
+
+// NewReport builds a report for a check that took the given time. The
+// partitions and history statistics come from the LinearizationInfo, so they
+// are only filled in if it comes from one of the verbose check functions,
+// e.g., [CheckOperationsVerbose].
+func NewReport(result CheckResult, info LinearizationInfo, elapsed time.Duration) Report {
+	report := Report{
+		Result:     result,
+		Elapsed:    elapsed,
+		Partitions: make([]PartitionReport, len(info.history)),
+	}
+	type point struct {
+		time  int64
+		delta int
+	}
+	var points []point
+	clients := make(map[int]bool)
+	calls := make(map[int]int64)
+	var totalLatency int64
+	stats := &report.History
+	stats.Partitions = len(info.history)
+	for p, history := range info.history {
+		partition := &report.Partitions[p]
+		partition.Operations = len(history) / 2
+		partition.PartialLinearizations = make([]int, len(info.partialLinearizations[p]))
+		for i, partial := range info.partialLinearizations[p] {
+			partition.PartialLinearizations[i] = len(partial)
+		}
+		sort.Sort(sort.Reverse(sort.IntSlice(partition.PartialLinearizations)))
+		switch {
+		case len(partition.PartialLinearizations) > 0 && partition.PartialLinearizations[0] == partition.Operations:
+			partition.Result = Ok
+		case result == Unknown || (info.timedOut != nil && info.timedOut[p]):
+			partition.Result = Unknown
+		default:
+			partition.Result = Illegal
+		}
+
+		for _, e := range history {
+			if len(points) == 0 || e.time < stats.Start {
+				stats.Start = e.time
+			}
+			if len(points) == 0 || e.time > stats.End {
+				stats.End = e.time
+			}
+			clients[e.clientId] = true
+			if e.kind == callEntry {
+				calls[e.id] = e.time
+				points = append(points, point{e.time, 1})
+			} else {
+				stats.Operations++
+				latency := e.time - calls[e.id]
+				totalLatency += latency
+				if latency > stats.MaxLatency {
+					stats.MaxLatency = latency
+				}
+				points = append(points, point{e.time, -1})
+			}
+		}
+		// IDs are only unique within a partition
+		calls = make(map[int]int64)
+	}
+	stats.Clients = len(clients)
+	if stats.Operations > 0 {
+		stats.MeanLatency = float64(totalLatency) / float64(stats.Operations)
+	}
+	// an operation that returns at the same time as another is called doesn't
+	// overlap with it
+	sort.Slice(points, func(i, j int) bool {
+		if points[i].time != points[j].time {
+			return points[i].time < points[j].time
+		}
+		return points[i].delta < points[j].delta
+	})
+	concurrency := 0
+	for _, pt := range points {
+		concurrency += pt.delta
+		if concurrency > stats.MaxConcurrency {
+			stats.MaxConcurrency = concurrency
+		}
+	}
+	return report
+}
+
+// WriteJSONReport writes a report as JSON, for example:
+//
+//	{
+//	  "result": "Illegal",
+//	  "elapsed": 1520000,
+//	  "history": {"operations": 3, "clients": 3, "partitions": 1, "start": 0,
+//	    "end": 100, "maxConcurrency": 2, "meanLatency": 40, "maxLatency": 100},
+//	  "partitions": [
+//	    {"operations": 3, "result": "Illegal", "partialLinearizations": [2]}
+//	  ]
+//	}
+func WriteJSONReport(w io.Writer, report Report) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
@@ -0,0 +1,76 @@
+package porcupine
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestReport(t *testing.T) {
+	ops := []Operation{
+		{0, registerInput{false, 1}, 0, 0, 100},
+		{1, registerInput{true, 0}, 10, 1, 20},
+		{2, registerInput{true, 0}, 30, 2, 40},
+	}
+	res, info := CheckOperationsVerbose(registerModel, ops, 0)
+	report := NewReport(res, info, time.Second)
+	expected := Report{
+		Result:  Illegal,
+		Elapsed: time.Second,
+		History: HistoryStats{
+			Operations:     3,
+			Clients:        3,
+			Partitions:     1,
+			Start:          0,
+			End:            100,
+			MaxConcurrency: 2,
+			MeanLatency:    40,
+			MaxLatency:     100,
+		},
+		Partitions: []PartitionReport{{Operations: 3, Result: Illegal, PartialLinearizations: []int{2}}},
+	}
+	if !reflect.DeepEqual(expected, report) {
+		t.Fatalf("expected %+v, got %+v", expected, report)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteJSONReport(&buf, report); err != nil {
+		t.Fatal(err)
+	}
+	var decoded Report
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(report, decoded) {
+		t.Fatalf("expected %+v, got %+v", report, decoded)
+	}
+}
+
+func TestReportPartitions(t *testing.T) {
+	ops := []Operation{
+		{0, kvInput{op: 1, key: "x", value: "a"}, 0, kvOutput{}, 10},
+		{1, kvInput{op: 0, key: "x"}, 10, kvOutput{"a"}, 20},
+		{0, kvInput{op: 1, key: "y", value: "b"}, 20, kvOutput{}, 30},
+	}
+	res, info := CheckOperationsVerbose(kvModel, ops, 0)
+	report := NewReport(res, info, 0)
+	if report.Result != Ok || len(report.Partitions) != 2 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	for _, p := range report.Partitions {
+		if p.Result != Ok || p.PartialLinearizations[0] != p.Operations {
+			t.Fatalf("unexpected partition report %+v", p)
+		}
+	}
+	if report.History.MaxConcurrency != 1 || report.History.Clients != 2 {
+		t.Fatalf("unexpected history stats %+v", report.History)
+	}
+
+	// a non-verbose check only gives the result
+	report = NewReport(Ok, LinearizationInfo{}, time.Millisecond)
+	if report.History.Operations != 0 || len(report.Partitions) != 0 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+}
@@ -0,0 +1,302 @@
+package porcupine
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/format"
+	"io"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ReproducerOptions configures [WriteReproducer].
+type ReproducerOptions struct {
+	// The name of the package that the test file is in, e.g., "kv", which
+	// must define the model, and which the types of the history's inputs
+	// and outputs must be in, or be importable from.
+	Package string
+	// A Go expression for the model in that package, e.g., "kvModel".
+	Model string
+	// The test's name; "TestLinearizabilityReproducer" if empty.
+	TestName string
+	// Shrink, if set, cuts the history down to the operations of the
+	// partition whose failing window ends first (see [FindFailingWindow]),
+	// up to the end of that window, which fails to linearize just like the
+	// whole history, since the windows before it are checked the same way.
+	Shrink bool
+}
+
+// WriteReproducer writes a self-contained Go test file that checks a history,
+// e.g., one on which a check failed in a nightly run, so that the failure can
+// be reproduced with "go test", and kept as a regression test. The history is
+// embedded as a Go literal, and the test fails, with the [Explain] output, as
+// long as the check fails on it; e.g., once the model is fixed, if the model
+// had a bug, the test passes.
+//
+// Inputs and outputs are written as Go literals, so they can be made up of
+// booleans, numbers, strings, and slices, arrays, maps, and structs of them,
+// along with pointers to structs. Fields of structs from other packages must
+// be exported. Values that can't be written, e.g., functions, make it return
+// an error. The model's code isn't written: the test refers to it by name, as
+// given in the options.
+func WriteReproducer(w io.Writer, model Model, history []Operation, opts ReproducerOptions) error {
+	if opts.Package == "" || opts.Model == "" {
+		return errors.New("porcupine: a reproducer needs a package and a model")
+	}
+	testName := opts.TestName
+	if testName == "" {
+		testName = "TestLinearizabilityReproducer"
+	}
+	if opts.Shrink {
+		history = shrinkToFailingWindow(fillDefault(model), history)
+	}
+	// the test's own package is used without a qualifier
+	qualifier := "porcupine."
+	if opts.Package == "porcupine" {
+		qualifier = ""
+	}
+	lw := literalWriter{pkg: opts.Package, imports: make(map[string]bool)}
+	var ops bytes.Buffer
+	for _, op := range history {
+		input, err := lw.literal(reflect.ValueOf(op.Input))
+		if err != nil {
+			return err
+		}
+		output, err := lw.literal(reflect.ValueOf(op.Output))
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&ops, "\t\t{ClientId: %d, Input: %s, Call: %d, Output: %s, Return: %d},\n", op.ClientId, input, op.Call, output, op.Return)
+	}
+	if qualifier != "" {
+		lw.imports[porcupineImportPath] = true
+	}
+	// the standard library's packages go first, as goimports has them
+	std := []string{"testing"}
+	var others []string
+	for path := range lw.imports {
+		if strings.Contains(strings.SplitN(path, "/", 2)[0], ".") {
+			others = append(others, path)
+		} else {
+			std = append(std, path)
+		}
+	}
+	sort.Strings(std)
+	sort.Strings(others)
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Code generated by porcupine.WriteReproducer. DO NOT EDIT.\n\npackage %s\n\nimport (\n", opts.Package)
+	for _, path := range std {
+		fmt.Fprintf(&b, "\t%q\n", path)
+	}
+	if len(others) > 0 {
+		b.WriteString("\n")
+	}
+	for _, path := range others {
+		fmt.Fprintf(&b, "\t%q\n", path)
+	}
+	b.WriteString(")\n\n")
+	fmt.Fprintf(&b, "func %s(t *testing.T) {\n", testName)
+	fmt.Fprintf(&b, "\thistory := []%sOperation{\n", qualifier)
+	b.Write(ops.Bytes())
+	b.WriteString("\t}\n")
+	fmt.Fprintf(&b, "\tres, info := %sCheckOperationsVerbose(%s, history, 0)\n", qualifier, opts.Model)
+	fmt.Fprintf(&b, "\tif res != %sOk {\n", qualifier)
+	fmt.Fprintf(&b, "\t\tt.Fatalf(\"history is not linearizable (%%v):\\n%%s\", res, %sExplain(%s, info))\n", qualifier, opts.Model)
+	b.WriteString("\t}\n}\n")
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		return fmt.Errorf("porcupine: generated invalid Go: %v", err)
+	}
+	_, err = w.Write(src)
+	return err
+}
+
+const porcupineImportPath = "github.com/anishathalye/porcupine"
+
+// shrinkToFailingWindow returns the operations of the partition of a history
+// whose failing window ends first, up to the end of the window, or the whole
+// history if it has no failing window.
+func shrinkToFailingWindow(model Model, history []Operation) []Operation {
+	res, window := FindFailingWindow(model, history, 0)
+	if res != Illegal {
+		return history
+	}
+	for _, partition := range model.Partition(history) {
+		inWindow := false
+		for _, op := range partition {
+			if reflect.DeepEqual(op, window.Operations[0]) {
+				inWindow = true
+				break
+			}
+		}
+		if !inWindow {
+			continue
+		}
+		var shrunk []Operation
+		for _, op := range partition {
+			if op.Return <= window.End {
+				shrunk = append(shrunk, op)
+			}
+		}
+		return shrunk
+	}
+	return history
+}
+
+// A literalWriter writes values as Go literals for a file in package pkg,
+// collecting the import paths of the other packages that they refer to.
+type literalWriter struct {
+	pkg     string
+	imports map[string]bool
+}
+
+// typeName returns the name of a type in Go source.
+func (lw literalWriter) typeName(t reflect.Type) (string, error) {
+	if t.Name() != "" {
+		if t.PkgPath() == "" {
+			return t.Name(), nil
+		}
+		name := t.String()
+		qualifier := name[:strings.IndexByte(name, '.')]
+		if qualifier == lw.pkg {
+			return t.Name(), nil
+		}
+		lw.imports[t.PkgPath()] = true
+		return name, nil
+	}
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Ptr:
+		elem, err := lw.typeName(t.Elem())
+		if err != nil {
+			return "", err
+		}
+		switch t.Kind() {
+		case reflect.Slice:
+			return "[]" + elem, nil
+		case reflect.Array:
+			return fmt.Sprintf("[%d]%s", t.Len(), elem), nil
+		}
+		return "*" + elem, nil
+	case reflect.Map:
+		key, err := lw.typeName(t.Key())
+		if err != nil {
+			return "", err
+		}
+		elem, err := lw.typeName(t.Elem())
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("map[%s]%s", key, elem), nil
+	case reflect.Interface:
+		if t.NumMethod() == 0 {
+			return "interface{}", nil
+		}
+	}
+	return "", fmt.Errorf("porcupine: can't write a Go literal of type %s", t)
+}
+
+// literal returns a Go expression for a value, which has the value's type
+// even where it's assigned to an interface{}.
+func (lw literalWriter) literal(v reflect.Value) (string, error) {
+	if !v.IsValid() || (v.Kind() == reflect.Interface && v.IsNil()) {
+		return "nil", nil
+	}
+	if v.Kind() == reflect.Interface {
+		return lw.literal(v.Elem())
+	}
+	t := v.Type()
+	name, err := lw.typeName(t)
+	if err != nil {
+		return "", err
+	}
+	// constants of these types need no conversion
+	plain := t.PkgPath() == "" && (t.Kind() == reflect.Bool || t.Kind() == reflect.Int || t.Kind() == reflect.String)
+	convert := func(s string) string {
+		if plain {
+			return s
+		}
+		return name + "(" + s + ")"
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return convert(strconv.FormatBool(v.Bool())), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return convert(strconv.FormatInt(v.Int(), 10)), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return convert(strconv.FormatUint(v.Uint(), 10)), nil
+	case reflect.Float32, reflect.Float64:
+		f := v.Float()
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return "", fmt.Errorf("porcupine: can't write a Go literal for %v", f)
+		}
+		return convert(strconv.FormatFloat(f, 'g', -1, t.Bits())), nil
+	case reflect.String:
+		return convert(strconv.Quote(v.String())), nil
+	case reflect.Ptr:
+		if v.IsNil() {
+			return "(" + name + ")(nil)", nil
+		}
+		if t.Elem().Kind() != reflect.Struct {
+			return "", fmt.Errorf("porcupine: can't write a Go literal of type %s", t)
+		}
+		s, err := lw.literal(v.Elem())
+		if err != nil {
+			return "", err
+		}
+		return "&" + s, nil
+	case reflect.Slice, reflect.Array:
+		if t.Kind() == reflect.Slice && v.IsNil() {
+			return name + "(nil)", nil
+		}
+		elems := make([]string, v.Len())
+		for i := range elems {
+			if elems[i], err = lw.literal(v.Index(i)); err != nil {
+				return "", err
+			}
+		}
+		return name + "{" + strings.Join(elems, ", ") + "}", nil
+	case reflect.Map:
+		if v.IsNil() {
+			return name + "(nil)", nil
+		}
+		var entries []string
+		iter := v.MapRange()
+		for iter.Next() {
+			key, err := lw.literal(iter.Key())
+			if err != nil {
+				return "", err
+			}
+			value, err := lw.literal(iter.Value())
+			if err != nil {
+				return "", err
+			}
+			entries = append(entries, key+": "+value)
+		}
+		sort.Strings(entries)
+		return name + "{" + strings.Join(entries, ", ") + "}", nil
+	case reflect.Struct:
+		local := name == t.Name() // not qualified by another package
+		var fields []string
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.PkgPath != "" && !local {
+				return "", fmt.Errorf("porcupine: can't write a Go literal of type %s, which has unexported fields", t)
+			}
+			if v.Field(i).IsZero() {
+				continue
+			}
+			s, err := lw.literal(v.Field(i))
+			if err != nil {
+				return "", err
+			}
+			fields = append(fields, f.Name+": "+s)
+		}
+		return name + "{" + strings.Join(fields, ", ") + "}", nil
+	}
+	return "", fmt.Errorf("porcupine: can't write a Go literal of type %s", t)
+}
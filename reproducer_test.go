@@ -0,0 +1,76 @@
+package porcupine
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteReproducer(t *testing.T) {
+	ops := []Operation{
+		{0, kvInput{op: 1, key: "x", value: "a"}, 0, kvOutput{}, 10},
+		{1, kvInput{op: 0, key: "x"}, 20, kvOutput{"b"}, 30},
+		{1, kvInput{op: 0, key: "x"}, 40, kvOutput{"b"}, 50},
+		{2, kvInput{op: 1, key: "y", value: "c"}, 0, kvOutput{}, 10},
+		{2, kvInput{op: 0, key: "y"}, 20, kvOutput{"c"}, 30},
+	}
+	var out strings.Builder
+	opts := ReproducerOptions{Package: "porcupine", Model: "kvModel", Shrink: true}
+	if err := WriteReproducer(&out, kvModel, ops, opts); err != nil {
+		t.Fatal(err)
+	}
+	expected := `// Code generated by porcupine.WriteReproducer. DO NOT EDIT.
+
+package porcupine
+
+import (
+	"testing"
+)
+
+func TestLinearizabilityReproducer(t *testing.T) {
+	history := []Operation{
+		{ClientId: 0, Input: kvInput{op: uint8(1), key: "x", value: "a"}, Call: 0, Output: kvOutput{}, Return: 10},
+		{ClientId: 1, Input: kvInput{key: "x"}, Call: 20, Output: kvOutput{value: "b"}, Return: 30},
+	}
+	res, info := CheckOperationsVerbose(kvModel, history, 0)
+	if res != Ok {
+		t.Fatalf("history is not linearizable (%v):\n%s", res, Explain(kvModel, info))
+	}
+}
+`
+	if out.String() != expected {
+		t.Fatalf("expected reproducer\n%s\ngot\n%s", expected, out.String())
+	}
+}
+
+func TestWriteReproducerOtherPackage(t *testing.T) {
+	ops := []Operation{
+		{0, MethodCall{"Put", []interface{}{"x", int64(1), nil, 1.5}}, 0, MethodResult{[]interface{}{nil}}, 10},
+		{1, map[string]uint{"b": 2, "a": 1}, 0, []*MethodCall{nil, {Method: "Get"}}, 10},
+	}
+	var out strings.Builder
+	if err := WriteReproducer(&out, Model{}, ops, ReproducerOptions{Package: "kv", Model: "kv.Model", TestName: "TestNightly"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		"package kv\n\nimport (\n\t\"testing\"\n\n\t\"github.com/anishathalye/porcupine\"\n)",
+		"func TestNightly(t *testing.T) {",
+		`Input: porcupine.MethodCall{Method: "Put", Args: []interface{}{"x", int64(1), nil, float64(1.5)}}`,
+		`Output: porcupine.MethodResult{Results: []interface{}{nil}}`,
+		`Input: map[string]uint{"a": uint(1), "b": uint(2)}`,
+		`Output: []*porcupine.MethodCall{(*porcupine.MethodCall)(nil), &porcupine.MethodCall{Method: "Get"}}`,
+		"porcupine.CheckOperationsVerbose(kv.Model, history, 0)",
+	} {
+		if !strings.Contains(out.String(), expected) {
+			t.Fatalf("expected reproducer to contain %q, got\n%s", expected, out.String())
+		}
+	}
+
+	// values that have no literal
+	for _, input := range []interface{}{func() {}, time.Time{}, make(chan int)} {
+		ops := []Operation{{Input: input}}
+		if err := WriteReproducer(&out, Model{}, ops, ReproducerOptions{Package: "kv", Model: "m"}); err == nil {
+			t.Fatalf("expected an error for input %#v", input)
+		}
+	}
+}
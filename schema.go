@@ -0,0 +1,285 @@
+package porcupine
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// A Schema describes the JSON encoding of operation inputs or outputs, so
+// that histories produced by non-Go programs can be decoded into dynamic
+// values and checked against models written over those values.
+//
+// Schemas are written in a subset of JSON Schema: the "type" (a single type
+// or a list of types), "properties", "required", "additionalProperties" (as a
+// boolean), "items", "enum", "const", "anyOf", and "oneOf" keywords are
+// supported, and other keywords are ignored. Decoded values are dynamic:
+//
+//   - "object" decodes to map[string]interface{}
+//   - "array" decodes to []interface{}
+//   - "integer" decodes to int64
+//   - "number" decodes to float64
+//   - "string", "boolean", and "null" decode to string, bool, and nil
+//
+// Maps and slices can't be compared with ==, so models over dynamic values
+// should compare states with [DynamicEqual] (or reflect.DeepEqual).
+type Schema struct {
+	Types                []string           // allowed types; empty means any
+	Properties           map[string]*Schema // for objects
+	Required             []string           // for objects
+	AdditionalProperties bool               // for objects; whether properties not in Properties are allowed
+	Items                *Schema            // for arrays
+	Enum                 []interface{}      // allowed values, if non-empty
+	AnyOf                []*Schema          // the value must match one of these, if non-empty
+}
+
+// ParseSchema parses a JSON Schema document.
+func ParseSchema(data []byte) (*Schema, error) {
+	var raw interface{}
+	if err := decodeJSONNumbers(data, &raw); err != nil {
+		return nil, err
+	}
+	return parseSchema(raw, "#")
+}
+
+func parseSchema(raw interface{}, path string) (*Schema, error) {
+	if b, ok := raw.(bool); ok {
+		// true accepts anything; false accepts nothing
+		if b {
+			return &Schema{AdditionalProperties: true}, nil
+		}
+		return &Schema{AnyOf: []*Schema{}}, nil
+	}
+	obj, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("schema %s: expected an object", path)
+	}
+	s := &Schema{AdditionalProperties: true}
+	switch t := obj["type"].(type) {
+	case nil:
+	case string:
+		s.Types = []string{t}
+	case []interface{}:
+		for _, elem := range t {
+			name, ok := elem.(string)
+			if !ok {
+				return nil, fmt.Errorf("schema %s: invalid type %v", path, elem)
+			}
+			s.Types = append(s.Types, name)
+		}
+	default:
+		return nil, fmt.Errorf("schema %s: invalid type %v", path, t)
+	}
+	for _, t := range s.Types {
+		switch t {
+		case "object", "array", "string", "integer", "number", "boolean", "null":
+		default:
+			return nil, fmt.Errorf("schema %s: unknown type %q", path, t)
+		}
+	}
+	if props, ok := obj["properties"].(map[string]interface{}); ok {
+		s.Properties = make(map[string]*Schema)
+		for name, sub := range props {
+			ps, err := parseSchema(sub, path+"/properties/"+name)
+			if err != nil {
+				return nil, err
+			}
+			s.Properties[name] = ps
+		}
+	}
+	if req, ok := obj["required"].([]interface{}); ok {
+		for _, elem := range req {
+			name, ok := elem.(string)
+			if !ok {
+				return nil, fmt.Errorf("schema %s: invalid required property %v", path, elem)
+			}
+			s.Required = append(s.Required, name)
+		}
+	}
+	if additional, ok := obj["additionalProperties"].(bool); ok {
+		s.AdditionalProperties = additional
+	}
+	if items, ok := obj["items"]; ok {
+		is, err := parseSchema(items, path+"/items")
+		if err != nil {
+			return nil, err
+		}
+		s.Items = is
+	}
+	if enum, ok := obj["enum"].([]interface{}); ok {
+		for _, v := range enum {
+			s.Enum = append(s.Enum, normalizeJSON(v))
+		}
+	}
+	if c, ok := obj["const"]; ok {
+		s.Enum = []interface{}{normalizeJSON(c)}
+	}
+	for _, key := range []string{"anyOf", "oneOf"} {
+		if alts, ok := obj[key].([]interface{}); ok {
+			for i, alt := range alts {
+				as, err := parseSchema(alt, fmt.Sprintf("%s/%s/%d", path, key, i))
+				if err != nil {
+					return nil, err
+				}
+				s.AnyOf = append(s.AnyOf, as)
+			}
+		}
+	}
+	return s, nil
+}
+
+// Decode decodes and validates a JSON value according to the schema.
+func (s *Schema) Decode(data []byte) (interface{}, error) {
+	var raw interface{}
+	if err := decodeJSONNumbers(data, &raw); err != nil {
+		return nil, err
+	}
+	return s.convert(raw, "$")
+}
+
+// convert validates a value decoded with json.Number for numbers, and
+// converts it to its dynamic representation.
+func (s *Schema) convert(raw interface{}, path string) (interface{}, error) {
+	if s.AnyOf != nil {
+		for _, alt := range s.AnyOf {
+			if v, err := alt.convert(raw, path); err == nil {
+				return s.checkEnum(v, path)
+			}
+		}
+		return nil, fmt.Errorf("%s: value does not match any alternative", path)
+	}
+	var v interface{}
+	var err error
+	switch x := raw.(type) {
+	case nil:
+		err = s.allow("null", path)
+	case bool:
+		v, err = x, s.allow("boolean", path)
+	case string:
+		v, err = x, s.allow("string", path)
+	case json.Number:
+		v, err = s.convertNumber(x, path)
+	case []interface{}:
+		v, err = s.convertArray(x, path)
+	case map[string]interface{}:
+		v, err = s.convertObject(x, path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.checkEnum(v, path)
+}
+
+func (s *Schema) allows(t string) bool {
+	if len(s.Types) == 0 {
+		return true
+	}
+	return containsString(s.Types, t)
+}
+
+func (s *Schema) allow(t string, path string) error {
+	if !s.allows(t) {
+		return fmt.Errorf("%s: expected %s, got %s", path, strings.Join(s.Types, " or "), t)
+	}
+	return nil
+}
+
+func (s *Schema) convertNumber(n json.Number, path string) (interface{}, error) {
+	// integer-valued numbers are integers, unless the schema only allows
+	// (non-integer) numbers
+	if i, err := n.Int64(); err == nil && (len(s.Types) == 0 || containsString(s.Types, "integer")) {
+		return i, nil
+	}
+	if err := s.allow("number", path); err != nil {
+		return nil, err
+	}
+	return n.Float64()
+}
+
+func (s *Schema) convertArray(arr []interface{}, path string) (interface{}, error) {
+	if err := s.allow("array", path); err != nil {
+		return nil, err
+	}
+	out := make([]interface{}, len(arr))
+	for i, elem := range arr {
+		var err error
+		sub := s.Items
+		if sub == nil {
+			sub = &Schema{AdditionalProperties: true}
+		}
+		out[i], err = sub.convert(elem, fmt.Sprintf("%s[%d]", path, i))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+func (s *Schema) convertObject(obj map[string]interface{}, path string) (interface{}, error) {
+	if err := s.allow("object", path); err != nil {
+		return nil, err
+	}
+	for _, name := range s.Required {
+		if _, ok := obj[name]; !ok {
+			return nil, fmt.Errorf("%s: missing required property %q", path, name)
+		}
+	}
+	out := make(map[string]interface{}, len(obj))
+	for name, elem := range obj {
+		sub, ok := s.Properties[name]
+		if !ok {
+			if !s.AdditionalProperties {
+				return nil, fmt.Errorf("%s: unexpected property %q", path, name)
+			}
+			sub = &Schema{AdditionalProperties: true}
+		}
+		v, err := sub.convert(elem, path+"."+name)
+		if err != nil {
+			return nil, err
+		}
+		out[name] = v
+	}
+	return out, nil
+}
+
+func (s *Schema) checkEnum(v interface{}, path string) (interface{}, error) {
+	if len(s.Enum) == 0 {
+		return v, nil
+	}
+	for _, allowed := range s.Enum {
+		if DynamicEqual(v, allowed) {
+			return v, nil
+		}
+	}
+	return nil, fmt.Errorf("%s: value %v is not one of the allowed values", path, v)
+}
+
+// normalizeJSON converts a value decoded with json.Number for numbers to its
+// dynamic representation, without a schema.
+func normalizeJSON(raw interface{}) interface{} {
+	v, _ := (&Schema{AdditionalProperties: true}).convert(raw, "$")
+	return v
+}
+
+func decodeJSONNumbers(data []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
+
+func containsString(list []string, s string) bool {
+	for _, elem := range list {
+		if elem == s {
+			return true
+		}
+	}
+	return false
+}
+
+// DynamicEqual compares two dynamic values, like those produced by
+// [Schema.Decode], for equality. It can be used as a model's Equal function.
+func DynamicEqual(v1, v2 interface{}) bool {
+	return reflect.DeepEqual(v1, v2)
+}
@@ -0,0 +1,180 @@
+package porcupine
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+const kvInputSchema = `{
+  "type": "object",
+  "properties": {
+    "op": {"enum": ["get", "put", "append"]},
+    "key": {"type": "string"},
+    "value": {"type": "string"}
+  },
+  "required": ["op", "key"],
+  "additionalProperties": false
+}`
+
+const kvOutputSchema = `{
+  "type": ["object", "null"],
+  "properties": {"value": {"type": "string"}, "version": {"type": "integer"}}
+}`
+
+// a model for a single key of a key-value store, written over dynamic values
+// decoded with the schemas above
+var dynamicKvModel = Model{
+	Partition: func(history []Operation) [][]Operation {
+		m := make(map[string][]Operation)
+		var keys []string
+		for _, op := range history {
+			key := op.Input.(map[string]interface{})["key"].(string)
+			if _, ok := m[key]; !ok {
+				keys = append(keys, key)
+			}
+			m[key] = append(m[key], op)
+		}
+		var partitions [][]Operation
+		for _, key := range keys {
+			partitions = append(partitions, m[key])
+		}
+		return partitions
+	},
+	Init: func() interface{} {
+		return ""
+	},
+	Step: func(state, input, output interface{}) (bool, interface{}) {
+		inp := input.(map[string]interface{})
+		st := state.(string)
+		switch inp["op"] {
+		case "get":
+			out := output.(map[string]interface{})
+			return out["value"] == st, state
+		case "put":
+			return true, inp["value"]
+		default:
+			return true, st + inp["value"].(string)
+		}
+	},
+	Equal: DynamicEqual,
+	DescribeOperation: func(input, output interface{}) string {
+		inp := input.(map[string]interface{})
+		if inp["op"] == "get" {
+			return fmt.Sprintf("get('%s') -> '%s'", inp["key"], output.(map[string]interface{})["value"])
+		}
+		return fmt.Sprintf("%s('%s', '%s')", inp["op"], inp["key"], inp["value"])
+	},
+}
+
+func kvSchemaCodec(t *testing.T) *SchemaCodec {
+	input, err := ParseSchema([]byte(kvInputSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	output, err := ParseSchema([]byte(kvOutputSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &SchemaCodec{Input: input, Output: output}
+}
+
+func TestSchemaDecode(t *testing.T) {
+	codec := kvSchemaCodec(t)
+	v, err := codec.Output.Decode([]byte(`{"value": "x", "version": 3}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !DynamicEqual(v, map[string]interface{}{"value": "x", "version": int64(3)}) {
+		t.Fatalf("unexpected value %#v", v)
+	}
+	for _, bad := range []string{
+		`{"op": "delete", "key": "x"}`,
+		`{"op": "get"}`,
+		`{"op": "get", "key": "x", "extra": 1}`,
+		`{"op": "put", "key": 1}`,
+		`[]`,
+	} {
+		if _, err := codec.Input.Decode([]byte(bad)); err == nil {
+			t.Fatalf("expected %s to be rejected", bad)
+		}
+	}
+	if _, err := codec.Output.Decode([]byte(`{"version": 1.5}`)); err == nil {
+		t.Fatal("expected a non-integer version to be rejected")
+	}
+	if _, err := ParseSchema([]byte(`{"type": "widget"}`)); err == nil {
+		t.Fatal("expected an unknown type to be rejected")
+	}
+
+	anyOf, err := ParseSchema([]byte(`{"oneOf": [{"type": "integer"}, {"type": "string", "const": "none"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, err := anyOf.Decode([]byte(`7`)); err != nil || v != int64(7) {
+		t.Fatalf("unexpected result %v, %v", v, err)
+	}
+	if _, err := anyOf.Decode([]byte(`"some"`)); err == nil {
+		t.Fatal("expected a value matching no alternative to be rejected")
+	}
+}
+
+func TestReadJSONOperations(t *testing.T) {
+	RegisterCodec("test-kv", kvSchemaCodec(t))
+	history := `{
+	  "codec": "test-kv",
+	  "operations": [
+	    {"client": 0, "input": {"op": "put", "key": "x", "value": "a"}, "call": 0, "output": null, "return": 10},
+	    {"client": 1, "input": {"op": "append", "key": "x", "value": "b"}, "call": 5, "output": null, "return": 15},
+	    {"client": 2, "input": {"op": "get", "key": "x"}, "call": 20, "output": {"value": "ab"}, "return": 30},
+	    {"client": 2, "input": {"op": "get", "key": "y"}, "call": 40, "output": {"value": ""}, "return": 50}
+	  ]
+	}`
+	ops, err := ReadJSONOperations(strings.NewReader(history), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, info := CheckOperationsVerbose(dynamicKvModel, ops, 0)
+	if res != Ok {
+		t.Fatalf("expected output %v, got output %v", Ok, res)
+	}
+	visualizeTempFile(t, dynamicKvModel, info)
+
+	bad := strings.Replace(history, `"value": "ab"`, `"value": "ba"`, 1)
+	ops, err = ReadJSONOperations(strings.NewReader(bad), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if CheckOperations(dynamicKvModel, ops) {
+		t.Fatal("expected operations to not be linearizable")
+	}
+
+	invalid := strings.Replace(history, `"op": "get", "key": "y"`, `"op": "get"`, 1)
+	_, err = ReadJSONOperations(strings.NewReader(invalid), nil)
+	if err == nil || !strings.Contains(err.Error(), "operations[3].input") {
+		t.Fatalf("expected a validation error pointing at the operation, got %v", err)
+	}
+	_, err = ReadJSONOperations(strings.NewReader(`{"codec": "missing"}`), nil)
+	if err == nil {
+		t.Fatal("expected an error for an unknown codec")
+	}
+}
+
+func TestReadJSONEvents(t *testing.T) {
+	history := `{
+	  "events": [
+	    {"client": 0, "kind": "call", "value": {"op": "put", "key": "x", "value": "a"}, "id": 0},
+	    {"client": 1, "kind": "call", "value": {"op": "get", "key": "x"}, "id": 1},
+	    {"client": 1, "kind": "return", "value": {"value": "a"}, "id": 1},
+	    {"client": 0, "kind": "return", "value": null, "id": 0}
+	  ]
+	}`
+	events, err := ReadJSONEvents(strings.NewReader(history), kvSchemaCodec(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	model := dynamicKvModel
+	model.Partition = nil
+	if !CheckEvents(model, events) {
+		t.Fatal("expected operations to be linearizable")
+	}
+}
@@ -0,0 +1,95 @@
+package porcupine
+
+import (
+	"math/rand"
+	"time"
+)
+
+// SensitivityOptions configures [CheckSensitivity].
+type SensitivityOptions struct {
+	// Maximum amount by which each Call and Return timestamp is shifted,
+	// in either direction, in the same units as the history's timestamps.
+	Jitter int64
+	// Number of perturbed histories to check. Defaults to 100 if left as 0.
+	Trials int
+	// Seed for the perturbations, so that an analysis can be reproduced.
+	Seed int64
+	// Timeout for each individual check; 0 means no timeout.
+	Timeout time.Duration
+}
+
+// A SensitivityReport is the result of [CheckSensitivity].
+type SensitivityReport struct {
+	Result         CheckResult // verdict on the history as recorded
+	Trials         int         // number of perturbed histories checked
+	Agreed         int         // trials whose verdict matched Result
+	Flipped        int         // trials whose verdict was the opposite of Result
+	Unknown        int         // trials that timed out
+	Jitter         int64       // the jitter bound that was used
+	Seed           int64       // the seed that was used
+	Counterexample []Operation // a perturbed history whose verdict flipped, if any
+}
+
+// Stable returns whether the verdict was the same for every perturbed history
+// that was checked to completion.
+func (r SensitivityReport) Stable() bool {
+	return r.Flipped == 0
+}
+
+// CheckSensitivity quantifies how sensitive a linearizability verdict is to
+// noise in the recorded timestamps.
+//
+// It checks the history as given, and then repeatedly shifts every Call and
+// Return timestamp by a uniformly random amount in [-Jitter, Jitter] and
+// checks the perturbed history. If the verdict is stable under a jitter bound
+// that covers the clock error of the recording layer, then the verdict does
+// not depend on measurement noise. If it is not stable, the report includes
+// one of the perturbed histories for which the verdict flipped.
+//
+// Perturbing a timestamp never makes an operation return before it is called:
+// if that would happen, both timestamps are set to their midpoint.
+func CheckSensitivity(model Model, history []Operation, opts SensitivityOptions) SensitivityReport {
+	trials := opts.Trials
+	if trials == 0 {
+		trials = 100
+	}
+	report := SensitivityReport{
+		Result: CheckOperationsTimeout(model, history, opts.Timeout),
+		Jitter: opts.Jitter,
+		Seed:   opts.Seed,
+	}
+	rng := rand.New(rand.NewSource(opts.Seed))
+	for i := 0; i < trials; i++ {
+		perturbed := perturbHistory(history, opts.Jitter, rng)
+		res := CheckOperationsTimeout(model, perturbed, opts.Timeout)
+		report.Trials++
+		switch {
+		case res == Unknown || report.Result == Unknown:
+			report.Unknown++
+		case res == report.Result:
+			report.Agreed++
+		default:
+			report.Flipped++
+			if report.Counterexample == nil {
+				report.Counterexample = perturbed
+			}
+		}
+	}
+	return report
+}
+
+func perturbHistory(history []Operation, jitter int64, rng *rand.Rand) []Operation {
+	perturbed := make([]Operation, len(history))
+	for i, op := range history {
+		if jitter > 0 {
+			op.Call += rng.Int63n(2*jitter+1) - jitter
+			op.Return += rng.Int63n(2*jitter+1) - jitter
+			if op.Call > op.Return {
+				mid := op.Call/2 + op.Return/2
+				op.Call, op.Return = mid, mid
+			}
+		}
+		perturbed[i] = op
+	}
+	return perturbed
+}
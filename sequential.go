@@ -0,0 +1,177 @@
+package porcupine
+
+import (
+	"encoding/binary"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// CheckSequentialConsistency checks whether a history is sequentially
+// consistent: whether there is a sequential order of its operations, legal
+// for the model, in which each client's operations take effect in the order
+// the client called them. Unlike linearizability, sequential consistency
+// doesn't require operations of different clients to take effect in the
+// order they happened in real time.
+//
+// Sequential consistency isn't compositional, so the history is checked as a
+// whole, and the model's partition functions are not used. Of the options,
+// the check uses [WithTimeout] and [WithContext], and returns Unknown if it
+// is stopped before it can decide.
+func CheckSequentialConsistency(model Model, history []Operation, opts ...CheckOption) CheckResult {
+	return checkSequential(fillDefault(model), clientPrograms(history), checkOptions{}.apply(opts))
+}
+
+// clientPrograms groups the operations of a history by client, each in the
+// order the client called them.
+func clientPrograms(history []Operation) map[int][]Operation {
+	programs := make(map[int][]Operation)
+	for _, op := range history {
+		programs[op.ClientId] = append(programs[op.ClientId], op)
+	}
+	for _, program := range programs {
+		sort.SliceStable(program, func(i, j int) bool {
+			return program[i].Call < program[j].Call
+		})
+	}
+	return programs
+}
+
+// CheckEventsSequentialConsistency checks whether a history of events is
+// sequentially consistent; see [CheckSequentialConsistency]. Each client's
+// operations are in the order of their call events.
+func CheckEventsSequentialConsistency(model Model, history []Event, opts ...CheckOption) CheckResult {
+	programs := make(map[int][]Operation)
+	type position struct{ client, index int }
+	calls := make(map[int]position)
+	for _, e := range history {
+		if e.Kind == CallEvent {
+			calls[e.Id] = position{e.ClientId, len(programs[e.ClientId])}
+			programs[e.ClientId] = append(programs[e.ClientId], Operation{ClientId: e.ClientId, Input: e.Value})
+		} else if p, ok := calls[e.Id]; ok {
+			programs[p.client][p.index].Output = e.Value
+		}
+	}
+	return checkSequential(fillDefault(model), programs, checkOptions{}.apply(opts))
+}
+
+// checkSequential searches for an interleaving of the clients' programs that
+// is legal for the model, trying the next operation of each client in turn
+// and backtracking when none can go next.
+func checkSequential(model Model, byClient map[int][]Operation, opts checkOptions) CheckResult {
+	clients := make([]int, 0, len(byClient))
+	for client := range byClient {
+		clients = append(clients, client)
+	}
+	sort.Ints(clients)
+	programs := make([][]Operation, len(clients))
+	remaining := 0
+	for i, client := range clients {
+		programs[i] = byClient[client]
+		remaining += len(programs[i])
+	}
+	stop, release := stopSignal(opts)
+	defer release()
+	type step struct {
+		program int
+		state   interface{} // the state before the step
+	}
+	// index of the next operation of each program
+	next := make([]int, len(programs))
+	// states reached after each combination of program prefixes, so that
+	// the search doesn't explore them again
+	visited := make(map[string][]interface{})
+	var steps []step
+	state := model.Init()
+	program := 0 // the next program to try at this point of the search
+	key := make([]byte, 0, len(programs)*binary.MaxVarintLen64)
+	for remaining > 0 {
+		if atomic.LoadInt32(stop) != 0 {
+			return Unknown
+		}
+		if program == len(programs) {
+			// no program can go next, so undo the last step
+			if len(steps) == 0 {
+				return Illegal
+			}
+			last := steps[len(steps)-1]
+			steps = steps[:len(steps)-1]
+			next[last.program]--
+			state = last.state
+			remaining++
+			program = last.program + 1
+			continue
+		}
+		p := program
+		program++
+		if next[p] == len(programs[p]) {
+			continue
+		}
+		op := programs[p][next[p]]
+		ok, newState := model.Step(state, op.Input, op.Output)
+		if !ok {
+			continue
+		}
+		next[p]++
+		key = key[:0]
+		for _, n := range next {
+			key = appendUvarint(key, uint64(n))
+		}
+		if containsState(model, visited[string(key)], newState) {
+			next[p]--
+			continue
+		}
+		visited[string(key)] = append(visited[string(key)], newState)
+		steps = append(steps, step{p, state})
+		state = newState
+		remaining--
+		program = 0
+	}
+	return Ok
+}
+
+// stopSignal returns a flag that is set once the check's timeout expires or
+// its context is done, and a function that releases what watches for them.
+func stopSignal(opts checkOptions) (*int32, func()) {
+	stop := new(int32)
+	var timer *time.Timer
+	if opts.timeout > 0 {
+		timer = time.AfterFunc(opts.timeout, func() {
+			atomic.StoreInt32(stop, 1)
+		})
+	}
+	done := make(chan struct{})
+	if opts.ctx != nil {
+		if opts.ctx.Err() != nil {
+			*stop = 1
+		}
+		go func() {
+			select {
+			case <-opts.ctx.Done():
+				atomic.StoreInt32(stop, 1)
+			case <-done:
+			}
+		}()
+	}
+	return stop, func() {
+		if timer != nil {
+			timer.Stop()
+		}
+		close(done)
+	}
+}
+
+func appendUvarint(buf []byte, x uint64) []byte {
+	var b [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(b[:], x)
+	return append(buf, b[:n]...)
+}
+
+func containsState(model Model, states []interface{}, state interface{}) bool {
+	for _, s := range states {
+		if model.Equal(s, state) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,81 @@
+package porcupine
+
+import (
+	"context"
+	"testing"
+)
+
+func TestSequentialConsistencyStaleRead(t *testing.T) {
+	// the read returns before the write takes effect, which is sequentially
+	// consistent but not linearizable
+	history := []Operation{
+		{ClientId: 0, Input: registerInput{false, 1}, Call: 0, Output: 0, Return: 10},
+		{ClientId: 1, Input: registerInput{true, 0}, Call: 20, Output: 0, Return: 30},
+	}
+	if CheckOperations(registerModel, history) {
+		t.Fatal("expected operations not to be linearizable")
+	}
+	if res := CheckSequentialConsistency(registerModel, history); res != Ok {
+		t.Fatalf("expected output %v, got output %v", Ok, res)
+	}
+	// but a client's own operations stay in order
+	history[1].ClientId = 0
+	if res := CheckSequentialConsistency(registerModel, history); res != Illegal {
+		t.Fatalf("expected output %v, got output %v", Illegal, res)
+	}
+}
+
+func TestSequentialConsistencyCrossedReads(t *testing.T) {
+	// each client reads the other's write after its own, so the writes would
+	// have to take effect in both orders
+	history := []Operation{
+		{ClientId: 0, Input: registerInput{false, 1}, Call: 0, Output: 0, Return: 10},
+		{ClientId: 0, Input: registerInput{true, 0}, Call: 20, Output: 2, Return: 30},
+		{ClientId: 1, Input: registerInput{false, 2}, Call: 0, Output: 0, Return: 10},
+		{ClientId: 1, Input: registerInput{true, 0}, Call: 20, Output: 1, Return: 30},
+	}
+	if res := CheckSequentialConsistency(registerModel, history); res != Illegal {
+		t.Fatalf("expected output %v, got output %v", Illegal, res)
+	}
+	history[3].Output = 2
+	if res := CheckSequentialConsistency(registerModel, history); res != Ok {
+		t.Fatalf("expected output %v, got output %v", Ok, res)
+	}
+}
+
+func TestSequentialConsistencyEvents(t *testing.T) {
+	events := []Event{
+		{ClientId: 0, Kind: CallEvent, Value: registerInput{false, 1}, Id: 0},
+		{ClientId: 0, Kind: ReturnEvent, Value: 0, Id: 0},
+		{ClientId: 1, Kind: CallEvent, Value: registerInput{true, 0}, Id: 1},
+		{ClientId: 1, Kind: ReturnEvent, Value: 0, Id: 1},
+		{ClientId: 1, Kind: CallEvent, Value: registerInput{true, 0}, Id: 2},
+		{ClientId: 1, Kind: ReturnEvent, Value: 1, Id: 2},
+	}
+	if res := CheckEventsSequentialConsistency(registerModel, events); res != Ok {
+		t.Fatalf("expected output %v, got output %v", Ok, res)
+	}
+	// the client can't read the old value after reading the new one
+	events[3].Value, events[5].Value = 1, 0
+	if res := CheckEventsSequentialConsistency(registerModel, events); res != Illegal {
+		t.Fatalf("expected output %v, got output %v", Illegal, res)
+	}
+}
+
+func TestSequentialConsistencyLinearizable(t *testing.T) {
+	// a linearizable history is sequentially consistent
+	history := registerHistory(100)
+	if res := CheckSequentialConsistency(registerModel, history); res != Ok {
+		t.Fatalf("expected output %v, got output %v", Ok, res)
+	}
+}
+
+func TestSequentialConsistencyContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	history := registerHistory(200)
+	history[len(history)-1].Output = 1000
+	if res := CheckSequentialConsistency(registerModel, history, WithContext(ctx)); res != Unknown {
+		t.Fatalf("expected output %v, got output %v", Unknown, res)
+	}
+}
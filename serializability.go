@@ -0,0 +1,52 @@
+package porcupine
+
+// SerializabilityResult reports which consistency levels a history of
+// transactions satisfies; see [CheckTransactions].
+type SerializabilityResult struct {
+	// StrictlySerializable is the result of checking the history with
+	// real-time constraints.
+	StrictlySerializable CheckResult
+	// Serializable is the result of checking the history without them. The
+	// check only runs if the history is not strictly serializable;
+	// otherwise, it is Ok.
+	Serializable CheckResult
+}
+
+// CheckTransactions checks a history in which each operation is a
+// transaction, and the model is a sequential specification of the whole
+// store, for strict serializability, and, if the history fails that, for
+// plain serializability, so that a failure reports whether the store still
+// provides the weaker guarantee.
+//
+// Strict serializability is linearizability of the transactions, so it is
+// checked like [CheckHistory], with the model's partitions.
+func CheckTransactions(model Model, history []Operation, opts ...CheckOption) SerializabilityResult {
+	txnModel, txnHistory := maybeCommitted(model, history)
+	res, _ := CheckHistory(txnModel, txnHistory, opts...)
+	if res != Illegal {
+		return SerializabilityResult{StrictlySerializable: res, Serializable: res}
+	}
+	return SerializabilityResult{
+		StrictlySerializable: Illegal,
+		Serializable:         CheckSerializability(model, history, opts...),
+	}
+}
+
+// CheckSerializability checks whether a history in which each operation is a
+// transaction is serializable: whether there is some sequential order of its
+// transactions that is legal for the model, regardless of when they happened
+// in real time and which clients ran them.
+//
+// Like [CheckSequentialConsistency], the history is checked as a whole, the
+// model's partition functions are not used, and of the options, the check
+// uses [WithTimeout] and [WithContext].
+func CheckSerializability(model Model, history []Operation, opts ...CheckOption) CheckResult {
+	model, history = maybeCommitted(model, history)
+	// each transaction is a program of its own, so that they can go in any
+	// order
+	programs := make(map[int][]Operation, len(history))
+	for i, op := range history {
+		programs[i] = []Operation{op}
+	}
+	return checkSequential(fillDefault(model), programs, checkOptions{}.apply(opts))
+}
@@ -0,0 +1,68 @@
+package porcupine
+
+import "testing"
+
+// transferInput moves amount from one account to the other, and reads both
+// balances afterwards.
+type transferInput struct {
+	from, to, amount int
+}
+
+type balances [2]int
+
+var bankModel = Model{
+	Init: func() interface{} {
+		return balances{100, 100}
+	},
+	Step: func(state, input, output interface{}) (bool, interface{}) {
+		st := state.(balances)
+		inp := input.(transferInput)
+		st[inp.from] -= inp.amount
+		st[inp.to] += inp.amount
+		return output.(balances) == st, st
+	},
+}
+
+func TestCheckTransactions(t *testing.T) {
+	// the second transfer finishes before the first starts, but only
+	// explains the balances if it took effect after it
+	history := []Operation{
+		{ClientId: 0, Input: transferInput{0, 1, 10}, Call: 20, Output: balances{90, 110}, Return: 30},
+		{ClientId: 1, Input: transferInput{1, 0, 5}, Call: 0, Output: balances{95, 105}, Return: 10},
+	}
+	res := CheckTransactions(bankModel, history)
+	if res.StrictlySerializable != Illegal || res.Serializable != Ok {
+		t.Fatalf("expected serializable but not strictly serializable, got %+v", res)
+	}
+	// in real-time order
+	history[1].Output = balances{105, 95}
+	history[0].Output = balances{95, 105}
+	res = CheckTransactions(bankModel, history)
+	if res.StrictlySerializable != Ok || res.Serializable != Ok {
+		t.Fatalf("expected strictly serializable, got %+v", res)
+	}
+	// money appears from nowhere
+	history[0].Output = balances{100, 105}
+	res = CheckTransactions(bankModel, history)
+	if res.StrictlySerializable != Illegal || res.Serializable != Illegal {
+		t.Fatalf("expected not serializable, got %+v", res)
+	}
+}
+
+func TestCheckSerializabilityConcurrent(t *testing.T) {
+	// transfers by the same client can go in any order too
+	var history []Operation
+	for i := 0; i < 10; i++ {
+		history = append(history, Operation{ClientId: 0, Input: transferInput{0, 1, 1}, Call: int64(i), Return: int64(i)})
+	}
+	for i := range history {
+		history[i].Output = balances{100 - (len(history) - i), 100 + (len(history) - i)}
+	}
+	if res := CheckSerializability(bankModel, history); res != Ok {
+		t.Fatalf("expected output %v, got output %v", Ok, res)
+	}
+	history[0].Output = balances{0, 0}
+	if res := CheckSerializability(bankModel, history); res != Illegal {
+		t.Fatalf("expected output %v, got output %v", Illegal, res)
+	}
+}
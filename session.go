@@ -0,0 +1,176 @@
+package porcupine
+
+import (
+	"fmt"
+	"sort"
+)
+
+// A KeyAccess describes how an operation accesses a key-value store, for
+// [CheckSessionGuarantees].
+//
+// Values must be comparable, and no two writes to a key may write the same
+// value, so that each read can be traced to the write it read from, which
+// test workloads usually ensure by writing unique values. A read of a value
+// that no operation wrote reads the initial value.
+type KeyAccess struct {
+	Key   string
+	Write bool // whether the operation writes Value to Key, or reads it
+	Value interface{}
+}
+
+// A SessionGuarantee is a guarantee that a store makes to each session.
+type SessionGuarantee string
+
+const (
+	// a read observes the session's earlier writes
+	ReadYourWrites SessionGuarantee = "ReadYourWrites"
+	// a read observes the writes that the session's earlier reads observed
+	MonotonicReads SessionGuarantee = "MonotonicReads"
+	// the session's writes take effect in the order it made them
+	MonotonicWrites SessionGuarantee = "MonotonicWrites"
+)
+
+// A SessionViolation is a read that violates a session guarantee, found by
+// [CheckSessionGuarantees].
+type SessionViolation struct {
+	Guarantee SessionGuarantee
+	Session   string
+	Operation int // the index in the history of the read
+	// the index in the history of the write that the read observed, or -1
+	// if it observed the initial value
+	Write       int
+	Description string
+}
+
+// CheckSessionGuarantees checks whether the reads in a history observe the
+// read-your-writes, monotonic-reads, and monotonic-writes session guarantees,
+// where access says how each operation accesses the store, and returns false
+// for operations that neither read nor write it. Each client is a session;
+// use [CheckTaggedSessionGuarantees] for clients that multiplex several.
+//
+// A read violates a guarantee if it observes a write that finished before a
+// write that the guarantee requires it to observe started, so the check only
+// relies on the real-time order of writes, and takes O(n log n) time.
+// Passing it doesn't mean that the history is linearizable, but a
+// linearizable history passes it if each session's operations don't overlap
+// in time, so it gives fast feedback before a full check.
+// Each violating read is reported once, for the first guarantee it violates
+// in the order above, and violations are in the order of the history.
+func CheckSessionGuarantees(history []Operation, access func(op Operation) (KeyAccess, bool)) []SessionViolation {
+	return checkSessionGuarantees(history, operationSessions(history, nil), access)
+}
+
+// CheckTaggedSessionGuarantees is like [CheckSessionGuarantees], but the
+// sessions are given by [TaggedOperation.Session].
+func CheckTaggedSessionGuarantees(history []TaggedOperation, access func(op Operation) (KeyAccess, bool)) []SessionViolation {
+	ops, tags := splitTaggedOperations(history)
+	return checkSessionGuarantees(ops, operationSessions(ops, tags), access)
+}
+
+// operationSessions returns the session of each operation in a history. If
+// tags is not nil, tags[i] holds the tags for history[i].
+func operationSessions(history []Operation, tags []map[string]string) []string {
+	sessions := make([]string, len(history))
+	for i, op := range history {
+		var t map[string]string
+		if tags != nil {
+			t = tags[i]
+		}
+		sessions[i] = sessionOf(op.ClientId, t)
+	}
+	return sessions
+}
+
+func checkSessionGuarantees(history []Operation, opSessions []string, access func(op Operation) (KeyAccess, bool)) []SessionViolation {
+	type write struct {
+		key   string
+		value interface{}
+	}
+	accesses := make([]KeyAccess, len(history))
+	accessed := make([]bool, len(history))
+	writes := make(map[write]int)
+	// the position of each write among its session's writes to the key
+	writeSeq := make(map[int]int)
+	bySession := make(map[string][]int)
+	for i, op := range history {
+		accesses[i], accessed[i] = access(op)
+		if !accessed[i] {
+			continue
+		}
+		bySession[opSessions[i]] = append(bySession[opSessions[i]], i)
+	}
+	var sessions []string
+	for s, ops := range bySession {
+		sessions = append(sessions, s)
+		sort.SliceStable(ops, func(a, b int) bool {
+			return history[ops[a]].Call < history[ops[b]].Call
+		})
+		seq := make(map[string]int)
+		for _, i := range ops {
+			if a := accesses[i]; a.Write {
+				writes[write{a.Key, a.Value}] = i
+				writeSeq[i] = seq[a.Key]
+				seq[a.Key]++
+			}
+		}
+	}
+	sort.Strings(sessions)
+	// precedes reports whether the write observed finishes before the
+	// write required starts; -1 is the initial value
+	precedes := func(observed, required int) bool {
+		if required < 0 {
+			return false
+		}
+		return observed < 0 || history[observed].Return < history[required].Call
+	}
+	var violations []SessionViolation
+	for _, s := range sessions {
+		// for each key, the write that the session made, and the one it
+		// read, that starts the latest, and the one it read last
+		type floors struct{ written, read, last int }
+		keys := make(map[string]*floors)
+		for _, i := range bySession[s] {
+			a := accesses[i]
+			f := keys[a.Key]
+			if f == nil {
+				f = &floors{-1, -1, -1}
+				keys[a.Key] = f
+			}
+			if a.Write {
+				if f.written < 0 || history[i].Call > history[f.written].Call {
+					f.written = i
+				}
+				continue
+			}
+			observed, ok := writes[write{a.Key, a.Value}]
+			if !ok {
+				observed = -1
+			}
+			violation := SessionViolation{Session: s, Operation: i, Write: observed}
+			switch {
+			case precedes(observed, f.written):
+				violation.Guarantee = ReadYourWrites
+				violation.Description = fmt.Sprintf("read %v from key %q, which is older than the session's write of %v", a.Value, a.Key, accesses[f.written].Value)
+			case precedes(observed, f.read):
+				violation.Guarantee = MonotonicReads
+				violation.Description = fmt.Sprintf("read %v from key %q after reading the newer %v", a.Value, a.Key, accesses[f.read].Value)
+			case f.last >= 0 && observed >= 0 && opSessions[observed] == opSessions[f.last] && writeSeq[observed] < writeSeq[f.last]:
+				violation.Guarantee = MonotonicWrites
+				violation.Description = fmt.Sprintf("read %v from key %q after reading %v, which session %q wrote later", a.Value, a.Key, accesses[f.last].Value, opSessions[f.last])
+			}
+			if violation.Guarantee != "" {
+				violations = append(violations, violation)
+			}
+			if observed >= 0 {
+				if f.read < 0 || history[observed].Call > history[f.read].Call {
+					f.read = observed
+				}
+			}
+			f.last = observed
+		}
+	}
+	sort.SliceStable(violations, func(a, b int) bool {
+		return violations[a].Operation < violations[b].Operation
+	})
+	return violations
+}
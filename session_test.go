@@ -0,0 +1,77 @@
+package porcupine
+
+import "testing"
+
+func registerAccess(op Operation) (KeyAccess, bool) {
+	in := op.Input.(registerInput)
+	if !in.op {
+		return KeyAccess{Key: "x", Write: true, Value: in.value}, true
+	}
+	return KeyAccess{Key: "x", Value: op.Output}, true
+}
+
+func TestCheckSessionGuarantees(t *testing.T) {
+	history := []Operation{
+		// 0-2: reads an older write than its own
+		{ClientId: 0, Input: registerInput{false, 1}, Call: 0, Output: 0, Return: 10},
+		{ClientId: 0, Input: registerInput{false, 2}, Call: 20, Output: 0, Return: 30},
+		{ClientId: 0, Input: registerInput{true, 0}, Call: 40, Output: 1, Return: 50},
+		// 3-4: goes back in time
+		{ClientId: 1, Input: registerInput{true, 0}, Call: 60, Output: 2, Return: 70},
+		{ClientId: 1, Input: registerInput{true, 0}, Call: 80, Output: 1, Return: 90},
+		// 5-6: overlapping writes of one session, tagged below, which must
+		// still take effect in order
+		{ClientId: 2, Input: registerInput{false, 3}, Call: 100, Output: 0, Return: 200},
+		{ClientId: 3, Input: registerInput{false, 4}, Call: 110, Output: 0, Return: 120},
+		// 7-8: sees the writes out of order
+		{ClientId: 4, Input: registerInput{true, 0}, Call: 210, Output: 4, Return: 220},
+		{ClientId: 4, Input: registerInput{true, 0}, Call: 230, Output: 3, Return: 240},
+		// 9-10: reads the initial value after a write
+		{ClientId: 5, Input: registerInput{true, 0}, Call: 250, Output: 4, Return: 260},
+		{ClientId: 5, Input: registerInput{true, 0}, Call: 270, Output: 0, Return: 280},
+		// 11-14: reads concurrent writes, which can take effect in either
+		// order
+		{ClientId: 7, Input: registerInput{false, 5}, Call: 300, Output: 0, Return: 400},
+		{ClientId: 8, Input: registerInput{false, 6}, Call: 300, Output: 0, Return: 400},
+		{ClientId: 6, Input: registerInput{true, 0}, Call: 410, Output: 6, Return: 420},
+		{ClientId: 6, Input: registerInput{true, 0}, Call: 430, Output: 5, Return: 440},
+	}
+	expected := []SessionViolation{
+		{Guarantee: ReadYourWrites, Session: "client 0", Operation: 2, Write: 0},
+		{Guarantee: MonotonicReads, Session: "client 1", Operation: 4, Write: 0},
+		{Guarantee: MonotonicWrites, Session: "client 4", Operation: 8, Write: 5},
+		{Guarantee: MonotonicReads, Session: "client 5", Operation: 10, Write: -1},
+	}
+	tagged := make([]TaggedOperation, len(history))
+	for i, op := range history {
+		tagged[i] = TaggedOperation{Operation: op}
+	}
+	tagged[5].Tags = map[string]string{SessionTag: "s"}
+	tagged[6].Tags = map[string]string{SessionTag: "s"}
+	violations := CheckTaggedSessionGuarantees(tagged, registerAccess)
+	if len(violations) != len(expected) {
+		t.Fatalf("expected %d violations, got %+v", len(expected), violations)
+	}
+	for i, e := range expected {
+		v := violations[i]
+		if v.Guarantee != e.Guarantee || v.Session != e.Session || v.Operation != e.Operation || v.Write != e.Write {
+			t.Fatalf("violation %d: expected %+v, got %+v", i, e, v)
+		}
+		if v.Description == "" {
+			t.Fatalf("violation %d: expected a description", i)
+		}
+	}
+	// without the tags, the writes are from separate sessions, so they
+	// can take effect in either order
+	violations = CheckSessionGuarantees(history, registerAccess)
+	if len(violations) != len(expected)-1 || violations[2].Operation != 10 {
+		t.Fatalf("expected the violations other than operation 8's, got %+v", violations)
+	}
+}
+
+func TestCheckSessionGuaranteesLinearizable(t *testing.T) {
+	history := registerHistory(400)
+	if violations := CheckSessionGuarantees(history, registerAccess); len(violations) != 0 {
+		t.Fatalf("expected no violations, got %+v", violations)
+	}
+}
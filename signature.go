@@ -0,0 +1,158 @@
+package porcupine
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// A FailureSignature identifies a linearizability failure in a way that is
+// stable across runs, so that identical failures (e.g., from runs of a
+// randomized test with different seeds) can be grouped together.
+//
+// A signature is made up of the model's Name, the shapes of the operations
+// that the checker was stuck on (see Model.OperationShape), and a digest of
+// the states the model could have been in at that point (see
+// Model.DescribeState). For signatures to group failures well, the
+// operation shapes and state descriptions should leave out details that vary
+// from run to run.
+type FailureSignature struct {
+	Model      string // the model's Name
+	Operations string // shapes of the operations that could not be linearized
+	States     string // digest of the candidate states at the point of failure
+}
+
+// String returns a human-readable form of the signature.
+func (s FailureSignature) String() string {
+	return fmt.Sprintf("%s: stuck on [%s] in states %s", s.Model, s.Operations, s.States)
+}
+
+// Key returns a short hash of the signature, suitable for use as a
+// deduplication key, e.g., in an issue title.
+func (s FailureSignature) Key() string {
+	h := sha256.Sum256([]byte(s.Model + "\x00" + s.Operations + "\x00" + s.States))
+	return hex.EncodeToString(h[:8])
+}
+
+// FailureSignature computes a signature for a failed linearizability check.
+//
+// The LinearizationInfo must come from one of the verbose check functions,
+// e.g., [CheckOperationsVerbose], and it returns false if the info does not
+// describe a failure (i.e., every partition was fully linearized). If several
+// partitions failed, the signature of the partition with the smallest Key is
+// returned, so the result does not depend on the order of partitions.
+func (li *LinearizationInfo) FailureSignature(model Model) (FailureSignature, bool) {
+	model = fillDefault(model)
+	var best FailureSignature
+	found := false
+	for p := range li.history {
+		sig, failed := partitionFailureSignature(model, li.history[p], li.partialLinearizations[p])
+		if failed && (!found || sig.Key() < best.Key()) {
+			best = sig
+			found = true
+		}
+	}
+	return best, found
+}
+
+func partitionFailureSignature(model Model, history []entry, partials [][]int) (FailureSignature, bool) {
+	ops := entryOperations(history)
+	longest := 0
+	for _, partial := range partials {
+		if len(partial) > longest {
+			longest = len(partial)
+		}
+	}
+	if longest == len(ops) {
+		return FailureSignature{}, false
+	}
+	if longest == 0 {
+		// no operation can be linearized first, so the checker is stuck
+		// in the initial state, with nothing linearized
+		partials = [][]int{{}}
+	}
+	shapes := make(map[string]struct{})
+	states := make(map[string]struct{})
+	for _, partial := range partials {
+		if len(partial) != longest {
+			continue
+		}
+		state := model.Init()
+		linearized := make([]bool, len(ops))
+		for _, id := range partial {
+			_, state = model.Step(state, ops[id].Input, ops[id].Output)
+			linearized[id] = true
+		}
+		states[model.DescribeState(state)] = struct{}{}
+		for _, id := range stuckOperations(model, ops, linearized, state) {
+			shapes[operationShape(model, ops[id])] = struct{}{}
+		}
+	}
+	stateDigest := sha256.Sum256([]byte(strings.Join(sortedKeys(states), "\x00")))
+	return FailureSignature{
+		Model:      model.Name,
+		Operations: strings.Join(sortedKeys(shapes), ", "),
+		States:     hex.EncodeToString(stateDigest[:8]),
+	}, true
+}
+
+// stuckOperations returns the IDs of the operations that could be linearized
+// next, given the operations linearized so far, but that the model rejects in
+// the given state.
+func stuckOperations(model Model, ops []Operation, linearized []bool, state interface{}) []int {
+	// an operation can go next if no other remaining operation returned
+	// before it was called
+	minReturn := int64(0)
+	first := true
+	for id, op := range ops {
+		if !linearized[id] && (first || op.Return < minReturn) {
+			minReturn = op.Return
+			first = false
+		}
+	}
+	var stuck []int
+	for id, op := range ops {
+		if linearized[id] || op.Call > minReturn {
+			continue
+		}
+		if ok, _ := model.Step(state, op.Input, op.Output); !ok {
+			stuck = append(stuck, id)
+		}
+	}
+	return stuck
+}
+
+func operationShape(model Model, op Operation) string {
+	if model.OperationShape != nil {
+		return model.OperationShape(op.Input, op.Output)
+	}
+	return fmt.Sprintf("%T -> %T", op.Input, op.Output)
+}
+
+// entryOperations reconstructs the operations in a partition's history,
+// indexed by ID.
+func entryOperations(history []entry) []Operation {
+	ops := make([]Operation, len(history)/2)
+	for _, e := range history {
+		if e.kind == callEntry {
+			ops[e.id].ClientId = e.clientId
+			ops[e.id].Input = e.value
+			ops[e.id].Call = e.time
+		} else {
+			ops[e.id].Output = e.value
+			ops[e.id].Return = e.time
+		}
+	}
+	return ops
+}
+
+func sortedKeys(set map[string]struct{}) []string {
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
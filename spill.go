@@ -0,0 +1,228 @@
+package porcupine
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const defaultSpillInterval = 100 * time.Millisecond
+
+// A spiller periodically moves a recorder's completed operations from the
+// clients' buffers to a writer. It reads the buffers the same way
+// Recorder.Operations does, so clients never wait for it, and it drops its
+// references to chunks once they have been written and filled, so that the
+// garbage collector can reclaim them.
+type spiller struct {
+	recorder *Recorder
+	stop     chan struct{}
+	done     chan struct{}
+
+	mu      sync.Mutex // protects everything below
+	w       *bufio.Writer
+	enc     *json.Encoder
+	codec   Codec
+	cursors map[*RecorderClient]*spillCursor
+	err     error
+	closed  bool
+}
+
+// A spillCursor is the position of the next operation to spill from a client.
+type spillCursor struct {
+	chunk *recordChunk
+	i     int64
+}
+
+func newSpiller(r *Recorder) *spiller {
+	w := bufio.NewWriter(r.opts.Spill)
+	s := &spiller{
+		recorder: r,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+		w:        w,
+		enc:      json.NewEncoder(w),
+		codec:    r.opts.SpillCodec,
+		cursors:  make(map[*RecorderClient]*spillCursor),
+	}
+	if s.codec == nil {
+		s.codec = &SchemaCodec{}
+	}
+	interval := r.opts.SpillInterval
+	if interval <= 0 {
+		interval = defaultSpillInterval
+	}
+	go s.run(interval)
+	return s
+}
+
+func (s *spiller) run(interval time.Duration) {
+	defer close(s.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.mu.Lock()
+			s.drain()
+			s.mu.Unlock()
+		}
+	}
+}
+
+// drain writes every operation that has completed since the last drain. The
+// caller must hold s.mu.
+func (s *spiller) drain() {
+	if s.err != nil {
+		return
+	}
+	for c := (*RecorderClient)(atomic.LoadPointer(&s.recorder.clients)); c != nil; c = c.next {
+		cur, ok := s.cursors[c]
+		if !ok {
+			// the client's head is published along with the client, and
+			// after this, only the cursor refers to it
+			cur = &spillCursor{chunk: c.head}
+			c.head = nil
+			s.cursors[c] = cur
+		}
+		for {
+			n := atomic.LoadInt64(&cur.chunk.n)
+			for ; cur.i < n; cur.i++ {
+				if err := s.write(cur.chunk.ops[cur.i].Operation); err != nil {
+					s.err = err
+					return
+				}
+			}
+			next := cur.chunk.loadNext()
+			if n < int64(len(cur.chunk.ops)) || next == nil {
+				break
+			}
+			cur.chunk = next
+			cur.i = 0
+		}
+	}
+}
+
+func (s *spiller) write(op Operation) error {
+	jop, err := encodeOperation(s.codec, op)
+	if err != nil {
+		return err
+	}
+	return s.enc.Encode(jop)
+}
+
+func (s *spiller) flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return s.err
+	}
+	s.drain()
+	if s.err == nil {
+		s.err = s.w.Flush()
+	}
+	return s.err
+}
+
+func (s *spiller) close() error {
+	s.mu.Lock()
+	closed := s.closed
+	s.closed = true
+	s.mu.Unlock()
+	if closed {
+		return s.err
+	}
+	close(s.stop)
+	<-s.done
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.drain()
+	if s.err == nil {
+		s.err = s.w.Flush()
+	}
+	return s.err
+}
+
+// Flush writes every operation that has completed so far to the spill
+// writer. It returns the first error encountered while spilling, if any; once
+// an error occurs, no more operations are written.
+//
+// Flush is a no-op for a recorder that does not spill.
+func (r *Recorder) Flush() error {
+	if r.spill == nil {
+		return nil
+	}
+	return r.spill.flush()
+}
+
+// Close flushes a spilling recorder and stops its background writer, and
+// returns the first error encountered while spilling, if any. It does not
+// close the spill writer. Operations that complete after Close are not
+// written.
+//
+// Close is a no-op for a recorder that does not spill.
+func (r *Recorder) Close() error {
+	if r.spill == nil {
+		return nil
+	}
+	return r.spill.close()
+}
+
+// A JSONLReader streams operations from a JSON Lines file, such as one written
+// by a spilling [Recorder], without reading the whole file into memory. Each
+// line holds one operation, in the same form as the elements of
+// "operations" in [ReadJSONOperations]:
+//
+//	{"client": 0, "input": ..., "call": 0, "output": ..., "return": 10}
+type JSONLReader struct {
+	dec   *json.Decoder
+	codec Codec
+	n     int
+}
+
+// NewJSONLReader returns a reader that decodes operations from r with the
+// given codec. If codec is nil, inputs and outputs are decoded as dynamic
+// values.
+func NewJSONLReader(r io.Reader, codec Codec) *JSONLReader {
+	if codec == nil {
+		codec = &SchemaCodec{}
+	}
+	return &JSONLReader{dec: json.NewDecoder(decompressed(r)), codec: codec}
+}
+
+// Next returns the next operation. It returns io.EOF when there are no more
+// operations.
+func (r *JSONLReader) Next() (Operation, error) {
+	var jop jsonOperation
+	if err := r.dec.Decode(&jop); err != nil {
+		if err == io.EOF {
+			return Operation{}, io.EOF
+		}
+		return Operation{}, fmt.Errorf("operation %d: %v", r.n, err)
+	}
+	op, err := decodeOperation(r.codec, jop, fmt.Sprintf("operation %d", r.n))
+	r.n++
+	return op, err
+}
+
+// ReadJSONLOperations reads all operations from a JSON Lines file; see
+// [JSONLReader].
+func ReadJSONLOperations(r io.Reader, codec Codec) ([]Operation, error) {
+	reader := NewJSONLReader(r, codec)
+	var ops []Operation
+	for {
+		op, err := reader.Next()
+		if err == io.EOF {
+			return ops, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		ops = append(ops, op)
+	}
+}
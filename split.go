@@ -0,0 +1,274 @@
+package porcupine
+
+import "sync/atomic"
+
+// SplitOptions configures how [WithPartitionSplitting] splits partitions that
+// are too large to check.
+type SplitOptions struct {
+	// A partition is oversized if it has more than MaxOperations
+	// operations, or if more than MaxConcurrency of its operations are in
+	// progress at once at some point; 0 means no limit on either. The cost
+	// of the search grows exponentially with the concurrency, so a
+	// partition with few but highly concurrent operations can be as
+	// intractable as a long one.
+	MaxOperations  int
+	MaxConcurrency int
+	// Split, if not nil, splits an oversized partition into smaller
+	// partitions that are checked independently, like Model.Partition, for
+	// example by a finer key than the model partitions by.
+	Split func(partition []Operation) [][]Operation
+	// WindowOperations, if positive, chunks an oversized partition, or a
+	// partition from Split that is still oversized, into time windows of
+	// at least this many operations each. The windows are cut where no
+	// operation is in progress, and checked in order, each starting from
+	// every state that the windows before it can end in. A partition where
+	// operations are always in progress can't be cut, and is checked as a
+	// whole.
+	WindowOperations int
+}
+
+// oversized reports whether a partition should be split.
+func (s *SplitOptions) oversized(history []entry) bool {
+	if s == nil {
+		return false
+	}
+	if s.MaxOperations > 0 && len(history)/2 > s.MaxOperations {
+		return true
+	}
+	return s.MaxConcurrency > 0 && maxConcurrency(history) > s.MaxConcurrency
+}
+
+// maxConcurrency returns the largest number of operations in a partition
+// that are in progress at once.
+func maxConcurrency(history []entry) int {
+	inProgress, max := 0, 0
+	for _, e := range history {
+		if e.kind == callEntry {
+			inProgress++
+			if inProgress > max {
+				max = inProgress
+			}
+		} else {
+			inProgress--
+		}
+	}
+	return max
+}
+
+// checkOversized checks a partition that is too large to search as a whole,
+// by splitting it as configured, and returns the longest linearizable prefix
+// found for each of its operations. The prefixes only cover the part of the
+// partition that each operation was searched in.
+func checkOversized(model Model, history []entry, opts searchOptions, split *SplitOptions) (CheckResult, []*[]int) {
+	n := len(history) / 2
+	longest := make([]*[]int, n)
+	var total PartitionMetrics
+	if m := opts.metrics; m != nil {
+		defer func() {
+			total.Entries = len(history)
+			*m = total
+		}()
+	}
+	// the pieces are searched as partitions of their own, which the
+	// progress tracker and checkpointer don't know about
+	search := opts
+	search.prog, search.cp = nil, nil
+	parts := [][]entry{history}
+	ids := [][]int{nil} // for each part, the ids of its operations in history
+	if split.Split != nil {
+		ops := entryOperations(history)
+		tags := make([]map[string]string, n)
+		for _, e := range history {
+			tags[e.id] = e.tags
+		}
+		matcher := newTagMatcher(ops, tags)
+		parts, ids = nil, nil
+		for _, part := range split.Split(ops) {
+			if len(part) == 0 {
+				continue
+			}
+			partIds := make([]int, len(part))
+			partTags := make([]map[string]string, len(part))
+			for i, op := range part {
+				partIds[i] = matcher.index(op)
+				if partIds[i] >= 0 {
+					partTags[i] = tags[partIds[i]]
+				}
+			}
+			parts = append(parts, makeEntries(part, partTags))
+			ids = append(ids, partIds)
+		}
+	}
+	result := Ok
+	for i, part := range parts {
+		var res CheckResult
+		var l []*[]int
+		if split.WindowOperations > 0 && split.oversized(part) {
+			// only a window of the whole partition is a prefix of it
+			prefix := new(int64)
+			if ids[i] == nil {
+				prefix = opts.prefix
+			}
+			res, l = checkWindows(model, part, search, split.WindowOperations, prefix, &total)
+		} else {
+			res, l = searchPart(model, part, search, &total)
+		}
+		mergeLongest(longest, l, ids[i])
+		if res == Illegal {
+			return Illegal, longest
+		}
+		if res == Unknown {
+			result = Unknown
+		}
+	}
+	if result == Ok {
+		atomic.StoreInt64(opts.prefix, int64(n))
+	}
+	return result, longest
+}
+
+// checkWindows checks a partition window by window, carrying the set of
+// states that the windows checked so far can end in to the next one. It
+// publishes the number of operations in the windows checked so far to
+// prefix.
+func checkWindows(model Model, history []entry, opts searchOptions, window int, prefix *int64, total *PartitionMetrics) (CheckResult, []*[]int) {
+	longest := make([]*[]int, len(history)/2)
+	states := []interface{}{model.Init()}
+	checked := 0
+	for start := 0; start < len(history); {
+		end, ops := windowEnd(history, start, window)
+		ids, entries := windowEntries(history[start:end])
+		res, next := searchWindow(model, entries, states, opts, total, longest, ids)
+		if res != Ok {
+			return res, longest
+		}
+		states = next
+		checked += ops
+		atomic.StoreInt64(prefix, int64(checked))
+		start = end
+	}
+	return Ok, longest
+}
+
+// windowEnd returns the end of the window of history that starts at start,
+// along with the number of operations in it. The window ends once it's long
+// enough and no operation is in progress, so that every operation is
+// entirely in one window.
+func windowEnd(history []entry, start int, window int) (int, int) {
+	end, inProgress, ops := start, 0, 0
+	for end < len(history) {
+		if history[end].kind == callEntry {
+			inProgress++
+		} else {
+			inProgress--
+			ops++
+		}
+		end++
+		if inProgress == 0 && ops >= window {
+			break
+		}
+	}
+	return end, ops
+}
+
+// searchWindow searches a window of a partition, whose entries are
+// renumbered as by windowEntries, from each of the given states, merging the
+// longest linearizable prefixes found into longest. It returns the states
+// that the window can end in, and Illegal if there are none.
+func searchWindow(model Model, entries []entry, states []interface{}, opts searchOptions, total *PartitionMetrics, longest []*[]int, ids []int) (CheckResult, []interface{}) {
+	var next []interface{}
+	search := opts
+	search.onComplete = func(state interface{}) {
+		for _, s := range next {
+			if model.Equal(s, state) {
+				return
+			}
+		}
+		next = append(next, state)
+	}
+	for _, state := range states {
+		state := state
+		windowModel := model
+		windowModel.Init = func() interface{} { return state }
+		res, l := searchPart(windowModel, entries, search, total)
+		mergeLongest(longest, l, ids)
+		if res == Unknown {
+			return Unknown, nil
+		}
+	}
+	if len(next) == 0 {
+		return Illegal, nil
+	}
+	return Ok, next
+}
+
+// windowEntries renumbers the entries of a window so that its operations'
+// ids start from 0, returning the original id of each operation along with
+// the renumbered entries.
+func windowEntries(history []entry) ([]int, []entry) {
+	renumbered := make(map[int]int)
+	var ids []int
+	entries := make([]entry, len(history))
+	for i, e := range history {
+		id, ok := renumbered[e.id]
+		if !ok {
+			id = len(ids)
+			renumbered[e.id] = id
+			ids = append(ids, e.id)
+		}
+		e.id = id
+		entries[i] = e
+	}
+	return ids, entries
+}
+
+// searchPart searches a piece of an oversized partition, adding its metrics
+// to total.
+func searchPart(model Model, history []entry, opts searchOptions, total *PartitionMetrics) (CheckResult, []*[]int) {
+	var m PartitionMetrics
+	var prefix int64
+	opts.metrics, opts.prefix = &m, &prefix
+	res, l := checkSingle(model, history, opts)
+	total.Steps += m.Steps
+	total.StatesGenerated += m.StatesGenerated
+	total.CacheHits += m.CacheHits
+	total.CacheMisses += m.CacheMisses
+	if m.MaxDepth > total.MaxDepth {
+		total.MaxDepth = m.MaxDepth
+	}
+	return res, l
+}
+
+// mergeLongest merges the longest linearizable prefixes found for a piece of
+// a partition into those of the whole partition, where ids maps the piece's
+// operation ids to the partition's, or is nil if they are the same.
+func mergeLongest(longest []*[]int, piece []*[]int, ids []int) {
+	mapped := make(map[*[]int]*[]int)
+	for id, seq := range piece {
+		if seq == nil {
+			continue
+		}
+		if ids != nil {
+			id = ids[id]
+			if id < 0 {
+				continue
+			}
+		}
+		m, ok := mapped[seq]
+		if !ok {
+			s := make([]int, 0, len(*seq))
+			for _, v := range *seq {
+				if ids == nil {
+					s = append(s, v)
+				} else if ids[v] >= 0 {
+					s = append(s, ids[v])
+				}
+			}
+			m = &s
+			mapped[seq] = m
+		}
+		if longest[id] == nil || len(*m) > len(*longest[id]) {
+			longest[id] = m
+		}
+	}
+}
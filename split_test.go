@@ -0,0 +1,82 @@
+package porcupine
+
+import "testing"
+
+func TestSplitWindows(t *testing.T) {
+	split := WithPartitionSplitting(SplitOptions{MaxOperations: 50, WindowOperations: 8})
+	history := registerHistory(400)
+	var metrics Metrics
+	res, info := CheckHistory(registerModel, history, split, WithMetrics(MetricsFunc(func(m Metrics) {
+		metrics = m
+	})))
+	if res != Ok {
+		t.Fatalf("expected output %v, got output %v", Ok, res)
+	}
+	if linearized, operations := info.LongestPrefixes(); linearized[0] != operations[0] {
+		t.Fatalf("expected the whole partition to be linearized, got %d of %d", linearized[0], operations[0])
+	}
+	if metrics.Total().Steps == 0 || metrics.Partitions[0].Entries != 800 {
+		t.Fatalf("expected metrics of the whole partition, got %+v", metrics.Partitions[0])
+	}
+	// a read in the middle of the history that no write explains
+	history[201].Output = 1000
+	if res := CheckOperations(registerModel, history, split); res {
+		t.Fatal("expected operations not to be linearizable")
+	}
+	res, info = CheckHistory(registerModel, history, split, WithVerbose())
+	if res != Illegal {
+		t.Fatalf("expected output %v, got output %v", Illegal, res)
+	}
+	if len(info.PartialLinearizations()[0]) == 0 {
+		t.Fatal("expected partial linearizations")
+	}
+}
+
+func TestSplitWindowsCarryStates(t *testing.T) {
+	// the first window can end with either write, and only one of them
+	// explains the read in the second window
+	history := []Operation{
+		{ClientId: 0, Input: registerInput{false, 1}, Call: 0, Output: 0, Return: 10},
+		{ClientId: 1, Input: registerInput{false, 2}, Call: 5, Output: 0, Return: 15},
+		{ClientId: 0, Input: registerInput{true, 0}, Call: 20, Output: 1, Return: 25},
+	}
+	split := WithPartitionSplitting(SplitOptions{MaxOperations: 1, WindowOperations: 1})
+	if res := CheckOperations(registerModel, history, split); !res {
+		t.Fatal("expected operations to be linearizable")
+	}
+	history[2].Output = 3
+	if res := CheckOperations(registerModel, history, split); res {
+		t.Fatal("expected operations not to be linearizable")
+	}
+}
+
+func TestSplitSecondary(t *testing.T) {
+	byKey := func(partition []Operation) [][]Operation {
+		return kvModel.Partition(partition)
+	}
+	for _, tc := range []struct {
+		file     string
+		expected bool
+	}{
+		{"test_data/kv/c10-ok.txt", true},
+		{"test_data/kv/c10-bad.txt", false},
+	} {
+		ops := kvOperations(parseKvLog(tc.file))
+		split := WithPartitionSplitting(SplitOptions{MaxConcurrency: 2, Split: byKey})
+		if res := CheckOperations(kvNoPartitionModel, ops, split); res != tc.expected {
+			t.Fatalf("%s: expected output %t, got output %t", tc.file, tc.expected, res)
+		}
+	}
+}
+
+func TestSplitUnderLimits(t *testing.T) {
+	// a partition within the limits is checked as a whole, so a splitter
+	// that would lose operations isn't called
+	split := WithPartitionSplitting(SplitOptions{MaxOperations: 100, MaxConcurrency: 4, Split: func([]Operation) [][]Operation {
+		t.Fatal("expected the partition not to be split")
+		return nil
+	}})
+	if res := CheckOperations(registerModel, registerHistory(40), split); !res {
+		t.Fatal("expected operations to be linearizable")
+	}
+}
@@ -0,0 +1,75 @@
+package porcupine
+
+import "sort"
+
+// A StaleRead is a read that missed writes that finished before it started,
+// found by [MeasureStaleness].
+type StaleRead struct {
+	Operation int // the index in the history of the read
+	// the index in the history of the write that the read observed, or -1
+	// if it observed the initial value
+	Write int
+	// the number of writes to the key that finished before the read
+	// started, and after the write it observed
+	Missed int
+	// the time from the return of the newest write it missed to the call of
+	// the read, in the units of the operations' timestamps
+	Staleness int64
+}
+
+// MeasureStaleness finds the reads in a history that returned stale values,
+// and measures how stale each was, which turns a failed linearizability check
+// of a register or key-value store into a number that can be tracked: how
+// long ago the newest write the read missed finished, and how many writes it
+// missed. access says how each operation accesses the store, as for
+// [CheckSessionGuarantees]. A read missed a write if the write finished
+// before the read started, and after the write that the read observed
+// finished, so that a linearizable history has no stale reads. The stale
+// reads are in the order of the history.
+func MeasureStaleness(history []Operation, access func(op Operation) (KeyAccess, bool)) []StaleRead {
+	type write struct {
+		key   string
+		value interface{}
+	}
+	writes := make(map[write]int)
+	// the writes to each key, sorted by return time
+	keyWrites := make(map[string][]int)
+	for i, op := range history {
+		if a, ok := access(op); ok && a.Write {
+			writes[write{a.Key, a.Value}] = i
+			keyWrites[a.Key] = append(keyWrites[a.Key], i)
+		}
+	}
+	for _, ws := range keyWrites {
+		sort.SliceStable(ws, func(a, b int) bool {
+			return history[ws[a]].Return < history[ws[b]].Return
+		})
+	}
+	var stale []StaleRead
+	for i, op := range history {
+		a, ok := access(op)
+		if !ok || a.Write {
+			continue
+		}
+		observed, ok := writes[write{a.Key, a.Value}]
+		if !ok {
+			observed = -1
+		}
+		read := StaleRead{Operation: i, Write: observed}
+		ws := keyWrites[a.Key]
+		// the writes that finished before the read started
+		preceding := sort.Search(len(ws), func(j int) bool {
+			return history[ws[j]].Return >= op.Call
+		})
+		for _, w := range ws[:preceding] {
+			if observed < 0 || history[w].Call > history[observed].Return {
+				read.Missed++
+				read.Staleness = op.Call - history[w].Return
+			}
+		}
+		if read.Missed > 0 {
+			stale = append(stale, read)
+		}
+	}
+	return stale
+}
@@ -0,0 +1,38 @@
+package porcupine
+
+import "testing"
+
+func TestMeasureStaleness(t *testing.T) {
+	history := []Operation{
+		{ClientId: 0, Input: registerInput{false, 1}, Call: 0, Output: 0, Return: 10},
+		{ClientId: 0, Input: registerInput{false, 2}, Call: 20, Output: 0, Return: 30},
+		{ClientId: 0, Input: registerInput{false, 3}, Call: 40, Output: 0, Return: 50},
+		// misses the writes of 2 and 3
+		{ClientId: 1, Input: registerInput{true, 0}, Call: 65, Output: 1, Return: 70},
+		// misses every write
+		{ClientId: 2, Input: registerInput{true, 0}, Call: 55, Output: 0, Return: 60},
+		// reads a concurrent write, which isn't stale
+		{ClientId: 3, Input: registerInput{true, 0}, Call: 25, Output: 2, Return: 35},
+		// up to date
+		{ClientId: 4, Input: registerInput{true, 0}, Call: 80, Output: 3, Return: 90},
+	}
+	if CheckOperations(registerModel, history) {
+		t.Fatal("expected operations not to be linearizable")
+	}
+	expected := []StaleRead{
+		{Operation: 3, Write: 0, Missed: 2, Staleness: 15},
+		{Operation: 4, Write: -1, Missed: 3, Staleness: 5},
+	}
+	stale := MeasureStaleness(history, registerAccess)
+	if len(stale) != len(expected) {
+		t.Fatalf("expected %+v, got %+v", expected, stale)
+	}
+	for i := range expected {
+		if stale[i] != expected[i] {
+			t.Fatalf("expected %+v, got %+v", expected, stale)
+		}
+	}
+	if stale := MeasureStaleness(registerHistory(400), registerAccess); len(stale) != 0 {
+		t.Fatalf("expected no stale reads, got %+v", stale)
+	}
+}
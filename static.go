@@ -0,0 +1,283 @@
+package porcupine
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"sort"
+	"strings"
+)
+
+const (
+	// staticBudget is roughly the most bytes of operations that a static
+	// visualization draws, to keep files around 100KB.
+	staticBudget = 90 * 1024
+	// staticUnit is the width, in pixels, of each distinct timestamp in a
+	// static visualization, and staticMaxWidth is the most that the
+	// timeline can take up, with the unit shrinking to fit.
+	staticUnit      = 60
+	staticMaxWidth  = 4000
+	staticLeft      = 60 // the least space for lane labels
+	staticRowHeight = 24
+	staticBoxHeight = 18
+)
+
+const staticCSS = `
+html {
+  font-family: Helvetica, Arial, sans-serif;
+  font-size: var(--font-size);
+  background-color: var(--background);
+  color: var(--text);
+}
+text {
+  dominant-baseline: middle;
+  fill: var(--text);
+}
+.op {
+  fill: var(--operation);
+  stroke: var(--border);
+}
+.illegal {
+  stroke: var(--illegal-linearization);
+  stroke-width: 2;
+}
+.annotation {
+  fill: var(--annotation);
+  stroke: var(--border);
+}
+.divider {
+  stroke: var(--divider);
+}
+.failing-window {
+  fill: var(--illegal-linearization);
+  opacity: 0.15;
+}
+.header {
+  font-weight: bold;
+}
+.label {
+  font-family: Menlo, Courier New, monospace;
+  font-size: 0.75rem;
+}
+`
+
+// writeStaticVisualization writes a visualization as an HTML file with a
+// static SVG image, and no JavaScript, for VisualizeOptions.Static.
+//
+// Each partition is a band of lanes, one per client, with partitions that
+// were not fully linearized first, and operations that are not in any
+// partial linearization outlined in the illegal linearization color. The
+// smallest window of a partition that isn't linearizable is shaded.
+// Operations are labeled if the label fits, and each has a tooltip. To keep
+// the file small, operations are drawn in this order until the budget runs
+// out, and the rest are counted in a note.
+func writeStaticVisualization(data VizData, output io.Writer, opts VisualizeOptions) error {
+	end := 0
+	for _, p := range data.Partitions {
+		for _, e := range p.History {
+			if e.End > end {
+				end = e.End
+			}
+		}
+	}
+	for _, a := range data.Annotations {
+		if a.End > end {
+			end = a.End
+		}
+	}
+	// mapped timestamps are 100 apart
+	unit := float64(staticUnit) / 100
+	if end > 0 && float64(end)*unit > staticMaxWidth {
+		unit = float64(staticMaxWidth) / float64(end)
+	}
+	fontSize := opts.FontSize
+	if fontSize <= 0 {
+		fontSize = 16
+	}
+	// roughly the width of a character of a label
+	charWidth := 0.75 * float64(fontSize) * 0.6
+	left := staticLeft
+	for _, p := range data.Partitions {
+		for _, e := range p.History {
+			if w := int(float64(len(staticClientName(data, e.ClientId)))*float64(fontSize)*0.6) + 10; w > left {
+				left = w
+			}
+		}
+	}
+	for _, a := range data.Annotations {
+		if w := int(float64(len(a.Tag))*float64(fontSize)*0.6) + 10; w > left {
+			left = w
+		}
+	}
+
+	var body strings.Builder
+	y := 0
+	budget := staticBudget
+	omitted := 0
+	box := func(class string, style string, start, end, lane int, label, tooltip string) {
+		x := float64(left) + float64(start)*unit
+		w := float64(end-start) * unit
+		if w < 1 {
+			w = 1
+		}
+		var b strings.Builder
+		fmt.Fprintf(&b, `<g><title>%s</title><rect class="%s" x="%.1f" y="%d" width="%.1f" height="%d" rx="2"%s/>`,
+			html.EscapeString(tooltip), class, x, lane+(staticRowHeight-staticBoxHeight)/2, w, staticBoxHeight, style)
+		if float64(len(label))*charWidth < w-4 {
+			fmt.Fprintf(&b, `<text class="label" x="%.1f" y="%d" text-anchor="middle">%s</text>`,
+				x+w/2, lane+staticRowHeight/2, html.EscapeString(label))
+		}
+		b.WriteString("</g>\n")
+		if b.Len() > budget {
+			budget = 0
+			omitted++
+			return
+		}
+		budget -= b.Len()
+		body.WriteString(b.String())
+	}
+	header := func(text string) {
+		fmt.Fprintf(&body, `<text class="header" x="0" y="%d">%s</text>`+"\n", y+staticRowHeight/2, html.EscapeString(text))
+		y += staticRowHeight
+	}
+	lanes := func(names []string) map[string]int {
+		ys := make(map[string]int)
+		for _, name := range names {
+			ys[name] = y
+			fmt.Fprintf(&body, `<text x="0" y="%d">%s</text>`+"\n", y+staticRowHeight/2, html.EscapeString(name))
+			y += staticRowHeight
+		}
+		fmt.Fprintf(&body, `<line class="divider" x1="0" y1="%d" x2="%d" y2="%d"/>`+"\n", y+2, left+int(float64(end)*unit), y+2)
+		y += 6
+		return ys
+	}
+
+	order := make([]int, len(data.Partitions))
+	linearized := make([]map[int]bool, len(data.Partitions))
+	complete := make([]bool, len(data.Partitions))
+	for i, p := range data.Partitions {
+		order[i] = i
+		linearized[i] = make(map[int]bool)
+		for _, partial := range p.PartialLinearizations {
+			for _, step := range partial {
+				linearized[i][step.Index] = true
+			}
+		}
+		complete[i] = len(linearized[i]) == len(p.History)
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return !complete[order[a]] && complete[order[b]]
+	})
+	for _, i := range order {
+		p := data.Partitions[i]
+		if len(p.History) == 0 {
+			continue
+		}
+		if budget == 0 {
+			omitted += len(p.History)
+			continue
+		}
+		if len(data.Partitions) > 1 || p.Description != "" {
+			description := p.Description
+			if description == "" {
+				description = fmt.Sprintf("Partition %d", i)
+			}
+			header(description)
+		}
+		var clients []int
+		seen := make(map[int]bool)
+		for _, e := range p.History {
+			if !seen[e.ClientId] {
+				seen[e.ClientId] = true
+				clients = append(clients, e.ClientId)
+			}
+		}
+		sort.Ints(clients)
+		names := make([]string, len(clients))
+		for j, c := range clients {
+			names[j] = staticClientName(data, c)
+		}
+		top := y
+		ys := lanes(names)
+		if w := p.FailingWindow; w != nil {
+			fmt.Fprintf(&body, `<rect class="failing-window" x="%.1f" y="%d" width="%.1f" height="%d"/>`+"\n",
+				float64(left)+float64(w.Start)*unit, top, float64(w.End-w.Start)*unit, y-6-top)
+		}
+		elements := make([]int, len(p.History))
+		for j := range elements {
+			elements[j] = j
+		}
+		sort.SliceStable(elements, func(a, b int) bool {
+			return p.History[elements[a]].Start < p.History[elements[b]].Start
+		})
+		for _, j := range elements {
+			e := p.History[j]
+			class := "op"
+			if !linearized[i][j] {
+				class += " illegal"
+			}
+			style := ""
+			if e.Color != "" {
+				style = fmt.Sprintf(` style="fill: %s"`, html.EscapeString(e.Color))
+			}
+			tooltip := fmt.Sprintf("%s\n%s - %s", e.Description, e.OriginalStart, e.OriginalEnd)
+			box(class, style, e.Start, e.End, ys[staticClientName(data, e.ClientId)], e.Description, tooltip)
+		}
+	}
+	if len(data.Annotations) > 0 && budget == 0 {
+		omitted += len(data.Annotations)
+	} else if len(data.Annotations) > 0 {
+		var names []string
+		lane := func(a VizAnnotation) string {
+			if a.Tag != "" {
+				return a.Tag
+			}
+			return staticClientName(data, a.ClientId)
+		}
+		seen := make(map[string]bool)
+		for _, a := range data.Annotations {
+			if name := lane(a); !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		header("Annotations")
+		ys := lanes(names)
+		for _, a := range data.Annotations {
+			style := ""
+			if a.BackgroundColor != "" {
+				style = fmt.Sprintf(` style="fill: %s"`, html.EscapeString(a.BackgroundColor))
+			}
+			tooltip := a.Description
+			if a.Details != "" {
+				tooltip += "\n" + a.Details
+			}
+			box("annotation", style, a.Start, a.End, ys[lane(a)], a.Description, tooltip)
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString("<!doctype html>\n<html>\n<head>\n<meta charset=\"UTF-8\" />\n<title>Porcupine</title>\n<style>\n")
+	b.WriteString(opts.css())
+	b.WriteString(staticCSS)
+	b.WriteString("</style>\n</head>\n<body>\n")
+	fmt.Fprintf(&b, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%d\" height=\"%d\">\n", left+int(float64(end)*unit)+10, y)
+	b.WriteString(body.String())
+	b.WriteString("</svg>\n")
+	if omitted > 0 {
+		fmt.Fprintf(&b, "<p>%d more operations and annotations are not shown.</p>\n", omitted)
+	}
+	b.WriteString("</body>\n</html>\n")
+	_, err := io.WriteString(output, b.String())
+	return err
+}
+
+// staticClientName returns the label of a client's lane in a static
+// visualization.
+func staticClientName(data VizData, clientId int) string {
+	if name, ok := data.ClientNames[clientId]; ok {
+		return name
+	}
+	return fmt.Sprint(clientId)
+}
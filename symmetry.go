@@ -0,0 +1,60 @@
+package porcupine
+
+import "fmt"
+
+// symmetry supports symmetry reduction: the model's Step function only sees
+// an operation's input and output, not its client, so operations with equal
+// inputs and outputs are interchangeable.
+//
+// Suppose the search tried linearizing operation A next, and is about to try
+// an interchangeable operation B that returns no earlier than A. Any
+// linearization that starts with B can be turned into one that starts with A,
+// by swapping A and B: A can go first because it has been called, and B can
+// take A's place because it returns no earlier than A. So if trying A didn't
+// lead to a linearization, trying B can't either, and the search skips it.
+type symmetry struct {
+	class []int   // for each operation, the class of interchangeable operations
+	ret   []int64 // for each operation, its return time
+}
+
+func newSymmetry(history []entry) *symmetry {
+	n := len(history) / 2
+	s := &symmetry{class: make([]int, n), ret: make([]int64, n)}
+	inputs := make([]interface{}, n)
+	outputs := make([]interface{}, n)
+	for _, e := range history {
+		if e.kind == callEntry {
+			inputs[e.id] = e.value
+		} else {
+			outputs[e.id] = e.value
+			s.ret[e.id] = e.time
+		}
+	}
+	classes := make(map[string]int)
+	for id := 0; id < n; id++ {
+		key := fmt.Sprintf("%#v\x00%#v", inputs[id], outputs[id])
+		class, ok := classes[key]
+		if !ok {
+			class = len(classes)
+			classes[key] = class
+		}
+		s.class[id] = class
+	}
+	return s
+}
+
+// redundant reports whether trying to linearize the given call next is
+// redundant, because the search has already tried an interchangeable call
+// that returns no later. The calls that were tried are the ones between head
+// and call. It is false on a nil receiver.
+func (s *symmetry) redundant(head, call *node) bool {
+	if s == nil {
+		return false
+	}
+	for n := head.next; n != call; n = n.next {
+		if s.class[n.id] == s.class[call.id] && s.ret[n.id] <= s.ret[call.id] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,176 @@
+package porcupine
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// traceEvent is an event in the Chrome trace event format, which is read by
+// Perfetto and about:tracing.
+type traceEvent struct {
+	Name     string                 `json:"name"`
+	Category string                 `json:"cat,omitempty"`
+	Phase    string                 `json:"ph"`
+	Scope    string                 `json:"s,omitempty"` // of instant events
+	Time     float64                `json:"ts"`
+	Duration *float64               `json:"dur,omitempty"`
+	Pid      int                    `json:"pid"`
+	Tid      int                    `json:"tid"`
+	Args     map[string]interface{} `json:"args,omitempty"`
+}
+
+type traceFile struct {
+	TraceEvents     []traceEvent `json:"traceEvents"`
+	DisplayTimeUnit string       `json:"displayTimeUnit"`
+}
+
+// WriteChromeTrace writes a history as JSON in the Chrome trace event format,
+// which can be opened in Perfetto (https://ui.perfetto.dev) or about:tracing,
+// with one track per client and one slice per operation. Slices are named
+// with the model's DescribeOperation.
+//
+// Call and return times are taken to be in nanoseconds, as they are for
+// histories from a [Recorder], and are converted to the format's
+// microseconds. The raw times are included in the slice's arguments.
+func WriteChromeTrace(w io.Writer, model Model, history []Operation) error {
+	model = fillDefault(model)
+	events := traceOperations(model, history, nil)
+	return json.NewEncoder(w).Encode(traceFile{TraceEvents: events, DisplayTimeUnit: "ns"})
+}
+
+// WriteLinearizationTrace is like [WriteChromeTrace], but writes the history
+// from the result of a verbose check, e.g., [CheckOperationsVerbose], along
+// with its linearization, so that it can be overlaid in Perfetto with
+// system-level traces from the same run.
+//
+// Besides the clients' tracks, the trace has a "linear order" track, with an
+// instant event at each operation's linearization point. For each partition,
+// the longest partial linearization is used, and each operation is placed at
+// the earliest time consistent with the linearization order: its call, or
+// the linearization point of the operation before it, whichever is later.
+// Operations left out of the linearization have "linearized" set to false in
+// their slice's arguments.
+func WriteLinearizationTrace(w io.Writer, model Model, info LinearizationInfo) error {
+	model = fillDefault(model)
+	var history []Operation
+	linearized := make(map[int]bool)
+	type point struct {
+		op   Operation
+		time int64
+	}
+	var points []point
+	for p, entries := range info.history {
+		ops := entryOperations(entries)
+		var longest []int
+		for _, partial := range info.partialLinearizations[p] {
+			if len(partial) > len(longest) {
+				longest = partial
+			}
+		}
+		var t int64
+		for i, id := range longest {
+			op := ops[id]
+			if i == 0 || op.Call > t {
+				t = op.Call
+			}
+			points = append(points, point{op, t})
+			linearized[len(history)+id] = true
+		}
+		history = append(history, ops...)
+	}
+	events := traceOperations(model, history, linearized)
+	events = append(events, traceEvent{
+		Name:  "thread_name",
+		Phase: "M",
+		Tid:   traceLinearOrderTid,
+		Args:  map[string]interface{}{"name": "linear order"},
+	}, traceEvent{
+		Name:  "thread_sort_index",
+		Phase: "M",
+		Tid:   traceLinearOrderTid,
+		Args:  map[string]interface{}{"sort_index": traceLinearOrderTid},
+	})
+	// keep points at the same time in linearization order
+	sort.SliceStable(points, func(i, j int) bool {
+		return points[i].time < points[j].time
+	})
+	for i, pt := range points {
+		args := map[string]interface{}{
+			"order":  i,
+			"client": pt.op.ClientId,
+			"call":   pt.op.Call,
+			"return": pt.op.Return,
+		}
+		events = append(events, traceEvent{
+			Name:     model.DescribeOperation(pt.op.Input, pt.op.Output),
+			Category: "linearization",
+			Phase:    "i",
+			Scope:    "t",
+			Time:     float64(pt.time) / 1000,
+			Tid:      traceLinearOrderTid,
+			Args:     args,
+		})
+	}
+	return json.NewEncoder(w).Encode(traceFile{TraceEvents: events, DisplayTimeUnit: "ns"})
+}
+
+// traceLinearOrderTid is the thread ID of the linear order track, which
+// can't collide with a client's, because client IDs are non-negative.
+const traceLinearOrderTid = -1
+
+// traceOperations returns the trace events for a history: the process and
+// client tracks, and a slice per operation. If linearized is non-nil, each
+// slice's arguments say whether the operation, by its index in the history,
+// was linearized.
+func traceOperations(model Model, history []Operation, linearized map[int]bool) []traceEvent {
+	name := model.Name
+	if name == "" {
+		name = "porcupine"
+	}
+	events := []traceEvent{{
+		Name:  "process_name",
+		Phase: "M",
+		Args:  map[string]interface{}{"name": name},
+	}}
+	clients := make(map[int]bool)
+	for _, op := range history {
+		clients[op.ClientId] = true
+	}
+	ids := make([]int, 0, len(clients))
+	for id := range clients {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	for _, id := range ids {
+		events = append(events, traceEvent{
+			Name:  "thread_name",
+			Phase: "M",
+			Tid:   id,
+			Args:  map[string]interface{}{"name": fmt.Sprintf("client %d", id)},
+		}, traceEvent{
+			Name:  "thread_sort_index",
+			Phase: "M",
+			Tid:   id,
+			Args:  map[string]interface{}{"sort_index": id},
+		})
+	}
+	for i, op := range history {
+		duration := float64(op.Return-op.Call) / 1000
+		args := map[string]interface{}{"call": op.Call, "return": op.Return}
+		if linearized != nil {
+			args["linearized"] = linearized[i]
+		}
+		events = append(events, traceEvent{
+			Name:     model.DescribeOperation(op.Input, op.Output),
+			Category: "operation",
+			Phase:    "X",
+			Time:     float64(op.Call) / 1000,
+			Duration: &duration,
+			Tid:      op.ClientId,
+			Args:     args,
+		})
+	}
+	return events
+}
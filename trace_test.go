@@ -0,0 +1,104 @@
+package porcupine
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestWriteChromeTrace(t *testing.T) {
+	ops := []Operation{
+		{1, kvInput{op: 1, key: "x", value: "a"}, 0, kvOutput{}, 1500},
+		{0, kvInput{op: 0, key: "x"}, 1000, kvOutput{"a"}, 3000},
+	}
+	var buf bytes.Buffer
+	if err := WriteChromeTrace(&buf, kvModel, ops); err != nil {
+		t.Fatal(err)
+	}
+	var trace struct {
+		TraceEvents []struct {
+			Name string
+			Ph   string
+			Ts   float64
+			Dur  float64
+			Tid  int
+			Args map[string]interface{}
+		}
+		DisplayTimeUnit string
+	}
+	if err := json.Unmarshal(buf.Bytes(), &trace); err != nil {
+		t.Fatal(err)
+	}
+	var threads []string
+	var slices int
+	for _, e := range trace.TraceEvents {
+		switch {
+		case e.Ph == "M" && e.Name == "thread_name":
+			threads = append(threads, e.Args["name"].(string))
+		case e.Ph == "X":
+			slices++
+			if e.Tid == 0 && (e.Name != "get('x') -> 'a'" || e.Ts != 1 || e.Dur != 2 || e.Args["call"] != float64(1000)) {
+				t.Fatalf("unexpected event %+v", e)
+			}
+		}
+	}
+	if len(threads) != 2 || threads[0] != "client 0" || threads[1] != "client 1" {
+		t.Fatalf("unexpected threads %v", threads)
+	}
+	if slices != 2 {
+		t.Fatalf("expected 2 slices, got %d", slices)
+	}
+}
+
+func TestWriteLinearizationTrace(t *testing.T) {
+	ops := []Operation{
+		{0, registerInput{false, 1}, 0, 0, 100},
+		{1, registerInput{true, 0}, 25, 1, 75},
+		{2, registerInput{true, 0}, 110, 0, 150},
+	}
+	res, info := CheckOperationsVerbose(registerModel, ops, 0)
+	if res != Illegal {
+		t.Fatalf("expected output %v, got output %v", Illegal, res)
+	}
+	var buf bytes.Buffer
+	if err := WriteLinearizationTrace(&buf, registerModel, info); err != nil {
+		t.Fatal(err)
+	}
+	var trace struct {
+		TraceEvents []struct {
+			Name string
+			Ph   string
+			Ts   float64
+			Tid  int
+			Args map[string]interface{}
+		}
+	}
+	if err := json.Unmarshal(buf.Bytes(), &trace); err != nil {
+		t.Fatal(err)
+	}
+	var order []string
+	var times []float64
+	linearized := make(map[int]bool)
+	for _, e := range trace.TraceEvents {
+		switch e.Ph {
+		case "i":
+			if e.Tid != traceLinearOrderTid {
+				t.Fatalf("unexpected event %+v", e)
+			}
+			order = append(order, e.Name)
+			times = append(times, e.Ts)
+		case "X":
+			linearized[e.Tid] = e.Args["linearized"].(bool)
+		}
+	}
+	// the longest partial linearization is put(1), get() -> 1
+	if len(order) != 2 || order[0] != "put('1')" || order[1] != "get() -> '1'" {
+		t.Fatalf("unexpected linear order %v", order)
+	}
+	if times[0] != 0 || times[1] != 0.025 {
+		t.Fatalf("unexpected linearization points %v", times)
+	}
+	if !linearized[0] || !linearized[1] || linearized[2] {
+		t.Fatalf("unexpected linearized operations %v", linearized)
+	}
+}
@@ -0,0 +1,179 @@
+package porcupine
+
+import "math"
+
+// A TxnOutcome is how a transaction ended.
+type TxnOutcome string
+
+const (
+	TxnCommitted TxnOutcome = "Committed"
+	TxnAborted   TxnOutcome = "Aborted"
+	// the client never learned whether the commit succeeded, e.g., because
+	// it timed out
+	TxnUnknown TxnOutcome = "Unknown"
+)
+
+// A TxnStep is a single step of a transaction, with the input and output of
+// the step like those of an Operation.
+type TxnStep struct {
+	Input  interface{}
+	Output interface{}
+}
+
+// A TxnOperation is a transaction in a history: a sequence of steps run by a
+// client between its begin, at Call, and its commit or abort, which returned
+// at Return.
+type TxnOperation struct {
+	ClientId int
+	Steps    []TxnStep
+	Outcome  TxnOutcome
+	Call     int64 // invocation time of the begin
+	Return   int64 // response time of the commit or abort; ignored if the outcome is unknown
+}
+
+// TxnOperations converts transactions to operations, one per transaction,
+// for [CheckTransactions] and [CheckSerializability], where the input of each
+// operation is a []interface{} of the inputs of its steps, and the output is
+// a []interface{} of their outputs. Aborted transactions have no effects, so
+// they are left out. A transaction with an unknown outcome might have
+// committed at any point after it began, so its operation never returns;
+// CheckTransactions and CheckSerializability take such an operation to have
+// either committed or aborted, whichever makes the history serializable.
+func TxnOperations(txns []TxnOperation) []Operation {
+	var ops []Operation
+	for _, txn := range txns {
+		if txn.Outcome == TxnAborted {
+			continue
+		}
+		inputs := make([]interface{}, len(txn.Steps))
+		outputs := make([]interface{}, len(txn.Steps))
+		for i, step := range txn.Steps {
+			inputs[i], outputs[i] = step.Input, step.Output
+		}
+		ops = append(ops, Operation{
+			ClientId: txn.ClientId,
+			Input:    inputs,
+			Call:     txn.Call,
+			Output:   outputs,
+			Return:   txnReturn(txn),
+		})
+	}
+	return ops
+}
+
+// FlattenTxnOperations converts transactions to operations, one per step,
+// for checking the linearizability of the steps with a model of single steps,
+// such as one partitioned by key. Each step spans the whole transaction,
+// because a client can't tell when a step took effect before the commit
+// returned. Like [TxnOperations], it leaves out aborted transactions, and
+// the steps of a transaction with an unknown outcome never return. A model
+// of single steps can't tell that they commit or abort together, so they are
+// checked as if the transaction committed.
+func FlattenTxnOperations(txns []TxnOperation) []Operation {
+	var ops []Operation
+	for _, txn := range txns {
+		if txn.Outcome == TxnAborted {
+			continue
+		}
+		for _, step := range txn.Steps {
+			ops = append(ops, Operation{
+				ClientId: txn.ClientId,
+				Input:    step.Input,
+				Call:     txn.Call,
+				Output:   step.Output,
+				Return:   txnReturn(txn),
+			})
+		}
+	}
+	return ops
+}
+
+func txnReturn(txn TxnOperation) int64 {
+	if txn.Outcome == TxnUnknown {
+		return math.MaxInt64
+	}
+	return txn.Return
+}
+
+// txnMaybe marks the input of a transaction with an unknown outcome, which
+// may or may not have taken effect.
+type txnMaybe struct {
+	input interface{}
+}
+
+func unmarkInput(input interface{}) interface{} {
+	if in, ok := input.(txnMaybe); ok {
+		return in.input
+	}
+	return input
+}
+
+// maybeCommitted prepares a history of transactions from [TxnOperations] to
+// be checked with the transactions with unknown outcomes, whose operations
+// never return, either committed or aborted. It marks their inputs, and
+// converts the model to one whose states are the sets of states that the
+// transactions' outcomes could lead to, like [NondeterministicModel.ToModel].
+// If no transaction's outcome is unknown, it returns the model and history
+// as they are.
+func maybeCommitted(model Model, history []Operation) (Model, []Operation) {
+	var marked []Operation
+	for i, op := range history {
+		if op.Return != math.MaxInt64 {
+			continue
+		}
+		if marked == nil {
+			marked = append([]Operation(nil), history...)
+		}
+		marked[i].Input = txnMaybe{op.Input}
+	}
+	if marked == nil {
+		return model, history
+	}
+	model = fillDefault(model)
+	nm := NondeterministicModel{
+		Name: model.Name,
+		// the model's partition function doesn't know about the marks,
+		// so it gets the plain inputs, and they are marked again after
+		Partition: func(history []Operation) [][]Operation {
+			unmarked := make([]Operation, len(history))
+			for i, op := range history {
+				op.Input = unmarkInput(op.Input)
+				unmarked[i] = op
+			}
+			partitions := model.Partition(unmarked)
+			for _, partition := range partitions {
+				for i, op := range partition {
+					if op.Return == math.MaxInt64 {
+						partition[i].Input = txnMaybe{op.Input}
+					}
+				}
+			}
+			return partitions
+		},
+		Init: func() []interface{} {
+			return []interface{}{model.Init()}
+		},
+		Step: func(state, input, output interface{}) []interface{} {
+			in, maybe := input.(txnMaybe)
+			if maybe {
+				input = in.input
+			}
+			var next []interface{}
+			if ok, newState := model.Step(state, input, output); ok {
+				next = append(next, newState)
+			}
+			if maybe {
+				// the transaction aborted
+				next = append(next, state)
+			}
+			return next
+		},
+		Equal:         model.Equal,
+		Hash:          model.Hash,
+		DescribeState: model.DescribeState,
+		DescribeOperation: func(input, output interface{}) string {
+			return model.DescribeOperation(unmarkInput(input), output)
+		},
+	}
+	return nm.ToModel(), marked
+}
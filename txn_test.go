@@ -0,0 +1,90 @@
+package porcupine
+
+import "testing"
+
+// kvTxnModel runs each transaction's steps with kvNoPartitionModel.
+var kvTxnModel = Model{
+	Init: kvNoPartitionModel.Init,
+	Step: func(state, input, output interface{}) (bool, interface{}) {
+		outputs := output.([]interface{})
+		for i, in := range input.([]interface{}) {
+			var ok bool
+			ok, state = kvNoPartitionModel.Step(state, in, outputs[i])
+			if !ok {
+				return false, state
+			}
+		}
+		return true, state
+	},
+	Equal: kvNoPartitionModel.Equal,
+}
+
+func TestTxnOperations(t *testing.T) {
+	put := func(key, value string) TxnStep {
+		return TxnStep{kvInput{op: 1, key: key, value: value}, kvOutput{}}
+	}
+	get := func(key, value string) TxnStep {
+		return TxnStep{kvInput{op: 0, key: key}, kvOutput{value}}
+	}
+	txns := []TxnOperation{
+		{ClientId: 0, Steps: []TxnStep{put("x", "1"), put("y", "1")}, Outcome: TxnCommitted, Call: 0, Return: 10},
+		// aborted, so nobody may see its write
+		{ClientId: 1, Steps: []TxnStep{put("x", "2")}, Outcome: TxnAborted, Call: 20, Return: 30},
+		// its commit timed out, but it took effect
+		{ClientId: 2, Steps: []TxnStep{put("y", "3")}, Outcome: TxnUnknown, Call: 20},
+		{ClientId: 3, Steps: []TxnStep{get("x", "1"), get("y", "3")}, Outcome: TxnCommitted, Call: 40, Return: 50},
+	}
+	ops := TxnOperations(txns)
+	if len(ops) != 3 {
+		t.Fatalf("expected the aborted transaction to be left out, got %d operations", len(ops))
+	}
+	if res := CheckTransactions(kvTxnModel, ops); res.StrictlySerializable != Ok {
+		t.Fatalf("expected strictly serializable, got %+v", res)
+	}
+	flat := FlattenTxnOperations(txns)
+	if len(flat) != 5 {
+		t.Fatalf("expected an operation per step, got %d operations", len(flat))
+	}
+	if !CheckOperations(kvModel, flat) {
+		t.Fatal("expected steps to be linearizable")
+	}
+	// reading the aborted write
+	txns[3].Steps[0] = get("x", "2")
+	if res := CheckTransactions(kvTxnModel, TxnOperations(txns)); res.Serializable != Illegal {
+		t.Fatalf("expected not serializable, got %+v", res)
+	}
+	if CheckOperations(kvModel, FlattenTxnOperations(txns)) {
+		t.Fatal("expected steps not to be linearizable")
+	}
+}
+
+func TestTxnOperationsUnknownAborted(t *testing.T) {
+	put := func(key, value string) TxnStep {
+		return TxnStep{kvInput{op: 1, key: key, value: value}, kvOutput{}}
+	}
+	get := func(key, value string) TxnStep {
+		return TxnStep{kvInput{op: 0, key: key}, kvOutput{value}}
+	}
+	// both transactions read x before writing it, so they can't both have
+	// committed: the one whose commit timed out must have aborted
+	txns := []TxnOperation{
+		{ClientId: 0, Steps: []TxnStep{get("x", ""), put("x", "1")}, Outcome: TxnUnknown, Call: 0},
+		{ClientId: 1, Steps: []TxnStep{get("x", ""), put("x", "2")}, Outcome: TxnCommitted, Call: 0, Return: 10},
+		{ClientId: 2, Steps: []TxnStep{get("x", "2")}, Outcome: TxnCommitted, Call: 20, Return: 30},
+	}
+	ops := TxnOperations(txns)
+	if res := CheckTransactions(kvTxnModel, ops); res.StrictlySerializable != Ok {
+		t.Fatalf("expected strictly serializable, got %+v", res)
+	}
+	if res := CheckSerializability(kvTxnModel, ops); res != Ok {
+		t.Fatalf("expected serializable, got %v", res)
+	}
+	if ops[0].Input.([]interface{})[1] != txns[0].Steps[1].Input {
+		t.Fatal("expected the operations to be unchanged")
+	}
+	// reading the write of the transaction that must have aborted
+	txns[2].Steps[0] = get("x", "1")
+	if res := CheckTransactions(kvTxnModel, TxnOperations(txns)); res.Serializable != Illegal {
+		t.Fatalf("expected not serializable, got %+v", res)
+	}
+}
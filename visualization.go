@@ -3,22 +3,52 @@ package porcupine
 import (
 	"embed"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
+	"math"
 	"os"
 	"sort"
+	"strconv"
+	"strings"
+	"time"
 )
 
-type historyElement struct {
+// A VizOperation is an operation in a [VizPartition]'s history. Start and
+// End are positions on the visualization's timeline, where distinct
+// timestamps of the history are 100 apart, in order; OriginalStart and
+// OriginalEnd are the timestamps themselves, as text.
+type VizOperation struct {
 	ClientId      int
 	Start         int
 	OriginalStart string
 	End           int
 	OriginalEnd   string
-	Description   string
+	// from the model's DescribeOperation
+	Description string
+	Tags        map[string]string `json:",omitempty"`
+	SessionId   string            `json:",omitempty"` // the SessionTag tag
+	// with [LinearizationInfo.ShowPlacement]
+	Window *VizWindow `json:",omitempty"`
+	// from VisualizeOptions.ColorOperation and Codec
+	Color  string          `json:",omitempty"`
+	Input  json.RawMessage `json:",omitempty"`
+	Output json.RawMessage `json:",omitempty"`
 }
 
-type annotation struct {
+// A VizWindow is a span of the timeline, in positions as in [VizOperation],
+// e.g., the window in which an operation's linearization point can fall.
+type VizWindow struct {
+	Start         int
+	OriginalStart string
+	End           int
+	OriginalEnd   string
+}
+
+// A VizAnnotation is an [Annotation] as it's visualized, with Start and End
+// positions on the timeline, as in [VizOperation].
+type VizAnnotation struct {
 	ClientId        int
 	Tag             string
 	Start           int
@@ -30,22 +60,38 @@ type annotation struct {
 	BackgroundColor string
 }
 
-type linearizationStep struct {
+// A VizLinearizationStep is a step of a partial linearization: the index of
+// an operation in the partition's history, and the model's description of
+// the state after it.
+type VizLinearizationStep struct {
 	Index            int
 	StateDescription string
 }
 
-type partialLinearization = []linearizationStep
-
-type partitionVisualizationData struct {
-	History               []historyElement
-	PartialLinearizations []partialLinearization
-	Largest               map[int]int
+// A VizPartition is a partition of a [VizData]'s history.
+type VizPartition struct {
+	// from the model's DescribePartition, if it has one
+	Description  string `json:",omitempty"`
+	InitialState string
+	History      []VizOperation
+	// the partial linearizations that the checker found, longest first
+	PartialLinearizations [][]VizLinearizationStep
+	// for each operation that is in a partial linearization, the index of
+	// the longest one that it's in
+	Largest map[int]int
+	// if the partition is not linearizable, the smallest window of it that
+	// isn't, which is shaded; see failingWindow
+	FailingWindow *VizWindow `json:",omitempty"`
 }
 
-type visualizationData struct {
-	Partitions  []partitionVisualizationData
-	Annotations []annotation
+// VizData is the data that a visualization shows, as returned by
+// [VisualizeData]. It's embedded in the HTML of visualizations as JSON, with
+// the field names as keys.
+type VizData struct {
+	Partitions  []VizPartition
+	Annotations []VizAnnotation
+	ClientNames map[int]string `json:",omitempty"`
+	TimeAxis    bool           `json:",omitempty"`
 }
 
 // Annotations to add to histories.
@@ -96,6 +142,54 @@ func (li *LinearizationInfo) AddAnnotations(annotations []Annotation) {
 	}
 }
 
+// A PayloadAnnotation is an [Annotation] that carries a payload, such as a
+// fault injected by a nemesis or an excerpt from a server's log, which
+// describes the annotation when it has no Description or Details of its own.
+// Use [VisualizeWithAnnotations] to add them to a visualization.
+type PayloadAnnotation struct {
+	Annotation
+	Payload interface{}
+}
+
+// VisualizeWithAnnotations is like [Visualize], but adds annotations with
+// payloads to the visualization, e.g., to plot when faults were injected as
+// spans on the timeline, and when log messages were written as points in time.
+//
+// The describe function turns an annotation's payload into the text shown in
+// the visualization and the text shown in its tooltip, which fill in the
+// annotation's Description and Details if they are empty. If describe is nil,
+// payloads are described using the "%v" format specifier. The info is not
+// modified.
+func VisualizeWithAnnotations(model Model, info LinearizationInfo, output io.Writer, annotations []PayloadAnnotation, describe func(payload interface{}) (description, details string)) error {
+	if describe == nil {
+		describe = defaultDescribePayload
+	}
+	added := make([]Annotation, len(annotations))
+	for i, a := range annotations {
+		added[i] = a.Annotation
+		if a.Payload == nil || (a.Description != "" && a.Details != "") {
+			continue
+		}
+		description, details := describe(a.Payload)
+		if added[i].Description == "" {
+			added[i].Description = description
+		}
+		if added[i].Details == "" {
+			added[i].Details = details
+		}
+	}
+	// don't append to the caller's annotations
+	info.annotations = info.annotations[:len(info.annotations):len(info.annotations)]
+	info.AddAnnotations(added)
+	return Visualize(model, info, output)
+}
+
+// defaultDescribePayload is a fallback to describe the payload of an
+// annotation. It renders the payload using the "%v" format specifier.
+func defaultDescribePayload(payload interface{}) (string, string) {
+	return fmt.Sprintf("%v", payload), ""
+}
+
 // timestampMapping applies a monotonic map to compress timestamps.
 //
 // This function applies a monotonic map to timestamps so that the encoding of
@@ -112,6 +206,8 @@ func timestampMapping(info LinearizationInfo) map[int64]int {
 			allTimestamps[elem.time] = struct{}{}
 		}
 	}
+	// window bounds are always call or return times, so they are included
+	// above
 	for _, elem := range info.annotations {
 		allTimestamps[elem.Start] = struct{}{}
 		allTimestamps[elem.End] = struct{}{}
@@ -134,43 +230,57 @@ func timestampMapping(info LinearizationInfo) map[int64]int {
 	return mapping
 }
 
-func computeVisualizationData(model Model, info LinearizationInfo) visualizationData {
-	timeMap := timestampMapping(info)
+func computeVisualizationData(model Model, info LinearizationInfo) VizData {
+	return computeMappedVisualizationData(model, info, timestampMapping(info))
+}
+
+// computeMappedVisualizationData is computeVisualizationData with the given
+// timestamp mapping, which may be shared with other histories.
+func computeMappedVisualizationData(model Model, info LinearizationInfo, timeMap map[int64]int) VizData {
 	model = fillDefault(model)
-	partitions := make([]partitionVisualizationData, len(info.history))
+	partitions := make([]VizPartition, len(info.history))
 	for partition := 0; partition < len(info.history); partition++ {
 		// history
 		n := len(info.history[partition]) / 2
-		history := make([]historyElement, n)
+		history := make([]VizOperation, n)
 		callValue := make(map[int]interface{})
 		returnValue := make(map[int]interface{})
+		ops := make([]Operation, n)
 		for _, elem := range info.history[partition] {
 			switch elem.kind {
 			case callEntry:
+				ops[elem.id] = Operation{ClientId: elem.clientId, Input: elem.value, Call: elem.time}
 				history[elem.id].ClientId = elem.clientId
+				history[elem.id].SessionId = elem.tags[SessionTag]
 				history[elem.id].Start = timeMap[elem.time]
 				history[elem.id].OriginalStart = fmt.Sprintf("%d", elem.time)
 				callValue[elem.id] = elem.value
 			case returnEntry:
 				history[elem.id].End = timeMap[elem.time]
 				history[elem.id].OriginalEnd = fmt.Sprintf("%d", elem.time)
-				history[elem.id].Description = model.DescribeOperation(callValue[elem.id], elem.value)
+				history[elem.id].Description = describeOperation(model, callValue[elem.id], elem.value, elem.tags)
+				history[elem.id].Tags = elem.tags
 				returnValue[elem.id] = elem.value
+				ops[elem.id].Output = elem.value
+				ops[elem.id].Return = elem.time
 			}
-			// historyElement.Annotation defaults to false, so we
+			// VizOperation.Annotation defaults to false, so we
 			// don't need to explicitly set it here; all of these
 			// are non-annotation elements
 		}
+		if info.showPlacement {
+			addPlacementWindows(history, info.history[partition], info.partialLinearizations[partition], timeMap)
+		}
 		// partial linearizations
 		largestIndex := make(map[int]int)
 		largestSize := make(map[int]int)
-		linearizations := make([]partialLinearization, len(info.partialLinearizations[partition]))
+		linearizations := make([][]VizLinearizationStep, len(info.partialLinearizations[partition]))
 		partials := info.partialLinearizations[partition]
 		sort.Slice(partials, func(i, j int) bool {
 			return len(partials[i]) > len(partials[j])
 		})
 		for i, partial := range partials {
-			linearization := make(partialLinearization, len(partial))
+			linearization := make([]VizLinearizationStep, len(partial))
 			state := model.Init()
 			for j, histId := range partial {
 				var ok bool
@@ -179,7 +289,7 @@ func computeVisualizationData(model Model, info LinearizationInfo) visualization
 					panic("valid partial linearization returned non-ok result from model step")
 				}
 				stateDesc := model.DescribeState(state)
-				linearization[j] = linearizationStep{histId, stateDesc}
+				linearization[j] = VizLinearizationStep{histId, stateDesc}
 				if largestSize[histId] < len(partial) {
 					largestSize[histId] = len(partial)
 					largestIndex[histId] = i
@@ -187,15 +297,34 @@ func computeVisualizationData(model Model, info LinearizationInfo) visualization
 			}
 			linearizations[i] = linearization
 		}
-		partitions[partition] = partitionVisualizationData{
+		var failing *VizWindow
+		timedOut := partition < len(info.timedOut) && info.timedOut[partition]
+		if n > 0 && !timedOut && (len(partials) == 0 || len(partials[0]) < n) {
+			if f, res := failingWindow(model, info.history[partition], failingWindowTimeout); res == Illegal {
+				failing = &VizWindow{
+					Start:         timeMap[f.start],
+					OriginalStart: fmt.Sprintf("%d", f.start),
+					End:           timeMap[f.end],
+					OriginalEnd:   fmt.Sprintf("%d", f.end),
+				}
+			}
+		}
+		var description string
+		if model.DescribePartition != nil {
+			description = model.DescribePartition(ops)
+		}
+		partitions[partition] = VizPartition{
+			Description:           description,
+			InitialState:          model.DescribeState(model.Init()),
 			History:               history,
 			PartialLinearizations: linearizations,
 			Largest:               largestIndex,
+			FailingWindow:         failing,
 		}
 	}
-	annotations := make([]annotation, len(info.annotations))
+	annotations := make([]VizAnnotation, len(info.annotations))
 	for i, elem := range info.annotations {
-		annotations[i] = annotation{
+		annotations[i] = VizAnnotation{
 			ClientId:        elem.ClientId,
 			Tag:             elem.Tag,
 			Start:           timeMap[elem.Start],
@@ -207,7 +336,7 @@ func computeVisualizationData(model Model, info LinearizationInfo) visualization
 			BackgroundColor: elem.BackgroundColor,
 		}
 	}
-	data := visualizationData{
+	data := VizData{
 		Partitions:  partitions,
 		Annotations: annotations,
 	}
@@ -215,6 +344,219 @@ func computeVisualizationData(model Model, info LinearizationInfo) visualization
 	return data
 }
 
+func addPlacementWindows(history []VizOperation, entries []entry, partials [][]int, timeMap map[int64]int) {
+	windows, order, ok := linearizationWindows(entries, partials)
+	if !ok {
+		return
+	}
+	for i, w := range windows {
+		history[order[i]].Window = &VizWindow{
+			Start:         timeMap[w.Earliest],
+			OriginalStart: fmt.Sprintf("%d", w.Earliest),
+			End:           timeMap[w.Latest],
+			OriginalEnd:   fmt.Sprintf("%d", w.Latest),
+		}
+	}
+}
+
+// A Theme is a base color scheme for visualizations.
+type Theme int
+
+const (
+	LightTheme Theme = iota
+	DarkTheme
+)
+
+// A Palette sets the colors of a visualization. Each is a CSS color, e.g.,
+// "#efaefc"; colors left empty come from the [Theme].
+type Palette struct {
+	Background           string
+	Text                 string
+	Operation            string // the boxes of history elements
+	Annotation           string // the default for client annotations
+	Linearization        string // linearization points and lines
+	IllegalLinearization string // illegal linearization points
+}
+
+// VisualizeOptions configures the look of a visualization, for
+// [VisualizeWithOptions]. The zero value is the default look.
+type VisualizeOptions struct {
+	Theme    Theme
+	FontSize int // in pixels; 0 means 16
+	Palette  Palette
+	// Optional: the CSS color of an operation's box, e.g., to tell writes,
+	// reads, and failed operations apart. Returning "" uses the palette's
+	// Operation color.
+	ColorOperation func(input, output interface{}) string
+	// Optional: encode each operation's input and output into the
+	// visualization, as the data-input and data-output attributes of its
+	// box, so that browser devtools and scripts can get the exact values
+	// without the original history.
+	Codec Codec
+	// Write a static image of the history instead, with no JavaScript, in a
+	// file of around 100KB at most, e.g., for archiving the results of
+	// many CI runs. It shows each operation, with its description as a
+	// tooltip, and outlines those that are in no partial linearization, but
+	// doesn't show linearizations or states. For large histories, it shows
+	// partitions that could not be linearized first, and leaves out the
+	// operations that don't fit.
+	Static bool
+	// Optional: names for clients, e.g., "node-3/worker-7", to label their
+	// rows with, rather than their IDs.
+	ClientNames map[int]string
+	// How to show the history's timestamps, in tooltips and on a time axis
+	// at the top, which is only drawn for formats other than RawTime.
+	TimeFormat TimeFormat
+	// Optional: files that replace those of the front-end, e.g., to brand,
+	// extend, or replace it: index.html, index.css, and index.js, with the
+	// built-in ones, from DefaultVisualizationTemplate, used for any that
+	// are missing. The index.html file is a format string, as for
+	// fmt.Sprintf, with a %s verb each for the CSS, the JavaScript, and the
+	// visualization's data, a [VizData] as JSON, in that order. Static
+	// visualizations don't use a template.
+	Template fs.FS
+}
+
+// A TimeFormat is how a visualization shows the history's timestamps. The
+// horizontal positions of operations only show their order, so showing the
+// timestamps helps correlate the visualization with, e.g., server logs.
+type TimeFormat int
+
+const (
+	// as integers
+	RawTime TimeFormat = iota
+	// as RFC 3339 times, in UTC, for timestamps that are Unix times in
+	// nanoseconds, e.g., from time.Now().UnixNano()
+	WallClockTime
+	// as durations since the earliest timestamp in the history, e.g.,
+	// "1.5ms", for timestamps in nanoseconds
+	RelativeTime
+)
+
+// formatTimes rewrites the original timestamps in the visualization data in
+// the given format.
+func formatTimes(data VizData, info LinearizationInfo, format TimeFormat) {
+	var start int64 = math.MaxInt64
+	for _, partition := range info.history {
+		for _, e := range partition {
+			if e.time < start {
+				start = e.time
+			}
+		}
+	}
+	formatTime := func(s *string) {
+		t, err := strconv.ParseInt(*s, 10, 64)
+		if err != nil {
+			return
+		}
+		switch format {
+		case WallClockTime:
+			*s = time.Unix(0, t).UTC().Format(time.RFC3339Nano)
+		case RelativeTime:
+			*s = time.Duration(t - start).String()
+		}
+	}
+	for _, partition := range data.Partitions {
+		for i := range partition.History {
+			e := &partition.History[i]
+			formatTime(&e.OriginalStart)
+			formatTime(&e.OriginalEnd)
+			if e.Window != nil {
+				formatTime(&e.Window.OriginalStart)
+				formatTime(&e.Window.OriginalEnd)
+			}
+		}
+		if w := partition.FailingWindow; w != nil {
+			formatTime(&w.OriginalStart)
+			formatTime(&w.OriginalEnd)
+		}
+	}
+}
+
+// themeColors are the colors of each theme: the ones in a Palette, along with
+// some that are derived from them.
+type themeColors struct {
+	Palette
+	divider   string
+	border    string
+	placement string
+	link      string
+}
+
+var themes = map[Theme]themeColors{
+	LightTheme: {
+		Palette: Palette{
+			Background:           "#ffffff",
+			Text:                 "#000000",
+			Operation:            "#42d1f5",
+			Annotation:           "#e0e0e0",
+			Linearization:        "rgba(0, 0, 0, 0.5)",
+			IllegalLinearization: "rgba(255, 0, 0, 0.5)",
+		},
+		divider:   "#ccc",
+		border:    "#888",
+		placement: "#1a5a6b",
+		link:      "#206475",
+	},
+	DarkTheme: {
+		Palette: Palette{
+			Background:           "#1e1e1e",
+			Text:                 "#e0e0e0",
+			Operation:            "#24708a",
+			Annotation:           "#3c3c3c",
+			Linearization:        "rgba(255, 255, 255, 0.6)",
+			IllegalLinearization: "rgba(255, 80, 80, 0.7)",
+		},
+		divider:   "#444",
+		border:    "#999",
+		placement: "#a8e6f7",
+		link:      "#6cc4dc",
+	},
+}
+
+// css returns the CSS variables that index.css uses for the options' theme,
+// font size, and palette.
+func (opts VisualizeOptions) css() string {
+	colors, ok := themes[opts.Theme]
+	if !ok {
+		colors = themes[LightTheme]
+	}
+	override := func(color *string, custom string) {
+		if custom != "" {
+			*color = custom
+		}
+	}
+	override(&colors.Background, opts.Palette.Background)
+	override(&colors.Text, opts.Palette.Text)
+	override(&colors.Operation, opts.Palette.Operation)
+	override(&colors.Annotation, opts.Palette.Annotation)
+	override(&colors.Linearization, opts.Palette.Linearization)
+	override(&colors.IllegalLinearization, opts.Palette.IllegalLinearization)
+	fontSize := opts.FontSize
+	if fontSize <= 0 {
+		fontSize = 16
+	}
+	var b strings.Builder
+	b.WriteString(":root {\n")
+	for _, v := range []struct{ name, value string }{
+		{"font-size", fmt.Sprintf("%dpx", fontSize)},
+		{"background", colors.Background},
+		{"text", colors.Text},
+		{"operation", colors.Operation},
+		{"annotation", colors.Annotation},
+		{"linearization", colors.Linearization},
+		{"illegal-linearization", colors.IllegalLinearization},
+		{"divider", colors.divider},
+		{"border", colors.border},
+		{"placement", colors.placement},
+		{"link", colors.link},
+	} {
+		fmt.Fprintf(&b, "  --%s: %s;\n", v.name, v.value)
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
 // Visualize produces a visualization of a history and (partial) linearization
 // as an HTML file that can be viewed in a web browser.
 //
@@ -226,18 +568,117 @@ func computeVisualizationData(model Model, info LinearizationInfo) visualization
 // [CheckOperationsVerbose] / [CheckEventsVerbose].
 //
 // This function writes the visualization, an HTML file with embedded
-// JavaScript and data, to the given output.
+// JavaScript and data, to the given output. To change its look, e.g., to a
+// dark theme, use [VisualizeWithOptions].
 func Visualize(model Model, info LinearizationInfo, output io.Writer) error {
+	return VisualizeWithOptions(model, info, output, VisualizeOptions{})
+}
+
+// VisualizeWithOptions is like [Visualize], but with a theme, font size, and
+// colors, which are written into the HTML file, e.g., so that the
+// visualization fits into dark-themed tools it is embedded in.
+func VisualizeWithOptions(model Model, info LinearizationInfo, output io.Writer, opts VisualizeOptions) error {
+	data, err := visualizeData(model, info, opts)
+	if err != nil {
+		return err
+	}
+	if opts.Static {
+		return writeStaticVisualization(data, output, opts)
+	}
+	return writeVisualization(data, output, opts, 0)
+}
+
+// VisualizeData returns the data that [Visualize] shows, which is the same
+// as what it embeds in the HTML file, e.g., for front-ends other than the
+// built-in one, or to export it as JSON with encoding/json.
+func VisualizeData(model Model, info LinearizationInfo) (VizData, error) {
+	return visualizeData(model, info, VisualizeOptions{})
+}
+
+// visualizeData computes the data that a visualization with the given
+// options shows.
+func visualizeData(model Model, info LinearizationInfo, opts VisualizeOptions) (VizData, error) {
 	data := computeVisualizationData(model, info)
+	data.ClientNames = opts.ClientNames
+	if opts.TimeFormat != RawTime {
+		formatTimes(data, info, opts.TimeFormat)
+		data.TimeAxis = true
+	}
+	if opts.ColorOperation != nil {
+		colorOperations(data, info, opts.ColorOperation)
+	}
+	if opts.Codec != nil {
+		if err := encodeOperations(data, info, opts.Codec); err != nil {
+			return VizData{}, err
+		}
+	}
+	return data, nil
+}
+
+// encodeOperations sets the encoded inputs and outputs of the history
+// elements in the visualization data for info.
+func encodeOperations(data VizData, info LinearizationInfo, codec Codec) error {
+	for p, entries := range info.history {
+		for id, op := range entryOperations(entries) {
+			input, err := codec.EncodeInput(op.Input)
+			if err != nil {
+				return fmt.Errorf("partition %d, operation %d: input: %v", p, id, err)
+			}
+			output, err := codec.EncodeOutput(op.Output)
+			if err != nil {
+				return fmt.Errorf("partition %d, operation %d: output: %v", p, id, err)
+			}
+			data.Partitions[p].History[id].Input = input
+			data.Partitions[p].History[id].Output = output
+		}
+	}
+	return nil
+}
+
+// colorOperations sets the colors of the history elements in the
+// visualization data for info.
+func colorOperations(data VizData, info LinearizationInfo, color func(input, output interface{}) string) {
+	for p, entries := range info.history {
+		for id, op := range entryOperations(entries) {
+			data.Partitions[p].History[id].Color = color(op.Input, op.Output)
+		}
+	}
+}
+
+// writeVisualization writes the HTML file for the visualization data. If
+// refresh is positive, the page reloads itself after that long.
+func writeVisualization(data VizData, output io.Writer, opts VisualizeOptions, refresh time.Duration) error {
 	jsonData, err := json.Marshal(data)
 	if err != nil {
 		return err
 	}
-	templateB, _ := visualizationFS.ReadFile("visualization/index.html")
+	readFile := func(name string) ([]byte, error) {
+		if opts.Template != nil {
+			b, err := fs.ReadFile(opts.Template, name)
+			if !errors.Is(err, fs.ErrNotExist) {
+				return b, err
+			}
+		}
+		return fs.ReadFile(DefaultVisualizationTemplate, name)
+	}
+	templateB, err := readFile("index.html")
+	if err != nil {
+		return err
+	}
 	template := string(templateB)
-	css, _ := visualizationFS.ReadFile("visualization/index.css")
-	js, _ := visualizationFS.ReadFile("visualization/index.js")
-	_, err = fmt.Fprintf(output, template, css, js, jsonData)
+	css, err := readFile("index.css")
+	if err != nil {
+		return err
+	}
+	js, err := readFile("index.js")
+	if err != nil {
+		return err
+	}
+	if refresh > 0 {
+		// the view is kept in the URL's fragment, so it survives reloads
+		js = append(js, fmt.Sprintf("\nsetTimeout(() => window.location.reload(), %d)\n", refresh.Milliseconds())...)
+	}
+	_, err = fmt.Fprintf(output, template, opts.css()+string(css), js, jsonData)
 	if err != nil {
 		return err
 	}
@@ -247,13 +688,92 @@ func Visualize(model Model, info LinearizationInfo, output io.Writer) error {
 // VisualizePath is a wrapper around [Visualize] to write the visualization to
 // a file path.
 func VisualizePath(model Model, info LinearizationInfo, path string) error {
+	return VisualizePathWithOptions(model, info, path, VisualizeOptions{})
+}
+
+// VisualizePathWithOptions is a wrapper around [VisualizeWithOptions] to write
+// the visualization to a file path.
+func VisualizePathWithOptions(model Model, info LinearizationInfo, path string, opts VisualizeOptions) error {
 	f, err := os.Create(path)
 	if err != nil {
 		return err
 	}
 	defer f.Close()
-	return Visualize(model, info, f)
+	return VisualizeWithOptions(model, info, f, opts)
+}
+
+// VisualizeCompare produces a visualization of two histories, e.g., of a run
+// before and after a fix, or of a passing and a failing seed, to compare
+// them. Both are drawn on the same timeline, so zooming and scrolling move
+// both, with each partition of history A next to the matching partition of
+// history B: the one with the same description, if the model has a
+// DescribePartition function, and otherwise the one with the same index. The
+// partitions' headers say which history they come from.
+//
+// See [Visualize] for what the visualization shows. Timestamps of both
+// histories are compared directly, so they should come from the same clock,
+// or be relative to the start of each run.
+func VisualizeCompare(model Model, infoA LinearizationInfo, infoB LinearizationInfo, output io.Writer) error {
+	// map the timestamps of both histories together, so they line up
+	var all LinearizationInfo
+	all.history = append(append(all.history, infoA.history...), infoB.history...)
+	all.annotations = append(append(all.annotations, infoA.annotations...), infoB.annotations...)
+	timeMap := timestampMapping(all)
+	a := computeMappedVisualizationData(model, infoA, timeMap)
+	b := computeMappedVisualizationData(model, infoB, timeMap)
+	label := func(name string, i int, partition *VizPartition) string {
+		if partition.Description == "" {
+			return fmt.Sprintf("%s: Partition %d", name, i)
+		}
+		return name + ": " + partition.Description
+	}
+	// pair up the partitions by description if there are any, and by index
+	// otherwise
+	key := func(i int, partition *VizPartition) string {
+		if model.DescribePartition != nil {
+			return partition.Description
+		}
+		return fmt.Sprintf("%d", i)
+	}
+	matches := make(map[string][]int)
+	for i := range b.Partitions {
+		k := key(i, &b.Partitions[i])
+		matches[k] = append(matches[k], i)
+	}
+	var data VizData
+	added := make([]bool, len(b.Partitions))
+	addB := func(i int) {
+		partition := b.Partitions[i]
+		partition.Description = label("B", i, &partition)
+		data.Partitions = append(data.Partitions, partition)
+		added[i] = true
+	}
+	for i := range a.Partitions {
+		k := key(i, &a.Partitions[i])
+		partition := a.Partitions[i]
+		partition.Description = label("A", i, &partition)
+		data.Partitions = append(data.Partitions, partition)
+		if m := matches[k]; len(m) > 0 {
+			addB(m[0])
+			matches[k] = m[1:]
+		}
+	}
+	for i := range b.Partitions {
+		if !added[i] {
+			addB(i)
+		}
+	}
+	data.Annotations = append(a.Annotations, b.Annotations...)
+	return writeVisualization(data, output, VisualizeOptions{}, 0)
 }
 
 //go:embed visualization
 var visualizationFS embed.FS
+
+// DefaultVisualizationTemplate holds the files of the built-in front-end of
+// visualizations: index.html, index.css, and index.js, e.g., to build on for
+// VisualizeOptions.Template.
+var DefaultVisualizationTemplate fs.FS = func() fs.FS {
+	f, _ := fs.Sub(visualizationFS, "visualization")
+	return f
+}()
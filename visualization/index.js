@@ -27,6 +27,19 @@ function svgadd(element, tag, attributes) {
   return svgattach(element, svgnew(tag, attributes))
 }
 
+// Makes a clickable SVG element usable from the keyboard, as a button
+function makeButton(element) {
+  element.setAttribute('tabindex', '0')
+  element.setAttribute('role', 'button')
+  element.addEventListener('keydown', (event_) => {
+    if (event_.key === 'Enter' || event_.key === ' ') {
+      event_.preventDefault()
+      event_.stopPropagation()
+      element.dispatchEvent(new MouseEvent('click'))
+    }
+  })
+}
+
 function newArray(n, function_) {
   const array = Array.from({length: n})
   for (let i = 0; i < n; i++) {
@@ -58,6 +71,90 @@ function arrayEq(a, b) {
   return true
 }
 
+function escapeHtml(text) {
+  return text
+    .replaceAll('&', '&amp;')
+    .replaceAll('<', '&lt;')
+    .replaceAll('>', '&gt;')
+    .replaceAll('"', '&quot;')
+}
+
+function tagsHtml(tags) {
+  if (!tags) {
+    return ''
+  }
+
+  const keys = Object.keys(tags).sort()
+  if (keys.length === 0) {
+    return ''
+  }
+
+  let html = '<br><br><strong>Tags:</strong>'
+  for (const key of keys) {
+    html += '<br>' + escapeHtml(key) + ': ' + escapeHtml(tags[key])
+  }
+
+  return html
+}
+
+// Renumbers ClientIds so that each row holds one (client, session) pair, and
+// returns the row labels, which name clients with clientName. Without
+// sessions, rows are clients, as usual, and this returns null.
+function groupSessions(partitions, annotations, clientName) {
+  const rows = new Map()
+  const addRow = (clientId, sessionId) => {
+    const key = JSON.stringify([clientId, sessionId])
+    if (!rows.has(key)) {
+      rows.set(key, {clientId, sessionId})
+    }
+
+    return key
+  }
+
+  let hasSessions = false
+  for (const partition of partitions) {
+    for (const element of partition.History) {
+      if (element.SessionId) {
+        hasSessions = true
+      }
+
+      element.Row = addRow(element.ClientId, element.SessionId || '')
+    }
+  }
+
+  for (const annot of annotations) {
+    if (annot.Tag.length === 0) {
+      annot.Row = addRow(annot.ClientId, '')
+    }
+  }
+
+  if (!hasSessions) {
+    return null
+  }
+
+  const sorted = [...rows.entries()].sort(([, a], [, b]) =>
+    a.clientId === b.clientId ? a.sessionId.localeCompare(b.sessionId) : a.clientId - b.clientId
+  )
+  const index = new Map(sorted.map(([key], i) => [key, i]))
+  for (const partition of partitions) {
+    for (const element of partition.History) {
+      element.ClientId = index.get(element.Row)
+    }
+  }
+
+  for (const annot of annotations) {
+    if (annot.Tag.length === 0) {
+      annot.ClientId = index.get(annot.Row)
+    }
+  }
+
+  return sorted.map(([, row]) =>
+    row.sessionId.length > 0
+      ? `${clientName(row.clientId)}/${row.sessionId}`
+      : clientName(row.clientId)
+  )
+}
+
 // eslint-disable-next-line no-unused-vars, complexity
 function render(data) {
   const PADDING = 10
@@ -68,12 +165,22 @@ function render(data) {
   const BOX_GAP = 20
   const BOX_TEXT_PADDING = 10
   const HISTORY_RECT_RADIUS = 4
+  const PLACEMENT_HEIGHT = 4
 
   const annotations = data.Annotations
   const coreHistory = data.Partitions
   // For simplicity, make annotations look like more history
   const allData = [...coreHistory, {History: annotations}]
 
+  // If operations carry session IDs, give each (client, session) pair its own
+  // row, since a client can multiplex several logical sessions
+  // Clients are labeled with their names, if they're given, and otherwise
+  // their IDs
+  const clientNames = data.ClientNames ?? {}
+  const clientName = (clientId) => clientNames[clientId] ?? clientId.toString()
+  const clientLabels = groupSessions(coreHistory, annotations, clientName)
+  const clientLabel = (i) => (clientLabels === null ? clientName(i) : clientLabels[i])
+
   let maxClient = -1
   for (const partition of allData) {
     for (const element of partition.History) {
@@ -135,6 +242,18 @@ function render(data) {
 
   let sortedTimestamps = [...allTimestamps].sort((a, b) => a - b)
 
+  // The original timestamps, formatted, for the time axis, if there is one;
+  // these are taken before the adjustments below
+  const timeLabels = new Map()
+  if (data.TimeAxis) {
+    for (const partition of coreHistory) {
+      for (const element of partition.History) {
+        timeLabels.set(element.Start, element.OriginalStart)
+        timeLabels.set(element.End, element.OriginalEnd)
+      }
+    }
+  }
+
   // If one event has the same end time as another's start time, that means that
   // they are concurrent, and we need to display them with overlap. We do this
   // by tweaking the events that share the end time, updating the time to
@@ -240,23 +359,32 @@ function render(data) {
   // doing so, also compute some useful information (e.g. x-positions of
   // linearization points) that is useful later.
   const xPos = {}
+  // Compute the width of the text inside history elements. Drawing each one
+  // (in a hidden div) and measuring it forces a layout per element, which is
+  // far too slow for large histories, so we measure with a canvas that uses
+  // the same font, and cache widths by text.
+  const textWidths = new Map()
+  const scratch = document.querySelector('#calc')
+  scratch.innerHTML = ''
+  const textStyle = window.getComputedStyle(
+    svgadd(svgadd(scratch, 'svg'), 'text', {class: 'history-text'})
+  )
+  const textContext = document.createElement('canvas').getContext('2d')
+  textContext.font = `${textStyle.fontSize} ${textStyle.fontFamily}`
+  const textWidth = (text) => {
+    if (!textWidths.has(text)) {
+      textWidths.set(text, textContext.measureText(text).width)
+    }
+
+    return textWidths.get(text)
+  }
+
   // Compute some information about history elements, sorted by end time;
   // the most important information here is box width.
   const byEnd = allData
     .flatMap((partition) =>
       partition.History.map((element) => {
-        // Compute width of the text inside the history element by actually
-        // drawing it (in a hidden div)
-        const scratch = document.querySelector('#calc')
-        scratch.innerHTML = ''
-        const svg = svgadd(scratch, 'svg')
-        const text = svgadd(svg, 'text', {
-          'text-anchor': 'middle',
-          class: 'history-text',
-        })
-        text.textContent = element.Description
-        const bbox = text.getBBox()
-        const width = bbox.width + 2 * BOX_TEXT_PADDING
+        const width = textWidth(element.Description) + 2 * BOX_TEXT_PADDING
         return {
           start: element.Start,
           end: element.End,
@@ -371,17 +499,18 @@ function render(data) {
     xPos[ts] = pos
   }
 
+  const rowLabel = (i) => (i < realClients ? clientLabel(i) : sortedTags[i - realClients])
+
   // Get maximum tag width
   let maxTagWidth = 0
   for (let i = 0; i < nClient; i++) {
-    const tag = i < realClients ? i.toString() : sortedTags[i - realClients]
     const scratch = document.querySelector('#calc')
     scratch.innerHTML = ''
     const svg = svgadd(scratch, 'svg')
     const text = svgadd(svg, 'text', {
       'text-anchor': 'end',
     })
-    text.textContent = tag
+    text.textContent = rowLabel(i)
     const bbox = text.getBBox()
     const width = bbox.width + 2 * BOX_TEXT_PADDING
     if (width > maxTagWidth) {
@@ -391,116 +520,663 @@ function render(data) {
 
   const t0x = PADDING + maxTagWidth // X-pos of line at t=0
 
+  // Group rows into bands. When there are several partitions (or a partition
+  // has a description), each partition gets its own band, with a header that
+  // collapses and expands it, holding only the rows of the clients that have
+  // operations in it; annotations get a band of their own. Otherwise, there
+  // is a single band with a row for every client and tag, and no header.
+  const grouped = coreHistory.length > 1 || coreHistory.some((partition) => partition.Description)
+  const bands = []
+  const sortedRows = (elements) =>
+    [...new Set(elements.map((element) => element.ClientId))].sort((a, b) => a - b)
+  if (grouped) {
+    for (const [i, partition] of coreHistory.entries()) {
+      const n = partition.History.length
+      const name = partition.Description || `Partition ${i}`
+      bands.push({
+        header: `${name} (${n} ${n === 1 ? 'operation' : 'operations'})`,
+        rows: sortedRows(partition.History),
+        partitions: [i],
+      })
+    }
+
+    if (annotations.length > 0) {
+      bands.push({
+        header: 'Annotations',
+        rows: sortedRows(annotations),
+        partitions: [coreHistory.length],
+      })
+    }
+  } else {
+    bands.push({
+      header: null,
+      rows: newArray(nClient, (i) => i),
+      partitions: newArray(allData.length, (i) => i),
+    })
+  }
+
+  const bandOf = [] // Partition -> band
+  for (const band of bands) {
+    band.headerHeight = band.header === null ? 0 : BOX_HEIGHT
+    band.height =
+      band.headerHeight + BOX_HEIGHT * band.rows.length + BOX_SPACE * (band.rows.length - 1)
+    band.rowIndex = new Map(band.rows.map((clientId, i) => [clientId, i]))
+    band.collapsed = false
+    for (const partition of band.partitions) {
+      bandOf[partition] = band
+    }
+  }
+
+  // Y-pos of an element's row, relative to its band
+  const rowY = (partition, clientId) => {
+    const band = bandOf[partition]
+    return band.headerHeight + band.rowIndex.get(clientId) * (BOX_HEIGHT + BOX_SPACE)
+  }
+
   // Solved, now draw UI.
 
   let selected = false
   let selectedIndex = [-1, -1]
 
-  const height = 2 * PADDING + BOX_HEIGHT * nClient + BOX_SPACE * (nClient - 1)
   const width = 2 * PADDING + maxTagWidth + xPos[sortedTimestamps.at(-1)]
-  const svg = svgadd(document.querySelector('#canvas'), 'svg', {
-    width,
-    height,
-  })
+  let height = 0 // Set by layoutBands
+  let zoom = 1
+  const svg = svgadd(document.querySelector('#canvas'), 'svg')
 
   // Draw background, etc.
   const bg = svgadd(svg, 'g')
   const bgRect = svgadd(bg, 'rect', {
-    height,
     width,
     x: 0,
     y: 0,
     class: 'bg',
   })
   bgRect.addEventListener('click', handleBgClick)
-  for (let i = 0; i < nClient; i++) {
-    const text = svgadd(bg, 'text', {
-      x: PADDING + maxTagWidth - BOX_TEXT_PADDING,
-      y: PADDING + BOX_HEIGHT / 2 + i * (BOX_HEIGHT + BOX_SPACE),
-      'text-anchor': 'end',
+
+  // Zooming and the minimap. Shift-dragging over the history zooms to that
+  // range of time, scaling the drawing so the range fills the window, and the
+  // minimap at the bottom of the window shows the whole history, with the part
+  // that's in view outlined; clicking or dragging on it scrolls there.
+  const MINIMAP_ROW_HEIGHT = 0.8
+  const MAX_ZOOM = 8
+  // The minimap is drawn on a canvas, rather than with an SVG element per
+  // history element, to stay fast for large histories
+  const minimap = document.querySelector('#minimap')
+  const minimapCanvas = svgattach(minimap, document.createElement('canvas'))
+  const minimapViewport = svgattach(minimap, document.createElement('div'))
+  minimapViewport.classList.add('minimap-viewport')
+
+  function drawMinimap() {
+    const w = minimapCanvas.clientWidth
+    const h = minimapCanvas.clientHeight
+    if (minimapCanvas.width === w && minimapCanvas.height === h) {
+      return // Already drawn at this size
+    }
+
+    minimapCanvas.width = w
+    minimapCanvas.height = h
+    const context = minimapCanvas.getContext('2d')
+    const style = window.getComputedStyle(document.documentElement)
+    const rowHeight = h / nClient
+    context.fillStyle = style.getPropertyValue('--illegal-linearization')
+    for (const partition of allData) {
+      const failing = partition.FailingWindow
+      if (failing) {
+        const x = ((t0x + xPos[failing.Start]) / width) * w
+        const failingWidth = ((xPos[failing.End] - xPos[failing.Start]) / width) * w
+        context.fillRect(x, 0, Math.max(failingWidth, 1), h)
+      }
+    }
+
+    for (const [partitionIndex, partition] of allData.entries()) {
+      const fill = style.getPropertyValue(
+        partitionIndex < coreHistory.length ? '--operation' : '--annotation'
+      )
+      for (const element of partition.History) {
+        context.fillStyle = element.Color ?? fill
+        const x = ((t0x + xPos[element.Start]) / width) * w
+        context.fillRect(
+          x,
+          (element.ClientId + (1 - MINIMAP_ROW_HEIGHT) / 2) * rowHeight,
+          Math.max(((xPos[element.End] - xPos[element.Start]) / width) * w, 1),
+          MINIMAP_ROW_HEIGHT * rowHeight
+        )
+      }
+    }
+  }
+
+  function updateMinimap() {
+    const viewWidth = document.documentElement.clientWidth
+    if (width * zoom <= viewWidth) {
+      minimap.classList.add('inactive')
+      return
+    }
+
+    minimap.classList.remove('inactive')
+    drawMinimap()
+    const left = -svg.getBoundingClientRect().left / zoom
+    minimapViewport.style.left = (Math.max(left, 0) / width) * 100 + '%'
+    minimapViewport.style.width = (viewWidth / zoom / width) * 100 + '%'
+  }
+
+  function applyZoom() {
+    svgattr(svg, {
+      width: width * zoom,
+      height: height * zoom,
+      viewBox: `0 0 ${width} ${height}`,
     })
-    text.textContent = i < realClients ? i : sortedTags[i - realClients]
+    const reset = document.querySelector('#zoom-reset')
+    if (zoom === 1) {
+      reset.classList.add('inactive')
+    } else {
+      reset.classList.remove('inactive')
+    }
+
+    updateMinimap()
+    scheduleRender()
+  }
+
+  // Scrolls horizontally so that x, in unzoomed coordinates, is at the left
+  // edge of the window
+  // Scrolls smoothly so that x and y, in unzoomed coordinates, with y
+  // relative to a partition's band, are in the middle of the window
+  function scrollToPoint(x, partition, y) {
+    const rect = svg.getBoundingClientRect()
+    const band = bandOf[partition]
+    window.scrollTo({
+      left: rect.left + window.scrollX + x * zoom - document.documentElement.clientWidth / 2,
+      top:
+        rect.top +
+        window.scrollY +
+        (band.top + y) * zoom -
+        document.documentElement.clientHeight / 2,
+      behavior: 'smooth',
+    })
+  }
+
+  function scrollToX(x) {
+    const svgLeft = svg.getBoundingClientRect().left + window.scrollX
+    window.scrollTo(svgLeft + x * zoom, window.scrollY)
+  }
+
+  function zoomTo(x1, x2) {
+    const viewWidth = document.documentElement.clientWidth - 2 * PADDING
+    zoom = Math.min(Math.max(viewWidth / (x2 - x1), viewWidth / width), MAX_ZOOM)
+    applyZoom()
+    scrollToX(x1)
+    saveViewState()
   }
 
-  // Vertical line at t=0
-  svgadd(bg, 'line', {
-    x1: t0x,
-    y1: PADDING,
-    x2: t0x,
-    y2: height - PADDING,
-    class: 'divider',
+  document.querySelector('#zoom-reset').addEventListener('click', () => {
+    zoom = 1
+    applyZoom()
+    saveViewState()
   })
-  // Horizontal line dividing clients from annotation tags, but only if there are tags
-  if (tags.size > 0) {
-    const annotationLineY = PADDING + realClients * (BOX_HEIGHT + BOX_SPACE) - BOX_SPACE / 2
-    svgadd(bg, 'line', {
-      x1: PADDING,
-      y1: annotationLineY,
+
+  // X-pos of a mouse event, in unzoomed coordinates
+  const eventX = (event_) => (event_.clientX - svg.getBoundingClientRect().left) / zoom
+  let zoomStart = null
+  let zoomSelection = null
+  svg.addEventListener('mousedown', (event_) => {
+    if (!event_.shiftKey) {
+      return
+    }
+
+    event_.preventDefault()
+    zoomStart = eventX(event_)
+    zoomSelection = svgadd(svg, 'rect', {
+      x: zoomStart,
+      y: 0,
+      width: 0,
+      height,
+      class: 'zoom-selection',
+    })
+  })
+  window.addEventListener('mousemove', (event_) => {
+    if (zoomSelection !== null) {
+      const x = eventX(event_)
+      svgattr(zoomSelection, {x: Math.min(x, zoomStart), width: Math.abs(x - zoomStart)})
+    }
+  })
+  window.addEventListener('mouseup', (event_) => {
+    if (zoomSelection === null) {
+      return
+    }
+
+    zoomSelection.remove()
+    zoomSelection = null
+    const x = eventX(event_)
+    // Ignore shift-clicks
+    if (Math.abs(x - zoomStart) * zoom > BOX_GAP) {
+      zoomTo(Math.min(x, zoomStart), Math.max(x, zoomStart))
+    }
+  })
+
+  let minimapDragging = false
+  const scrollToMinimap = (event_) => {
+    const rect = minimap.getBoundingClientRect()
+    const x = ((event_.clientX - rect.left) / rect.width) * width
+    scrollToX(x - document.documentElement.clientWidth / zoom / 2)
+  }
+
+  minimap.addEventListener('mousedown', (event_) => {
+    event_.preventDefault()
+    minimapDragging = true
+    scrollToMinimap(event_)
+  })
+  window.addEventListener('mousemove', (event_) => {
+    if (minimapDragging) {
+      scrollToMinimap(event_)
+    }
+  })
+  window.addEventListener('mouseup', () => {
+    minimapDragging = false
+  })
+  window.addEventListener('scroll', () => {
+    updateMinimap()
+    scheduleRender()
+    saveViewState()
+  })
+  window.addEventListener('resize', () => {
+    updateMinimap()
+    scheduleRender()
+  })
+
+  // Virtualized rendering. Large histories have far too many elements to put
+  // them all in the DOM, so history elements and linearization points are
+  // only drawn when they're near the part of the history that's in view, and
+  // removed when they're far from it. Each item covers a range of x-positions,
+  // and create draws it, returning its nodes; destroy (optional) is called
+  // after they're removed.
+  const virtualItems = []
+  function virtual(x1, x2, create, destroy) {
+    virtualItems.push({x1, x2, create, destroy, nodes: null})
+  }
+
+  function renderVisible() {
+    // Draw what's in view, along with a window's width on either side, so
+    // that scrolling doesn't show blank space
+    const viewWidth = document.documentElement.clientWidth / zoom
+    const left = -svg.getBoundingClientRect().left / zoom - viewWidth
+    const right = left + 3 * viewWidth
+    for (const item of virtualItems) {
+      const visible = item.x2 >= left && item.x1 <= right
+      if (visible && item.nodes === null) {
+        item.nodes = item.create()
+      } else if (!visible && item.nodes !== null) {
+        for (const node of item.nodes) {
+          node.remove()
+        }
+
+        item.nodes = null
+        if (item.destroy) {
+          item.destroy()
+        }
+      }
+    }
+  }
+
+  let renderScheduled = false
+  function scheduleRender() {
+    if (!renderScheduled && virtualItems.length > 0) {
+      renderScheduled = true
+      window.requestAnimationFrame(() => {
+        renderScheduled = false
+        renderVisible()
+      })
+    }
+  }
+
+  for (const band of bands) {
+    band.g = svgadd(svg, 'g')
+    if (band.header !== null) {
+      // Divider above the band, and its header
+      svgadd(band.g, 'line', {
+        x1: PADDING,
+        y1: -BOX_SPACE / 2,
+        x2: width - PADDING,
+        y2: -BOX_SPACE / 2,
+        class: 'divider',
+      })
+      band.headerText = svgadd(band.g, 'text', {
+        x: PADDING,
+        y: BOX_HEIGHT / 2,
+        class: 'partition-header',
+      })
+      band.headerText.addEventListener('click', () => {
+        toggleBand(band)
+      })
+      makeButton(band.headerText)
+    }
+
+    band.body = svgadd(band.g, 'g')
+    for (const [i, clientId] of band.rows.entries()) {
+      const text = svgadd(band.body, 'text', {
+        x: PADDING + maxTagWidth - BOX_TEXT_PADDING,
+        y: band.headerHeight + BOX_HEIGHT / 2 + i * (BOX_HEIGHT + BOX_SPACE),
+        'text-anchor': 'end',
+      })
+      text.textContent = rowLabel(clientId)
+    }
+
+    // Vertical line at t=0
+    svgadd(band.body, 'line', {
+      x1: t0x,
+      y1: band.headerHeight,
       x2: t0x,
-      y2: annotationLineY,
+      y2: band.height,
       class: 'divider',
     })
+    // Horizontal line dividing clients from annotation tags, but only if
+    // there are both
+    const firstTag = band.rows.findIndex((clientId) => clientId >= realClients)
+    if (firstTag > 0) {
+      const annotationLineY =
+        band.headerHeight + firstTag * (BOX_HEIGHT + BOX_SPACE) - BOX_SPACE / 2
+      svgadd(band.body, 'line', {
+        x1: PADDING,
+        y1: annotationLineY,
+        x2: t0x,
+        y2: annotationLineY,
+        class: 'divider',
+      })
+    }
+
+    // Shading of the smallest window of each partition that isn't
+    // linearizable, behind the operations
+    for (const partition of band.partitions) {
+      const failing = allData[partition].FailingWindow
+      if (failing) {
+        svgadd(band.body, 'rect', {
+          x: t0x + xPos[failing.Start],
+          y: band.headerHeight,
+          width: xPos[failing.End] - xPos[failing.Start],
+          height: band.height - band.headerHeight,
+          class: 'failing-window',
+        })
+      }
+    }
+
+    // Layers, bottom to top: history, mouse targets, and partial
+    // linearizations, whose lines let the mouse through to the targets, so
+    // they don't create holes, except for the LPs' own targets
+    band.history = svgadd(band.body, 'g')
+    band.targets = svgadd(band.body, 'g')
+    band.partials = svgadd(band.body, 'g')
   }
 
+  // The time axis, above the bands, labels some of the timestamps with their
+  // original values, as far apart as their labels need
+  const axisHeight = data.TimeAxis ? BOX_HEIGHT : 0
+  if (data.TimeAxis) {
+    const axis = svgadd(svg, 'g', {transform: `translate(0, ${PADDING})`})
+    let next = -Infinity
+    for (const ts of sortedTimestamps) {
+      const label = timeLabels.get(ts)
+      const x = t0x + xPos[ts]
+      if (label === undefined || x < next) {
+        continue
+      }
+
+      const labelWidth = textWidth(label)
+      next = x + labelWidth + BOX_GAP
+      virtual(x, x + labelWidth, () => {
+        const tick = svgadd(axis, 'line', {
+          x1: x,
+          y1: BOX_HEIGHT / 2,
+          x2: x,
+          y2: BOX_HEIGHT,
+          class: 'axis-tick',
+        })
+        const text = svgadd(axis, 'text', {
+          x: x + 3,
+          y: BOX_HEIGHT / 4,
+          class: 'history-text axis-label',
+        })
+        text.textContent = label
+        return [tick, text]
+      })
+    }
+  }
+
+  function layoutBands() {
+    let y = PADDING + axisHeight
+    for (const band of bands) {
+      band.top = y
+      svgattr(band.g, {transform: `translate(0, ${y})`})
+      if (band.collapsed) {
+        band.body.classList.add('collapsed')
+      } else {
+        band.body.classList.remove('collapsed')
+      }
+
+      if (band.header !== null) {
+        band.headerText.textContent = (band.collapsed ? '▸ ' : '▾ ') + band.header
+        band.headerText.setAttribute('aria-expanded', String(!band.collapsed))
+      }
+
+      y += (band.collapsed ? band.headerHeight : band.height) + BOX_SPACE
+    }
+
+    height = y - BOX_SPACE + PADDING
+    svgattr(bgRect, {height})
+    applyZoom()
+  }
+
+  function toggleBand(band) {
+    band.collapsed = !band.collapsed
+    if (band.collapsed && selected && bandOf[selectedIndex[0]] === band) {
+      handleBgClick()
+    }
+
+    layoutBands()
+    updateJump()
+    saveViewState()
+  }
+
+  layoutBands()
+
   // Draw history
   const historyLayers = []
-  const historyRects = []
-  const targetRects = svgnew('g')
+  // The history elements' rects, when they're drawn, and the extra classes
+  // they have (e.g., for selection), which they get when they're drawn
+  const historyRects = allData.map((partition) => partition.History.map(() => null))
+  const elementClasses = new Map()
+  function setElementClass(partition, index, cls, on) {
+    const key = `${partition}/${index}`
+    const classes = elementClasses.get(key) ?? new Set()
+    if (on) {
+      classes.add(cls)
+    } else {
+      classes.delete(cls)
+    }
+
+    elementClasses.set(key, classes)
+    const rect = historyRects[partition][index]
+    if (rect !== null) {
+      if (on) {
+        rect.classList.add(cls)
+      } else {
+        rect.classList.remove(cls)
+      }
+    }
+  }
+
+  function elementFill(element) {
+    if (element.Annotation) {
+      return element.BackgroundColor.length > 0 ? `fill: ${element.BackgroundColor};` : ''
+    }
+
+    return element.Color ? `fill: ${element.Color};` : ''
+  }
+
+  function drawElement(l, partitionIndex, elementIndex) {
+    const element = allData[partitionIndex].History[elementIndex]
+    const g = svgadd(l, 'g')
+    const rx = xPos[element.Start]
+    const width = xPos[element.End] - rx
+    const x = rx + t0x
+    const y = rowY(partitionIndex, element.ClientId)
+    const rectClass = element.Annotation ? 'client-annotation-rect' : 'history-rect'
+    const rect = svgadd(g, 'rect', {
+      height: BOX_HEIGHT,
+      width,
+      x,
+      y,
+      rx: HISTORY_RECT_RADIUS,
+      ry: HISTORY_RECT_RADIUS,
+      class: rectClass,
+      style: elementFill(element),
+    })
+    for (const cls of elementClasses.get(`${partitionIndex}/${elementIndex}`) ?? []) {
+      rect.classList.add(cls)
+    }
+
+    historyRects[partitionIndex][elementIndex] = rect
+    const text = svgadd(g, 'text', {
+      x: x + width / 2,
+      y: y + BOX_HEIGHT / 2,
+      'text-anchor': 'middle',
+      class: 'history-text',
+      style:
+        element.Annotation && element.TextColor.length > 0 ? `fill: ${element.TextColor};` : '',
+    })
+    text.textContent = element.Description
+    if (element.Window) {
+      // Overlay marking where the linearization point can fall
+      const wx = t0x + xPos[element.Window.Start]
+      svgadd(g, 'rect', {
+        height: PLACEMENT_HEIGHT,
+        width: Math.max(xPos[element.Window.End] - xPos[element.Window.Start], PLACEMENT_HEIGHT),
+        x: Math.min(wx, x + width - PLACEMENT_HEIGHT),
+        y: y + BOX_HEIGHT - PLACEMENT_HEIGHT,
+        class: 'placement-window',
+      })
+    }
+
+    // We don't add mouseTarget to g, but to the band's targets, because
+    // we want to layer this on top of everything; otherwise, the LPs and
+    // lines will be over the target, which will create holes where hover
+    // etc. won't work
+    const mouseTarget = svgadd(bandOf[partitionIndex].targets, 'rect', {
+      height: BOX_HEIGHT,
+      width,
+      x,
+      y,
+      class: 'target-rect',
+      id: `element-${partitionIndex}-${elementIndex}`,
+      role: 'button',
+      'aria-label': elementLabel(partitionIndex, elementIndex),
+      'data-partition': partitionIndex,
+      'data-index': elementIndex,
+    })
+    // The exact input and output, if they were encoded, for scripts and
+    // devtools
+    for (const [attribute, value] of [
+      ['data-input', element.Input],
+      ['data-output', element.Output],
+    ]) {
+      if (value !== undefined) {
+        mouseTarget.setAttributeNS(null, attribute, JSON.stringify(value))
+      }
+    }
+
+    mouseTarget.addEventListener('mouseover', handleMouseOver)
+    mouseTarget.addEventListener('mousemove', handleMouseMove)
+    mouseTarget.addEventListener('mouseout', handleMouseOut)
+    mouseTarget.addEventListener('click', handleClick)
+    return [g, mouseTarget]
+  }
+
   for (const [partitionIndex, partition] of allData.entries()) {
-    const l = svgadd(svg, 'g')
+    // In bands, the annotations have no band when there are none
+    const band = bandOf[partitionIndex]
+    const l = svgadd(band === undefined ? svg : band.history, 'g')
     historyLayers.push(l)
-    const rects = []
     for (const [elementIndex, element] of partition.History.entries()) {
-      const g = svgadd(l, 'g')
-      const rx = xPos[element.Start]
-      const width = xPos[element.End] - rx
-      const x = rx + t0x
-      const y = PADDING + element.ClientId * (BOX_HEIGHT + BOX_SPACE)
-      const rectClass = element.Annotation ? 'client-annotation-rect' : 'history-rect'
-      rects.push(
-        svgadd(g, 'rect', {
-          height: BOX_HEIGHT,
-          width,
-          x,
-          y,
-          rx: HISTORY_RECT_RADIUS,
-          ry: HISTORY_RECT_RADIUS,
-          class: rectClass,
-          style:
-            element.Annotation && element.BackgroundColor.length > 0
-              ? `fill: ${element.BackgroundColor};`
-              : '',
+      virtual(
+        t0x + xPos[element.Start],
+        t0x + xPos[element.End],
+        () => drawElement(l, partitionIndex, elementIndex),
+        () => {
+          historyRects[partitionIndex][elementIndex] = null
+        }
+      )
+    }
+  }
+
+  // Hovering over a linearization point shows its operation, along with the
+  // states before and after it; position is null for illegal LPs
+  function addPointTarget(g, x, y, partition, linIndex, position, index) {
+    const target = svgadd(g, 'line', {
+      x1: x,
+      x2: x,
+      y1: y,
+      y2: y + BOX_HEIGHT + 2 * LINE_BLEED,
+      class: 'linearization-target',
+    })
+    target.addEventListener('mouseover', () => {
+      if (frozen()) {
+        return
+      }
+
+      showLinearization(partition, linIndex)
+      tooltip.innerHTML = pointTooltip(partition, linIndex, position, index)
+      tooltip.style.display = 'block'
+      lastTooltip = [null, null, null, null, null]
+    })
+    target.addEventListener('mousemove', (event_) => {
+      if (!frozen()) {
+        moveTooltip(event_)
+      }
+    })
+    target.addEventListener('mouseout', handleMouseOut)
+    return target
+  }
+
+  function pointTooltip(partition, linIndex, position, index) {
+    const {History: history, InitialState: initialState} = coreHistory[partition]
+    const lin = coreHistory[partition].PartialLinearizations[linIndex]
+    const last = position === null ? lin.length : position
+    const before = last === 0 ? initialState : lin[last - 1].StateDescription
+    const after = position === null ? '&langle;invalid op&rangle;' : lin[position].StateDescription
+    return (
+      '<strong>Operation:</strong><br>' +
+      escapeHtml(history[index].Description) +
+      '<br><br><strong>State before:</strong><br>' +
+      before +
+      '<br><br><strong>State after:</strong><br>' +
+      after
+    )
+  }
+
+  // Draws a linearization point, with a line from the previous one, if
+  // there is one, and a target for hovering over it
+  function drawPoint(g, previous, x, y, cls, [partition, linIndex, position, index]) {
+    const nodes = []
+    if (previous !== null) {
+      nodes.push(
+        svgadd(g, 'line', {
+          x1: previous.x,
+          x2: x,
+          y1: previous.y,
+          y2: previous.y > y ? y + BOX_HEIGHT + 2 * LINE_BLEED : y,
+          class: `${cls} linearization-line`,
         })
       )
-      const text = svgadd(g, 'text', {
-        x: x + width / 2,
-        y: y + BOX_HEIGHT / 2,
-        'text-anchor': 'middle',
-        class: 'history-text',
-        style:
-          element.Annotation && element.TextColor.length > 0 ? `fill: ${element.TextColor};` : '',
-      })
-      text.textContent = element.Description
-      // We don't add mouseTarget to g, but to targetRects, because we
-      // want to layer this on top of everything at the end; otherwise, the
-      // LPs and lines will be over the target, which will create holes
-      // where hover etc. won't work
-      const mouseTarget = svgadd(targetRects, 'rect', {
-        height: BOX_HEIGHT,
-        width,
-        x,
-        y,
-        class: 'target-rect',
-        'data-partition': partitionIndex,
-        'data-index': elementIndex,
-      })
-      mouseTarget.addEventListener('mouseover', handleMouseOver)
-      mouseTarget.addEventListener('mousemove', handleMouseMove)
-      mouseTarget.addEventListener('mouseout', handleMouseOut)
-      mouseTarget.addEventListener('click', handleClick)
     }
 
-    historyRects.push(rects)
+    nodes.push(
+      svgadd(g, 'line', {
+        x1: x,
+        x2: x,
+        y1: y,
+        y2: y + BOX_HEIGHT + 2 * LINE_BLEED,
+        class: `${cls} linearization-point`,
+      }),
+      addPointTarget(g, x, y, partition, linIndex, position, index)
+    )
+    return nodes
   }
 
   // Draw partial linearizations
@@ -519,39 +1195,35 @@ function render(data) {
     const l = []
     partialLayers.push(l)
     for (const [linIndex, lin] of partition.PartialLinearizations.entries()) {
-      const g = svgadd(svg, 'g')
+      const g = svgadd(bandOf[partitionIndex].partials, 'g')
       l.push(g)
       let previousX = null
       let previousY = null
       let previousElement = null
       const included = new Set()
-      for (const id of lin) {
+      for (const [position, id] of lin.entries()) {
         const element = partition.History[id.Index]
         const hereX = t0x + xPos[element.Start]
         const x = previousX === null ? hereX : Math.max(hereX, previousX + EPSILON)
-        const y = PADDING + element.ClientId * (BOX_HEIGHT + BOX_SPACE) - LINE_BLEED
-        // Line from previous
-        if (previousElement !== null) {
-          svgadd(g, 'line', {
-            x1: previousX,
-            x2: x,
-            y1:
-              previousElement.ClientId >= element.ClientId
-                ? previousY
-                : previousY + BOX_HEIGHT + 2 * LINE_BLEED,
-            y2: previousElement.ClientId <= element.ClientId ? y : y + BOX_HEIGHT + 2 * LINE_BLEED,
-            class: 'linearization linearization-line',
-          })
-        }
-
-        // Current line
-        svgadd(g, 'line', {
-          x1: x,
-          x2: x,
-          y1: y,
-          y2: y + BOX_HEIGHT + 2 * LINE_BLEED,
-          class: 'linearization linearization-point',
-        })
+        const y = rowY(partitionIndex, element.ClientId) - LINE_BLEED
+        const previous =
+          previousElement === null
+            ? null
+            : {
+                x: previousX,
+                y:
+                  previousElement.ClientId >= element.ClientId
+                    ? previousY
+                    : previousY + BOX_HEIGHT + 2 * LINE_BLEED,
+              }
+        virtual(previous === null ? x : previous.x, x, () =>
+          drawPoint(g, previous, x, y, 'linearization', [
+            partitionIndex,
+            linIndex,
+            position,
+            id.Index,
+          ])
+        )
         previousX = x
         previousY = y
         previousElement = element
@@ -573,31 +1245,28 @@ function render(data) {
         if (!included.has(index) && element.Start < minEnd) {
           const hereX = t0x + xPos[element.Start]
           const x = previousX === null ? hereX : Math.max(hereX, previousX + EPSILON)
-          const y = PADDING + element.ClientId * (BOX_HEIGHT + BOX_SPACE) - LINE_BLEED
-          // Line from previous
-          svgadd(g, 'line', {
-            x1: previousX,
-            x2: x,
-            y1:
+          const y = rowY(partitionIndex, element.ClientId) - LINE_BLEED
+          const previous = {
+            x: previousX,
+            y:
               previousElement.ClientId >= element.ClientId
                 ? previousY
                 : previousY + BOX_HEIGHT + 2 * LINE_BLEED,
-            y2: previousElement.ClientId <= element.ClientId ? y : y + BOX_HEIGHT + 2 * LINE_BLEED,
-            class: 'linearization-invalid linearization-line',
-          })
-          // Current line
-          const point = svgadd(g, 'line', {
-            x1: x,
-            x2: x,
-            y1: y,
-            y2: y + BOX_HEIGHT + 2 * LINE_BLEED,
-            class: 'linearization-invalid linearization-point',
-          })
+          }
+          virtual(previousX, x, () =>
+            drawPoint(g, previous, x, y, 'linearization-invalid', [
+              partitionIndex,
+              linIndex,
+              null,
+              index,
+            ])
+          )
           errorPoints.push({
             x,
+            y: y + LINE_BLEED + BOX_HEIGHT / 2,
             partition: partitionIndex,
+            linIndex,
             index: lin.at(-1).Index, // NOTE not index
-            element: point,
           })
           illegalLast[partitionIndex][linIndex].add(index)
           // eslint-disable-next-line max-depth
@@ -615,16 +1284,13 @@ function render(data) {
 
   errorPoints.sort((a, b) => a.x - b.x)
 
-  // Attach targetRects
-  svgattach(svg, targetRects)
-
   // Tooltip
   // eslint-disable-next-line unicorn/prefer-dom-node-append
   const tooltip = document.querySelector('#canvas').appendChild(document.createElement('div'))
   tooltip.setAttribute('class', 'tooltip')
 
   function handleMouseOver() {
-    if (!selected) {
+    if (!frozen()) {
       const partition = Number.parseInt(this.dataset.partition, 10)
       const index = Number.parseInt(this.dataset.index, 10)
       highlight(partition, index)
@@ -651,6 +1317,10 @@ function render(data) {
   }
 
   function highlight(partition, index) {
+    showLinearization(partition, linearizationIndex(partition, index))
+  }
+
+  function showLinearization(partition, maxIndex) {
     // Hide all but this partition
     for (const [i, layer] of historyLayers.entries()) {
       if (i === partition) {
@@ -668,7 +1338,6 @@ function render(data) {
     }
 
     // Show this linearization
-    const maxIndex = linearizationIndex(partition, index)
     if (maxIndex !== null) {
       partialLayers[partition][maxIndex].classList.remove('hidden')
     }
@@ -678,8 +1347,8 @@ function render(data) {
 
   let lastTooltip = [null, null, null, null, null]
   function handleMouseMove(event_) {
-    // Keep tooltip static if selected
-    if (selected) {
+    // Keep tooltip static if selected or playing back
+    if (frozen()) {
       return
     }
 
@@ -723,13 +1392,12 @@ function render(data) {
         let message = ''
         if (found) {
           // Part of linearization
-          if (previous !== null) {
-            message =
-              '<strong>Previous state:</strong><br>' + previous.StateDescription + '<br><br>'
-          }
-
-          message +=
-            '<strong>New state:</strong><br>' +
+          const previousState =
+            previous === null ? coreHistory[partition].InitialState : previous.StateDescription
+          message =
+            '<strong>Previous state:</strong><br>' +
+            previousState +
+            '<br><br><strong>New state:</strong><br>' +
             current.StateDescription +
             '<br><br>Call: ' +
             callTime +
@@ -750,12 +1418,22 @@ function render(data) {
           message = "Not part of selected element's partial linearization."
         }
 
-        tooltip.innerHTML = message
+        const window_ = allData[partition].History[index].Window
+        if (found && window_) {
+          message +=
+            '<br><br>Linearization window: ' + window_.OriginalStart + ' to ' + window_.OriginalEnd
+        }
+
+        tooltip.innerHTML = message + tagsHtml(allData[partition].History[index].Tags)
       }
 
       lastTooltip = thisTooltip
     }
 
+    moveTooltip(event_)
+  }
+
+  function moveTooltip(event_) {
     // Make sure tooltip doesn't overflow off the right side of the screen
     const maxX =
       document.documentElement.scrollLeft +
@@ -767,7 +1445,7 @@ function render(data) {
   }
 
   function handleMouseOut() {
-    if (!selected) {
+    if (!frozen()) {
       resetHighlight()
       tooltip.style.display = 'none'
       lastTooltip = [null, null, null, null, null]
@@ -799,9 +1477,13 @@ function render(data) {
 
   function updateJump() {
     const jump = document.querySelector('#jump-link')
-    // Find first non-hidden point
+    // Find first non-hidden point, in an expanded band
     // feels a little hacky, but it works
-    const point = errorPoints.find((pt) => !pt.element.parentElement.classList.contains('hidden'))
+    const point = errorPoints.find(
+      (pt) =>
+        !partialLayers[pt.partition][pt.linIndex].classList.contains('hidden') &&
+        !bandOf[pt.partition].collapsed
+    )
 
     // Remove any existing event listener
     if (jumpClickHandler) {
@@ -812,7 +1494,7 @@ function render(data) {
     if (point) {
       jump.classList.remove('inactive')
       jumpClickHandler = () => {
-        point.element.scrollIntoView({behavior: 'smooth', inline: 'center', block: 'center'})
+        scrollToPoint(point.x, point.partition, point.y)
         if (!selected) {
           select(point.partition, point.index)
         }
@@ -827,28 +1509,32 @@ function render(data) {
   function handleClick(event_) {
     const partition = Number.parseInt(this.dataset.partition, 10)
     const index = Number.parseInt(this.dataset.index, 10)
+    setFocused(partition, index)
+    if (!toggleSelection(partition, index)) {
+      // Note: we're still displaying the tooltip, but once the user's mouse moves, it'll get updated
+      return
+    }
+
+    tooltip.style.display = 'block'
+    // Set static tooltip position when selecting
+    moveTooltip(event_)
+  }
+
+  // Selects the element, or deselects it if it's already selected, and
+  // returns whether it's selected
+  function toggleSelection(partition, index) {
     if (selected) {
       const [sPartition, sIndex] = selectedIndex
       if (partition === sPartition && index === sIndex) {
         deselect()
-        // Note: we're still displaying the tooltip, but once the user's mouse moves, it'll get updated
-        return
+        return false
       }
 
-      historyRects[sPartition][sIndex].classList.remove('selected')
+      setElementClass(sPartition, sIndex, 'selected', false)
     }
 
     select(partition, index)
-
-    tooltip.style.display = 'block'
-    // Set static tooltip position when selecting
-    const maxX =
-      document.documentElement.scrollLeft +
-      document.documentElement.clientWidth -
-      PADDING -
-      tooltip.getBoundingClientRect().width
-    tooltip.style.left = Math.min(event_.pageX + 20, maxX) + 'px'
-    tooltip.style.top = event_.pageY + 20 + 'px'
+    return true
   }
 
   function handleBgClick() {
@@ -859,10 +1545,12 @@ function render(data) {
   }
 
   function select(partition, index) {
+    stopPlayback()
     selected = true
     selectedIndex = [partition, index]
     highlight(partition, index)
-    historyRects[partition][index].classList.add('selected')
+    setElementClass(partition, index, 'selected', true)
+    saveViewState()
   }
 
   function deselect() {
@@ -871,10 +1559,460 @@ function render(data) {
     }
 
     selected = false
+    saveViewState()
     resetHighlight()
     const [partition, index] = selectedIndex
-    historyRects[partition][index].classList.remove('selected')
+    setElementClass(partition, index, 'selected', false)
+  }
+
+  // Playback steps through a linearization one operation at a time,
+  // highlighting each operation and showing the state after it. It plays the
+  // selected element's linearization, if there is one, and otherwise the
+  // longest linearization of the first partition with an error, or of the
+  // first partition if there are no errors. While it's active, hovering
+  // doesn't change the highlighted linearization, as when an element is
+  // selected.
+  const PLAYBACK_INTERVAL = 1000 // Milliseconds per step
+  const playback = {partition: null, lin: null, step: 0, current: null, timer: null}
+  const playbackPanel = document.querySelector('#playback')
+  const playbackToggle = document.querySelector('#playback-toggle')
+
+  function frozen() {
+    return selected || playback.partition !== null
+  }
+
+  function startPlayback() {
+    stopPlayback()
+    let partition = null
+    let lin = null
+    if (selected && selectedIndex[0] < coreHistory.length) {
+      partition = selectedIndex[0]
+      lin = linearizationIndex(...selectedIndex)
+    }
+
+    if (lin === null) {
+      const candidates = coreHistory
+        .map((data, i) => i)
+        .filter((i) => coreHistory[i].PartialLinearizations.length > 0)
+      partition =
+        candidates.find(
+          (i) =>
+            coreHistory[i].PartialLinearizations[0].length < coreHistory[i].History.length
+        ) ?? candidates[0]
+      lin = 0
+    }
+
+    if (partition === undefined) {
+      return // Nothing to play
+    }
+
+    deselect()
+    tooltip.style.display = 'none'
+    Object.assign(playback, {partition, lin, step: 0, current: null})
+    playbackPanel.classList.remove('inactive')
+    if (bandOf[partition].collapsed) {
+      toggleBand(bandOf[partition])
+    }
+
+    showStep()
+    play()
+  }
+
+  function showStep() {
+    const {partition, lin: linIndex, step} = playback
+    const lin = coreHistory[partition].PartialLinearizations[linIndex]
+    showLinearization(partition, linIndex)
+    if (playback.current !== null) {
+      setElementClass(partition, playback.current, 'playback-current', false)
+    }
+
+    const {Index: index, StateDescription: state} = lin[step]
+    playback.current = index
+    setElementClass(partition, index, 'playback-current', true)
+    const element = coreHistory[partition].History[index]
+    scrollToPoint(
+      t0x + (xPos[element.Start] + xPos[element.End]) / 2,
+      partition,
+      rowY(partition, element.ClientId) + BOX_HEIGHT / 2
+    )
+    const partial = lin.length < coreHistory[partition].History.length
+    document.querySelector('#playback-step').textContent =
+      `Step ${step + 1} of ${lin.length}${partial ? ' (partial linearization)' : ''}: ` +
+      coreHistory[partition].History[index].Description
+    document.querySelector('#playback-state').innerHTML = '<strong>State:</strong> ' + state
+  }
+
+  function playbackLength() {
+    return coreHistory[playback.partition].PartialLinearizations[playback.lin].length
+  }
+
+  function play() {
+    if (playback.step === playbackLength() - 1) {
+      // Start over
+      playback.step = 0
+      showStep()
+    }
+
+    playback.timer = setInterval(() => {
+      if (playback.step < playbackLength() - 1) {
+        playback.step++
+        showStep()
+      } else {
+        pause()
+      }
+    }, PLAYBACK_INTERVAL)
+    playbackToggle.textContent = '⏸'
+  }
+
+  function pause() {
+    clearInterval(playback.timer)
+    playback.timer = null
+    playbackToggle.textContent = '▶'
+  }
+
+  function stepTo(step) {
+    pause()
+    playback.step = Math.min(Math.max(step, 0), playbackLength() - 1)
+    showStep()
+  }
+
+  function stopPlayback() {
+    if (playback.partition === null) {
+      return
+    }
+
+    pause()
+    if (playback.current !== null) {
+      setElementClass(playback.partition, playback.current, 'playback-current', false)
+    }
+
+    playback.partition = null
+    playbackPanel.classList.add('inactive')
+    resetHighlight()
+  }
+
+  document.querySelector('#play-link').addEventListener('click', startPlayback)
+  playbackToggle.addEventListener('click', () => {
+    if (playback.timer === null) {
+      play()
+    } else {
+      pause()
+    }
+  })
+  document.querySelector('#playback-previous').addEventListener('click', () => {
+    stepTo(playback.step - 1)
+  })
+  document.querySelector('#playback-next').addEventListener('click', () => {
+    stepTo(playback.step + 1)
+  })
+  document.querySelector('#playback-close').addEventListener('click', stopPlayback)
+  document.addEventListener('keydown', (event_) => {
+    if (playback.partition === null) {
+      return
+    }
+
+    switch (event_.key) {
+      case 'ArrowLeft': {
+        stepTo(playback.step - 1)
+        break
+      }
+
+      case 'ArrowRight': {
+        stepTo(playback.step + 1)
+        break
+      }
+
+      case 'Escape': {
+        stopPlayback()
+        break
+      }
+
+      default: {
+        return
+      }
+    }
+
+    event_.preventDefault()
+  })
+
+  // Keyboard navigation. The canvas can be focused, and then the arrow keys
+  // move the focus between elements: left and right to the previous or next
+  // one in the same row, and up and down to the one in the row above or
+  // below that's closest in time. Enter or space selects the focused element,
+  // as clicking does, and escape deselects it. Since only the elements in
+  // view are drawn, the focus stays on the canvas, which points to the
+  // focused element with aria-activedescendant, and the focused element is
+  // also announced in a live region, for screen readers.
+  const canvas = document.querySelector('#canvas')
+  const announcement = document.querySelector('#announcement')
+  let focused = null // [partition, index]
+
+  // The rows, in order from top to bottom, each with its elements in order
+  // of time, and the row and position of each element
+  const rows = []
+  const rowOf = new Map()
+  for (const band of bands) {
+    const byClient = new Map(band.rows.map((clientId) => [clientId, []]))
+    for (const partition of band.partitions) {
+      for (const [index, element] of allData[partition].History.entries()) {
+        byClient.get(element.ClientId).push([partition, index])
+      }
+    }
+
+    for (const elements of byClient.values()) {
+      elements.sort((a, b) => allData[a[0]].History[a[1]].Start - allData[b[0]].History[b[1]].Start)
+      for (const [position, [partition, index]] of elements.entries()) {
+        rowOf.set(`${partition}/${index}`, {row: rows.length, position})
+      }
+
+      rows.push({band, elements})
+    }
+  }
+
+  function elementLabel(partition, index) {
+    const element = allData[partition].History[index]
+    const row =
+      element.ClientId < realClients
+        ? `Client ${rowLabel(element.ClientId)}`
+        : rowLabel(element.ClientId)
+    if (element.Annotation) {
+      return `${row}: ${element.Description}`
+    }
+
+    return `${row}: ${element.Description}, from ${element.OriginalStart} to ${element.OriginalEnd}`
+  }
+
+  function setFocused(partition, index) {
+    if (focused !== null) {
+      setElementClass(focused[0], focused[1], 'focused', false)
+    }
+
+    focused = [partition, index]
+    setElementClass(partition, index, 'focused', true)
+    canvas.setAttribute('aria-activedescendant', `element-${partition}-${index}`)
+  }
+
+  function focusElement(partition, index) {
+    setFocused(partition, index)
+    const element = allData[partition].History[index]
+    const y = rowY(partition, element.ClientId)
+    scrollToPoint(t0x + (xPos[element.Start] + xPos[element.End]) / 2, partition, y)
+    announcement.textContent = elementLabel(partition, index)
+    if (!frozen()) {
+      highlight(partition, index)
+      showTooltipAt(partition, index)
+    }
+  }
+
+  // Shows the element's tooltip next to it, as when it's hovered over
+  function showTooltipAt(partition, index) {
+    const element = allData[partition].History[index]
+    const rect = svg.getBoundingClientRect()
+    const target = {dataset: {partition, index}}
+    tooltip.style.display = 'block'
+    handleMouseMove.call(target, {
+      pageX: rect.left + window.scrollX + (t0x + xPos[element.End]) * zoom,
+      pageY:
+        rect.top +
+        window.scrollY +
+        (bandOf[partition].top + rowY(partition, element.ClientId) + BOX_HEIGHT) * zoom,
+    })
+  }
+
+  function moveFocus(key) {
+    if (focused === null) {
+      const first = selected
+        ? selectedIndex
+        : rows.find((row) => row.elements.length > 0)?.elements[0]
+      if (first !== undefined) {
+        focusElement(...first)
+      }
+
+      return
+    }
+
+    const {row, position} = rowOf.get(`${focused[0]}/${focused[1]}`)
+    const {elements} = rows[row]
+    switch (key) {
+      case 'ArrowLeft':
+      case 'ArrowRight': {
+        const next = elements[position + (key === 'ArrowLeft' ? -1 : 1)]
+        if (next !== undefined) {
+          focusElement(...next)
+        }
+
+        break
+      }
+
+      default: {
+        // Find the closest row above or below, in an expanded band, with
+        // elements in it, and the element in it that's closest in time
+        const step = key === 'ArrowUp' ? -1 : 1
+        let r = row + step
+        while (
+          r >= 0 &&
+          r < rows.length &&
+          (rows[r].band.collapsed || rows[r].elements.length === 0)
+        ) {
+          r += step
+        }
+
+        if (r < 0 || r >= rows.length) {
+          return
+        }
+
+        const current = allData[focused[0]].History[focused[1]]
+        const middle = (current.Start + current.End) / 2
+        let best = null
+        let bestDistance = Infinity
+        for (const [partition, index] of rows[r].elements) {
+          const element = allData[partition].History[index]
+          const distance =
+            middle < element.Start ? element.Start - middle : Math.max(middle - element.End, 0)
+          if (distance < bestDistance) {
+            best = [partition, index]
+            bestDistance = distance
+          }
+        }
+
+        focusElement(...best)
+      }
+    }
+  }
+
+  canvas.addEventListener('keydown', (event_) => {
+    if (playback.partition !== null) {
+      return // The arrow keys step through the playback instead
+    }
+
+    switch (event_.key) {
+      case 'ArrowLeft':
+      case 'ArrowRight':
+      case 'ArrowUp':
+      case 'ArrowDown': {
+        moveFocus(event_.key)
+        break
+      }
+
+      case 'Enter':
+      case ' ': {
+        if (focused !== null) {
+          if (toggleSelection(...focused)) {
+            showTooltipAt(...focused)
+          }
+
+          announcement.textContent =
+            (selected ? 'Selected ' : 'Deselected ') + elementLabel(...focused)
+        }
+
+        break
+      }
+
+      case 'Escape': {
+        handleBgClick()
+        break
+      }
+
+      default: {
+        return
+      }
+    }
+
+    event_.preventDefault()
+  })
+  canvas.addEventListener('blur', () => {
+    if (!frozen()) {
+      handleMouseOut()
+    }
+  })
+  for (const id of ['#jump-link', '#zoom-reset', '#play-link']) {
+    makeButton(document.querySelector(id))
+  }
+
+  // The view (zoom, scroll position, collapsed partitions, and selected
+  // element) is kept in the URL's fragment, so that a link to the file, e.g.,
+  // in a bug report, opens the same view. Saving is debounced, because
+  // browsers limit how often the URL can be replaced.
+  const SAVE_DELAY = 250 // Milliseconds
+  let saveTimer = null
+  let restoring = false
+  function saveViewState() {
+    if (restoring) {
+      return
+    }
+
+    clearTimeout(saveTimer)
+    saveTimer = setTimeout(() => {
+      const rect = svg.getBoundingClientRect()
+      const parameters = new URLSearchParams()
+      if (zoom !== 1) {
+        parameters.set('zoom', zoom.toFixed(4))
+      }
+
+      parameters.set('x', Math.round(Math.max(-rect.left, 0) / zoom))
+      parameters.set('y', Math.round(Math.max(-rect.top, 0) / zoom))
+      const collapsed = bands.flatMap((band, i) => (band.collapsed ? [i] : []))
+      if (collapsed.length > 0) {
+        parameters.set('collapsed', collapsed.join(','))
+      }
+
+      if (selected) {
+        parameters.set('selected', selectedIndex.join('.'))
+      }
+
+      window.history.replaceState(null, '', '#' + parameters.toString())
+    }, SAVE_DELAY)
+  }
+
+  function loadViewState() {
+    const parameters = new URLSearchParams(window.location.hash.slice(1))
+    restoring = true
+    for (const i of (parameters.get('collapsed') ?? '').split(',')) {
+      const band = bands[Number.parseInt(i, 10)]
+      if (band !== undefined && band.header !== null) {
+        band.collapsed = true
+      }
+    }
+
+    layoutBands()
+    const z = Number.parseFloat(parameters.get('zoom'))
+    if (z > 0) {
+      zoom = Math.min(z, MAX_ZOOM)
+      applyZoom()
+    }
+
+    const [partition, index] = (parameters.get('selected') ?? '')
+      .split('.')
+      .map((n) => Number.parseInt(n, 10))
+    if (allData[partition]?.History[index] !== undefined && !bandOf[partition].collapsed) {
+      select(partition, index)
+    }
+
+    if (parameters.has('x') || parameters.has('y')) {
+      const rect = svg.getBoundingClientRect()
+      window.scrollTo(
+        rect.left + window.scrollX + (Number.parseFloat(parameters.get('x')) || 0) * zoom,
+        rect.top + window.scrollY + (Number.parseFloat(parameters.get('y')) || 0) * zoom
+      )
+    } else {
+      // Go straight to the first failing window, with its start in view
+      const partition = coreHistory.findIndex((p, i) => p.FailingWindow && !bandOf[i].collapsed)
+      if (partition >= 0) {
+        const failing = coreHistory[partition].FailingWindow
+        const x1 = t0x + xPos[failing.Start]
+        const x = Math.min(
+          (x1 + t0x + xPos[failing.End]) / 2,
+          x1 + document.documentElement.clientWidth / 2 / zoom - PADDING
+        )
+        scrollToPoint(x, partition, bandOf[partition].height / 2)
+      }
+    }
+
+    restoring = false
   }
 
+  renderVisible()
   handleMouseOut() // Initialize, same as mouse out
+  loadViewState()
+  updateJump()
 }
@@ -4,6 +4,7 @@ const xoConfig = {
   languageOptions: {
     globals: {
       document: 'readonly',
+      window: 'readonly',
     },
   },
 }
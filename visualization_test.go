@@ -1,9 +1,15 @@
 package porcupine
 
 import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
 	"os"
 	"reflect"
+	"sort"
+	"strings"
 	"testing"
+	"testing/fstest"
 )
 
 func visualizeTempFile(t *testing.T, model Model, info LinearizationInfo) {
@@ -35,8 +41,8 @@ func TestVisualizationMultipleLengths(t *testing.T) {
 		t.Fatalf("expected output %v, got output %v", Illegal, res)
 	}
 	data := computeVisualizationData(kvModel, info)
-	expected := []partitionVisualizationData{{
-		History: []historyElement{
+	expected := []VizPartition{{
+		History: []VizOperation{
 			{ClientId: 0, Start: 0, OriginalStart: "0", End: 1300, OriginalEnd: "100", Description: "get('x') -> 'w'"},
 			{ClientId: 1, Start: 100, OriginalStart: "5", End: 200, OriginalEnd: "10", Description: "put('x', 'y')"},
 			{ClientId: 2, Start: 0, OriginalStart: "0", End: 200, OriginalEnd: "10", Description: "put('x', 'z')"},
@@ -45,17 +51,19 @@ func TestVisualizationMultipleLengths(t *testing.T) {
 			{ClientId: 5, Start: 400, OriginalStart: "25", End: 600, OriginalEnd: "35", Description: "get('x') -> 'z'"},
 			{ClientId: 3, Start: 500, OriginalStart: "30", End: 700, OriginalEnd: "40", Description: "get('x') -> 'y'"},
 		},
-		PartialLinearizations: []partialLinearization{
+		PartialLinearizations: [][]VizLinearizationStep{
 			{{2, "z"}, {1, "y"}, {3, "y"}, {6, "y"}, {4, "w"}, {0, "w"}},
 			{{1, "y"}, {2, "z"}, {5, "z"}},
 		},
 		Largest: map[int]int{0: 0, 1: 0, 2: 0, 3: 0, 4: 0, 5: 1, 6: 0},
+		// the first get is in progress throughout
+		FailingWindow: &VizWindow{Start: 0, OriginalStart: "0", End: 1300, OriginalEnd: "100"},
 	}, {
-		History: []historyElement{
+		History: []VizOperation{
 			{ClientId: 4, Start: 900, OriginalStart: "50", End: 1200, OriginalEnd: "90", Description: "get('y') -> 'a'"},
 			{ClientId: 2, Start: 1000, OriginalStart: "55", End: 1100, OriginalEnd: "85", Description: "put('y', 'a')"},
 		},
-		PartialLinearizations: []partialLinearization{
+		PartialLinearizations: [][]VizLinearizationStep{
 			{{1, "a"}, {0, "a"}},
 		},
 		Largest: map[int]int{0: 0, 1: 0},
@@ -212,3 +220,481 @@ func TestVisualizeAnnotationsNoEvents(t *testing.T) {
 	info.AddAnnotations(annotations)
 	visualizeTempFile(t, kvModel, info)
 }
+
+func TestVisualizationTags(t *testing.T) {
+	ops := []TaggedOperation{
+		{Operation{0, kvInput{op: 1, key: "x", value: "y"}, 0, kvOutput{}, 10}, map[string]string{"request": "r1", "node": "n1"}},
+		{Operation{1, kvInput{op: 1, key: "z", value: "w"}, 0, kvOutput{}, 10}, map[string]string{"request": "r2"}},
+		{Operation{1, kvInput{op: 0, key: "x"}, 20, kvOutput{"y"}, 30}, nil},
+		// identical except for its tags
+		{Operation{1, kvInput{op: 0, key: "x"}, 20, kvOutput{"y"}, 30}, map[string]string{"retry": "1"}},
+	}
+	model := kvModel
+	model.DescribeTaggedOperation = func(input, output interface{}, tags map[string]string) string {
+		return kvModel.DescribeOperation(input, output) + " @" + tags["node"]
+	}
+	res, info := CheckTaggedOperationsVerbose(model, ops, 0)
+	if res != Ok {
+		t.Fatalf("expected output %v, got output %v", Ok, res)
+	}
+	data := computeVisualizationData(model, info)
+	var tagged []map[string]string
+	var descriptions []string
+	for _, partition := range data.Partitions {
+		for _, elem := range partition.History {
+			tagged = append(tagged, elem.Tags)
+			descriptions = append(descriptions, elem.Description)
+		}
+	}
+	expectedTags := []map[string]string{
+		{"request": "r1", "node": "n1"}, nil, {"retry": "1"},
+		{"request": "r2"},
+	}
+	if !reflect.DeepEqual(expectedTags, tagged) {
+		t.Fatalf("expected tags %v, got %v", expectedTags, tagged)
+	}
+	if descriptions[0] != "put('x', 'y') @n1" {
+		t.Fatalf("expected description to include tags, got %q", descriptions[0])
+	}
+	visualizeTempFile(t, model, info)
+}
+
+func TestVisualizationTaggedEvents(t *testing.T) {
+	events := []TaggedEvent{
+		{Event{0, CallEvent, registerInput{false, 1}, 0}, map[string]string{"request": "r1", "attempt": "1"}},
+		{Event{1, CallEvent, registerInput{true, 0}, 1}, nil},
+		{Event{0, ReturnEvent, 0, 0}, map[string]string{"attempt": "2", "node": "n2"}},
+		{Event{1, ReturnEvent, 1, 1}, nil},
+	}
+	res, info := CheckTaggedEventsVerbose(registerModel, events, 0)
+	if res != Ok {
+		t.Fatalf("expected output %v, got output %v", Ok, res)
+	}
+	data := computeVisualizationData(registerModel, info)
+	history := data.Partitions[0].History
+	expected := map[string]string{"request": "r1", "attempt": "2", "node": "n2"}
+	if !reflect.DeepEqual(expected, history[0].Tags) || history[1].Tags != nil {
+		t.Fatalf("expected merged tags %v, got %v and %v", expected, history[0].Tags, history[1].Tags)
+	}
+}
+
+func TestVisualizationPlacement(t *testing.T) {
+	ops := []Operation{
+		{0, registerInput{false, 1}, 0, 0, 100},
+		{1, registerInput{true, 0}, 10, 1, 20},
+	}
+	res, info := CheckOperationsVerbose(registerModel, ops, 0)
+	if res != Ok {
+		t.Fatalf("expected output %v, got output %v", Ok, res)
+	}
+	data := computeVisualizationData(registerModel, info)
+	if data.Partitions[0].History[0].Window != nil {
+		t.Fatal("expected no placement windows unless requested")
+	}
+	info.ShowPlacement()
+	data = computeVisualizationData(registerModel, info)
+	expected := &VizWindow{Start: 0, OriginalStart: "0", End: 200, OriginalEnd: "20"}
+	if !reflect.DeepEqual(expected, data.Partitions[0].History[0].Window) {
+		t.Fatalf("expected window %+v, got %+v", expected, data.Partitions[0].History[0].Window)
+	}
+	visualizeTempFile(t, registerModel, info)
+}
+
+func TestVisualizationSessions(t *testing.T) {
+	// one client, multiplexing two sessions
+	ops := []TaggedOperation{
+		{Operation{0, registerInput{false, 1}, 0, 0, 10}, map[string]string{SessionTag: "a"}},
+		{Operation{0, registerInput{true, 0}, 5, 1, 15}, map[string]string{SessionTag: "b"}},
+		{Operation{1, registerInput{true, 0}, 20, 1, 30}, nil},
+	}
+	res, info := CheckTaggedOperationsVerbose(registerModel, ops, 0)
+	if res != Ok {
+		t.Fatalf("expected output %v, got output %v", Ok, res)
+	}
+	data := computeVisualizationData(registerModel, info)
+	var sessions []string
+	for _, elem := range data.Partitions[0].History {
+		sessions = append(sessions, elem.SessionId)
+	}
+	if !reflect.DeepEqual([]string{"a", "b", ""}, sessions) {
+		t.Fatalf("expected sessions in visualization data, got %v", sessions)
+	}
+	visualizeTempFile(t, registerModel, info)
+}
+
+func TestVisualizationEventSessions(t *testing.T) {
+	// the session is tagged on the call events
+	events := []TaggedEvent{
+		{Event{0, CallEvent, registerInput{false, 1}, 0}, map[string]string{SessionTag: "s1"}},
+		{Event{0, CallEvent, registerInput{true, 0}, 1}, map[string]string{SessionTag: "s2"}},
+		{Event{0, ReturnEvent, 0, 0}, nil},
+		{Event{0, ReturnEvent, 1, 1}, nil},
+	}
+	res, info := CheckTaggedEventsVerbose(registerModel, events, 0)
+	if res != Ok {
+		t.Fatalf("expected output %v, got output %v", Ok, res)
+	}
+	data := computeVisualizationData(registerModel, info)
+	var sessions []string
+	for _, elem := range data.Partitions[0].History {
+		sessions = append(sessions, elem.SessionId)
+	}
+	if !reflect.DeepEqual([]string{"s1", "s2"}, sessions) {
+		t.Fatalf("expected sessions in visualization data, got %v", sessions)
+	}
+}
+
+func TestVisualizationPartitionDescriptions(t *testing.T) {
+	ops := []Operation{
+		{0, kvInput{op: 1, key: "x", value: "y"}, 0, kvOutput{}, 10},
+		{1, kvInput{op: 0, key: "x"}, 20, kvOutput{"y"}, 30},
+		{1, kvInput{op: 1, key: "z", value: "w"}, 40, kvOutput{}, 50},
+	}
+	model := kvModel
+	model.DescribePartition = func(history []Operation) string {
+		return fmt.Sprintf("key '%s' (%d)", history[0].Input.(kvInput).key, len(history))
+	}
+	res, info := CheckOperationsVerbose(model, ops, 0)
+	if res != Ok {
+		t.Fatalf("expected output %v, got output %v", Ok, res)
+	}
+	data := computeVisualizationData(model, info)
+	var descriptions []string
+	for _, partition := range data.Partitions {
+		descriptions = append(descriptions, partition.Description)
+	}
+	sort.Strings(descriptions)
+	if !reflect.DeepEqual([]string{"key 'x' (2)", "key 'z' (1)"}, descriptions) {
+		t.Fatalf("expected partition descriptions, got %v", descriptions)
+	}
+	visualizeTempFile(t, model, info)
+}
+
+func TestVisualizeWithOptions(t *testing.T) {
+	res, info := CheckOperationsVerbose(registerModel, registerHistory(10), 0)
+	if res != Ok {
+		t.Fatalf("expected output %v, got output %v", Ok, res)
+	}
+	var light, dark strings.Builder
+	if err := Visualize(registerModel, info, &light); err != nil {
+		t.Fatalf("visualization failed: %v", err)
+	}
+	opts := VisualizeOptions{Theme: DarkTheme, FontSize: 12, Palette: Palette{Operation: "#123456"}}
+	if err := VisualizeWithOptions(registerModel, info, &dark, opts); err != nil {
+		t.Fatalf("visualization failed: %v", err)
+	}
+	for _, expected := range []string{"--background: #ffffff;", "--font-size: 16px;", "--operation: #42d1f5;"} {
+		if !strings.Contains(light.String(), expected) {
+			t.Fatalf("expected default visualization to contain %q", expected)
+		}
+	}
+	for _, expected := range []string{"--background: #1e1e1e;", "--font-size: 12px;", "--operation: #123456;"} {
+		if !strings.Contains(dark.String(), expected) {
+			t.Fatalf("expected dark visualization to contain %q", expected)
+		}
+	}
+}
+
+func TestVisualizeColorOperation(t *testing.T) {
+	res, info := CheckOperationsVerbose(registerModel, registerHistory(10), 0)
+	if res != Ok {
+		t.Fatalf("expected output %v, got output %v", Ok, res)
+	}
+	opts := VisualizeOptions{ColorOperation: func(input, output interface{}) string {
+		if input.(registerInput).op {
+			return "#00ff00" // reads
+		}
+		return ""
+	}}
+	data := computeVisualizationData(registerModel, info)
+	colorOperations(data, info, opts.ColorOperation)
+	for _, element := range data.Partitions[0].History {
+		read := strings.HasPrefix(element.Description, "get")
+		if read != (element.Color == "#00ff00") || !read && element.Color != "" {
+			t.Fatalf("unexpected color %q for %q", element.Color, element.Description)
+		}
+	}
+	var out strings.Builder
+	if err := VisualizeWithOptions(registerModel, info, &out, opts); err != nil {
+		t.Fatalf("visualization failed: %v", err)
+	}
+	if !strings.Contains(out.String(), `"Color":"#00ff00"`) {
+		t.Fatal("expected the visualization to contain the colors")
+	}
+}
+
+func TestVisualizeCodec(t *testing.T) {
+	res, info := CheckOperationsVerbose(registerModel, registerHistory(4), 0)
+	if res != Ok {
+		t.Fatalf("expected output %v, got output %v", Ok, res)
+	}
+	codec := &FuncCodec{MarshalInput: func(input interface{}) ([]byte, error) {
+		in := input.(registerInput)
+		return json.Marshal(map[string]interface{}{"get": in.op, "value": in.value})
+	}}
+	var out strings.Builder
+	if err := VisualizeWithOptions(registerModel, info, &out, VisualizeOptions{Codec: codec}); err != nil {
+		t.Fatalf("visualization failed: %v", err)
+	}
+	for _, expected := range []string{`"Input":{"get":false,"value":1}`, `"Output":0`} {
+		if !strings.Contains(out.String(), expected) {
+			t.Fatalf("expected visualization to contain %q", expected)
+		}
+	}
+
+	codec.MarshalOutput = func(output interface{}) ([]byte, error) {
+		return nil, fmt.Errorf("unsupported output %v", output)
+	}
+	if err := VisualizeWithOptions(registerModel, info, &out, VisualizeOptions{Codec: codec}); err == nil {
+		t.Fatal("expected an error from the codec")
+	}
+}
+
+func TestVisualizeStatic(t *testing.T) {
+	ops := []Operation{
+		{0, registerInput{false, 1}, 0, 0, 10},
+		{1, registerInput{true, 0}, 20, 0, 30},
+	}
+	res, info := CheckOperationsVerbose(registerModel, ops, 0)
+	if res != Illegal {
+		t.Fatalf("expected output %v, got output %v", Illegal, res)
+	}
+	info.AddAnnotations([]Annotation{{Tag: "Test <Framework>", Start: 0, Description: "start"}})
+	var out strings.Builder
+	if err := VisualizeWithOptions(registerModel, info, &out, VisualizeOptions{Static: true}); err != nil {
+		t.Fatalf("visualization failed: %v", err)
+	}
+	page := out.String()
+	if strings.Contains(page, "<script") {
+		t.Fatal("expected no scripts in a static visualization")
+	}
+	for _, expected := range []string{"<svg", "<title>put(&#39;1&#39;)\n0 - 10</title>", `class="op illegal"`, "Test &lt;Framework&gt;"} {
+		if !strings.Contains(page, expected) {
+			t.Fatalf("expected static visualization to contain %q", expected)
+		}
+	}
+
+	_, info = CheckOperationsVerbose(registerModel, registerHistory(10000), 0)
+	out.Reset()
+	if err := VisualizeWithOptions(registerModel, info, &out, VisualizeOptions{Static: true}); err != nil {
+		t.Fatalf("visualization failed: %v", err)
+	}
+	if out.Len() > 100*1024 {
+		t.Fatalf("expected a static visualization of at most 100KB, got %d bytes", out.Len())
+	}
+	if !strings.Contains(out.String(), "more operations and annotations are not shown") {
+		t.Fatal("expected a note about operations that are not shown")
+	}
+}
+
+func TestVisualizeWithAnnotations(t *testing.T) {
+	type partition struct {
+		nodes []string
+	}
+	res, info := CheckOperationsVerbose(registerModel, registerHistory(10), 0)
+	if res != Ok {
+		t.Fatalf("expected output %v, got output %v", Ok, res)
+	}
+	info.AddAnnotations([]Annotation{{Tag: "Test Framework", Start: 0, Description: "start"}})
+	annotations := []PayloadAnnotation{
+		{Annotation{Tag: "Nemesis", Start: 10, End: 40}, partition{[]string{"n1", "n2"}}},
+		{Annotation{Tag: "Nemesis", Start: 50, End: 60, Description: "heal"}, partition{}},
+		{Annotation{Tag: "Log", Start: 20}, "election timeout"},
+	}
+	describe := func(payload interface{}) (string, string) {
+		if p, ok := payload.(partition); ok {
+			return "partition " + strings.Join(p.nodes, ","), fmt.Sprintf("%d nodes", len(p.nodes))
+		}
+		return fmt.Sprintf("log: %v", payload), ""
+	}
+	var out strings.Builder
+	if err := VisualizeWithAnnotations(registerModel, info, &out, annotations, describe); err != nil {
+		t.Fatalf("visualization failed: %v", err)
+	}
+	for _, expected := range []string{`"partition n1,n2"`, `"2 nodes"`, `"heal"`, `"0 nodes"`, `"log: election timeout"`, `"start"`} {
+		if !strings.Contains(out.String(), expected) {
+			t.Fatalf("expected visualization to contain %s", expected)
+		}
+	}
+	if len(info.annotations) != 1 {
+		t.Fatalf("expected info not to be modified, got annotations %+v", info.annotations)
+	}
+}
+
+func TestVisualizationInitialState(t *testing.T) {
+	model := registerModel
+	model.DescribeState = func(state interface{}) string {
+		return fmt.Sprintf("register = %d", state.(int))
+	}
+	res, info := CheckOperationsVerbose(model, registerHistory(4), 0)
+	if res != Ok {
+		t.Fatalf("expected output %v, got output %v", Ok, res)
+	}
+	data := computeVisualizationData(model, info)
+	if data.Partitions[0].InitialState != "register = 0" {
+		t.Fatalf("expected initial state description, got %q", data.Partitions[0].InitialState)
+	}
+}
+
+func TestVisualizeCompare(t *testing.T) {
+	model := kvModel
+	model.DescribePartition = func(history []Operation) string {
+		return "key " + history[0].Input.(kvInput).key
+	}
+	_, infoA := CheckOperationsVerbose(model, []Operation{
+		{0, kvInput{op: 1, key: "x", value: "1"}, 0, kvOutput{}, 10},
+		{1, kvInput{op: 0, key: "z"}, 20, kvOutput{""}, 30},
+	}, 0)
+	_, infoB := CheckOperationsVerbose(model, []Operation{
+		{0, kvInput{op: 1, key: "z", value: "1"}, 5, kvOutput{}, 10},
+		{1, kvInput{op: 0, key: "z"}, 20, kvOutput{""}, 30},
+		{1, kvInput{op: 0, key: "y"}, 40, kvOutput{""}, 50},
+	}, 0)
+	var out strings.Builder
+	if err := VisualizeCompare(model, infoA, infoB, &out); err != nil {
+		t.Fatalf("visualization failed: %v", err)
+	}
+	html := out.String()
+	start := strings.Index(html, "const data = ") + len("const data = ")
+	end := start + strings.Index(html[start:], "\n")
+	var data VizData
+	if err := json.Unmarshal([]byte(html[start:end]), &data); err != nil {
+		t.Fatalf("failed to parse visualization data: %v", err)
+	}
+	var descriptions []string
+	for _, partition := range data.Partitions {
+		descriptions = append(descriptions, partition.Description)
+	}
+	expected := []string{"A: key x", "A: key z", "B: key z", "B: key y"}
+	if !reflect.DeepEqual(expected, descriptions) {
+		t.Fatalf("expected partitions %v, got %v", expected, descriptions)
+	}
+	// both histories are on the same timeline, where A's get of z and B's
+	// line up
+	if a, b := data.Partitions[1].History[0], data.Partitions[2].History[1]; a.Start != b.Start || a.End != b.End {
+		t.Fatalf("expected aligned operations, got %+v and %+v", a, b)
+	}
+}
+
+func TestVisualizeClientNames(t *testing.T) {
+	res, info := CheckOperationsVerbose(registerModel, registerHistory(4), 0)
+	if res != Ok {
+		t.Fatalf("expected output %v, got output %v", Ok, res)
+	}
+	opts := VisualizeOptions{ClientNames: map[int]string{0: "node-3/worker-7"}}
+	var out strings.Builder
+	if err := VisualizeWithOptions(registerModel, info, &out, opts); err != nil {
+		t.Fatalf("visualization failed: %v", err)
+	}
+	if !strings.Contains(out.String(), `"ClientNames":{"0":"node-3/worker-7"}`) {
+		t.Fatal("expected the visualization to contain the client names")
+	}
+	opts.Static = true
+	out.Reset()
+	if err := VisualizeWithOptions(registerModel, info, &out, opts); err != nil {
+		t.Fatalf("visualization failed: %v", err)
+	}
+	if !strings.Contains(out.String(), ">node-3/worker-7</text>") || !strings.Contains(out.String(), ">1</text>") {
+		t.Fatal("expected the static visualization to label lanes with client names, or IDs")
+	}
+}
+
+func TestVisualizeTimeFormat(t *testing.T) {
+	start := int64(1700000000 * 1e9)
+	ops := []Operation{
+		{0, registerInput{false, 1}, start, 0, start + 1500000},
+		{1, registerInput{true, 0}, start + 2000000, 1, start + 3000000},
+	}
+	res, info := CheckOperationsVerbose(registerModel, ops, 0)
+	if res != Ok {
+		t.Fatalf("expected output %v, got output %v", Ok, res)
+	}
+	for _, tc := range []struct {
+		format   TimeFormat
+		expected []string
+	}{
+		{RawTime, []string{`"OriginalEnd":"1700000000001500000"`}},
+		{WallClockTime, []string{`"OriginalEnd":"2023-11-14T22:13:20.0015Z"`, `"TimeAxis":true`}},
+		{RelativeTime, []string{`"OriginalStart":"0s"`, `"OriginalEnd":"1.5ms"`, `"TimeAxis":true`}},
+	} {
+		var out strings.Builder
+		if err := VisualizeWithOptions(registerModel, info, &out, VisualizeOptions{TimeFormat: tc.format}); err != nil {
+			t.Fatalf("visualization failed: %v", err)
+		}
+		for _, expected := range tc.expected {
+			if !strings.Contains(out.String(), expected) {
+				t.Fatalf("expected visualization with time format %d to contain %q", tc.format, expected)
+			}
+		}
+		if tc.format == RawTime && strings.Contains(out.String(), `"TimeAxis"`) {
+			t.Fatal("expected no time axis for raw times")
+		}
+	}
+}
+
+func TestVisualizeTemplate(t *testing.T) {
+	ops := []Operation{
+		{0, registerInput{false, 1}, 0, 0, 10},
+	}
+	res, info := CheckOperationsVerbose(registerModel, ops, 0)
+	if res != Ok {
+		t.Fatalf("expected output %v, got output %v", Ok, res)
+	}
+	template := fstest.MapFS{
+		"index.html": {Data: []byte("<style>%s</style><h1>Acme</h1><script>%s render(%s);</script>")},
+		"index.js":   {Data: []byte("function render(data) {}")},
+	}
+	var out strings.Builder
+	if err := VisualizeWithOptions(registerModel, info, &out, VisualizeOptions{Template: template}); err != nil {
+		t.Fatalf("visualization failed: %v", err)
+	}
+	css, err := fs.ReadFile(DefaultVisualizationTemplate, "index.css")
+	if err != nil {
+		t.Fatal(err)
+	}
+	page := out.String()
+	if !strings.HasPrefix(page, "<style>") || !strings.Contains(page, "<h1>Acme</h1>") {
+		t.Fatal("expected the visualization to use the template's index.html")
+	}
+	if !strings.Contains(page, "<script>function render(data) {} render({") {
+		t.Fatal("expected the visualization to use the template's index.js and data")
+	}
+	if !strings.Contains(page, string(css)) {
+		t.Fatal("expected the visualization to fall back to the built-in index.css")
+	}
+}
+
+func TestVisualizeData(t *testing.T) {
+	ops := []Operation{
+		{0, registerInput{false, 1}, 0, 0, 10},
+		{1, registerInput{true, 0}, 20, 1, 30},
+	}
+	res, info := CheckOperationsVerbose(registerModel, ops, 0)
+	if res != Ok {
+		t.Fatalf("expected output %v, got output %v", Ok, res)
+	}
+	data, err := VisualizeData(registerModel, info)
+	if err != nil {
+		t.Fatalf("failed to get visualization data: %v", err)
+	}
+	if len(data.Partitions) != 1 || len(data.Partitions[0].History) != 2 {
+		t.Fatalf("expected 1 partition of 2 operations, got %+v", data.Partitions)
+	}
+	if op := data.Partitions[0].History[1]; op.Description != "get() -> '1'" || op.OriginalEnd != "30" {
+		t.Fatalf("unexpected operation %+v", op)
+	}
+	if steps := data.Partitions[0].PartialLinearizations[0]; len(steps) != 2 || steps[1].StateDescription != "1" {
+		t.Fatalf("unexpected linearization %+v", steps)
+	}
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out strings.Builder
+	if err := Visualize(registerModel, info, &out); err != nil {
+		t.Fatalf("visualization failed: %v", err)
+	}
+	if !strings.Contains(out.String(), string(encoded)) {
+		t.Fatal("expected the visualization to embed the same data")
+	}
+}